@@ -16,19 +16,33 @@ package cmd
 
 import (
 	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/spf13/cobra"
 )
 
 // NewCmdCopy creates a new cobra.Command for the copy subcommand.
 func NewCmdCopy(options *[]crane.Option) *cobra.Command {
-	return &cobra.Command{
+	platforms := ""
+	cmd := &cobra.Command{
 		Use:     "copy SRC DST",
 		Aliases: []string{"cp"},
 		Short:   "Efficiently copy a remote image from src to dst while retaining the digest value",
-		Args:    cobra.ExactArgs(2),
+		Example: `  # Copy only the linux images of a multi-platform index, except for s390x
+  crane copy --platforms 'linux/*,!linux/s390x' ubuntu:latest registry.example.com/ubuntu:latest`,
+		Args: cobra.ExactArgs(2),
 		RunE: func(_ *cobra.Command, args []string) error {
 			src, dst := args[0], args[1]
-			return crane.Copy(src, dst, *options...)
+			opts := *options
+			if platforms != "" {
+				s, err := v1.ParsePlatformSelector(platforms)
+				if err != nil {
+					return err
+				}
+				opts = append(opts, crane.WithPlatforms(s))
+			}
+			return crane.Copy(src, dst, opts...)
 		},
 	}
+	cmd.Flags().StringVar(&platforms, "platforms", "", "Copy only the images of an index whose platforms match this comma-separated list, e.g. linux/amd64,linux/arm64,!windows/*")
+	return cmd
 }
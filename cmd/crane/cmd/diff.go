@@ -0,0 +1,140 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/compare"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdDiff creates a new cobra.Command for the diff subcommand.
+func NewCmdDiff(options *[]crane.Option) *cobra.Command {
+	var files, all bool
+
+	cmd := &cobra.Command{
+		Use:   "diff IMAGE1 IMAGE2",
+		Short: "Diff two images",
+		Example: `  # Show layers that differ between two images
+  crane diff ubuntu:20.04 ubuntu:22.04
+
+  # Show files that were added (+), removed (-), or modified (M)
+  crane diff --files ubuntu:20.04 ubuntu:22.04
+
+  # Show every difference between the manifests, configs and layers
+  crane diff --all ubuntu:20.04 ubuntu:22.04`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := crane.Pull(args[0], *options...)
+			if err != nil {
+				return fmt.Errorf("pulling %s: %w", args[0], err)
+			}
+			b, err := crane.Pull(args[1], *options...)
+			if err != nil {
+				return fmt.Errorf("pulling %s: %w", args[1], err)
+			}
+
+			if files && all {
+				return errors.New("--files and --all are mutually exclusive")
+			}
+			if files {
+				return diffFiles(cmd.OutOrStdout(), a, b)
+			}
+			if all {
+				return diffAll(cmd.OutOrStdout(), a, b)
+			}
+			return diffLayers(cmd.OutOrStdout(), a, b)
+		},
+	}
+	cmd.Flags().BoolVar(&files, "files", false, "Compare the flattened filesystems of the images instead of their layers")
+	cmd.Flags().BoolVar(&all, "all", false, "Show every difference between the images' manifests, configs and layers instead of just their layers")
+
+	return cmd
+}
+
+func diffAll(w io.Writer, a, b v1.Image) error {
+	diffs, err := compare.ImageDifferences(a, b)
+	if err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		fmt.Fprintln(w, d)
+	}
+	return nil
+}
+
+func diffFiles(w io.Writer, a, b v1.Image) error {
+	diff, err := mutate.Diff(a, b)
+	if err != nil {
+		return err
+	}
+	for _, f := range diff.Removed {
+		fmt.Fprintf(w, "- %s %s %d\n", f.Mode, f.Path, f.Size)
+	}
+	for _, f := range diff.Added {
+		fmt.Fprintf(w, "+ %s %s %d\n", f.Mode, f.Path, f.Size)
+	}
+	for _, c := range diff.Modified {
+		fmt.Fprintf(w, "M %s %s %d -> %s %d\n", c.Old.Mode, c.Path, c.Old.Size, c.New.Mode, c.New.Size)
+	}
+	return nil
+}
+
+func diffLayers(w io.Writer, a, b v1.Image) error {
+	al, err := a.Layers()
+	if err != nil {
+		return err
+	}
+	bl, err := b.Layers()
+	if err != nil {
+		return err
+	}
+
+	seen := map[v1.Hash]bool{}
+	for _, l := range bl {
+		h, err := l.DiffID()
+		if err != nil {
+			return err
+		}
+		seen[h] = true
+	}
+	before := map[v1.Hash]bool{}
+	for _, l := range al {
+		h, err := l.DiffID()
+		if err != nil {
+			return err
+		}
+		before[h] = true
+		if !seen[h] {
+			fmt.Fprintf(w, "- %s\n", h)
+		}
+	}
+	for _, l := range bl {
+		h, err := l.DiffID()
+		if err != nil {
+			return err
+		}
+		if !before[h] {
+			fmt.Fprintf(w, "+ %s\n", h)
+		}
+	}
+	return nil
+}
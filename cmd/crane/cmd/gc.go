@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/retention"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdGc creates a new cobra.Command for the gc subcommand.
+func NewCmdGc(options *[]crane.Option) *cobra.Command {
+	var (
+		policyFile string
+		del, j     bool
+	)
+	cmd := &cobra.Command{
+		Use:   "gc REPO",
+		Short: "List or delete the tagged images in a repository that a retention policy selects",
+		Long: `List or delete the tagged images in a repository that a retention policy selects.
+
+The registry API can't list untagged images, or when images were uploaded, so
+images are ordered by the creation time in their configs. Deleting an image
+deletes all of its tags.`,
+		Example: `  # List the images that policy.json selects
+  crane gc registry.example.com/repo --policy=policy.json
+
+  # Delete them
+  crane gc registry.example.com/repo --policy=policy.json --delete`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			b, err := ioutil.ReadFile(policyFile)
+			if err != nil {
+				return err
+			}
+			policy, err := retention.Parse(b)
+			if err != nil {
+				return err
+			}
+
+			o := crane.GetOptions(*options...)
+			repo, err := name.NewRepository(args[0], o.Name...)
+			if err != nil {
+				return err
+			}
+			images, err := retention.List(repo, o.Remote...)
+			if err != nil {
+				return err
+			}
+			deletions, err := retention.DropReferenced(repo, images, policy.Evaluate(images, time.Now()), o.Remote...)
+			if err != nil {
+				return err
+			}
+
+			w := cmd.OutOrStdout()
+			for _, d := range deletions {
+				ref := repo.Digest(d.Digest)
+				if del {
+					if err := remote.Delete(ref, o.Remote...); err != nil {
+						return fmt.Errorf("deleting %s: %w", ref, err)
+					}
+				}
+				if j {
+					if err := json.NewEncoder(w).Encode(d); err != nil {
+						return err
+					}
+				} else {
+					fmt.Fprintf(w, "%s %s\n", ref, d.Reason)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&policyFile, "policy", "", "Path to a JSON retention policy")
+	cmd.Flags().BoolVar(&del, "delete", false, "Delete the selected images instead of only listing them")
+	cmd.Flags().BoolVar(&j, "json", false, "Print each selected image as JSON, one per line")
+	cmd.MarkFlagRequired("policy")
+
+	return cmd
+}
@@ -37,6 +37,8 @@ var Root = New(use, short, []crane.Option{})
 // to share code with gcrane.
 func New(use, short string, options []crane.Option) *cobra.Command {
 	verbose := false
+	trace := false
+	logFormat := "text"
 	insecure := false
 	platform := &platformValue{}
 
@@ -46,12 +48,29 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 		RunE:              func(cmd *cobra.Command, _ []string) error { return cmd.Usage() },
 		DisableAutoGenTag: true,
 		SilenceUsage:      true,
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			options = append(options, crane.WithContext(cmd.Context()))
 			// TODO(jonjohnsonjr): crane.Verbose option?
 			if verbose {
 				logs.Debug.SetOutput(os.Stderr)
 			}
+			if trace {
+				logs.Trace.SetOutput(os.Stderr)
+			}
+			switch logFormat {
+			case "text":
+			case "json":
+				level := logs.LevelInfo
+				if verbose {
+					level = logs.LevelDebug
+				}
+				if trace {
+					level = logs.LevelTrace
+				}
+				logs.SetLogger(logs.NewJSONLogger(os.Stderr, level))
+			default:
+				return fmt.Errorf("--log-format must be one of text or json, got %q", logFormat)
+			}
 			if insecure {
 				options = append(options, crane.Insecure)
 			}
@@ -83,6 +102,7 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 			}
 
 			options = append(options, crane.WithTransport(rt))
+			return nil
 		},
 	}
 
@@ -94,9 +114,11 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 		NewCmdConfig(&options),
 		NewCmdCopy(&options),
 		NewCmdDelete(&options),
+		NewCmdDiff(&options),
 		NewCmdDigest(&options),
 		NewCmdExport(&options),
 		NewCmdFlatten(&options),
+		NewCmdGc(&options),
 		NewCmdList(&options),
 		NewCmdManifest(&options),
 		NewCmdOptimize(&options),
@@ -106,12 +128,15 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 		NewCmdTag(&options),
 		NewCmdValidate(&options),
 		NewCmdVersion(),
+		NewCmdWasm(&options),
 		NewCmdMutate(&options),
 	}
 
 	root.AddCommand(commands...)
 
 	root.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable debug logs")
+	root.PersistentFlags().BoolVar(&trace, "trace", false, "Enable trace logs with request timings and throughput")
+	root.PersistentFlags().StringVar(&logFormat, "log-format", logFormat, "Format of logs, either text or json (one event per line)")
 	root.PersistentFlags().BoolVar(&insecure, "insecure", false, "Allow image references to be fetched without TLS")
 	root.PersistentFlags().Var(platform, "platform", "Specifies the platform in the form os/arch[/variant][:osversion] (e.g. linux/amd64).")
 
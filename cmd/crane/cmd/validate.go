@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/logs"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
@@ -27,15 +28,16 @@ import (
 // NewCmdValidate creates a new cobra.Command for the validate subcommand.
 func NewCmdValidate(options *[]crane.Option) *cobra.Command {
 	var (
-		tarballPath, remoteRef string
-		fast                   bool
+		tarballPath, remoteRef      string
+		fast, compressedOnly, light bool
+		ignore                      []string
 	)
 
 	validateCmd := &cobra.Command{
 		Use:   "validate",
 		Short: "Validate that an image is well-formed",
 		Args:  cobra.ExactArgs(0),
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			for flag, maker := range map[string]func(string, ...crane.Option) (v1.Image, error){
 				tarballPath: makeTarball,
 				remoteRef:   crane.Pull,
@@ -48,10 +50,24 @@ func NewCmdValidate(options *[]crane.Option) *cobra.Command {
 					return fmt.Errorf("failed to read image %s: %w", flag, err)
 				}
 
-				opt := []validate.Option{}
+				opt := []validate.Option{
+					validate.WithContext(cmd.Context()),
+					validate.WithProgress(func(p validate.Progress) {
+						logs.Progress.Printf("validating layer %d/%d %s", p.Layer+1, p.Layers, p.Digest)
+					}),
+				}
 				if fast {
 					opt = append(opt, validate.Fast)
 				}
+				if compressedOnly {
+					opt = append(opt, validate.WithCompressedOnly())
+				}
+				if light {
+					opt = append(opt, validate.WithLight())
+				}
+				for _, kind := range ignore {
+					opt = append(opt, validate.Ignore(validate.Kind(kind)))
+				}
 				if err := validate.Image(img, opt...); err != nil {
 					fmt.Printf("FAIL: %s: %v\n", flag, err)
 					return err
@@ -64,6 +80,9 @@ func NewCmdValidate(options *[]crane.Option) *cobra.Command {
 	validateCmd.Flags().StringVar(&tarballPath, "tarball", "", "Path to tarball to validate")
 	validateCmd.Flags().StringVar(&remoteRef, "remote", "", "Name of remote image to validate")
 	validateCmd.Flags().BoolVar(&fast, "fast", false, "Skip downloading/digesting layers")
+	validateCmd.Flags().BoolVar(&compressedOnly, "compressed-only", false, "Digest compressed layers, but skip decompressing them to check diffids")
+	validateCmd.Flags().BoolVar(&light, "light", false, "Only check manifests and that blobs exist with the right digest and size")
+	validateCmd.Flags().StringSliceVar(&ignore, "ignore", nil, "Kinds of findings to ignore (e.g. mediatype-mismatch)")
 
 	return validateCmd
 }
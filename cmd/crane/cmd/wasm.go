@@ -0,0 +1,101 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/artifacts/wasm"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdWasm creates a new cobra.Command for the wasm subcommand.
+func NewCmdWasm(options *[]crane.Option) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wasm",
+		Short: "Push and pull WebAssembly modules as OCI artifacts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Usage()
+		},
+	}
+	cmd.AddCommand(NewCmdWasmPush(options), NewCmdWasmPull(options))
+	return cmd
+}
+
+// NewCmdWasmPush creates a new cobra.Command for the wasm push subcommand.
+func NewCmdWasmPush(options *[]crane.Option) *cobra.Command {
+	return &cobra.Command{
+		Use:     "push FILE IMAGE",
+		Short:   "Push a WebAssembly module or component to a remote registry",
+		Long:    `Pushes FILE as a Wasm OCI artifact and prints the digest of the pushed artifact.`,
+		Example: `  crane wasm push hello.wasm registry.example.com/wasm/hello:v1`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, dst := args[0], args[1]
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			d, err := wasm.Push(b, dst, *options...)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), d)
+			return nil
+		},
+	}
+}
+
+// NewCmdWasmPull creates a new cobra.Command for the wasm pull subcommand.
+func NewCmdWasmPull(options *[]crane.Option) *cobra.Command {
+	return &cobra.Command{
+		Use:     "pull IMAGE FILE",
+		Short:   "Pull a WebAssembly module or component from a remote registry",
+		Long:    `Writes the module in the Wasm OCI artifact IMAGE to FILE, or to stdout if FILE is "-".`,
+		Example: `  crane wasm pull registry.example.com/wasm/hello:v1 hello.wasm`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, path := args[0], args[1]
+			mod, err := wasm.Pull(src, *options...)
+			if err != nil {
+				return err
+			}
+			rc, err := mod.Module.Compressed()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+
+			if path == "-" {
+				_, err := io.Copy(cmd.OutOrStdout(), rc)
+				return err
+			}
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(f, rc); err != nil {
+				return err
+			}
+			return f.Close()
+		},
+	}
+}
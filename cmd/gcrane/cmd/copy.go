@@ -15,7 +15,11 @@
 package cmd
 
 import (
+	"bufio"
+	"fmt"
+	"os"
 	"runtime"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/gcrane"
 	"github.com/spf13/cobra"
@@ -25,23 +29,108 @@ import (
 func NewCmdCopy() *cobra.Command {
 	recursive := false
 	jobs := 1
+	var (
+		includeRepos, excludeRepos []string
+		includeTags, excludeTags   []string
+		since, state               string
+		dryRun, referrers          bool
+	)
 	cmd := &cobra.Command{
 		Use:     "copy SRC DST",
 		Aliases: []string{"cp"},
 		Short:   "Efficiently copy a remote image from src to dst",
-		Args:    cobra.ExactArgs(2),
+		Example: `  # Mirror the releases of everything under team-a, printing the plan first
+  gcrane cp -r gcr.io/src gcr.io/dst --include-repo='team-a/*' --include-tag='v*' --dry-run
+
+  # Copy everything with 16 concurrent copies, resuming if interrupted
+  gcrane cp -r gcr.io/src gcr.io/dst --jobs=16 --state=copy.state`,
+		Args: cobra.ExactArgs(2),
 		RunE: func(cc *cobra.Command, args []string) error {
 			src, dst := args[0], args[1]
 			ctx := cc.Context()
-			if recursive {
-				return gcrane.CopyRepository(ctx, src, dst, gcrane.WithJobs(jobs), gcrane.WithUserAgent(userAgent()), gcrane.WithContext(ctx))
+			if !recursive {
+				if dryRun || referrers || since != "" || state != "" || len(includeRepos)+len(excludeRepos)+len(includeTags)+len(excludeTags) != 0 {
+					return fmt.Errorf("filters, --referrers, --state and --dry-run require --recursive")
+				}
+				return gcrane.Copy(src, dst, gcrane.WithUserAgent(userAgent()), gcrane.WithContext(ctx))
+			}
+
+			opts := []gcrane.Option{
+				gcrane.WithJobs(jobs),
+				gcrane.WithUserAgent(userAgent()),
+				gcrane.WithContext(ctx),
+				gcrane.WithIncludeRepos(includeRepos...),
+				gcrane.WithExcludeRepos(excludeRepos...),
+				gcrane.WithIncludeTags(includeTags...),
+				gcrane.WithExcludeTags(excludeTags...),
+			}
+			if since != "" {
+				digests, err := readDigests(since)
+				if err != nil {
+					return err
+				}
+				opts = append(opts, gcrane.WithSkipDigests(digests...))
+			}
+			if state != "" {
+				opts = append(opts, gcrane.WithState(state))
+			}
+			if referrers {
+				opts = append(opts, gcrane.WithReferrers())
 			}
-			return gcrane.Copy(src, dst, gcrane.WithUserAgent(userAgent()), gcrane.WithContext(ctx))
+			if dryRun {
+				w := cc.OutOrStdout()
+				var images int
+				var total uint64
+				opts = append(opts, gcrane.WithDryRun(), gcrane.WithPlan(func(p gcrane.PlannedCopy) {
+					images++
+					total += p.Size
+					fmt.Fprintf(w, "%s@%s -> %s %s (%d bytes)\n", p.Source, p.Digest, p.Destination, strings.Join(p.Tags, ","), p.Size)
+				}))
+				if err := gcrane.CopyRepository(ctx, src, dst, opts...); err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "%d images, up to %d bytes\n", images, total)
+				return nil
+			}
+			return gcrane.CopyRepository(ctx, src, dst, opts...)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Whether to recurse through repos")
 	cmd.Flags().IntVarP(&jobs, "jobs", "j", runtime.GOMAXPROCS(0), "The maximum number of concurrent copies")
+	cmd.Flags().StringSliceVar(&includeRepos, "include-repo", nil, "Only copy repos matching these patterns, relative to SRC (which itself is \".\")")
+	cmd.Flags().StringSliceVar(&excludeRepos, "exclude-repo", nil, "Skip repos matching these patterns, relative to SRC")
+	cmd.Flags().StringSliceVar(&includeTags, "include-tag", nil, "Only copy tags matching these patterns, skipping untagged images")
+	cmd.Flags().StringSliceVar(&excludeTags, "exclude-tag", nil, "Skip tags matching these patterns")
+	cmd.Flags().StringVar(&since, "since", "", "Path to a file of digests to skip, one per line, e.g. from a previous run")
+	cmd.Flags().StringVar(&state, "state", "", "Path to a file recording copied images and repos, to resume from if interrupted")
+	cmd.Flags().BoolVar(&referrers, "referrers", false, "Also copy manifests that refer to each copied image, e.g. signatures, using the referrers API")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print what would be copied, with size estimates, without copying anything")
 
 	return cmd
 }
+
+// readDigests reads the digests from path, one per line. Lines may also be
+// references by digest, like the output of --dry-run.
+func readDigests(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var digests []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		d := fields[0]
+		if i := strings.LastIndex(d, "@"); i != -1 {
+			d = d[i+1:]
+		}
+		digests = append(digests, d)
+	}
+	return digests, s.Err()
+}
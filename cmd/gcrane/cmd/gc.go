@@ -16,61 +16,105 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/gcrane"
-	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/retention"
 	"github.com/spf13/cobra"
 )
 
 // NewCmdGc creates a new cobra.Command for the gc subcommand.
 func NewCmdGc() *cobra.Command {
-	recursive := false
+	var (
+		recursive, del, dryRun, j bool
+		olderThan                 time.Duration
+		keepTags                  int
+		protect                   []string
+		policyFile                string
+	)
 	cmd := &cobra.Command{
 		Use:   "gc",
-		Short: "List images that are not tagged",
-		Args:  cobra.ExactArgs(1),
+		Short: "List images that are not tagged, or delete images according to a retention policy",
+		Example: `  # List untagged images
+  gcrane gc gcr.io/my-project/my-repo
+
+  # Delete images that have been untagged for a week, and all but the
+  # newest 10 tagged images, except releases
+  gcrane gc gcr.io/my-project/my-repo --delete --older-than=168h --keep-tags=10 --protect='^v[0-9]'
+
+  # Delete images according to a declarative retention policy
+  gcrane gc gcr.io/my-project/my-repo --delete --policy=policy.json`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cc *cobra.Command, args []string) error {
-			return gc(cc.Context(), args[0], recursive)
+			policy := gcrane.RetentionPolicy{
+				UntaggedOlderThan: olderThan,
+				KeepTagged:        keepTags,
+			}
+			for _, p := range protect {
+				re, err := regexp.Compile(p)
+				if err != nil {
+					return fmt.Errorf("parsing --protect: %w", err)
+				}
+				policy.Protect = append(policy.Protect, re)
+			}
+			if policyFile != "" {
+				for _, f := range []string{"older-than", "keep-tags", "protect"} {
+					if cc.Flags().Changed(f) {
+						return fmt.Errorf("--policy and --%s are mutually exclusive", f)
+					}
+				}
+				b, err := ioutil.ReadFile(policyFile)
+				if err != nil {
+					return err
+				}
+				if policy.Policy, err = retention.Parse(b); err != nil {
+					return err
+				}
+			}
+
+			opts := []gcrane.Option{gcrane.WithUserAgent(userAgent())}
+			if recursive {
+				opts = append(opts, gcrane.WithRecursive())
+			}
+			if !del || dryRun {
+				opts = append(opts, gcrane.WithDryRun())
+			}
+			return gc(cc.Context(), cc.OutOrStdout(), args[0], policy, j, opts...)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Whether to recurse through repos")
+	cmd.Flags().BoolVar(&del, "delete", false, "Delete the selected images instead of only listing them")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "With --delete, print what would be deleted without deleting it")
+	cmd.Flags().DurationVar(&olderThan, "older-than", 0, "Only select untagged images uploaded longer ago than this")
+	cmd.Flags().IntVar(&keepTags, "keep-tags", 0, "Also select tagged images, except the newest N in each repo")
+	cmd.Flags().StringSliceVar(&protect, "protect", nil, "Never select images with a tag matching these regular expressions")
+	cmd.Flags().StringVar(&policyFile, "policy", "", "Select images according to the retention policy in this JSON file, instead of the flags above")
+	cmd.Flags().BoolVar(&j, "json", false, "Print each selected image as JSON, one per line")
 
 	return cmd
 }
 
-func gc(ctx context.Context, root string, recursive bool) error {
-	repo, err := name.NewRepository(root)
-	if err != nil {
-		return err
-	}
-
-	opts := []google.Option{
-		google.WithAuthFromKeychain(gcrane.Keychain),
-		google.WithUserAgent(userAgent()),
-		google.WithContext(ctx),
-	}
-
-	if recursive {
-		return google.Walk(repo, printUntaggedImages, opts...)
-	}
-
-	tags, err := google.List(repo, opts...)
-	return printUntaggedImages(repo, tags, err)
-}
-
-func printUntaggedImages(repo name.Repository, tags *google.Tags, err error) error {
-	if err != nil {
-		return err
-	}
-
-	for digest, manifest := range tags.Manifests {
-		if len(manifest.Tags) == 0 {
-			fmt.Printf("%s@%s\n", repo, digest)
+func gc(ctx context.Context, w io.Writer, root string, policy gcrane.RetentionPolicy, j bool, opts ...gcrane.Option) error {
+	report := func(d gcrane.Deletion) error {
+		if j {
+			return json.NewEncoder(w).Encode(d)
+		}
+		switch {
+		case d.Error != "":
+			_, err := fmt.Fprintf(w, "%s@%s: %s\n", d.Repository, d.Digest, d.Error)
+			return err
+		case d.Deleted:
+			_, err := fmt.Fprintf(w, "deleted %s@%s\n", d.Repository, d.Digest)
+			return err
 		}
+		_, err := fmt.Fprintf(w, "%s@%s\n", d.Repository, d.Digest)
+		return err
 	}
-
-	return nil
+	return gcrane.GarbageCollect(ctx, root, policy, report, opts...)
 }
@@ -1,21 +1,66 @@
 package main
 
 import (
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 
 	"github.com/google/go-containerregistry/pkg/registry"
 )
 
-var port = flag.Int("port", 1338, "port to run registry on")
+var (
+	port = flag.Int("port", 1338, "port to run registry on")
+	dir  = flag.String("dir", "", "directory to store blobs and manifests in; if unset, they're kept in memory")
+
+	metrics = flag.Bool("metrics", false, "serve Prometheus metrics at /metrics, without authentication")
+
+	tlsCert  = flag.String("tls-cert", "", "PEM encoded certificate to serve https with")
+	tlsKey   = flag.String("tls-key", "", "PEM encoded private key for -tls-cert")
+	clientCA = flag.String("client-ca", "", "PEM encoded CA certificates to require client certificates from")
+)
 
 func main() {
 	flag.Parse()
+	var opts []registry.Option
+	if *dir != "" {
+		opts = append(opts, registry.WithStorageDir(*dir))
+	}
+	if *metrics {
+		opts = append(opts, registry.WithMetrics(&registry.Metrics{}), registry.WithMetricsEndpoint())
+	}
 	s := &http.Server{
 		Addr:    fmt.Sprintf(":%d", *port),
-		Handler: registry.New(),
+		Handler: registry.New(opts...),
+	}
+	if *tlsCert == "" {
+		log.Fatal(s.ListenAndServe())
+	}
+
+	cert, err := ioutil.ReadFile(*tlsCert)
+	if err != nil {
+		log.Fatal(err)
+	}
+	key, err := ioutil.ReadFile(*tlsKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts = append(opts, registry.WithTLS(cert, key))
+	if *clientCA != "" {
+		b, err := ioutil.ReadFile(*clientCA)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			log.Fatalf("no certificates found in %s", *clientCA)
+		}
+		opts = append(opts, registry.WithClientCAs(pool))
+	}
+	if s.TLSConfig, err = registry.TLSConfig(opts...); err != nil {
+		log.Fatal(err)
 	}
-	log.Fatal(s.ListenAndServe())
+	log.Fatal(s.ListenAndServeTLS("", ""))
 }
@@ -37,6 +37,21 @@ go get -u ./...
 go mod tidy -compat=1.17
 go mod download
 
+cd ${PROJECT_ROOT}/pkg/authn/aws
+go get -u ./...
+go mod tidy -compat=1.17
+go mod download
+
+cd ${PROJECT_ROOT}/pkg/aws
+go get -u ./...
+go mod tidy -compat=1.17
+go mod download
+
+cd ${PROJECT_ROOT}/pkg/v1/containerd
+go get -u ./...
+go mod tidy -compat=1.17
+go mod download
+
 cd ${PROJECT_ROOT}/cmd/krane
 go get -u ./...
 go mod tidy
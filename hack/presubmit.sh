@@ -58,3 +58,15 @@ go build ./...
 pushd ${PROJECT_ROOT}/pkg/authn/kubernetes
 trap popd EXIT
 go test ./...
+
+pushd ${PROJECT_ROOT}/pkg/authn/aws
+trap popd EXIT
+go test ./...
+
+pushd ${PROJECT_ROOT}/pkg/aws
+trap popd EXIT
+go test ./...
+
+pushd ${PROJECT_ROOT}/pkg/v1/containerd
+trap popd EXIT
+go test ./...
@@ -0,0 +1,121 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compression abstracts over gzip and zstd.
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/google/go-containerregistry/internal/gzip"
+	"github.com/google/go-containerregistry/internal/zstd"
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Opener represents e.g. opening a file.
+type Opener = func() (io.ReadCloser, error)
+
+// PeekReader is an io.Reader that also implements Peek a la bufio.Reader.
+type PeekReader = gzip.PeekReader
+
+// Peek detects the compression of the input stream.
+//
+// If r implements Peek, we will use that directly, otherwise a small number
+// of bytes are buffered to Peek at the header, and the returned PeekReader
+// can be used as a replacement for the consumed input io.Reader.
+func Peek(r io.Reader) (compression.Compression, PeekReader, error) {
+	var pr PeekReader
+	if p, ok := r.(PeekReader); ok {
+		pr = p
+	} else {
+		pr = bufio.NewReader(r)
+	}
+
+	for _, c := range []struct {
+		magic []byte
+		comp  compression.Compression
+	}{
+		{gzip.MagicHeader, compression.GZip},
+		{zstd.MagicHeader, compression.ZStd},
+	} {
+		header, err := pr.Peek(len(c.magic))
+		if err != nil {
+			// https://github.com/google/go-containerregistry/issues/367
+			if err == io.EOF {
+				continue
+			}
+			return compression.None, pr, err
+		}
+		if bytes.Equal(header, c.magic) {
+			return c.comp, pr, nil
+		}
+	}
+	return compression.None, pr, nil
+}
+
+// Detect opens the stream returned by opener and detects its compression.
+func Detect(opener Opener) (compression.Compression, error) {
+	rc, err := opener()
+	if err != nil {
+		return compression.None, err
+	}
+	defer rc.Close()
+
+	comp, _, err := Peek(rc)
+	return comp, err
+}
+
+// Compress reads uncompressed input data from the io.ReadCloser and returns
+// an io.ReadCloser from which data compressed with comp may be read.
+func Compress(rc io.ReadCloser, comp compression.Compression, level int) io.ReadCloser {
+	switch comp {
+	case compression.ZStd:
+		return zstd.ReadCloserLevel(rc, level)
+	case compression.None:
+		return rc
+	default:
+		return gzip.ReadCloserLevel(rc, level)
+	}
+}
+
+// Decompress reads input data compressed with comp from the io.ReadCloser
+// and returns an io.ReadCloser from which uncompressed data may be read.
+func Decompress(rc io.ReadCloser, comp compression.Compression) (io.ReadCloser, error) {
+	switch comp {
+	case compression.ZStd:
+		return zstd.UnzipReadCloser(rc)
+	case compression.GZip:
+		return gzip.UnzipReadCloser(rc)
+	default:
+		return rc, nil
+	}
+}
+
+// ForMediaType returns the compression implied by a layer media type. It
+// returns false for media types that don't identify a compression, e.g.
+// non-layer or unknown artifact media types.
+func ForMediaType(mt types.MediaType) (compression.Compression, bool) {
+	switch mt {
+	case types.DockerLayer, types.DockerForeignLayer, types.OCILayer, types.OCIRestrictedLayer:
+		return compression.GZip, true
+	case types.OCILayerZStd, types.OCIRestrictedLayerZStd:
+		return compression.ZStd, true
+	case types.DockerUncompressedLayer, types.OCIUncompressedLayer, types.OCIUncompressedRestrictedLayer:
+		return compression.None, true
+	}
+	return compression.None, false
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+)
+
+func TestRoundTrip(t *testing.T) {
+	want := "This is the input string."
+	for _, comp := range []compression.Compression{compression.None, compression.GZip, compression.ZStd} {
+		t.Run(string(comp), func(t *testing.T) {
+			compressed := Compress(ioutil.NopCloser(bytes.NewBufferString(want)), comp, 1)
+			b, err := ioutil.ReadAll(compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, pr, err := Peek(bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("Peek() = %v", err)
+			}
+			if got != comp {
+				t.Errorf("Peek(); got %q, want %q", got, comp)
+			}
+
+			rc, err := Decompress(ioutil.NopCloser(pr), got)
+			if err != nil {
+				t.Fatalf("Decompress() = %v", err)
+			}
+			defer rc.Close()
+			b, err = ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != want {
+				t.Errorf("ReadAll(); got %q, want %q", string(b), want)
+			}
+		})
+	}
+}
+
+func TestPeekShort(t *testing.T) {
+	got, _, err := Peek(bytes.NewReader([]byte{'\x28'}))
+	if err != nil {
+		t.Fatalf("Peek() = %v", err)
+	}
+	if got != compression.None {
+		t.Errorf("Peek(); got %q, want %q", got, compression.None)
+	}
+}
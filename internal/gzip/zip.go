@@ -24,7 +24,8 @@ import (
 	"github.com/google/go-containerregistry/internal/and"
 )
 
-var gzipMagicHeader = []byte{'\x1f', '\x8b'}
+// MagicHeader is the start of gzip files.
+var MagicHeader = []byte{'\x1f', '\x8b'}
 
 // ReadCloser reads uncompressed input data from the io.ReadCloser and
 // returns an io.ReadCloser from which compressed data may be read.
@@ -113,7 +114,7 @@ func Is(r io.Reader) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return bytes.Equal(magicHeader, gzipMagicHeader), nil
+	return bytes.Equal(magicHeader, MagicHeader), nil
 }
 
 // PeekReader is an io.Reader that also implements Peek a la bufio.Reader.
@@ -142,5 +143,5 @@ func Peek(r io.Reader) (bool, PeekReader, error) {
 		}
 		return false, pr, err
 	}
-	return bytes.Equal(header, gzipMagicHeader), pr, nil
+	return bytes.Equal(header, MagicHeader), pr, nil
 }
@@ -35,7 +35,41 @@ import (
 // send all requests to the returned server. The TLS certs are generated for the given domain.
 // If you need a transport, Client().Transport is correctly configured.
 func NewTLSServer(domain string, handler http.Handler) (*httptest.Server, error) {
+	return NewTLSServerWithConfig(domain, handler, &tls.Config{})
+}
+
+// NewTLSServerWithConfig is like NewTLSServer, but serves with cfg. If cfg has
+// no certificates, one is generated for the given domain. cfg is not modified.
+func NewTLSServerWithConfig(domain string, handler http.Handler, cfg *tls.Config) (*httptest.Server, error) {
+	cfg = cfg.Clone()
 	s := httptest.NewUnstartedServer(handler)
+	s.TLS = cfg
+	if len(cfg.Certificates) == 0 {
+		c, err := generateCert(domain)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{c}
+	}
+	s.StartTLS()
+
+	certpool := x509.NewCertPool()
+	certpool.AddCert(s.Certificate())
+
+	t := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			RootCAs: certpool,
+		},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial(s.Listener.Addr().Network(), s.Listener.Addr().String())
+		},
+	}
+	s.Client().Transport = t
+
+	return s, nil
+}
+
+func generateCert(domain string) (tls.Certificate, error) {
 
 	template := x509.Certificate{
 		SerialNumber: big.NewInt(1),
@@ -55,50 +89,28 @@ func NewTLSServer(domain string, handler http.Handler) (*httptest.Server, error)
 
 	priv, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
 	if err != nil {
-		return nil, err
+		return tls.Certificate{}, err
 	}
 
 	b, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
 	if err != nil {
-		return nil, err
+		return tls.Certificate{}, err
 	}
 
 	pc := &bytes.Buffer{}
 	if err := pem.Encode(pc, &pem.Block{Type: "CERTIFICATE", Bytes: b}); err != nil {
-		return nil, err
+		return tls.Certificate{}, err
 	}
 
 	ek, err := x509.MarshalECPrivateKey(priv)
 	if err != nil {
-		return nil, err
+		return tls.Certificate{}, err
 	}
 
 	pk := &bytes.Buffer{}
 	if err := pem.Encode(pk, &pem.Block{Type: "EC PRIVATE KEY", Bytes: ek}); err != nil {
-		return nil, err
-	}
-
-	c, err := tls.X509KeyPair(pc.Bytes(), pk.Bytes())
-	if err != nil {
-		return nil, err
-	}
-	s.TLS = &tls.Config{
-		Certificates: []tls.Certificate{c},
-	}
-	s.StartTLS()
-
-	certpool := x509.NewCertPool()
-	certpool.AddCert(s.Certificate())
-
-	t := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			RootCAs: certpool,
-		},
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return net.Dial(s.Listener.Addr().Network(), s.Listener.Addr().String())
-		},
+		return tls.Certificate{}, err
 	}
-	s.Client().Transport = t
 
-	return s, nil
+	return tls.X509KeyPair(pc.Bytes(), pk.Bytes())
 }
@@ -0,0 +1,135 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mirror copies every image in a registry to another one. It holds
+// what's shared by the cloud providers' CopyRegistry functions, which only
+// differ in how they list images.
+package mirror
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/sync/errgroup"
+)
+
+// Image is an image to copy, by digest, along with its tags.
+type Image struct {
+	Digest string
+	Tags   []string
+}
+
+// ListFunc lists the images of every repository in the source registry,
+// calling yield with each repository's images. It must stop and return
+// yield's error, if there is one, and stop when ctx is done.
+type ListFunc func(ctx context.Context, yield func(repo name.Repository, images []Image) error) error
+
+type task struct {
+	src, dst name.Repository
+	image    Image
+}
+
+// Registry copies every image that list lists to the repository of the same
+// name under dst, running jobs copies at a time with ropts. Images and tags
+// that already exist in dst are skipped.
+func Registry(ctx context.Context, dst string, jobs int, list ListFunc, ropts ...remote.Option) error {
+	if _, err := name.NewRepository(dst + "/x"); err != nil {
+		return fmt.Errorf("parsing destination %q: %w", dst, err)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	tasks := make(chan task, jobs*2)
+
+	g.Go(func() error {
+		defer close(tasks)
+		return list(ctx, func(repo name.Repository, images []Image) error {
+			to, err := name.NewRepository(fmt.Sprintf("%s/%s", dst, repo.RepositoryStr()))
+			if err != nil {
+				return err
+			}
+			for _, img := range images {
+				select {
+				case tasks <- task{src: repo, dst: to, image: img}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	})
+
+	for i := 0; i < jobs; i++ {
+		g.Go(func() error {
+			for t := range tasks {
+				if err := copyImage(t, ropts); err != nil {
+					return fmt.Errorf("copying %s@%s: %w", t.src, t.image.Digest, err)
+				}
+			}
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+// copyImage copies t.image by digest, unless it already exists, and then
+// adds any of its tags that are missing.
+func copyImage(t task, ropts []remote.Option) error {
+	if _, err := remote.Head(t.dst.Digest(t.image.Digest), ropts...); err != nil {
+		desc, err := remote.Get(t.src.Digest(t.image.Digest), ropts...)
+		if err != nil {
+			return err
+		}
+		logs.Progress.Printf("Copying %s@%s to %s", t.src, t.image.Digest, t.dst)
+		if err := write(t.dst.Digest(t.image.Digest), desc, ropts); err != nil {
+			return err
+		}
+	}
+
+	for _, tag := range t.image.Tags {
+		if desc, err := remote.Head(t.dst.Tag(tag), ropts...); err == nil && desc.Digest.String() == t.image.Digest {
+			continue
+		}
+		desc, err := remote.Get(t.dst.Digest(t.image.Digest), ropts...)
+		if err != nil {
+			return err
+		}
+		if err := remote.Tag(t.dst.Tag(tag), desc, ropts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write copies desc, along with everything it refers to, to ref.
+func write(ref name.Reference, desc *remote.Descriptor, ropts []remote.Option) error {
+	switch {
+	case desc.MediaType.IsIndex():
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		return remote.WriteIndex(ref, idx, ropts...)
+	case desc.MediaType.IsImage():
+		img, err := desc.Image()
+		if err != nil {
+			return err
+		}
+		return remote.Write(ref, img, ropts...)
+	}
+	return remote.Put(ref, desc, ropts...)
+}
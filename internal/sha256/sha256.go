@@ -0,0 +1,34 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !sha256simd
+// +build !sha256simd
+
+// Package sha256 returns the SHA-256 implementation used to digest blobs.
+//
+// By default, that's crypto/sha256, which uses the CPU's SHA extensions
+// (SHA-NI on amd64, SHA2 on arm64) when they're available. Building with
+// the sha256simd tag uses github.com/minio/sha256-simd instead, which also
+// has AVX-512 and AVX2 implementations, for CPUs without SHA extensions.
+package sha256
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+// New returns a new SHA-256 hash.Hash.
+func New() hash.Hash {
+	return sha256.New()
+}
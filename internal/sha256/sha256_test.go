@@ -0,0 +1,42 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sha256
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	b := make([]byte, 1<<20)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	want := sha256.Sum256(b)
+
+	h := New()
+	// Write in uneven chunks, to exercise buffering.
+	for i := 0; i < len(b); i += 1000 {
+		j := i + 1000
+		if j > len(b) {
+			j = len(b)
+		}
+		h.Write(b[i:j])
+	}
+	if got := h.Sum(nil); hex.EncodeToString(got) != hex.EncodeToString(want[:]) {
+		t.Errorf("New() sum = %x, want %x", got, want)
+	}
+}
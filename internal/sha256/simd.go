@@ -0,0 +1,29 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build sha256simd
+// +build sha256simd
+
+package sha256
+
+import (
+	"hash"
+
+	sha256simd "github.com/minio/sha256-simd"
+)
+
+// New returns a new SHA-256 hash.Hash.
+func New() hash.Hash {
+	return sha256simd.New()
+}
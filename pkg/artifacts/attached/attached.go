@@ -0,0 +1,289 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attached
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Kind is the kind of an attached document.
+type Kind string
+
+// The kinds of documents that Discover returns.
+const (
+	// SBOM is a bare software bill of materials, e.g. an SPDX or CycloneDX
+	// document.
+	SBOM Kind = "sbom"
+
+	// Attestation is an in-toto statement, possibly wrapped in a DSSE
+	// envelope or sigstore bundle. Its predicate may itself be an SBOM.
+	Attestation Kind = "attestation"
+)
+
+// Source is the convention by which a document was attached.
+type Source string
+
+// The conventions that Discover follows.
+const (
+	Referrers         Source = "referrers"
+	CosignTag         Source = "cosign-tag"
+	DockerAttestation Source = "docker-attestation"
+)
+
+// Annotations that carry an attestation's predicate type.
+const (
+	intotoPredicateAnnotation     = "in-toto.io/predicate-type"
+	cosignPredicateAnnotation     = "predicateType"
+	bundlePredicateAnnotation     = "dev.sigstore.bundle.predicateType"
+	dockerReferenceTypeAnnotation = "vnd.docker.reference.type"
+)
+
+var (
+	sbomTypes = map[string]bool{
+		"application/spdx+json":          true,
+		"text/spdx":                      true,
+		"text/spdx+json":                 true,
+		"text/spdx+xml":                  true,
+		"application/vnd.cyclonedx":      true,
+		"application/vnd.cyclonedx+json": true,
+		"application/vnd.cyclonedx+xml":  true,
+		"application/vnd.syft+json":      true,
+	}
+	attestationTypes = map[string]bool{
+		"application/vnd.in-toto+json":          true,
+		"application/vnd.dsse.envelope.v1+json": true,
+	}
+
+	// sbomPredicates prefix the predicate types of SBOM attestations, which
+	// carry a version, e.g. "https://spdx.dev/Document/v2.3".
+	sbomPredicates = []string{
+		"https://spdx.dev/Document",
+		"https://cyclonedx.org/bom",
+		"https://syft.dev/bom",
+	}
+)
+
+// Document is an SBOM or attestation attached to an image.
+type Document struct {
+	Kind   Kind
+	Source Source
+
+	// Manifest is the manifest that holds the document.
+	Manifest name.Digest
+
+	// Descriptor describes the layer of Manifest that holds the document.
+	Descriptor v1.Descriptor
+
+	// ArtifactType is the manifest's artifactType if it has one, otherwise
+	// the layer's media type.
+	ArtifactType string
+
+	// PredicateType is the in-toto predicate type of an attestation, if known.
+	PredicateType string
+}
+
+// IsSBOM returns true if d is an SBOM, or an attestation whose predicate is
+// one.
+func (d Document) IsSBOM() bool {
+	if d.Kind == SBOM {
+		return true
+	}
+	for _, p := range sbomPredicates {
+		if strings.HasPrefix(d.PredicateType, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Layer returns the layer that holds d.
+func (d Document) Layer(opt ...crane.Option) (v1.Layer, error) {
+	o := crane.GetOptions(opt...)
+	return remote.Layer(d.Manifest.Context().Digest(d.Descriptor.Digest.String()), o.Remote...)
+}
+
+// Discover returns the SBOMs and attestations attached to the image at src.
+// If src is an index, only documents attached to the index itself are
+// returned, along with any docker buildx attestations it contains.
+func Discover(src string, opt ...crane.Option) ([]Document, error) {
+	o := crane.GetOptions(opt...)
+	ref, err := name.ParseReference(src, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %w", src, err)
+	}
+	desc, err := remote.Get(ref, o.Remote...)
+	if err != nil {
+		return nil, err
+	}
+	repo := ref.Context()
+	d := &discoverer{
+		options: o.Remote,
+		seen:    map[string]bool{},
+	}
+
+	refs, err := remote.Referrers(repo.Digest(desc.Digest.String()), o.Remote...)
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers of %s: %w", ref, err)
+	}
+	for _, m := range refs.Manifests {
+		if err := d.manifest(repo.Digest(m.Digest.String()), Referrers); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, suffix := range []string{"sbom", "att"} {
+		tag := repo.Tag(fmt.Sprintf("%s-%s.%s", desc.Digest.Algorithm, desc.Digest.Hex, suffix))
+		td, err := remote.Get(tag, o.Remote...)
+		if err != nil {
+			var terr *transport.Error
+			if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+				continue
+			}
+			return nil, err
+		}
+		if err := d.manifest(repo.Digest(td.Digest.String()), CosignTag); err != nil {
+			return nil, err
+		}
+	}
+
+	if desc.MediaType.IsIndex() {
+		idx, err := v1.ParseIndexManifest(bytes.NewReader(desc.Manifest))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range idx.Manifests {
+			if m.Annotations[dockerReferenceTypeAnnotation] != "attestation-manifest" {
+				continue
+			}
+			if err := d.manifest(repo.Digest(m.Digest.String()), DockerAttestation); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return d.docs, nil
+}
+
+type discoverer struct {
+	options []remote.Option
+	seen    map[string]bool
+	docs    []Document
+}
+
+// manifest adds the documents held by the layers of the manifest at ref.
+func (d *discoverer) manifest(ref name.Digest, src Source) error {
+	if d.seen[ref.DigestStr()] {
+		return nil
+	}
+	d.seen[ref.DigestStr()] = true
+
+	desc, err := remote.Get(ref, d.options...)
+	if err != nil {
+		return err
+	}
+	if !desc.MediaType.IsImage() {
+		return nil
+	}
+	m, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+	if err != nil {
+		return err
+	}
+	for _, l := range m.Layers {
+		doc := Document{
+			Source:        src,
+			Manifest:      ref,
+			Descriptor:    l,
+			ArtifactType:  m.ArtifactType,
+			PredicateType: predicateType(l.Annotations, m.Annotations),
+		}
+		if doc.ArtifactType == "" {
+			doc.ArtifactType = string(l.MediaType)
+		}
+		// The layer's media type is more specific than the manifest's
+		// artifactType, unless it's generic, as with "oras attach".
+		kind, ok := kindOf(string(l.MediaType))
+		if !ok {
+			if kind, ok = kindOf(m.ArtifactType); !ok {
+				continue
+			}
+		}
+		doc.Kind = kind
+		d.docs = append(d.docs, doc)
+	}
+	return nil
+}
+
+// kindOf returns the kind of document with the given media type.
+func kindOf(mt string) (Kind, bool) {
+	// Drop parameters, e.g. "application/vnd.dev.sigstore.bundle+json;version=0.2".
+	if i := strings.Index(mt, ";"); i >= 0 {
+		mt = mt[:i]
+	}
+	switch {
+	case sbomTypes[mt]:
+		return SBOM, true
+	case attestationTypes[mt], strings.HasPrefix(mt, "application/vnd.dev.sigstore.bundle"):
+		return Attestation, true
+	}
+	return "", false
+}
+
+// predicateType returns the predicate type from the first set of annotations
+// that has one.
+func predicateType(annotations ...map[string]string) string {
+	for _, a := range annotations {
+		for _, k := range []string{intotoPredicateAnnotation, cosignPredicateAnnotation, bundlePredicateAnnotation} {
+			if p, ok := a[k]; ok {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// ByKind returns the documents of the given kind.
+func ByKind(docs []Document, kind Kind) []Document {
+	return filter(docs, func(d Document) bool { return d.Kind == kind })
+}
+
+// ByArtifactType returns the documents with the given artifact type.
+func ByArtifactType(docs []Document, artifactType string) []Document {
+	return filter(docs, func(d Document) bool { return d.ArtifactType == artifactType })
+}
+
+// ByPredicateType returns the attestations with the given predicate type.
+func ByPredicateType(docs []Document, predicateType string) []Document {
+	return filter(docs, func(d Document) bool { return d.PredicateType == predicateType })
+}
+
+func filter(docs []Document, keep func(Document) bool) []Document {
+	kept := []Document{}
+	for _, d := range docs {
+		if keep(d) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,247 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attached
+
+import (
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	slsa = "https://slsa.dev/provenance/v1"
+	spdx = "https://spdx.dev/Document/v2.3"
+)
+
+func TestKindOf(t *testing.T) {
+	for mt, want := range map[string]Kind{
+		"application/spdx+json":                                SBOM,
+		"application/vnd.cyclonedx+json":                       SBOM,
+		"application/vnd.in-toto+json":                         Attestation,
+		"application/vnd.dsse.envelope.v1+json":                Attestation,
+		"application/vnd.dev.sigstore.bundle+json;version=0.2": Attestation,
+		"application/vnd.dev.sigstore.bundle.v0.3+json":        Attestation,
+		"application/vnd.dev.cosign.artifact.sig.v1+json":      "",
+		"": "",
+	} {
+		if got, ok := kindOf(mt); got != want || ok != (want != "") {
+			t.Errorf("kindOf(%q) = %q, %t, want %q", mt, got, ok, want)
+		}
+	}
+}
+
+func TestIsSBOM(t *testing.T) {
+	for _, tc := range []struct {
+		doc  Document
+		want bool
+	}{
+		{Document{Kind: SBOM}, true},
+		{Document{Kind: Attestation, PredicateType: spdx}, true},
+		{Document{Kind: Attestation, PredicateType: "https://cyclonedx.org/bom"}, true},
+		{Document{Kind: Attestation, PredicateType: slsa}, false},
+	} {
+		if got := tc.doc.IsSBOM(); got != tc.want {
+			t.Errorf("%+v.IsSBOM() = %t, want %t", tc.doc, got, tc.want)
+		}
+	}
+}
+
+// attach writes a to repo and returns it as an image, for use in an index.
+func attach(t *testing.T, repo name.Repository, a v1.Artifact) v1.Image {
+	t.Helper()
+	d, err := a.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteArtifact(repo.Digest(d.String()), a); err != nil {
+		t.Fatal(err)
+	}
+	img, err := artifact.Image(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// dsse returns an image holding a DSSE envelope, as cosign attaches them.
+func dsse(t *testing.T, predicateType string) v1.Image {
+	t.Helper()
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       static.NewLayer([]byte(`{"payloadType":"application/vnd.in-toto+json"}`), "application/vnd.dsse.envelope.v1+json"),
+		Annotations: map[string]string{cosignPredicateAnnotation: predicateType},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mutate.MediaType(img, types.OCIManifestSchema1)
+}
+
+func TestDiscover(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	repo, err := name.NewRepository(strings.TrimPrefix(s.URL, "http://") + "/test/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(repo.Tag("v1"), img); err != nil {
+		t.Fatal(err)
+	}
+	subject, err := partial.Descriptor(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Referrers: an SPDX SBOM, a SLSA attestation and a signature, which
+	// isn't returned.
+	sbom, err := artifact.New("application/spdx+json",
+		artifact.WithBlobs(static.NewLayer([]byte(`{"spdxVersion":"SPDX-2.3"}`), "application/spdx+json")),
+		artifact.WithSubject(*subject))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prov, err := artifact.New("application/vnd.in-toto+json",
+		artifact.WithBlobs(static.NewLayer([]byte(`{}`), "application/octet-stream")),
+		artifact.WithAnnotations(map[string]string{intotoPredicateAnnotation: slsa}),
+		artifact.WithSubject(*subject))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := artifact.New("application/vnd.dev.cosign.artifact.sig.v1+json",
+		artifact.WithBlobs(static.NewLayer([]byte(`sig`), "application/vnd.dev.cosign.simplesigning.v1+json")),
+		artifact.WithSubject(*subject))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.IndexMediaType(empty.Index, types.OCIImageIndex)
+	for _, a := range []v1.Artifact{sbom, prov, sig} {
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: attach(t, repo, a)})
+	}
+	if err := remote.WriteIndex(repo.Tag("sha256-"+subject.Digest.Hex), idx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cosign attestation, attached by tag.
+	att := dsse(t, spdx)
+	if err := remote.Write(repo.Tag("sha256-"+subject.Digest.Hex+".att"), att); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := Discover(repo.Tag("v1").String())
+	if err != nil {
+		t.Fatalf("Discover() = %v", err)
+	}
+	got := summarize(docs)
+	want := []string{
+		"attestation application/vnd.dsse.envelope.v1+json https://spdx.dev/Document/v2.3 cosign-tag",
+		"attestation application/vnd.in-toto+json https://slsa.dev/provenance/v1 referrers",
+		"sbom application/spdx+json  referrers",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Discover() (-want +got) = %s", diff)
+	}
+
+	if got := ByPredicateType(docs, slsa); len(got) != 1 || got[0].Source != Referrers {
+		t.Errorf("ByPredicateType(%s) = %+v", slsa, got)
+	}
+	if got := ByArtifactType(docs, "application/spdx+json"); len(got) != 1 || got[0].Kind != SBOM {
+		t.Errorf("ByArtifactType() = %+v", got)
+	}
+	if got := ByKind(docs, Attestation); len(got) != 2 {
+		t.Errorf("ByKind(%s) = %+v", Attestation, got)
+	}
+
+	// The document's content can be fetched.
+	for _, d := range ByKind(docs, SBOM) {
+		l, err := d.Layer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h, err := l.Digest(); err != nil || h != d.Descriptor.Digest {
+			t.Errorf("Layer().Digest() = %s, %v, want %s", h, err, d.Descriptor.Digest)
+		}
+	}
+}
+
+func TestDiscoverDockerAttestation(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	repo, err := name.NewRepository(strings.TrimPrefix(s.URL, "http://") + "/test/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	att, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       static.NewLayer([]byte(`{}`), "application/vnd.in-toto+json"),
+		Annotations: map[string]string{intotoPredicateAnnotation: spdx},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	att = mutate.MediaType(att, types.OCIManifestSchema1)
+	idx := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex),
+		mutate.IndexAddendum{Add: img},
+		mutate.IndexAddendum{Add: att, Descriptor: v1.Descriptor{
+			Platform:    &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{dockerReferenceTypeAnnotation: "attestation-manifest"},
+		}},
+	)
+	if err := remote.WriteIndex(repo.Tag("v1"), idx); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := Discover(repo.Tag("v1").String())
+	if err != nil {
+		t.Fatalf("Discover() = %v", err)
+	}
+	want := []string{"attestation application/vnd.in-toto+json https://spdx.dev/Document/v2.3 docker-attestation"}
+	if diff := cmp.Diff(want, summarize(docs)); diff != "" {
+		t.Errorf("Discover() (-want +got) = %s", diff)
+	}
+	if !docs[0].IsSBOM() {
+		t.Errorf("IsSBOM() = false, want true")
+	}
+}
+
+func summarize(docs []Document) []string {
+	s := []string{}
+	for _, d := range docs {
+		s = append(s, strings.Join([]string{string(d.Kind), d.ArtifactType, d.PredicateType, string(d.Source)}, " "))
+	}
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,26 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package attached discovers the SBOMs and attestations attached to an image.
+//
+// Tools attach these documents in several ways, and Discover looks for all of
+// them, so that scanners don't need to know each convention:
+//
+//   - Manifests whose subject is the image, found with the OCI referrers API
+//     or, for registries that lack it, the referrers tag schema. See:
+//     https://github.com/opencontainers/distribution-spec/blob/main/spec.md#listing-referrers
+//   - Images tagged "sha256-<hex>.sbom" and "sha256-<hex>.att", as pushed by
+//     cosign.
+//   - Attestation manifests that docker buildx adds to an image's index.
+package attached
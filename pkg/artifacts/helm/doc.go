@@ -0,0 +1,21 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package helm provides helpers for Helm charts stored as OCI artifacts, see:
+// https://helm.sh/docs/topics/registries/
+//
+// A chart is stored as an image manifest whose config is the chart's
+// Chart.yaml (as JSON), with a layer containing the chart's gzipped tarball
+// and, optionally, a layer containing its provenance file.
+package helm
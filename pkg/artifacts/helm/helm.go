@@ -0,0 +1,200 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/annotations"
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// The media types used by Helm charts.
+const (
+	ConfigMediaType     types.MediaType = "application/vnd.cncf.helm.config.v1+json"
+	ChartMediaType      types.MediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	ProvenanceMediaType types.MediaType = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+
+	// Charts pushed by Helm before 3.7 may use this for the chart layer.
+	legacyChartMediaType types.MediaType = "application/tar+gzip"
+)
+
+// Chart is a Helm chart stored as an OCI artifact.
+type Chart struct {
+	// Metadata is the chart's Chart.yaml.
+	Metadata *Metadata
+
+	// Content is the chart's gzipped tarball.
+	Content v1.Layer
+
+	// Provenance is the chart's provenance file, or nil if it has none.
+	Provenance v1.Layer
+}
+
+// New returns a chart artifact for a chart's gzipped tarball and, optionally,
+// its provenance file, with the config and annotations that Helm sets.
+func New(content, provenance []byte) (v1.Artifact, error) {
+	m, err := ChartMetadata(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	config, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := []v1.Layer{static.NewLayer(content, ChartMediaType)}
+	if provenance != nil {
+		blobs = append(blobs, static.NewLayer(provenance, ProvenanceMediaType))
+	}
+	anns := map[string]string{
+		annotations.Title:   m.Name,
+		annotations.Version: m.Version,
+	}
+	if m.Description != "" {
+		anns[annotations.Description] = m.Description
+	}
+	return artifact.New("",
+		artifact.WithConfig(static.NewLayer(config, ConfigMediaType)),
+		artifact.WithBlobs(blobs...),
+		artifact.WithAnnotations(anns),
+	)
+}
+
+// FromArtifact validates that a is a Helm chart and returns its contents.
+func FromArtifact(a v1.Artifact) (*Chart, error) {
+	m, err := a.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	if m.Config.MediaType != ConfigMediaType {
+		return nil, fmt.Errorf("not a helm chart: config media type is %q, want %q", m.Config.MediaType, ConfigMediaType)
+	}
+
+	c := &Chart{}
+	blobs, err := a.Blobs()
+	if err != nil {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		mt, err := blob.MediaType()
+		if err != nil {
+			return nil, err
+		}
+		switch mt {
+		case ChartMediaType, legacyChartMediaType:
+			if c.Content != nil {
+				return nil, errors.New("helm chart has more than one chart layer")
+			}
+			c.Content = blob
+		case ProvenanceMediaType:
+			if c.Provenance != nil {
+				return nil, errors.New("helm chart has more than one provenance layer")
+			}
+			c.Provenance = blob
+		default:
+			return nil, fmt.Errorf("helm chart has a layer with unexpected media type %q", mt)
+		}
+	}
+	if c.Content == nil {
+		return nil, errors.New("helm chart has no chart layer")
+	}
+
+	config, err := a.Config()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := config.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	c.Metadata = &Metadata{}
+	if err := json.Unmarshal(b, c.Metadata); err != nil {
+		return nil, fmt.Errorf("parsing helm chart config: %w", err)
+	}
+	if err := c.Metadata.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Pull returns the Helm chart at src.
+func Pull(src string, opt ...crane.Option) (*Chart, error) {
+	o := crane.GetOptions(opt...)
+	ref, err := name.ParseReference(src, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %w", src, err)
+	}
+	a, err := remote.Artifact(ref, o.Remote...)
+	if err != nil {
+		return nil, fmt.Errorf("pulling %s: %w", ref, err)
+	}
+	return FromArtifact(a)
+}
+
+// Push pushes a chart's gzipped tarball and, optionally, its provenance file
+// to dst. As with "helm push", if dst is a tag it must match the chart's
+// version, with any "+" replaced by "_".
+func Push(content, provenance []byte, dst string, opt ...crane.Option) error {
+	o := crane.GetOptions(opt...)
+	ref, err := name.ParseReference(dst, o.Name...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %w", dst, err)
+	}
+	if tag, ok := ref.(name.Tag); ok {
+		m, err := ChartMetadata(bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		if want := Tag(m.Version); tag.TagStr() != want {
+			return fmt.Errorf("tag %q does not match chart version %q, want %q", tag.TagStr(), m.Version, want)
+		}
+	}
+	a, err := New(content, provenance)
+	if err != nil {
+		return err
+	}
+	return remote.WriteArtifact(ref, a, o.Remote...)
+}
+
+// Copy copies the Helm chart at src to dst, failing if src is not a chart.
+func Copy(src, dst string, opt ...crane.Option) error {
+	if _, err := Pull(src, opt...); err != nil {
+		return err
+	}
+	return crane.Copy(src, dst, opt...)
+}
+
+// Tag returns the tag that Helm uses for a chart version, since "+" is not
+// allowed in tags.
+func Tag(version string) string {
+	return strings.ReplaceAll(version, "+", "_")
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+const chartYAML = `apiVersion: v2
+name: mychart
+version: 1.2.3+build.4
+description: A chart for testing
+appVersion: "2.0"
+maintainers:
+- name: someone
+  email: someone@example.com
+dependencies:
+- name: redis
+  version: 16.x.x
+  repository: oci://registry.example.com/charts
+`
+
+func chart(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for name, contents := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestChartMetadata(t *testing.T) {
+	m, err := ChartMetadata(bytes.NewReader(chart(t, map[string]string{
+		"mychart/Chart.yaml":          chartYAML,
+		"mychart/charts/a/Chart.yaml": "name: a\nversion: 0.1.0\n",
+	})))
+	if err != nil {
+		t.Fatalf("ChartMetadata() = %v", err)
+	}
+	if m.Name != "mychart" || m.Version != "1.2.3+build.4" || m.AppVersion != "2.0" {
+		t.Errorf("ChartMetadata() = %+v", m)
+	}
+	if len(m.Dependencies) != 1 || m.Dependencies[0].Name != "redis" {
+		t.Errorf("Dependencies = %+v", m.Dependencies)
+	}
+	if len(m.Maintainers) != 1 || m.Maintainers[0].Email != "someone@example.com" {
+		t.Errorf("Maintainers = %+v", m.Maintainers)
+	}
+
+	for _, files := range []map[string]string{
+		{"mychart/values.yaml": ""},
+		{"mychart/Chart.yaml": "name: mychart\n"},
+	} {
+		if _, err := ChartMetadata(bytes.NewReader(chart(t, files))); err == nil {
+			t.Errorf("ChartMetadata(%v) should fail", files)
+		}
+	}
+}
+
+func TestPushPullCopy(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	content := chart(t, map[string]string{"mychart/Chart.yaml": chartYAML})
+	prov := []byte("-----BEGIN PGP SIGNED MESSAGE-----")
+
+	src := fmt.Sprintf("%s/charts/mychart:%s", host, Tag("1.2.3+build.4"))
+	if err := Push(content, prov, fmt.Sprintf("%s/charts/mychart:latest", host)); err == nil {
+		t.Error("Push() to a tag that isn't the version should fail")
+	}
+	if err := Push(content, prov, src); err != nil {
+		t.Fatalf("Push() = %v", err)
+	}
+
+	dst := fmt.Sprintf("%s/mirror/mychart:%s", host, Tag("1.2.3+build.4"))
+	if err := Copy(src, dst); err != nil {
+		t.Fatalf("Copy() = %v", err)
+	}
+
+	c, err := Pull(dst)
+	if err != nil {
+		t.Fatalf("Pull() = %v", err)
+	}
+	if c.Metadata.Name != "mychart" || c.Metadata.Description != "A chart for testing" {
+		t.Errorf("Metadata = %+v", c.Metadata)
+	}
+	for layer, want := range map[string][]byte{"content": content, "provenance": prov} {
+		l := c.Content
+		if layer == "provenance" {
+			l = c.Provenance
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s = %q, %v, want %q", layer, got, err, want)
+		}
+	}
+
+	m, err := crane.Manifest(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{string(ConfigMediaType), `"org.opencontainers.image.version":"1.2.3+build.4"`} {
+		if !strings.Contains(string(m), want) {
+			t.Errorf("manifest %s does not contain %s", m, want)
+		}
+	}
+
+	// Images aren't charts.
+	img, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notChart := fmt.Sprintf("%s/charts/image:latest", host)
+	if err := crane.Push(img, notChart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Pull(notChart); err == nil {
+		t.Error("Pull() of an image should fail")
+	}
+	if err := Copy(notChart, dst); err == nil {
+		t.Error("Copy() of an image should fail")
+	}
+}
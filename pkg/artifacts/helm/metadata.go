@@ -0,0 +1,112 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Maintainer describes a chart maintainer.
+type Maintainer struct {
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"`
+	Email string `json:"email,omitempty" yaml:"email,omitempty"`
+	URL   string `json:"url,omitempty" yaml:"url,omitempty"`
+}
+
+// Dependency describes a chart that a chart depends on.
+type Dependency struct {
+	Name       string   `json:"name" yaml:"name"`
+	Version    string   `json:"version,omitempty" yaml:"version,omitempty"`
+	Repository string   `json:"repository" yaml:"repository"`
+	Condition  string   `json:"condition,omitempty" yaml:"condition,omitempty"`
+	Tags       []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Alias      string   `json:"alias,omitempty" yaml:"alias,omitempty"`
+}
+
+// Metadata holds the contents of a chart's Chart.yaml, which Helm also stores
+// as the chart artifact's config.
+type Metadata struct {
+	APIVersion   string            `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+	Name         string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Version      string            `json:"version,omitempty" yaml:"version,omitempty"`
+	KubeVersion  string            `json:"kubeVersion,omitempty" yaml:"kubeVersion,omitempty"`
+	Description  string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Type         string            `json:"type,omitempty" yaml:"type,omitempty"`
+	Keywords     []string          `json:"keywords,omitempty" yaml:"keywords,omitempty"`
+	Home         string            `json:"home,omitempty" yaml:"home,omitempty"`
+	Sources      []string          `json:"sources,omitempty" yaml:"sources,omitempty"`
+	Dependencies []*Dependency     `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+	Maintainers  []*Maintainer     `json:"maintainers,omitempty" yaml:"maintainers,omitempty"`
+	Icon         string            `json:"icon,omitempty" yaml:"icon,omitempty"`
+	AppVersion   string            `json:"appVersion,omitempty" yaml:"appVersion,omitempty"`
+	Deprecated   bool              `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// Validate checks that the fields Helm requires are set.
+func (m *Metadata) Validate() error {
+	if m.Name == "" {
+		return errors.New("chart metadata has no name")
+	}
+	if m.Version == "" {
+		return fmt.Errorf("chart %q has no version", m.Name)
+	}
+	return nil
+}
+
+// ChartMetadata reads the Chart.yaml from a chart's gzipped tarball, which
+// holds a single top-level directory named after the chart.
+func ChartMetadata(r io.Reader) (*Metadata, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading chart: %w", err)
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("Chart.yaml not found in chart")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading chart: %w", err)
+		}
+		parts := strings.Split(strings.TrimPrefix(hdr.Name, "./"), "/")
+		if len(parts) != 2 || parts[1] != "Chart.yaml" || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		m := &Metadata{}
+		if err := yaml.Unmarshal(b, m); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", hdr.Name, err)
+		}
+		if err := m.Validate(); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intoto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/artifacts/attached"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// The media types of layers that hold attestations.
+const (
+	// StatementMediaType is the media type of layers holding bare
+	// statements, as stored by docker buildx.
+	StatementMediaType types.MediaType = "application/vnd.in-toto+json"
+
+	// EnvelopeMediaType is the media type of layers holding DSSE envelopes,
+	// as stored by cosign.
+	EnvelopeMediaType types.MediaType = "application/vnd.dsse.envelope.v1+json"
+)
+
+const (
+	dockerPredicateAnnotation       = "in-toto.io/predicate-type"
+	dockerReferenceTypeAnnotation   = "vnd.docker.reference.type"
+	dockerReferenceDigestAnnotation = "vnd.docker.reference.digest"
+	cosignPredicateAnnotation       = "predicateType"
+	cosignSignatureAnnotation       = "dev.cosignproject.cosign/signature"
+)
+
+// unknown is the platform of docker buildx attestation manifests, which
+// keeps runtimes from selecting them.
+var unknown = v1.Platform{OS: "unknown", Architecture: "unknown"}
+
+// Attestation is an attestation read from a registry.
+type Attestation struct {
+	Statement *Statement
+
+	// Envelope is the envelope that held Statement, or nil if the statement
+	// was stored bare.
+	Envelope *Envelope
+}
+
+// DockerImage returns a docker buildx style attestation manifest that holds
+// statements. Add it to an index with AttachDocker.
+func DockerImage(statements ...*Statement) (v1.Image, error) {
+	adds := make([]mutate.Addendum, 0, len(statements))
+	for _, s := range statements {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		adds = append(adds, mutate.Addendum{
+			Layer:       static.NewLayer(b, StatementMediaType),
+			Annotations: map[string]string{dockerPredicateAnnotation: s.PredicateType},
+		})
+	}
+	img := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON)
+	img, err := mutate.Append(img, adds...)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cfg = cfg.DeepCopy()
+	cfg.OS, cfg.Architecture = unknown.OS, unknown.Architecture
+	return mutate.ConfigFile(img, cfg)
+}
+
+// AttachDocker returns idx with att, an attestation manifest from
+// DockerImage, added as the attestations of the manifest with digest
+// subject.
+func AttachDocker(idx v1.ImageIndex, subject v1.Hash, att v1.Image) v1.ImageIndex {
+	platform := unknown
+	return mutate.AppendManifests(idx, mutate.IndexAddendum{
+		Add: att,
+		Descriptor: v1.Descriptor{
+			Platform: &platform,
+			Annotations: map[string]string{
+				dockerReferenceTypeAnnotation:   "attestation-manifest",
+				dockerReferenceDigestAnnotation: subject.String(),
+			},
+		},
+	})
+}
+
+// CosignImage returns a cosign style attestation image that holds envelopes.
+func CosignImage(envelopes ...*Envelope) (v1.Image, error) {
+	return appendEnvelopes(mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON), envelopes)
+}
+
+func appendEnvelopes(base v1.Image, envelopes []*Envelope) (v1.Image, error) {
+	adds := make([]mutate.Addendum, 0, len(envelopes))
+	for _, e := range envelopes {
+		s, err := e.Statement()
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		adds = append(adds, mutate.Addendum{
+			Layer: static.NewLayer(b, EnvelopeMediaType),
+			Annotations: map[string]string{
+				cosignPredicateAnnotation: s.PredicateType,
+				cosignSignatureAnnotation: "",
+			},
+		})
+	}
+	return mutate.Append(base, adds...)
+}
+
+// PushCosign adds envelopes to the cosign attestation image of the image at
+// src, creating it if needed, and returns the digest of the result.
+func PushCosign(src string, envelopes []*Envelope, opt ...crane.Option) (name.Digest, error) {
+	o := crane.GetOptions(opt...)
+	ref, err := name.ParseReference(src, o.Name...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("parsing reference %q: %w", src, err)
+	}
+	desc, err := remote.Head(ref, o.Remote...)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	tag := ref.Context().Tag(fmt.Sprintf("%s-%s.att", desc.Digest.Algorithm, desc.Digest.Hex))
+
+	var img v1.Image
+	base, err := remote.Image(tag, o.Remote...)
+	if err != nil {
+		var terr *transport.Error
+		if !errors.As(err, &terr) || terr.StatusCode != http.StatusNotFound {
+			return name.Digest{}, err
+		}
+		img, err = CosignImage(envelopes...)
+	} else {
+		img, err = appendEnvelopes(base, envelopes)
+	}
+	if err != nil {
+		return name.Digest{}, err
+	}
+	if err := remote.Write(tag, img, o.Remote...); err != nil {
+		return name.Digest{}, err
+	}
+	h, err := img.Digest()
+	if err != nil {
+		return name.Digest{}, err
+	}
+	return ref.Context().Digest(h.String()), nil
+}
+
+// FromImage returns the attestations held by the layers of img, skipping
+// layers of other media types.
+func FromImage(img v1.Image) ([]Attestation, error) {
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	var atts []Attestation
+	for _, desc := range m.Layers {
+		if !isAttestation(desc.MediaType) {
+			continue
+		}
+		l, err := img.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		att, err := parse(desc.MediaType, l)
+		if err != nil {
+			return nil, err
+		}
+		atts = append(atts, *att)
+	}
+	return atts, nil
+}
+
+// FromLayer returns the attestation held by l, which must have media type
+// StatementMediaType or EnvelopeMediaType.
+func FromLayer(l v1.Layer) (*Attestation, error) {
+	mt, err := l.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	if !isAttestation(mt) {
+		return nil, fmt.Errorf("layer media type %q is not an attestation", mt)
+	}
+	return parse(mt, l)
+}
+
+// Pull returns the attestations attached to the image at src, in any of the
+// ways that attached.Discover finds.
+func Pull(src string, opt ...crane.Option) ([]Attestation, error) {
+	docs, err := attached.Discover(src, opt...)
+	if err != nil {
+		return nil, err
+	}
+	var atts []Attestation
+	for _, d := range attached.ByKind(docs, attached.Attestation) {
+		if !isAttestation(d.Descriptor.MediaType) {
+			continue
+		}
+		l, err := d.Layer(opt...)
+		if err != nil {
+			return nil, err
+		}
+		att, err := parse(d.Descriptor.MediaType, l)
+		if err != nil {
+			return nil, fmt.Errorf("reading attestation %s in %s: %w", d.Descriptor.Digest, d.Manifest, err)
+		}
+		atts = append(atts, *att)
+	}
+	return atts, nil
+}
+
+// baseType strips any parameters from mt.
+func baseType(mt types.MediaType) types.MediaType {
+	return types.MediaType(strings.TrimSpace(strings.SplitN(string(mt), ";", 2)[0]))
+}
+
+func isAttestation(mt types.MediaType) bool {
+	switch baseType(mt) {
+	case StatementMediaType, EnvelopeMediaType:
+		return true
+	}
+	return false
+}
+
+func parse(mt types.MediaType, l v1.Layer) (*Attestation, error) {
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	if baseType(mt) == StatementMediaType {
+		s, err := ParseStatement(b)
+		if err != nil {
+			return nil, err
+		}
+		return &Attestation{Statement: s}, nil
+	}
+	e, err := ParseEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+	s, err := e.Statement()
+	if err != nil {
+		return nil, err
+	}
+	return &Attestation{Statement: s, Envelope: e}, nil
+}
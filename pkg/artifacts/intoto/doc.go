@@ -0,0 +1,30 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package intoto provides types for in-toto attestations and helpers to store
+// them in, and read them from, registries. See:
+// https://github.com/in-toto/attestation/tree/main/spec
+//
+// An attestation is a Statement about one or more subjects, usually wrapped
+// in a signed DSSE Envelope. Two conventions for storing them are supported:
+//
+//   - docker buildx stores unsigned statements as the layers of an
+//     attestation manifest in the image's index. See DockerImage and
+//     AttachDocker.
+//   - cosign stores envelopes as the layers of an image tagged
+//     "sha256-<hex>.att" in the image's repository. See CosignImage and
+//     PushCosign.
+//
+// Use Pull to read the attestations attached to an image in either way.
+package intoto
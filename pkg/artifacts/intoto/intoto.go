@@ -0,0 +1,214 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intoto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// The in-toto statement types.
+const (
+	StatementType    = "https://in-toto.io/Statement/v1"
+	StatementTypeV01 = "https://in-toto.io/Statement/v0.1"
+)
+
+// PayloadType is the DSSE payload type of in-toto statements.
+const PayloadType = "application/vnd.in-toto+json"
+
+// Subject is an artifact that a statement is about.
+type Subject struct {
+	Name   string            `json:"name,omitempty"`
+	Digest map[string]string `json:"digest"`
+}
+
+// SubjectFor returns a subject with the given name and digest, e.g. for an
+// image's manifest.
+func SubjectFor(name string, h v1.Hash) Subject {
+	return Subject{
+		Name:   name,
+		Digest: map[string]string{h.Algorithm: h.Hex},
+	}
+}
+
+// Statement is an in-toto statement.
+type Statement struct {
+	Type          string          `json:"_type"`
+	Subject       []Subject       `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate,omitempty"`
+}
+
+// NewStatement returns a statement of the given predicate about subjects. The
+// predicate is marshalled to JSON, unless it's already a json.RawMessage.
+func NewStatement(predicateType string, predicate interface{}, subjects ...Subject) (*Statement, error) {
+	raw, ok := predicate.(json.RawMessage)
+	if !ok {
+		b, err := json.Marshal(predicate)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling predicate: %w", err)
+		}
+		raw = b
+	}
+	s := &Statement{
+		Type:          StatementType,
+		Subject:       subjects,
+		PredicateType: predicateType,
+		Predicate:     raw,
+	}
+	return s, s.Validate()
+}
+
+// ParseStatement parses an in-toto statement from b.
+func ParseStatement(b []byte) (*Statement, error) {
+	s := &Statement{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("parsing in-toto statement: %w", err)
+	}
+	return s, s.Validate()
+}
+
+// Validate checks that s has a known type, a predicate type and subjects
+// that each have a digest.
+func (s *Statement) Validate() error {
+	switch s.Type {
+	case StatementType, StatementTypeV01:
+	default:
+		return fmt.Errorf("unknown in-toto statement type %q", s.Type)
+	}
+	if s.PredicateType == "" {
+		return errors.New("in-toto statement has no predicate type")
+	}
+	if len(s.Subject) == 0 {
+		return errors.New("in-toto statement has no subjects")
+	}
+	for i, sub := range s.Subject {
+		if len(sub.Digest) == 0 {
+			return fmt.Errorf("in-toto statement subject %d has no digest", i)
+		}
+	}
+	return nil
+}
+
+// DecodePredicate unmarshals the predicate of s into v.
+func (s *Statement) DecodePredicate(v interface{}) error {
+	return json.Unmarshal(s.Predicate, v)
+}
+
+// Envelope is a DSSE envelope. See:
+// https://github.com/secure-systems-lab/dsse/blob/master/envelope.md
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     []byte      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a signature of a DSSE envelope's payload.
+type Signature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   []byte `json:"sig"`
+}
+
+// Signer signs DSSE envelopes.
+type Signer interface {
+	// KeyID returns the ID of the signer's key, or "" if it has none.
+	KeyID() string
+
+	// Sign signs msg.
+	Sign(msg []byte) ([]byte, error)
+}
+
+// Verifier verifies the signatures of DSSE envelopes.
+type Verifier interface {
+	// KeyID returns the ID of the verifier's key, or "" if it has none.
+	KeyID() string
+
+	// Verify returns an error if sig isn't a valid signature of msg.
+	Verify(msg, sig []byte) error
+}
+
+// PAE returns the DSSE pre-authentication encoding of a payload, which is
+// the message that is signed.
+func PAE(payloadType string, payload []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// Wrap returns an envelope holding s, signed by each of signers.
+func Wrap(s *Statement, signers ...Signer) (*Envelope, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	e := &Envelope{
+		PayloadType: PayloadType,
+		Payload:     payload,
+		Signatures:  []Signature{},
+	}
+	msg := PAE(e.PayloadType, e.Payload)
+	for _, signer := range signers {
+		sig, err := signer.Sign(msg)
+		if err != nil {
+			return nil, fmt.Errorf("signing envelope: %w", err)
+		}
+		e.Signatures = append(e.Signatures, Signature{KeyID: signer.KeyID(), Sig: sig})
+	}
+	return e, nil
+}
+
+// ParseEnvelope parses a DSSE envelope from b.
+func ParseEnvelope(b []byte) (*Envelope, error) {
+	e := &Envelope{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, fmt.Errorf("parsing DSSE envelope: %w", err)
+	}
+	return e, nil
+}
+
+// Statement returns the in-toto statement in e's payload. It doesn't verify
+// e's signatures.
+func (e *Envelope) Statement() (*Statement, error) {
+	if e.PayloadType != PayloadType {
+		return nil, fmt.Errorf("DSSE payload type is %q, want %q", e.PayloadType, PayloadType)
+	}
+	return ParseStatement(e.Payload)
+}
+
+// Verify returns nil if any of e's signatures is verified by any of
+// verifiers. Where both a signature and a verifier have a key ID, they must
+// match.
+func (e *Envelope) Verify(verifiers ...Verifier) error {
+	if len(e.Signatures) == 0 {
+		return errors.New("DSSE envelope is not signed")
+	}
+	msg := PAE(e.PayloadType, e.Payload)
+	for _, sig := range e.Signatures {
+		for _, v := range verifiers {
+			if sig.KeyID != "" && v.KeyID() != "" && sig.KeyID != v.KeyID() {
+				continue
+			}
+			if err := v.Verify(msg, sig.Sig); err == nil {
+				return nil
+			}
+		}
+	}
+	return errors.New("no DSSE envelope signature could be verified")
+}
@@ -0,0 +1,293 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intoto
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const slsa = "https://slsa.dev/provenance/v1"
+
+type key struct {
+	id   string
+	priv ed25519.PrivateKey
+}
+
+func newKey(t *testing.T, id string) key {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key{id: id, priv: priv}
+}
+
+func (k key) KeyID() string { return k.id }
+
+func (k key) Sign(msg []byte) ([]byte, error) { return ed25519.Sign(k.priv, msg), nil }
+
+func (k key) Verify(msg, sig []byte) error {
+	if !ed25519.Verify(k.priv.Public().(ed25519.PublicKey), msg, sig) {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+func statement(t *testing.T, h v1.Hash) *Statement {
+	t.Helper()
+	s, err := NewStatement(slsa, map[string]string{"buildType": "test"}, SubjectFor("img", h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPAE(t *testing.T) {
+	// From the DSSE protocol spec.
+	got := string(PAE("http://example.com/HelloWorld", []byte("hello world")))
+	want := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"
+	if got != want {
+		t.Errorf("PAE() = %q, want %q", got, want)
+	}
+}
+
+func TestStatement(t *testing.T) {
+	h := v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)}
+	s := statement(t, h)
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"img","digest":{"sha256":"` + h.Hex + `"}}],"predicateType":"https://slsa.dev/provenance/v1","predicate":{"buildType":"test"}}`
+	if string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+
+	got, err := ParseStatement(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pred struct {
+		BuildType string `json:"buildType"`
+	}
+	if err := got.DecodePredicate(&pred); err != nil {
+		t.Fatal(err)
+	}
+	if pred.BuildType != "test" {
+		t.Errorf("buildType = %q, want test", pred.BuildType)
+	}
+
+	for _, bad := range []string{
+		`{"_type":"https://example.com/Statement","subject":[{"digest":{"sha256":"a"}}],"predicateType":"p"}`,
+		`{"_type":"https://in-toto.io/Statement/v0.1","subject":[{"digest":{"sha256":"a"}}]}`,
+		`{"_type":"https://in-toto.io/Statement/v0.1","subject":[],"predicateType":"p"}`,
+		`{"_type":"https://in-toto.io/Statement/v0.1","subject":[{"name":"a"}],"predicateType":"p"}`,
+		`not json`,
+	} {
+		if _, err := ParseStatement([]byte(bad)); err == nil {
+			t.Errorf("ParseStatement(%s) = nil error", bad)
+		}
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	k1, k2 := newKey(t, "k1"), newKey(t, "k2")
+	s := statement(t, v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)})
+	e, err := Wrap(s, k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Verify(k1); err != nil {
+		t.Errorf("Verify(k1) = %v", err)
+	}
+	if err := e.Verify(k2); err == nil {
+		t.Error("Verify(k2) = nil error")
+	}
+	// A verifier with a different key ID isn't tried.
+	if err := e.Verify(key{id: "other", priv: k1.priv}); err == nil {
+		t.Error("Verify(other) = nil error")
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseEnvelope(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.Verify(k2, k1); err != nil {
+		t.Errorf("Verify(k2, k1) = %v", err)
+	}
+	got, err := parsed.Statement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(s, got); diff != "" {
+		t.Errorf("Statement() (-want +got) = %s", diff)
+	}
+
+	unsigned, err := Wrap(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unsigned.Verify(k1); err == nil {
+		t.Error("Verify() of unsigned envelope = nil error")
+	}
+	parsed.PayloadType = "text/plain"
+	if _, err := parsed.Statement(); err == nil {
+		t.Error("Statement() with wrong payload type = nil error")
+	}
+}
+
+func TestDocker(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	repo, err := name.NewRepository(strings.TrimPrefix(s.URL, "http://") + "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := statement(t, h)
+	att, err := DockerImage(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := att.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OS != "unknown" || cfg.Architecture != "unknown" {
+		t.Errorf("platform = %s/%s, want unknown/unknown", cfg.OS, cfg.Architecture)
+	}
+	m, err := att.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Layers[0].Annotations[dockerPredicateAnnotation]; got != slsa {
+		t.Errorf("predicate annotation = %q, want %q", got, slsa)
+	}
+	fromImage, err := FromImage(att)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]Attestation{{Statement: st}}, fromImage); diff != "" {
+		t.Errorf("FromImage() (-want +got) = %s", diff)
+	}
+
+	idx := mutate.IndexMediaType(empty.Index, types.OCIImageIndex)
+	idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: img})
+	idx = AttachDocker(idx, h, att)
+	ref := repo.Tag("latest")
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	atts, err := Pull(ref.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]Attestation{{Statement: st}}, atts); diff != "" {
+		t.Errorf("Pull() (-want +got) = %s", diff)
+	}
+}
+
+func TestCosign(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := newKey(t, "k")
+	var want []Attestation
+	for i := 0; i < 2; i++ {
+		st := statement(t, h)
+		st.PredicateType += strings.Repeat("/x", i)
+		e, err := Wrap(st, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Each push adds to the existing attestations.
+		if _, err := PushCosign(ref.String(), []*Envelope{e}); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, Attestation{Statement: st, Envelope: e})
+	}
+
+	atts, err := Pull(ref.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, atts); diff != "" {
+		t.Errorf("Pull() (-want +got) = %s", diff)
+	}
+	for _, a := range atts {
+		if err := a.Envelope.Verify(k); err != nil {
+			t.Errorf("Verify() = %v", err)
+		}
+	}
+
+	att, err := remote.Image(ref.Context().Tag("sha256-" + h.Hex + ".att"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := att.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := FromLayer(layers[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&want[1], got); diff != "" {
+		t.Errorf("FromLayer() (-want +got) = %s", diff)
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wasm provides helpers for WebAssembly modules stored as OCI
+// artifacts, following the CNCF TAG Runtime's Wasm OCI artifact layout:
+// https://tag-runtime.cncf.io/wgs/wasm/deliverables/wasm-oci-artifact/
+//
+// A module is stored as an image manifest with a single layer holding the
+// module's bytes and a small JSON config that describes it. Artifacts pushed
+// by wasm-to-oci, which used different media types, can also be read.
+package wasm
@@ -0,0 +1,234 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// The media types used by Wasm artifacts.
+const (
+	ConfigMediaType types.MediaType = "application/vnd.wasm.config.v0+json"
+	LayerMediaType  types.MediaType = "application/wasm"
+
+	// wasm-to-oci used these.
+	legacyConfigMediaType types.MediaType = "application/vnd.wasm.config.v1+json"
+	legacyLayerMediaType  types.MediaType = "application/vnd.wasm.content.layer.v1+wasm"
+)
+
+// The values of Config.OS for core modules and components.
+const (
+	OSWasip1 = "wasip1"
+	OSWasip2 = "wasip2"
+)
+
+var (
+	// magic starts every Wasm binary.
+	magic = []byte("\x00asm")
+
+	// The version (and layer) that follows magic in core modules and in
+	// components.
+	moduleVersion    = []byte{0x01, 0x00, 0x00, 0x00}
+	componentVersion = []byte{0x0d, 0x00, 0x01, 0x00}
+)
+
+// Config is the config of a Wasm artifact.
+type Config struct {
+	Created      *time.Time `json:"created,omitempty"`
+	Author       string     `json:"author,omitempty"`
+	Architecture string     `json:"architecture"`
+	OS           string     `json:"os"`
+	LayerDigests []string   `json:"layerDigests,omitempty"`
+}
+
+// Module is a Wasm module stored as an OCI artifact.
+type Module struct {
+	// Config describes the module. It is empty for artifacts pushed by
+	// wasm-to-oci.
+	Config *Config
+
+	// Module is the module's binary.
+	Module v1.Layer
+}
+
+// IsComponent returns whether b is a Wasm component, as opposed to a core
+// module.
+func IsComponent(b []byte) bool {
+	return bytes.HasPrefix(b, magic) && bytes.HasPrefix(b[len(magic):], componentVersion)
+}
+
+// Validate checks that b is a Wasm binary, i.e. a core module or component.
+func Validate(b []byte) error {
+	if !bytes.HasPrefix(b, magic) {
+		return errors.New("not a wasm binary: missing \\0asm magic number")
+	}
+	if v := b[len(magic):]; !bytes.HasPrefix(v, moduleVersion) && !bytes.HasPrefix(v, componentVersion) {
+		return fmt.Errorf("unsupported wasm binary version % x", v[:min(len(v), 4)])
+	}
+	return nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Option is a functional option for New.
+type Option func(*Config)
+
+// WithAuthor sets the author in the artifact's config.
+func WithAuthor(author string) Option {
+	return func(c *Config) {
+		c.Author = author
+	}
+}
+
+// WithCreated sets the creation time in the artifact's config. By default it
+// is unset, so that pushing the same module twice produces the same digest.
+func WithCreated(t time.Time) Option {
+	return func(c *Config) {
+		c.Created = &t
+	}
+}
+
+// New returns an artifact for the Wasm module or component b. The config's
+// OS is set to wasip2 for components and wasip1 for core modules.
+func New(b []byte, opts ...Option) (v1.Artifact, error) {
+	if err := Validate(b); err != nil {
+		return nil, err
+	}
+	layer := static.NewLayer(b, LayerMediaType)
+	h, err := layer.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{
+		Architecture: "wasm",
+		OS:           OSWasip1,
+		LayerDigests: []string{h.String()},
+	}
+	if IsComponent(b) {
+		c.OS = OSWasip2
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	config, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return artifact.New("",
+		artifact.WithConfig(static.NewLayer(config, ConfigMediaType)),
+		artifact.WithBlobs(layer),
+	)
+}
+
+// FromArtifact validates that a is a Wasm artifact and returns its contents.
+func FromArtifact(a v1.Artifact) (*Module, error) {
+	m, err := a.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	switch m.Config.MediaType {
+	case ConfigMediaType, legacyConfigMediaType:
+	default:
+		return nil, fmt.Errorf("not a wasm artifact: config media type is %q, want %q", m.Config.MediaType, ConfigMediaType)
+	}
+	if len(m.Layers) != 1 {
+		return nil, fmt.Errorf("wasm artifact has %d layers, want 1", len(m.Layers))
+	}
+	switch mt := m.Layers[0].MediaType; mt {
+	case LayerMediaType, legacyLayerMediaType:
+	default:
+		return nil, fmt.Errorf("wasm artifact has a layer with unexpected media type %q", mt)
+	}
+
+	mod := &Module{Config: &Config{}}
+	if mod.Module, err = a.Blob(m.Layers[0].Digest); err != nil {
+		return nil, err
+	}
+	if m.Config.MediaType == ConfigMediaType {
+		cfg, err := a.Config()
+		if err != nil {
+			return nil, err
+		}
+		rc, err := cfg.Compressed()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, mod.Config); err != nil {
+			return nil, fmt.Errorf("parsing wasm config: %w", err)
+		}
+	}
+	return mod, nil
+}
+
+// Pull returns the Wasm artifact at src.
+func Pull(src string, opt ...crane.Option) (*Module, error) {
+	o := crane.GetOptions(opt...)
+	ref, err := name.ParseReference(src, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %w", src, err)
+	}
+	a, err := remote.Artifact(ref, o.Remote...)
+	if err != nil {
+		return nil, fmt.Errorf("pulling %s: %w", ref, err)
+	}
+	return FromArtifact(a)
+}
+
+// Push pushes the Wasm module or component b to dst, returning the digest of
+// the pushed artifact.
+func Push(b []byte, dst string, opt ...crane.Option) (name.Digest, error) {
+	o := crane.GetOptions(opt...)
+	ref, err := name.ParseReference(dst, o.Name...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("parsing reference %q: %w", dst, err)
+	}
+	a, err := New(b)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	if err := remote.WriteArtifact(ref, a, o.Remote...); err != nil {
+		return name.Digest{}, err
+	}
+	h, err := a.Digest()
+	if err != nil {
+		return name.Digest{}, err
+	}
+	return ref.Context().Digest(h.String()), nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+)
+
+var (
+	// The smallest valid core module and component.
+	module    = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	component = []byte{0x00, 0x61, 0x73, 0x6d, 0x0d, 0x00, 0x01, 0x00}
+)
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		b         []byte
+		ok        bool
+		component bool
+	}{
+		{b: module, ok: true},
+		{b: component, ok: true, component: true},
+		{b: []byte("\x00asm")},
+		{b: []byte("\x00asm\x02\x00\x00\x00")},
+		{b: []byte("#!/bin/sh\n")},
+		{b: nil},
+	} {
+		if err := Validate(tc.b); (err == nil) != tc.ok {
+			t.Errorf("Validate(% x) = %v, want ok=%t", tc.b, err, tc.ok)
+		}
+		if got := IsComponent(tc.b); got != tc.component {
+			t.Errorf("IsComponent(% x) = %t, want %t", tc.b, got, tc.component)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		b    []byte
+		os   string
+		opts []Option
+	}{
+		{b: module, os: OSWasip1},
+		{b: component, os: OSWasip2, opts: []Option{WithAuthor("someone"), WithCreated(created)}},
+	} {
+		a, err := New(tc.b, tc.opts...)
+		if err != nil {
+			t.Fatalf("New() = %v", err)
+		}
+		m, err := a.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Config.MediaType != ConfigMediaType {
+			t.Errorf("config media type = %s, want %s", m.Config.MediaType, ConfigMediaType)
+		}
+		if len(m.Layers) != 1 || m.Layers[0].MediaType != LayerMediaType {
+			t.Fatalf("layers = %+v", m.Layers)
+		}
+
+		mod, err := FromArtifact(a)
+		if err != nil {
+			t.Fatalf("FromArtifact() = %v", err)
+		}
+		c := mod.Config
+		if c.OS != tc.os || c.Architecture != "wasm" {
+			t.Errorf("Config = %+v, want os %s", c, tc.os)
+		}
+		if len(c.LayerDigests) != 1 || c.LayerDigests[0] != m.Layers[0].Digest.String() {
+			t.Errorf("LayerDigests = %v, want [%s]", c.LayerDigests, m.Layers[0].Digest)
+		}
+		if len(tc.opts) != 0 && (c.Author != "someone" || c.Created == nil || !c.Created.Equal(created)) {
+			t.Errorf("Config = %+v, want author and created", c)
+		}
+	}
+
+	if _, err := New([]byte("not wasm")); err == nil {
+		t.Error("New() with an invalid module should fail")
+	}
+}
+
+func TestFromArtifactLegacy(t *testing.T) {
+	a, err := artifact.New("",
+		artifact.WithConfig(static.NewLayer([]byte("{}"), legacyConfigMediaType)),
+		artifact.WithBlobs(static.NewLayer(module, legacyLayerMediaType)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod, err := FromArtifact(a)
+	if err != nil {
+		t.Fatalf("FromArtifact() = %v", err)
+	}
+	if mt, err := mod.Module.MediaType(); err != nil || mt != legacyLayerMediaType {
+		t.Errorf("MediaType() = %s, %v", mt, err)
+	}
+
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromArtifact(artifact.FromImage(img)); err == nil {
+		t.Error("FromArtifact() of an image should fail")
+	}
+}
+
+func TestPushPull(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	dst := fmt.Sprintf("%s/wasm/hello:v1", host)
+	d, err := Push(component, dst)
+	if err != nil {
+		t.Fatalf("Push() = %v", err)
+	}
+	ref, err := name.ParseReference(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Head(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest.String() != d.DigestStr() {
+		t.Errorf("Push() = %s, registry has %s", d.DigestStr(), desc.Digest)
+	}
+
+	mod, err := Pull(d.String())
+	if err != nil {
+		t.Fatalf("Pull() = %v", err)
+	}
+	rc, err := mod.Module.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, component) {
+		t.Errorf("module = % x, want % x", got, component)
+	}
+	if mod.Config.OS != OSWasip2 {
+		t.Errorf("OS = %s, want %s", mod.Config.OS, OSWasip2)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aws provides a keychain for Amazon Elastic Container Registry
+// (ECR). Like docker-credential-ecr-login, it gets registry tokens from the
+// ECR API with the AWS SDK's default credentials, but it doesn't need the
+// helper binary.
+//
+// This package is a separate module, so that the AWS SDK is only a
+// dependency of programs that use it.
+package aws
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// tokenRefreshSkew is how long before an ECR token expires that we get a new
+// one.
+const tokenRefreshSkew = 5 * time.Minute
+
+// ecrHost matches ECR registries, e.g. 123456789012.dkr.ecr.us-east-1.amazonaws.com.
+var ecrHost = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(-fips)?\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+// Keychain authenticates to ECR registries in any account and region with
+// the default AWS configuration (see config.LoadDefaultConfig), and resolves
+// other registries to authn.Anonymous, so that it can be combined with other
+// keychains using authn.NewMultiKeychain.
+var Keychain = NewKeychain()
+
+// NewKeychain returns a keychain like Keychain, configured by opts.
+//
+// Tokens are fetched for the account and region in each registry's hostname,
+// so one keychain serves registries in any region, and in other accounts
+// whose policies allow it (see WithAccountRole). They're reused until
+// shortly before they expire, and the Authenticators it returns fetch a new
+// token when theirs is about to expire, so long pushes and pulls keep working.
+func NewKeychain(opts ...Option) authn.Keychain {
+	return &keychain{
+		o:      makeOptions(opts...),
+		tokens: map[string]token{},
+	}
+}
+
+type keychain struct {
+	o *options
+
+	once sync.Once
+	cfg  aws.Config
+	err  error
+
+	mu     sync.Mutex
+	tokens map[string]token
+	roles  map[string]aws.CredentialsProvider
+}
+
+type token struct {
+	auth    authn.AuthConfig
+	expires time.Time
+}
+
+// Resolve implements authn.Keychain.
+func (k *keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	host := target.RegistryStr()
+	if !ecrHost.MatchString(host) {
+		return authn.Anonymous, nil
+	}
+
+	// Get a token now, so that errors surface here, rather than as an
+	// authentication failure.
+	a := &authenticator{k: k, host: host}
+	if _, err := a.Authorization(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// config loads the AWS configuration the first time it's needed, so that
+// Keychain can be initialized without touching the environment.
+func (k *keychain) config() (aws.Config, error) {
+	k.once.Do(func() {
+		if k.o.cfg != nil {
+			k.cfg = *k.o.cfg
+			return
+		}
+		k.cfg, k.err = config.LoadDefaultConfig(k.o.ctx)
+	})
+	return k.cfg, k.err
+}
+
+// client returns an ECR API client for the registry in the given account
+// and region, assuming the account's role if one was given. k.mu must be
+// held.
+func (k *keychain) client(cfg aws.Config, account, region string, fips bool) *ecr.Client {
+	return ecr.NewFromConfig(cfg, append([]func(*ecr.Options){func(o *ecr.Options) {
+		o.Region = region
+		if fips {
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		}
+		if role, ok := k.o.roles[account]; ok {
+			creds, ok := k.roles[account]
+			if !ok {
+				creds = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role))
+				if k.roles == nil {
+					k.roles = map[string]aws.CredentialsProvider{}
+				}
+				k.roles[account] = creds
+			}
+			o.Credentials = creds
+		}
+	}}, k.o.client...)...)
+}
+
+// token returns a token for the registry host, reusing the last one until
+// it's about to expire.
+func (k *keychain) token(host string) (token, error) {
+	cfg, err := k.config()
+	if err != nil {
+		return token{}, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if t, ok := k.tokens[host]; ok && time.Until(t.expires) > tokenRefreshSkew {
+		return t, nil
+	}
+
+	m := ecrHost.FindStringSubmatch(host)
+	if m == nil {
+		return token{}, fmt.Errorf("%s is not an ECR registry", host)
+	}
+	account, region, fips := m[1], m[3], m[2] != ""
+	out, err := k.client(cfg, account, region, fips).GetAuthorizationToken(k.o.ctx, &ecr.GetAuthorizationTokenInput{
+		RegistryIds: []string{account},
+	})
+	if err != nil {
+		return token{}, err
+	}
+	if len(out.AuthorizationData) == 0 || out.AuthorizationData[0].AuthorizationToken == nil {
+		return token{}, fmt.Errorf("no authorization data for %s", host)
+	}
+	data := out.AuthorizationData[0]
+	b, err := base64.StdEncoding.DecodeString(aws.ToString(data.AuthorizationToken))
+	if err != nil {
+		return token{}, fmt.Errorf("decoding authorization token: %w", err)
+	}
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 {
+		return token{}, errors.New("malformed authorization token for " + host)
+	}
+
+	t := token{
+		auth:    authn.AuthConfig{Username: parts[0], Password: parts[1]},
+		expires: aws.ToTime(data.ExpiresAt),
+	}
+	k.tokens[host] = t
+	return t, nil
+}
+
+// authenticator authenticates to one ECR registry with the keychain's
+// tokens for it.
+type authenticator struct {
+	k    *keychain
+	host string
+
+	mu      sync.Mutex
+	expires time.Time
+}
+
+var (
+	_ authn.Authenticator = (*authenticator)(nil)
+	_ authn.Expirer       = (*authenticator)(nil)
+)
+
+// Authorization implements authn.Authenticator.
+func (a *authenticator) Authorization() (*authn.AuthConfig, error) {
+	t, err := a.k.token(a.host)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	a.expires = t.expires
+	a.mu.Unlock()
+	auth := t.auth
+	return &auth, nil
+}
+
+// Expiry implements authn.Expirer.
+func (a *authenticator) Expiry() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.expires
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const host = "123456789012.dkr.ecr.us-east-1.amazonaws.com"
+
+// fakeAWS serves GetAuthorizationToken from the ECR API, and AssumeRole
+// from STS.
+type fakeAWS struct {
+	t *testing.T
+
+	// keys records the access key that signed each ECR request.
+	keys []string
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Amz-Target") == "" {
+		if err := r.ParseForm(); err != nil {
+			f.t.Fatal(err)
+		}
+		if got, want := r.Form.Get("Action"), "AssumeRole"; got != want {
+			f.t.Errorf("Action = %q, want %q", got, want)
+		}
+		if got, want := r.Form.Get("RoleArn"), "arn:aws:iam::123456789012:role/pull"; got != want {
+			f.t.Errorf("RoleArn = %q, want %q", got, want)
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIAROLE</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		return
+	}
+
+	if got, want := r.Header.Get("X-Amz-Target"), "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken"; got != want {
+		f.t.Errorf("X-Amz-Target = %q, want %q", got, want)
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.Contains(auth, "/us-east-1/ecr/aws4_request") {
+		f.t.Errorf("Authorization = %q", auth)
+	}
+	key := strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 Credential=")
+	f.keys = append(f.keys, key[:strings.Index(key, "/")])
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"authorizationData": []map[string]interface{}{{
+			"authorizationToken": base64.StdEncoding.EncodeToString([]byte("AWS:hunter2")),
+			"expiresAt":          time.Now().Add(12 * time.Hour).Unix(),
+		}},
+	})
+}
+
+func setup(t *testing.T) (*fakeAWS, Option) {
+	t.Helper()
+	f := &fakeAWS{t: t}
+	s := httptest.NewServer(f)
+	t.Cleanup(s.Close)
+
+	return f, WithConfig(aws.Config{
+		Region:      "us-west-2",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "secret", ""),
+		HTTPClient:  s.Client(),
+		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: s.URL, SigningRegion: region}, nil
+		}),
+	})
+}
+
+func TestKeychain(t *testing.T) {
+	f, opt := setup(t)
+	kc := NewKeychain(opt)
+
+	reg := name.MustParseReference(host + "/foo").Context().Registry
+	for i := 0; i < 2; i++ {
+		auth, err := kc.Resolve(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := auth.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Username != "AWS" || cfg.Password != "hunter2" {
+			t.Errorf("Authorization() = %+v", cfg)
+		}
+		// The token's expiry is exposed, so that it can be refreshed.
+		if e, ok := auth.(authn.Expirer); !ok || time.Until(e.Expiry()) < 11*time.Hour {
+			t.Errorf("Resolve() = %T, want an authn.Expirer that expires in 12h", auth)
+		}
+	}
+	if got, want := strings.Join(f.keys, ","), "AKID"; got != want {
+		t.Errorf("ECR requests signed by %q, want %q", got, want)
+	}
+}
+
+func TestKeychainAccountRole(t *testing.T) {
+	f, opt := setup(t)
+	kc := NewKeychain(opt, WithAccountRole("123456789012", "arn:aws:iam::123456789012:role/pull"))
+
+	reg := name.MustParseReference(host + "/foo").Context().Registry
+	if _, err := kc.Resolve(reg); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(f.keys, ","), "ASIAROLE"; got != want {
+		t.Errorf("ECR requests signed by %q, want %q", got, want)
+	}
+}
+
+func TestKeychainNotECR(t *testing.T) {
+	for _, s := range []string{"gcr.io", "docker.io", "public.ecr.aws", "123456789012.dkr.ecr.us-east-1.example.com"} {
+		reg, err := name.NewRegistry(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Registries that aren't ECR don't need credentials to resolve.
+		auth, err := Keychain.Resolve(reg)
+		if err != nil {
+			t.Fatalf("Resolve(%s) = %v", s, err)
+		}
+		if auth != authn.Anonymous {
+			t.Errorf("Resolve(%s) = %v, want Anonymous", s, auth)
+		}
+	}
+}
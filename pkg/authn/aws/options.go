@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+)
+
+// Option is a functional option for NewKeychain.
+type Option func(*options)
+
+type options struct {
+	ctx    context.Context
+	cfg    *aws.Config
+	roles  map[string]string
+	client []func(*ecr.Options)
+}
+
+func makeOptions(opts ...Option) *options {
+	o := &options{
+		ctx: context.Background(),
+	}
+	for _, option := range opts {
+		option(o)
+	}
+	return o
+}
+
+// WithContext sets the context for loading the AWS configuration and for
+// ECR API requests.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithConfig sets the AWS configuration used to make ECR API requests, e.g.
+// one loaded with config.LoadDefaultConfig and a shared config profile. By
+// default, config.LoadDefaultConfig is called the first time a token is
+// needed. The region is always taken from each registry's hostname.
+func WithConfig(cfg aws.Config) Option {
+	return func(o *options) {
+		o.cfg = &cfg
+	}
+}
+
+// WithAccountRole assumes roleARN, with stscreds, to get tokens for
+// registries in account, e.g. for a registry in another account whose policy
+// trusts the role rather than the caller. It can be passed once for each
+// account.
+func WithAccountRole(account, roleARN string) Option {
+	return func(o *options) {
+		if o.roles == nil {
+			o.roles = map[string]string{}
+		}
+		o.roles[account] = roleARN
+	}
+}
+
+// WithClientOptions customizes the ECR API clients, e.g. to use a VPC
+// endpoint with ecr.EndpointResolverFromURL.
+func WithClientOptions(fns ...func(*ecr.Options)) Option {
+	return func(o *options) {
+		o.client = append(o.client, fns...)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azure provides a keychain for Azure Container Registry (ACR). Like
+// docker-credential-acr-env, it exchanges an Azure Active Directory (AAD)
+// access token for an ACR refresh token, using a service principal or access
+// token from the environment, or the managed identity we're running as, but
+// it doesn't need the helper binary or the Azure SDK.
+//
+// See github.com/google/go-containerregistry/pkg/azure for the options it
+// takes, e.g. to use explicit credentials.
+package azure
+
+import (
+	"github.com/google/go-containerregistry/pkg/authn"
+	acr "github.com/google/go-containerregistry/pkg/azure"
+)
+
+// Keychain authenticates to ACR registries with credentials from the
+// environment (see acr.CredentialsFromEnv) or else the managed identity we're
+// running as (see acr.ManagedIdentityCredentials), and resolves other
+// registries, or any registry when no credentials are found, to
+// authn.Anonymous, so that it can be combined with other keychains using
+// authn.NewMultiKeychain.
+//
+// Refresh tokens are reused until shortly before they expire.
+var Keychain = NewKeychain()
+
+// NewKeychain returns a keychain like Keychain, configured by opts.
+func NewKeychain(opts ...acr.Option) authn.Keychain {
+	return acr.Keychain(opts...)
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestKeychainNotACR(t *testing.T) {
+	for _, s := range []string{"gcr.io", "docker.io", "mcr.microsoft.com", "myregistry.azurecr.io.example.com"} {
+		reg, err := name.NewRegistry(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Registries that aren't ACR don't need credentials to resolve.
+		auth, err := Keychain.Resolve(reg)
+		if err != nil {
+			t.Fatalf("Resolve(%s) = %v", s, err)
+		}
+		if auth != authn.Anonymous {
+			t.Errorf("Resolve(%s) = %v, want Anonymous", s, auth)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"sync"
+	"time"
+)
+
+type cachingKeychain struct {
+	inner Keychain
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*cacheEntry
+	nextSweep time.Time
+}
+
+// cacheEntry holds the result of resolving one target.  Until done is
+// closed, the resolution is in flight and the other fields must not be read.
+type cacheEntry struct {
+	done    chan struct{}
+	auth    Authenticator
+	err     error
+	expires time.Time
+}
+
+// Assert that our caching keychain implements Keychain.
+var _ (Keychain) = (*cachingKeychain)(nil)
+
+// NewCachingKeychain returns a Keychain that memoizes the Authenticators
+// resolved by inner for each registry or repository for ttl.  Concurrent
+// calls to Resolve the same target share a single call to inner.  Errors are
+// not cached.
+//
+// This is useful when resolving many images with a keychain that is expensive
+// to query, e.g. one that execs a credential helper.  If ttl is not positive,
+// inner is returned as is.
+func NewCachingKeychain(inner Keychain, ttl time.Duration) Keychain {
+	if ttl <= 0 {
+		return inner
+	}
+	return &cachingKeychain{
+		inner:   inner,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]*cacheEntry{},
+	}
+}
+
+// Resolve implements Keychain.
+func (ck *cachingKeychain) Resolve(target Resource) (Authenticator, error) {
+	key := target.String()
+
+	ck.mu.Lock()
+	ck.sweepLocked()
+	if e, ok := ck.entries[key]; ok {
+		select {
+		case <-e.done:
+			if ck.now().Before(e.expires) {
+				ck.mu.Unlock()
+				return e.auth, nil
+			}
+		default:
+			ck.mu.Unlock()
+			<-e.done
+			return e.auth, e.err
+		}
+	}
+	e := &cacheEntry{done: make(chan struct{})}
+	ck.entries[key] = e
+	ck.mu.Unlock()
+
+	e.auth, e.err = ck.inner.Resolve(target)
+	e.expires = ck.now().Add(ck.ttl)
+
+	ck.mu.Lock()
+	if e.err != nil && ck.entries[key] == e {
+		delete(ck.entries, key)
+	}
+	ck.mu.Unlock()
+	close(e.done)
+
+	return e.auth, e.err
+}
+
+// sweepLocked deletes the entries that have expired, at most once per ttl, so
+// that targets which are never resolved again don't accumulate.  ck.mu must be
+// held.
+func (ck *cachingKeychain) sweepLocked() {
+	now := ck.now()
+	if now.Before(ck.nextSweep) {
+		return
+	}
+	ck.nextSweep = now.Add(ck.ttl)
+	for key, e := range ck.entries {
+		select {
+		case <-e.done:
+			if !now.Before(e.expires) {
+				delete(ck.entries, key)
+			}
+		default:
+			// Still resolving.
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// countingKeychain resolves every target to auth, or to err, once release is
+// closed, and counts calls to Resolve.
+type countingKeychain struct {
+	auth    Authenticator
+	err     error
+	release chan struct{}
+	calls   int32
+}
+
+func (ck *countingKeychain) Resolve(Resource) (Authenticator, error) {
+	atomic.AddInt32(&ck.calls, 1)
+	if ck.release != nil {
+		<-ck.release
+	}
+	return ck.auth, ck.err
+}
+
+func TestCachingKeychain(t *testing.T) {
+	one := &Basic{Username: "one", Password: "secret"}
+	regOne, _ := name.NewRegistry("one.gcr.io", name.StrictValidation)
+	regTwo, _ := name.NewRegistry("two.gcr.io", name.StrictValidation)
+
+	t.Run("memoizes per target", func(t *testing.T) {
+		inner := &countingKeychain{auth: one}
+		kc := NewCachingKeychain(inner, time.Hour)
+		for _, reg := range []name.Registry{regOne, regOne, regTwo, regTwo} {
+			auth, err := kc.Resolve(reg)
+			if err != nil {
+				t.Fatalf("Resolve(%v) = %v", reg, err)
+			}
+			if auth != one {
+				t.Errorf("Resolve(%v) = %v, want %v", reg, auth, one)
+			}
+		}
+		if inner.calls != 2 {
+			t.Errorf("inner called %d times, want 2", inner.calls)
+		}
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		inner := &countingKeychain{auth: one}
+		kc := NewCachingKeychain(inner, time.Minute).(*cachingKeychain)
+		now := time.Now()
+		kc.now = func() time.Time { return now }
+		if _, err := kc.Resolve(regOne); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(30 * time.Second)
+		if _, err := kc.Resolve(regOne); err != nil {
+			t.Fatal(err)
+		}
+		if inner.calls != 1 {
+			t.Errorf("inner called %d times before expiry, want 1", inner.calls)
+		}
+		now = now.Add(time.Minute)
+		if _, err := kc.Resolve(regOne); err != nil {
+			t.Fatal(err)
+		}
+		if inner.calls != 2 {
+			t.Errorf("inner called %d times after expiry, want 2", inner.calls)
+		}
+	})
+
+	t.Run("evicts expired entries", func(t *testing.T) {
+		inner := &countingKeychain{auth: one}
+		kc := NewCachingKeychain(inner, time.Minute).(*cachingKeychain)
+		now := time.Now()
+		kc.now = func() time.Time { return now }
+		if _, err := kc.Resolve(regOne); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Minute)
+		if _, err := kc.Resolve(regTwo); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := kc.entries[regOne.String()]; ok {
+			t.Errorf("entry for %v not evicted after expiry", regOne)
+		}
+		if got := len(kc.entries); got != 1 {
+			t.Errorf("len(entries) = %d, want 1", got)
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		inner := &countingKeychain{err: errors.New("boom")}
+		kc := NewCachingKeychain(inner, time.Hour)
+		for i := 0; i < 2; i++ {
+			if _, err := kc.Resolve(regOne); err == nil {
+				t.Error("Resolve() = nil, wanted error")
+			}
+		}
+		if inner.calls != 2 {
+			t.Errorf("inner called %d times, want 2", inner.calls)
+		}
+	})
+
+	t.Run("deduplicates concurrent calls", func(t *testing.T) {
+		inner := &countingKeychain{auth: one, release: make(chan struct{})}
+		kc := NewCachingKeychain(inner, time.Hour)
+
+		const n = 10
+		var wg sync.WaitGroup
+		wg.Add(n)
+		for i := 0; i < n; i++ {
+			go func() {
+				defer wg.Done()
+				auth, err := kc.Resolve(regOne)
+				if err != nil {
+					t.Errorf("Resolve() = %v", err)
+				}
+				if auth != one {
+					t.Errorf("Resolve() = %v, want %v", auth, one)
+				}
+			}()
+		}
+		// Wait for the first call to reach inner before releasing it.
+		for atomic.LoadInt32(&inner.calls) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(inner.release)
+		wg.Wait()
+
+		if inner.calls != 1 {
+			t.Errorf("inner called %d times, want 1", inner.calls)
+		}
+	})
+
+	t.Run("non-positive ttl", func(t *testing.T) {
+		inner := &countingKeychain{auth: one}
+		if kc := NewCachingKeychain(inner, 0); kc != inner {
+			t.Errorf("NewCachingKeychain(inner, 0) = %v, want inner", kc)
+		}
+	})
+}
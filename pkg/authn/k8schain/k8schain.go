@@ -54,10 +54,31 @@ func New(ctx context.Context, client kubernetes.Interface, opt Options) (authn.K
 	), nil
 }
 
+// NewRefreshing returns a new authn.Keychain like New, except that it watches
+// the ServiceAccount and Secrets it reads, so that rotated credentials are
+// picked up.  It stops watching when ctx is done.  See kauth.NewRefreshing.
+func NewRefreshing(ctx context.Context, client kubernetes.Interface, opt Options) (authn.Keychain, error) {
+	k8s, err := kauth.NewRefreshing(ctx, client, kauth.Options(opt))
+	if err != nil {
+		return nil, err
+	}
+
+	return authn.NewMultiKeychain(
+		authn.DefaultKeychain,
+		google.Keychain,
+		amazonKeychain,
+		azureKeychain,
+		k8s,
+	), nil
+}
+
 // NewInCluster returns a new authn.Keychain suitable for resolving image references as
 // scoped by the provided Options, constructing a kubernetes.Interface based on in-cluster
-// authentication.
+// authentication. If opt.PullSecretsPaths is set, no client is constructed.
 func NewInCluster(ctx context.Context, opt Options) (authn.Keychain, error) {
+	if len(opt.PullSecretsPaths) > 0 {
+		return New(ctx, nil, opt)
+	}
 	clusterConfig, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, err
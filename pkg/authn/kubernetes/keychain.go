@@ -18,8 +18,12 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -40,12 +44,20 @@ type Options struct {
 	// ImagePullSecrets holds the names of the Kubernetes secrets (scoped to
 	// Namespace) containing credential data to use for the image pull.
 	ImagePullSecrets []string
+	// PullSecretsPaths holds the paths of pull secrets mounted into the
+	// container, e.g. by a secret or projected volume. If set, credentials
+	// are read from these instead of the API server, which needs no RBAC,
+	// and the fields above are ignored. See NewFromPullSecretsPaths.
+	PullSecretsPaths []string
 }
 
 // New returns a new authn.Keychain suitable for resolving image references as
 // scoped by the provided Options.  It speaks to Kubernetes through the provided
 // client interface.
 func New(ctx context.Context, client kubernetes.Interface, opt Options) (authn.Keychain, error) {
+	if len(opt.PullSecretsPaths) > 0 {
+		return NewFromPullSecretsPaths(ctx, opt.PullSecretsPaths)
+	}
 	if opt.Namespace == "" {
 		opt.Namespace = "default"
 	}
@@ -89,6 +101,9 @@ func New(ctx context.Context, client kubernetes.Interface, opt Options) (authn.K
 // scoped by the provided Options, constructing a kubernetes.Interface based on in-cluster
 // authentication.
 func NewInCluster(ctx context.Context, opt Options) (authn.Keychain, error) {
+	if len(opt.PullSecretsPaths) > 0 {
+		return NewFromPullSecretsPaths(ctx, opt.PullSecretsPaths)
+	}
 	clusterConfig, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, err
@@ -159,6 +174,69 @@ func NewFromPullSecrets(ctx context.Context, secrets []corev1.Secret) (authn.Key
 	return authsKeychain(m), nil
 }
 
+// NewFromPullSecretsPaths returns a new authn.Keychain suitable for resolving
+// image references as scoped by the pull secrets mounted at paths, in order.
+//
+// Each path is either a file holding a docker config, in the
+// .dockerconfigjson or legacy .dockercfg format, or a directory where a pull
+// secret is mounted, which holds a file named after either key.
+func NewFromPullSecretsPaths(ctx context.Context, paths []string) (authn.Keychain, error) {
+	var pullSecrets []corev1.Secret
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			ps, err := pullSecretFromFile(path)
+			if err != nil {
+				return nil, err
+			}
+			pullSecrets = append(pullSecrets, *ps)
+			continue
+		}
+
+		found := false
+		for _, key := range []string{corev1.DockerConfigJsonKey, corev1.DockerConfigKey} {
+			ps, err := pullSecretFromFile(filepath.Join(path, key))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			pullSecrets = append(pullSecrets, *ps)
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("no %s or %s file in %s", corev1.DockerConfigJsonKey, corev1.DockerConfigKey, path)
+		}
+	}
+	return NewFromPullSecrets(ctx, pullSecrets)
+}
+
+// pullSecretFromFile returns a pull secret holding the docker config in the
+// file at path, whose format is detected from its contents.
+func pullSecretFromFile(path string) (*corev1.Secret, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg map[string]json.RawMessage
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing pull secret %s: %w", path, err)
+	}
+	if _, ok := cfg["auths"]; ok {
+		return &corev1.Secret{
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: b},
+		}, nil
+	}
+	return &corev1.Secret{
+		Type: corev1.SecretTypeDockercfg,
+		Data: map[string][]byte{corev1.DockerConfigKey: b},
+	}, nil
+}
+
 type authsKeychain map[string]authn.AuthConfig
 
 func (kc authsKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
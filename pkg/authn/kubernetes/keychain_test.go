@@ -18,6 +18,9 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -287,3 +290,84 @@ func TestFromPullSecrets(t *testing.T) {
 		})
 	}
 }
+
+func TestFromPullSecretsPaths(t *testing.T) {
+	username, password := "foo", "bar"
+	specificUser, specificPass := "very", "specific"
+
+	dir := t.TempDir()
+	mounted := filepath.Join(dir, "mounted")
+	if err := os.Mkdir(mounted, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(mounted, corev1.DockerConfigJsonKey), []byte(
+		fmt.Sprintf(`{"auths": {"fake.registry.io/more/specific": {"auth": %q}}}`,
+			base64.StdEncoding.EncodeToString([]byte(specificUser+":"+specificPass))),
+	), 0644); err != nil {
+		t.Fatal(err)
+	}
+	legacy := filepath.Join(dir, "legacy.json")
+	if err := ioutil.WriteFile(legacy, []byte(
+		fmt.Sprintf(`{"fake.registry.io": {"auth": %q}}`,
+			base64.StdEncoding.EncodeToString([]byte(username+":"+password))),
+	), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The client is never used when PullSecretsPaths is set.
+	kc, err := New(context.Background(), nil, Options{
+		PullSecretsPaths: []string{mounted, legacy},
+	})
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	repo, err := name.NewRepository("fake.registry.io/more/specific", name.WeakValidation)
+	if err != nil {
+		t.Errorf("NewRepository() = %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		auth   authn.Authenticator
+		target authn.Resource
+	}{{
+		name:   "registry",
+		auth:   &authn.Basic{Username: username, Password: password},
+		target: repo.Registry,
+	}, {
+		name:   "repo",
+		auth:   &authn.Basic{Username: specificUser, Password: specificPass},
+		target: repo,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc := tc
+			auth, err := kc.Resolve(tc.target)
+			if err != nil {
+				t.Errorf("Resolve(%v) = %v", tc.target, err)
+			}
+			got, err := auth.Authorization()
+			if err != nil {
+				t.Errorf("Authorization() = %v", err)
+			}
+			want, err := tc.auth.Authorization()
+			if err != nil {
+				t.Errorf("Authorization() = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Resolve() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("no secret in directory", func(t *testing.T) {
+		if _, err := NewFromPullSecretsPaths(context.Background(), []string{dir}); err == nil {
+			t.Error("NewFromPullSecretsPaths() = nil, wanted error")
+		}
+	})
+	t.Run("missing path", func(t *testing.T) {
+		if _, err := NewFromPullSecretsPaths(context.Background(), []string{filepath.Join(dir, "missing")}); err == nil {
+			t.Error("NewFromPullSecretsPaths() = nil, wanted error")
+		}
+	})
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewRefreshing returns a new authn.Keychain like New, except that it watches
+// the ServiceAccount and Secrets that New reads, so that long-lived processes
+// pick up rotated credentials without constructing a new keychain.  It stops
+// watching when ctx is done.
+//
+// Unlike New, this lists and watches Secrets in the whole of opt.Namespace,
+// which needs the corresponding RBAC.  Pull secrets that don't exist are
+// skipped, since they may be created later.  If opt.PullSecretsPaths is set,
+// it behaves like New, and doesn't refresh.
+func NewRefreshing(ctx context.Context, client kubernetes.Interface, opt Options) (authn.Keychain, error) {
+	if len(opt.PullSecretsPaths) > 0 {
+		return NewFromPullSecretsPaths(ctx, opt.PullSecretsPaths)
+	}
+	if opt.Namespace == "" {
+		opt.Namespace = "default"
+	}
+	if opt.ServiceAccountName == "" {
+		opt.ServiceAccountName = "default"
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace(opt.Namespace))
+	secrets := factory.Core().V1().Secrets()
+	serviceAccounts := factory.Core().V1().ServiceAccounts()
+
+	kc := &refreshingKeychain{
+		ctx:             ctx,
+		opt:             opt,
+		secrets:         secrets.Lister().Secrets(opt.Namespace),
+		serviceAccounts: serviceAccounts.Lister().ServiceAccounts(opt.Namespace),
+		stale:           true,
+	}
+
+	// Any change may affect the credentials, so rebuild them lazily.
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { kc.invalidate() },
+		UpdateFunc: func(interface{}, interface{}) { kc.invalidate() },
+		DeleteFunc: func(interface{}) { kc.invalidate() },
+	}
+	secrets.Informer().AddEventHandler(handler)
+	serviceAccounts.Informer().AddEventHandler(handler)
+
+	factory.Start(ctx.Done())
+	for typ, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return nil, fmt.Errorf("syncing %v informer: %w", typ, ctx.Err())
+		}
+	}
+
+	// Fail early, like New, if the service account doesn't exist.
+	if _, err := kc.keychain(); err != nil {
+		return nil, err
+	}
+	return kc, nil
+}
+
+type refreshingKeychain struct {
+	ctx             context.Context
+	opt             Options
+	secrets         corev1listers.SecretNamespaceLister
+	serviceAccounts corev1listers.ServiceAccountNamespaceLister
+
+	mu    sync.Mutex
+	stale bool
+	kc    authn.Keychain
+}
+
+// Resolve implements authn.Keychain.
+func (k *refreshingKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	kc, err := k.keychain()
+	if err != nil {
+		return nil, err
+	}
+	return kc.Resolve(target)
+}
+
+func (k *refreshingKeychain) invalidate() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.stale = true
+}
+
+// keychain returns a keychain for the pull secrets in the informers' caches,
+// rebuilding it if they have changed since it was last built.
+func (k *refreshingKeychain) keychain() (authn.Keychain, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.stale {
+		return k.kc, nil
+	}
+
+	names := append([]string{}, k.opt.ImagePullSecrets...)
+	sa, err := k.serviceAccounts.Get(k.opt.ServiceAccountName)
+	if err != nil {
+		return nil, err
+	}
+	for _, localObj := range sa.ImagePullSecrets {
+		names = append(names, localObj.Name)
+	}
+
+	var pullSecrets []corev1.Secret
+	for _, name := range names {
+		ps, err := k.secrets.Get(name)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		pullSecrets = append(pullSecrets, *ps)
+	}
+
+	kc, err := NewFromPullSecrets(k.ctx, pullSecrets)
+	if err != nil {
+		return nil, err
+	}
+	k.kc, k.stale = kc, false
+	return kc, nil
+}
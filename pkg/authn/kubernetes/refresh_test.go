@@ -0,0 +1,133 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRefreshing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	secret := func(username, password string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "ns",
+			},
+			Type: corev1.SecretTypeDockercfg,
+			Data: map[string][]byte{
+				corev1.DockerConfigKey: []byte(
+					fmt.Sprintf(`{"fake.registry.io": {"auth": %q}}`,
+						base64.StdEncoding.EncodeToString([]byte(username+":"+password))),
+				),
+			},
+		}
+	}
+	client := fakeclient.NewSimpleClientset(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "svcacct",
+			Namespace: "ns",
+		},
+		ImagePullSecrets: []corev1.LocalObjectReference{{
+			Name: "secret",
+		}, {
+			// Missing secrets are skipped.
+			Name: "missing",
+		}},
+	}, secret("foo", "bar"))
+
+	kc, err := NewRefreshing(ctx, client, Options{
+		Namespace:          "ns",
+		ServiceAccountName: "svcacct",
+	})
+	if err != nil {
+		t.Fatalf("NewRefreshing() = %v", err)
+	}
+
+	reg, err := name.NewRegistry("fake.registry.io", name.WeakValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve := func() *authn.AuthConfig {
+		t.Helper()
+		auth, err := kc.Resolve(reg)
+		if err != nil {
+			t.Fatalf("Resolve() = %v", err)
+		}
+		cfg, err := auth.Authorization()
+		if err != nil {
+			t.Fatalf("Authorization() = %v", err)
+		}
+		return cfg
+	}
+	if got := resolve(); got.Username != "foo" || got.Password != "bar" {
+		t.Errorf("Resolve() = %+v, want foo:bar", got)
+	}
+
+	// Rotate the credentials, and wait for the keychain to notice.
+	if _, err := client.CoreV1().Secrets("ns").Update(ctx, secret("baz", "quux"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		got := resolve()
+		if got.Username == "baz" && got.Password == "quux" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Resolve() = %+v, want baz:quux", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Removing the secret falls back to anonymous.
+	if err := client.CoreV1().Secrets("ns").Delete(ctx, "secret", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		auth, err := kc.Resolve(reg)
+		if err != nil {
+			t.Fatalf("Resolve() = %v", err)
+		}
+		if auth == authn.Anonymous {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Resolve() = %v, want Anonymous", auth)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRefreshingMissingServiceAccount(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := NewRefreshing(ctx, fakeclient.NewSimpleClientset(), Options{}); err == nil {
+		t.Error("NewRefreshing() = nil, wanted error")
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// tokenExchangeGrantType and jwtTokenType are defined by RFC 8693.
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	jwtTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+
+	defaultExpirationSeconds = 3600
+
+	// refreshSkew is how long before expiry a token is considered stale, to
+	// allow for clock skew and for time spent in flight.
+	refreshSkew = time.Minute
+)
+
+// WorkloadIdentity holds configuration for exchanging bound ServiceAccount
+// tokens, issued through the TokenRequest API, for registry credentials.
+// This is how workload identity federation works on EKS, GKE and AKS, and it
+// needs no static pull secrets.
+type WorkloadIdentity struct {
+	// Namespace holds the namespace of ServiceAccountName.  If empty,
+	// "default" is assumed.
+	Namespace string
+	// ServiceAccountName holds the serviceaccount for which tokens are
+	// requested.  If empty, "default" is assumed.
+	ServiceAccountName string
+	// Audience is the audience the tokens are bound to, which the token
+	// endpoint or registry must accept.  It is required.
+	Audience string
+	// ExpirationSeconds is the requested lifetime of each token.  If zero,
+	// an hour is requested.  The API server may issue shorter tokens.
+	ExpirationSeconds int64
+	// Registries holds the registries, or repositories, that the credentials
+	// are presented to.  Any other target resolves to authn.Anonymous.
+	Registries []string
+	// TokenEndpoint is the URL of an RFC 8693 token exchange endpoint.  If
+	// set, the ServiceAccount token is exchanged there for an access token
+	// that is sent to the registry as a bearer token.  If empty, the
+	// ServiceAccount token is sent to the registry as an identity token,
+	// which the registry's token service exchanges in the OAuth2 flow.
+	TokenEndpoint string
+	// Transport is used to reach TokenEndpoint.  If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+}
+
+// NewWorkloadIdentity returns a new authn.Keychain that resolves the
+// configured registries to credentials obtained by exchanging bound
+// ServiceAccount tokens.  It speaks to Kubernetes through the provided client
+// interface.  Tokens are requested lazily, and reused until shortly before
+// they expire.
+func NewWorkloadIdentity(ctx context.Context, client kubernetes.Interface, wi WorkloadIdentity) (authn.Keychain, error) {
+	if wi.Audience == "" {
+		return nil, errors.New("workload identity: audience is required")
+	}
+	if len(wi.Registries) == 0 {
+		return nil, errors.New("workload identity: no registries configured")
+	}
+	if wi.TokenEndpoint != "" {
+		if _, err := url.Parse(wi.TokenEndpoint); err != nil {
+			return nil, fmt.Errorf("workload identity: parsing token endpoint: %w", err)
+		}
+	}
+	if wi.Namespace == "" {
+		wi.Namespace = "default"
+	}
+	if wi.ServiceAccountName == "" {
+		wi.ServiceAccountName = "default"
+	}
+	if wi.ExpirationSeconds == 0 {
+		wi.ExpirationSeconds = defaultExpirationSeconds
+	}
+	if wi.Transport == nil {
+		wi.Transport = http.DefaultTransport
+	}
+
+	kc := &workloadIdentityKeychain{
+		auth: &workloadIdentityAuthenticator{
+			client: client,
+			wi:     wi,
+			now:    time.Now,
+		},
+		registries: map[string]struct{}{},
+	}
+	for _, r := range wi.Registries {
+		kc.registries[r] = struct{}{}
+	}
+	return kc, nil
+}
+
+type workloadIdentityKeychain struct {
+	auth       *workloadIdentityAuthenticator
+	registries map[string]struct{}
+}
+
+// Resolve implements authn.Keychain.
+func (kc *workloadIdentityKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	for _, key := range []string{target.String(), target.RegistryStr()} {
+		if _, ok := kc.registries[key]; ok {
+			return kc.auth, nil
+		}
+	}
+	return authn.Anonymous, nil
+}
+
+// workloadIdentityAuthenticator is shared by every target of a keychain, so
+// that they share a cached token.
+type workloadIdentityAuthenticator struct {
+	client kubernetes.Interface
+	wi     WorkloadIdentity
+	now    func() time.Time
+
+	sync.Mutex
+	cfg     *authn.AuthConfig
+	expires time.Time
+}
+
+// Authorization implements authn.Authenticator.
+func (a *workloadIdentityAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.cfg != nil && a.now().Add(refreshSkew).Before(a.expires) {
+		return a.cfg, nil
+	}
+
+	ctx := context.Background()
+	token, expires, err := a.requestToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &authn.AuthConfig{IdentityToken: token}
+	if a.wi.TokenEndpoint != "" {
+		cfg, expires, err = a.exchange(ctx, token, expires)
+		if err != nil {
+			return nil, err
+		}
+	}
+	a.cfg, a.expires = cfg, expires
+	return cfg, nil
+}
+
+// requestToken requests a ServiceAccount token bound to the audience.
+func (a *workloadIdentityAuthenticator) requestToken(ctx context.Context) (string, time.Time, error) {
+	expirationSeconds := a.wi.ExpirationSeconds
+	tr, err := a.client.CoreV1().ServiceAccounts(a.wi.Namespace).CreateToken(ctx, a.wi.ServiceAccountName, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         []string{a.wi.Audience},
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("requesting token for serviceaccount %s/%s: %w", a.wi.Namespace, a.wi.ServiceAccountName, err)
+	}
+	if tr.Status.Token == "" {
+		return "", time.Time{}, fmt.Errorf("requesting token for serviceaccount %s/%s: empty token", a.wi.Namespace, a.wi.ServiceAccountName)
+	}
+	return tr.Status.Token, tr.Status.ExpirationTimestamp.Time, nil
+}
+
+// tokenExchangeResponse is the subset of an RFC 8693 response that we use.
+type tokenExchangeResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// exchange trades the ServiceAccount token for an access token at the token
+// endpoint.  The access token expires no later than the token it was issued
+// for, unless the response says otherwise.
+func (a *workloadIdentityAuthenticator) exchange(ctx context.Context, token string, expires time.Time) (*authn.AuthConfig, time.Time, error) {
+	form := url.Values{
+		"grant_type":         {tokenExchangeGrantType},
+		"subject_token":      {token},
+		"subject_token_type": {jwtTokenType},
+		"audience":           {a.wi.Audience},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.wi.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := (&http.Client{Transport: a.wi.Transport}).Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("exchanging token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("exchanging token: unexpected status %s from %s", resp.Status, a.wi.TokenEndpoint)
+	}
+
+	var tr tokenExchangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, time.Time{}, fmt.Errorf("exchanging token: parsing response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return nil, time.Time{}, errors.New("exchanging token: no access_token in response")
+	}
+	if tr.ExpiresIn > 0 {
+		expires = a.now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return &authn.AuthConfig{RegistryToken: tr.AccessToken}, expires, nil
+}
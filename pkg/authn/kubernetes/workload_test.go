@@ -0,0 +1,172 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeTokenClient returns a client that issues a new token, valid for an
+// hour, for each TokenRequest, and counts them in *requests.
+func fakeTokenClient(t *testing.T, requests *int) *fakeclient.Clientset {
+	client := fakeclient.NewSimpleClientset()
+	client.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		ca := action.(k8stesting.CreateAction)
+		if ca.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		tr := ca.GetObject().(*authenticationv1.TokenRequest)
+		if got, want := tr.Spec.Audiences, []string{"registry.example"}; len(got) != 1 || got[0] != want[0] {
+			t.Errorf("Audiences = %v, want %v", got, want)
+		}
+		*requests++
+		return true, &authenticationv1.TokenRequest{
+			Status: authenticationv1.TokenRequestStatus{
+				Token:               "sa-token",
+				ExpirationTimestamp: metav1.NewTime(time.Now().Add(time.Hour)),
+			},
+		}, nil
+	})
+	return client
+}
+
+func TestWorkloadIdentity(t *testing.T) {
+	repo, err := name.NewRepository("fake.registry.io/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := name.NewRepository("other.registry.io/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("identity token", func(t *testing.T) {
+		var requests int
+		kc, err := NewWorkloadIdentity(context.Background(), fakeTokenClient(t, &requests), WorkloadIdentity{
+			Audience:   "registry.example",
+			Registries: []string{"fake.registry.io"},
+		})
+		if err != nil {
+			t.Fatalf("NewWorkloadIdentity() = %v", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			auth, err := kc.Resolve(repo)
+			if err != nil {
+				t.Fatalf("Resolve() = %v", err)
+			}
+			cfg, err := auth.Authorization()
+			if err != nil {
+				t.Fatalf("Authorization() = %v", err)
+			}
+			if want := (authn.AuthConfig{IdentityToken: "sa-token"}); *cfg != want {
+				t.Errorf("Authorization() = %+v, want %+v", *cfg, want)
+			}
+		}
+		if requests != 1 {
+			t.Errorf("requested %d tokens, want 1", requests)
+		}
+
+		auth, err := kc.Resolve(other)
+		if err != nil {
+			t.Fatalf("Resolve() = %v", err)
+		}
+		if auth != authn.Anonymous {
+			t.Errorf("Resolve(%v) = %v, want Anonymous", other, auth)
+		}
+	})
+
+	t.Run("token exchange", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := r.ParseForm(); err != nil {
+				t.Error(err)
+				return
+			}
+			if got, want := r.PostForm.Get("grant_type"), tokenExchangeGrantType; got != want {
+				t.Errorf("grant_type = %q, want %q", got, want)
+			}
+			if got, want := r.PostForm.Get("subject_token"), "sa-token"; got != want {
+				t.Errorf("subject_token = %q, want %q", got, want)
+			}
+			json.NewEncoder(w).Encode(tokenExchangeResponse{AccessToken: "access-token", ExpiresIn: 3600})
+		}))
+		defer server.Close()
+
+		var requests int
+		kc, err := NewWorkloadIdentity(context.Background(), fakeTokenClient(t, &requests), WorkloadIdentity{
+			Audience:      "registry.example",
+			Registries:    []string{"fake.registry.io/foo"},
+			TokenEndpoint: server.URL,
+		})
+		if err != nil {
+			t.Fatalf("NewWorkloadIdentity() = %v", err)
+		}
+
+		auth, err := kc.Resolve(repo)
+		if err != nil {
+			t.Fatalf("Resolve() = %v", err)
+		}
+		cfg, err := auth.Authorization()
+		if err != nil {
+			t.Fatalf("Authorization() = %v", err)
+		}
+		if want := (authn.AuthConfig{RegistryToken: "access-token"}); *cfg != want {
+			t.Errorf("Authorization() = %+v, want %+v", *cfg, want)
+		}
+	})
+
+	t.Run("expired token is refreshed", func(t *testing.T) {
+		var requests int
+		kc, err := NewWorkloadIdentity(context.Background(), fakeTokenClient(t, &requests), WorkloadIdentity{
+			Audience:   "registry.example",
+			Registries: []string{"fake.registry.io"},
+		})
+		if err != nil {
+			t.Fatalf("NewWorkloadIdentity() = %v", err)
+		}
+		a := kc.(*workloadIdentityKeychain).auth
+		if _, err := a.Authorization(); err != nil {
+			t.Fatalf("Authorization() = %v", err)
+		}
+		a.now = func() time.Time { return time.Now().Add(time.Hour) }
+		if _, err := a.Authorization(); err != nil {
+			t.Fatalf("Authorization() = %v", err)
+		}
+		if requests != 2 {
+			t.Errorf("requested %d tokens, want 2", requests)
+		}
+	})
+
+	t.Run("missing audience", func(t *testing.T) {
+		if _, err := NewWorkloadIdentity(context.Background(), fakeclient.NewSimpleClientset(), WorkloadIdentity{
+			Registries: []string{"fake.registry.io"},
+		}); err == nil {
+			t.Error("NewWorkloadIdentity() = nil, wanted error")
+		}
+	})
+}
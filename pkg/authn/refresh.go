@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Expirer is implemented by Authenticators that know when the credentials
+// they return expire, so that their users can get new ones beforehand.
+type Expirer interface {
+	// Expiry returns when the credentials last returned by Authorization
+	// expire, or the zero time if they don't, or it isn't known.
+	Expiry() time.Time
+}
+
+// ExpiryFunc returns when the credentials in auth expire, or the zero time
+// if they don't, or it can't tell.
+type ExpiryFunc func(auth *AuthConfig) time.Time
+
+// refreshSkew is how long before credentials expire that a refreshing
+// authenticator gets new ones. It's longer than the transport's skew, so
+// that a transport that refreshes a token because it's about to expire gets
+// a new one.
+const refreshSkew = time.Minute
+
+type refreshingAuthenticator struct {
+	inner  Authenticator
+	expiry ExpiryFunc
+	now    func() time.Time
+
+	mu      sync.Mutex
+	auth    *AuthConfig
+	expires time.Time
+}
+
+// Assert that our refreshing authenticator implements Authenticator and
+// Expirer.
+var (
+	_ Authenticator = (*refreshingAuthenticator)(nil)
+	_ Expirer       = (*refreshingAuthenticator)(nil)
+)
+
+// NewRefreshingAuthenticator returns an Authenticator that memoizes the
+// credentials returned by inner, and gets new ones from inner shortly before
+// they expire, according to expiry. If expiry is nil, TokenExpiry is used.
+// Credentials that don't expire are memoized forever.
+//
+// The returned Authenticator implements Expirer, so that transports which
+// exchange its credentials for bearer tokens get new tokens before they
+// expire, rather than failing part way through long operations.
+func NewRefreshingAuthenticator(inner Authenticator, expiry ExpiryFunc) Authenticator {
+	if expiry == nil {
+		expiry = TokenExpiry
+	}
+	return &refreshingAuthenticator{
+		inner:  inner,
+		expiry: expiry,
+		now:    time.Now,
+	}
+}
+
+// Authorization implements Authenticator.
+func (ra *refreshingAuthenticator) Authorization() (*AuthConfig, error) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if ra.auth != nil && (ra.expires.IsZero() || ra.now().Before(ra.expires.Add(-refreshSkew))) {
+		return ra.auth, nil
+	}
+
+	auth, err := ra.inner.Authorization()
+	if err != nil {
+		return nil, err
+	}
+	ra.auth, ra.expires = auth, ra.expiry(auth)
+	return ra.auth, nil
+}
+
+// Expiry implements Expirer.
+func (ra *refreshingAuthenticator) Expiry() time.Time {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	return ra.expires
+}
+
+// TokenExpiry returns the expiry ("exp" claim) of the first of auth's
+// RegistryToken, IdentityToken, and Password that is a JWT, as many registry
+// and identity tokens are. It returns the zero time if none of them is.
+func TokenExpiry(auth *AuthConfig) time.Time {
+	for _, token := range []string{auth.RegistryToken, auth.IdentityToken, auth.Password} {
+		parts := strings.Split(token, ".")
+		if len(parts) != 3 {
+			continue
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err != nil {
+			continue
+		}
+		var claims struct {
+			Exp int64 `json:"exp"`
+		}
+		if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+			continue
+		}
+		return time.Unix(claims.Exp, 0)
+	}
+	return time.Time{}
+}
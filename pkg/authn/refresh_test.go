@@ -0,0 +1,137 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// countingAuthenticator returns a new token, or err, on each call.
+type countingAuthenticator struct {
+	err   error
+	calls int
+}
+
+func (ca *countingAuthenticator) Authorization() (*AuthConfig, error) {
+	ca.calls++
+	if ca.err != nil {
+		return nil, ca.err
+	}
+	return &AuthConfig{RegistryToken: fmt.Sprintf("token-%d", ca.calls)}, nil
+}
+
+func TestRefreshingAuthenticator(t *testing.T) {
+	// Tokens last an hour from when they're fetched.
+	start := time.Now()
+	now := start
+	inner := &countingAuthenticator{}
+	auth := NewRefreshingAuthenticator(inner, func(*AuthConfig) time.Time {
+		return now.Add(time.Hour)
+	})
+	auth.(*refreshingAuthenticator).now = func() time.Time { return now }
+
+	for _, step := range []struct {
+		at   time.Duration
+		want string
+	}{{
+		at:   0,
+		want: "token-1",
+	}, {
+		at:   30 * time.Minute,
+		want: "token-1",
+	}, {
+		// Within a minute of expiry.
+		at:   59 * time.Minute,
+		want: "token-2",
+	}} {
+		now = start.Add(step.at)
+		got, err := auth.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.RegistryToken != step.want {
+			t.Errorf("at %v: Authorization() = %q, want %q", step.at, got.RegistryToken, step.want)
+		}
+	}
+	if got, want := auth.(Expirer).Expiry(), start.Add(59*time.Minute+time.Hour); !got.Equal(want) {
+		t.Errorf("Expiry() = %v, want %v", got, want)
+	}
+
+	// Errors aren't memoized.
+	inner.err = errors.New("nope")
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if _, err := auth.Authorization(); !errors.Is(err, inner.err) {
+			t.Errorf("Authorization() = %v, want %v", err, inner.err)
+		}
+	}
+	if inner.calls != 4 {
+		t.Errorf("inner called %d times, want 4", inner.calls)
+	}
+}
+
+func TestRefreshingAuthenticatorNoExpiry(t *testing.T) {
+	inner := &countingAuthenticator{}
+	auth := NewRefreshingAuthenticator(inner, nil)
+	for i := 0; i < 3; i++ {
+		if _, err := auth.Authorization(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inner.calls != 1 {
+		t.Errorf("inner called %d times, want 1", inner.calls)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	jwt := func(claims string) string {
+		enc := base64.RawURLEncoding.EncodeToString
+		return enc([]byte(`{"alg":"none"}`)) + "." + enc([]byte(claims)) + ".sig"
+	}
+	exp := time.Unix(1700000000, 0)
+
+	for _, tc := range []struct {
+		name string
+		auth AuthConfig
+		want time.Time
+	}{{
+		name: "registry token",
+		auth: AuthConfig{RegistryToken: jwt(`{"exp":1700000000}`)},
+		want: exp,
+	}, {
+		name: "password",
+		auth: AuthConfig{Username: "user", Password: jwt(`{"exp":1700000000,"sub":"user"}`)},
+		want: exp,
+	}, {
+		name: "opaque token",
+		auth: AuthConfig{RegistryToken: "ya29.not-a-jwt"},
+	}, {
+		name: "no exp",
+		auth: AuthConfig{IdentityToken: jwt(`{"sub":"user"}`)},
+	}, {
+		name: "basic",
+		auth: AuthConfig{Username: "user", Password: "hunter2"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := TokenExpiry(&tc.auth); !got.Equal(tc.want) {
+				t.Errorf("TokenExpiry() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
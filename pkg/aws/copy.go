@@ -0,0 +1,77 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/internal/mirror"
+	"github.com/google/go-containerregistry/pkg/authn"
+	awsauthn "github.com/google/go-containerregistry/pkg/authn/aws"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// CopyRegistry copies every image in every repository of the ECR registry
+// src to dst, keeping repository names and tags, e.g. copying
+// "123456789012.dkr.ecr.us-east-1.amazonaws.com/app" to "gcr.io/mirror/app".
+// Images and tags that already exist in dst are skipped.
+//
+// Registries are accessed with the ECR keychain from pkg/authn/aws, which is
+// given the same configuration and roles, falling back to
+// authn.DefaultKeychain; use WithRemoteOptions to authenticate differently.
+func CopyRegistry(ctx context.Context, src, dst string, opts ...Option) error {
+	o := makeOptions(opts...)
+	reg, err := name.NewRegistry(src)
+	if err != nil {
+		return fmt.Errorf("parsing registry %q: %w", src, err)
+	}
+
+	o.ctx = ctx
+	cfg, err := o.config()
+	if err != nil {
+		return err
+	}
+	kopts := []awsauthn.Option{
+		awsauthn.WithContext(ctx),
+		awsauthn.WithConfig(cfg),
+		awsauthn.WithClientOptions(o.client...),
+	}
+	for account, role := range o.roles {
+		kopts = append(kopts, awsauthn.WithAccountRole(account, role))
+	}
+
+	ropts := append([]remote.Option{
+		remote.WithAuthFromKeychain(authn.NewMultiKeychain(awsauthn.NewKeychain(kopts...), authn.DefaultKeychain)),
+		remote.WithContext(ctx),
+	}, o.remote...)
+
+	list := func(ctx context.Context, yield func(name.Repository, []mirror.Image) error) error {
+		wo := *o
+		wo.ctx = ctx
+		return wo.walk(reg, func(repo name.Repository, images []Image, err error) error {
+			if err != nil {
+				return fmt.Errorf("listing %s: %w", repo, err)
+			}
+			copies := make([]mirror.Image, 0, len(images))
+			for _, img := range images {
+				copies = append(copies, mirror.Image{Digest: img.Digest, Tags: img.Tags})
+			}
+			return yield(repo, copies)
+		})
+	}
+	return mirror.Registry(ctx, dst, o.jobs, list, ropts...)
+}
@@ -0,0 +1,22 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aws holds helpers for listing and copying Amazon Elastic Container
+// Registry (ECR) registries using the ECR API, analogous to gcrane for GCR.
+//
+// It uses the AWS SDK, and authenticates to registries with the keychain
+// from github.com/google/go-containerregistry/pkg/authn/aws. Like that
+// package, it's a separate module, so that the AWS SDK is only a dependency
+// of programs that use it.
+package aws
@@ -0,0 +1,172 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// ecrHost matches ECR registries, e.g. 123456789012.dkr.ecr.us-east-1.amazonaws.com.
+var ecrHost = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(-fips)?\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+// registry identifies an ECR registry, and holds a client for the ECR API
+// that serves it.
+type registry struct {
+	account string
+	client  *ecr.Client
+}
+
+func (o *options) registry(reg name.Registry) (*registry, error) {
+	m := ecrHost.FindStringSubmatch(reg.RegistryStr())
+	if m == nil {
+		return nil, fmt.Errorf("%s is not an ECR registry", reg)
+	}
+	account, region, fips := m[1], m[3], m[2] != ""
+
+	cfg, err := o.config()
+	if err != nil {
+		return nil, err
+	}
+	client := ecr.NewFromConfig(cfg, append([]func(*ecr.Options){func(eo *ecr.Options) {
+		eo.Region = region
+		if fips {
+			eo.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		}
+		if role, ok := o.roles[account]; ok {
+			creds, ok := o.assumed[account]
+			if !ok {
+				creds = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role))
+				o.assumed[account] = creds
+			}
+			eo.Credentials = creds
+		}
+	}}, o.client...)...)
+	return &registry{account: account, client: client}, nil
+}
+
+// Repositories uses the ECR API to list the repositories in reg, e.g.
+// "123456789012.dkr.ecr.us-east-1.amazonaws.com". The account ID in reg's
+// hostname is passed along, so the repositories of other accounts can be
+// listed given the right permissions.
+func Repositories(reg name.Registry, opts ...Option) ([]name.Repository, error) {
+	return makeOptions(opts...).repositories(reg)
+}
+
+func (o *options) repositories(reg name.Registry) ([]name.Repository, error) {
+	r, err := o.registry(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []name.Repository
+	p := ecr.NewDescribeRepositoriesPaginator(r.client, &ecr.DescribeRepositoriesInput{
+		RegistryId: aws.String(r.account),
+		MaxResults: aws.Int32(1000),
+	})
+	for p.HasMorePages() {
+		out, err := p.NextPage(o.ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, repo := range out.Repositories {
+			nr, err := name.NewRepository(fmt.Sprintf("%s/%s", reg.RegistryStr(), aws.ToString(repo.RepositoryName)), name.StrictValidation)
+			if err != nil {
+				return nil, err
+			}
+			repos = append(repos, nr)
+		}
+	}
+	return repos, nil
+}
+
+// Image is an image in an ECR repository.
+type Image struct {
+	Digest string
+	Tags   []string
+}
+
+// List uses the ECR API to list the images in repo, with their tags.
+func List(repo name.Repository, opts ...Option) ([]Image, error) {
+	return makeOptions(opts...).list(repo)
+}
+
+func (o *options) list(repo name.Repository) ([]Image, error) {
+	r, err := o.registry(repo.Registry)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []Image
+	index := map[string]int{}
+	p := ecr.NewListImagesPaginator(r.client, &ecr.ListImagesInput{
+		RegistryId:     aws.String(r.account),
+		RepositoryName: aws.String(repo.RepositoryStr()),
+		MaxResults:     aws.Int32(1000),
+	})
+	for p.HasMorePages() {
+		out, err := p.NextPage(o.ctx)
+		if err != nil {
+			return nil, err
+		}
+		// There's an entry for each tag, and one for each untagged image.
+		for _, id := range out.ImageIds {
+			digest := aws.ToString(id.ImageDigest)
+			i, ok := index[digest]
+			if !ok {
+				i = len(images)
+				index[digest] = i
+				images = append(images, Image{Digest: digest})
+			}
+			if tag := aws.ToString(id.ImageTag); tag != "" {
+				images[i].Tags = append(images[i].Tags, tag)
+			}
+		}
+	}
+	return images, nil
+}
+
+// WalkFunc is the type of the function called for each repository visited by
+// Walk. The images and error arguments are the result of calling List on repo.
+type WalkFunc func(repo name.Repository, images []Image, err error) error
+
+// Walk calls walkFn for each repository in reg. ECR repositories aren't
+// nested, so unlike google.Walk, this just lists them. If walkFn returns an
+// error, Walk stops and returns it.
+func Walk(reg name.Registry, walkFn WalkFunc, opts ...Option) error {
+	return makeOptions(opts...).walk(reg, walkFn)
+}
+
+// walk shares o between repositories, so that the configuration is loaded,
+// and roles are assumed, once rather than for each repository.
+func (o *options) walk(reg name.Registry, walkFn WalkFunc) error {
+	repos, err := o.repositories(reg)
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		images, err := o.list(repo)
+		if err := walkFn(repo, images, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/google/go-cmp/cmp"
+	ggcrtest "github.com/google/go-containerregistry/internal/httptest"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/retention"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+const host = "123456789012.dkr.ecr.us-east-1.amazonaws.com"
+
+var cfg = aws.Config{
+	Region:      "us-west-2",
+	Credentials: credentials.NewStaticCredentialsProvider("AKID", "secret", ""),
+}
+
+type imageID struct {
+	ImageDigest string `json:"imageDigest"`
+	ImageTag    string `json:"imageTag,omitempty"`
+}
+
+// fakeECR serves both the ECR API and a registry that requires the tokens
+// it hands out.
+type fakeECR struct {
+	t        *testing.T
+	registry http.Handler
+	images   map[string][]imageID
+	tokens   int
+}
+
+func (f *fakeECR) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/v2/") {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "AWS" || pass != "hunter2" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ecr"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f.registry.ServeHTTP(w, r)
+		return
+	}
+
+	if got := r.Header.Get("Authorization"); !strings.HasPrefix(got, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(got, "/us-east-1/ecr/aws4_request") {
+		f.t.Errorf("Authorization = %q", got)
+	}
+	var in struct {
+		RegistryID     string `json:"registryId"`
+		RepositoryName string `json:"repositoryName"`
+		NextToken      string `json:"nextToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		f.t.Fatal(err)
+	}
+
+	var out interface{}
+	switch target := r.Header.Get("X-Amz-Target"); target {
+	case "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken":
+		f.tokens++
+		out = map[string]interface{}{
+			"authorizationData": []map[string]interface{}{{
+				"authorizationToken": base64.StdEncoding.EncodeToString([]byte("AWS:hunter2")),
+				"expiresAt":          float64(time.Now().Add(12 * time.Hour).Unix()),
+			}},
+		}
+	case "AmazonEC2ContainerRegistry_V20150921.DescribeRepositories":
+		if in.RegistryID != "123456789012" {
+			f.t.Errorf("registryId = %q", in.RegistryID)
+		}
+		// Return one repository per page.
+		if in.NextToken == "" {
+			out = map[string]interface{}{
+				"repositories": []map[string]string{{"repositoryName": "app"}},
+				"nextToken":    "more",
+			}
+		} else {
+			out = map[string]interface{}{
+				"repositories": []map[string]string{{"repositoryName": "tools/lint"}},
+			}
+		}
+	case "AmazonEC2ContainerRegistry_V20150921.ListImages":
+		ids, ok := f.images[in.RepositoryName]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"__type":  "com.amazonaws.ecr#RepositoryNotFoundException",
+				"message": "not found",
+			})
+			return
+		}
+		out = map[string]interface{}{"imageIds": ids}
+	default:
+		f.t.Errorf("unexpected target %q", target)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func setup(t *testing.T) (*fakeECR, []Option, remote.Option) {
+	t.Helper()
+	f := &fakeECR{
+		t:        t,
+		registry: ggcrregistry.New(ggcrregistry.Logger(log.New(ioutil.Discard, "", 0))),
+		images:   map[string][]imageID{},
+	}
+	s, err := ggcrtest.NewTLSServer(host, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+
+	opts := []Option{
+		WithTransport(s.Client().Transport),
+		WithEndpoint("https://" + host),
+		WithConfig(cfg),
+	}
+	ropt := remote.WithTransport(s.Client().Transport)
+	return f, opts, ropt
+}
+
+func TestList(t *testing.T) {
+	f, opts, _ := setup(t)
+	f.images["app"] = []imageID{
+		{ImageDigest: "sha256:a", ImageTag: "v1"},
+		{ImageDigest: "sha256:b"},
+		{ImageDigest: "sha256:a", ImageTag: "latest"},
+	}
+	f.images["tools/lint"] = []imageID{}
+
+	repos, err := Repositories(name.MustParseReference(host+"/foo").Context().Registry, opts...)
+	if err != nil {
+		t.Fatalf("Repositories() = %v", err)
+	}
+	var got []string
+	for _, r := range repos {
+		got = append(got, r.String())
+	}
+	if diff := cmp.Diff([]string{host + "/app", host + "/tools/lint"}, got); diff != "" {
+		t.Errorf("Repositories() (-want +got) = %s", diff)
+	}
+
+	images, err := List(repos[0], opts...)
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	want := []Image{
+		{Digest: "sha256:a", Tags: []string{"v1", "latest"}},
+		{Digest: "sha256:b"},
+	}
+	if diff := cmp.Diff(want, images); diff != "" {
+		t.Errorf("List() (-want +got) = %s", diff)
+	}
+
+	wantRetention := []retention.Image{
+		{Digest: "sha256:a", Tags: []string{"v1", "latest"}},
+		{Digest: "sha256:b"},
+	}
+	if diff := cmp.Diff(wantRetention, Retention(images)); diff != "" {
+		t.Errorf("Retention() (-want +got) = %s", diff)
+	}
+
+	_, err = List(name.MustParseReference(host+"/missing").Context(), opts...)
+	var notFound *types.RepositoryNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Errorf("List() = %v, want RepositoryNotFoundException", err)
+	}
+
+	if _, err := List(name.MustParseReference("gcr.io/not/ecr").Context(), opts...); err == nil {
+		t.Error("List() succeeded for a non-ECR registry")
+	}
+}
+
+func TestCopyRegistry(t *testing.T) {
+	f, opts, ropt := setup(t)
+	rauth := remote.WithAuth(&authn.Basic{Username: "AWS", Password: "hunter2"})
+
+	tagged, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untagged, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, _ := tagged.Digest()
+	ud, _ := untagged.Digest()
+	id, _ := idx.Digest()
+
+	app := name.MustParseReference(host + "/app").Context()
+	lint := name.MustParseReference(host + "/tools/lint").Context()
+	if err := remote.Write(app.Tag("v1"), tagged, ropt, rauth); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(app.Digest(ud.String()), untagged, ropt, rauth); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(lint.Tag("latest"), idx, ropt, rauth); err != nil {
+		t.Fatal(err)
+	}
+	f.images["app"] = []imageID{{ImageDigest: td.String(), ImageTag: "v1"}, {ImageDigest: ud.String()}}
+	f.images["tools/lint"] = []imageID{{ImageDigest: id.String(), ImageTag: "latest"}}
+
+	// Copying again should be a no-op.
+	for i := 0; i < 2; i++ {
+		if err := CopyRegistry(context.Background(), host, host+"/mirror", append(opts, WithJobs(2))...); err != nil {
+			t.Fatalf("CopyRegistry() = %v", err)
+		}
+	}
+
+	for ref, want := range map[string]string{
+		host + "/mirror/app:v1":             td.String(),
+		host + "/mirror/app@" + ud.String(): ud.String(),
+		host + "/mirror/tools/lint:latest":  id.String(),
+	} {
+		r, err := name.ParseReference(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc, err := remote.Head(r, ropt, rauth)
+		if err != nil {
+			t.Errorf("Head(%s) = %v", ref, err)
+			continue
+		}
+		if got := desc.Digest.String(); got != want {
+			t.Errorf("Head(%s) = %s, want %s", ref, got, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Option is a functional option for the ECR helpers.
+type Option func(*options)
+
+type options struct {
+	cfg     *aws.Config
+	roles   map[string]string
+	assumed map[string]aws.CredentialsProvider
+	client  []func(*ecr.Options)
+	ctx     context.Context
+	jobs    int
+	remote  []remote.Option
+}
+
+func makeOptions(opts ...Option) *options {
+	o := &options{
+		assumed: map[string]aws.CredentialsProvider{},
+		ctx:     context.Background(),
+		jobs:    runtime.GOMAXPROCS(0),
+	}
+	for _, option := range opts {
+		option(o)
+	}
+	return o
+}
+
+// config returns the AWS configuration, loading the default one the first
+// time it's needed.
+func (o *options) config() (aws.Config, error) {
+	if o.cfg == nil {
+		cfg, err := config.LoadDefaultConfig(o.ctx)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("loading AWS configuration: %w", err)
+		}
+		o.cfg = &cfg
+	}
+	return *o.cfg, nil
+}
+
+// WithConfig sets the AWS configuration used to make ECR API requests, e.g.
+// one loaded with config.LoadDefaultConfig and a shared config profile. By
+// default, config.LoadDefaultConfig is used. The region is always taken
+// from the registry's hostname.
+func WithConfig(cfg aws.Config) Option {
+	return func(o *options) {
+		o.cfg = &cfg
+	}
+}
+
+// WithAccountRole assumes roleARN, with stscreds, to make ECR API requests
+// about registries in account, e.g. to list a registry in another account
+// whose policy trusts the role rather than the caller. It can be passed once
+// for each account.
+func WithAccountRole(account, roleARN string) Option {
+	return func(o *options) {
+		if o.roles == nil {
+			o.roles = map[string]string{}
+		}
+		o.roles[account] = roleARN
+	}
+}
+
+// WithClientOptions customizes the ECR API clients.
+func WithClientOptions(fns ...func(*ecr.Options)) Option {
+	return func(o *options) {
+		o.client = append(o.client, fns...)
+	}
+}
+
+// WithTransport overrides the transport used for ECR API and registry
+// requests.
+func WithTransport(t http.RoundTripper) Option {
+	return func(o *options) {
+		o.client = append(o.client, func(eo *ecr.Options) {
+			eo.HTTPClient = &http.Client{Transport: t}
+		})
+		o.remote = append(o.remote, remote.WithTransport(t))
+	}
+}
+
+// WithContext sets the context for ECR API and registry requests.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+		o.remote = append(o.remote, remote.WithContext(ctx))
+	}
+}
+
+// WithEndpoint overrides the ECR API endpoint, e.g. for VPC endpoints. By
+// default, it's derived from the registry's region.
+func WithEndpoint(endpoint string) Option {
+	return WithClientOptions(func(eo *ecr.Options) {
+		eo.EndpointResolver = ecr.EndpointResolverFromURL(endpoint)
+	})
+}
+
+// WithUserAgent adds the given string to the User-Agent header for any HTTP
+// requests.
+func WithUserAgent(ua string) Option {
+	return func(o *options) {
+		o.client = append(o.client, func(eo *ecr.Options) {
+			eo.APIOptions = append(eo.APIOptions, awsmiddleware.AddUserAgentKey(ua))
+		})
+		o.remote = append(o.remote, remote.WithUserAgent(ua))
+	}
+}
+
+// WithJobs sets the number of concurrent copies for CopyRegistry.
+//
+// The default number of jobs is GOMAXPROCS.
+func WithJobs(jobs int) Option {
+	return func(o *options) {
+		o.jobs = jobs
+	}
+}
+
+// WithRemoteOptions passes extra options to the remote package when copying,
+// e.g. to authenticate to a destination that isn't in ECR.
+func WithRemoteOptions(opts ...remote.Option) Option {
+	return func(o *options) {
+		o.remote = append(o.remote, opts...)
+	}
+}
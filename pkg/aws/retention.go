@@ -0,0 +1,38 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"sort"
+
+	"github.com/google/go-containerregistry/pkg/retention"
+)
+
+// Retention returns the images in a listing from List or Walk, for
+// evaluating retention policies. ECR listings don't include media types or
+// upload times.
+func Retention(images []Image) []retention.Image {
+	out := make([]retention.Image, 0, len(images))
+	for _, img := range images {
+		out = append(out, retention.Image{
+			Digest: img.Digest,
+			Tags:   img.Tags,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Digest < out[j].Digest
+	})
+	return out
+}
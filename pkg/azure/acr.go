@@ -0,0 +1,225 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// acrHost matches ACR login servers, e.g. myregistry.azurecr.io.
+var acrHost = regexp.MustCompile(`^[a-z0-9]+\.azurecr\.(io|cn|us)$`)
+
+// client returns an HTTP client for reg's ACR API, authorized for scopes.
+func (o *options) client(reg name.Registry, scopes ...string) (*http.Client, error) {
+	auth, err := authn.NewMultiKeychain(o.keychain, authn.DefaultKeychain).Resolve(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := o.transport
+	// transport.Wrapper is a signal that consumers are opt-ing into providing their own transport without any additional wrapping.
+	if _, ok := tr.(*transport.Wrapper); !ok {
+		if logs.Enabled(logs.Debug) {
+			tr = transport.NewLogger(tr)
+		}
+		tr = transport.NewRetry(tr)
+		tr = transport.NewUserAgent(tr, o.userAgent)
+	}
+
+	tr, err = transport.NewWithContext(o.ctx, reg, auth, tr, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: tr}, nil
+}
+
+// get calls fn with the body of every page of the ACR API response for u.
+func (o *options) get(c *http.Client, u *url.URL, fn func(io.Reader) error) error {
+	for u != nil {
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.Do(req.WithContext(o.ctx))
+		if err != nil {
+			return err
+		}
+		if err := transport.CheckError(resp, http.StatusOK); err != nil {
+			resp.Body.Close()
+			return err
+		}
+		err = fn(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if u, err = nextPage(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextPage returns the URL of the next page from resp's Link header, or nil
+// if this was the last page.
+func nextPage(resp *http.Response) (*url.URL, error) {
+	link := resp.Header.Get("Link")
+	if link == "" {
+		return nil, nil
+	}
+
+	if link[0] != '<' {
+		return nil, fmt.Errorf("failed to parse link header: missing '<' in: %s", link)
+	}
+
+	end := strings.Index(link, ">")
+	if end == -1 {
+		return nil, fmt.Errorf("failed to parse link header: missing '>' in: %s", link)
+	}
+
+	linkURL, err := url.Parse(link[1:end])
+	if err != nil {
+		return nil, err
+	}
+	return resp.Request.URL.ResolveReference(linkURL), nil
+}
+
+// Repositories uses the ACR API to list the repositories in reg, e.g.
+// "myregistry.azurecr.io".
+func Repositories(reg name.Registry, opts ...Option) ([]name.Repository, error) {
+	return makeOptions(opts...).repositories(reg)
+}
+
+func (o *options) repositories(reg name.Registry) ([]name.Repository, error) {
+	c, err := o.client(reg, "registry:catalog:*")
+	if err != nil {
+		return nil, err
+	}
+	u := &url.URL{
+		Scheme:   reg.Scheme(),
+		Host:     reg.RegistryStr(),
+		Path:     "/acr/v1/_catalog",
+		RawQuery: "n=1000",
+	}
+
+	var repos []name.Repository
+	if err := o.get(c, u, func(r io.Reader) error {
+		var out struct {
+			Repositories []string `json:"repositories"`
+		}
+		if err := json.NewDecoder(r).Decode(&out); err != nil {
+			return err
+		}
+		for _, repo := range out.Repositories {
+			nr, err := name.NewRepository(fmt.Sprintf("%s/%s", reg.RegistryStr(), repo), name.StrictValidation)
+			if err != nil {
+				return err
+			}
+			repos = append(repos, nr)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// Manifest is a manifest in an ACR repository, as listed by the ACR API.
+type Manifest struct {
+	Digest    string          `json:"digest"`
+	Tags      []string        `json:"tags"`
+	MediaType types.MediaType `json:"mediaType"`
+	Size      uint64          `json:"imageSize"`
+
+	// Created is when the manifest was pushed, and LastUpdated when it was
+	// last tagged or otherwise changed.
+	Created     time.Time `json:"createdTime"`
+	LastUpdated time.Time `json:"lastUpdateTime"`
+
+	// Architecture and OS are only set for images.
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// Manifests uses the ACR API to list the manifests in repo, with their tags.
+func Manifests(repo name.Repository, opts ...Option) ([]Manifest, error) {
+	return makeOptions(opts...).manifests(repo)
+}
+
+func (o *options) manifests(repo name.Repository) ([]Manifest, error) {
+	c, err := o.client(repo.Registry, repo.Scope("metadata_read"))
+	if err != nil {
+		return nil, err
+	}
+	u := &url.URL{
+		Scheme:   repo.Registry.Scheme(),
+		Host:     repo.RegistryStr(),
+		Path:     fmt.Sprintf("/acr/v1/%s/_manifests", repo.RepositoryStr()),
+		RawQuery: "n=1000",
+	}
+
+	var manifests []Manifest
+	if err := o.get(c, u, func(r io.Reader) error {
+		var out struct {
+			Manifests []Manifest `json:"manifests"`
+		}
+		if err := json.NewDecoder(r).Decode(&out); err != nil {
+			return err
+		}
+		manifests = append(manifests, out.Manifests...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// WalkFunc is called by Walk with the manifests in each repository, or the
+// error listing them.
+type WalkFunc func(repo name.Repository, manifests []Manifest, err error) error
+
+// Walk calls walkFn with the result of Manifests for each repository in reg.
+// ACR repositories aren't nested, so this just lists them. If walkFn returns
+// an error, Walk stops and returns it.
+func Walk(reg name.Registry, walkFn WalkFunc, opts ...Option) error {
+	return makeOptions(opts...).walk(reg, walkFn)
+}
+
+func (o *options) walk(reg name.Registry, walkFn WalkFunc) error {
+	repos, err := o.repositories(reg)
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		manifests, err := o.manifests(repo)
+		if err := walkFn(repo, manifests, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
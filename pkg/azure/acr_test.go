@@ -0,0 +1,393 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	ggcrtest "github.com/google/go-containerregistry/internal/httptest"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+const host = "myregistry.azurecr.io"
+
+// refreshToken is a JWT that expires in 2100.
+var refreshToken = "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"exp":4102444800}`)) + ".sig"
+
+// fakeACR serves AAD, the ACR token endpoints, the ACR API and a registry
+// that requires the tokens it hands out.
+type fakeACR struct {
+	t         *testing.T
+	registry  http.Handler
+	transport http.RoundTripper
+	manifests map[string][]Manifest
+	exchanges int
+}
+
+func (f *fakeACR) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/tenant/oauth2/v2.0/token":
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "aad"})
+		return
+	case r.URL.Path == "/metadata/identity/oauth2/token":
+		if r.Header.Get("Metadata") != "true" || r.FormValue("resource") != "https://containerregistry.azure.net" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if id := r.FormValue("client_id"); id != "" && id != "client" {
+			// Like IMDS, when no such identity is assigned.
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "aad"})
+		return
+	case r.URL.Path == "/msi/token":
+		if r.Header.Get("X-IDENTITY-HEADER") != "secret" || r.FormValue("resource") != "https://containerregistry.azure.net" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "aad"})
+		return
+	case r.URL.Path == "/oauth2/exchange":
+		f.exchanges++
+		if r.FormValue("grant_type") != "access_token" || r.FormValue("access_token") != "aad" || r.FormValue("service") != host {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"refresh_token": refreshToken})
+		return
+	case r.URL.Path == "/oauth2/token":
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != refreshToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "acr"})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer acr" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="https://%s/oauth2/token",service="%s"`, host, host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/v2/") {
+		f.registry.ServeHTTP(w, r)
+		return
+	}
+
+	if r.URL.Path == "/acr/v1/_catalog" {
+		// Return one repository per page.
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</acr/v1/_catalog?last=app&n=1000>; rel="next"`)
+			json.NewEncoder(w).Encode(map[string][]string{"repositories": {"app"}})
+		} else {
+			json.NewEncoder(w).Encode(map[string][]string{"repositories": {"tools/lint"}})
+		}
+		return
+	}
+	repo := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/acr/v1/"), "/_manifests")
+	manifests, ok := f.manifests[repo]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errors": []map[string]string{{"code": "NAME_UNKNOWN", "message": "repository not found"}},
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"manifests": manifests})
+}
+
+func setup(t *testing.T) (*fakeACR, []Option, remote.Option) {
+	t.Helper()
+	f := &fakeACR{
+		t:         t,
+		registry:  ggcrregistry.New(ggcrregistry.Logger(log.New(ioutil.Discard, "", 0))),
+		manifests: map[string][]Manifest{},
+	}
+	s, err := ggcrtest.NewTLSServer(host, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	f.transport = s.Client().Transport
+
+	opts := []Option{
+		WithTransport(s.Client().Transport),
+		WithAuthority("https://" + host),
+		WithCredentials(Credentials{TenantID: "tenant", ClientID: "client", ClientSecret: "secret"}),
+	}
+	return f, opts, remote.WithTransport(s.Client().Transport)
+}
+
+func TestList(t *testing.T) {
+	f, opts, _ := setup(t)
+	created := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	f.manifests["app"] = []Manifest{{
+		Digest:       "sha256:a",
+		Tags:         []string{"v1", "latest"},
+		MediaType:    "application/vnd.oci.image.manifest.v1+json",
+		Size:         1234,
+		Created:      created,
+		LastUpdated:  created.Add(time.Hour),
+		Architecture: "amd64",
+		OS:           "linux",
+	}, {
+		Digest:  "sha256:b",
+		Created: created.Add(-time.Hour),
+	}}
+	f.manifests["tools/lint"] = []Manifest{}
+
+	repos, err := Repositories(name.MustParseReference(host+"/foo").Context().Registry, opts...)
+	if err != nil {
+		t.Fatalf("Repositories() = %v", err)
+	}
+	var got []string
+	for _, r := range repos {
+		got = append(got, r.String())
+	}
+	if diff := cmp.Diff([]string{host + "/app", host + "/tools/lint"}, got); diff != "" {
+		t.Errorf("Repositories() (-want +got) = %s", diff)
+	}
+
+	manifests, err := Manifests(repos[0], opts...)
+	if err != nil {
+		t.Fatalf("Manifests() = %v", err)
+	}
+	if diff := cmp.Diff(f.manifests["app"], manifests); diff != "" {
+		t.Errorf("Manifests() (-want +got) = %s", diff)
+	}
+
+	_, err = Manifests(name.MustParseReference(host+"/missing").Context(), opts...)
+	var terr *transport.Error
+	if !errors.As(err, &terr) || terr.StatusCode != http.StatusNotFound {
+		t.Errorf("Manifests() = %v, want 404", err)
+	}
+
+	f.exchanges = 0
+	var walked []string
+	if err := Walk(repos[0].Registry, func(repo name.Repository, manifests []Manifest, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, fmt.Sprintf("%s:%d", repo.RepositoryStr(), len(manifests)))
+		return nil
+	}, opts...); err != nil {
+		t.Fatalf("Walk() = %v", err)
+	}
+	if diff := cmp.Diff([]string{"app:2", "tools/lint:0"}, walked); diff != "" {
+		t.Errorf("Walk() (-want +got) = %s", diff)
+	}
+	if f.exchanges != 1 {
+		t.Errorf("Walk() exchanged %d tokens, want 1", f.exchanges)
+	}
+}
+
+func TestKeychain(t *testing.T) {
+	f, opts, _ := setup(t)
+	kc := Keychain(opts...)
+
+	reg := name.MustParseReference(host + "/foo").Context().Registry
+	for i := 0; i < 2; i++ {
+		auth, err := kc.Resolve(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := auth.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Username != nullUser || cfg.IdentityToken != refreshToken {
+			t.Errorf("Authorization() = %+v", cfg)
+		}
+		e, ok := auth.(authn.Expirer)
+		if !ok {
+			t.Fatalf("Resolve() = %T, want an authn.Expirer", auth)
+		}
+		if got, want := e.Expiry(), time.Unix(4102444800, 0); !got.Equal(want) {
+			t.Errorf("Expiry() = %v, want %v", got, want)
+		}
+	}
+	if f.exchanges != 1 {
+		t.Errorf("got %d exchanges, want 1", f.exchanges)
+	}
+
+	auth, err := kc.Resolve(name.MustParseReference("gcr.io/foo").Context().Registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != authn.Anonymous {
+		t.Errorf("Resolve(gcr.io) = %v, want Anonymous", auth)
+	}
+
+	// Without credentials, fall through to other keychains.
+	clearEnv(t)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	t.Setenv("AZURE_POD_IDENTITY_AUTHORITY_HOST", closed.URL)
+	auth, err = Keychain().Resolve(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != authn.Anonymous {
+		t.Errorf("Resolve() without credentials = %v, want Anonymous", auth)
+	}
+}
+
+// clearEnv unsets the environment variables that configure credentials.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range []string{"AZURE_ACCESS_TOKEN", "AZURE_TENANT_ID", "AZURE_CLIENT_ID", "AZURE_CLIENT_SECRET", "IDENTITY_ENDPOINT", "IDENTITY_HEADER"} {
+		t.Setenv(env, "")
+	}
+}
+
+func TestKeychainManagedIdentity(t *testing.T) {
+	f, _, _ := setup(t)
+	reg := name.MustParseReference(host + "/foo").Context().Registry
+
+	for _, tc := range []struct {
+		name string
+		env  map[string]string
+		want authn.Authenticator
+	}{{
+		name: "instance metadata",
+		env:  map[string]string{"AZURE_POD_IDENTITY_AUTHORITY_HOST": "https://" + host},
+	}, {
+		name: "user-assigned",
+		env:  map[string]string{"AZURE_POD_IDENTITY_AUTHORITY_HOST": "https://" + host, "AZURE_CLIENT_ID": "client"},
+	}, {
+		name: "no such identity",
+		env:  map[string]string{"AZURE_POD_IDENTITY_AUTHORITY_HOST": "https://" + host, "AZURE_CLIENT_ID": "other"},
+		want: authn.Anonymous,
+	}, {
+		name: "app service",
+		env:  map[string]string{"IDENTITY_ENDPOINT": "https://" + host + "/msi/token", "IDENTITY_HEADER": "secret"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			auth, err := Keychain(WithTransport(f.transport)).Resolve(reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want != nil {
+				if auth != tc.want {
+					t.Errorf("Resolve() = %v, want %v", auth, tc.want)
+				}
+				return
+			}
+			cfg, err := auth.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Username != nullUser || cfg.IdentityToken != refreshToken {
+				t.Errorf("Authorization() = %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestCopyRegistry(t *testing.T) {
+	f, opts, ropt := setup(t)
+	rauth := remote.WithAuth(authn.FromConfig(authn.AuthConfig{Username: nullUser, IdentityToken: refreshToken}))
+
+	tagged, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untagged, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, _ := tagged.Digest()
+	ud, _ := untagged.Digest()
+	id, _ := idx.Digest()
+
+	app := name.MustParseReference(host + "/app").Context()
+	lint := name.MustParseReference(host + "/tools/lint").Context()
+	if err := remote.Write(app.Tag("v1"), tagged, ropt, rauth); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(app.Digest(ud.String()), untagged, ropt, rauth); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(lint.Tag("latest"), idx, ropt, rauth); err != nil {
+		t.Fatal(err)
+	}
+	f.manifests["app"] = []Manifest{{Digest: td.String(), Tags: []string{"v1"}}, {Digest: ud.String()}}
+	f.manifests["tools/lint"] = []Manifest{{Digest: id.String(), Tags: []string{"latest"}}}
+
+	// Copying again should be a no-op.
+	for i := 0; i < 2; i++ {
+		if err := CopyRegistry(context.Background(), host, host+"/mirror", append(opts, WithJobs(2))...); err != nil {
+			t.Fatalf("CopyRegistry() = %v", err)
+		}
+	}
+
+	for ref, want := range map[string]string{
+		host + "/mirror/app:v1":             td.String(),
+		host + "/mirror/app@" + ud.String(): ud.String(),
+		host + "/mirror/tools/lint:latest":  id.String(),
+	} {
+		r, err := name.ParseReference(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc, err := remote.Head(r, ropt, rauth)
+		if err != nil {
+			t.Errorf("Head(%s) = %v", ref, err)
+			continue
+		}
+		if got := desc.Digest.String(); got != want {
+			t.Errorf("Head(%s) = %s, want %s", ref, got, want)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	if got, want := expiry(refreshToken), time.Unix(4102444800, 0); !got.Equal(want) {
+		t.Errorf("expiry() = %v, want %v", got, want)
+	}
+	if got := expiry("not-a-jwt"); time.Until(got) <= 0 || time.Until(got) > time.Hour {
+		t.Errorf("expiry(not-a-jwt) = %v, want within the hour", got)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/go-containerregistry/internal/mirror"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// CopyRegistry copies every image in every repository of the ACR registry
+// src to dst, keeping repository names and tags, e.g. copying
+// "myregistry.azurecr.io/app" to "gcr.io/mirror/app".
+// Images and tags that already exist in dst are skipped.
+// Registries are accessed with Keychain, falling back to authn.DefaultKeychain;
+// use WithRemoteOptions to authenticate differently.
+func CopyRegistry(ctx context.Context, src, dst string, opts ...Option) error {
+	o := makeOptions(opts...)
+	reg, err := name.NewRegistry(src)
+	if err != nil {
+		return fmt.Errorf("parsing registry %q: %w", src, err)
+	}
+
+	ropts := append([]remote.Option{
+		remote.WithAuthFromKeychain(authn.NewMultiKeychain(o.keychain, authn.DefaultKeychain)),
+		remote.WithContext(ctx),
+	}, o.remote...)
+
+	list := func(ctx context.Context, yield func(name.Repository, []mirror.Image) error) error {
+		o.ctx = ctx
+		return o.walk(reg, func(repo name.Repository, manifests []Manifest, err error) error {
+			if err != nil {
+				return fmt.Errorf("listing %s: %w", repo, err)
+			}
+			return yield(repo, copies(manifests))
+		})
+	}
+	return mirror.Registry(ctx, dst, o.jobs, list, ropts...)
+}
+
+// copies returns the images to copy for manifests, in digest order.
+func copies(manifests []Manifest) []mirror.Image {
+	images := make([]mirror.Image, 0, len(manifests))
+	for _, m := range manifests {
+		images = append(images, mirror.Image{Digest: m.Digest, Tags: m.Tags})
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Digest < images[j].Digest })
+	return images
+}
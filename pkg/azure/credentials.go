@@ -0,0 +1,208 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Credentials identify the AAD principal used to authenticate to ACR.
+type Credentials struct {
+	// TenantID is the AAD tenant (directory) of the principal.
+	TenantID string
+
+	// ClientID and ClientSecret identify a service principal, which is
+	// exchanged for an AAD access token using the client credentials flow.
+	ClientID     string
+	ClientSecret string
+
+	// ManagedIdentity gets AAD access tokens for the managed identity of the
+	// VM, AKS node, App Service or Container App we're running on. ClientID,
+	// if set, selects a user-assigned identity.
+	ManagedIdentity bool
+
+	// AccessToken is an AAD access token, e.g. from
+	// "az account get-access-token". If set, it's used as is.
+	AccessToken string
+}
+
+// ErrNoCredentials is returned when no credentials can be found.
+var ErrNoCredentials = errors.New("no Azure credentials found")
+
+// CredentialsFromEnv returns credentials from the AZURE_ACCESS_TOKEN
+// environment variable or, failing that, a service principal from the
+// AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET environment
+// variables.
+func CredentialsFromEnv() (Credentials, error) {
+	creds := Credentials{
+		TenantID:     os.Getenv("AZURE_TENANT_ID"),
+		ClientID:     os.Getenv("AZURE_CLIENT_ID"),
+		ClientSecret: os.Getenv("AZURE_CLIENT_SECRET"),
+		AccessToken:  os.Getenv("AZURE_ACCESS_TOKEN"),
+	}
+	if creds.AccessToken == "" && (creds.TenantID == "" || creds.ClientID == "" || creds.ClientSecret == "") {
+		return Credentials{}, ErrNoCredentials
+	}
+	return creds, nil
+}
+
+// ManagedIdentityCredentials returns credentials for the managed identity
+// we're running as, the user-assigned one identified by AZURE_CLIENT_ID if
+// that's set.
+func ManagedIdentityCredentials() Credentials {
+	return Credentials{
+		ManagedIdentity: true,
+		ClientID:        os.Getenv("AZURE_CLIENT_ID"),
+	}
+}
+
+// aadScope requests an AAD token that ACR will exchange.
+const aadScope = "https://containerregistry.azure.net/.default"
+
+// accessToken returns an AAD access token for creds.
+func (o *options) accessToken(creds Credentials) (string, error) {
+	if creds.AccessToken != "" {
+		return creds.AccessToken, nil
+	}
+	if creds.ManagedIdentity {
+		return o.managedIdentityToken(creds.ClientID)
+	}
+
+	u := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(o.authority, "/"), url.PathEscape(creds.TenantID))
+	var out struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := o.postForm(u, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {creds.ClientID},
+		"client_secret": {creds.ClientSecret},
+		"scope":         {aadScope},
+	}, &out); err != nil {
+		return "", fmt.Errorf("getting AAD token: %w", err)
+	}
+	if out.AccessToken == "" {
+		return "", errors.New("getting AAD token: no access_token in response")
+	}
+	return out.AccessToken, nil
+}
+
+// imdsHost is the address of the Azure instance metadata service, unless
+// AZURE_POD_IDENTITY_AUTHORITY_HOST overrides it.
+const imdsHost = "http://169.254.169.254"
+
+// imdsTimeout bounds how long we wait for the instance metadata service,
+// which doesn't exist outside of Azure.
+const imdsTimeout = 2 * time.Second
+
+// managedIdentityToken returns an AAD access token for the managed identity
+// we're running as, from the App Service identity endpoint if there is one,
+// or else the instance metadata service. If neither has an identity for us,
+// the error wraps ErrNoCredentials.
+func (o *options) managedIdentityToken(clientID string) (string, error) {
+	resource := strings.TrimSuffix(aadScope, "/.default")
+	v := url.Values{"resource": {resource}}
+	if clientID != "" {
+		v.Set("client_id", clientID)
+	}
+
+	var req *http.Request
+	if endpoint, secret := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); endpoint != "" && secret != "" {
+		// App Service, Container Apps and Azure Functions.
+		v.Set("api-version", "2019-08-01")
+		r, err := http.NewRequest(http.MethodGet, endpoint+"?"+v.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		r.Header.Set("X-IDENTITY-HEADER", secret)
+		req = r
+	} else {
+		host := os.Getenv("AZURE_POD_IDENTITY_AUTHORITY_HOST")
+		if host == "" {
+			host = imdsHost
+		}
+		v.Set("api-version", "2018-02-01")
+		r, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(host, "/")+"/metadata/identity/oauth2/token?"+v.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		r.Header.Set("Metadata", "true")
+		req = r
+	}
+	if o.userAgent != "" {
+		req.Header.Set("User-Agent", o.userAgent)
+	}
+
+	ctx, cancel := context.WithTimeout(o.ctx, imdsTimeout)
+	defer cancel()
+	resp, err := (&http.Client{Transport: o.transport}).Do(req.WithContext(ctx))
+	if err != nil {
+		// We're probably not on Azure.
+		return "", fmt.Errorf("%w: managed identity: %v", ErrNoCredentials, err)
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := decode(resp, &out); err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && (terr.StatusCode == http.StatusBadRequest || terr.StatusCode == http.StatusNotFound) {
+			// There's no identity assigned to us.
+			return "", fmt.Errorf("%w: managed identity: %v", ErrNoCredentials, err)
+		}
+		return "", fmt.Errorf("getting managed identity token: %w", err)
+	}
+	if out.AccessToken == "" {
+		return "", errors.New("getting managed identity token: no access_token in response")
+	}
+	return out.AccessToken, nil
+}
+
+// postForm posts v to u, decoding the JSON response into out.
+func (o *options) postForm(u string, v url.Values, out interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(o.ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if o.userAgent != "" {
+		req.Header.Set("User-Agent", o.userAgent)
+	}
+
+	resp, err := (&http.Client{Transport: o.transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	return decode(resp, out)
+}
+
+// decode decodes the JSON body of a successful response into out.
+func decode(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
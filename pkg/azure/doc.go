@@ -0,0 +1,21 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azure holds helpers for listing, copying and authenticating to
+// Azure Container Registry (ACR) using the ACR REST API, analogous to gcrane
+// for GCR.
+// Registries are authenticated to by exchanging an Azure Active Directory
+// (AAD) access token for an ACR refresh token, so it doesn't depend on the
+// Azure SDK.
+package azure
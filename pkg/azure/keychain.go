@@ -0,0 +1,190 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// nullUser is the username ACR expects alongside refresh tokens.
+const nullUser = "00000000-0000-0000-0000-000000000000"
+
+// tokenRefreshSkew is how long before an ACR refresh token expires that we
+// get a new one.
+const tokenRefreshSkew = 5 * time.Minute
+
+// Keychain returns an authn.Keychain that authenticates to ACR registries by
+// exchanging an AAD access token for an ACR refresh token, which is reused
+// until shortly before it expires. Other registries, or any registry when no
+// credentials are found, resolve to authn.Anonymous, so it can be combined
+// with other keychains using authn.NewMultiKeychain.
+//
+// Without WithCredentials, it uses CredentialsFromEnv or, failing that, the
+// managed identity we're running as (see ManagedIdentityCredentials). The
+// Authenticators it returns exchange a new token when theirs is about to
+// expire, so long pushes and pulls keep working.
+func Keychain(opts ...Option) authn.Keychain {
+	return makeOptions(opts...).keychain
+}
+
+type keychain struct {
+	o *options
+
+	mu     sync.Mutex
+	tokens map[string]token
+}
+
+type token struct {
+	auth    authn.AuthConfig
+	expires time.Time
+}
+
+// Resolve implements authn.Keychain.
+func (k *keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	host := strings.ToLower(target.RegistryStr())
+	if !acrHost.MatchString(host) {
+		return authn.Anonymous, nil
+	}
+
+	// Get a token now, so that errors surface here, rather than as an
+	// authentication failure.
+	a := &authenticator{k: k, host: host}
+	if _, err := a.Authorization(); errors.Is(err, ErrNoCredentials) {
+		return authn.Anonymous, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// credentials returns the configured credentials or else finds some.
+func (k *keychain) credentials() Credentials {
+	if k.o.creds != nil {
+		return *k.o.creds
+	}
+	if c, err := CredentialsFromEnv(); err == nil {
+		return c
+	}
+	return ManagedIdentityCredentials()
+}
+
+// token returns a token for the registry host, reusing the last one until
+// it's about to expire.
+func (k *keychain) token(host string) (token, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if t, ok := k.tokens[host]; ok && time.Until(t.expires) > tokenRefreshSkew {
+		return t, nil
+	}
+
+	creds := k.credentials()
+	aad, err := k.o.accessToken(creds)
+	if err != nil {
+		return token{}, err
+	}
+
+	v := url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {host},
+		"access_token": {aad},
+	}
+	if creds.TenantID != "" {
+		v.Set("tenant", creds.TenantID)
+	}
+	var out struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := k.o.postForm(fmt.Sprintf("https://%s/oauth2/exchange", host), v, &out); err != nil {
+		return token{}, fmt.Errorf("exchanging AAD token for %s: %w", host, err)
+	}
+	if out.RefreshToken == "" {
+		return token{}, fmt.Errorf("exchanging AAD token for %s: no refresh_token in response", host)
+	}
+
+	t := token{
+		auth: authn.AuthConfig{
+			Username:      nullUser,
+			IdentityToken: out.RefreshToken,
+		},
+		expires: expiry(out.RefreshToken),
+	}
+	k.tokens[host] = t
+	return t, nil
+}
+
+// authenticator authenticates to one ACR registry with the keychain's
+// tokens for it.
+type authenticator struct {
+	k    *keychain
+	host string
+
+	mu      sync.Mutex
+	expires time.Time
+}
+
+var (
+	_ authn.Authenticator = (*authenticator)(nil)
+	_ authn.Expirer       = (*authenticator)(nil)
+)
+
+// Authorization implements authn.Authenticator.
+func (a *authenticator) Authorization() (*authn.AuthConfig, error) {
+	t, err := a.k.token(a.host)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	a.expires = t.expires
+	a.mu.Unlock()
+	auth := t.auth
+	return &auth, nil
+}
+
+// Expiry implements authn.Expirer.
+func (a *authenticator) Expiry() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.expires
+}
+
+// expiry returns when the given ACR refresh token, a JWT, expires. If that
+// can't be determined, it's assumed to last an hour; ACR's last three.
+func expiry(jwt string) time.Time {
+	fallback := time.Now().Add(time.Hour)
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fallback
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(b, &claims); err != nil || claims.Exp == 0 {
+		return fallback
+	}
+	return time.Unix(claims.Exp, 0)
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Option is a functional option for the ACR helpers.
+type Option func(*options)
+
+type options struct {
+	creds     *Credentials
+	authority string
+	transport http.RoundTripper
+	ctx       context.Context
+	userAgent string
+	jobs      int
+	remote    []remote.Option
+
+	// keychain is shared by everything using these options, so tokens are
+	// only exchanged once per registry.
+	keychain *keychain
+}
+
+func makeOptions(opts ...Option) *options {
+	o := &options{
+		authority: "https://login.microsoftonline.com",
+		transport: http.DefaultTransport,
+		ctx:       context.Background(),
+		jobs:      runtime.GOMAXPROCS(0),
+	}
+	for _, option := range opts {
+		option(o)
+	}
+	o.keychain = &keychain{o: o, tokens: map[string]token{}}
+	return o
+}
+
+// WithCredentials sets the credentials used to obtain AAD access tokens. By
+// default, CredentialsFromEnv is used or, failing that, the managed identity
+// we're running as.
+func WithCredentials(creds Credentials) Option {
+	return func(o *options) {
+		o.creds = &creds
+	}
+}
+
+// WithAuthority overrides the AAD endpoint used for the client credentials
+// flow, e.g. for sovereign clouds. The default is
+// "https://login.microsoftonline.com".
+func WithAuthority(authority string) Option {
+	return func(o *options) {
+		o.authority = authority
+	}
+}
+
+// WithTransport overrides the transport used for AAD, ACR API and registry
+// requests.
+func WithTransport(t http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = t
+		o.remote = append(o.remote, remote.WithTransport(t))
+	}
+}
+
+// WithContext sets the context for AAD, ACR API and registry requests.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+		o.remote = append(o.remote, remote.WithContext(ctx))
+	}
+}
+
+// WithUserAgent adds the given string to the User-Agent header for any HTTP
+// requests.
+func WithUserAgent(ua string) Option {
+	return func(o *options) {
+		o.userAgent = ua
+		o.remote = append(o.remote, remote.WithUserAgent(ua))
+	}
+}
+
+// WithJobs sets the number of concurrent copies for CopyRegistry.
+// The default number of jobs is GOMAXPROCS.
+func WithJobs(jobs int) Option {
+	return func(o *options) {
+		o.jobs = jobs
+	}
+}
+
+// WithRemoteOptions passes extra options to the remote package when copying,
+// e.g. to authenticate to a destination that isn't in ACR.
+func WithRemoteOptions(opts ...remote.Option) Option {
+	return func(o *options) {
+		o.remote = append(o.remote, opts...)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package concurrency provides a budget of concurrent work that is shared by
+// the parallel operations in this module, so that embedding applications can
+// cap their total parallelism, rather than each call's.
+//
+// A budget bounds the units of work that don't themselves fan out: uploading
+// or downloading a blob, or compressing a layer. Operations that fan out,
+// like remote.WithJobs or gcrane.WithJobs, still decide how many workers to
+// start, but the workers of every operation sharing a budget take turns.
+//
+// A unit of work may need another to finish, e.g. uploading a blob that is
+// itself being downloaded. Hold and Do pass the slot down in a context, and
+// nested calls that are given that context reuse the slot rather than waiting
+// for another, so they can't deadlock. Code that holds a slot must not call
+// anything that draws on the same budget without that context, such as the
+// methods of a v1.Layer, which don't take one.
+package concurrency
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+)
+
+// EnvVar is the environment variable that sets the limit of the default
+// budget, if SetDefault isn't called.
+const EnvVar = "GGCR_MAX_CONCURRENCY"
+
+// Budget limits how many units of work run at once. A nil *Budget is
+// unlimited.
+type Budget struct {
+	sem chan struct{}
+}
+
+// New returns a budget that allows n units of work at once. If n is not
+// positive, it returns nil, which is unlimited.
+func New(n int) *Budget {
+	if n <= 0 {
+		return nil
+	}
+	return &Budget{sem: make(chan struct{}, n)}
+}
+
+// Limit returns how many units of work b allows at once, or 0 if it's
+// unlimited.
+func (b *Budget) Limit() int {
+	if b == nil {
+		return 0
+	}
+	return cap(b.sem)
+}
+
+// Acquire blocks until b allows another unit of work, or ctx is done. Each
+// successful call must be matched by a call to Release.
+//
+// Unlike Hold, Acquire always takes a new slot, even if ctx carries one.
+func (b *Budget) Acquire(ctx context.Context) error {
+	if b == nil {
+		return ctx.Err()
+	}
+	select {
+	case b.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release ends a unit of work started by Acquire.
+func (b *Budget) Release() {
+	if b == nil {
+		return
+	}
+	<-b.sem
+}
+
+// heldKey is the context key under which Hold records a slot of b.
+type heldKey struct{ b *Budget }
+
+// Hold blocks until b allows another unit of work, or ctx is done, like
+// Acquire, and returns a context that carries the slot and a func that
+// releases it. If ctx already carries a slot of b, Hold reuses it: it returns
+// ctx as is and release does nothing.
+//
+// Calling release more than once is safe.
+func (b *Budget) Hold(ctx context.Context) (context.Context, func(), error) {
+	if b == nil || ctx.Value(heldKey{b}) != nil {
+		return ctx, func() {}, ctx.Err()
+	}
+	if err := b.Acquire(ctx); err != nil {
+		return nil, nil, err
+	}
+	var once sync.Once
+	release := func() { once.Do(b.Release) }
+	return context.WithValue(ctx, heldKey{b}, true), release, nil
+}
+
+// Do calls f as a unit of work of b, with a context that carries its slot.
+// See Hold.
+func (b *Budget) Do(ctx context.Context, f func(context.Context) error) error {
+	ctx, release, err := b.Hold(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return f(ctx)
+}
+
+var (
+	mu            sync.Mutex
+	defaultBudget *Budget
+	defaultSet    bool
+)
+
+// Default returns the budget used by operations that aren't given one. It's
+// unlimited unless SetDefault is called or EnvVar is set to a positive
+// integer.
+func Default() *Budget {
+	mu.Lock()
+	defer mu.Unlock()
+	if !defaultSet {
+		defaultSet = true
+		if s := os.Getenv(EnvVar); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				logs.Warn.Printf("ignoring %s=%q: %v", EnvVar, s, err)
+			}
+			defaultBudget = New(n)
+		}
+	}
+	return defaultBudget
+}
+
+// SetDefault sets the budget used by operations that aren't given one,
+// overriding EnvVar. A nil budget is unlimited.
+func SetDefault(b *Budget) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultBudget, defaultSet = b, true
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	b := New(2)
+	if got := b.Limit(); got != 2 {
+		t.Errorf("Limit() = %d, want 2", got)
+	}
+
+	var inFlight, most int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.Do(context.Background(), func(context.Context) error {
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					m := atomic.LoadInt32(&most)
+					if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				return nil
+			}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if most > 2 {
+		t.Errorf("%d units of work ran at once, want at most 2", most)
+	}
+
+	want := errors.New("oops")
+	if err := b.Do(context.Background(), func(context.Context) error { return want }); !errors.Is(err, want) {
+		t.Errorf("Do() = %v, want %v", err, want)
+	}
+}
+
+func TestAcquireCancelled(t *testing.T) {
+	b := New(1)
+	if err := b.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	b.Release()
+	if err := b.Acquire(context.Background()); err != nil {
+		t.Errorf("Acquire() after Release() = %v", err)
+	}
+}
+
+func TestHoldNested(t *testing.T) {
+	b := New(1)
+	done := make(chan error)
+	go func() {
+		done <- b.Do(context.Background(), func(ctx context.Context) error {
+			// A nested unit of work that's given ctx reuses its slot.
+			return b.Do(ctx, func(ctx context.Context) error {
+				ctx, release, err := b.Hold(ctx)
+				if err != nil {
+					return err
+				}
+				release()
+				release()
+				return ctx.Err()
+			})
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nested Do deadlocked")
+	}
+
+	// The slot was released exactly once, and a different budget isn't held.
+	ctx, release, err := b.Hold(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	other := New(1)
+	if err := other.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := other.Hold(tctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Hold() of a full budget = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	var b *Budget
+	if New(0) != nil || New(-1) != nil {
+		t.Error("New() of a non-positive limit is not nil")
+	}
+	if got := b.Limit(); got != 0 {
+		t.Errorf("Limit() = %d, want 0", got)
+	}
+	for i := 0; i < 100; i++ {
+		if err := b.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Release()
+}
+
+func TestDefault(t *testing.T) {
+	reset := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		defaultBudget, defaultSet = nil, false
+	}
+	t.Cleanup(reset)
+
+	for _, tc := range []struct {
+		env  string
+		want int
+	}{
+		{"", 0},
+		{"3", 3},
+		{"0", 0},
+		{"lots", 0},
+	} {
+		reset()
+		t.Setenv(EnvVar, tc.env)
+		if got := Default().Limit(); got != tc.want {
+			t.Errorf("%s=%q: Default().Limit() = %d, want %d", EnvVar, tc.env, got, tc.want)
+		}
+	}
+
+	t.Setenv(EnvVar, "3")
+	SetDefault(New(5))
+	if got := Default().Limit(); got != 5 {
+		t.Errorf("Default().Limit() after SetDefault = %d, want 5", got)
+	}
+	SetDefault(nil)
+	if got := Default(); got != nil {
+		t.Errorf("Default() after SetDefault(nil) = %v, want nil", got)
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"github.com/google/go-containerregistry/internal/legacy"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -37,7 +39,7 @@ func Copy(src, dst string, opt ...Option) error {
 		return fmt.Errorf("parsing reference for %q: %w", dst, err)
 	}
 
-	logs.Progress.Printf("Copying from %v to %v", srcRef, dstRef)
+	logs.FromContext(o.ctx).Log(logs.LevelInfo, "Copying", "from", srcRef, "to", dstRef)
 	desc, err := remote.Get(srcRef, o.Remote...)
 	if err != nil {
 		return fmt.Errorf("fetching %q: %w", src, err)
@@ -84,5 +86,8 @@ func copyIndex(desc *remote.Descriptor, dstRef name.Reference, o Options) error
 	if err != nil {
 		return err
 	}
+	if o.Platforms != nil {
+		idx = mutate.RemoveManifests(idx, match.Not(match.PlatformSelector(o.Platforms)))
+	}
 	return remote.WriteIndex(dstRef, idx, o.Remote...)
 }
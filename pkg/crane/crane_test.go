@@ -26,15 +26,16 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"reflect"
 	"strings"
 	"testing"
 
-	"github.com/google/go-containerregistry/internal/compare"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/compare"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
@@ -342,6 +343,68 @@ func TestWithPlatform(t *testing.T) {
 	}
 }
 
+func TestWithPlatforms(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imgs := []mutate.IndexAddendum{}
+	for _, plat := range []string{"linux/amd64", "linux/arm/v7", "linux/arm64", "windows/amd64"} {
+		img, err := crane.Image(map[string][]byte{
+			"platform.txt": []byte(plat),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := v1.ParsePlatform(plat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imgs = append(imgs, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: p},
+		})
+	}
+	idx := mutate.AppendManifests(empty.Index, imgs...)
+
+	src := path.Join(u.Host, "src")
+	dst := path.Join(u.Host, "dst")
+	ref, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	sel, err := v1.ParsePlatformSelector("linux/*,!linux/arm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Copy(src, dst, crane.WithPlatforms(sel)); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := crane.Manifest(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := v1.ParseIndexManifest(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, desc := range im.Manifests {
+		got = append(got, desc.Platform.String())
+	}
+	if want := []string{"linux/amd64", "linux/arm64"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("copied platforms = %v, want %v", got, want)
+	}
+}
+
 func TestCraneTarball(t *testing.T) {
 	t.Parallel()
 	// Write an image as a tarball.
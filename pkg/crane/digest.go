@@ -41,7 +41,7 @@ func Digest(ref string, opt ...Option) (string, error) {
 	}
 	desc, err := Head(ref, opt...)
 	if err != nil {
-		logs.Warn.Printf("HEAD request failed, falling back on GET: %v", err)
+		logs.FromContext(o.ctx).Log(logs.LevelWarn, "HEAD request failed, falling back on GET", "error", err)
 		rdesc, err := getManifest(ref, opt...)
 		if err != nil {
 			return "", err
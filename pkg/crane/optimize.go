@@ -18,14 +18,13 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/containerd/stargz-snapshotter/estargz"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/estargz"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
@@ -44,7 +43,7 @@ func Optimize(src, dst string, prioritize []string, opt ...Option) error {
 		return fmt.Errorf("parsing reference for %q: %w", dst, err)
 	}
 
-	logs.Progress.Printf("Optimizing from %v to %v", srcRef, dstRef)
+	logs.FromContext(o.ctx).Log(logs.LevelInfo, "Optimizing", "from", srcRef, "to", dstRef)
 	desc, err := remote.Get(srcRef, o.Remote...)
 	if err != nil {
 		return fmt.Errorf("fetching %q: %w", src, err)
@@ -118,12 +117,10 @@ func optimizeImage(img v1.Image, prioritize stringSet) (stringSet, v1.Image, err
 	olayers := make([]mutate.Addendum, 0, len(layers))
 	for _, layer := range layers {
 		missingFromLayer := []string{}
-		olayer, err := tarball.LayerFromOpener(layer.Uncompressed,
-			tarball.WithEstargz,
-			tarball.WithEstargzOptions(
-				estargz.WithPrioritizedFiles(prioritize.List()),
-				estargz.WithAllowPrioritizeNotFound(&missingFromLayer),
-			))
+		olayer, err := estargz.Layer(layer,
+			estargz.WithPrioritizedFiles(prioritize.List()...),
+			estargz.WithMissingPrioritizedFiles(&missingFromLayer),
+		)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -19,6 +19,7 @@ import (
 	"net/http"
 
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/concurrency"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
@@ -29,6 +30,11 @@ type Options struct {
 	Name     []name.Option
 	Remote   []remote.Option
 	Platform *v1.Platform
+
+	// Platforms, if set, selects the images of an index that Copy copies.
+	Platforms *v1.PlatformSelector
+
+	ctx context.Context
 }
 
 // GetOptions exposes the underlying []remote.Option, []name.Option, and
@@ -44,6 +50,7 @@ func makeOptions(opts ...Option) Options {
 		Remote: []remote.Option{
 			remote.WithAuthFromKeychain(authn.DefaultKeychain),
 		},
+		ctx: context.Background(),
 	}
 	for _, o := range opts {
 		o(&opt)
@@ -77,6 +84,24 @@ func WithPlatform(platform *v1.Platform) Option {
 	}
 }
 
+// WithPlatforms is an Option to copy only the images of an index whose
+// platforms the selector selects, see v1.ParsePlatformSelector. Unlike
+// WithPlatform, the result is still an index.
+func WithPlatforms(s *v1.PlatformSelector) Option {
+	return func(o *Options) {
+		o.Platforms = s
+	}
+}
+
+// WithBudget is a functional option to set the concurrency budget that bounds
+// how many blobs are transferred at once, across every operation sharing it.
+// The default is concurrency.Default().
+func WithBudget(b *concurrency.Budget) Option {
+	return func(o *Options) {
+		o.Remote = append(o.Remote, remote.WithBudget(b))
+	}
+}
+
 // WithAuthFromKeychain is a functional option for overriding the default
 // authenticator for remote operations, using an authn.Keychain to find
 // credentials.
@@ -109,8 +134,12 @@ func WithUserAgent(ua string) Option {
 }
 
 // WithContext is a functional option for setting the context.
+//
+// A logger attached to ctx with logs.NewContext is used for the messages
+// logged by crane and by the remote operations it performs.
 func WithContext(ctx context.Context) Option {
 	return func(o *Options) {
+		o.ctx = ctx
 		o.Remote = append(o.Remote, remote.WithContext(ctx))
 	}
 }
@@ -71,6 +71,12 @@ func Copy(src, dst string, opts ...Option) error {
 // dst GCR repository.
 func CopyRepository(ctx context.Context, src, dst string, opts ...Option) error {
 	o := makeOptions(opts...)
+	if o.jobs < 1 {
+		return fmt.Errorf("jobs must be at least 1, got %d", o.jobs)
+	}
+	if err := o.filters.validate(); err != nil {
+		return err
+	}
 	return recursiveCopy(ctx, src, dst, o)
 }
 
@@ -79,6 +85,7 @@ type task struct {
 	manifest google.ManifestInfo
 	oldRepo  name.Repository
 	newRepo  name.Repository
+	pending  *pending
 }
 
 type copier struct {
@@ -87,6 +94,10 @@ type copier struct {
 
 	tasks chan task
 	opt   *options
+
+	state *state
+	// pending is only accessed by the goroutine that walks the source.
+	pending map[string]*pending
 }
 
 func newCopier(src, dst string, o *options) (*copier, error) {
@@ -103,7 +114,19 @@ func newCopier(src, dst string, o *options) (*copier, error) {
 	// A queue of size 2*jobs should keep each goroutine busy.
 	tasks := make(chan task, o.jobs*2)
 
-	return &copier{srcRepo, dstRepo, tasks, o}, nil
+	c := &copier{
+		srcRepo: srcRepo,
+		dstRepo: dstRepo,
+		tasks:   tasks,
+		opt:     o,
+		pending: map[string]*pending{},
+	}
+	if o.state != "" {
+		if c.state, err = openState(o.state, o.dryRun); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
 }
 
 // recursiveCopy copies images from repo src to repo dst.
@@ -156,18 +179,39 @@ func recursiveCopy(ctx context.Context, src, dst string, o *options) error {
 				}); err != nil {
 					return fmt.Errorf("failed to copy %q: %w", task.digest, err)
 				}
+				if o.referrers {
+					if err := backoffErrors(GCRBackoff(), func() error {
+						return c.copyReferrers(task)
+					}); err != nil {
+						return fmt.Errorf("failed to copy referrers of %q: %w", task.digest, err)
+					}
+				}
+				c.state.record(task.oldRepo.Digest(task.digest).String())
+				task.pending.finish(c.state)
 			}
 			return nil
 		})
 	}
 
-	return g.Wait()
+	err = g.Wait()
+	if serr := c.state.close(); err == nil {
+		err = serr
+	}
+	return err
 }
 
 // copyRepo figures out the name for our destination repo (newRepo), lists the
 // contents of newRepo, calculates the diff of what needs to be copied, then
 // starts a goroutine to copy each image we need, and waits for them to finish.
 func (c *copier) copyRepo(ctx context.Context, oldRepo name.Repository, tags *google.Tags) error {
+	if !c.opt.filters.keepRepo(relative(c.srcRepo.String(), oldRepo.String())) {
+		return nil
+	}
+	if c.state.repoDone(oldRepo) {
+		logs.Progress.Printf("Skipping %s, which was already copied", oldRepo)
+		return nil
+	}
+
 	newRepo, err := c.rename(oldRepo)
 	if err != nil {
 		return fmt.Errorf("rename failed: %w", err)
@@ -186,15 +230,42 @@ func (c *copier) copyRepo(ctx context.Context, oldRepo name.Repository, tags *go
 	} else {
 		have = haveTags.Manifests
 	}
-	need := diffImages(want, have)
+	need := c.opt.filters.filterImages(diffImages(want, have), want)
+
+	p, ok := c.pending[oldRepo.String()]
+	if !ok {
+		p = &pending{repo: oldRepo}
+		c.pending[oldRepo.String()] = p
+	}
+	// Hold p open until every task has been queued. If this fails, it's
+	// never released, so the repository is never recorded as done.
+	p.add(1)
 
 	// Queue up every image as a task.
-	for digest, manifest := range need {
+	for _, digest := range sortedDigests(need) {
+		if c.state.imageDone(oldRepo, digest) {
+			continue
+		}
+		manifest := need[digest]
+		if c.opt.plan != nil {
+			c.opt.plan(PlannedCopy{
+				Source:      oldRepo.String(),
+				Destination: newRepo.String(),
+				Digest:      digest,
+				Tags:        manifest.Tags,
+				Size:        manifest.Size,
+			})
+		}
+		if c.opt.dryRun {
+			continue
+		}
+		p.add(1)
 		t := task{
 			digest:   digest,
 			manifest: manifest,
 			oldRepo:  oldRepo,
 			newRepo:  newRepo,
+			pending:  p,
 		}
 		select {
 		case c.tasks <- t:
@@ -203,6 +274,9 @@ func (c *copier) copyRepo(ctx context.Context, oldRepo name.Repository, tags *go
 		}
 	}
 
+	if !c.opt.dryRun {
+		p.finish(c.state)
+	}
 	return nil
 }
 
@@ -252,6 +326,27 @@ func (c *copier) copyImages(_ context.Context, t task) error {
 	return nil
 }
 
+// copyReferrers copies the manifests that refer to t's image, like signatures
+// and attestations, that aren't already in t.newRepo. Referrers that are
+// tagged, or listed as untagged images, are copied like any other image;
+// this finds those that are only discoverable with the referrers API.
+func (c *copier) copyReferrers(t task) error {
+	referrers, err := remote.Referrers(t.oldRepo.Digest(t.digest), c.opt.remote...)
+	if err != nil {
+		return err
+	}
+	for _, desc := range referrers.Manifests {
+		dst := t.newRepo.Digest(desc.Digest.String())
+		if _, err := remote.Head(dst, c.opt.remote...); err == nil {
+			continue
+		}
+		if err := crane.Copy(t.oldRepo.Digest(desc.Digest.String()).String(), dst.String(), c.opt.crane...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Retry temporary errors, 429, and 500+ with backoff.
 func backoffErrors(bo retry.Backoff, f func() error) error {
 	p := func(err error) bool {
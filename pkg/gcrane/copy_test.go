@@ -35,7 +35,9 @@ import (
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
@@ -112,7 +114,7 @@ func newFakeXCR(stuff map[name.Reference]partial.Describable, t *testing.T) (*fa
 		mi, ok := tags.Manifests[d.String()]
 		if !ok {
 			mi = google.ManifestInfo{
-				MediaType: string(mt),
+				MediaType: mt,
 				Tags:      []string{},
 			}
 		}
@@ -301,7 +303,7 @@ func TestDiffImages(t *testing.T) {
 		want: map[string]google.ManifestInfo{
 			"a": {
 				Size:      123,
-				MediaType: string(types.DockerManifestSchema2),
+				MediaType: types.DockerManifestSchema2,
 				Created:   time.Date(1992, time.January, 7, 6, 40, 00, 5e8, time.UTC),
 				Uploaded:  time.Date(2018, time.November, 29, 4, 13, 30, 5e8, time.UTC),
 				Tags:      []string{"b", "c", "d"},
@@ -311,7 +313,7 @@ func TestDiffImages(t *testing.T) {
 		need: map[string]google.ManifestInfo{
 			"a": {
 				Size:      123,
-				MediaType: string(types.DockerManifestSchema2),
+				MediaType: types.DockerManifestSchema2,
 				Created:   time.Date(1992, time.January, 7, 6, 40, 00, 5e8, time.UTC),
 				Uploaded:  time.Date(2018, time.November, 29, 4, 13, 30, 5e8, time.UTC),
 				Tags:      []string{"b", "c", "d"},
@@ -427,3 +429,67 @@ func TestBadInputs(t *testing.T) {
 		}
 	}
 }
+
+func TestCopyReferrers(t *testing.T) {
+	src, err := name.NewRepository("registry.example.com/test/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := newFakeXCR(map[name.Reference]partial.Describable{src.Tag("v1"): img}, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ggcrtest.NewTLSServer("registry.example.com", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	opt := remote.WithTransport(s.Client().Transport)
+	if err := remote.Write(src.Tag("v1"), img, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	// Attach a signature with the referrers tag schema, without listing it,
+	// so it can only be found with remote.Referrers.
+	sig, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := sig.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.IndexMediaType(mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: sig}), types.OCIImageIndex)
+	if err := remote.WriteIndex(src.Tag("sha256-"+d.Hex), idx, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		dst  string
+		opts []Option
+		want bool
+	}{
+		{"registry.example.com/test/without", nil, false},
+		{"registry.example.com/test/with", []Option{WithReferrers()}, true},
+	} {
+		opts := append([]Option{WithTransport(s.Client().Transport), WithJobs(1)}, tc.opts...)
+		if err := CopyRepository(context.Background(), src.String(), tc.dst, opts...); err != nil {
+			t.Fatal(err)
+		}
+		dst, err := name.NewDigest(tc.dst + "@" + sd.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := remote.Head(dst, opt); (err == nil) != tc.want {
+			t.Errorf("copied signature to %s = %t, want %t", tc.dst, err == nil, tc.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcrane
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/google"
+)
+
+// PlannedCopy is an image that CopyRepository copies, or would copy with
+// WithDryRun.
+type PlannedCopy struct {
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Digest      string   `json:"digest"`
+	Tags        []string `json:"tags,omitempty"`
+
+	// Size is the size of the image as reported by the source registry, or 0
+	// if it's unknown. Blobs that already exist at the destination won't be
+	// copied, so this is an upper bound.
+	Size uint64 `json:"size"`
+}
+
+// filters limit what CopyRepository copies.
+type filters struct {
+	includeRepos, excludeRepos []string
+	includeTags, excludeTags   []string
+	skip                       map[string]bool
+}
+
+func (f *filters) validate() error {
+	for _, patterns := range [][]string{f.includeRepos, f.excludeRepos, f.includeTags, f.excludeTags} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// keep returns true if s matches one of include, if any, and none of exclude.
+func keep(s string, include, exclude []string) bool {
+	if matchAny(s, exclude) {
+		return false
+	}
+	return len(include) == 0 || matchAny(s, include)
+}
+
+func matchAny(s string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// keepRepo returns true if repo, given relative to the source of the copy,
+// should be copied. The source itself is ".".
+func (f *filters) keepRepo(rel string) bool {
+	return keep(rel, f.includeRepos, f.excludeRepos)
+}
+
+// attachedTag matches the tags of artifacts that are attached to an image by
+// tag, rather than with the referrers API: cosign's "sha256-<hex>.sig",
+// ".att" and ".sbom", and the OCI referrers tag schema's "sha256-<hex>".
+var attachedTag = regexp.MustCompile(`^(sha256)-([0-9a-f]{64})(\.(sig|att|sbom))?$`)
+
+// subject returns the digest of the image that tag is attached to, if it's
+// an attachedTag.
+func subject(tag string) (string, bool) {
+	m := attachedTag.FindStringSubmatch(tag)
+	if m == nil {
+		return "", false
+	}
+	return m[1] + ":" + m[2], true
+}
+
+// filterImages drops skipped digests and tags that shouldn't be copied from
+// need. Images that have tags but none that should be copied are dropped, as
+// are untagged images if only some tags should be copied.
+//
+// Tags of attached artifacts, like signatures, follow the image they're
+// attached to instead: they're kept if it would be, going by its listing in
+// want, so that copied images stay verifiable.
+func (f *filters) filterImages(need, want map[string]google.ManifestInfo) map[string]google.ManifestInfo {
+	kept := make(map[string]google.ManifestInfo, len(need))
+	for digest, m := range need {
+		if f.skip[digest] {
+			continue
+		}
+		if len(m.Tags) == 0 {
+			if len(f.includeTags) == 0 {
+				kept[digest] = m
+			}
+			continue
+		}
+		tags := []string{}
+		for _, tag := range m.Tags {
+			if s, ok := subject(tag); ok {
+				if sm, ok := want[s]; ok {
+					if f.keepTagged(sm.Tags) {
+						tags = append(tags, tag)
+					}
+					continue
+				}
+			}
+			if keep(tag, f.includeTags, f.excludeTags) {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) == 0 {
+			continue
+		}
+		m.Tags = tags
+		kept[digest] = m
+	}
+	return kept
+}
+
+// keepTagged returns true if an image with the given tags passes the tag
+// filters.
+func (f *filters) keepTagged(tags []string) bool {
+	if len(tags) == 0 {
+		return len(f.includeTags) == 0
+	}
+	for _, tag := range tags {
+		if keep(tag, f.includeTags, f.excludeTags) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedDigests(m map[string]google.ManifestInfo) []string {
+	digests := make([]string, 0, len(m))
+	for d := range m {
+		digests = append(digests, d)
+	}
+	sort.Strings(digests)
+	return digests
+}
+
+// relative returns the path of repo relative to root, or "." for root itself.
+func relative(root, repo string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(repo, root), "/")
+	if rel == "" {
+		return "."
+	}
+	return rel
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcrane
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	ggcrtest "github.com/google/go-containerregistry/internal/httptest"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestFilterImages(t *testing.T) {
+	need := map[string]google.ManifestInfo{
+		"a": {Tags: []string{"v1", "v1-debug"}},
+		"b": {Tags: []string{"dev"}},
+		"c": {},
+		"d": {Tags: []string{"v2"}},
+	}
+	for _, tc := range []struct {
+		name string
+		f    filters
+		want map[string]google.ManifestInfo
+	}{{
+		name: "none",
+		want: need,
+	}, {
+		name: "include tags",
+		f:    filters{includeTags: []string{"v*"}, excludeTags: []string{"*-debug"}},
+		want: map[string]google.ManifestInfo{
+			"a": {Tags: []string{"v1"}},
+			"d": {Tags: []string{"v2"}},
+		},
+	}, {
+		name: "exclude tags and skip",
+		f:    filters{excludeTags: []string{"dev"}, skip: map[string]bool{"d": true}},
+		want: map[string]google.ManifestInfo{
+			"a": {Tags: []string{"v1", "v1-debug"}},
+			"c": {},
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.f.filterImages(need, need)); diff != "" {
+				t.Errorf("filterImages() (-want +got) = %s", diff)
+			}
+		})
+	}
+
+	// Signatures follow the images they're attached to, even if those were
+	// already copied.
+	a, b := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	want := map[string]google.ManifestInfo{
+		"sha256:" + a: {Tags: []string{"v1"}},
+		"sha256:" + b: {Tags: []string{"dev"}},
+		"sig-a":       {Tags: []string{"sha256-" + a + ".sig", "sha256-" + a}},
+		"sig-b":       {Tags: []string{"sha256-" + b + ".sig"}},
+		"orphan":      {Tags: []string{"sha256-" + strings.Repeat("c", 64) + ".att"}},
+	}
+	need = map[string]google.ManifestInfo{}
+	for digest, m := range want {
+		if digest != "sha256:"+a {
+			need[digest] = m
+		}
+	}
+	f := filters{includeTags: []string{"v*"}}
+	if diff := cmp.Diff(map[string]google.ManifestInfo{"sig-a": want["sig-a"]}, f.filterImages(need, want)); diff != "" {
+		t.Errorf("filterImages() attached (-want +got) = %s", diff)
+	}
+
+	f = filters{includeRepos: []string{".", "team-a/*"}, excludeRepos: []string{"*/tmp"}}
+	for rel, want := range map[string]bool{
+		".":          true,
+		"team-a/app": true,
+		"team-a/tmp": false,
+		"team-b/app": false,
+	} {
+		if got := f.keepRepo(rel); got != want {
+			t.Errorf("keepRepo(%q) = %t, want %t", rel, got, want)
+		}
+	}
+}
+
+func TestCopyRepositoryDryRun(t *testing.T) {
+	src, err := name.NewRepository("registry.example.com/test/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stuff := map[name.Reference]partial.Describable{}
+	for _, ref := range []name.Reference{
+		src.Tag("v1"),
+		src.Tag("dev"),
+		name.MustParseReference("registry.example.com/test/src/sub:v1"),
+		name.MustParseReference("registry.example.com/test/src/skipped:v1"),
+	} {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stuff[ref] = img
+	}
+	h, err := newFakeXCR(stuff, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ggcrtest.NewTLSServer("registry.example.com", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var got []string
+	if err := CopyRepository(context.Background(), src.String(), "registry.example.com/test/dst",
+		WithTransport(s.Client().Transport),
+		WithJobs(1),
+		WithDryRun(),
+		WithExcludeRepos("skipped"),
+		WithIncludeTags("v*"),
+		WithPlan(func(p PlannedCopy) {
+			got = append(got, p.Destination+":"+p.Tags[0])
+		}),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"registry.example.com/test/dst:v1",
+		"registry.example.com/test/dst/sub:v1",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("planned copies (-want +got) = %s", diff)
+	}
+	if _, err := remote.Head(name.MustParseReference("registry.example.com/test/dst:v1"), remote.WithTransport(s.Client().Transport)); err == nil {
+		t.Error("dry run copied an image")
+	}
+
+	if err := CopyRepository(context.Background(), src.String(), "registry.example.com/test/dst", WithIncludeTags("[")); err == nil {
+		t.Error("CopyRepository() accepted an invalid pattern")
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcrane
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/retention"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// RetentionPolicy decides which images GarbageCollect deletes.
+type RetentionPolicy struct {
+	// UntaggedOlderThan selects untagged images that were uploaded more than
+	// this long ago. If zero, every untagged image is selected.
+	UntaggedOlderThan time.Duration
+
+	// KeepTagged, if positive, selects tagged images other than the
+	// KeepTagged most recently uploaded ones in each repository.
+	KeepTagged int
+
+	// Protect prevents images with any tag matching one of these from being
+	// selected.
+	Protect []*regexp.Regexp
+
+	// Policy, if set, is used instead of the fields above.
+	Policy *retention.Policy
+}
+
+// policy returns p as a retention.Policy.
+func (p RetentionPolicy) policy() *retention.Policy {
+	if p.Policy != nil {
+		return p.Policy
+	}
+	rp := &retention.Policy{
+		Untagged: &retention.UntaggedRule{OlderThan: retention.Duration(p.UntaggedOlderThan)},
+	}
+	if p.KeepTagged > 0 {
+		rp.Keep = []retention.KeepRule{{Last: p.KeepTagged}}
+	}
+	for _, re := range p.Protect {
+		rp.Protect = append(rp.Protect, retention.MustCompile(re.String()))
+	}
+	return rp
+}
+
+// Deletion is an image that a RetentionPolicy selected for deletion.
+type Deletion struct {
+	Repository string          `json:"repository"`
+	Digest     string          `json:"digest"`
+	Tags       []string        `json:"tags,omitempty"`
+	MediaType  types.MediaType `json:"mediaType,omitempty"`
+	Uploaded   time.Time       `json:"uploaded"`
+	Reason     string          `json:"reason"`
+
+	// Deleted is true once the image has been deleted. It's false for dry runs.
+	Deleted bool `json:"deleted"`
+
+	// Error is why deleting the image failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// Select returns the images in tags, as listed from repo, that p selects for
+// deletion. Indexes come first, so that they're deleted before the images
+// they refer to, and otherwise the oldest images come first.
+//
+// Select doesn't know which images are referenced by indexes; GarbageCollect
+// takes care of that.
+func (p RetentionPolicy) Select(repo name.Repository, tags *google.Tags, now time.Time) []Deletion {
+	var selected []Deletion
+	for _, d := range p.policy().Evaluate(retention.FromGoogle(tags), now) {
+		selected = append(selected, Deletion{
+			Repository: repo.String(),
+			Digest:     d.Digest,
+			Tags:       d.Tags,
+			MediaType:  d.MediaType,
+			Uploaded:   d.Uploaded,
+			Reason:     d.Reason,
+		})
+	}
+	return selected
+}
+
+// GarbageCollect deletes the images in root that policy selects, calling
+// report for each of them after attempting the deletion. Images that are
+// referenced by an index that isn't being deleted are never deleted, even
+// though GCR lists them as untagged.
+//
+// With WithDryRun, nothing is deleted. With WithRecursive, every repository
+// under root is collected too.
+//
+// Failing to delete an image doesn't stop collection, but GarbageCollect then
+// returns an error at the end. An error from report stops collection.
+func GarbageCollect(ctx context.Context, root string, policy RetentionPolicy, report func(Deletion) error, opts ...Option) error {
+	o := makeOptions(opts...)
+	o.remote = append(o.remote, remote.WithContext(ctx))
+	o.google = append(o.google, google.WithContext(ctx))
+	repo, err := name.NewRepository(root)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	collect := func(repo name.Repository, tags *google.Tags, err error) error {
+		if err != nil {
+			return err
+		}
+		deletions, err := referenced(repo, tags, policy.Select(repo, tags, time.Now()), o)
+		if err != nil {
+			return err
+		}
+		for _, d := range deletions {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !o.dryRun {
+				if err := deleteImage(repo, d, o); err != nil {
+					d.Error = err.Error()
+					failed++
+				} else {
+					d.Deleted = true
+				}
+			}
+			if err := report(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if o.recursive {
+		err = google.Walk(repo, collect, o.google...)
+	} else {
+		tags, lerr := google.List(repo, o.google...)
+		err = collect(repo, tags, lerr)
+	}
+	if err != nil {
+		return err
+	}
+	if failed != 0 {
+		return fmt.Errorf("failed to delete %d images", failed)
+	}
+	return nil
+}
+
+// referenced drops deletions of images that are referenced by an index in
+// tags that isn't being deleted.
+func referenced(repo name.Repository, tags *google.Tags, deletions []Deletion, o *options) ([]Deletion, error) {
+	ds := make([]retention.Deletion, 0, len(deletions))
+	for _, d := range deletions {
+		ds = append(ds, retention.Deletion{Digest: d.Digest})
+	}
+	ds, err := retention.DropReferenced(repo, retention.FromGoogle(tags), ds, o.remote...)
+	if err != nil {
+		return nil, err
+	}
+	keep := map[string]bool{}
+	for _, d := range ds {
+		keep[d.Digest] = true
+	}
+
+	kept := deletions[:0]
+	for _, d := range deletions {
+		if keep[d.Digest] {
+			kept = append(kept, d)
+		}
+	}
+	return kept, nil
+}
+
+// deleteImage deletes d's tags and then its manifest, since GCR refuses to
+// delete tagged manifests.
+func deleteImage(repo name.Repository, d Deletion, o *options) error {
+	for _, tag := range d.Tags {
+		if err := remote.Delete(repo.Tag(tag), o.remote...); err != nil {
+			return fmt.Errorf("deleting tag %s: %w", tag, err)
+		}
+	}
+	return remote.Delete(repo.Digest(d.Digest), o.remote...)
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcrane
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	ggcrtest "github.com/google/go-containerregistry/internal/httptest"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestSelect(t *testing.T) {
+	repo, err := name.NewRepository("gcr.io/test/gc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(100*24*60*60, 0)
+	daysAgo := func(n int) time.Time {
+		return now.Add(-time.Duration(n) * 24 * time.Hour)
+	}
+	tags := &google.Tags{
+		Manifests: map[string]google.ManifestInfo{
+			"sha256:old":     {Uploaded: daysAgo(30)},
+			"sha256:new":     {Uploaded: daysAgo(1)},
+			"sha256:index":   {Uploaded: daysAgo(40), MediaType: types.OCIImageIndex},
+			"sha256:v1":      {Uploaded: daysAgo(50), Tags: []string{"v1"}},
+			"sha256:v2":      {Uploaded: daysAgo(20), Tags: []string{"v2"}},
+			"sha256:v3":      {Uploaded: daysAgo(10), Tags: []string{"v3", "latest"}},
+			"sha256:release": {Uploaded: daysAgo(60), Tags: []string{"release-1"}},
+		},
+	}
+
+	digests := func(ds []Deletion) []string {
+		got := []string{}
+		for _, d := range ds {
+			got = append(got, d.Digest)
+		}
+		return got
+	}
+
+	for _, tc := range []struct {
+		name   string
+		policy RetentionPolicy
+		want   []string
+	}{{
+		name: "untagged",
+		want: []string{"sha256:index", "sha256:old", "sha256:new"},
+	}, {
+		name:   "older than",
+		policy: RetentionPolicy{UntaggedOlderThan: 7 * 24 * time.Hour},
+		want:   []string{"sha256:index", "sha256:old"},
+	}, {
+		name: "keep tagged",
+		policy: RetentionPolicy{
+			UntaggedOlderThan: 7 * 24 * time.Hour,
+			KeepTagged:        1,
+			Protect:           []*regexp.Regexp{regexp.MustCompile("^release-")},
+		},
+		want: []string{"sha256:index", "sha256:v1", "sha256:old", "sha256:v2"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.policy.Select(repo, tags, now)
+			if diff := cmp.Diff(tc.want, digests(got)); diff != "" {
+				t.Errorf("Select() (-want +got) = %s", diff)
+			}
+		})
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	repo, err := name.NewRepository("registry.example.com/test/gc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untagged, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := untagged.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	untaggedRef := repo.Digest(d.String())
+
+	stuff := map[name.Reference]partial.Describable{
+		repo.Tag("v1"):    tagged,
+		untaggedRef:       untagged,
+		repo.Tag("multi"): idx,
+	}
+	// GCR lists the children of indexes as untagged.
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, desc := range im.Manifests {
+		child, err := idx.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stuff[repo.Digest(desc.Digest.String())] = child
+	}
+
+	h, err := newFakeXCR(stuff, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ggcrtest.NewTLSServer("registry.example.com", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	opt := remote.WithTransport(s.Client().Transport)
+	if err := remote.Write(repo.Tag("v1"), tagged, opt); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(untaggedRef, untagged, opt); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(repo.Tag("multi"), idx, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var got []Deletion
+	report := func(d Deletion) error {
+		got = append(got, d)
+		return nil
+	}
+
+	// A dry run only reports the untagged image that isn't in an index.
+	if err := GarbageCollect(ctx, repo.String(), RetentionPolicy{}, report, WithTransport(s.Client().Transport), WithDryRun()); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Digest != d.String() || got[0].Deleted {
+		t.Fatalf("GarbageCollect(WithDryRun) reported %+v", got)
+	}
+	if _, err := remote.Head(untaggedRef, opt); err != nil {
+		t.Errorf("dry run deleted %s: %v", untaggedRef, err)
+	}
+
+	got = nil
+	if err := GarbageCollect(ctx, repo.String(), RetentionPolicy{}, report, WithTransport(s.Client().Transport)); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !got[0].Deleted {
+		t.Fatalf("GarbageCollect() reported %+v", got)
+	}
+	if _, err := remote.Head(untaggedRef, opt); err == nil {
+		t.Errorf("%s wasn't deleted", untaggedRef)
+	}
+	for _, desc := range im.Manifests {
+		if _, err := remote.Head(repo.Digest(desc.Digest.String()), opt); err != nil {
+			t.Errorf("index child was deleted: %v", err)
+		}
+	}
+}
@@ -33,6 +33,14 @@ type options struct {
 	remote []remote.Option
 	google []google.Option
 	crane  []crane.Option
+
+	dryRun    bool
+	recursive bool
+	referrers bool
+
+	filters filters
+	plan    func(PlannedCopy)
+	state   string
 }
 
 func makeOptions(opts ...Option) *options {
@@ -65,6 +73,98 @@ func WithJobs(jobs int) Option {
 	}
 }
 
+// WithDryRun makes GarbageCollect report what it would delete without
+// deleting anything, and CopyRepository report what it would copy, via
+// WithPlan, without copying anything.
+func WithDryRun() Option {
+	return func(o *options) {
+		o.dryRun = true
+	}
+}
+
+// WithRecursive makes GarbageCollect descend into child repositories.
+func WithRecursive() Option {
+	return func(o *options) {
+		o.recursive = true
+	}
+}
+
+// WithIncludeRepos makes CopyRepository only copy images from repositories
+// matching one of the given path.Match patterns, e.g. "team-a/*". Patterns
+// match paths relative to the source, which itself is ".". Child
+// repositories are still visited, even if their parent isn't copied.
+func WithIncludeRepos(patterns ...string) Option {
+	return func(o *options) {
+		o.filters.includeRepos = append(o.filters.includeRepos, patterns...)
+	}
+}
+
+// WithExcludeRepos makes CopyRepository skip repositories matching any of the
+// given path.Match patterns, as for WithIncludeRepos.
+func WithExcludeRepos(patterns ...string) Option {
+	return func(o *options) {
+		o.filters.excludeRepos = append(o.filters.excludeRepos, patterns...)
+	}
+}
+
+// WithIncludeTags makes CopyRepository only copy tags matching one of the
+// given path.Match patterns, e.g. "v1.*". Untagged images are then skipped.
+func WithIncludeTags(patterns ...string) Option {
+	return func(o *options) {
+		o.filters.includeTags = append(o.filters.includeTags, patterns...)
+	}
+}
+
+// WithExcludeTags makes CopyRepository skip tags matching any of the given
+// path.Match patterns. Images are skipped if all of their tags are.
+func WithExcludeTags(patterns ...string) Option {
+	return func(o *options) {
+		o.filters.excludeTags = append(o.filters.excludeTags, patterns...)
+	}
+}
+
+// WithSkipDigests makes CopyRepository skip images with the given digests,
+// e.g. those copied by a previous run.
+func WithSkipDigests(digests ...string) Option {
+	return func(o *options) {
+		if o.filters.skip == nil {
+			o.filters.skip = map[string]bool{}
+		}
+		for _, d := range digests {
+			o.filters.skip[d] = true
+		}
+	}
+}
+
+// WithPlan makes CopyRepository call f for each image before copying it.
+// Calls are made sequentially.
+func WithPlan(f func(PlannedCopy)) Option {
+	return func(o *options) {
+		o.plan = f
+	}
+}
+
+// WithState makes CopyRepository record its progress in the file at path,
+// and skip whatever a previous run recorded there, so that an interrupted
+// copy resumes where it stopped instead of re-checking every repository.
+//
+// Repositories are recorded once all of their images have been copied, so
+// images pushed to them later are only copied with a new state file.
+func WithState(path string) Option {
+	return func(o *options) {
+		o.state = path
+	}
+}
+
+// WithReferrers makes CopyRepository also copy the manifests that refer to
+// each image it copies, as found with remote.Referrers. Signatures attached
+// by tag are copied regardless.
+func WithReferrers() Option {
+	return func(o *options) {
+		o.referrers = true
+	}
+}
+
 // WithTransport is a functional option for overriding the default transport
 // for remote operations.
 func WithTransport(t http.RoundTripper) Option {
@@ -0,0 +1,140 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcrane
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// state records the progress of CopyRepository in a file, so that an
+// interrupted copy can resume where it stopped.
+//
+// Each line is either a source image, as repo@digest, that has been copied,
+// or a source repository whose images have all been copied. Since images are
+// recorded as references by digest, the file can also be passed to
+// WithSkipDigests (or gcrane cp --since).
+//
+// A nil *state records nothing.
+type state struct {
+	mu     sync.Mutex
+	f      *os.File
+	done   map[string]bool
+	failed error
+}
+
+// openState reads the state recorded in path, if any. Unless readOnly, path
+// is then opened for appending.
+func openState(path string, readOnly bool) (*state, error) {
+	s := &state{done: map[string]bool{}}
+
+	f, err := os.Open(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err == nil {
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" {
+				s.done[line] = true
+			}
+		}
+		f.Close()
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("reading state %s: %w", path, err)
+		}
+	}
+
+	if readOnly {
+		return s, nil
+	}
+	s.f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// repoDone returns true if every image in repo has been copied.
+func (s *state) repoDone(repo name.Repository) bool {
+	return s.has(repo.String())
+}
+
+// imageDone returns true if repo@digest has been copied.
+func (s *state) imageDone(repo name.Repository, digest string) bool {
+	return s.has(repo.Digest(digest).String())
+}
+
+func (s *state) has(line string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done[line]
+}
+
+// record appends line to the state file. Only the first write error is
+// returned, by close, so that copying can continue without a record.
+func (s *state) record(line string) {
+	if s == nil || s.f == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done[line] || s.failed != nil {
+		return
+	}
+	s.done[line] = true
+	if _, err := fmt.Fprintln(s.f, line); err != nil {
+		s.failed = fmt.Errorf("recording state: %w", err)
+	}
+}
+
+func (s *state) close() error {
+	if s == nil || s.f == nil {
+		return nil
+	}
+	if err := s.f.Close(); err != nil && s.failed == nil {
+		s.failed = err
+	}
+	return s.failed
+}
+
+// pending counts the outstanding work for a source repository, so that it's
+// recorded as done once its last image has been copied.
+type pending struct {
+	repo name.Repository
+	n    int32
+}
+
+// add marks n more units of work as outstanding.
+func (p *pending) add(n int32) {
+	atomic.AddInt32(&p.n, n)
+}
+
+// finish marks a unit of work as done, recording the repository in s if it
+// was the last.
+func (p *pending) finish(s *state) {
+	if atomic.AddInt32(&p.n, -1) == 0 {
+		s.record(p.repo.String())
+	}
+}
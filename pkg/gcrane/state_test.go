@@ -0,0 +1,134 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcrane
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	ggcrtest "github.com/google/go-containerregistry/internal/httptest"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestCopyRepositoryState(t *testing.T) {
+	src, err := name.NewRepository("registry.example.com/test/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := name.MustParseReference("registry.example.com/test/src/sub").Context()
+	v1Tag, v2Tag, subTag := src.Tag("v1"), src.Tag("v2"), sub.Tag("v1")
+
+	images := map[name.Tag]v1.Image{}
+	stuff := map[name.Reference]partial.Describable{}
+	for _, ref := range []name.Tag{v1Tag, v2Tag, subTag} {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		images[ref] = img
+		stuff[ref] = img
+	}
+	h, err := newFakeXCR(stuff, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dstLists int
+	s, err := ggcrtest.NewTLSServer("registry.example.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v2/test/dst") && strings.HasSuffix(r.URL.Path, "/tags/list") {
+			dstLists++
+		}
+		h.ServeHTTP(w, r)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	opt := remote.WithTransport(s.Client().Transport)
+	for ref, img := range images {
+		if err := remote.Write(ref, img, opt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	digest := func(ref name.Tag) string {
+		d, err := images[ref].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.String()
+	}
+
+	// Pretend that a previous run copied sub and src:v1, then was interrupted.
+	path := filepath.Join(t.TempDir(), "state")
+	v1Done := src.Digest(digest(v1Tag)).String()
+	if err := os.WriteFile(path, []byte(sub.String()+"\n"+v1Done+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := "registry.example.com/test/dst"
+	copyOpts := []Option{WithTransport(s.Client().Transport), WithJobs(2), WithState(path)}
+	if err := CopyRepository(context.Background(), src.String(), dst, copyOpts...); err != nil {
+		t.Fatal(err)
+	}
+
+	for ref, want := range map[string]bool{
+		dst + ":v1":     false,
+		dst + ":v2":     true,
+		dst + "/sub:v1": false,
+	} {
+		r, err := name.ParseReference(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = remote.Head(r, opt)
+		if got := err == nil; got != want {
+			t.Errorf("copied %s = %t, want %t", ref, got, want)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Fields(string(b))
+	sort.Strings(got)
+	want := []string{src.String(), src.Digest(digest(v2Tag)).String(), v1Done, sub.String()}
+	sort.Strings(want)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("state (-want +got) = %s", diff)
+	}
+
+	// Resuming a finished copy shouldn't look at the destination at all.
+	dstLists = 0
+	if err := CopyRepository(context.Background(), src.String(), dst, copyOpts...); err != nil {
+		t.Fatal(err)
+	}
+	if dstLists != 0 {
+		t.Errorf("resumed copy listed the destination %d times", dstLists)
+	}
+
+	if err := CopyRepository(context.Background(), src.String(), dst, WithJobs(0)); err == nil {
+		t.Error("CopyRepository() accepted zero jobs")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import "context"
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// NewContext returns a copy of ctx that carries l. Operations that are passed
+// the returned context log to l instead of the logger set with SetLogger,
+// which keeps the logs of concurrent operations in one process separate.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// WithRequestID returns a copy of ctx that carries id. Messages logged via
+// FromContext on the returned context include it as "request_id", so that
+// they can be correlated with each other.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID attached to ctx with WithRequestID, if any.
+func RequestID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// FromContext returns the logger attached to ctx with NewContext, falling
+// back to the logger set with SetLogger. If ctx carries a request ID, it is
+// added to every message.
+func FromContext(ctx context.Context) Logger {
+	l, ok := ctx.Value(loggerKey{}).(Logger)
+	if !ok || l == nil {
+		l = Default()
+	}
+	if id, ok := RequestID(ctx); ok {
+		return withValues{l, []interface{}{"request_id", id}}
+	}
+	return l
+}
+
+// withValues prepends keysAndValues to every message.
+type withValues struct {
+	Logger
+	keysAndValues []interface{}
+}
+
+func (w withValues) Log(level Level, msg string, keysAndValues ...interface{}) {
+	kv := make([]interface{}, 0, len(w.keysAndValues)+len(keysAndValues))
+	kv = append(kv, w.keysAndValues...)
+	w.Logger.Log(level, msg, append(kv, keysAndValues...)...)
+}
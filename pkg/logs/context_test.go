@@ -0,0 +1,56 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	var got []string
+	record := func(name string) Logger {
+		return LoggerFunc(func(level Level, msg string, kv ...interface{}) {
+			got = append(got, fmt.Sprintf("%s: %s %v", name, msg, kv))
+		})
+	}
+	SetLogger(record("global"))
+	defer SetLogger(nil)
+
+	ctx := context.Background()
+	FromContext(ctx).Log(LevelInfo, "a")
+
+	ctx = NewContext(ctx, record("scoped"))
+	FromContext(ctx).Log(LevelInfo, "b", "k", "v")
+
+	ctx = WithRequestID(ctx, "1234")
+	FromContext(ctx).Log(LevelInfo, "c", "k", "v")
+	if !FromContext(ctx).Enabled(LevelDebug) {
+		t.Error("Enabled(LevelDebug) = false, want true")
+	}
+	if id, ok := RequestID(ctx); !ok || id != "1234" {
+		t.Errorf("RequestID() = %q, %t, want 1234", id, ok)
+	}
+
+	want := []string{
+		"global: a []",
+		"scoped: b [k v]",
+		"scoped: c [request_id 1234 k v]",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// now is overridden in tests.
+var now = time.Now
+
+type jsonLogger struct {
+	level Level
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLogger returns a Logger that writes messages at or above level to w
+// as a stream of JSON objects, one per line, e.g.:
+//
+//	{"time":"2022-04-01T12:00:00Z","level":"INFO","msg":"pushed blob","digest":"sha256:..."}
+//
+// Each message describes one event, such as "pull started", "pulled blob",
+// "pushed blob", "pushed manifest", "retrying" or (at LevelDebug) "refreshed
+// token", and its "msg" can be used to select events of a given kind. Errors
+// and fmt.Stringers are written as strings, durations as seconds.
+func NewJSONLogger(w io.Writer, level Level) Logger {
+	return &jsonLogger{level: level, w: w}
+}
+
+// Enabled implements Logger.
+func (l *jsonLogger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Log implements Logger.
+func (l *jsonLogger) Log(level Level, msg string, keysAndValues ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeJSON(&b, now().UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		b.WriteByte(',')
+		if i+1 == len(keysAndValues) {
+			// Like log/slog, report a value without a key.
+			writeJSON(&b, "!BADKEY")
+			b.WriteByte(':')
+			writeJSON(&b, jsonValue(keysAndValues[i]))
+			break
+		}
+		writeJSON(&b, fmt.Sprint(keysAndValues[i]))
+		b.WriteByte(':')
+		writeJSON(&b, jsonValue(keysAndValues[i+1]))
+	}
+	b.WriteString("}\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(b.Bytes()) //nolint: errcheck
+}
+
+// jsonValue converts v to something that encodes to useful JSON.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Duration:
+		return v.Seconds()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+func writeJSON(b *bytes.Buffer, v interface{}) {
+	var j bytes.Buffer
+	enc := json.NewEncoder(&j)
+	// Messages like "-->" are common, so don't escape them.
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		j.Reset()
+		enc.Encode(fmt.Sprint(v)) //nolint: errcheck
+	}
+	b.Write(bytes.TrimSuffix(j.Bytes(), []byte("\n")))
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stringer struct{}
+
+func (stringer) String() string { return "sha256:deadbeef" }
+
+func TestJSONLogger(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC) }
+
+	var b bytes.Buffer
+	l := NewJSONLogger(&b, LevelInfo)
+	if l.Enabled(LevelDebug) {
+		t.Error("Enabled(LevelDebug) = true, want false")
+	}
+
+	l.Log(LevelDebug, "refreshed token")
+	l.Log(LevelInfo, "pushed blob", "digest", stringer{}, "size", 42)
+	l.Log(LevelWarn, "retrying", "error", errors.New("EOF"), "after", 1500*time.Millisecond, "dangling")
+	l.Log(LevelInfo, "<--", "ok", func() {})
+
+	want := `{"time":"2022-04-01T12:00:00Z","level":"INFO","msg":"pushed blob","digest":"sha256:deadbeef","size":42}
+{"time":"2022-04-01T12:00:00Z","level":"WARN","msg":"retrying","error":"EOF","after":1.5,"!BADKEY":"dangling"}
+`
+	got := b.String()
+	if !strings.HasPrefix(got, want) {
+		t.Fatalf("logged:\n%s\nwant:\n%s", got, want)
+	}
+	if last := strings.TrimPrefix(got, want); !strings.HasPrefix(last, `{"time":"2022-04-01T12:00:00Z","level":"INFO","msg":"<--","ok":"0x`) {
+		t.Errorf("logged %s, want a string for values that can't be encoded", last)
+	}
+}
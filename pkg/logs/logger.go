@@ -0,0 +1,164 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is the severity of a log message. Its values match those of
+// log/slog, so a Level can be converted to a slog.Level directly.
+type Level int
+
+const (
+	// LevelTrace is for detailed timings of every request, which the default
+	// logger writes to Trace.
+	LevelTrace Level = -8
+
+	// LevelDebug is for information that is useful for debugging, which the
+	// default logger writes to Debug.
+	LevelDebug Level = -4
+
+	// LevelInfo is for notable, successful events, which the default logger
+	// writes to Progress.
+	LevelInfo Level = 0
+
+	// LevelWarn is for non-fatal errors, which the default logger writes to
+	// Warn.
+	LevelWarn Level = 4
+)
+
+// String implements fmt.Stringer.
+func (l Level) String() string {
+	switch {
+	case l >= LevelWarn:
+		return "WARN"
+	case l >= LevelInfo:
+		return "INFO"
+	case l >= LevelDebug:
+		return "DEBUG"
+	default:
+		return "TRACE"
+	}
+}
+
+// Logger is a leveled, structured logger. It's small enough that adapting
+// slog, logr or zap to it only takes a few lines, e.g.:
+//
+//	logs.SetLogger(logs.LoggerFunc(func(l logs.Level, msg string, kv ...interface{}) {
+//		slogger.Log(context.Background(), slog.Level(l), msg, kv...)
+//	}))
+type Logger interface {
+	// Enabled returns whether messages at level are logged, so that callers
+	// can avoid doing expensive work for messages that would be dropped.
+	Enabled(level Level) bool
+
+	// Log logs msg at level, along with alternating keys and values.
+	Log(level Level, msg string, keysAndValues ...interface{})
+}
+
+// LoggerFunc adapts a function to a Logger that's enabled at every level.
+type LoggerFunc func(level Level, msg string, keysAndValues ...interface{})
+
+// Enabled implements Logger.
+func (f LoggerFunc) Enabled(Level) bool {
+	return true
+}
+
+// Log implements Logger.
+func (f LoggerFunc) Log(level Level, msg string, keysAndValues ...interface{}) {
+	f(level, msg, keysAndValues...)
+}
+
+// holder lets loggers of different types be stored in an atomic.Value.
+type holder struct {
+	Logger
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(holder{stdLogger{}})
+}
+
+// SetLogger replaces the logger used by this library. Passing nil restores
+// the default, which writes messages to Warn, Progress, Debug and Trace.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = stdLogger{}
+	}
+	current.Store(holder{l})
+}
+
+// Default returns the logger set with SetLogger.
+func Default() Logger {
+	return current.Load().(holder).Logger
+}
+
+// Log logs msg at level with the logger set with SetLogger.
+func Log(level Level, msg string, keysAndValues ...interface{}) {
+	Default().Log(level, msg, keysAndValues...)
+}
+
+// IsEnabled returns whether the logger set with SetLogger logs messages at
+// level.
+func IsEnabled(level Level) bool {
+	return Default().Enabled(level)
+}
+
+// stdLogger writes to the package's *log.Loggers, formatting key/value pairs
+// as "key=value" after the message.
+type stdLogger struct{}
+
+func (stdLogger) logger(level Level) *log.Logger {
+	switch {
+	case level >= LevelWarn:
+		return Warn
+	case level >= LevelInfo:
+		return Progress
+	case level >= LevelDebug:
+		return Debug
+	default:
+		return Trace
+	}
+}
+
+func (s stdLogger) Enabled(level Level) bool {
+	return Enabled(s.logger(level))
+}
+
+func (s stdLogger) Log(level Level, msg string, keysAndValues ...interface{}) {
+	l := s.logger(level)
+	if !Enabled(l) {
+		return
+	}
+	l.Print(format(msg, keysAndValues))
+}
+
+func format(msg string, keysAndValues []interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			fmt.Fprintf(&b, " %v", keysAndValues[i])
+			break
+		}
+		fmt.Fprintf(&b, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+	}
+	return b.String()
+}
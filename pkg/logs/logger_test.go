@@ -0,0 +1,88 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+func TestDefaultLogger(t *testing.T) {
+	var b bytes.Buffer
+	Warn.SetOutput(&b)
+	Warn.SetFlags(0)
+	defer func() {
+		Warn.SetOutput(ioutil.Discard)
+		Warn.SetFlags(log.LstdFlags)
+	}()
+
+	if IsEnabled(LevelDebug) {
+		t.Error("IsEnabled(LevelDebug) = true, want false")
+	}
+	if !IsEnabled(LevelWarn) {
+		t.Error("IsEnabled(LevelWarn) = false, want true")
+	}
+	Log(LevelWarn, "retrying", "attempt", 2, "error", "boom", "dangling")
+	Log(LevelInfo, "dropped")
+
+	if got, want := b.String(), "retrying attempt=2 error=boom dangling\n"; got != want {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	var got []string
+	SetLogger(LoggerFunc(func(level Level, msg string, kv ...interface{}) {
+		got = append(got, fmt.Sprintf("%s %s %v", level, msg, kv))
+	}))
+	defer SetLogger(nil)
+
+	if !IsEnabled(LevelDebug) {
+		t.Error("IsEnabled(LevelDebug) = false, want true")
+	}
+	Log(LevelDebug, "request", "method", "GET")
+	Log(LevelWarn+1, "error")
+
+	want := []string{"DEBUG request [method GET]", "WARN error []"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+
+	SetLogger(nil)
+	if _, ok := Default().(stdLogger); !ok {
+		t.Errorf("SetLogger(nil) left %T, want the default", Default())
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	for level, want := range map[Level]string{
+		LevelTrace:     "TRACE",
+		LevelDebug:     "DEBUG",
+		LevelInfo:      "INFO",
+		LevelWarn:      "WARN",
+		LevelWarn + 4:  "WARN",
+		LevelDebug - 1: "TRACE",
+	} {
+		if got := level.String(); got != want {
+			t.Errorf("Level(%d).String() = %q, want %q", level, got, want)
+		}
+	}
+	if IsEnabled(LevelTrace) {
+		t.Error("IsEnabled(LevelTrace) = true, want false")
+	}
+}
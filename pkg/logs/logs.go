@@ -13,6 +13,10 @@
 // limitations under the License.
 
 // Package logs exposes the loggers used by this library.
+//
+// By default, messages are written to Warn, Progress, Debug and Trace, which
+// discard them until their output is set. Use SetLogger to send them
+// elsewhere, e.g. to a structured logging library.
 package logs
 
 import (
@@ -29,6 +33,10 @@ var (
 
 	// Debug is used to log information that is useful for debugging.
 	Debug = log.New(ioutil.Discard, "", log.LstdFlags)
+
+	// Trace is used to log detailed timings of every request, which are
+	// useful for debugging slow transfers.
+	Trace = log.New(ioutil.Discard, "", log.LstdFlags)
 )
 
 // Enabled checks to see if the logger's writer is set to something other
@@ -0,0 +1,437 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// tokenPath is where the registry serves its token endpoint when token
+// authentication is enabled.
+const tokenPath = "/token"
+
+// TokenAuth configures the token authentication scheme described at
+// https://docs.docker.com/registry/spec/auth/token/, with the registry acting
+// as its own token server.
+type TokenAuth struct {
+	// Users maps usernames to passwords. If nil, anonymous clients are
+	// issued tokens too.
+	Users map[string]string
+
+	// Authorize reports whether user may perform action ("pull", "push" or
+	// "delete") on repo. Anonymous clients have an empty user. If nil, every
+	// authenticated user may do anything.
+	Authorize func(user, repo, action string) bool
+
+	// Expiry is how long issued tokens are valid. Defaults to 5 minutes.
+	Expiry time.Duration
+}
+
+// WithBasicAuth requires clients to authenticate with one of the given
+// username and password pairs using HTTP basic auth.
+func WithBasicAuth(users map[string]string) Option {
+	return func(r *registry) {
+		r.auth = &auth{users: users}
+	}
+}
+
+// WithTokenAuth requires clients to present a bearer token issued by the
+// registry itself. Tokens are handed out at /token to clients with valid
+// credentials, and are scoped to the repositories and actions they asked for
+// and cfg.Authorize allows.
+func WithTokenAuth(cfg TokenAuth) Option {
+	return func(r *registry) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+		expiry := cfg.Expiry
+		if expiry == 0 {
+			expiry = 5 * time.Minute
+		}
+		r.auth = &auth{
+			users:     cfg.Users,
+			token:     true,
+			authorize: cfg.Authorize,
+			expiry:    expiry,
+			key:       key,
+		}
+	}
+}
+
+type auth struct {
+	users map[string]string
+
+	// open lets anyone in, as anonymous, so only tenants restrict access.
+	open    bool
+	tenants []Tenant
+
+	// Only used for token auth.
+	token     bool
+	authorize func(user, repo, action string) bool
+	expiry    time.Duration
+	key       []byte
+}
+
+// access is a single entry of a token's grants, as in the "access" claim of
+// https://docs.docker.com/registry/spec/auth/jwt/.
+type access struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+type claims struct {
+	Subject string   `json:"sub"`
+	Expires int64    `json:"exp"`
+	Refresh bool     `json:"refresh,omitempty"`
+	Access  []access `json:"access,omitempty"`
+}
+
+var regErrUnauthorized = &regError{
+	Status:  http.StatusUnauthorized,
+	Code:    "UNAUTHORIZED",
+	Message: "authentication required",
+}
+
+var regErrDenied = &regError{
+	Status:  http.StatusForbidden,
+	Code:    "DENIED",
+	Message: "requested access to the resource is denied",
+}
+
+// check returns the authenticated user, or an error, having set the
+// appropriate challenge, if req is not allowed to proceed.
+func (a *auth) check(resp http.ResponseWriter, req *http.Request) (string, *regError) {
+	if !a.token {
+		var user string
+		if !a.open {
+			u, pass, ok := req.BasicAuth()
+			if !ok || !a.valid(u, pass) {
+				resp.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+				return "", regErrUnauthorized
+			}
+			user = u
+		}
+		if want, ok := required(req); ok && !a.permits(user, want) {
+			return "", regErrDenied
+		}
+		return user, nil
+	}
+
+	want, ok := required(req)
+	challenge := fmt.Sprintf("Bearer realm=%q,service=%q", realm(req), req.Host)
+	if ok {
+		scope := want
+		if want.Actions[0] == "push" {
+			// Pushes need to check what's already there, too.
+			scope.Actions = []string{"pull", "push"}
+		}
+		challenge += fmt.Sprintf(",scope=%q", scope.String())
+	}
+
+	c, err := a.parse(req)
+	if err != nil {
+		resp.Header().Set("WWW-Authenticate", challenge)
+		return "", regErrUnauthorized
+	}
+	if !ok || c.allows(want) {
+		return c.Subject, nil
+	}
+	// Challenge again, so clients that asked for too narrow a scope get a
+	// chance to ask for the right one.
+	resp.Header().Set("WWW-Authenticate", challenge+`,error="insufficient_scope"`)
+	return "", regErrUnauthorized
+}
+
+func (a *auth) valid(user, pass string) bool {
+	want, ok := a.users[user]
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(want), []byte(pass)) == 1
+}
+
+// parse validates the bearer token in req.
+func (a *auth) parse(req *http.Request) (*claims, error) {
+	h := req.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") {
+		return nil, errors.New("no bearer token")
+	}
+	c, err := a.verify(strings.TrimPrefix(h, "Bearer "))
+	if err != nil {
+		return nil, err
+	}
+	if c.Refresh {
+		return nil, errors.New("refresh token used as access token")
+	}
+	return c, nil
+}
+
+// sign returns a token for c, of the form base64(claims).base64(hmac).
+func (a *auth) sign(c *claims) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (a *auth) verify(token string) (*claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, errors.New("malformed token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(parts[0]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errors.New("invalid token signature")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	c := &claims{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > c.Expires {
+		return nil, errors.New("token expired")
+	}
+	return c, nil
+}
+
+// allows reports whether c grants want, which has a single action.
+func (c *claims) allows(want access) bool {
+	for _, got := range c.Access {
+		if got.Type != want.Type || got.Name != want.Name {
+			continue
+		}
+		for _, action := range got.Actions {
+			if action == "*" || action == want.Actions[0] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a access) String() string {
+	return a.Type + ":" + a.Name + ":" + strings.Join(a.Actions, ",")
+}
+
+// required returns the access needed to serve req, or false if any
+// authenticated client may proceed.
+func required(req *http.Request) (access, bool) {
+	if isCatalog(req) {
+		return access{Type: "registry", Name: "catalog", Actions: []string{"*"}}, true
+	}
+
+	repo, ok := repository(req)
+	if !ok {
+		return access{}, false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return access{Type: "repository", Name: repo, Actions: []string{"pull"}}, true
+	case http.MethodDelete:
+		return access{Type: "repository", Name: repo, Actions: []string{"delete"}}, true
+	default:
+		return access{Type: "repository", Name: repo, Actions: []string{"push"}}, true
+	}
+}
+
+func realm(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host + tokenPath
+}
+
+// parseScope parses a scope like "repository:foo/bar:pull,push". Repository
+// names can't contain colons, but the type and actions are split off the ends
+// anyway to be lenient about hosts with ports.
+func parseScope(s string) (access, bool) {
+	first, last := strings.Index(s, ":"), strings.LastIndex(s, ":")
+	if first < 0 || first == last {
+		return access{}, false
+	}
+	return access{
+		Type:    s[:first],
+		Name:    s[first+1 : last],
+		Actions: strings.Split(s[last+1:], ","),
+	}, true
+}
+
+// https://docs.docker.com/registry/spec/auth/token/#requesting-a-token
+// https://docs.docker.com/registry/spec/auth/oauth/
+func (a *auth) handleToken(resp http.ResponseWriter, req *http.Request) *regError {
+	var (
+		user    string
+		scopes  []string
+		refresh bool
+	)
+	switch req.Method {
+	case http.MethodGet:
+		if u, p, ok := req.BasicAuth(); ok {
+			if !a.valid(u, p) {
+				return regErrUnauthorized
+			}
+			user = u
+		} else if a.users != nil {
+			return regErrUnauthorized
+		}
+		scopes = req.URL.Query()["scope"]
+	case http.MethodPost:
+		if err := req.ParseForm(); err != nil {
+			return &regError{
+				Status:  http.StatusBadRequest,
+				Code:    "UNSUPPORTED",
+				Message: err.Error(),
+			}
+		}
+		switch req.PostForm.Get("grant_type") {
+		case "password":
+			user = req.PostForm.Get("username")
+			if !a.valid(user, req.PostForm.Get("password")) {
+				return regErrUnauthorized
+			}
+			refresh = true
+		case "refresh_token":
+			c, err := a.verify(req.PostForm.Get("refresh_token"))
+			if err != nil || !c.Refresh {
+				return regErrUnauthorized
+			}
+			user = c.Subject
+		default:
+			return &regError{
+				Status:  http.StatusBadRequest,
+				Code:    "UNSUPPORTED",
+				Message: "unsupported grant_type",
+			}
+		}
+		scopes = req.PostForm["scope"]
+	default:
+		return &regError{
+			Status:  http.StatusMethodNotAllowed,
+			Code:    "METHOD_UNKNOWN",
+			Message: "We don't understand your method + url",
+		}
+	}
+
+	now := time.Now()
+	c := &claims{
+		Subject: user,
+		Expires: now.Add(a.expiry).Unix(),
+	}
+	for _, s := range scopes {
+		// The oauth flow joins scopes with spaces.
+		for _, s := range strings.Fields(s) {
+			want, ok := parseScope(s)
+			if !ok {
+				continue
+			}
+			if granted := a.grant(user, want); len(granted.Actions) != 0 {
+				c.Access = append(c.Access, granted)
+			}
+		}
+	}
+	token, err := a.sign(c)
+	if err != nil {
+		return regErrInternal(err)
+	}
+
+	body := map[string]interface{}{
+		"token":        token,
+		"access_token": token,
+		"expires_in":   int64(a.expiry / time.Second),
+		"issued_at":    now.UTC().Format(time.RFC3339),
+	}
+	if refresh {
+		rt, err := a.sign(&claims{
+			Subject: user,
+			Expires: now.Add(24 * time.Hour).Unix(),
+			Refresh: true,
+		})
+		if err != nil {
+			return regErrInternal(err)
+		}
+		body["refresh_token"] = rt
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(resp).Encode(body); err != nil {
+		return regErrInternal(err)
+	}
+	return nil
+}
+
+// grant returns the subset of want's actions that user is allowed.
+//
+// Clients like go-containerregistry's ask for push access to delete, so
+// tokens with push access also get delete access, but only if user is
+// allowed to delete.
+func (a *auth) grant(user string, want access) access {
+	granted := access{Type: want.Type, Name: want.Name}
+	for _, action := range want.Actions {
+		if a.permits(user, access{Type: want.Type, Name: want.Name, Actions: []string{action}}) {
+			granted.Actions = append(granted.Actions, action)
+		}
+	}
+	if contains(granted.Actions, "push") && !contains(want.Actions, "delete") &&
+		a.permits(user, access{Type: want.Type, Name: want.Name, Actions: []string{"delete"}}) {
+		granted.Actions = append(granted.Actions, "delete")
+	}
+	return granted
+}
+
+// permits reports whether user may perform want, which has a single action.
+func (a *auth) permits(user string, want access) bool {
+	action := want.Actions[0]
+	if want.Type == "repository" && len(a.tenants) != 0 && !permitted(a.tenants, user, want.Name, action) {
+		return false
+	}
+	return a.authorize == nil || a.authorize(user, want.Name, action)
+}
+
+// allows reports whether the client that made req, having authenticated as
+// user, may perform action on repo.
+func (a *auth) allows(req *http.Request, user, repo, action string) bool {
+	want := access{Type: "repository", Name: repo, Actions: []string{action}}
+	if !a.token {
+		return a.permits(user, want)
+	}
+	c, err := a.parse(req)
+	return err == nil && c.allows(want)
+}
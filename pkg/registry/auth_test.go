@@ -0,0 +1,226 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+var users = map[string]string{"alice": "secret", "bob": "hunter2"}
+
+func authServer(t *testing.T, opt registry.Option) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)), opt))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func push(t *testing.T, s *httptest.Server, repo string, auth authn.Authenticator) error {
+	t.Helper()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/%s:latest", u.Host, repo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img, remote.WithAuth(auth)); err != nil {
+		return err
+	}
+	_, err = remote.Image(ref, remote.WithAuth(auth))
+	return err
+}
+
+func wantStatus(t *testing.T, err error, code int) {
+	t.Helper()
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		t.Fatalf("got %v, want status %d", err, code)
+	}
+	if terr.StatusCode != code {
+		t.Errorf("got status %d, want %d", terr.StatusCode, code)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	s := authServer(t, registry.WithBasicAuth(users))
+
+	if err := push(t, s, "foo", &authn.Basic{Username: "alice", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(t, push(t, s, "foo", &authn.Basic{Username: "alice", Password: "wrong"}), http.StatusUnauthorized)
+	wantStatus(t, push(t, s, "foo", authn.Anonymous), http.StatusUnauthorized)
+
+	resp, err := http.Get(s.URL + "/v2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, want := resp.Header.Get("WWW-Authenticate"), `Basic realm="registry"`; got != want {
+		t.Errorf("WWW-Authenticate = %q, want %q", got, want)
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	s := authServer(t, registry.WithTokenAuth(registry.TokenAuth{
+		Users: users,
+		Authorize: func(user, repo, action string) bool {
+			// Everyone can pull; only alice can push, and bob only to his own repos.
+			return action == "pull" || user == "alice" || strings.HasPrefix(repo, user+"/")
+		},
+	}))
+
+	alice := &authn.Basic{Username: "alice", Password: "secret"}
+	bob := &authn.Basic{Username: "bob", Password: "hunter2"}
+
+	if err := push(t, s, "foo", alice); err != nil {
+		t.Fatal(err)
+	}
+	if err := push(t, s, "bob/foo", bob); err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(t, push(t, s, "foo", bob), http.StatusUnauthorized)
+	wantStatus(t, push(t, s, "foo", &authn.Basic{Username: "bob", Password: "wrong"}), http.StatusUnauthorized)
+	wantStatus(t, push(t, s, "foo", authn.Anonymous), http.StatusUnauthorized)
+
+	// Bob can pull what alice pushed.
+	u, _ := url.Parse(s.URL)
+	ref, err := name.ParseReference(u.Host + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Image(ref, remote.WithAuth(bob)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The catalog is off limits unless Authorize allows it.
+	if _, err := remote.Catalog(context.Background(), ref.Context().Registry, remote.WithAuth(bob)); err == nil {
+		t.Error("Catalog() succeeded without catalog access")
+	}
+	if _, err := remote.Catalog(context.Background(), ref.Context().Registry, remote.WithAuth(alice)); err != nil {
+		t.Errorf("Catalog() = %v", err)
+	}
+}
+
+func TestTokenAuthOauth(t *testing.T) {
+	s := authServer(t, registry.WithTokenAuth(registry.TokenAuth{Users: users}))
+
+	// A password grant hands out a refresh token, which the transport then
+	// uses via the refresh_token grant.
+	resp, err := http.PostForm(s.URL+"/token", url.Values{
+		"grant_type": {"password"},
+		"username":   {"alice"},
+		"password":   {"secret"},
+		"service":    {"registry"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /token = %d: %s", resp.StatusCode, body)
+	}
+	i := strings.Index(string(body), `"refresh_token":"`)
+	if i < 0 {
+		t.Fatalf("no refresh_token in %s", body)
+	}
+	rt := strings.SplitN(string(body[i+len(`"refresh_token":"`):]), `"`, 2)[0]
+
+	if err := push(t, s, "foo", authn.FromConfig(authn.AuthConfig{IdentityToken: rt})); err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(t, push(t, s, "foo", authn.FromConfig(authn.AuthConfig{IdentityToken: "bogus"})), http.StatusUnauthorized)
+}
+
+func TestTokenAuthAnonymous(t *testing.T) {
+	s := authServer(t, registry.WithTokenAuth(registry.TokenAuth{}))
+
+	if err := push(t, s, "foo", authn.Anonymous); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests without a token are challenged with the scope they need.
+	resp, err := http.Get(s.URL + "/v2/foo/manifests/latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if got, want := resp.Header.Get("WWW-Authenticate"), `scope="repository:foo:pull"`; !strings.Contains(got, want) {
+		t.Errorf("WWW-Authenticate = %q, want it to contain %q", got, want)
+	}
+}
+
+func TestTokenAuthPushWithoutDelete(t *testing.T) {
+	s := authServer(t, registry.WithTokenAuth(registry.TokenAuth{
+		Users: users,
+		Authorize: func(user, repo, action string) bool {
+			// Only alice can delete.
+			return action != "delete" || user == "alice"
+		},
+	}))
+	alice := &authn.Basic{Username: "alice", Password: "secret"}
+	bob := &authn.Basic{Username: "bob", Password: "hunter2"}
+
+	u, _ := url.Parse(s.URL)
+	for _, repo := range []string{"alice", "bob"} {
+		auth := alice
+		if repo == "bob" {
+			auth = bob
+		}
+		if err := push(t, s, repo, auth); err != nil {
+			t.Fatal(err)
+		}
+		ref, err := name.ParseReference(u.Host + "/" + repo + ":latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = remote.Delete(ref, remote.WithAuth(auth))
+		if repo == "alice" && err != nil {
+			t.Errorf("Delete() as alice = %v", err)
+		}
+		if repo == "bob" {
+			// Bob's token lets him push, but not delete.
+			wantStatus(t, err, http.StatusUnauthorized)
+		}
+	}
+}
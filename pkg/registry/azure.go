@@ -0,0 +1,246 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// azureVersion is the Blob service REST API version requests use.
+const azureVersion = "2020-10-02"
+
+// AzureConfig configures an Azure Blob Storage ObjectStore.
+type AzureConfig struct {
+	// Account and Container name the storage account and the container in
+	// it. The container must already exist.
+	Account   string
+	Container string
+
+	// SAS is a shared access signature for the container, without the
+	// leading "?", which authorizes requests. It needs read, write, delete
+	// and list permissions.
+	SAS string
+
+	// Endpoint is the URL of the Blob service, which defaults to
+	// https://<Account>.blob.core.windows.net. Set it to use an emulator.
+	Endpoint string
+
+	// Client is the HTTP client to use. It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type azureStore struct {
+	cfg AzureConfig
+}
+
+// NewAzureStore returns an ObjectStore backed by an Azure Blob Storage
+// container. Uploads of unknown size are streamed as blocks, buffering one
+// block at a time.
+func NewAzureStore(cfg AzureConfig) ObjectStore {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", cfg.Account)
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	cfg.SAS = strings.TrimPrefix(cfg.SAS, "?")
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &azureStore{cfg: cfg}
+}
+
+// url returns the URL of the blob with the given key, or of the container if
+// key is empty, with the SAS and query.
+func (a *azureStore) url(key string, query url.Values) string {
+	u := a.cfg.Endpoint + "/" + url.PathEscape(a.cfg.Container)
+	if key != "" {
+		parts := strings.Split(key, "/")
+		for i, p := range parts {
+			parts[i] = url.PathEscape(p)
+		}
+		u += "/" + strings.Join(parts, "/")
+	}
+	q := query.Encode()
+	if a.cfg.SAS != "" {
+		if q != "" {
+			q += "&"
+		}
+		q += a.cfg.SAS
+	}
+	if q != "" {
+		u += "?" + q
+	}
+	return u
+}
+
+func (a *azureStore) request(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, a.url(key, query), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	return req, nil
+}
+
+func (a *azureStore) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	req, err := a.request(ctx, http.MethodGet, key, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := rangeHeader(offset, length); r != "" {
+		req.Header.Set("x-ms-range", r)
+	}
+	resp, err := do(a.cfg.Client, req, http.StatusOK, http.StatusPartialContent)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (a *azureStore) Stat(ctx context.Context, key string) (int64, error) {
+	req, err := a.request(ctx, http.MethodHead, key, nil, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := do(a.cfg.Client, req, http.StatusOK)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+func (a *azureStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if size < 0 {
+		chunk, done, err := firstChunk(r)
+		if err != nil {
+			return err
+		}
+		if !done {
+			return a.putBlocks(ctx, key, r, chunk)
+		}
+		r, size = bytes.NewReader(chunk), int64(len(chunk))
+	}
+	req, err := a.request(ctx, http.MethodPut, key, nil, r, size)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	resp, err := do(a.cfg.Client, req, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// putBlocks streams r as blocks of a block blob, one chunk per block, then
+// commits them.
+func (a *azureStore) putBlocks(ctx context.Context, key string, r io.Reader, first []byte) error {
+	var ids []string
+	if err := chunks(r, first, func(n int, chunk []byte) error {
+		// Block IDs must all be the same length.
+		id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", n)))
+		q := url.Values{"comp": {"block"}, "blockid": {id}}
+		req, err := a.request(ctx, http.MethodPut, key, q, bytes.NewReader(chunk), int64(len(chunk)))
+		if err != nil {
+			return err
+		}
+		resp, err := do(a.cfg.Client, req, http.StatusCreated)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		ids = append(ids, id)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{Latest: ids})
+	if err != nil {
+		return err
+	}
+	req, err := a.request(ctx, http.MethodPut, key, url.Values{"comp": {"blocklist"}}, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	resp, err := do(a.cfg.Client, req, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (a *azureStore) Delete(ctx context.Context, key string) error {
+	req, err := a.request(ctx, http.MethodDelete, key, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp, err := do(a.cfg.Client, req, http.StatusAccepted)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (a *azureStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	marker := ""
+	for {
+		q := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		req, err := a.request(ctx, http.MethodGet, "", q, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := do(a.cfg.Client, req, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Blobs []struct {
+				Name string
+			} `xml:"Blobs>Blob"`
+			NextMarker string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", prefix, err)
+		}
+		for _, b := range result.Blobs {
+			keys = append(keys, b.Name)
+		}
+		if result.NextMarker == "" {
+			return keys, nil
+		}
+		marker = result.NextMarker
+	}
+}
@@ -49,24 +49,24 @@ func isBlob(req *http.Request) bool {
 		elem[len(elem)-2] == "uploads")
 }
 
-// blobHandler represents a minimal blob storage backend, capable of serving
+// BlobHandler represents a minimal blob storage backend, capable of serving
 // blob contents.
-type blobHandler interface {
-	// Get gets the blob contents, or errNotFound if the blob wasn't found.
+type BlobHandler interface {
+	// Get gets the blob contents, or ErrNotFound if the blob wasn't found.
 	Get(ctx context.Context, repo string, h v1.Hash) (io.ReadCloser, error)
 }
 
-// blobStatHandler is an extension interface representing a blob storage
+// BlobStatHandler is an extension interface representing a blob storage
 // backend that can serve metadata about blobs.
-type blobStatHandler interface {
-	// Stat returns the size of the blob, or errNotFound if the blob wasn't
-	// found, or redirectError if the blob can be found elsewhere.
+type BlobStatHandler interface {
+	// Stat returns the size of the blob, or ErrNotFound if the blob wasn't
+	// found, or RedirectError if the blob can be found elsewhere.
 	Stat(ctx context.Context, repo string, h v1.Hash) (int64, error)
 }
 
-// blobPutHandler is an extension interface representing a blob storage backend
+// BlobPutHandler is an extension interface representing a blob storage backend
 // that can write blob contents.
-type blobPutHandler interface {
+type BlobPutHandler interface {
 	// Put puts the blob contents.
 	//
 	// The contents will be verified against the expected size and digest
@@ -76,10 +76,25 @@ type blobPutHandler interface {
 	Put(ctx context.Context, repo string, h v1.Hash, rc io.ReadCloser) error
 }
 
-// redirectError represents a signal that the blob handler doesn't have the blob
+// BlobDeleteHandler is an extension interface representing a blob storage
+// backend that can delete blobs.
+type BlobDeleteHandler interface {
+	// Delete deletes the blob contents, or returns ErrNotFound if the blob
+	// wasn't found.
+	Delete(ctx context.Context, repo string, h v1.Hash) error
+}
+
+// BlobListHandler is an extension interface representing a blob storage
+// backend that can enumerate the blobs it has, which GarbageCollect needs.
+type BlobListHandler interface {
+	// List returns the digests of all blobs.
+	List(ctx context.Context) ([]v1.Hash, error)
+}
+
+// RedirectError represents a signal that the blob handler doesn't have the blob
 // contents, but that those contents are at another location which registry
 // clients should redirect to.
-type redirectError struct {
+type RedirectError struct {
 	// Location is the location to find the contents.
 	Location string
 
@@ -87,10 +102,11 @@ type redirectError struct {
 	Code int
 }
 
-func (e redirectError) Error() string { return fmt.Sprintf("redirecting (%d): %s", e.Code, e.Location) }
+func (e RedirectError) Error() string { return fmt.Sprintf("redirecting (%d): %s", e.Code, e.Location) }
 
-// errNotFound represents an error locating the blob.
-var errNotFound = errors.New("not found")
+// ErrNotFound is returned by storage backends when a blob or manifest doesn't
+// exist.
+var ErrNotFound = errors.New("not found")
 
 type memHandler struct {
 	m    map[string][]byte
@@ -103,7 +119,7 @@ func (m *memHandler) Stat(_ context.Context, _ string, h v1.Hash) (int64, error)
 
 	b, found := m.m[h.String()]
 	if !found {
-		return 0, errNotFound
+		return 0, ErrNotFound
 	}
 	return int64(len(b)), nil
 }
@@ -113,7 +129,7 @@ func (m *memHandler) Get(_ context.Context, _ string, h v1.Hash) (io.ReadCloser,
 
 	b, found := m.m[h.String()]
 	if !found {
-		return nil, errNotFound
+		return nil, ErrNotFound
 	}
 	return ioutil.NopCloser(bytes.NewReader(b)), nil
 }
@@ -130,13 +146,51 @@ func (m *memHandler) Put(_ context.Context, _ string, h v1.Hash, rc io.ReadClose
 	return nil
 }
 
+func (m *memHandler) Delete(_ context.Context, _ string, h v1.Hash) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, found := m.m[h.String()]; !found {
+		return ErrNotFound
+	}
+	delete(m.m, h.String())
+	return nil
+}
+
+func (m *memHandler) List(context.Context) ([]v1.Hash, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	hs := make([]v1.Hash, 0, len(m.m))
+	for k := range m.m {
+		h, err := v1.NewHash(k)
+		if err != nil {
+			return nil, err
+		}
+		hs = append(hs, h)
+	}
+	return hs, nil
+}
+
 // blobs
 type blobs struct {
-	blobHandler blobHandler
+	blobHandler BlobHandler
+	events      *notifier
+	maxSize     int64
+
+	// auth, if set, decides whether blobs may be mounted from other
+	// repositories.
+	auth        *auth
+	mountPolicy MountPolicy
 
 	// Each upload gets a unique id that writes occur to until finalized.
-	uploads map[string][]byte
-	lock    sync.Mutex
+	uploads  uploadStore
+	minChunk int64
+	lock     sync.Mutex
+
+	// recent, if set, records blobs used by pushes, which garbage
+	// collection leaves alone for a while.
+	recent *recentBlobs
 }
 
 func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
@@ -160,6 +214,10 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 
 	repo := req.URL.Host + path.Join(elem[1:len(elem)-2]...)
 
+	if service == "uploads" && (req.Method == http.MethodGet || req.Method == http.MethodDelete) {
+		return b.handleUpload(resp, req, path.Join(elem[1:len(elem)-3]...), target)
+	}
+
 	switch req.Method {
 	case http.MethodHead:
 		h, err := v1.NewHash(target)
@@ -172,12 +230,12 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		}
 
 		var size int64
-		if bsh, ok := b.blobHandler.(blobStatHandler); ok {
+		if bsh, ok := b.blobHandler.(BlobStatHandler); ok {
 			size, err = bsh.Stat(req.Context(), repo, h)
-			if errors.Is(err, errNotFound) {
+			if errors.Is(err, ErrNotFound) {
 				return regErrBlobUnknown
 			} else if err != nil {
-				var rerr redirectError
+				var rerr RedirectError
 				if errors.As(err, &rerr) {
 					http.Redirect(resp, req, rerr.Location, rerr.Code)
 					return nil
@@ -186,10 +244,10 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 		} else {
 			rc, err := b.blobHandler.Get(req.Context(), repo, h)
-			if errors.Is(err, errNotFound) {
+			if errors.Is(err, ErrNotFound) {
 				return regErrBlobUnknown
 			} else if err != nil {
-				var rerr redirectError
+				var rerr RedirectError
 				if errors.As(err, &rerr) {
 					http.Redirect(resp, req, rerr.Location, rerr.Code)
 					return nil
@@ -203,6 +261,7 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 		}
 
+		b.recent.touch(h)
 		resp.Header().Set("Content-Length", fmt.Sprint(size))
 		resp.Header().Set("Docker-Content-Digest", h.String())
 		resp.WriteHeader(http.StatusOK)
@@ -220,12 +279,12 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 
 		var size int64
 		var r io.Reader
-		if bsh, ok := b.blobHandler.(blobStatHandler); ok {
+		if bsh, ok := b.blobHandler.(BlobStatHandler); ok {
 			size, err = bsh.Stat(req.Context(), repo, h)
-			if errors.Is(err, errNotFound) {
+			if errors.Is(err, ErrNotFound) {
 				return regErrBlobUnknown
 			} else if err != nil {
-				var rerr redirectError
+				var rerr RedirectError
 				if errors.As(err, &rerr) {
 					http.Redirect(resp, req, rerr.Location, rerr.Code)
 					return nil
@@ -234,10 +293,10 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 
 			rc, err := b.blobHandler.Get(req.Context(), repo, h)
-			if errors.Is(err, errNotFound) {
+			if errors.Is(err, ErrNotFound) {
 				return regErrBlobUnknown
 			} else if err != nil {
-				var rerr redirectError
+				var rerr RedirectError
 				if errors.As(err, &rerr) {
 					http.Redirect(resp, req, rerr.Location, rerr.Code)
 					return nil
@@ -249,10 +308,10 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			r = rc
 		} else {
 			tmp, err := b.blobHandler.Get(req.Context(), repo, h)
-			if errors.Is(err, errNotFound) {
+			if errors.Is(err, ErrNotFound) {
 				return regErrBlobUnknown
 			} else if err != nil {
-				var rerr redirectError
+				var rerr RedirectError
 				if errors.As(err, &rerr) {
 					http.Redirect(resp, req, rerr.Location, rerr.Code)
 					return nil
@@ -267,6 +326,7 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			r = &buf
 		}
 
+		b.notify(req, EventActionPull, path.Join(elem[1:len(elem)-2]...), h, size)
 		resp.Header().Set("Content-Length", fmt.Sprint(size))
 		resp.Header().Set("Docker-Content-Digest", h.String())
 		resp.WriteHeader(http.StatusOK)
@@ -274,7 +334,7 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		return nil
 
 	case http.MethodPost:
-		bph, ok := b.blobHandler.(blobPutHandler)
+		bph, ok := b.blobHandler.(BlobPutHandler)
 		if !ok {
 			return regErrUnsupported
 		}
@@ -289,33 +349,64 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 		}
 
+		if mount, from := req.URL.Query().Get("mount"), req.URL.Query().Get("from"); mount != "" && from != "" {
+			h, err := v1.NewHash(mount)
+			if err != nil {
+				return regErrDigestInvalid
+			}
+			if ok, rerr := b.mount(resp, req, path.Join(elem[1:len(elem)-2]...), from, h); ok || rerr != nil {
+				return rerr
+			}
+			// Otherwise, fall back to starting an upload, like other
+			// registries do.
+		}
+
 		if digest != "" {
 			h, err := v1.NewHash(digest)
 			if err != nil {
 				return regErrDigestInvalid
 			}
 
-			vrc, err := verify.ReadCloser(req.Body, req.ContentLength, h)
+			if b.maxSize > 0 && req.ContentLength > b.maxSize {
+				return b.tooLarge()
+			}
+			body := struct {
+				io.Reader
+				io.Closer
+			}{b.limit(req.Body, 0), req.Body}
+			vrc, err := verify.ReadCloser(body, req.ContentLength, h)
 			if err != nil {
 				return regErrInternal(err)
 			}
 			defer vrc.Close()
 
 			if err = bph.Put(req.Context(), repo, h, vrc); err != nil {
+				if errors.Is(err, errTooLarge) {
+					return b.tooLarge()
+				}
 				if errors.As(err, &verify.Error{}) {
 					log.Printf("Digest mismatch: %v", err)
 					return regErrDigestMismatch
 				}
 				return regErrInternal(err)
 			}
+			b.recent.touch(h)
+			b.notify(req, EventActionPush, path.Join(elem[1:len(elem)-2]...), h, req.ContentLength)
 			resp.Header().Set("Docker-Content-Digest", h.String())
 			resp.WriteHeader(http.StatusCreated)
 			return nil
 		}
 
 		id := fmt.Sprint(rand.Int63())
-		resp.Header().Set("Location", "/"+path.Join("v2", path.Join(elem[1:len(elem)-2]...), "blobs/uploads", id))
-		resp.Header().Set("Range", "0-0")
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		if err := b.uploads.create(id); err != nil {
+			return regErrInternal(err)
+		}
+		setUploadStatus(resp, path.Join(elem[1:len(elem)-2]...), id, 0)
+		if b.minChunk > 0 {
+			resp.Header().Set("OCI-Chunk-Min-Length", fmt.Sprint(b.minChunk))
+		}
 		resp.WriteHeader(http.StatusAccepted)
 		return nil
 
@@ -328,8 +419,17 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 		}
 
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		size, err := b.uploads.size(target)
+		if errors.Is(err, ErrNotFound) {
+			size = 0
+		} else if err != nil {
+			return regErrInternal(err)
+		}
+
 		if contentRange != "" {
-			start, end := 0, 0
+			var start, end int64
 			if _, err := fmt.Sscanf(contentRange, "%d-%d", &start, &end); err != nil {
 				return &regError{
 					Status:  http.StatusRequestedRangeNotSatisfiable,
@@ -337,27 +437,21 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 					Message: "We don't understand your Content-Range",
 				}
 			}
-			b.lock.Lock()
-			defer b.lock.Unlock()
-			if start != len(b.uploads[target]) {
+			if start != size {
 				return &regError{
 					Status:  http.StatusRequestedRangeNotSatisfiable,
 					Code:    "BLOB_UPLOAD_UNKNOWN",
 					Message: "Your content range doesn't match what we have",
 				}
 			}
-			l := bytes.NewBuffer(b.uploads[target])
-			io.Copy(l, req.Body)
-			b.uploads[target] = l.Bytes()
-			resp.Header().Set("Location", "/"+path.Join("v2", path.Join(elem[1:len(elem)-3]...), "blobs/uploads", target))
-			resp.Header().Set("Range", fmt.Sprintf("0-%d", len(l.Bytes())-1))
-			resp.WriteHeader(http.StatusNoContent)
-			return nil
-		}
-
-		b.lock.Lock()
-		defer b.lock.Unlock()
-		if _, ok := b.uploads[target]; ok {
+			if b.minChunk > 0 && end-start+1 < b.minChunk {
+				return &regError{
+					Status:  http.StatusRequestedRangeNotSatisfiable,
+					Code:    "BLOB_UPLOAD_INVALID",
+					Message: fmt.Sprintf("Chunks must be at least %d bytes, except the last, which must be sent with the PUT", b.minChunk),
+				}
+			}
+		} else if size > 0 {
 			return &regError{
 				Status:  http.StatusBadRequest,
 				Code:    "BLOB_UPLOAD_INVALID",
@@ -365,17 +459,18 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 		}
 
-		l := &bytes.Buffer{}
-		io.Copy(l, req.Body)
-
-		b.uploads[target] = l.Bytes()
-		resp.Header().Set("Location", "/"+path.Join("v2", path.Join(elem[1:len(elem)-3]...), "blobs/uploads", target))
-		resp.Header().Set("Range", fmt.Sprintf("0-%d", len(l.Bytes())-1))
+		cr := &countingReader{r: b.limit(req.Body, size)}
+		if err := b.uploads.append(target, cr); errors.Is(err, errTooLarge) {
+			return b.tooLarge()
+		} else if err != nil {
+			return regErrInternal(err)
+		}
+		setUploadStatus(resp, path.Join(elem[1:len(elem)-3]...), target, size+cr.n)
 		resp.WriteHeader(http.StatusNoContent)
 		return nil
 
 	case http.MethodPut:
-		bph, ok := b.blobHandler.(blobPutHandler)
+		bph, ok := b.blobHandler.(BlobPutHandler)
 		if !ok {
 			return regErrUnsupported
 		}
@@ -396,10 +491,23 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 		}
 
-		b.lock.Lock()
-		defer b.lock.Unlock()
+		h, n, rerr := b.finishUpload(req, bph, repo, target, digest)
+		if rerr != nil {
+			return rerr
+		}
+		b.recent.touch(h)
+		b.notify(req, EventActionPush, path.Join(elem[1:len(elem)-3]...), h, n)
+		resp.Header().Set("Docker-Content-Digest", h.String())
+		resp.WriteHeader(http.StatusCreated)
+		return nil
 
-		h, err := v1.NewHash(digest)
+	case http.MethodDelete:
+		bdh, ok := b.blobHandler.(BlobDeleteHandler)
+		if !ok {
+			return regErrUnsupported
+		}
+
+		h, err := v1.NewHash(target)
 		if err != nil {
 			return &regError{
 				Status:  http.StatusBadRequest,
@@ -407,32 +515,13 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 				Message: "invalid digest",
 			}
 		}
-
-		defer req.Body.Close()
-		in := ioutil.NopCloser(io.MultiReader(bytes.NewBuffer(b.uploads[target]), req.Body))
-
-		size := int64(verify.SizeUnknown)
-		if req.ContentLength > 0 {
-			size = int64(len(b.uploads[target])) + req.ContentLength
-		}
-
-		vrc, err := verify.ReadCloser(in, size, h)
-		if err != nil {
+		if err := bdh.Delete(req.Context(), repo, h); errors.Is(err, ErrNotFound) {
+			return regErrBlobUnknown
+		} else if err != nil {
 			return regErrInternal(err)
 		}
-		defer vrc.Close()
-
-		if err := bph.Put(req.Context(), repo, h, vrc); err != nil {
-			if errors.As(err, &verify.Error{}) {
-				log.Printf("Digest mismatch: %v", err)
-				return regErrDigestMismatch
-			}
-			return regErrInternal(err)
-		}
-
-		delete(b.uploads, target)
-		resp.Header().Set("Docker-Content-Digest", h.String())
-		resp.WriteHeader(http.StatusCreated)
+		b.notify(req, EventActionDelete, path.Join(elem[1:len(elem)-2]...), h, 0)
+		resp.WriteHeader(http.StatusAccepted)
 		return nil
 
 	default:
@@ -443,3 +532,111 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		}
 	}
 }
+
+// finishUpload stores the upload target, completed by req's body, as the blob
+// digest, returning its digest and the number of bytes read. Events are sent
+// by the caller once the lock is released.
+func (b *blobs) finishUpload(req *http.Request, bph BlobPutHandler, repo, target, digest string) (v1.Hash, int64, *regError) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	h, err := v1.NewHash(digest)
+	if err != nil {
+		return v1.Hash{}, 0, &regError{
+			Status:  http.StatusBadRequest,
+			Code:    "NAME_INVALID",
+			Message: "invalid digest",
+		}
+	}
+
+	defer req.Body.Close()
+	uploaded, err := b.uploads.open(target)
+	if err != nil {
+		return v1.Hash{}, 0, regErrInternal(err)
+	}
+	defer uploaded.Close()
+	cr := &countingReader{r: b.limit(io.MultiReader(uploaded, req.Body), 0)}
+	in := ioutil.NopCloser(cr)
+
+	size := int64(verify.SizeUnknown)
+	if req.ContentLength > 0 {
+		have, err := b.uploads.size(target)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return v1.Hash{}, 0, regErrInternal(err)
+		}
+		size = have + req.ContentLength
+	}
+
+	vrc, err := verify.ReadCloser(in, size, h)
+	if err != nil {
+		return v1.Hash{}, 0, regErrInternal(err)
+	}
+	defer vrc.Close()
+
+	if err := bph.Put(req.Context(), repo, h, vrc); err != nil {
+		if errors.Is(err, errTooLarge) {
+			return v1.Hash{}, 0, b.tooLarge()
+		}
+		if errors.As(err, &verify.Error{}) {
+			log.Printf("Digest mismatch: %v", err)
+			return v1.Hash{}, 0, regErrDigestMismatch
+		}
+		return v1.Hash{}, 0, regErrInternal(err)
+	}
+
+	if err := b.uploads.delete(target); err != nil {
+		return v1.Hash{}, 0, regErrInternal(err)
+	}
+	return h, cr.n, nil
+}
+
+// notify sends an event about the blob h in repo.
+func (b *blobs) notify(req *http.Request, action, repo string, h v1.Hash, size int64) {
+	target := EventTarget{
+		Repository: repo,
+		Digest:     h.String(),
+	}
+	if action != EventActionDelete {
+		target.MediaType = "application/octet-stream"
+		target.Size = size
+	}
+	b.events.notify(req, action, "blobs", target)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// handleUpload reports the status of, or cancels, the upload with the given
+// id.
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pushing-a-blob-in-chunks
+func (b *blobs) handleUpload(resp http.ResponseWriter, req *http.Request, repo, id string) *regError {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	size, err := b.uploads.size(id)
+	if errors.Is(err, ErrNotFound) {
+		return regErrUploadUnknown
+	} else if err != nil {
+		return regErrInternal(err)
+	}
+
+	if req.Method == http.MethodDelete {
+		if err := b.uploads.delete(id); err != nil {
+			return regErrInternal(err)
+		}
+		resp.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	setUploadStatus(resp, repo, id, size)
+	resp.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// notModified sets the ETag and Last-Modified headers of a response for a
+// manifest with the given digest and modification time, and reports whether
+// the client's cached copy, as described by req's conditional headers, is
+// still fresh.
+//
+// See https://www.rfc-editor.org/rfc/rfc7232.
+func notModified(resp http.ResponseWriter, req *http.Request, digest string, modified time.Time) bool {
+	etag := `"` + digest + `"`
+	resp.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		resp.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since.
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		// Last-Modified only has second granularity.
+		return !modified.Truncate(time.Second).After(since)
+	}
+	return false
+}
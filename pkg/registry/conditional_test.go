@@ -0,0 +1,123 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opts []registry.Option
+	}{{
+		desc: "memory",
+	}, {
+		desc: "disk",
+		opts: []registry.Option{registry.WithStorageDir(t.TempDir())},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := httptest.NewServer(registry.New(append(tc.opts, quiet())...))
+			defer s.Close()
+
+			d, _ := pushRandom(t, s, "foo:latest")
+			etag := `"` + d.DigestStr() + `"`
+			u := s.URL + "/v2/foo/manifests/latest"
+
+			resp, err := http.Get(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := resp.Header.Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			modified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+			if err != nil {
+				t.Fatalf("Last-Modified: %v", err)
+			}
+			before := modified.Add(-time.Second).Format(http.TimeFormat)
+			after := modified.Add(time.Second).Format(http.TimeFormat)
+
+			for _, cc := range []struct {
+				desc   string
+				header map[string]string
+				want   int
+			}{{
+				desc:   "matching etag",
+				header: map[string]string{"If-None-Match": etag},
+				want:   http.StatusNotModified,
+			}, {
+				desc:   "one of several etags",
+				header: map[string]string{"If-None-Match": `"sha256:abc", W/` + etag},
+				want:   http.StatusNotModified,
+			}, {
+				desc:   "any etag",
+				header: map[string]string{"If-None-Match": "*"},
+				want:   http.StatusNotModified,
+			}, {
+				desc:   "stale etag",
+				header: map[string]string{"If-None-Match": `"sha256:abc"`},
+				want:   http.StatusOK,
+			}, {
+				desc:   "unmodified since",
+				header: map[string]string{"If-Modified-Since": after},
+				want:   http.StatusNotModified,
+			}, {
+				desc:   "modified since",
+				header: map[string]string{"If-Modified-Since": before},
+				want:   http.StatusOK,
+			}, {
+				desc:   "etag takes precedence",
+				header: map[string]string{"If-None-Match": `"sha256:abc"`, "If-Modified-Since": after},
+				want:   http.StatusOK,
+			}} {
+				for _, method := range []string{http.MethodGet, http.MethodHead} {
+					req, err := http.NewRequest(method, u, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+					for k, v := range cc.header {
+						req.Header.Set(k, v)
+					}
+					resp, err := s.Client().Do(req)
+					if err != nil {
+						t.Fatal(err)
+					}
+					body, err := ioutil.ReadAll(resp.Body)
+					resp.Body.Close()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if resp.StatusCode != cc.want {
+						t.Errorf("%s %s: status = %d, want %d", cc.desc, method, resp.StatusCode, cc.want)
+					}
+					if resp.StatusCode == http.StatusNotModified && len(body) != 0 {
+						t.Errorf("%s %s: 304 had a body: %q", cc.desc, method, body)
+					}
+					if got := resp.Header.Get("ETag"); got != etag {
+						t.Errorf("%s %s: ETag = %q, want %q", cc.desc, method, got, etag)
+					}
+				}
+			}
+		})
+	}
+}
@@ -30,6 +30,7 @@ func TestDeps(t *testing.T) {
 
 			"github.com/google/go-containerregistry/internal/verify",
 			"github.com/google/go-containerregistry/internal/and",
+			"github.com/google/go-containerregistry/internal/sha256",
 		),
 	})
 }
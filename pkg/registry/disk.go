@@ -0,0 +1,314 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+type diskBlobs struct {
+	dir string
+}
+
+// NewDiskBlobHandler returns a BlobHandler that stores blobs in dir, named by
+// digest. Blobs are shared by all repositories, as they are in memory.
+func NewDiskBlobHandler(dir string) BlobHandler {
+	return &diskBlobs{dir: dir}
+}
+
+func (d *diskBlobs) path(h v1.Hash) string {
+	return filepath.Join(d.dir, h.Algorithm, h.Hex)
+}
+
+func (d *diskBlobs) Stat(_ context.Context, _ string, h v1.Hash) (int64, error) {
+	fi, err := os.Stat(d.path(h))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (d *diskBlobs) Get(_ context.Context, _ string, h v1.Hash) (io.ReadCloser, error) {
+	f, err := os.Open(d.path(h))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (d *diskBlobs) Put(_ context.Context, _ string, h v1.Hash, rc io.ReadCloser) error {
+	defer rc.Close()
+	return writeFile(d.path(h), rc)
+}
+
+func (d *diskBlobs) Delete(_ context.Context, _ string, h v1.Hash) error {
+	err := os.Remove(d.path(h))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (d *diskBlobs) List(context.Context) ([]v1.Hash, error) {
+	var hs []v1.Hash
+	algs, err := ioutil.ReadDir(d.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, alg := range algs {
+		if !alg.IsDir() {
+			continue
+		}
+		fis, err := ioutil.ReadDir(filepath.Join(d.dir, alg.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, fi := range fis {
+			// Skip in-progress writes.
+			if strings.HasPrefix(fi.Name(), ".") {
+				continue
+			}
+			h, err := v1.NewHash(alg.Name() + ":" + fi.Name())
+			if err != nil {
+				continue
+			}
+			hs = append(hs, h)
+		}
+	}
+	return hs, nil
+}
+
+// writeFile writes the contents of r to p atomically, so that readers never
+// see partial files, even if the write fails.
+func writeFile(p string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// manifestsDir is where a repository's manifests are kept, under the
+// repository's path. Repository path components can't start with "_", so it
+// can't collide with a nested repository.
+const manifestsDir = "_manifests"
+
+type diskManifests struct {
+	dir string
+}
+
+// NewDiskManifestStore returns a ManifestStore that stores manifests in dir,
+// in a directory per repository. Manifests are kept by tag in "tags" and by
+// digest in "digests".
+func NewDiskManifestStore(dir string) ManifestStore {
+	return &diskManifests{dir: dir}
+}
+
+// repoKey returns the slash-separated path of the repository's manifests in a
+// store, refusing paths that would escape it.
+func repoKey(repo string) (string, error) {
+	for _, part := range strings.Split(repo, "/") {
+		if part == "" || part == "." || part == ".." || strings.HasPrefix(part, "_") || strings.Contains(part, `\`) {
+			return "", fmt.Errorf("invalid repository name %q", repo)
+		}
+	}
+	return repo + "/" + manifestsDir, nil
+}
+
+// manifestKey returns the slash-separated path of a manifest in a store.
+func manifestKey(repo, ref string) (string, error) {
+	rk, err := repoKey(repo)
+	if err != nil {
+		return "", err
+	}
+	if h, err := v1.NewHash(ref); err == nil {
+		return rk + "/digests/" + h.Algorithm + "/" + h.Hex, nil
+	}
+	if ref == "" || ref == "." || ref == ".." || strings.ContainsAny(ref, `/\`) {
+		return "", fmt.Errorf("invalid reference %q", ref)
+	}
+	return rk + "/tags/" + ref, nil
+}
+
+// encodeManifest returns how manifests are stored: their content type on one
+// line, followed by the manifest itself.
+func encodeManifest(m Manifest) (io.Reader, int64, error) {
+	if strings.Contains(m.ContentType, "\n") {
+		return nil, 0, fmt.Errorf("invalid content type %q", m.ContentType)
+	}
+	size := int64(len(m.ContentType) + 1 + len(m.Blob))
+	return io.MultiReader(strings.NewReader(m.ContentType+"\n"), bytes.NewReader(m.Blob)), size, nil
+}
+
+func decodeManifest(b []byte) (Manifest, error) {
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return Manifest{}, errors.New("corrupt manifest: no content type")
+	}
+	return Manifest{ContentType: string(b[:i]), Blob: b[i+1:]}, nil
+}
+
+func (d *diskManifests) repoPath(repo string) (string, error) {
+	rk, err := repoKey(repo)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(rk)), nil
+}
+
+func (d *diskManifests) refPath(repo, ref string) (string, error) {
+	k, err := manifestKey(repo, ref)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(k)), nil
+}
+
+func (d *diskManifests) Get(_ context.Context, repo, ref string) (Manifest, error) {
+	p, err := d.refPath(repo, ref)
+	if err != nil {
+		return Manifest{}, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, ErrNotFound
+	} else if err != nil {
+		return Manifest{}, err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return Manifest{}, err
+	}
+	m, err := decodeManifest(b)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%s: %w", p, err)
+	}
+	if fi, err := f.Stat(); err == nil {
+		m.Modified = fi.ModTime()
+	}
+	return m, nil
+}
+
+func (d *diskManifests) Put(_ context.Context, repo, ref string, m Manifest) error {
+	p, err := d.refPath(repo, ref)
+	if err != nil {
+		return err
+	}
+	r, _, err := encodeManifest(m)
+	if err != nil {
+		return err
+	}
+	return writeFile(p, r)
+}
+
+func (d *diskManifests) Delete(_ context.Context, repo, ref string) error {
+	p, err := d.refPath(repo, ref)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *diskManifests) Refs(_ context.Context, repo string) ([]string, error) {
+	rp, err := d.repoPath(repo)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(rp); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	tags, err := ioutil.ReadDir(filepath.Join(rp, "tags"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, fi := range tags {
+		if !strings.HasPrefix(fi.Name(), ".tmp-") {
+			refs = append(refs, fi.Name())
+		}
+	}
+	algs, err := ioutil.ReadDir(filepath.Join(rp, "digests"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, alg := range algs {
+		hexes, err := ioutil.ReadDir(filepath.Join(rp, "digests", alg.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, fi := range hexes {
+			if !strings.HasPrefix(fi.Name(), ".tmp-") {
+				refs = append(refs, alg.Name()+":"+fi.Name())
+			}
+		}
+	}
+	return refs, nil
+}
+
+func (d *diskManifests) Repos(context.Context) ([]string, error) {
+	var repos []string
+	err := filepath.Walk(d.dir, func(p string, fi os.FileInfo, err error) error {
+		if errors.Is(err, os.ErrNotExist) && p == d.dir {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if !fi.IsDir() || fi.Name() != manifestsDir {
+			return nil
+		}
+		rel, err := filepath.Rel(d.dir, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		repos = append(repos, filepath.ToSlash(rel))
+		return filepath.SkipDir
+	})
+	return repos, err
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestStorageDir(t *testing.T) {
+	dir := t.TempDir()
+	serve := func() *httptest.Server {
+		return httptest.NewServer(registry.New(
+			registry.WithStorageDir(dir),
+			registry.Logger(log.New(ioutil.Discard, "", 0)),
+		))
+	}
+
+	s := serve()
+	tag, err := name.NewTag(strings.TrimPrefix(s.URL, "http://") + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatalf("remote.Write: %v", err)
+	}
+	s.Close()
+
+	// A new registry with the same directory still has the image.
+	s = serve()
+	defer s.Close()
+	repo, err := name.NewRepository(strings.TrimPrefix(s.URL, "http://") + "/foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := remote.Image(repo.Tag("latest"))
+	if err != nil {
+		t.Fatalf("remote.Image: %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := got.Digest(); err != nil {
+		t.Fatal(err)
+	} else if d != want {
+		t.Errorf("Digest = %s, want %s", d, want)
+	}
+
+	tags, err := remote.List(repo)
+	if err != nil {
+		t.Fatalf("remote.List: %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "latest" {
+		t.Errorf("tags = %v, want [latest]", tags)
+	}
+	repos, err := remote.Catalog(context.Background(), repo.Registry)
+	if err != nil {
+		t.Fatalf("remote.Catalog: %v", err)
+	}
+	if len(repos) != 1 || repos[0] != "foo/bar" {
+		t.Errorf("repos = %v, want [foo/bar]", repos)
+	}
+
+	if err := remote.Delete(repo.Tag("latest")); err != nil {
+		t.Fatalf("remote.Delete: %v", err)
+	}
+	if _, err := remote.Image(repo.Tag("latest")); err == nil {
+		t.Error("expected error reading deleted tag")
+	}
+	if _, err := remote.Image(repo.Digest(want.String())); err != nil {
+		t.Errorf("reading by digest after deleting tag: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// EventsMediaType is the media type of the notification envelopes sent to
+// webhooks.
+const EventsMediaType = "application/vnd.docker.distribution.events.v1+json"
+
+// Event actions.
+const (
+	EventActionPull   = "pull"
+	EventActionPush   = "push"
+	EventActionDelete = "delete"
+	EventActionMount  = "mount"
+)
+
+// Event describes something that happened to a manifest or blob in the
+// registry. It has the same shape as the events sent by the reference
+// registry, https://docs.docker.com/registry/notifications/.
+type Event struct {
+	// ID uniquely identifies the event.
+	ID string `json:"id"`
+
+	// Timestamp is when the event happened.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Action is one of EventActionPull, EventActionPush, EventActionDelete or
+	// EventActionMount.
+	Action string `json:"action"`
+
+	// Target is the manifest or blob the event is about.
+	Target EventTarget `json:"target"`
+
+	// Request describes the request that caused the event.
+	Request EventRequest `json:"request"`
+
+	// Actor is who made the request, if they authenticated.
+	Actor EventActor `json:"actor"`
+
+	// Source identifies the registry that sent the event.
+	Source EventSource `json:"source"`
+}
+
+// EventTarget describes the manifest or blob an Event is about.
+type EventTarget struct {
+	MediaType  string `json:"mediaType,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	Length     int64  `json:"length,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+
+	// FromRepository is the repository a mounted blob came from.
+	FromRepository string `json:"fromRepository,omitempty"`
+}
+
+// EventRequest describes the request that caused an Event.
+type EventRequest struct {
+	ID        string `json:"id"`
+	Addr      string `json:"addr"`
+	Host      string `json:"host"`
+	Method    string `json:"method"`
+	UserAgent string `json:"useragent"`
+}
+
+// EventActor describes who caused an Event.
+type EventActor struct {
+	Name string `json:"name,omitempty"`
+}
+
+// EventSource identifies the registry that sent an Event.
+type EventSource struct {
+	Addr       string `json:"addr"`
+	InstanceID string `json:"instanceID"`
+}
+
+// WithEventHandler calls f with an event for every pull, push or delete of a
+// manifest or blob, before responding to the request that caused it.
+func WithEventHandler(f func(Event)) Option {
+	return func(r *registry) {
+		r.events.handlers = append(r.events.handlers, f)
+	}
+}
+
+// WithWebhook POSTs an envelope containing an event to endpoint for every
+// pull, push or delete of a manifest or blob, in the format described at
+// https://docs.docker.com/registry/notifications/.
+//
+// Unlike the reference registry, events are sent before responding to the
+// request that caused them, so that they've been delivered by the time the
+// client sees the response, though not while holding locks that other
+// requests need. Deliveries time out after 10 seconds, and failed deliveries
+// are logged, not retried.
+func WithWebhook(endpoint string) Option {
+	return func(r *registry) {
+		r.events.webhooks = append(r.events.webhooks, endpoint)
+	}
+}
+
+type notifier struct {
+	handlers []func(Event)
+	webhooks []string
+	client   *http.Client
+	instance string
+	log      *log.Logger
+}
+
+type userKey struct{}
+
+// withUser records the authenticated user in the request's context, for
+// events.
+func withUser(req *http.Request, user string) *http.Request {
+	if user == "" {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), userKey{}, user))
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// notify sends an event about target, a manifest or blob depending on kind
+// ("manifests" or "blobs").
+func (n *notifier) notify(req *http.Request, action, kind string, target EventTarget) {
+	if n == nil || (len(n.handlers) == 0 && len(n.webhooks) == 0) {
+		return
+	}
+
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	target.Length = target.Size
+	target.URL = fmt.Sprintf("%s://%s/v2/%s/%s/%s", scheme, req.Host, target.Repository, kind, target.Digest)
+
+	user, _ := req.Context().Value(userKey{}).(string)
+	if user == "" {
+		user, _, _ = req.BasicAuth()
+	}
+
+	e := Event{
+		ID:        randomID(),
+		Timestamp: time.Now().UTC(),
+		Action:    action,
+		Target:    target,
+		Request: EventRequest{
+			ID:        randomID(),
+			Addr:      req.RemoteAddr,
+			Host:      req.Host,
+			Method:    req.Method,
+			UserAgent: req.UserAgent(),
+		},
+		Actor: EventActor{Name: user},
+		Source: EventSource{
+			Addr:       req.Host,
+			InstanceID: n.instance,
+		},
+	}
+
+	for _, f := range n.handlers {
+		f(e)
+	}
+	for _, endpoint := range n.webhooks {
+		if err := n.send(req.Context(), endpoint, e); err != nil {
+			n.log.Printf("sending %s event to %s: %v", action, endpoint, err)
+		}
+	}
+}
+
+func (n *notifier) send(ctx context.Context, endpoint string, e Event) error {
+	b, err := json.Marshal(struct {
+		Events []Event `json:"events"`
+	}{[]Event{e}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", EventsMediaType)
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
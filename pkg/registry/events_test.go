@@ -0,0 +1,223 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+type recorder struct {
+	sync.Mutex
+	events []registry.Event
+}
+
+func (r *recorder) record(e registry.Event) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) take() []registry.Event {
+	r.Lock()
+	defer r.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func TestEventHandler(t *testing.T) {
+	rec := &recorder{}
+	s := httptest.NewServer(registry.New(
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+		registry.WithEventHandler(rec.record),
+		registry.WithBasicAuth(users),
+	))
+	defer s.Close()
+	alice := &authn.Basic{Username: "alice", Password: "secret"}
+
+	d, blobs := pushRandomWithAuth(t, s, "foo:latest", alice)
+	events := rec.take()
+	if got, want := len(events), len(blobs)+1; got != want {
+		t.Fatalf("push sent %d events, want %d", got, want)
+	}
+	pushed := map[string]bool{}
+	for _, e := range events {
+		if e.Action != registry.EventActionPush {
+			t.Errorf("Action = %q, want push", e.Action)
+		}
+		if e.Target.Repository != "foo" {
+			t.Errorf("Repository = %q, want foo", e.Target.Repository)
+		}
+		if e.Actor.Name != "alice" {
+			t.Errorf("Actor = %q, want alice", e.Actor.Name)
+		}
+		pushed[e.Target.Digest] = true
+	}
+	for _, h := range blobs {
+		if !pushed[h.String()] {
+			t.Errorf("no push event for blob %s", h)
+		}
+	}
+	last := events[len(events)-1]
+	if last.Target.Digest != d.DigestStr() || last.Target.Tag != "latest" {
+		t.Errorf("manifest event = %+v, want %s tagged latest", last.Target, d.DigestStr())
+	}
+
+	img, err := remote.Image(d.Context().Tag("latest"), remote.WithAuth(alice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := img.RawConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+	events = rec.take()
+	if got, want := len(events), 2; got != want {
+		t.Fatalf("pull sent %d events, want %d", got, want)
+	}
+	for _, e := range events {
+		if e.Action != registry.EventActionPull {
+			t.Errorf("Action = %q, want pull", e.Action)
+		}
+	}
+	if events[1].Target.Digest != blobs[0].String() {
+		t.Errorf("pulled %s, want config %s", events[1].Target.Digest, blobs[0])
+	}
+
+	if err := remote.Delete(d, remote.WithAuth(alice)); err != nil {
+		t.Fatal(err)
+	}
+	events = rec.take()
+	if len(events) != 1 || events[0].Action != registry.EventActionDelete || events[0].Target.Digest != d.DigestStr() {
+		t.Errorf("delete sent %+v, want one delete event for %s", events, d.DigestStr())
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	type envelope struct {
+		Events []registry.Event `json:"events"`
+	}
+	var (
+		mu        sync.Mutex
+		envelopes []envelope
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Content-Type"), registry.EventsMediaType; got != want {
+			t.Errorf("Content-Type = %q, want %q", got, want)
+		}
+		var e envelope
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		envelopes = append(envelopes, e)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	s := httptest.NewServer(registry.New(
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+		registry.WithWebhook(hook.URL),
+	))
+	defer s.Close()
+
+	d, blobs := pushRandom(t, s, "foo:latest")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got, want := len(envelopes), len(blobs)+1; got != want {
+		t.Fatalf("webhook got %d envelopes, want %d", got, want)
+	}
+	last := envelopes[len(envelopes)-1]
+	if len(last.Events) != 1 {
+		t.Fatalf("got %d events, want 1", len(last.Events))
+	}
+	e := last.Events[0]
+	if e.Action != registry.EventActionPush || e.Target.Digest != d.DigestStr() || e.Target.Tag != "latest" {
+		t.Errorf("event = %+v", e)
+	}
+	if want := s.URL + "/v2/foo/manifests/" + d.DigestStr(); e.Target.URL != want {
+		t.Errorf("URL = %q, want %q", e.Target.URL, want)
+	}
+	if e.Source.InstanceID == "" || e.ID == "" {
+		t.Errorf("event is missing IDs: %+v", e)
+	}
+}
+
+func TestWebhookDoesNotBlockPushes(t *testing.T) {
+	// The webhook hangs on the first event until the test is done.
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first := false
+		once.Do(func() { first = true })
+		if first {
+			close(started)
+			<-release
+		}
+	}))
+	defer hook.Close()
+
+	s := httptest.NewServer(registry.New(
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+		registry.WithWebhook(hook.URL),
+	))
+	defer s.Close()
+	defer close(release)
+
+	put := func(repo string) error {
+		req, err := http.NewRequest(http.MethodPut, s.URL+"/v2/"+repo+"/manifests/latest", strings.NewReader(repo))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", string(types.OCIManifestSchema1))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			return fmt.Errorf("PUT %s = %d", repo, resp.StatusCode)
+		}
+		return nil
+	}
+
+	go put("slow")
+	<-started
+
+	done := make(chan error, 1)
+	go func() { done <- put("fast") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("push blocked on another push's webhook")
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fault describes misbehavior to inject into the registry's responses, to
+// exercise client retry and verification logic.
+type Fault struct {
+	// Routes limits the fault to requests for these routes, e.g.
+	// RouteManifests. If empty, every route is affected.
+	Routes []string
+
+	// Methods limits the fault to requests with these methods. If empty,
+	// every method is affected.
+	Methods []string
+
+	// Percent is the percentage of matching requests to affect. Zero means
+	// all of them.
+	Percent float64
+
+	// Latency delays affected responses.
+	Latency time.Duration
+
+	// Status, if set, responds to affected requests with this status code
+	// (e.g. 500 or 503) instead of handling them.
+	Status int
+
+	// Truncate sends only the first half of affected response bodies, and
+	// then drops the connection.
+	Truncate bool
+
+	// WrongDigest corrupts the contents of affected blob and manifest
+	// responses, so they no longer match their digest.
+	WrongDigest bool
+}
+
+// WithFault injects f into the registry's responses. It can be passed more
+// than once, in which case every matching fault is applied.
+func WithFault(f Fault) Option {
+	return func(r *registry) {
+		r.faults.faults = append(r.faults.faults, f)
+	}
+}
+
+// WithFaultSeed seeds the choice of which requests faults with a Percent
+// affect, to make that choice reproducible.
+func WithFaultSeed(seed int64) Option {
+	return func(r *registry) {
+		r.faults.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+type faults struct {
+	faults []Fault
+	rand   *rand.Rand
+	lock   sync.Mutex
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// matching returns the faults that affect req.
+func (fs *faults) matching(req *http.Request) []Fault {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	var matched []Fault
+	for _, f := range fs.faults {
+		if len(f.Routes) != 0 && !contains(f.Routes, route(req)) {
+			continue
+		}
+		if len(f.Methods) != 0 && !contains(f.Methods, req.Method) {
+			continue
+		}
+		if f.Percent > 0 && fs.rand.Float64()*100 >= f.Percent {
+			continue
+		}
+		matched = append(matched, f)
+	}
+	return matched
+}
+
+// inject applies the faults that affect req, returning the writer to use for
+// the response, or an error to respond with instead of handling it.
+func (fs *faults) inject(resp http.ResponseWriter, req *http.Request) (*faultWriter, *regError) {
+	matched := fs.matching(req)
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	fw := &faultWriter{ResponseWriter: resp, limit: -1}
+	for _, f := range matched {
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-req.Context().Done():
+			}
+		}
+		if f.Status != 0 {
+			code := "UNKNOWN"
+			switch f.Status {
+			case http.StatusServiceUnavailable:
+				code = "UNAVAILABLE"
+			case http.StatusTooManyRequests:
+				code = "TOOMANYREQUESTS"
+			}
+			return nil, &regError{
+				Status:  f.Status,
+				Code:    code,
+				Message: "injected fault",
+			}
+		}
+		fw.truncate = fw.truncate || f.Truncate
+		fw.corrupt = fw.corrupt || f.WrongDigest
+	}
+	return fw, nil
+}
+
+// faultWriter truncates or corrupts a response.
+type faultWriter struct {
+	http.ResponseWriter
+	truncate, corrupt bool
+
+	// limit is how many more bytes to write before truncating, or -1 if
+	// that's not known yet.
+	limit     int64
+	truncated bool
+	corrupted bool
+}
+
+func (w *faultWriter) WriteHeader(code int) {
+	if w.truncate {
+		// Half of what's promised, or nothing if nothing was.
+		n, _ := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64)
+		w.limit = n / 2
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *faultWriter) Write(b []byte) (int, error) {
+	if w.limit < 0 && w.truncate {
+		w.WriteHeader(http.StatusOK)
+	}
+	n := len(b)
+	if w.corrupt && !w.corrupted && n > 0 && w.Header().Get("Docker-Content-Digest") != "" {
+		b = append([]byte{b[0] ^ 0xff}, b[1:]...)
+		w.corrupted = true
+	}
+	if w.truncate {
+		if int64(len(b)) > w.limit {
+			b = b[:w.limit]
+			w.truncated = true
+		}
+		w.limit -= int64(len(b))
+	}
+	if _, err := w.ResponseWriter.Write(b); err != nil {
+		return 0, err
+	}
+	// Pretend everything was written, so the handler carries on.
+	return n, nil
+}
+
+// finish drops the connection if the response was truncated, after sending
+// what was written so far.
+func (w *faultWriter) finish() {
+	if w.truncated {
+		if f, ok := w.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+		panic(http.ErrAbortHandler)
+	}
+}
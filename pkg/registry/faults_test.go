@@ -0,0 +1,178 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// faultyRegistry returns a registry with an image pushed to it, and then the
+// given faults injected.
+func faultyRegistry(t *testing.T, faults ...registry.Fault) (*httptest.Server, name.Digest) {
+	t.Helper()
+	// Share storage between a well-behaved registry, for pushing, and a
+	// faulty one.
+	dir := t.TempDir()
+	faulty := false
+	clean := registry.New(quiet(), registry.WithStorageDir(dir))
+	opts := []registry.Option{quiet(), registry.WithStorageDir(dir)}
+	for _, f := range faults {
+		opts = append(opts, registry.WithFault(f))
+	}
+	broken := registry.New(opts...)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if faulty {
+			broken.ServeHTTP(w, r)
+		} else {
+			clean.ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	faulty = true
+	return s, ref.Context().Digest(d.String())
+}
+
+func TestFaultStatus(t *testing.T) {
+	s := httptest.NewServer(registry.New(quiet(), registry.WithFault(registry.Fault{
+		Routes: []string{registry.RouteManifests},
+		Status: http.StatusServiceUnavailable,
+	})))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/v2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("ping = %d, want 200", resp.StatusCode)
+	}
+
+	resp, err = http.Get(s.URL + "/v2/foo/manifests/latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(string(b), "UNAVAILABLE") {
+		t.Errorf("manifest = %d %s, want 503 UNAVAILABLE", resp.StatusCode, b)
+	}
+}
+
+func TestFaultPercent(t *testing.T) {
+	s := httptest.NewServer(registry.New(quiet(), registry.WithFaultSeed(42), registry.WithFault(registry.Fault{
+		Methods: []string{http.MethodGet},
+		Percent: 50,
+		Status:  http.StatusInternalServerError,
+	})))
+	defer s.Close()
+
+	failed := 0
+	for i := 0; i < 100; i++ {
+		resp, err := http.Get(s.URL + "/v2/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusInternalServerError {
+			failed++
+		}
+	}
+	if failed < 30 || failed > 70 {
+		t.Errorf("%d of 100 requests failed, want about 50", failed)
+	}
+
+	// HEAD isn't affected.
+	resp, err := http.Head(s.URL + "/v2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("HEAD = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestFaultLatency(t *testing.T) {
+	s := httptest.NewServer(registry.New(quiet(), registry.WithFault(registry.Fault{Latency: 50 * time.Millisecond})))
+	defer s.Close()
+
+	start := time.Now()
+	resp, err := http.Get(s.URL + "/v2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("request took %s, want at least 50ms", d)
+	}
+}
+
+func TestFaultTruncate(t *testing.T) {
+	_, d := faultyRegistry(t, registry.Fault{Routes: []string{registry.RouteBlobs}, Methods: []string{http.MethodGet}, Truncate: true})
+
+	img, err := remote.Image(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := img.RawConfigFile(); err == nil {
+		t.Error("reading a truncated config succeeded")
+	}
+}
+
+func TestFaultWrongDigest(t *testing.T) {
+	for _, r := range []string{registry.RouteManifests, registry.RouteBlobs} {
+		t.Run(r, func(t *testing.T) {
+			_, d := faultyRegistry(t, registry.Fault{Routes: []string{r}, WrongDigest: true})
+
+			img, err := remote.Image(d)
+			if err == nil {
+				_, err = img.RawConfigFile()
+			}
+			if err == nil {
+				t.Error("reading a corrupted image succeeded")
+			}
+		})
+	}
+}
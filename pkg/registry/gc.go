@@ -0,0 +1,163 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// WithGCOnDelete garbage collects blobs (see GarbageCollect) whenever a
+// manifest is deleted. The blob handler must implement BlobListHandler and
+// BlobDeleteHandler, as the default in-memory and disk ones do.
+//
+// Blobs that were uploaded, mounted or found to exist by a client less than
+// grace ago are kept, since they may belong to an image that's still being
+// pushed. grace should be longer than the slowest push takes.
+func WithGCOnDelete(grace time.Duration) Option {
+	return func(r *registry) {
+		r.manifests.gcOnDelete = true
+		r.manifests.gcGrace = grace
+		r.blobs.recent = &recentBlobs{m: map[v1.Hash]time.Time{}}
+		r.manifests.recent = r.blobs.recent
+	}
+}
+
+// recentBlobs remembers when pushes last used each blob.
+type recentBlobs struct {
+	m    map[v1.Hash]time.Time
+	lock sync.Mutex
+}
+
+// touch records that h was just used. It's a no-op on a nil recentBlobs, as
+// when blobs aren't garbage collected on delete.
+func (r *recentBlobs) touch(h v1.Hash) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.m[h] = time.Now()
+}
+
+// since returns the blobs that were used after cutoff, forgetting the rest.
+func (r *recentBlobs) since(cutoff time.Time) map[v1.Hash]bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	recent := map[v1.Hash]bool{}
+	for h, t := range r.m {
+		if t.After(cutoff) {
+			recent[h] = true
+		} else {
+			delete(r.m, h)
+		}
+	}
+	return recent
+}
+
+// GarbageCollect deletes the blobs in blobs that aren't referenced by any of
+// the manifests in manifests, and returns their digests. blobs must implement
+// BlobListHandler and BlobDeleteHandler.
+//
+// Blobs that have been uploaded for a manifest that hasn't been pushed yet are
+// unreferenced too, so this shouldn't be run while images are being pushed.
+func GarbageCollect(ctx context.Context, manifests ManifestStore, blobs BlobHandler) ([]v1.Hash, error) {
+	return garbageCollect(ctx, manifests, blobs, nil)
+}
+
+// garbageCollect is GarbageCollect, but also keeps the blobs in keep.
+func garbageCollect(ctx context.Context, manifests ManifestStore, blobs BlobHandler, keep map[v1.Hash]bool) ([]v1.Hash, error) {
+	lister, ok := blobs.(BlobListHandler)
+	if !ok {
+		return nil, errors.New("blob handler can't list blobs")
+	}
+	deleter, ok := blobs.(BlobDeleteHandler)
+	if !ok {
+		return nil, errors.New("blob handler can't delete blobs")
+	}
+
+	live, err := referencedBlobs(ctx, manifests)
+	if err != nil {
+		return nil, err
+	}
+	all, err := lister.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing blobs: %w", err)
+	}
+
+	var deleted []v1.Hash
+	for _, h := range all {
+		if live[h] || keep[h] {
+			continue
+		}
+		if err := deleter.Delete(ctx, "", h); err != nil && !errors.Is(err, ErrNotFound) {
+			return deleted, fmt.Errorf("deleting %s: %w", h, err)
+		}
+		deleted = append(deleted, h)
+	}
+	return deleted, nil
+}
+
+// referencedBlobs returns the digests of the blobs referenced by any manifest
+// in the store. Indexes only reference other manifests, which are kept in the
+// store by digest, so they're covered too.
+func referencedBlobs(ctx context.Context, manifests ManifestStore) (map[v1.Hash]bool, error) {
+	live := map[v1.Hash]bool{}
+	repos, err := manifests.Repos(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing repositories: %w", err)
+	}
+	for _, repo := range repos {
+		refs, err := manifests.Refs(ctx, repo)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", repo, err)
+		}
+		for _, ref := range refs {
+			mf, err := manifests.Get(ctx, repo, ref)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("reading %s@%s: %w", repo, ref, err)
+			}
+
+			// Anything with a config, layers or blobs (as in artifact
+			// manifests) references blobs.
+			var m struct {
+				Config *v1.Descriptor  `json:"config"`
+				Layers []v1.Descriptor `json:"layers"`
+				Blobs  []v1.Descriptor `json:"blobs"`
+			}
+			if err := json.Unmarshal(mf.Blob, &m); err != nil {
+				// Not something we understand, so it can't reference anything.
+				continue
+			}
+			if m.Config != nil {
+				live[m.Config.Digest] = true
+			}
+			for _, desc := range append(m.Layers, m.Blobs...) {
+				live[desc.Digest] = true
+			}
+		}
+	}
+	return live, nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// pushRandom pushes a random image to repo:tag on s, returning its digest and
+// blobs.
+func pushRandom(t *testing.T, s *httptest.Server, ref string) (name.Digest, []v1.Hash) {
+	t.Helper()
+	return pushRandomWithAuth(t, s, ref, authn.Anonymous)
+}
+
+func pushRandomWithAuth(t *testing.T, s *httptest.Server, ref string, auth authn.Authenticator) (name.Digest, []v1.Hash) {
+	t.Helper()
+	tag, err := name.NewTag(strings.TrimPrefix(s.URL, "http://") + "/" + ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img, remote.WithAuth(auth)); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := []v1.Hash{m.Config.Digest}
+	for _, l := range m.Layers {
+		hs = append(hs, l.Digest)
+	}
+	return tag.Context().Digest(d.String()), hs
+}
+
+func blobExists(t *testing.T, s *httptest.Server, repo string, h v1.Hash) bool {
+	t.Helper()
+	resp, err := http.Head(s.URL + "/v2/" + repo + "/blobs/" + h.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+func TestDeleteManifest(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer s.Close()
+
+	d, _ := pushRandom(t, s, "foo:a")
+	tag := d.Context().Tag("b")
+	img, err := remote.Image(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting a tag leaves the manifest and its other tags.
+	if err := remote.Delete(tag); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Head(tag); err == nil {
+		t.Error("tag b still exists")
+	}
+	if _, err := remote.Head(d.Context().Tag("a")); err != nil {
+		t.Errorf("tag a: %v", err)
+	}
+
+	// Deleting the manifest by digest deletes the remaining tags.
+	if err := remote.Delete(d); err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []name.Reference{d, d.Context().Tag("a")} {
+		if _, err := remote.Head(ref); err == nil {
+			t.Errorf("%s still exists", ref)
+		}
+	}
+}
+
+func TestDeleteBlob(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer s.Close()
+
+	_, blobs := pushRandom(t, s, "foo:latest")
+
+	for _, want := range []int{http.StatusAccepted, http.StatusNotFound} {
+		req, err := http.NewRequest(http.MethodDelete, s.URL+"/v2/foo/blobs/"+blobs[1].String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("DELETE = %d, want %d", resp.StatusCode, want)
+		}
+	}
+	if blobExists(t, s, "foo", blobs[1]) {
+		t.Error("blob still exists")
+	}
+}
+
+func TestGCOnDelete(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)), registry.WithGCOnDelete(0)))
+	defer s.Close()
+
+	gone, goneBlobs := pushRandom(t, s, "foo:gone")
+	_, keptBlobs := pushRandom(t, s, "foo:kept")
+
+	if err := remote.Delete(gone); err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range goneBlobs {
+		if blobExists(t, s, "foo", h) {
+			t.Errorf("blob %s wasn't collected", h)
+		}
+	}
+	for _, h := range keptBlobs {
+		if !blobExists(t, s, "foo", h) {
+			t.Errorf("blob %s was collected", h)
+		}
+	}
+}
+
+func TestGCOnDeleteGracePeriod(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)), registry.WithGCOnDelete(time.Hour)))
+	defer s.Close()
+
+	gone, goneBlobs := pushRandom(t, s, "foo:gone")
+
+	// Upload a layer for an image whose manifest hasn't been pushed yet.
+	repo, err := name.NewRepository(strings.TrimPrefix(s.URL, "http://") + "/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteLayer(repo, layer); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := remote.Delete(gone); err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range append(goneBlobs, pending) {
+		if !blobExists(t, s, "foo", h) {
+			t.Errorf("blob %s was collected within the grace period", h)
+		}
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	dir := t.TempDir()
+	blobs := registry.NewDiskBlobHandler(filepath.Join(dir, "blobs"))
+	manifests := registry.NewDiskManifestStore(filepath.Join(dir, "manifests"))
+	s := httptest.NewServer(registry.New(
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+		registry.WithBlobHandler(blobs),
+		registry.WithManifestStore(manifests),
+	))
+	defer s.Close()
+
+	// Tags don't keep manifests alive once they're deleted by digest, but
+	// untagged manifests still keep their blobs.
+	gone, goneBlobs := pushRandom(t, s, "foo:gone")
+	kept, keptBlobs := pushRandom(t, s, "bar:kept")
+	if err := remote.Delete(gone); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Delete(kept.Context().Tag("kept")); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := registry.GarbageCollect(context.Background(), manifests, blobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(deleted), len(goneBlobs); got != want {
+		t.Errorf("GarbageCollect() deleted %d blobs, want %d", got, want)
+	}
+	for _, h := range goneBlobs {
+		if blobExists(t, s, "foo", h) {
+			t.Errorf("blob %s wasn't collected", h)
+		}
+	}
+	for _, h := range keptBlobs {
+		if !blobExists(t, s, "bar", h) {
+			t.Errorf("blob %s was collected", h)
+		}
+	}
+
+	// A blob handler that can't enumerate its blobs can't be collected.
+	if _, err := registry.GarbageCollect(context.Background(), manifests, getOnly{}); err == nil {
+		t.Error("GarbageCollect() with a get-only handler should fail")
+	}
+}
+
+type getOnly struct{ registry.BlobHandler }
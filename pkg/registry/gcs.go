@@ -0,0 +1,185 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GCSConfig configures a Google Cloud Storage ObjectStore.
+type GCSConfig struct {
+	// Bucket is the name of the bucket. It must already exist.
+	Bucket string
+
+	// Endpoint is the URL of the GCS JSON API, which defaults to
+	// https://storage.googleapis.com. Set it to use an emulator.
+	Endpoint string
+
+	// Token returns an OAuth2 access token to authorize requests with, e.g.
+	// from golang.org/x/oauth2/google. If it's nil, requests are
+	// unauthenticated.
+	Token func(context.Context) (string, error)
+
+	// Client is the HTTP client to use. It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type gcsStore struct {
+	cfg GCSConfig
+}
+
+// NewGCSStore returns an ObjectStore backed by a GCS bucket, using the GCS
+// JSON API. Uploads are streamed.
+func NewGCSStore(cfg GCSConfig) ObjectStore {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://storage.googleapis.com"
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &gcsStore{cfg: cfg}
+}
+
+func (g *gcsStore) objectURL(key string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", g.cfg.Endpoint, url.PathEscape(g.cfg.Bucket), url.PathEscape(key))
+}
+
+// request returns an authorized request.
+func (g *gcsStore) request(ctx context.Context, method, u string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if g.cfg.Token != nil {
+		tok, err := g.cfg.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+	return req, nil
+}
+
+func (g *gcsStore) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	req, err := g.request(ctx, http.MethodGet, g.objectURL(key)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	if r := rangeHeader(offset, length); r != "" {
+		req.Header.Set("Range", r)
+	}
+	resp, err := do(g.cfg.Client, req, http.StatusOK, http.StatusPartialContent)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (g *gcsStore) Stat(ctx context.Context, key string) (int64, error) {
+	req, err := g.request(ctx, http.MethodGet, g.objectURL(key), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := do(g.cfg.Client, req, http.StatusOK)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var obj struct {
+		// The JSON API returns sizes as strings.
+		Size string `json:"size"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		return 0, fmt.Errorf("reading metadata of %s: %w", key, err)
+	}
+	return strconv.ParseInt(obj.Size, 10, 64)
+}
+
+func (g *gcsStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", g.cfg.Endpoint, url.PathEscape(g.cfg.Bucket), url.Values{
+		"uploadType": {"media"},
+		"name":       {key},
+	}.Encode())
+	req, err := g.request(ctx, http.MethodPost, u, r)
+	if err != nil {
+		return err
+	}
+	// Bodies of unknown size are streamed with chunked encoding.
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := do(g.cfg.Client, req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (g *gcsStore) Delete(ctx context.Context, key string) error {
+	req, err := g.request(ctx, http.MethodDelete, g.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := do(g.cfg.Client, req, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (g *gcsStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+		if token != "" {
+			q.Set("pageToken", token)
+		}
+		u := fmt.Sprintf("%s/storage/v1/b/%s/o?%s", g.cfg.Endpoint, url.PathEscape(g.cfg.Bucket), q.Encode())
+		req, err := g.request(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := do(g.cfg.Client, req, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", prefix, err)
+		}
+		for _, item := range result.Items {
+			keys = append(keys, item.Name)
+		}
+		if result.NextPageToken == "" {
+			return keys, nil
+		}
+		token = result.NextPageToken
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterForgetsIdleClients(t *testing.T) {
+	now := time.Now()
+	l := &limiter{
+		cfg:     RateLimit{Rate: 1, Burst: 10},
+		buckets: map[string]*bucket{},
+		now:     func() time.Time { return now },
+	}
+
+	for i := 0; i < 5; i++ {
+		l.take("idle")
+	}
+	now = now.Add(5 * time.Second)
+	l.take("busy")
+	if got, want := len(l.buckets), 2; got != want {
+		t.Fatalf("got %d buckets, want %d", got, want)
+	}
+
+	// After 10s idle's bucket has refilled, so it can be forgotten, but busy's
+	// was used 5s ago.
+	now = now.Add(5 * time.Second)
+	remaining, _, _ := l.take("busy")
+	if _, found := l.buckets["idle"]; found {
+		t.Error("idle client's bucket wasn't forgotten")
+	}
+	if got, want := remaining, 9; got != want {
+		t.Errorf("busy client has %d requests remaining, want %d", got, want)
+	}
+
+	// Forgetting a client doesn't give it more than a full bucket.
+	if remaining, _, _ := l.take("idle"); remaining != 9 {
+		t.Errorf("idle client has %d requests remaining, want 9", remaining)
+	}
+}
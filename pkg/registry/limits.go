@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// WithMaxManifestSize rejects manifests larger than n bytes with 413 Payload
+// Too Large and a SIZE_INVALID error.
+func WithMaxManifestSize(n int64) Option {
+	return func(r *registry) {
+		r.manifests.maxSize = n
+	}
+}
+
+// WithMaxBlobSize rejects blob uploads larger than n bytes with 413 Payload
+// Too Large and a SIZE_INVALID error.
+func WithMaxBlobSize(n int64) Option {
+	return func(r *registry) {
+		r.blobs.maxSize = n
+	}
+}
+
+// WithManifestMediaTypes rejects manifests whose Content-Type isn't one of
+// mts with 415 Unsupported Media Type and a MANIFEST_INVALID error.
+func WithManifestMediaTypes(mts ...types.MediaType) Option {
+	return func(r *registry) {
+		r.manifests.mediaTypes = mts
+	}
+}
+
+// errTooLarge is returned when reading more than allowed by WithMaxBlobSize.
+var errTooLarge = errors.New("maximum size exceeded")
+
+// maxReader fails with errTooLarge once more than n bytes have been read.
+type maxReader struct {
+	r io.Reader
+	n int64
+}
+
+func (m *maxReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	m.n -= int64(n)
+	if m.n < 0 {
+		return n, errTooLarge
+	}
+	return n, err
+}
+
+// limit returns a reader that fails with errTooLarge if reading all of r
+// would make a blob that already has the given size too large.
+func (b *blobs) limit(r io.Reader, size int64) io.Reader {
+	if b.maxSize <= 0 {
+		return r
+	}
+	return &maxReader{r: r, n: b.maxSize - size}
+}
+
+func (b *blobs) tooLarge() *regError {
+	return &regError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    "SIZE_INVALID",
+		Message: fmt.Sprintf("blob is larger than the maximum of %d bytes", b.maxSize),
+	}
+}
+
+// checkManifest returns an error if a manifest of the given media type and
+// size is against policy.
+func (m *manifests) checkManifest(mt string, size int64) *regError {
+	if m.maxSize > 0 && size > m.maxSize {
+		return &regError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    "SIZE_INVALID",
+			Message: fmt.Sprintf("manifest is larger than the maximum of %d bytes", m.maxSize),
+		}
+	}
+	if len(m.mediaTypes) == 0 {
+		return nil
+	}
+	// Ignore parameters, like charset.
+	mt = strings.TrimSpace(strings.SplitN(mt, ";", 2)[0])
+	for _, want := range m.mediaTypes {
+		if types.MediaType(mt) == want {
+			return nil
+		}
+	}
+	return &regError{
+		Status:  http.StatusUnsupportedMediaType,
+		Code:    "MANIFEST_INVALID",
+		Message: fmt.Sprintf("manifest media type %q is not allowed", mt),
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func wantCode(t *testing.T, err error, status int, code transport.ErrorCode) {
+	t.Helper()
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		t.Fatalf("got %v, want a transport error", err)
+	}
+	if terr.StatusCode != status {
+		t.Errorf("got status %d, want %d", terr.StatusCode, status)
+	}
+	if len(terr.Errors) != 1 || terr.Errors[0].Code != code {
+		t.Errorf("got errors %v, want %s", terr.Errors, code)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		opt    registry.Option
+		status int
+		code   transport.ErrorCode
+	}{{
+		desc:   "blob too large",
+		opt:    registry.WithMaxBlobSize(512),
+		status: http.StatusRequestEntityTooLarge,
+		code:   "SIZE_INVALID",
+	}, {
+		desc:   "manifest too large",
+		opt:    registry.WithMaxManifestSize(64),
+		status: http.StatusRequestEntityTooLarge,
+		code:   "SIZE_INVALID",
+	}, {
+		desc:   "media type not allowed",
+		opt:    registry.WithManifestMediaTypes(types.OCIManifestSchema1, types.OCIImageIndex),
+		status: http.StatusUnsupportedMediaType,
+		code:   transport.ManifestInvalidErrorCode,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)), tc.opt))
+			defer s.Close()
+
+			ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := random.Image(1024, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantCode(t, remote.Write(ref, img), tc.status, tc.code)
+		})
+	}
+}
+
+func TestLimitsAllowed(t *testing.T) {
+	s := httptest.NewServer(registry.New(
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+		registry.WithMaxBlobSize(4096),
+		registry.WithMaxManifestSize(4096),
+		registry.WithManifestMediaTypes(types.OCIManifestSchema1),
+	))
+	defer s.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -16,17 +16,21 @@ package registry
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -41,16 +45,133 @@ type listTags struct {
 	Tags []string `json:"tags"`
 }
 
-type manifest struct {
-	contentType string
-	blob        []byte
+// Manifest is a manifest as it was pushed to the registry.
+type Manifest struct {
+	ContentType string
+	Blob        []byte
+
+	// Modified is when the manifest was pushed. Stores that can't keep track
+	// of it leave it zero.
+	Modified time.Time
 }
 
-type manifests struct {
+// ManifestStore represents a manifest storage backend. Manifests are stored
+// by repository and reference, which is either a tag or a digest.
+//
+// Implementations must be safe for concurrent use.
+type ManifestStore interface {
+	// Get returns the manifest, or ErrNotFound if it wasn't found.
+	Get(ctx context.Context, repo, ref string) (Manifest, error)
+
+	// Put stores the manifest under the reference, replacing any manifest
+	// that was there.
+	Put(ctx context.Context, repo, ref string, m Manifest) error
+
+	// Delete removes the reference, or returns ErrNotFound if it wasn't
+	// found.
+	Delete(ctx context.Context, repo, ref string) error
+
+	// Refs returns the tags and digests in the repository, or ErrNotFound if
+	// nothing was ever pushed to it.
+	Refs(ctx context.Context, repo string) ([]string, error)
+
+	// Repos returns the repositories that something was pushed to.
+	Repos(ctx context.Context) ([]string, error)
+}
+
+type memManifests struct {
 	// maps repo -> manifest tag/digest -> manifest
-	manifests map[string]map[string]manifest
-	lock      sync.Mutex
-	log       *log.Logger
+	m    map[string]map[string]Manifest
+	lock sync.Mutex
+}
+
+func (m *memManifests) Get(_ context.Context, repo, ref string) (Manifest, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	mf, ok := m.m[repo][ref]
+	if !ok {
+		return Manifest{}, ErrNotFound
+	}
+	return mf, nil
+}
+
+func (m *memManifests) Put(_ context.Context, repo, ref string, mf Manifest) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.m[repo]; !ok {
+		m.m[repo] = map[string]Manifest{}
+	}
+	m.m[repo][ref] = mf
+	return nil
+}
+
+func (m *memManifests) Delete(_ context.Context, repo, ref string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.m[repo][ref]; !ok {
+		return ErrNotFound
+	}
+	delete(m.m[repo], ref)
+	return nil
+}
+
+func (m *memManifests) Refs(_ context.Context, repo string) ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, ok := m.m[repo]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	refs := make([]string, 0, len(c))
+	for ref := range c {
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+func (m *memManifests) Repos(context.Context) ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	repos := make([]string, 0, len(m.m))
+	for repo := range m.m {
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+type manifests struct {
+	store ManifestStore
+
+	// lock serializes pushes, so that an index's children can't be deleted
+	// while it's being pushed.
+	lock sync.Mutex
+	log  *log.Logger
+
+	// blobs is where the blobs that manifests reference are stored, for
+	// garbage collection.
+	blobs      BlobHandler
+	gcOnDelete bool
+	gcGrace    time.Duration
+	recent     *recentBlobs
+
+	events *notifier
+
+	maxSize    int64
+	mediaTypes []types.MediaType
+	strict     bool
+
+	// pageSize, if set, is the default and maximum page size for listing
+	// tags and repositories.
+	pageSize int
+
+	// tenants, if set, limit the catalog to the repositories each client
+	// may pull from.
+	tenants []Tenant
 }
 
 func isManifest(req *http.Request) bool {
@@ -81,6 +202,68 @@ func isCatalog(req *http.Request) bool {
 	return elems[len(elems)-1] == "_catalog"
 }
 
+// unknown returns the error for a missing manifest, depending on whether the
+// repository is missing too.
+func (m *manifests) unknown(ctx context.Context, repo string) *regError {
+	if _, err := m.store.Refs(ctx, repo); errors.Is(err, ErrNotFound) {
+		return &regError{
+			Status:  http.StatusNotFound,
+			Code:    "NAME_UNKNOWN",
+			Message: "Unknown name",
+		}
+	} else if err != nil {
+		return regErrInternal(err)
+	}
+	return &regError{
+		Status:  http.StatusNotFound,
+		Code:    "MANIFEST_UNKNOWN",
+		Message: "Unknown manifest",
+	}
+}
+
+// notify sends an event about the manifest mf, with the given digest, that
+// was accessed in repo by ref.
+func (m *manifests) notify(req *http.Request, action, repo, ref, digest string, mf Manifest) {
+	target := EventTarget{
+		MediaType:  mf.ContentType,
+		Size:       int64(len(mf.Blob)),
+		Digest:     digest,
+		Repository: repo,
+	}
+	if ref != digest {
+		target.Tag = ref
+	}
+	m.events.notify(req, action, "manifests", target)
+}
+
+// untag deletes the tags in repo that point to the manifest with the given
+// digest.
+func (m *manifests) untag(ctx context.Context, repo, digest string) error {
+	refs, err := m.store.Refs(ctx, repo)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if _, err := v1.NewHash(ref); err == nil {
+			continue
+		}
+		mf, err := m.store.Get(ctx, repo, ref)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		rd := sha256.Sum256(mf.Blob)
+		if "sha256:"+hex.EncodeToString(rd[:]) != digest {
+			continue
+		}
+		if err := m.store.Delete(ctx, repo, ref); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
 // https://github.com/opencontainers/distribution-spec/blob/master/spec.md#pulling-an-image-manifest
 // https://github.com/opencontainers/distribution-spec/blob/master/spec.md#pushing-an-image
 func (m *manifests) handle(resp http.ResponseWriter, req *http.Request) *regError {
@@ -88,188 +271,203 @@ func (m *manifests) handle(resp http.ResponseWriter, req *http.Request) *regErro
 	elem = elem[1:]
 	target := elem[len(elem)-1]
 	repo := strings.Join(elem[1:len(elem)-2], "/")
+	ctx := req.Context()
 
 	switch req.Method {
-	case http.MethodGet:
-		m.lock.Lock()
-		defer m.lock.Unlock()
-
-		c, ok := m.manifests[repo]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
-		}
-		m, ok := c[target]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "MANIFEST_UNKNOWN",
-				Message: "Unknown manifest",
-			}
+	case http.MethodGet, http.MethodHead:
+		mf, err := m.store.Get(ctx, repo, target)
+		if errors.Is(err, ErrNotFound) {
+			return m.unknown(ctx, repo)
+		} else if err != nil {
+			return regErrInternal(err)
 		}
-		rd := sha256.Sum256(m.blob)
+		rd := sha256.Sum256(mf.Blob)
 		d := "sha256:" + hex.EncodeToString(rd[:])
 		resp.Header().Set("Docker-Content-Digest", d)
-		resp.Header().Set("Content-Type", m.contentType)
-		resp.Header().Set("Content-Length", fmt.Sprint(len(m.blob)))
-		resp.WriteHeader(http.StatusOK)
-		io.Copy(resp, bytes.NewReader(m.blob))
-		return nil
-
-	case http.MethodHead:
-		m.lock.Lock()
-		defer m.lock.Unlock()
-		if _, ok := m.manifests[repo]; !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
+		if notModified(resp, req, d, mf.Modified) {
+			resp.WriteHeader(http.StatusNotModified)
+			return nil
 		}
-		m, ok := m.manifests[repo][target]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "MANIFEST_UNKNOWN",
-				Message: "Unknown manifest",
-			}
+		resp.Header().Set("Content-Type", mf.ContentType)
+		resp.Header().Set("Content-Length", fmt.Sprint(len(mf.Blob)))
+		if req.Method == http.MethodGet {
+			m.notify(req, EventActionPull, repo, target, d, mf)
 		}
-		rd := sha256.Sum256(m.blob)
-		d := "sha256:" + hex.EncodeToString(rd[:])
-		resp.Header().Set("Docker-Content-Digest", d)
-		resp.Header().Set("Content-Type", m.contentType)
-		resp.Header().Set("Content-Length", fmt.Sprint(len(m.blob)))
 		resp.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			io.Copy(resp, bytes.NewReader(mf.Blob))
+		}
 		return nil
 
 	case http.MethodPut:
-		m.lock.Lock()
-		defer m.lock.Unlock()
-		if _, ok := m.manifests[repo]; !ok {
-			m.manifests[repo] = map[string]manifest{}
+		digest, mf, rerr := m.put(req, repo, target)
+		if rerr != nil {
+			return rerr
 		}
-		b := &bytes.Buffer{}
-		io.Copy(b, req.Body)
-		rd := sha256.Sum256(b.Bytes())
-		digest := "sha256:" + hex.EncodeToString(rd[:])
-		mf := manifest{
-			blob:        b.Bytes(),
-			contentType: req.Header.Get("Content-Type"),
+		m.notify(req, EventActionPush, repo, target, digest, mf)
+		resp.Header().Set("Docker-Content-Digest", digest)
+		resp.WriteHeader(http.StatusCreated)
+		return nil
+
+	case http.MethodDelete:
+		digest, rerr := m.delete(ctx, repo, target)
+		if rerr != nil {
+			return rerr
 		}
+		m.notify(req, EventActionDelete, repo, target, digest, Manifest{})
+		resp.WriteHeader(http.StatusAccepted)
+		return nil
+
+	default:
+		return &regError{
+			Status:  http.StatusBadRequest,
+			Code:    "METHOD_UNKNOWN",
+			Message: "We don't understand your method + url",
+		}
+	}
+}
+
+// put stores the manifest in req as repo:target, returning its digest. Events
+// are sent by the caller once the lock is released, so that slow webhooks
+// don't hold up other pushes.
+func (m *manifests) put(req *http.Request, repo, target string) (string, Manifest, *regError) {
+	ctx := req.Context()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if rerr := m.checkManifest(req.Header.Get("Content-Type"), req.ContentLength); rerr != nil {
+		return "", Manifest{}, rerr
+	}
+	b := &bytes.Buffer{}
+	body := io.Reader(req.Body)
+	if m.maxSize > 0 {
+		body = io.LimitReader(body, m.maxSize+1)
+	}
+	io.Copy(b, body)
+	if rerr := m.checkManifest(req.Header.Get("Content-Type"), int64(b.Len())); rerr != nil {
+		return "", Manifest{}, rerr
+	}
+	rd := sha256.Sum256(b.Bytes())
+	digest := "sha256:" + hex.EncodeToString(rd[:])
+	mf := Manifest{
+		Blob:        b.Bytes(),
+		ContentType: req.Header.Get("Content-Type"),
+		Modified:    time.Now(),
+	}
 
+	if m.strict {
+		if rerr := m.validate(ctx, repo, mf); rerr != nil {
+			return "", Manifest{}, rerr
+		}
+	} else if types.MediaType(mf.ContentType).IsIndex() {
 		// If the manifest is a manifest list, check that the manifest
 		// list's constituent manifests are already uploaded.
 		// This isn't strictly required by the registry API, but some
 		// registries require this.
-		if types.MediaType(mf.contentType).IsIndex() {
-			im, err := v1.ParseIndexManifest(b)
-			if err != nil {
-				return &regError{
-					Status:  http.StatusBadRequest,
-					Code:    "MANIFEST_INVALID",
-					Message: err.Error(),
-				}
+		im, err := v1.ParseIndexManifest(b)
+		if err != nil {
+			return "", Manifest{}, &regError{
+				Status:  http.StatusBadRequest,
+				Code:    "MANIFEST_INVALID",
+				Message: err.Error(),
 			}
-			for _, desc := range im.Manifests {
-				if !desc.MediaType.IsDistributable() {
-					continue
-				}
-				if desc.MediaType.IsIndex() || desc.MediaType.IsImage() {
-					if _, found := m.manifests[repo][desc.Digest.String()]; !found {
-						return &regError{
-							Status:  http.StatusNotFound,
-							Code:    "MANIFEST_UNKNOWN",
-							Message: fmt.Sprintf("Sub-manifest %q not found", desc.Digest),
-						}
+		}
+		for _, desc := range im.Manifests {
+			if !desc.MediaType.IsDistributable() {
+				continue
+			}
+			if desc.MediaType.IsIndex() || desc.MediaType.IsImage() {
+				if _, err := m.store.Get(ctx, repo, desc.Digest.String()); errors.Is(err, ErrNotFound) {
+					return "", Manifest{}, &regError{
+						Status:  http.StatusNotFound,
+						Code:    "MANIFEST_UNKNOWN",
+						Message: fmt.Sprintf("Sub-manifest %q not found", desc.Digest),
 					}
-				} else {
-					// TODO: Probably want to do an existence check for blobs.
-					m.log.Printf("TODO: Check blobs for %q", desc.Digest)
+				} else if err != nil {
+					return "", Manifest{}, regErrInternal(err)
 				}
+			} else {
+				// TODO: Probably want to do an existence check for blobs.
+				m.log.Printf("TODO: Check blobs for %q", desc.Digest)
 			}
 		}
+	}
 
-		// Allow future references by target (tag) and immutable digest.
-		// See https://docs.docker.com/engine/reference/commandline/pull/#pull-an-image-by-digest-immutable-identifier.
-		m.manifests[repo][target] = mf
-		m.manifests[repo][digest] = mf
-		resp.Header().Set("Docker-Content-Digest", digest)
-		resp.WriteHeader(http.StatusCreated)
-		return nil
+	// Allow future references by target (tag) and immutable digest.
+	// See https://docs.docker.com/engine/reference/commandline/pull/#pull-an-image-by-digest-immutable-identifier.
+	if err := m.store.Put(ctx, repo, target, mf); err != nil {
+		return "", Manifest{}, regErrInternal(err)
+	}
+	if err := m.store.Put(ctx, repo, digest, mf); err != nil {
+		return "", Manifest{}, regErrInternal(err)
+	}
+	return digest, mf, nil
+}
 
-	case http.MethodDelete:
-		m.lock.Lock()
-		defer m.lock.Unlock()
-		if _, ok := m.manifests[repo]; !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
-		}
+// delete deletes repo:target, returning the digest of the deleted manifest.
+func (m *manifests) delete(ctx context.Context, repo, target string) (string, *regError) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	mf, err := m.store.Get(ctx, repo, target)
+	if errors.Is(err, ErrNotFound) {
+		return "", m.unknown(ctx, repo)
+	} else if err != nil {
+		return "", regErrInternal(err)
+	}
 
-		_, ok := m.manifests[repo][target]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "MANIFEST_UNKNOWN",
-				Message: "Unknown manifest",
-			}
+	// Deleting a manifest by digest deletes the tags that point to it,
+	// while deleting a tag leaves the manifest.
+	if _, err := v1.NewHash(target); err == nil {
+		if err := m.untag(ctx, repo, target); err != nil {
+			return "", regErrInternal(err)
 		}
-
-		delete(m.manifests[repo], target)
-		resp.WriteHeader(http.StatusAccepted)
-		return nil
-
-	default:
-		return &regError{
-			Status:  http.StatusBadRequest,
-			Code:    "METHOD_UNKNOWN",
-			Message: "We don't understand your method + url",
+	}
+	if err := m.store.Delete(ctx, repo, target); err != nil && !errors.Is(err, ErrNotFound) {
+		return "", regErrInternal(err)
+	}
+	if m.gcOnDelete {
+		keep := m.recent.since(time.Now().Add(-m.gcGrace))
+		deleted, err := garbageCollect(ctx, m.store, m.blobs, keep)
+		if err != nil {
+			return "", regErrInternal(err)
 		}
+		m.log.Printf("garbage collected %d blobs", len(deleted))
 	}
+	rd := sha256.Sum256(mf.Blob)
+	return "sha256:" + hex.EncodeToString(rd[:]), nil
 }
 
 func (m *manifests) handleTags(resp http.ResponseWriter, req *http.Request) *regError {
 	elem := strings.Split(req.URL.Path, "/")
 	elem = elem[1:]
 	repo := strings.Join(elem[1:len(elem)-2], "/")
-	query := req.URL.Query()
-	nStr := query.Get("n")
-	n := 1000
-	if nStr != "" {
-		n, _ = strconv.Atoi(nStr)
-	}
 
 	if req.Method == "GET" {
-		m.lock.Lock()
-		defer m.lock.Unlock()
+		n, rerr := m.pageLimit(req, 1000)
+		if rerr != nil {
+			return rerr
+		}
 
-		c, ok := m.manifests[repo]
-		if !ok {
+		refs, err := m.store.Refs(req.Context(), repo)
+		if errors.Is(err, ErrNotFound) {
 			return &regError{
 				Status:  http.StatusNotFound,
 				Code:    "NAME_UNKNOWN",
 				Message: "Unknown name",
 			}
+		} else if err != nil {
+			return regErrInternal(err)
 		}
 
 		var tags []string
-		countTags := 0
-		// TODO: implement pagination https://github.com/opencontainers/distribution-spec/blob/b505e9cc53ec499edbd9c1be32298388921bb705/detail.md#tags-paginated
-		for tag := range c {
-			if countTags >= n {
-				break
-			}
-			countTags++
+		for _, tag := range refs {
 			if !strings.Contains(tag, "sha256:") {
 				tags = append(tags, tag)
 			}
 		}
-		sort.Strings(tags)
+		tags, more := paginate(tags, n, req.URL.Query().Get("last"))
+		if more {
+			setNextLink(resp, req, n, tags[len(tags)-1])
+		}
 
 		tagsToList := listTags{
 			Name: repo,
@@ -291,27 +489,31 @@ func (m *manifests) handleTags(resp http.ResponseWriter, req *http.Request) *reg
 }
 
 func (m *manifests) handleCatalog(resp http.ResponseWriter, req *http.Request) *regError {
-	query := req.URL.Query()
-	nStr := query.Get("n")
-	n := 10000
-	if nStr != "" {
-		n, _ = strconv.Atoi(nStr)
-	}
-
 	if req.Method == "GET" {
-		m.lock.Lock()
-		defer m.lock.Unlock()
-
-		var repos []string
-		countRepos := 0
-		// TODO: implement pagination
-		for key := range m.manifests {
-			if countRepos >= n {
-				break
+		n, rerr := m.pageLimit(req, 10000)
+		if rerr != nil {
+			return rerr
+		}
+
+		all, err := m.store.Repos(req.Context())
+		if err != nil {
+			return regErrInternal(err)
+		}
+		if len(m.tenants) != 0 {
+			// Only list the repositories the client could pull from.
+			user, _ := req.Context().Value(userKey{}).(string)
+			visible := []string{}
+			for _, repo := range all {
+				if permitted(m.tenants, user, repo, "pull") {
+					visible = append(visible, repo)
+				}
 			}
-			countRepos++
+			all = visible
+		}
 
-			repos = append(repos, key)
+		repos, more := paginate(all, n, req.URL.Query().Get("last"))
+		if more {
+			setNextLink(resp, req, n, repos[len(repos)-1])
 		}
 
 		repositoriesToList := catalog{
@@ -331,3 +533,56 @@ func (m *manifests) handleCatalog(resp http.ResponseWriter, req *http.Request) *
 		Message: "We don't understand your method + url",
 	}
 }
+
+// pageLimit returns the number of results to return for req, which is the
+// requested n, capped at the configured page size.
+func (m *manifests) pageLimit(req *http.Request, def int) (int, *regError) {
+	n := def
+	if m.pageSize > 0 {
+		n = m.pageSize
+	}
+	if nStr := req.URL.Query().Get("n"); nStr != "" {
+		want, err := strconv.Atoi(nStr)
+		if err != nil || want < 0 {
+			return 0, &regError{
+				Status:  http.StatusBadRequest,
+				Code:    "PAGINATION_NUMBER_INVALID",
+				Message: fmt.Sprintf("invalid number of results requested: %q", nStr),
+			}
+		}
+		if want < n {
+			n = want
+		}
+	}
+	return n, nil
+}
+
+// paginate returns up to n of the sorted names that come after last, and
+// whether there are more to come. Asking for no results gets an empty page
+// with nothing more to come, since there's no last name to continue from.
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#listing-tags
+func paginate(names []string, n int, last string) ([]string, bool) {
+	if n == 0 {
+		return []string{}, false
+	}
+	sort.Strings(names)
+	if last != "" {
+		names = names[sort.SearchStrings(names, last):]
+		if len(names) > 0 && names[0] == last {
+			names = names[1:]
+		}
+	}
+	if len(names) > n {
+		return names[:n], true
+	}
+	return names, false
+}
+
+// setNextLink points to the page after last with an RFC 5988 Link header.
+func setNextLink(resp http.ResponseWriter, req *http.Request, n int, last string) {
+	q := url.Values{}
+	q.Set("n", strconv.Itoa(n))
+	q.Set("last", last)
+	next := url.URL{Path: req.URL.Path, RawQuery: q.Encode()}
+	resp.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+}
@@ -0,0 +1,290 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// metricsPath is where the registry serves Prometheus metrics when
+// WithMetricsEndpoint is used.
+const metricsPath = "/metrics"
+
+// Metrics collects statistics about the requests a registry handles. The zero
+// value is ready to use. See WithMetrics.
+type Metrics struct {
+	lock     sync.Mutex
+	requests map[RequestKey]int64
+	received int64
+	sent     int64
+	repos    map[string]*RepoStats
+
+	// blobs is the registry's blob handler, for storage stats.
+	blobs BlobHandler
+}
+
+// RequestKey identifies a kind of request, for counting them.
+type RequestKey struct {
+	Method string
+	Code   int
+}
+
+// Stats is a snapshot of a registry's statistics.
+type Stats struct {
+	// Requests counts requests by method and response status code.
+	Requests map[RequestKey]int64
+
+	// BytesReceived and BytesSent count request and response body bytes.
+	BytesReceived int64
+	BytesSent     int64
+
+	// Blobs and StorageBytes are the number and total size of the stored
+	// blobs. They're only set if the blob handler implements BlobListHandler
+	// and BlobStatHandler.
+	Blobs        int64
+	StorageBytes int64
+
+	// Repos has statistics for each repository that's been accessed.
+	Repos map[string]RepoStats
+}
+
+// RepoStats are the statistics for a single repository.
+type RepoStats struct {
+	// Requests counts requests for the repository's manifests, blobs and tags.
+	Requests int64
+
+	// BytesReceived and BytesSent count request and response body bytes.
+	BytesReceived int64
+	BytesSent     int64
+
+	// Pulls and Pushes count manifests that were successfully fetched with
+	// GET or uploaded.
+	Pulls  int64
+	Pushes int64
+
+	// Mounts counts blobs that were mounted into the repository from another
+	// one, and MountFallbacks requests to mount a blob that started an upload
+	// instead. See WithMountPolicy.
+	Mounts         int64
+	MountFallbacks int64
+}
+
+// WithMetrics records statistics about the registry's requests in m. Use
+// WithMetricsEndpoint to also serve them at /metrics.
+func WithMetrics(m *Metrics) Option {
+	return func(r *registry) {
+		r.metrics = m
+	}
+}
+
+// WithMetricsEndpoint serves the statistics recorded with WithMetrics at
+// /metrics in the Prometheus text format.
+//
+// The endpoint is unauthenticated: whatever WithBasicAuth, WithTokenAuth or
+// WithTenants say, anyone who can reach the registry can see the names of
+// all its repositories and their statistics. To keep them private, serve the
+// Metrics, which is an http.Handler, on a separate listener instead.
+func WithMetricsEndpoint() Option {
+	return func(r *registry) {
+		r.serveMetrics = true
+	}
+}
+
+// Stats returns a snapshot of the statistics collected so far.
+func (m *Metrics) Stats() Stats {
+	return m.stats(context.Background())
+}
+
+func (m *Metrics) stats(ctx context.Context) Stats {
+	m.lock.Lock()
+	s := Stats{
+		Requests:      make(map[RequestKey]int64, len(m.requests)),
+		BytesReceived: m.received,
+		BytesSent:     m.sent,
+		Repos:         make(map[string]RepoStats, len(m.repos)),
+	}
+	for k, v := range m.requests {
+		s.Requests[k] = v
+	}
+	for k, v := range m.repos {
+		s.Repos[k] = *v
+	}
+	blobs := m.blobs
+	m.lock.Unlock()
+
+	// Don't hold the lock while walking storage, which can be slow.
+	bl, lok := blobs.(BlobListHandler)
+	bs, sok := blobs.(BlobStatHandler)
+	if lok && sok {
+		if hs, err := bl.List(ctx); err == nil {
+			for _, h := range hs {
+				size, err := bs.Stat(ctx, "", h)
+				if err != nil {
+					continue
+				}
+				s.Blobs++
+				s.StorageBytes += size
+			}
+		}
+	}
+	return s
+}
+
+// record counts a request for repo (if any) that received and sent the given
+// number of body bytes.
+func (m *Metrics) record(req *http.Request, repo string, code int, received, sent int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.requests == nil {
+		m.requests = map[RequestKey]int64{}
+		m.repos = map[string]*RepoStats{}
+	}
+	m.requests[RequestKey{Method: req.Method, Code: code}]++
+	m.received += received
+	m.sent += sent
+
+	if repo == "" {
+		return
+	}
+	rs, ok := m.repos[repo]
+	if !ok {
+		rs = &RepoStats{}
+		m.repos[repo] = rs
+	}
+	rs.Requests++
+	rs.BytesReceived += received
+	rs.BytesSent += sent
+	if isManifest(req) {
+		if req.Method == http.MethodGet && code == http.StatusOK {
+			rs.Pulls++
+		} else if req.Method == http.MethodPut && code == http.StatusCreated {
+			rs.Pushes++
+		}
+	}
+	if isBlob(req) && req.Method == http.MethodPost && req.URL.Query().Get("mount") != "" && req.URL.Query().Get("from") != "" {
+		if code == http.StatusCreated {
+			rs.Mounts++
+		} else if code == http.StatusAccepted {
+			rs.MountFallbacks++
+		}
+	}
+}
+
+// ServeHTTP writes the statistics in the Prometheus text format.
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+func (m *Metrics) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	s := m.stats(req.Context())
+	resp.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(resp, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("registry_requests_total", "counter", "Requests handled, by method and status code.")
+	keys := make([]RequestKey, 0, len(s.Requests))
+	for k := range s.Requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Method != keys[j].Method {
+			return keys[i].Method < keys[j].Method
+		}
+		return keys[i].Code < keys[j].Code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(resp, "registry_requests_total{method=%s,code=\"%d\"} %d\n", strconv.Quote(k.Method), k.Code, s.Requests[k])
+	}
+
+	metric("registry_received_bytes_total", "counter", "Request body bytes received.")
+	fmt.Fprintf(resp, "registry_received_bytes_total %d\n", s.BytesReceived)
+	metric("registry_sent_bytes_total", "counter", "Response body bytes sent.")
+	fmt.Fprintf(resp, "registry_sent_bytes_total %d\n", s.BytesSent)
+	metric("registry_storage_blobs", "gauge", "Number of stored blobs.")
+	fmt.Fprintf(resp, "registry_storage_blobs %d\n", s.Blobs)
+	metric("registry_storage_bytes", "gauge", "Total size of stored blobs.")
+	fmt.Fprintf(resp, "registry_storage_bytes %d\n", s.StorageBytes)
+
+	repos := make([]string, 0, len(s.Repos))
+	for repo := range s.Repos {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	for _, rm := range []struct {
+		name, help string
+		value      func(RepoStats) int64
+	}{
+		{"registry_repository_requests_total", "Requests handled, by repository.", func(rs RepoStats) int64 { return rs.Requests }},
+		{"registry_repository_received_bytes_total", "Request body bytes received, by repository.", func(rs RepoStats) int64 { return rs.BytesReceived }},
+		{"registry_repository_sent_bytes_total", "Response body bytes sent, by repository.", func(rs RepoStats) int64 { return rs.BytesSent }},
+		{"registry_repository_pulls_total", "Manifests pulled, by repository.", func(rs RepoStats) int64 { return rs.Pulls }},
+		{"registry_repository_pushes_total", "Manifests pushed, by repository.", func(rs RepoStats) int64 { return rs.Pushes }},
+		{"registry_repository_mounts_total", "Blobs mounted from other repositories, by repository.", func(rs RepoStats) int64 { return rs.Mounts }},
+		{"registry_repository_mount_fallbacks_total", "Blob mounts that fell back to uploads, by repository.", func(rs RepoStats) int64 { return rs.MountFallbacks }},
+	} {
+		metric(rm.name, "counter", rm.help)
+		for _, repo := range repos {
+			fmt.Fprintf(resp, "%s{repository=%s} %d\n", rm.name, strconv.Quote(repo), rm.value(s.Repos[repo]))
+		}
+	}
+}
+
+// countingWriter records the status code and number of body bytes written to
+// a response.
+type countingWriter struct {
+	http.ResponseWriter
+	code int
+	n    int64
+}
+
+func (w *countingWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *countingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestMetrics(t *testing.T) {
+	m := &registry.Metrics{}
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)), registry.WithMetrics(m), registry.WithMetricsEndpoint()))
+	defer s.Close()
+
+	d, blobs := pushRandom(t, s, "foo:latest")
+	img, err := remote.Image(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := img.RawConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+	mf, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := m.Stats()
+	if got, want := stats.Blobs, int64(len(blobs)); got != want {
+		t.Errorf("Blobs = %d, want %d", got, want)
+	}
+	if stats.StorageBytes == 0 || stats.BytesReceived < stats.StorageBytes+int64(len(mf)) {
+		t.Errorf("StorageBytes = %d, BytesReceived = %d", stats.StorageBytes, stats.BytesReceived)
+	}
+	if got, want := stats.Requests[registry.RequestKey{Method: http.MethodPut, Code: http.StatusCreated}], int64(len(blobs)+1); got != want {
+		t.Errorf("PUT 201 requests = %d, want %d", got, want)
+	}
+	foo := stats.Repos["foo"]
+	if foo.Pushes != 1 || foo.Pulls != 1 {
+		t.Errorf("Pushes = %d, Pulls = %d, want 1 and 1", foo.Pushes, foo.Pulls)
+	}
+	if foo.BytesSent == 0 || foo.BytesSent > stats.BytesSent {
+		t.Errorf("BytesSent = %d, total %d", foo.BytesSent, stats.BytesSent)
+	}
+
+	resp, err := http.Get(s.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE registry_requests_total counter\n",
+		fmt.Sprintf("registry_requests_total{method=\"PUT\",code=\"201\"} %d\n", len(blobs)+1),
+		fmt.Sprintf("registry_storage_blobs %d\n", len(blobs)),
+		`registry_repository_pushes_total{repository="foo"} 1` + "\n",
+		`registry_repository_pulls_total{repository="foo"} 1` + "\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("/metrics doesn't contain %q:\n%s", want, b)
+		}
+	}
+
+	// Scrapes aren't counted.
+	if got, want := m.Stats().Requests, stats.Requests; len(got) != len(want) {
+		t.Errorf("Requests changed after scraping: %v != %v", got, want)
+	}
+}
+
+func TestMetricsEndpointIsOptIn(t *testing.T) {
+	s := httptest.NewServer(registry.New(
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+		registry.WithMetrics(&registry.Metrics{}),
+		registry.WithBasicAuth(map[string]string{"alice": "secret"}),
+	))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /metrics = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
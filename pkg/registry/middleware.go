@@ -0,0 +1,96 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestInfo describes a request handled by the registry.
+type RequestInfo struct {
+	Method string
+	Path   string
+
+	// Route is the kind of API endpoint the request matched, e.g.
+	// RouteManifests.
+	Route string
+
+	// Status is the status code of the response.
+	Status int
+
+	// Duration is how long the registry took to handle the request.
+	Duration time.Duration
+}
+
+// WithRequestHook calls f after every request the registry handles, in the
+// order they complete, so tests can assert on the exact requests a client
+// made. Calls to f are serialized.
+func WithRequestHook(f func(RequestInfo)) Option {
+	return func(r *registry) {
+		r.hooks.hooks = append(r.hooks.hooks, f)
+	}
+}
+
+// WithMiddleware wraps the registry's handler with mw. If it's passed more
+// than once, the first middleware is the outermost.
+func WithMiddleware(mw func(http.Handler) http.Handler) Option {
+	return func(r *registry) {
+		r.middleware = append(r.middleware, mw)
+	}
+}
+
+type hooks struct {
+	hooks []func(RequestInfo)
+	lock  sync.Mutex
+}
+
+func (h *hooks) call(info RequestInfo) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, f := range h.hooks {
+		f(info)
+	}
+}
+
+// handler returns the registry's handler wrapped with its hooks and
+// middleware.
+func (r *registry) handler() http.Handler {
+	var h http.Handler = http.HandlerFunc(r.root)
+	if len(r.hooks.hooks) != 0 {
+		next := h
+		h = http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			cw := &countingWriter{ResponseWriter: resp}
+			next.ServeHTTP(cw, req)
+			code := cw.code
+			if code == 0 {
+				code = http.StatusOK
+			}
+			r.hooks.call(RequestInfo{
+				Method:   req.Method,
+				Path:     req.URL.Path,
+				Route:    route(req),
+				Status:   code,
+				Duration: time.Since(start),
+			})
+		})
+	}
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	return h
+}
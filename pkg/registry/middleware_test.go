@@ -0,0 +1,107 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestRequestHook(t *testing.T) {
+	var got []registry.RequestInfo
+	s := httptest.NewServer(registry.New(quiet(), registry.WithRequestHook(func(info registry.RequestInfo) {
+		if info.Duration <= 0 {
+			t.Errorf("%s %s: Duration = %v", info.Method, info.Path, info.Duration)
+		}
+		info.Duration = 0
+		got = append(got, info)
+	})))
+	defer s.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Head(ref); err == nil {
+		t.Fatal("Head() succeeded for a missing image")
+	}
+
+	want := []registry.RequestInfo{{
+		Method: http.MethodGet,
+		Path:   "/v2/",
+		Route:  registry.RoutePing,
+		Status: http.StatusOK,
+	}, {
+		Method: http.MethodHead,
+		Path:   "/v2/foo/manifests/latest",
+		Route:  registry.RouteManifests,
+		Status: http.StatusNotFound,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %+v, want %+v", got, want)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var order []string
+	mw := func(label string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				order = append(order, label)
+				if req.Header.Get("X-Block") != "" {
+					http.Error(resp, "blocked", http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(resp, req)
+			})
+		}
+	}
+	s := httptest.NewServer(registry.New(quiet(), registry.WithMiddleware(mw("outer")), registry.WithMiddleware(mw("inner"))))
+	defer s.Close()
+
+	for _, block := range []bool{false, true} {
+		t.Run(fmt.Sprint(block), func(t *testing.T) {
+			order = nil
+			req, err := http.NewRequest(http.MethodGet, s.URL+"/v2/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, wantOrder := http.StatusOK, "outer,inner"
+			if block {
+				req.Header.Set("X-Block", "true")
+				want, wantOrder = http.StatusForbidden, "outer"
+			}
+			resp, err := s.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, want)
+			}
+			if got := strings.Join(order, ","); got != wantOrder {
+				t.Errorf("order = %s, want %s", got, wantOrder)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"errors"
+	"net/http"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// MountPolicy decides how the registry answers requests to mount a blob from
+// another repository (POST /v2/<name>/blobs/uploads/?mount=<digest>&from=<repo>).
+type MountPolicy int
+
+const (
+	// MountIfAllowed mounts blobs that exist if the client may pull from the
+	// repository they're mounted from. This is the default.
+	MountIfAllowed MountPolicy = iota
+
+	// MountAlways mounts blobs that exist, whether or not the client may pull
+	// from the repository they're mounted from.
+	MountAlways
+
+	// MountNever never mounts blobs, and starts an upload instead, like
+	// registries that don't support mounting.
+	MountNever
+)
+
+// WithMountPolicy sets how the registry answers requests to mount blobs from
+// other repositories. Use WithMetrics to count how many mounts succeeded and
+// how many fell back to uploads.
+func WithMountPolicy(p MountPolicy) Option {
+	return func(r *registry) {
+		r.blobs.mountPolicy = p
+	}
+}
+
+// mount mounts the blob h from the repository from into repo, reporting false
+// if the client should upload it instead.
+func (b *blobs) mount(resp http.ResponseWriter, req *http.Request, repo, from string, h v1.Hash) (bool, *regError) {
+	if b.mountPolicy == MountNever {
+		return false, nil
+	}
+	if b.mountPolicy == MountIfAllowed && b.auth != nil {
+		user, _ := req.Context().Value(userKey{}).(string)
+		if !b.auth.allows(req, user, from, "pull") {
+			return false, nil
+		}
+	}
+	size, err := blobSize(req.Context(), b.blobHandler, from, h)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, regErrInternal(err)
+	}
+	b.recent.touch(h)
+	b.events.notify(req, EventActionMount, "blobs", EventTarget{
+		MediaType:      "application/octet-stream",
+		Size:           size,
+		Digest:         h.String(),
+		Repository:     repo,
+		FromRepository: from,
+	})
+	resp.Header().Set("Location", "/v2/"+repo+"/blobs/"+h.String())
+	resp.Header().Set("Docker-Content-Digest", h.String())
+	resp.WriteHeader(http.StatusCreated)
+	return true, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestMountPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		policy registry.MountPolicy
+		user   string
+		want   int
+	}{{
+		desc:   "allowed",
+		policy: registry.MountIfAllowed,
+		user:   "bob",
+		want:   http.StatusCreated,
+	}, {
+		desc:   "not allowed",
+		policy: registry.MountIfAllowed,
+		user:   "alice",
+		want:   http.StatusAccepted,
+	}, {
+		desc:   "always",
+		policy: registry.MountAlways,
+		user:   "alice",
+		want:   http.StatusCreated,
+	}, {
+		desc:   "never",
+		policy: registry.MountNever,
+		user:   "bob",
+		want:   http.StatusAccepted,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			m := &registry.Metrics{}
+			s := httptest.NewServer(registry.New(quiet(),
+				registry.WithBasicAuth(users),
+				registry.WithTenants(
+					registry.Tenant{Namespace: "src", Push: []string{"bob"}},
+					registry.Tenant{Namespace: "dst", Push: []string{"alice", "bob"}}),
+				registry.WithMetrics(m),
+				registry.WithMountPolicy(tc.policy)))
+			defer s.Close()
+
+			_, blobs := pushRandomWithAuth(t, s, "src:latest", &authn.Basic{Username: "bob", Password: users["bob"]})
+			for _, mount := range []string{blobs[0].String(), "sha256:0000000000000000000000000000000000000000000000000000000000000000"} {
+				req, err := http.NewRequest(http.MethodPost, s.URL+"/v2/dst/blobs/uploads/?mount="+mount+"&from=src", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.SetBasicAuth(tc.user, users[tc.user])
+				resp, err := s.Client().Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+
+				// Blobs that don't exist can never be mounted.
+				want := tc.want
+				if mount != blobs[0].String() {
+					want = http.StatusAccepted
+				}
+				if resp.StatusCode != want {
+					t.Errorf("POST ?mount=%s = %d, want %d", mount, resp.StatusCode, want)
+				}
+				if want == http.StatusCreated {
+					if got, want := resp.Header.Get("Location"), "/v2/dst/blobs/"+mount; got != want {
+						t.Errorf("Location = %q, want %q", got, want)
+					}
+				}
+			}
+
+			var mounts int64
+			if tc.want == http.StatusCreated {
+				mounts = 1
+			}
+			rs := m.Stats().Repos["dst"]
+			if rs.Mounts != mounts || rs.MountFallbacks != 2-mounts {
+				t.Errorf("Mounts = %d, MountFallbacks = %d, want %d and %d", rs.Mounts, rs.MountFallbacks, mounts, 2-mounts)
+			}
+		})
+	}
+}
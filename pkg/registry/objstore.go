@@ -0,0 +1,265 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ObjectStore is the subset of an object storage service, like S3, GCS or
+// Azure Blob Storage, that the registry needs to keep its blobs and manifests
+// in one. See NewS3Store, NewGCSStore and NewAzureStore.
+//
+// Implementations must be safe for concurrent use.
+type ObjectStore interface {
+	// Get returns length bytes of the object starting at offset, or the rest
+	// of the object if length is negative, or ErrNotFound.
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+
+	// Stat returns the size of the object, or ErrNotFound.
+	Stat(ctx context.Context, key string) (int64, error)
+
+	// Put stores the contents of r as the object. The size is -1 if it's
+	// unknown, in which case the contents should be streamed rather than
+	// buffered.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+
+	// Delete removes the object, or returns ErrNotFound.
+	Delete(ctx context.Context, key string) error
+
+	// List returns the keys of the objects that start with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// WithObjectStore stores blobs and manifests in s, under "blobs/" and
+// "manifests/" respectively, with the same layout as WithStorageDir.
+func WithObjectStore(s ObjectStore) Option {
+	return func(r *registry) {
+		r.blobs.blobHandler = &objectBlobs{store: s}
+		r.manifests.store = &objectManifests{store: s}
+	}
+}
+
+const (
+	blobsPrefix     = "blobs/"
+	manifestsPrefix = "manifests/"
+)
+
+type objectBlobs struct {
+	store ObjectStore
+}
+
+func blobKey(h v1.Hash) string {
+	return blobsPrefix + h.Algorithm + "/" + h.Hex
+}
+
+func (o *objectBlobs) Stat(ctx context.Context, _ string, h v1.Hash) (int64, error) {
+	return o.store.Stat(ctx, blobKey(h))
+}
+
+func (o *objectBlobs) Get(ctx context.Context, _ string, h v1.Hash) (io.ReadCloser, error) {
+	return o.store.Get(ctx, blobKey(h), 0, -1)
+}
+
+func (o *objectBlobs) Put(ctx context.Context, _ string, h v1.Hash, rc io.ReadCloser) error {
+	defer rc.Close()
+	return o.store.Put(ctx, blobKey(h), rc, -1)
+}
+
+func (o *objectBlobs) Delete(ctx context.Context, _ string, h v1.Hash) error {
+	return o.store.Delete(ctx, blobKey(h))
+}
+
+func (o *objectBlobs) List(ctx context.Context) ([]v1.Hash, error) {
+	keys, err := o.store.List(ctx, blobsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	hs := make([]v1.Hash, 0, len(keys))
+	for _, k := range keys {
+		h, err := v1.NewHash(strings.Replace(strings.TrimPrefix(k, blobsPrefix), "/", ":", 1))
+		if err != nil {
+			continue
+		}
+		hs = append(hs, h)
+	}
+	return hs, nil
+}
+
+type objectManifests struct {
+	store ObjectStore
+}
+
+func (o *objectManifests) Get(ctx context.Context, repo, ref string) (Manifest, error) {
+	k, err := manifestKey(repo, ref)
+	if err != nil {
+		return Manifest{}, err
+	}
+	rc, err := o.store.Get(ctx, manifestsPrefix+k, 0, -1)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return Manifest{}, err
+	}
+	return decodeManifest(b)
+}
+
+func (o *objectManifests) Put(ctx context.Context, repo, ref string, m Manifest) error {
+	k, err := manifestKey(repo, ref)
+	if err != nil {
+		return err
+	}
+	r, size, err := encodeManifest(m)
+	if err != nil {
+		return err
+	}
+	return o.store.Put(ctx, manifestsPrefix+k, r, size)
+}
+
+func (o *objectManifests) Delete(ctx context.Context, repo, ref string) error {
+	k, err := manifestKey(repo, ref)
+	if err != nil {
+		return err
+	}
+	return o.store.Delete(ctx, manifestsPrefix+k)
+}
+
+func (o *objectManifests) Refs(ctx context.Context, repo string) ([]string, error) {
+	rk, err := repoKey(repo)
+	if err != nil {
+		return nil, err
+	}
+	prefix := manifestsPrefix + rk + "/"
+	keys, err := o.store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNotFound
+	}
+	refs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		k = strings.TrimPrefix(k, prefix)
+		if tag := strings.TrimPrefix(k, "tags/"); tag != k {
+			refs = append(refs, tag)
+		} else if d := strings.TrimPrefix(k, "digests/"); d != k {
+			refs = append(refs, strings.Replace(d, "/", ":", 1))
+		}
+	}
+	return refs, nil
+}
+
+func (o *objectManifests) Repos(ctx context.Context) ([]string, error) {
+	keys, err := o.store.List(ctx, manifestsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var repos []string
+	for _, k := range keys {
+		i := strings.Index(k, "/"+manifestsDir+"/")
+		if i < 0 {
+			continue
+		}
+		repo := strings.TrimPrefix(k[:i], manifestsPrefix)
+		if !seen[repo] {
+			seen[repo] = true
+			repos = append(repos, repo)
+		}
+	}
+	sort.Strings(repos)
+	return repos, nil
+}
+
+// chunkSize is how much of an upload of unknown size object stores buffer
+// at a time. S3 requires parts of at least 5MiB.
+const chunkSize = 8 << 20
+
+// firstChunk reads up to chunkSize bytes of r. If that's all of r, done is
+// true and the upload can be done in one request.
+func firstChunk(r io.Reader) (chunk []byte, done bool, err error) {
+	buf := make([]byte, chunkSize)
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return buf[:n], true, nil
+	}
+	return buf[:n], false, err
+}
+
+// chunks calls fn with successive chunks of r, numbered from 1, starting with
+// first. The chunk's buffer is reused once fn returns.
+func chunks(r io.Reader, first []byte, fn func(int, []byte) error) error {
+	buf := first
+	for i := 1; ; i++ {
+		if err := fn(i, buf); err != nil {
+			return err
+		}
+		n, err := io.ReadFull(r, buf[:cap(buf)])
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return fn(i+1, buf[:n])
+		case err != nil:
+			return err
+		}
+		buf = buf[:n]
+	}
+}
+
+// do sends the request, returning ErrNotFound for 404s and an error for any
+// status other than the wanted ones. The caller must close the body.
+func do(client *http.Client, req *http.Request, want ...int) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range want {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("%s %s: unexpected status %s: %s", req.Method, req.URL.Redacted(), resp.Status, bytes.TrimSpace(b))
+}
+
+// rangeHeader returns the value of the Range header for a ranged read, or ""
+// to read the whole object.
+func rangeHeader(offset, length int64) string {
+	switch {
+	case length >= 0:
+		return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	case offset > 0:
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return ""
+}
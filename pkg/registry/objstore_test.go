@@ -0,0 +1,452 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeObjects is the in-memory state of a fake object store.
+type fakeObjects struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	pending map[string][]byte // parts and blocks, by upload and number or ID
+}
+
+func newFakeObjects() *fakeObjects {
+	return &fakeObjects{objects: map[string][]byte{}, pending: map[string][]byte{}}
+}
+
+func (f *fakeObjects) put(key string, b []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = b
+}
+
+// serve serves the object, including ranged reads.
+func (f *fakeObjects) serve(w http.ResponseWriter, r *http.Request, key string) {
+	f.mu.Lock()
+	b, ok := f.objects[key]
+	f.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+}
+
+func (f *fakeObjects) remove(w http.ResponseWriter, key string, code int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.objects[key]; !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	delete(f.objects, key)
+	w.WriteHeader(code)
+}
+
+// list returns a page of up to 2 keys with the prefix after marker, and the
+// marker for the next page, to exercise pagination.
+func (f *fakeObjects) list(prefix, marker string) ([]string, string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) && k > marker {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 2 {
+		return keys[:2], keys[1]
+	}
+	return keys, ""
+}
+
+func fakeS3(t *testing.T, f *fakeObjects) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "unsigned", http.StatusForbidden)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+		q := r.URL.Query()
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodGet && q.Get("list-type") == "2":
+			keys, next := f.list(q.Get("prefix"), q.Get("continuation-token"))
+			var res struct {
+				XMLName               xml.Name `xml:"ListBucketResult"`
+				Contents              []struct{ Key string }
+				IsTruncated           bool
+				NextContinuationToken string
+			}
+			for _, k := range keys {
+				res.Contents = append(res.Contents, struct{ Key string }{k})
+			}
+			res.IsTruncated, res.NextContinuationToken = next != "", next
+			xml.NewEncoder(w).Encode(res)
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			fmt.Fprint(w, "<InitiateMultipartUploadResult><UploadId>upload</UploadId></InitiateMultipartUploadResult>")
+		case r.Method == http.MethodPut && q.Get("uploadId") != "":
+			f.put("part/"+q.Get("partNumber"), body)
+			w.Header().Set("ETag", `"etag-`+q.Get("partNumber")+`"`)
+		case r.Method == http.MethodPost && q.Get("uploadId") != "":
+			var complete struct {
+				Parts []struct {
+					PartNumber int
+					ETag       string
+				} `xml:"Part"`
+			}
+			if err := xml.Unmarshal(body, &complete); err != nil {
+				t.Errorf("CompleteMultipartUpload: %v", err)
+			}
+			var all []byte
+			for _, p := range complete.Parts {
+				if want := fmt.Sprintf(`"etag-%d"`, p.PartNumber); p.ETag != want {
+					t.Errorf("ETag = %s, want %s", p.ETag, want)
+				}
+				k := fmt.Sprintf("part/%d", p.PartNumber)
+				all = append(all, f.objects[k]...)
+				delete(f.objects, k)
+			}
+			f.put(key, all)
+			fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+		case r.Method == http.MethodPut:
+			f.put(key, body)
+		case r.Method == http.MethodDelete:
+			f.remove(w, key, http.StatusNoContent)
+		default:
+			f.serve(w, r, key)
+		}
+	}))
+}
+
+func fakeGCS(t *testing.T, f *fakeObjects) *httptest.Server {
+	const objects = "/storage/v1/b/bucket/o"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		if r.URL.Path == "/upload"+objects {
+			body, _ := ioutil.ReadAll(r.Body)
+			f.put(q.Get("name"), body)
+			fmt.Fprint(w, "{}")
+			return
+		}
+		if r.URL.Path == objects {
+			keys, next := f.list(q.Get("prefix"), q.Get("pageToken"))
+			var res struct {
+				Items []struct {
+					Name string `json:"name"`
+				} `json:"items"`
+				NextPageToken string `json:"nextPageToken,omitempty"`
+			}
+			for _, k := range keys {
+				res.Items = append(res.Items, struct {
+					Name string `json:"name"`
+				}{k})
+			}
+			res.NextPageToken = next
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		// Keys are escaped into a single path segment.
+		key, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), objects+"/"))
+		if err != nil || strings.Contains(strings.TrimPrefix(r.URL.EscapedPath(), objects+"/"), "/") {
+			t.Errorf("bad object path %q", r.URL.EscapedPath())
+		}
+		switch {
+		case r.Method == http.MethodDelete:
+			f.remove(w, key, http.StatusNoContent)
+		case q.Get("alt") == "media":
+			f.serve(w, r, key)
+		default:
+			f.mu.Lock()
+			b, ok := f.objects[key]
+			f.mu.Unlock()
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `{"name":%q,"size":"%d"}`, key, len(b))
+		}
+	}))
+}
+
+func fakeAzure(t *testing.T, f *fakeObjects) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("sig") != "secret" || r.Header.Get("x-ms-version") == "" {
+			http.Error(w, "unauthorized", http.StatusForbidden)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/container/")
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodGet && q.Get("comp") == "list":
+			keys, next := f.list(q.Get("prefix"), q.Get("marker"))
+			var res struct {
+				XMLName    xml.Name                `xml:"EnumerationResults"`
+				Blobs      []struct{ Name string } `xml:"Blobs>Blob"`
+				NextMarker string
+			}
+			for _, k := range keys {
+				res.Blobs = append(res.Blobs, struct{ Name string }{k})
+			}
+			res.NextMarker = next
+			xml.NewEncoder(w).Encode(res)
+		case r.Method == http.MethodPut && q.Get("comp") == "block":
+			f.put("block/"+q.Get("blockid"), body)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && q.Get("comp") == "blocklist":
+			var list struct {
+				Latest []string
+			}
+			if err := xml.Unmarshal(body, &list); err != nil {
+				t.Errorf("Put Block List: %v", err)
+			}
+			var all []byte
+			for _, id := range list.Latest {
+				all = append(all, f.objects["block/"+id]...)
+				delete(f.objects, "block/"+id)
+			}
+			f.put(key, all)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				t.Errorf("x-ms-blob-type = %q", r.Header.Get("x-ms-blob-type"))
+			}
+			f.put(key, body)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete:
+			f.remove(w, key, http.StatusAccepted)
+		default:
+			if rng := r.Header.Get("x-ms-range"); rng != "" {
+				r.Header.Set("Range", rng)
+			}
+			f.serve(w, r, key)
+		}
+	}))
+}
+
+func testObjectStore(t *testing.T, s ObjectStore) {
+	ctx := context.Background()
+
+	if _, err := s.Stat(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat(missing) = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Get(ctx, "missing", 0, -1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete(missing) = %v, want ErrNotFound", err)
+	}
+
+	small := []byte("hello, world")
+	// Big enough to need several parts or blocks.
+	big := make([]byte, chunkSize+1234)
+	rand.New(rand.NewSource(0)).Read(big)
+	for _, tc := range []struct {
+		key  string
+		b    []byte
+		size int64
+	}{
+		{"a/small", small, int64(len(small))},
+		{"a/streamed", small, -1},
+		{"b/big", big, -1},
+	} {
+		if err := s.Put(ctx, tc.key, bytes.NewReader(tc.b), tc.size); err != nil {
+			t.Fatalf("Put(%s): %v", tc.key, err)
+		}
+		if size, err := s.Stat(ctx, tc.key); err != nil {
+			t.Errorf("Stat(%s): %v", tc.key, err)
+		} else if size != int64(len(tc.b)) {
+			t.Errorf("Stat(%s) = %d, want %d", tc.key, size, len(tc.b))
+		}
+		rc, err := s.Get(ctx, tc.key, 0, -1)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", tc.key, err)
+		}
+		got, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tc.b) {
+			t.Errorf("Get(%s) returned %d bytes that don't match", tc.key, len(got))
+		}
+	}
+
+	rc, err := s.Get(ctx, "a/small", 7, 5)
+	if err != nil {
+		t.Fatalf("ranged Get: %v", err)
+	}
+	got, _ := ioutil.ReadAll(rc)
+	rc.Close()
+	if string(got) != "world" {
+		t.Errorf("ranged Get = %q, want %q", got, "world")
+	}
+
+	keys, err := s.List(ctx, "a/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	sort.Strings(keys)
+	if want := []string{"a/small", "a/streamed"}; strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("List(a/) = %v, want %v", keys, want)
+	}
+
+	if err := s.Delete(ctx, "a/small"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if _, err := s.Stat(ctx, "a/small"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestObjectStores(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		store func(f *fakeObjects) (ObjectStore, func())
+	}{{
+		name: "s3",
+		store: func(f *fakeObjects) (ObjectStore, func()) {
+			s := fakeS3(t, f)
+			return NewS3Store(S3Config{
+				Bucket:          "bucket",
+				Region:          "us-east-1",
+				Endpoint:        s.URL,
+				AccessKeyID:     "AKID",
+				SecretAccessKey: "secret",
+			}), s.Close
+		},
+	}, {
+		name: "gcs",
+		store: func(f *fakeObjects) (ObjectStore, func()) {
+			s := fakeGCS(t, f)
+			return NewGCSStore(GCSConfig{
+				Bucket:   "bucket",
+				Endpoint: s.URL,
+				Token:    func(context.Context) (string, error) { return "token", nil },
+			}), s.Close
+		},
+	}, {
+		name: "azure",
+		store: func(f *fakeObjects) (ObjectStore, func()) {
+			s := fakeAzure(t, f)
+			return NewAzureStore(AzureConfig{
+				Account:   "account",
+				Container: "container",
+				SAS:       "?sv=2020-10-02&sig=secret",
+				Endpoint:  s.URL,
+			}), s.Close
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			store, done := tc.store(newFakeObjects())
+			defer done()
+			testObjectStore(t, store)
+		})
+	}
+}
+
+func TestS3Query(t *testing.T) {
+	q := url.Values{"b": {"a b", "*"}, "a": {"x/y+z"}}
+	if got, want := s3Query(q), "a=x%2Fy%2Bz&b=%2A&b=a%20b"; got != want {
+		t.Errorf("s3Query() = %q, want %q", got, want)
+	}
+}
+
+func sha256Digest(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+func TestWithObjectStore(t *testing.T) {
+	f := newFakeObjects()
+	s := fakeS3(t, f)
+	defer s.Close()
+	store := NewS3Store(S3Config{
+		Bucket:          "bucket",
+		Region:          "us-east-1",
+		Endpoint:        s.URL,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	})
+	reg := httptest.NewServer(New(WithObjectStore(store), Logger(log.New(ioutil.Discard, "", 0))))
+	defer reg.Close()
+
+	blob := "some blob"
+	for _, req := range []struct {
+		method, path, body, contentType string
+		want                            int
+	}{
+		{http.MethodPost, "/v2/foo/bar/blobs/uploads/?digest=" + sha256Digest(blob), blob, "", http.StatusCreated},
+		{http.MethodGet, "/v2/foo/bar/blobs/" + sha256Digest(blob), "", "", http.StatusOK},
+		{http.MethodPut, "/v2/foo/bar/manifests/latest", "{}", "application/json", http.StatusCreated},
+		{http.MethodGet, "/v2/foo/bar/manifests/latest", "", "", http.StatusOK},
+		{http.MethodGet, "/v2/foo/bar/manifests/" + sha256Digest("{}"), "", "", http.StatusOK},
+		{http.MethodGet, "/v2/foo/baz/manifests/latest", "", "", http.StatusNotFound},
+	} {
+		r, err := http.NewRequest(req.method, reg.URL+req.path, strings.NewReader(req.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.contentType != "" {
+			r.Header.Set("Content-Type", req.contentType)
+		}
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != req.want {
+			t.Errorf("%s %s = %d, want %d", req.method, req.path, resp.StatusCode, req.want)
+		}
+	}
+
+	repos, err := (&objectManifests{store: store}).Repos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0] != "foo/bar" {
+		t.Errorf("Repos = %v, want [foo/bar]", repos)
+	}
+	if _, ok := f.objects["blobs/sha256/"+strings.TrimPrefix(sha256Digest(blob), "sha256:")]; !ok {
+		t.Errorf("blob not stored under blobs/: %v", f.objects)
+	}
+}
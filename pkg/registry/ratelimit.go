@@ -0,0 +1,179 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit configures a token bucket per client: each client can make Burst
+// requests at once, and Rate requests per second on average.
+type RateLimit struct {
+	// Rate is the number of requests per second that are added back to each
+	// client's bucket.
+	Rate float64
+
+	// Burst is the size of each client's bucket. Defaults to Rate, or 1 if
+	// that's less than 1.
+	Burst int
+
+	// Key identifies the client a request comes from. Defaults to the
+	// request's remote IP address.
+	Key func(*http.Request) string
+}
+
+// WithRateLimit responds with 429 Too Many Requests, and a Retry-After header
+// saying when to try again, to clients that exceed l. Like Docker Hub, it
+// also reports each client's limit in RateLimit-Limit and RateLimit-Remaining
+// headers.
+func WithRateLimit(l RateLimit) Option {
+	return func(r *registry) {
+		if l.Burst == 0 {
+			l.Burst = int(math.Max(1, l.Rate))
+		}
+		if l.Key == nil {
+			l.Key = remoteIP
+		}
+		r.limiter = &limiter{
+			cfg:     l,
+			buckets: map[string]*bucket{},
+			now:     time.Now,
+		}
+	}
+}
+
+// WithTooManyRequests responds with 429 Too Many Requests, and a Retry-After
+// header of retryAfter, to requests for which when returns true. This makes it
+// possible to exercise client retry logic deterministically, e.g. by failing
+// every other request, or only manifest requests.
+func WithTooManyRequests(when func(*http.Request) bool, retryAfter time.Duration) Option {
+	return func(r *registry) {
+		r.throttle = append(r.throttle, throttle{when: when, retryAfter: retryAfter})
+	}
+}
+
+type throttle struct {
+	when       func(*http.Request) bool
+	retryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type limiter struct {
+	cfg     RateLimit
+	buckets map[string]*bucket
+	lock    sync.Mutex
+	now     func() time.Time
+
+	// swept is when idle buckets were last forgotten.
+	swept time.Time
+}
+
+// take takes a token from the client's bucket, returning how many are left,
+// or how long until there's one if there aren't any.
+func (l *limiter) take(key string) (remaining int, wait time.Duration, ok bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(l.cfg.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.cfg.Burst), b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		if l.cfg.Rate <= 0 {
+			return 0, time.Hour, false
+		}
+		return 0, time.Duration((1 - b.tokens) / l.cfg.Rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return int(b.tokens), 0, true
+}
+
+// sweep forgets the buckets of clients that have been idle long enough for
+// their buckets to refill, since they're no different from new ones. Without
+// a Rate buckets never refill, so they're kept.
+func (l *limiter) sweep(now time.Time) {
+	if l.cfg.Rate <= 0 {
+		return
+	}
+	refill := time.Duration(float64(l.cfg.Burst) / l.cfg.Rate * float64(time.Second))
+	if now.Sub(l.swept) < refill {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
+
+// limit returns an error if req should be rejected for exceeding limits.
+func (r *registry) limit(resp http.ResponseWriter, req *http.Request) *regError {
+	for _, t := range r.throttle {
+		if t.when(req) {
+			return tooManyRequests(resp, t.retryAfter)
+		}
+	}
+	if r.limiter == nil {
+		return nil
+	}
+
+	remaining, wait, ok := r.limiter.take(r.limiter.cfg.Key(req))
+	window := ""
+	if r.limiter.cfg.Rate > 0 {
+		window = fmt.Sprintf(";w=%d", int(math.Ceil(float64(r.limiter.cfg.Burst)/r.limiter.cfg.Rate)))
+	}
+	resp.Header().Set("RateLimit-Limit", strconv.Itoa(r.limiter.cfg.Burst)+window)
+	resp.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining)+window)
+	if !ok {
+		return tooManyRequests(resp, wait)
+	}
+	return nil
+}
+
+func tooManyRequests(resp http.ResponseWriter, retryAfter time.Duration) *regError {
+	// Retry-After is in whole seconds, so round up, to not invite clients
+	// back too early.
+	resp.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	return &regError{
+		Status:  http.StatusTooManyRequests,
+		Code:    "TOOMANYREQUESTS",
+		Message: "too many requests, try again later",
+	}
+}
+
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestRateLimit(t *testing.T) {
+	s := httptest.NewServer(registry.New(
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+		registry.WithRateLimit(registry.RateLimit{
+			Rate:  0.01,
+			Burst: 2,
+			Key:   func(req *http.Request) string { return req.Header.Get("X-Client") },
+		}),
+	))
+	defer s.Close()
+
+	get := func(client string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, s.URL+"/v2/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Client", client)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	for i, want := range []string{"1;w=200", "0;w=200"} {
+		resp := get("a")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i, resp.StatusCode)
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != want {
+			t.Errorf("RateLimit-Remaining = %q, want %q", got, want)
+		}
+		if got, want := resp.Header.Get("RateLimit-Limit"), "2;w=200"; got != want {
+			t.Errorf("RateLimit-Limit = %q, want %q", got, want)
+		}
+	}
+
+	resp := get("a")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit = %d, want 429", resp.StatusCode)
+	}
+	if got, want := resp.Header.Get("Retry-After"), "100"; got != want {
+		t.Errorf("Retry-After = %q, want %q", got, want)
+	}
+
+	// Other clients have their own buckets.
+	if resp := get("b"); resp.StatusCode != http.StatusOK {
+		t.Errorf("other client = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestTooManyRequests(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	everyOther := func(req *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		return requests%2 == 0
+	}
+	s := httptest.NewServer(registry.New(
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+		registry.WithTooManyRequests(everyOther, time.Second),
+	))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/v2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("first request = %d, want 200", resp.StatusCode)
+	}
+	resp, err = http.Get(s.URL + "/v2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("second request = %d, want 429", resp.StatusCode)
+	}
+	if got, want := resp.Header.Get("Retry-After"), "1"; got != want {
+		t.Errorf("Retry-After = %q, want %q", got, want)
+	}
+
+	// Clients that retry get there eventually. Pings and HEAD responses
+	// aren't retried, so only throttle the first request for the repository
+	// with each other method.
+	seen := map[string]bool{}
+	s.Config.Handler = registry.New(
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+		registry.WithTooManyRequests(func(req *http.Request) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			key := req.Method
+			if req.Method == http.MethodHead || !strings.HasPrefix(req.URL.Path, "/v2/foo/") || seen[key] {
+				return false
+			}
+			seen[key] = true
+			return true
+		}, time.Second),
+	)
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backoff := remote.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 5}
+	if err := remote.Write(ref, img, remote.WithRetryBackoff(backoff)); err != nil {
+		t.Errorf("Write() = %v", err)
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPatch, http.MethodPut} {
+		if !seen[method] {
+			t.Errorf("no %s requests were throttled", method)
+		}
+	}
+}
@@ -25,19 +25,56 @@ package registry
 
 import (
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 type registry struct {
-	log       *log.Logger
-	blobs     blobs
-	manifests manifests
+	log          *log.Logger
+	blobs        blobs
+	manifests    manifests
+	auth         *auth
+	tls          tlsOptions
+	events       notifier
+	limiter      *limiter
+	throttle     []throttle
+	metrics      *Metrics
+	serveMetrics bool
+	readOnly     bool
+	faults       faults
+	hooks        hooks
+	middleware   []func(http.Handler) http.Handler
+	tenants      []Tenant
 }
 
 // https://docs.docker.com/registry/spec/api/#api-version-check
 // https://github.com/opencontainers/distribution-spec/blob/master/spec.md#api-version-check
 func (r *registry) v2(resp http.ResponseWriter, req *http.Request) *regError {
+	if rerr := r.limit(resp, req); rerr != nil {
+		return rerr
+	}
+	if r.auth != nil {
+		if r.auth.token && req.URL.Path == tokenPath {
+			return r.auth.handleToken(resp, req)
+		}
+		user, rerr := r.auth.check(resp, req)
+		if rerr != nil {
+			return rerr
+		}
+		req = withUser(req, user)
+	}
+	if r.readOnly && (isBlob(req) || isManifest(req)) && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return &regError{
+			Status:  http.StatusMethodNotAllowed,
+			Code:    "UNSUPPORTED",
+			Message: "The registry is in read-only mode",
+		}
+	}
 	if isBlob(req) {
 		return r.blobs.handle(resp, req)
 	}
@@ -62,8 +99,93 @@ func (r *registry) v2(resp http.ResponseWriter, req *http.Request) *regError {
 	return nil
 }
 
+// Routes, as matched by Fault.Routes.
+const (
+	RoutePing      = "ping"
+	RouteCatalog   = "catalog"
+	RouteTags      = "tags"
+	RouteManifests = "manifests"
+	RouteBlobs     = "blobs"
+	RouteUploads   = "uploads"
+	RouteToken     = "token"
+	RouteMetrics   = "metrics"
+	RouteUnknown   = "unknown"
+)
+
+// route returns which of the registry's routes req is for.
+func route(req *http.Request) string {
+	switch {
+	case req.URL.Path == tokenPath:
+		return RouteToken
+	case req.URL.Path == metricsPath:
+		return RouteMetrics
+	case isBlob(req):
+		if strings.Contains(req.URL.Path, "/blobs/uploads") {
+			return RouteUploads
+		}
+		return RouteBlobs
+	case isManifest(req):
+		return RouteManifests
+	case isTags(req):
+		return RouteTags
+	case isCatalog(req):
+		return RouteCatalog
+	case req.URL.Path == "/v2" || req.URL.Path == "/v2/":
+		return RoutePing
+	}
+	return RouteUnknown
+}
+
+// repository returns the name of the repository req is for, if any.
+func repository(req *http.Request) (string, bool) {
+	elem := strings.Split(strings.TrimSuffix(req.URL.Path, "/"), "/")
+	switch {
+	case isBlob(req) && elem[len(elem)-2] == "uploads":
+		// /v2/<repo>/blobs/uploads/<id>
+		return path.Join(elem[2 : len(elem)-3]...), true
+	case isBlob(req), isManifest(req), isTags(req):
+		// /v2/<repo>/blobs/uploads, /v2/<repo>/blobs/<digest>, etc.
+		return path.Join(elem[2 : len(elem)-2]...), true
+	}
+	return "", false
+}
+
 func (r *registry) root(resp http.ResponseWriter, req *http.Request) {
-	if rerr := r.v2(resp, req); rerr != nil {
+	if r.metrics != nil {
+		if r.serveMetrics && req.URL.Path == metricsPath {
+			r.metrics.ServeHTTP(resp, req)
+			return
+		}
+		cw := &countingWriter{ResponseWriter: resp}
+		var body *countingBody
+		if req.Body != nil {
+			body = &countingBody{ReadCloser: req.Body}
+			req.Body = body
+		}
+		defer func() {
+			code := cw.code
+			if code == 0 {
+				code = http.StatusOK
+			}
+			var received int64
+			if body != nil {
+				received = body.n
+			}
+			repo, _ := repository(req)
+			r.metrics.record(req, repo, code, received, cw.n)
+		}()
+		resp = cw
+	}
+
+	fw, rerr := r.faults.inject(resp, req)
+	if fw != nil {
+		defer fw.finish()
+		resp = fw
+	}
+	if rerr == nil {
+		rerr = r.v2(resp, req)
+	}
+	if rerr != nil {
 		r.log.Printf("%s %s %d %s %s", req.Method, req.URL, rerr.Status, rerr.Code, rerr.Message)
 		rerr.Write(resp)
 		return
@@ -78,17 +200,39 @@ func New(opts ...Option) http.Handler {
 		log: log.New(os.Stderr, "", log.LstdFlags),
 		blobs: blobs{
 			blobHandler: &memHandler{m: map[string][]byte{}},
-			uploads:     map[string][]byte{},
+			uploads:     &memUploads{m: map[string][]byte{}},
 		},
 		manifests: manifests{
-			manifests: map[string]map[string]manifest{},
-			log:       log.New(os.Stderr, "", log.LstdFlags),
+			store: &memManifests{m: map[string]map[string]Manifest{}},
+			log:   log.New(os.Stderr, "", log.LstdFlags),
 		},
 	}
 	for _, o := range opts {
 		o(r)
 	}
-	return http.HandlerFunc(r.root)
+	r.manifests.blobs = r.blobs.blobHandler
+	if len(r.tenants) != 0 {
+		if r.auth == nil {
+			r.auth = &auth{open: true}
+		}
+		r.auth.tenants = r.tenants
+		r.manifests.tenants = r.tenants
+	}
+	r.blobs.auth = r.auth
+	if r.faults.rand == nil {
+		r.faults.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if r.metrics != nil {
+		r.metrics.lock.Lock()
+		r.metrics.blobs = r.blobs.blobHandler
+		r.metrics.lock.Unlock()
+	}
+	r.events.client = &http.Client{Timeout: 10 * time.Second}
+	r.events.instance = randomID()
+	r.events.log = r.log
+	r.blobs.events = &r.events
+	r.manifests.events = &r.events
+	return r.handler()
 }
 
 // Option describes the available options
@@ -102,3 +246,46 @@ func Logger(l *log.Logger) Option {
 		r.manifests.log = l
 	}
 }
+
+// ReadOnly rejects all pushes and deletes with 405 Method Not Allowed, like a
+// registry that's frozen or in maintenance mode.
+func ReadOnly() Option {
+	return func(r *registry) {
+		r.readOnly = true
+	}
+}
+
+// WithPageSize limits the number of tags or repositories returned per page,
+// so clients have to follow Link headers to see them all. It's also the
+// default number of results when clients don't ask for one.
+func WithPageSize(n int) Option {
+	return func(r *registry) {
+		r.manifests.pageSize = n
+	}
+}
+
+// WithBlobHandler stores blobs with h rather than in memory. Pushes are only
+// supported if h also implements BlobPutHandler.
+func WithBlobHandler(h BlobHandler) Option {
+	return func(r *registry) {
+		r.blobs.blobHandler = h
+	}
+}
+
+// WithManifestStore stores manifests in s rather than in memory.
+func WithManifestStore(s ManifestStore) Option {
+	return func(r *registry) {
+		r.manifests.store = s
+	}
+}
+
+// WithStorageDir persists blobs, manifests and in-progress uploads in dir, so
+// that the registry keeps its contents across restarts. See
+// NewDiskBlobHandler, NewDiskManifestStore and WithUploadDir.
+func WithStorageDir(dir string) Option {
+	return func(r *registry) {
+		r.blobs.blobHandler = NewDiskBlobHandler(filepath.Join(dir, "blobs"))
+		r.manifests.store = NewDiskManifestStore(filepath.Join(dir, "manifests"))
+		r.blobs.uploads = &diskUploads{dir: filepath.Join(dir, "uploads")}
+	}
+}
@@ -15,8 +15,10 @@
 package registry_test
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -26,7 +28,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
 
 const (
@@ -401,6 +407,40 @@ func TestCalls(t *testing.T) {
 			URL:         "/v2/foo/tags/list?n=1000",
 			Code:        http.StatusOK,
 		},
+		{
+			Description: "list tags paginated",
+			Manifests:   map[string]string{"foo/manifests/a": "a", "foo/manifests/b": "b", "foo/manifests/c": "c"},
+			Method:      "GET",
+			URL:         "/v2/foo/tags/list?n=1&last=a",
+			Code:        http.StatusOK,
+			Header:      map[string]string{"Link": `</v2/foo/tags/list?last=b&n=1>; rel="next"`},
+			Want:        `{"name":"foo","tags":["b"]}`,
+		},
+		{
+			Description: "list tags last page",
+			Manifests:   map[string]string{"foo/manifests/a": "a", "foo/manifests/b": "b", "foo/manifests/c": "c"},
+			Method:      "GET",
+			URL:         "/v2/foo/tags/list?n=1&last=b",
+			Code:        http.StatusOK,
+			Header:      map[string]string{"Link": ""},
+			Want:        `{"name":"foo","tags":["c"]}`,
+		},
+		{
+			Description: "list tags n=0",
+			Manifests:   map[string]string{"foo/manifests/a": "a", "foo/manifests/b": "b"},
+			Method:      "GET",
+			URL:         "/v2/foo/tags/list?n=0",
+			Code:        http.StatusOK,
+			Header:      map[string]string{"Link": ""},
+			Want:        `{"name":"foo","tags":[]}`,
+		},
+		{
+			Description: "list tags invalid n",
+			Manifests:   map[string]string{"foo/manifests/latest": "foo"},
+			Method:      "GET",
+			URL:         "/v2/foo/tags/list?n=lots",
+			Code:        http.StatusBadRequest,
+		},
 		{
 			Description: "list non existing tags",
 			Method:      "GET",
@@ -414,6 +454,24 @@ func TestCalls(t *testing.T) {
 			URL:         "/v2/_catalog?n=1000",
 			Code:        http.StatusOK,
 		},
+		{
+			Description: "list repos paginated",
+			Manifests:   map[string]string{"foo/manifests/latest": "foo", "bar/manifests/latest": "bar", "baz/manifests/latest": "baz"},
+			Method:      "GET",
+			URL:         "/v2/_catalog?n=2",
+			Code:        http.StatusOK,
+			Header:      map[string]string{"Link": `</v2/_catalog?last=baz&n=2>; rel="next"`},
+			Want:        `{"repositories":["bar","baz"]}`,
+		},
+		{
+			Description: "list repos n=0",
+			Manifests:   map[string]string{"foo/manifests/latest": "foo", "bar/manifests/latest": "bar"},
+			Method:      "GET",
+			URL:         "/v2/_catalog?n=0",
+			Code:        http.StatusOK,
+			Header:      map[string]string{"Link": ""},
+			Want:        `{"repositories":[]}`,
+		},
 	}
 
 	for _, tc := range tcs {
@@ -536,3 +594,107 @@ func TestCalls(t *testing.T) {
 		t.Run(tc.Description+" - custom log", testf)
 	}
 }
+
+func TestPageSize(t *testing.T) {
+	var pages int
+	r := registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)), registry.WithPageSize(2))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/tags/list") || strings.HasSuffix(req.URL.Path, "/_catalog") {
+			pages++
+		}
+		r.ServeHTTP(w, req)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{"foo:a", "foo:b", "foo:c", "foo:d", "foo:e", "bar:a", "baz:a"} {
+		tag, err := name.NewTag(u.Host + "/" + ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(tag, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo, err := name.NewRepository(u.Host + "/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := remote.List(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(tags, ","), "a,b,c,d,e"; got != want {
+		t.Errorf("List() = %s, want %s", got, want)
+	}
+	if got, want := pages, 3; got != want {
+		t.Errorf("listing tags took %d pages, want %d", got, want)
+	}
+
+	pages = 0
+	repos, err := remote.Catalog(context.Background(), repo.Registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(repos, ","), "bar,baz,foo"; got != want {
+		t.Errorf("Catalog() = %s, want %s", got, want)
+	}
+	if got, want := pages, 2; got != want {
+		t.Errorf("listing repos took %d pages, want %d", got, want)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)), registry.WithStorageDir(dir)))
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(u.Host + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Reads still work, but writes don't.
+	s = httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)), registry.WithStorageDir(dir), registry.ReadOnly()))
+	defer s.Close()
+	u, err = url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err = name.NewTag(u.Host + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Image(tag); err != nil {
+		t.Errorf("Image() = %v", err)
+	}
+	if _, err := remote.List(tag.Context()); err != nil {
+		t.Errorf("List() = %v", err)
+	}
+
+	var terr *transport.Error
+	if err := remote.Write(tag.Context().Tag("other"), img); !errors.As(err, &terr) || terr.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Write() = %v, want 405", err)
+	}
+	if err := remote.Delete(tag); !errors.As(err, &terr) || terr.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Delete() = %v, want 405", err)
+	}
+}
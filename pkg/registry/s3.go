@@ -0,0 +1,394 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3 ObjectStore.
+type S3Config struct {
+	// Bucket is the name of the bucket. It must already exist.
+	Bucket string
+
+	// Region is the bucket's region, e.g. "us-east-1". It defaults to
+	// AWS_REGION.
+	Region string
+
+	// Endpoint is the URL of an S3-compatible service, e.g. MinIO. Buckets
+	// are addressed by path there. By default, AWS's virtual-hosted
+	// endpoint for the region is used.
+	Endpoint string
+
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials to
+	// sign requests with. They default to AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Client is the HTTP client to use. It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type s3Store struct {
+	cfg S3Config
+}
+
+// NewS3Store returns an ObjectStore backed by an S3 bucket.
+//
+// Requests are signed with AWS Signature Version 4. Uploads of unknown size
+// are streamed as multipart uploads, buffering one part at a time.
+func NewS3Store(cfg S3Config) ObjectStore {
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_REGION")
+	}
+	if cfg.AccessKeyID == "" {
+		cfg.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &s3Store{cfg: cfg}
+}
+
+// url returns the URL of the object with the given key, or of the bucket if
+// key is empty.
+func (s *s3Store) url(key string, query url.Values) string {
+	var base string
+	if s.cfg.Endpoint != "" {
+		base = strings.TrimSuffix(s.cfg.Endpoint, "/") + "/" + s.cfg.Bucket
+	} else {
+		base = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", s.cfg.Bucket, s.cfg.Region)
+	}
+	u := base + "/" + s3Escape(key)
+	if len(query) > 0 {
+		u += "?" + s3Query(query)
+	}
+	return u
+}
+
+func (s *s3Store) request(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.url(key, query), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	s.sign(req, time.Now().UTC())
+	return req, nil
+}
+
+// sign adds an AWS Signature Version 4 to the request. The payload isn't
+// signed, so that it can be streamed.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func (s *s3Store) sign(req *http.Request, now time.Time) {
+	if s.cfg.AccessKeyID == "" {
+		return
+	}
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-amz-") || lk == "content-type" || lk == "range" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3Query(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + s.cfg.SecretAccessKey)
+	for _, part := range []string{date, s.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes a key as S3 expects in paths: everything but unreserved
+// characters and slashes.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Query returns the canonical form of a query, which is sorted by key and
+// then value, with keys and values escaped like paths, slashes included.
+func s3Query(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string{}, q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, strings.ReplaceAll(s3Escape(k), "/", "%2F")+"="+strings.ReplaceAll(s3Escape(v), "/", "%2F"))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func (s *s3Store) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(key, nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	if r := rangeHeader(offset, length); r != "" {
+		req.Header.Set("Range", r)
+	}
+	s.sign(req, time.Now().UTC())
+	resp, err := do(s.cfg.Client, req, http.StatusOK, http.StatusPartialContent)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3Store) Stat(ctx context.Context, key string) (int64, error) {
+	req, err := s.request(ctx, http.MethodHead, key, nil, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := do(s.cfg.Client, req, http.StatusOK)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if size < 0 {
+		chunk, done, err := firstChunk(r)
+		if err != nil {
+			return err
+		}
+		if !done {
+			return s.putMultipart(ctx, key, r, chunk)
+		}
+		r, size = bytes.NewReader(chunk), int64(len(chunk))
+	}
+	req, err := s.request(ctx, http.MethodPut, key, nil, r, size)
+	if err != nil {
+		return err
+	}
+	resp, err := do(s.cfg.Client, req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+type s3Part struct {
+	PartNumber int
+	ETag       string
+}
+
+// putMultipart streams r as a multipart upload, one chunk per part.
+func (s *s3Store) putMultipart(ctx context.Context, key string, r io.Reader, first []byte) (err error) {
+	req, err := s.request(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp, err := do(s.cfg.Client, req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("initiating multipart upload: %w", err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		// Abort the upload, so its parts don't linger and cost money.
+		req, rerr := s.request(context.Background(), http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil, 0)
+		if rerr != nil {
+			return
+		}
+		if resp, rerr := do(s.cfg.Client, req, http.StatusNoContent); rerr == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	var parts []s3Part
+	if err := chunks(r, first, func(n int, chunk []byte) error {
+		q := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {initiated.UploadID}}
+		req, err := s.request(ctx, http.MethodPut, key, q, bytes.NewReader(chunk), int64(len(chunk)))
+		if err != nil {
+			return err
+		}
+		resp, err := do(s.cfg.Client, req, http.StatusOK)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		parts = append(parts, s3Part{PartNumber: n, ETag: resp.Header.Get("ETag")})
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	req, err = s.request(ctx, http.MethodPost, key, url.Values{"uploadId": {initiated.UploadID}}, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	resp, err = do(s.cfg.Client, req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Completion can fail after a 200, with an error in the body.
+	var result struct {
+		XMLName xml.Name
+		Message string
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("completing multipart upload: %w", err)
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("completing multipart upload: %s", result.Message)
+	}
+	return nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	// S3 doesn't say whether the object existed, so check first.
+	if _, err := s.Stat(ctx, key); err != nil {
+		return err
+	}
+	req, err := s.request(ctx, http.MethodDelete, key, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp, err := do(s.cfg.Client, req, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		req, err := s.request(ctx, http.MethodGet, "", q, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := do(s.cfg.Client, req, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", prefix, err)
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
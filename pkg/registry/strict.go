@@ -0,0 +1,158 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// WithStrictValidation makes the registry validate pushed manifests like
+// stricter registries do. Manifests must be well-formed OCI or Docker schema 2
+// images or indexes whose Content-Type matches their mediaType, and every
+// blob and manifest they reference must already be in the repository with
+// the size given by its descriptor.
+//
+// Malformed manifests are rejected with MANIFEST_INVALID, references to
+// missing blobs with MANIFEST_BLOB_UNKNOWN, and references to missing
+// manifests with MANIFEST_UNKNOWN.
+func WithStrictValidation() Option {
+	return func(r *registry) {
+		r.manifests.strict = true
+	}
+}
+
+func manifestInvalid(format string, args ...interface{}) *regError {
+	return &regError{
+		Status:  http.StatusBadRequest,
+		Code:    "MANIFEST_INVALID",
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// validate checks that mf is a well-formed manifest whose references all
+// exist in repo.
+func (m *manifests) validate(ctx context.Context, repo string, mf Manifest) *regError {
+	mt := types.MediaType(strings.TrimSpace(strings.SplitN(mf.ContentType, ";", 2)[0]))
+	if !mt.IsImage() && !mt.IsIndex() {
+		return manifestInvalid("unsupported manifest media type %q", mt)
+	}
+
+	// Unlike v1.ParseManifest, json.Unmarshal rejects trailing data.
+	var header struct {
+		SchemaVersion int64           `json:"schemaVersion"`
+		MediaType     types.MediaType `json:"mediaType"`
+	}
+	if err := json.Unmarshal(mf.Blob, &header); err != nil {
+		return manifestInvalid("parsing manifest: %v", err)
+	}
+	if header.SchemaVersion != 2 {
+		return manifestInvalid("schemaVersion is %d, want 2", header.SchemaVersion)
+	}
+	if header.MediaType != "" && header.MediaType != mt {
+		return manifestInvalid("mediaType %q does not match Content-Type %q", header.MediaType, mt)
+	}
+
+	var descs []v1.Descriptor
+	if mt.IsIndex() {
+		im, err := v1.ParseIndexManifest(bytes.NewReader(mf.Blob))
+		if err != nil {
+			return manifestInvalid("parsing index: %v", err)
+		}
+		descs = im.Manifests
+	} else {
+		man, err := v1.ParseManifest(bytes.NewReader(mf.Blob))
+		if err != nil {
+			return manifestInvalid("parsing manifest: %v", err)
+		}
+		if man.Config.Digest == (v1.Hash{}) {
+			return manifestInvalid("manifest has no config")
+		}
+		descs = append([]v1.Descriptor{man.Config}, man.Layers...)
+	}
+
+	for _, desc := range descs {
+		if desc.Digest == (v1.Hash{}) {
+			return manifestInvalid("descriptor has no digest")
+		}
+		if desc.Size < 0 {
+			return manifestInvalid("descriptor %s has negative size %d", desc.Digest, desc.Size)
+		}
+		if !desc.MediaType.IsDistributable() {
+			continue
+		}
+		if mt.IsIndex() && (desc.MediaType.IsIndex() || desc.MediaType.IsImage()) {
+			child, err := m.store.Get(ctx, repo, desc.Digest.String())
+			if errors.Is(err, ErrNotFound) {
+				return &regError{
+					Status:  http.StatusNotFound,
+					Code:    "MANIFEST_UNKNOWN",
+					Message: fmt.Sprintf("Sub-manifest %q not found", desc.Digest),
+				}
+			} else if err != nil {
+				return regErrInternal(err)
+			}
+			if int64(len(child.Blob)) != desc.Size {
+				return manifestInvalid("descriptor for %s has size %d, but it is %d bytes", desc.Digest, desc.Size, len(child.Blob))
+			}
+			continue
+		}
+
+		size, err := blobSize(ctx, m.blobs, repo, desc.Digest)
+		if errors.Is(err, ErrNotFound) {
+			return &regError{
+				Status:  http.StatusBadRequest,
+				Code:    "MANIFEST_BLOB_UNKNOWN",
+				Message: fmt.Sprintf("blob %s not found", desc.Digest),
+			}
+		} else if err != nil {
+			return regErrInternal(err)
+		}
+		if size >= 0 && size != desc.Size {
+			return manifestInvalid("descriptor for %s has size %d, but it is %d bytes", desc.Digest, desc.Size, size)
+		}
+	}
+	return nil
+}
+
+// blobSize returns the size of a blob, or -1 if it's stored elsewhere.
+func blobSize(ctx context.Context, bh BlobHandler, repo string, h v1.Hash) (int64, error) {
+	var rerr RedirectError
+	if bsh, ok := bh.(BlobStatHandler); ok {
+		size, err := bsh.Stat(ctx, repo, h)
+		if errors.As(err, &rerr) {
+			return -1, nil
+		}
+		return size, err
+	}
+	rc, err := bh.Get(ctx, repo, h)
+	if errors.As(err, &rerr) {
+		return -1, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	return io.Copy(ioutil.Discard, rc)
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestStrictValidation(t *testing.T) {
+	s := httptest.NewServer(registry.New(quiet(), registry.WithStrictValidation()))
+	defer s.Close()
+
+	// Well-formed images and indexes can still be pushed.
+	d, _ := pushRandom(t, s, "foo:image")
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(d.Context().Tag("index"), idx); err != nil {
+		t.Fatalf("WriteIndex() = %v", err)
+	}
+
+	img, err := remote.Image(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := v1.Hash{Algorithm: "sha256", Hex: "0000000000000000000000000000000000000000000000000000000000000000"}
+
+	for _, tc := range []struct {
+		desc   string
+		mt     types.MediaType
+		body   interface{}
+		status int
+		code   transport.ErrorCode
+	}{{
+		desc:   "not json",
+		mt:     types.DockerManifestSchema2,
+		body:   "{",
+		status: http.StatusBadRequest,
+		code:   "MANIFEST_INVALID",
+	}, {
+		desc:   "unsupported media type",
+		mt:     types.DockerManifestSchema1Signed,
+		body:   base,
+		status: http.StatusBadRequest,
+		code:   "MANIFEST_INVALID",
+	}, {
+		desc: "wrong schema version",
+		mt:   types.DockerManifestSchema2,
+		body: func() *v1.Manifest {
+			m := base.DeepCopy()
+			m.SchemaVersion = 1
+			return m
+		}(),
+		status: http.StatusBadRequest,
+		code:   "MANIFEST_INVALID",
+	}, {
+		desc:   "mismatched media type",
+		mt:     types.OCIManifestSchema1,
+		body:   base,
+		status: http.StatusBadRequest,
+		code:   "MANIFEST_INVALID",
+	}, {
+		desc: "missing layer",
+		mt:   types.DockerManifestSchema2,
+		body: func() *v1.Manifest {
+			m := base.DeepCopy()
+			m.Layers[0].Digest = missing
+			return m
+		}(),
+		status: http.StatusBadRequest,
+		code:   "MANIFEST_BLOB_UNKNOWN",
+	}, {
+		desc: "wrong config size",
+		mt:   types.DockerManifestSchema2,
+		body: func() *v1.Manifest {
+			m := base.DeepCopy()
+			m.Config.Size++
+			return m
+		}(),
+		status: http.StatusBadRequest,
+		code:   "MANIFEST_INVALID",
+	}, {
+		desc: "missing child manifest",
+		mt:   types.OCIImageIndex,
+		body: &v1.IndexManifest{
+			SchemaVersion: 2,
+			MediaType:     types.OCIImageIndex,
+			Manifests: []v1.Descriptor{{
+				MediaType: types.OCIManifestSchema1,
+				Digest:    missing,
+				Size:      100,
+			}},
+		},
+		status: http.StatusNotFound,
+		code:   "MANIFEST_UNKNOWN",
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var b []byte
+			if s, ok := tc.body.(string); ok {
+				b = []byte(s)
+			} else if b, err = json.Marshal(tc.body); err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest(http.MethodPut, s.URL+"/v2/foo/manifests/bad", bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", string(tc.mt))
+			resp, err := s.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			wantCode(t, transport.CheckError(resp, http.StatusCreated), tc.status, tc.code)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import "strings"
+
+// Tenant is a namespace of repositories and the users that may access them.
+type Tenant struct {
+	// Namespace is the repository, or the parent of the repositories, that
+	// the tenant owns. For example, "acme" covers both "acme" and
+	// "acme/app".
+	Namespace string
+
+	// Pull is the users that may pull from the tenant's repositories. The
+	// empty string stands for anonymous clients.
+	Pull []string
+
+	// Push is the users that may push to and delete from the tenant's
+	// repositories. Pushing implies pulling.
+	Push []string
+}
+
+// WithTenants scopes repositories to tenants: every request for a repository
+// must be allowed by a tenant whose namespace covers it, and repositories
+// outside of every namespace can't be accessed at all. The catalog only lists
+// the repositories that the client may pull from.
+//
+// Tenants apply to clients authenticated with WithBasicAuth or WithTokenAuth,
+// in addition to TokenAuth.Authorize, and to anonymous clients otherwise.
+// Requests that basic or anonymous clients aren't allowed to make get a 403
+// Forbidden with a DENIED error, while token clients aren't granted the
+// access in the first place.
+func WithTenants(tenants ...Tenant) Option {
+	return func(r *registry) {
+		r.tenants = append(r.tenants, tenants...)
+	}
+}
+
+func (t Tenant) covers(repo string) bool {
+	return repo == t.Namespace || strings.HasPrefix(repo, t.Namespace+"/")
+}
+
+// permitted reports whether one of tenants allows user to perform action on
+// repo.
+func permitted(tenants []Tenant, user, repo, action string) bool {
+	for _, t := range tenants {
+		if !t.covers(repo) {
+			continue
+		}
+		if contains(t.Push, user) || (action == "pull" && contains(t.Pull, user)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+var tenants = []registry.Tenant{{
+	Namespace: "acme",
+	Pull:      []string{"bob"},
+	Push:      []string{"alice"},
+}, {
+	Namespace: "bob",
+	Push:      []string{"bob"},
+}, {
+	Namespace: "public",
+	Pull:      []string{""},
+	Push:      []string{"alice", "bob"},
+}}
+
+func pull(t *testing.T, s *httptest.Server, repo string, auth authn.Authenticator) error {
+	t.Helper()
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/" + repo + ":latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = remote.Image(ref, remote.WithAuth(auth))
+	return err
+}
+
+func TestTenants(t *testing.T) {
+	alice := &authn.Basic{Username: "alice", Password: "secret"}
+	bob := &authn.Basic{Username: "bob", Password: "hunter2"}
+
+	for _, tc := range []struct {
+		desc   string
+		auth   registry.Option
+		denied int
+	}{{
+		desc:   "basic",
+		auth:   registry.WithBasicAuth(users),
+		denied: http.StatusForbidden,
+	}, {
+		desc:   "token",
+		auth:   registry.WithTokenAuth(registry.TokenAuth{Users: users}),
+		denied: http.StatusUnauthorized,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := httptest.NewServer(registry.New(quiet(), tc.auth, registry.WithTenants(tenants...)))
+			defer s.Close()
+
+			for _, repo := range []string{"acme/app", "acme", "public/app"} {
+				if err := push(t, s, repo, alice); err != nil {
+					t.Errorf("alice pushing %s: %v", repo, err)
+				}
+			}
+			if err := push(t, s, "bob/app", bob); err != nil {
+				t.Errorf("bob pushing bob/app: %v", err)
+			}
+
+			// Bob can only pull from acme, and nobody can touch repositories
+			// outside of every tenant.
+			wantStatus(t, push(t, s, "acme/app", bob), tc.denied)
+			wantStatus(t, push(t, s, "bob/app", alice), tc.denied)
+			wantStatus(t, push(t, s, "acmefoo", alice), tc.denied)
+			wantStatus(t, push(t, s, "other", alice), tc.denied)
+			if err := pull(t, s, "acme/app", bob); err != nil {
+				t.Errorf("bob pulling acme/app: %v", err)
+			}
+		})
+	}
+}
+
+func TestTenantsCatalog(t *testing.T) {
+	alice := &authn.Basic{Username: "alice", Password: "secret"}
+	bob := &authn.Basic{Username: "bob", Password: "hunter2"}
+
+	for _, tc := range []struct {
+		desc string
+		auth registry.Option
+	}{{
+		desc: "basic",
+		auth: registry.WithBasicAuth(users),
+	}, {
+		desc: "token",
+		auth: registry.WithTokenAuth(registry.TokenAuth{Users: users}),
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := httptest.NewServer(registry.New(quiet(), tc.auth, registry.WithTenants(tenants...)))
+			defer s.Close()
+
+			for _, repo := range []string{"acme/app", "public/app"} {
+				if err := push(t, s, repo, alice); err != nil {
+					t.Fatalf("alice pushing %s: %v", repo, err)
+				}
+			}
+			if err := push(t, s, "bob/app", bob); err != nil {
+				t.Fatalf("bob pushing bob/app: %v", err)
+			}
+
+			reg, err := name.NewRegistry(strings.TrimPrefix(s.URL, "http://"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []struct {
+				auth authn.Authenticator
+				want []string
+			}{
+				{alice, []string{"acme/app", "public/app"}},
+				{bob, []string{"acme/app", "bob/app", "public/app"}},
+			} {
+				got, err := remote.Catalog(context.Background(), reg, remote.WithAuth(c.auth))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.Join(got, ",") != strings.Join(c.want, ",") {
+					t.Errorf("Catalog(%v) = %v, want %v", c.auth, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTenantsAnonymous(t *testing.T) {
+	s := httptest.NewServer(registry.New(quiet(), registry.WithTenants(registry.Tenant{
+		Namespace: "public",
+		Push:      []string{""},
+	})))
+	defer s.Close()
+
+	if err := push(t, s, "public/app", authn.Anonymous); err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(t, push(t, s, "private/app", authn.Anonymous), http.StatusForbidden)
+}
+
+func TestMountPermissions(t *testing.T) {
+	s := httptest.NewServer(registry.New(quiet(), registry.WithBasicAuth(users), registry.WithTenants(tenants...)))
+	defer s.Close()
+
+	_, blobs := pushRandomWithAuth(t, s, "acme/app:latest", &authn.Basic{Username: "alice", Password: "secret"})
+
+	for _, tc := range []struct {
+		desc       string
+		user, pass string
+		repo, from string
+		want       int
+	}{{
+		desc: "allowed",
+		user: "bob", pass: "hunter2",
+		repo: "bob/app", from: "acme/app",
+		want: http.StatusCreated,
+	}, {
+		desc: "source not readable",
+		user: "alice", pass: "secret",
+		repo: "public/app", from: "bob/app",
+		want: http.StatusAccepted,
+	}, {
+		desc: "source outside every tenant",
+		user: "alice", pass: "secret",
+		repo: "acme/app", from: "other",
+		want: http.StatusAccepted,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, s.URL+"/v2/"+tc.repo+"/blobs/uploads/?mount="+blobs[0].String()+"&from="+tc.from, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetBasicAuth(tc.user, tc.pass)
+			resp, err := s.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Errorf("POST = %d, want %d", resp.StatusCode, tc.want)
+			}
+		})
+	}
+}
@@ -15,15 +15,92 @@
 package registry
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"net/http/httptest"
 
 	ggcrtest "github.com/google/go-containerregistry/internal/httptest"
 )
 
+type tlsOptions struct {
+	certPEM, keyPEM []byte
+	clientCAs       *x509.CertPool
+	clientAuth      tls.ClientAuthType
+}
+
+// WithTLS serves the registry with the given PEM encoded certificate (chain)
+// and private key. It only takes effect for servers created by TLS, or with
+// the config returned by TLSConfig.
+func WithTLS(certPEM, keyPEM []byte) Option {
+	return func(r *registry) {
+		r.tls.certPEM = certPEM
+		r.tls.keyPEM = keyPEM
+	}
+}
+
+// WithClientCAs requires clients to present a certificate signed by one of
+// the CAs in pool, for testing mutual TLS.
+func WithClientCAs(pool *x509.CertPool) Option {
+	return func(r *registry) {
+		r.tls.clientCAs = pool
+		if r.tls.clientAuth == tls.NoClientCert {
+			r.tls.clientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+}
+
+// WithClientAuth sets the policy for client certificates, e.g. to only
+// verify them if given. It defaults to tls.RequireAndVerifyClientCert when
+// WithClientCAs is used, and tls.NoClientCert otherwise.
+func WithClientAuth(auth tls.ClientAuthType) Option {
+	return func(r *registry) {
+		r.tls.clientAuth = auth
+	}
+}
+
+// TLSConfig returns the TLS configuration to serve the registry with, as set
+// by WithTLS, WithClientCAs and WithClientAuth, for use with an http.Server.
+func TLSConfig(opts ...Option) (*tls.Config, error) {
+	r := &registry{}
+	for _, o := range opts {
+		o(r)
+	}
+	if r.tls.certPEM == nil {
+		return nil, errors.New("no certificate given, see WithTLS")
+	}
+	return r.tls.config()
+}
+
+func (o *tlsOptions) config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ClientCAs:  o.clientCAs,
+		ClientAuth: o.clientAuth,
+	}
+	if o.certPEM != nil {
+		c, err := tls.X509KeyPair(o.certPEM, o.keyPEM)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{c}
+	}
+	return cfg, nil
+}
+
 // TLS returns an httptest server, with an http client that has been configured to
-// send all requests to the returned server. The TLS certs are generated for the given domain
-// which should correspond to the domain the image is stored in.
-// If you need a transport, Client().Transport is correctly configured.
-func TLS(domain string) (*httptest.Server, error) {
-	return ggcrtest.NewTLSServer(domain, New())
+// send all requests to the returned server. Unless a certificate is given with
+// WithTLS, TLS certs are generated for the given domain, which should correspond
+// to the domain the image is stored in.
+// If you need a transport, Client().Transport is correctly configured to trust
+// the server's certificate; for mTLS, add a client certificate to its TLSClientConfig.
+func TLS(domain string, opts ...Option) (*httptest.Server, error) {
+	r := &registry{}
+	for _, o := range opts {
+		o(r)
+	}
+	cfg, err := r.tls.config()
+	if err != nil {
+		return nil, err
+	}
+	return ggcrtest.NewTLSServerWithConfig(domain, New(opts...), cfg)
 }
@@ -15,7 +15,18 @@
 package registry_test
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
@@ -47,3 +58,205 @@ func TestTLS(t *testing.T) {
 		t.Fatalf("Unable to write image to remote: %s", err)
 	}
 }
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newCert returns a certificate from template, signed by parent, or
+// self-signed if parent is nil.
+func newCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cb, kb bytes.Buffer
+	if err := pem.Encode(&cb, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pem.Encode(&kb, &pem.Block{Type: "EC PRIVATE KEY", Bytes: ek}); err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, certPEM: cb.Bytes(), keyPEM: kb.Bytes()}
+}
+
+func newCA(t *testing.T) *testCert {
+	return newCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil)
+}
+
+func pool(certs ...*testCert) *x509.CertPool {
+	p := x509.NewCertPool()
+	for _, c := range certs {
+		p.AddCert(c.cert)
+	}
+	return p
+}
+
+func writeRandom(t *testing.T, domain string, tr http.RoundTripper) error {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(domain + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return remote.Write(ref, img, remote.WithTransport(tr))
+}
+
+func TestWithTLS(t *testing.T) {
+	ca := newCA(t)
+	server := newCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "registry.example.com"},
+		DNSNames:    []string{"registry.example.com"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+
+	s, err := registry.TLS("registry.example.com", registry.WithTLS(server.certPEM, server.keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if !s.Certificate().Equal(server.cert) {
+		t.Error("server isn't using the given certificate")
+	}
+
+	tr := s.Client().Transport.(*http.Transport)
+	if err := writeRandom(t, "registry.example.com", tr); err != nil {
+		t.Fatalf("trusting the server certificate: %v", err)
+	}
+
+	// Trusting the CA is enough.
+	tr = tr.Clone()
+	tr.TLSClientConfig.RootCAs = pool(ca)
+	if err := writeRandom(t, "registry.example.com", tr); err != nil {
+		t.Errorf("trusting the CA: %v", err)
+	}
+
+	// The certificate only covers the name it was issued for.
+	if err := writeRandom(t, "other.example.com", tr); err == nil {
+		t.Error("expected a certificate error for the wrong server name")
+	}
+
+	// Trusting something else isn't.
+	tr = tr.Clone()
+	tr.TLSClientConfig.RootCAs = pool(newCA(t))
+	if err := writeRandom(t, "registry.example.com", tr); err == nil {
+		t.Error("expected a certificate error for an untrusted CA")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newCA(t)
+	client := newCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	stranger := newCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "stranger"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, newCA(t))
+
+	for _, tc := range []struct {
+		desc    string
+		auth    tls.ClientAuthType
+		cert    *testCert
+		wantErr bool
+	}{{
+		desc: "trusted client cert",
+		cert: client,
+	}, {
+		desc:    "no client cert",
+		wantErr: true,
+	}, {
+		desc:    "untrusted client cert",
+		cert:    stranger,
+		wantErr: true,
+	}, {
+		desc: "optional, no client cert",
+		auth: tls.VerifyClientCertIfGiven,
+	}, {
+		desc:    "optional, untrusted client cert",
+		auth:    tls.VerifyClientCertIfGiven,
+		cert:    stranger,
+		wantErr: true,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := []registry.Option{registry.WithClientCAs(pool(ca))}
+			if tc.auth != tls.NoClientCert {
+				opts = append(opts, registry.WithClientAuth(tc.auth))
+			}
+			s, err := registry.TLS("registry.example.com", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			tr := s.Client().Transport.(*http.Transport)
+			if tc.cert != nil {
+				c, err := tls.X509KeyPair(tc.cert.certPEM, tc.cert.keyPEM)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tr.TLSClientConfig.Certificates = []tls.Certificate{c}
+			}
+			if err := writeRandom(t, "registry.example.com", tr); (err != nil) != tc.wantErr {
+				t.Errorf("Write() = %v, wantErr %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	if _, err := registry.TLSConfig(); err == nil {
+		t.Error("TLSConfig() without a certificate should fail")
+	}
+	if _, err := registry.TLSConfig(registry.WithTLS([]byte("nope"), []byte("nope"))); err == nil {
+		t.Error("TLSConfig() with a bogus certificate should fail")
+	}
+
+	ca := newCA(t)
+	cfg, err := registry.TLSConfig(registry.WithTLS(ca.certPEM, ca.keyPEM), registry.WithClientCAs(pool(ca)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.ClientAuth, tls.RequireAndVerifyClientCert; got != want {
+		t.Errorf("ClientAuth = %v, want %v", got, want)
+	}
+	if len(cfg.Certificates) != 1 {
+		t.Errorf("len(Certificates) = %d, want 1", len(cfg.Certificates))
+	}
+}
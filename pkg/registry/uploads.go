@@ -0,0 +1,203 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithUploadDir keeps the contents of in-progress blob uploads in dir rather
+// than in memory, so that clients can resume them after the registry
+// restarts. WithStorageDir does this too.
+func WithUploadDir(dir string) Option {
+	return func(r *registry) {
+		r.blobs.uploads = &diskUploads{dir: dir}
+	}
+}
+
+// WithMinChunkSize rejects chunks of chunked uploads (PATCH requests with a
+// Content-Range) smaller than n bytes, and advertises n to clients in the
+// OCI-Chunk-Min-Length header. The last chunk of an upload can be smaller, but
+// has to be sent with the PUT that completes the upload.
+func WithMinChunkSize(n int64) Option {
+	return func(r *registry) {
+		r.blobs.minChunk = n
+	}
+}
+
+// uploadStore keeps the contents of in-progress blob uploads. Callers
+// serialize access.
+type uploadStore interface {
+	// size returns the number of bytes uploaded so far, or ErrNotFound if
+	// there's no such upload.
+	size(id string) (int64, error)
+
+	// create starts a new, empty upload.
+	create(id string) error
+
+	// append adds the contents of r to the upload, creating it if needed.
+	// If reading r fails, the upload is left as it was.
+	append(id string, r io.Reader) error
+
+	// open returns the contents of the upload so far.
+	open(id string) (io.ReadCloser, error)
+
+	// delete discards the upload.
+	delete(id string) error
+}
+
+type memUploads struct {
+	m map[string][]byte
+}
+
+func (m *memUploads) size(id string) (int64, error) {
+	b, ok := m.m[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return int64(len(b)), nil
+}
+
+func (m *memUploads) create(id string) error {
+	m.m[id] = []byte{}
+	return nil
+}
+
+func (m *memUploads) append(id string, r io.Reader) error {
+	// If the copy fails, m.m[id] still has the old length.
+	l := bytes.NewBuffer(m.m[id])
+	if _, err := io.Copy(l, r); err != nil {
+		return err
+	}
+	m.m[id] = l.Bytes()
+	return nil
+}
+
+func (m *memUploads) open(id string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(m.m[id])), nil
+}
+
+func (m *memUploads) delete(id string) error {
+	delete(m.m, id)
+	return nil
+}
+
+type diskUploads struct {
+	dir string
+}
+
+func (d *diskUploads) path(id string) (string, error) {
+	if id == "" || strings.HasPrefix(id, ".") || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid upload id %q", id)
+	}
+	return filepath.Join(d.dir, id), nil
+}
+
+func (d *diskUploads) size(id string) (int64, error) {
+	p, err := d.path(id)
+	if err != nil {
+		return 0, err
+	}
+	fi, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (d *diskUploads) create(id string) error {
+	p, err := d.path(id)
+	if err != nil {
+		return err
+	}
+	return writeFile(p, bytes.NewReader(nil))
+}
+
+func (d *diskUploads) append(id string, r io.Reader) error {
+	p, err := d.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	start, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		// Drop the partial chunk, so that the client can retry it.
+		f.Truncate(start)
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (d *diskUploads) open(id string) (io.ReadCloser, error) {
+	p, err := d.path(id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+	return f, err
+}
+
+func (d *diskUploads) delete(id string) error {
+	p, err := d.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+var regErrUploadUnknown = &regError{
+	Status:  http.StatusNotFound,
+	Code:    "BLOB_UPLOAD_UNKNOWN",
+	Message: "Unknown upload",
+}
+
+// setUploadStatus sets the headers that tell clients where to continue an
+// upload of the given size.
+func setUploadStatus(resp http.ResponseWriter, repo, id string, size int64) {
+	resp.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/"+id)
+	resp.Header().Set("Docker-Upload-UUID", id)
+	if size == 0 {
+		resp.Header().Set("Range", "0-0")
+	} else {
+		resp.Header().Set("Range", fmt.Sprintf("0-%d", size-1))
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+type uploader struct {
+	t   *testing.T
+	url string
+}
+
+func (u *uploader) do(method, path string, header map[string]string, body string) *http.Response {
+	u.t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.url+path, r)
+	if err != nil {
+		u.t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		u.t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func (u *uploader) start() string {
+	u.t.Helper()
+	resp := u.do(http.MethodPost, "/v2/foo/blobs/uploads/", nil, "")
+	if resp.StatusCode != http.StatusAccepted {
+		u.t.Fatalf("POST = %d", resp.StatusCode)
+	}
+	return resp.Header.Get("Location")
+}
+
+func (u *uploader) chunk(loc string, offset int, chunk string) *http.Response {
+	u.t.Helper()
+	return u.do(http.MethodPatch, loc, map[string]string{
+		"Content-Range": fmt.Sprintf("%d-%d", offset, offset+len(chunk)-1),
+	}, chunk)
+}
+
+func (u *uploader) status(loc string) *http.Response {
+	u.t.Helper()
+	return u.do(http.MethodGet, loc, nil, "")
+}
+
+func (u *uploader) finish(loc, last, contents string) *http.Response {
+	u.t.Helper()
+	sum := sha256.Sum256([]byte(contents))
+	return u.do(http.MethodPut, loc+"?digest=sha256:"+hex.EncodeToString(sum[:]), nil, last)
+}
+
+func quiet() registry.Option {
+	return registry.Logger(log.New(ioutil.Discard, "", 0))
+}
+
+func TestChunkedUpload(t *testing.T) {
+	s := httptest.NewServer(registry.New(quiet()))
+	defer s.Close()
+	u := &uploader{t: t, url: s.URL}
+
+	loc := u.start()
+	if resp := u.chunk(loc, 0, "hello "); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PATCH = %d", resp.StatusCode)
+	}
+	if got, want := u.status(loc).Header.Get("Range"), "0-5"; got != want {
+		t.Errorf("Range = %q, want %q", got, want)
+	}
+	if resp := u.chunk(loc, 3, "oops"); resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("out of order PATCH = %d, want 416", resp.StatusCode)
+	}
+	if resp := u.chunk(loc, 6, "chunked "); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PATCH = %d", resp.StatusCode)
+	}
+	if resp := u.finish(loc, "world", "hello chunked world"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT = %d", resp.StatusCode)
+	}
+	if resp := u.status(loc); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status of finished upload = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestCancelUpload(t *testing.T) {
+	s := httptest.NewServer(registry.New(quiet()))
+	defer s.Close()
+	u := &uploader{t: t, url: s.URL}
+
+	loc := u.start()
+	u.chunk(loc, 0, "hello")
+	if resp := u.do(http.MethodDelete, loc, nil, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", resp.StatusCode)
+	}
+	if resp := u.status(loc); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status of canceled upload = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestMinChunkSize(t *testing.T) {
+	s := httptest.NewServer(registry.New(quiet(), registry.WithMinChunkSize(5)))
+	defer s.Close()
+	u := &uploader{t: t, url: s.URL}
+
+	resp := u.do(http.MethodPost, "/v2/foo/blobs/uploads/", nil, "")
+	if got, want := resp.Header.Get("OCI-Chunk-Min-Length"), "5"; got != want {
+		t.Errorf("OCI-Chunk-Min-Length = %q, want %q", got, want)
+	}
+	loc := resp.Header.Get("Location")
+
+	if resp := u.chunk(loc, 0, "hi"); resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("small PATCH = %d, want 416", resp.StatusCode)
+	}
+	if resp := u.chunk(loc, 0, "hello"); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PATCH = %d", resp.StatusCode)
+	}
+	// The last chunk can be small.
+	if resp := u.finish(loc, "!", "hello!"); resp.StatusCode != http.StatusCreated {
+		t.Errorf("PUT = %d", resp.StatusCode)
+	}
+}
+
+func TestResumeUploadAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	s := httptest.NewServer(registry.New(quiet(), registry.WithStorageDir(dir)))
+	u := &uploader{t: t, url: s.URL}
+
+	loc := u.start()
+	u.chunk(loc, 0, "hello ")
+	s.Close()
+
+	s = httptest.NewServer(registry.New(quiet(), registry.WithStorageDir(dir)))
+	defer s.Close()
+	u.url = s.URL
+
+	resp := u.status(loc)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status after restart = %d, want 204", resp.StatusCode)
+	}
+	if got, want := resp.Header.Get("Range"), "0-5"; got != want {
+		t.Errorf("Range = %q, want %q", got, want)
+	}
+	if resp := u.chunk(loc, 6, "again"); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PATCH = %d", resp.StatusCode)
+	}
+	if resp := u.finish(loc, "", "hello again"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT = %d", resp.StatusCode)
+	}
+
+	sum := sha256.Sum256([]byte("hello again"))
+	if resp := u.do(http.MethodHead, "/v2/foo/blobs/sha256:"+hex.EncodeToString(sum[:]), nil, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("HEAD = %d, want 200", resp.StatusCode)
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retention evaluates declarative retention policies against
+// repository listings, to plan which images to delete.
+//
+// Listings come from google.List and google.Walk, azure.Manifests and
+// azure.Walk, or List, which works with any registry but can only see tagged
+// images. ECR listings can be converted with aws.Retention, from the separate
+// github.com/google/go-containerregistry/pkg/aws module.  The
+// resulting plan is a list of Deletions; executing it is up to the caller,
+// e.g. gcrane gc or crane gc.
+package retention
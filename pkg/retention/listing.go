@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"sort"
+
+	"github.com/google/go-containerregistry/pkg/azure"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// FromGoogle returns the images in a listing from google.List or
+// google.Walk.
+func FromGoogle(tags *google.Tags) []Image {
+	images := make([]Image, 0, len(tags.Manifests))
+	for digest, m := range tags.Manifests {
+		images = append(images, Image{
+			Digest:    digest,
+			Tags:      m.Tags,
+			MediaType: m.MediaType,
+			Uploaded:  m.Uploaded,
+		})
+	}
+	sortByDigest(images)
+	return images
+}
+
+// FromACR returns the images in a listing from azure.Manifests or azure.Walk.
+// ACR records when each manifest was pushed, which is used as its upload time.
+func FromACR(manifests []azure.Manifest) []Image {
+	images := make([]Image, 0, len(manifests))
+	for _, m := range manifests {
+		images = append(images, Image{
+			Digest:    m.Digest,
+			Tags:      m.Tags,
+			MediaType: m.MediaType,
+			Uploaded:  m.Created,
+		})
+	}
+	sortByDigest(images)
+	return images
+}
+
+// List returns the tagged images in repo, using only the registry API, which
+// works with any registry.  The API can't list untagged images, or say when
+// an image was uploaded, so each image's Uploaded is its config's creation
+// time, and is zero for indexes.
+func List(repo name.Repository, opts ...remote.Option) ([]Image, error) {
+	tags, err := remote.List(repo, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	index := map[string]int{}
+	var images []Image
+	for _, tag := range tags {
+		desc, err := remote.Get(repo.Tag(tag), opts...)
+		if err != nil {
+			return nil, err
+		}
+		digest := desc.Digest.String()
+		if i, ok := index[digest]; ok {
+			images[i].Tags = append(images[i].Tags, tag)
+			continue
+		}
+
+		img := Image{
+			Digest:    digest,
+			Tags:      []string{tag},
+			MediaType: desc.MediaType,
+		}
+		if desc.MediaType.IsImage() {
+			i, err := desc.Image()
+			if err != nil {
+				return nil, err
+			}
+			cf, err := i.ConfigFile()
+			if err != nil {
+				return nil, err
+			}
+			img.Uploaded = cf.Created.Time
+		}
+		index[digest] = len(images)
+		images = append(images, img)
+	}
+	sortByDigest(images)
+	return images, nil
+}
+
+func sortByDigest(images []Image) {
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Digest < images[j].Digest
+	})
+}
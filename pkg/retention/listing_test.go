@@ -0,0 +1,125 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/azure"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestFromGoogle(t *testing.T) {
+	uploaded := time.Unix(1000, 0)
+	got := FromGoogle(&google.Tags{
+		Manifests: map[string]google.ManifestInfo{
+			"sha256:b": {Tags: []string{"latest"}, Uploaded: uploaded},
+			"sha256:a": {MediaType: types.OCIImageIndex},
+		},
+	})
+	want := []Image{
+		{Digest: "sha256:a", MediaType: types.OCIImageIndex},
+		{Digest: "sha256:b", Tags: []string{"latest"}, Uploaded: uploaded},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FromGoogle() (-want +got) = %s", diff)
+	}
+}
+
+func TestFromACR(t *testing.T) {
+	created := time.Unix(1000, 0)
+	got := FromACR([]azure.Manifest{
+		{Digest: "sha256:b", Tags: []string{"latest"}, Created: created},
+		{Digest: "sha256:a", MediaType: types.OCIImageIndex},
+	})
+	want := []Image{
+		{Digest: "sha256:a", MediaType: types.OCIImageIndex},
+		{Digest: "sha256:b", Tags: []string{"latest"}, Uploaded: created},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FromACR() (-want +got) = %s", diff)
+	}
+}
+
+func TestList(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/retention")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Unix(1000, 0).UTC()
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img, err = mutate.CreatedAt(img, v1.Time{Time: created}); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(64, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"a", "b"} {
+		if err := remote.Write(repo.Tag(tag), img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := remote.WriteIndex(repo.Tag("index"), idx); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := List(repo)
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+
+	imgDigest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxDigest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgMediaType, err := img.MediaType()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Image{
+		{Digest: imgDigest.String(), Tags: []string{"a", "b"}, MediaType: imgMediaType, Uploaded: created},
+		{Digest: idxDigest.String(), Tags: []string{"index"}, MediaType: types.OCIImageIndex},
+	}
+	sortByDigest(want)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("List() (-want +got) = %s", diff)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy declares which images in a repository to delete.  Its zero value
+// deletes nothing.
+//
+// Untagged images are deleted according to Untagged.  Tagged images are
+// deleted if some Keep rule applies to them but none keeps them, unless they
+// are protected by ProtectSemver or Protect.  Tagged images that no Keep rule
+// applies to are kept.
+//
+// Policies can be read from JSON, e.g.
+//
+//	{
+//	  "untagged": {"olderThan": "7d"},
+//	  "keep": [{"tags": "^main-", "last": 10}],
+//	  "protectSemver": true
+//	}
+type Policy struct {
+	// Untagged, if set, deletes untagged images.
+	Untagged *UntaggedRule `json:"untagged,omitempty"`
+
+	// Keep holds rules that each keep the newest images among those with a
+	// tag matching a pattern.
+	Keep []KeepRule `json:"keep,omitempty"`
+
+	// ProtectSemver protects images with a tag that is a semantic version
+	// release, e.g. "1.2.3" or "v1.2.3", but not "v1.2.3-rc.1".
+	ProtectSemver bool `json:"protectSemver,omitempty"`
+
+	// Protect protects images with a tag matching any of these.
+	Protect []Pattern `json:"protect,omitempty"`
+}
+
+// UntaggedRule selects untagged images for deletion.
+type UntaggedRule struct {
+	// OlderThan only selects images that were uploaded more than this long
+	// ago.  If zero, every untagged image is selected.  Images whose upload
+	// time isn't known are only selected if it is zero.
+	OlderThan Duration `json:"olderThan,omitempty"`
+}
+
+// KeepRule applies to the tagged images with a tag matching Tags, and keeps
+// the Last most recently uploaded of them.  Images whose upload time isn't
+// known count as the oldest.
+type KeepRule struct {
+	// Tags selects the images the rule applies to.  If empty, it applies to
+	// every tagged image.
+	Tags Pattern `json:"tags"`
+
+	// Last is how many of those images to keep.
+	Last int `json:"last"`
+}
+
+// Parse parses a Policy from JSON, rejecting unknown fields so that typos
+// don't silently loosen it.
+func Parse(b []byte) (*Policy, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parsing retention policy: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate returns an error if p is inconsistent.
+func (p *Policy) Validate() error {
+	if p.Untagged != nil && p.Untagged.OlderThan < 0 {
+		return fmt.Errorf("untagged.olderThan is negative: %s", p.Untagged.OlderThan)
+	}
+	for i, k := range p.Keep {
+		if k.Last < 0 {
+			return fmt.Errorf("keep[%d].last is negative: %d", i, k.Last)
+		}
+	}
+	return nil
+}
+
+// semver matches semantic version releases, with an optional "v" prefix and
+// build metadata, but no pre-release version.
+var semver = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(\+[0-9A-Za-z.-]+)?$`)
+
+// protected reports whether p protects an image with tags.
+func (p *Policy) protected(tags []string) bool {
+	for _, tag := range tags {
+		if p.ProtectSemver && semver.MatchString(tag) {
+			return true
+		}
+		for _, re := range p.Protect {
+			if re.MatchString(tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Pattern is a regular expression, encoded in JSON as a string.  The zero
+// Pattern matches everything.
+type Pattern struct {
+	re *regexp.Regexp
+}
+
+// Compile parses a Pattern.
+func Compile(expr string) (Pattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Pattern{}, err
+	}
+	return Pattern{re: re}, nil
+}
+
+// MustCompile is like Compile but panics if expr can't be parsed.
+func MustCompile(expr string) Pattern {
+	return Pattern{re: regexp.MustCompile(expr)}
+}
+
+// MatchString reports whether s matches p.
+func (p Pattern) MatchString(s string) bool {
+	return p.re == nil || p.re.MatchString(s)
+}
+
+// String returns the source text of p.
+func (p Pattern) String() string {
+	if p.re == nil {
+		return ""
+	}
+	return p.re.String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p Pattern) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *Pattern) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*p = Pattern{}
+		return nil
+	}
+	pp, err := Compile(string(text))
+	if err != nil {
+		return err
+	}
+	*p = pp
+	return nil
+}
+
+// Duration is a time.Duration, encoded in JSON as a string in the form
+// accepted by time.ParseDuration, or as a whole number of days, e.g. "30d".
+type Duration time.Duration
+
+// ParseDuration parses a Duration.
+func ParseDuration(s string) (Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.ParseInt(days, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return Duration(time.Duration(n) * 24 * time.Hour), nil
+	}
+	d, err := time.ParseDuration(s)
+	return Duration(d), err
+}
+
+// String implements fmt.Stringer.
+func (d Duration) String() string {
+	if day := 24 * time.Hour; d != 0 && time.Duration(d)%day == 0 {
+		return fmt.Sprintf("%dd", time.Duration(d)/day)
+	}
+	return time.Duration(d).String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	dd, err := ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = dd
+	return nil
+}
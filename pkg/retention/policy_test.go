@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(`{
+		"untagged": {"olderThan": "7d"},
+		"keep": [{"tags": "^main-", "last": 10}, {"last": 100}],
+		"protectSemver": true,
+		"protect": ["^release-"]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	if got, want := time.Duration(p.Untagged.OlderThan), 7*24*time.Hour; got != want {
+		t.Errorf("untagged.olderThan = %s, want %s", got, want)
+	}
+	if len(p.Keep) != 2 || p.Keep[0].Tags.String() != "^main-" || p.Keep[0].Last != 10 || p.Keep[1].Tags.String() != "" {
+		t.Errorf("keep = %+v", p.Keep)
+	}
+	if !p.ProtectSemver || len(p.Protect) != 1 || !p.Protect[0].MatchString("release-1") {
+		t.Errorf("protect = %v, %v", p.ProtectSemver, p.Protect)
+	}
+
+	// Round trip.
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"untagged":{"olderThan":"7d"},"keep":[{"tags":"^main-","last":10},{"tags":"","last":100}],"protectSemver":true,"protect":["^release-"]}`
+	if string(b) != want {
+		t.Errorf("Marshal() = %s, want %s", b, want)
+	}
+
+	for _, bad := range []string{
+		`{"untaged": {}}`,
+		`{"keep": [{"tags": "(", "last": 1}]}`,
+		`{"keep": [{"last": -1}]}`,
+		`{"untagged": {"olderThan": "soon"}}`,
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%s) = nil, wanted error", bad)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+		out  string
+	}{
+		{"30d", 30 * 24 * time.Hour, "30d"},
+		{"36h", 36 * time.Hour, "36h0m0s"},
+		{"48h", 48 * time.Hour, "2d"},
+		{"0", 0, "0s"},
+	} {
+		d, err := ParseDuration(tc.in)
+		if err != nil {
+			t.Errorf("ParseDuration(%q) = %v", tc.in, err)
+			continue
+		}
+		if time.Duration(d) != tc.want {
+			t.Errorf("ParseDuration(%q) = %s, want %s", tc.in, time.Duration(d), tc.want)
+		}
+		if got := d.String(); got != tc.out {
+			t.Errorf("String() = %q, want %q", got, tc.out)
+		}
+	}
+}
+
+func TestSemver(t *testing.T) {
+	for tag, want := range map[string]bool{
+		"1.2.3":       true,
+		"v1.2.3":      true,
+		"v1.2.3+abc":  true,
+		"v1.2.3-rc.1": false,
+		"v1.2":        false,
+		"latest":      false,
+		"v01.2.3":     false,
+	} {
+		if got := semver.MatchString(tag); got != want {
+			t.Errorf("semver.MatchString(%q) = %v, want %v", tag, got, want)
+		}
+	}
+}
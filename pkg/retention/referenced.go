@@ -0,0 +1,59 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// DropReferenced returns deletions without the images that are referenced by
+// an index in images, as listed from repo, that isn't being deleted.  Deleting
+// those would break the index.  Indexes are recognized by their MediaType, so
+// listings without media types, like ECR's, can't be checked.
+//
+// deletions is modified in place.
+func DropReferenced(repo name.Repository, images []Image, deletions []Deletion, opts ...remote.Option) ([]Deletion, error) {
+	deleting := map[string]bool{}
+	for _, d := range deletions {
+		deleting[d.Digest] = true
+	}
+
+	keep := map[string]bool{}
+	for _, img := range images {
+		if !img.MediaType.IsIndex() || deleting[img.Digest] {
+			continue
+		}
+		idx, err := remote.Index(repo.Digest(img.Digest), opts...)
+		if err != nil {
+			return nil, err
+		}
+		im, err := idx.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		for _, desc := range im.Manifests {
+			keep[desc.Digest.String()] = true
+		}
+	}
+
+	kept := deletions[:0]
+	for _, d := range deletions {
+		if !keep[d.Digest] {
+			kept = append(kept, d)
+		}
+	}
+	return kept, nil
+}
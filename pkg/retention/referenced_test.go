@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestDropReferenced(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/referenced")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := random.Index(64, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(repo.Tag("index"), idx); err != nil {
+		t.Fatal(err)
+	}
+	idxDigest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := im.Manifests[0].Digest.String()
+
+	images := []Image{
+		{Digest: idxDigest.String(), Tags: []string{"index"}, MediaType: types.OCIImageIndex},
+		{Digest: child},
+		{Digest: "sha256:other"},
+	}
+
+	// The child is kept while the index is.
+	got, err := DropReferenced(repo, images, []Deletion{{Digest: child}, {Digest: "sha256:other"}})
+	if err != nil {
+		t.Fatalf("DropReferenced() = %v", err)
+	}
+	if diff := cmp.Diff([]Deletion{{Digest: "sha256:other"}}, got); diff != "" {
+		t.Errorf("DropReferenced() (-want +got) = %s", diff)
+	}
+
+	// But not if the index is being deleted too.
+	all := []Deletion{{Digest: idxDigest.String()}, {Digest: child}}
+	got, err = DropReferenced(repo, images, append([]Deletion{}, all...))
+	if err != nil {
+		t.Fatalf("DropReferenced() = %v", err)
+	}
+	if diff := cmp.Diff(all, got); diff != "" {
+		t.Errorf("DropReferenced() (-want +got) = %s", diff)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Image is an image in a repository listing.
+type Image struct {
+	Digest    string
+	Tags      []string
+	MediaType types.MediaType
+
+	// Uploaded is when the image was pushed, or zero if that isn't known.
+	Uploaded time.Time
+}
+
+// Deletion is an image that a Policy selected for deletion.
+type Deletion struct {
+	Digest    string          `json:"digest"`
+	Tags      []string        `json:"tags,omitempty"`
+	MediaType types.MediaType `json:"mediaType,omitempty"`
+	Uploaded  time.Time       `json:"uploaded"`
+	Reason    string          `json:"reason"`
+}
+
+// Evaluate returns the images that p selects for deletion, as of now.
+// Indexes come first, so that they're deleted before the images they refer
+// to, and otherwise the oldest images come first.
+//
+// Evaluate doesn't know which images are referenced by indexes, which some
+// registries list as untagged; callers must take care not to delete those.
+func (p *Policy) Evaluate(images []Image, now time.Time) []Deletion {
+	var selected []Deletion
+	var tagged []Image
+	for _, img := range images {
+		if len(img.Tags) == 0 {
+			if reason, ok := p.untagged(img, now); ok {
+				selected = append(selected, deletion(img, reason))
+			}
+			continue
+		}
+		if !p.protected(img.Tags) {
+			tagged = append(tagged, img)
+		}
+	}
+
+	// Apply each rule to the tagged images, newest first.
+	sortByUpload(tagged)
+	kept := map[string]bool{}
+	reasons := map[string]string{}
+	for _, rule := range p.Keep {
+		n := 0
+		for i := len(tagged) - 1; i >= 0; i-- {
+			img := tagged[i]
+			if !rule.applies(img.Tags) {
+				continue
+			}
+			if n < rule.Last {
+				kept[img.Digest] = true
+			} else if _, ok := reasons[img.Digest]; !ok {
+				reasons[img.Digest] = rule.reason()
+			}
+			n++
+		}
+	}
+	for _, img := range tagged {
+		if reason, ok := reasons[img.Digest]; ok && !kept[img.Digest] {
+			selected = append(selected, deletion(img, reason))
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		a, b := selected[i], selected[j]
+		if ai, bi := a.MediaType.IsIndex(), b.MediaType.IsIndex(); ai != bi {
+			return ai
+		}
+		if !a.Uploaded.Equal(b.Uploaded) {
+			return a.Uploaded.Before(b.Uploaded)
+		}
+		return a.Digest < b.Digest
+	})
+	return selected
+}
+
+// untagged returns why p selects the untagged img, if it does.
+func (p *Policy) untagged(img Image, now time.Time) (string, bool) {
+	if p.Untagged == nil {
+		return "", false
+	}
+	olderThan := time.Duration(p.Untagged.OlderThan)
+	if olderThan == 0 {
+		return "untagged", true
+	}
+	if img.Uploaded.IsZero() || now.Sub(img.Uploaded) < olderThan {
+		return "", false
+	}
+	return fmt.Sprintf("untagged for more than %s", p.Untagged.OlderThan), true
+}
+
+func (r KeepRule) reason() string {
+	if r.Tags.String() == "" {
+		return fmt.Sprintf("older than the newest %d tagged images", r.Last)
+	}
+	return fmt.Sprintf("older than the newest %d images with a tag matching %q", r.Last, r.Tags)
+}
+
+func (r KeepRule) applies(tags []string) bool {
+	for _, tag := range tags {
+		if r.Tags.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func deletion(img Image, reason string) Deletion {
+	return Deletion{
+		Digest:    img.Digest,
+		Tags:      img.Tags,
+		MediaType: img.MediaType,
+		Uploaded:  img.Uploaded,
+		Reason:    reason,
+	}
+}
+
+// sortByUpload sorts images oldest first, with unknown upload times first.
+func sortByUpload(images []Image) {
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Uploaded.Equal(images[j].Uploaded) {
+			return images[i].Digest < images[j].Digest
+		}
+		return images[i].Uploaded.Before(images[j].Uploaded)
+	})
+}
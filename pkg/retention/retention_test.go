@@ -0,0 +1,107 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Unix(100*24*60*60, 0)
+	daysAgo := func(n int) time.Time {
+		return now.Add(-time.Duration(n) * 24 * time.Hour)
+	}
+	images := []Image{
+		{Digest: "sha256:old", Uploaded: daysAgo(30)},
+		{Digest: "sha256:new", Uploaded: daysAgo(1)},
+		{Digest: "sha256:unknown"},
+		{Digest: "sha256:index", Uploaded: daysAgo(40), MediaType: types.OCIImageIndex},
+		{Digest: "sha256:main-1", Uploaded: daysAgo(50), Tags: []string{"main-1"}},
+		{Digest: "sha256:main-2", Uploaded: daysAgo(20), Tags: []string{"main-2"}},
+		{Digest: "sha256:main-3", Uploaded: daysAgo(10), Tags: []string{"main-3", "latest"}},
+		{Digest: "sha256:pr-1", Uploaded: daysAgo(15), Tags: []string{"pr-1"}},
+		{Digest: "sha256:pr-2", Uploaded: daysAgo(5), Tags: []string{"pr-2"}},
+		{Digest: "sha256:v1.0.0", Uploaded: daysAgo(60), Tags: []string{"v1.0.0", "main-0"}},
+		{Digest: "sha256:v1.1.0-rc.1", Uploaded: daysAgo(55), Tags: []string{"v1.1.0-rc.1"}},
+		{Digest: "sha256:release", Uploaded: daysAgo(70), Tags: []string{"release-1"}},
+	}
+
+	digests := func(ds []Deletion) []string {
+		got := []string{}
+		for _, d := range ds {
+			got = append(got, d.Digest)
+		}
+		return got
+	}
+
+	for _, tc := range []struct {
+		name   string
+		policy Policy
+		want   []string
+	}{{
+		name: "zero",
+		want: []string{},
+	}, {
+		name:   "untagged",
+		policy: Policy{Untagged: &UntaggedRule{}},
+		want:   []string{"sha256:index", "sha256:unknown", "sha256:old", "sha256:new"},
+	}, {
+		name:   "untagged older than",
+		policy: Policy{Untagged: &UntaggedRule{OlderThan: Duration(7 * 24 * time.Hour)}},
+		want:   []string{"sha256:index", "sha256:old"},
+	}, {
+		name:   "keep last",
+		policy: Policy{Keep: []KeepRule{{Last: 3}}},
+		want:   []string{"sha256:release", "sha256:v1.0.0", "sha256:v1.1.0-rc.1", "sha256:main-1", "sha256:main-2"},
+	}, {
+		name: "keep last per pattern",
+		policy: Policy{
+			Keep: []KeepRule{
+				{Tags: MustCompile("^main-"), Last: 2},
+				{Tags: MustCompile("^pr-"), Last: 1},
+			},
+		},
+		want: []string{"sha256:v1.0.0", "sha256:main-1", "sha256:pr-1"},
+	}, {
+		name: "protect",
+		policy: Policy{
+			Keep:          []KeepRule{{Last: 3}},
+			ProtectSemver: true,
+			Protect:       []Pattern{MustCompile("^release-")},
+		},
+		want: []string{"sha256:v1.1.0-rc.1", "sha256:main-1", "sha256:main-2"},
+	}, {
+		// An image is kept if any rule keeps it.
+		name: "overlapping rules",
+		policy: Policy{
+			Keep: []KeepRule{
+				{Tags: MustCompile("^main-"), Last: 1},
+				{Tags: MustCompile("^(main-1|latest)$"), Last: 2},
+			},
+		},
+		want: []string{"sha256:v1.0.0", "sha256:main-2"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.policy.Evaluate(images, now)
+			if diff := cmp.Diff(tc.want, digests(got)); diff != "" {
+				t.Errorf("Evaluate() (-want +got) = %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package annotations provides the keys of the OCI pre-defined annotations,
+// and typed access to the most used of them. See:
+// https://github.com/opencontainers/image-spec/blob/main/annotations.md
+package annotations
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// The OCI pre-defined annotation keys.
+const (
+	// Created is when the image was built, as an RFC 3339 date-time.
+	Created = "org.opencontainers.image.created"
+
+	// Authors is the contact details of those responsible for the image.
+	Authors = "org.opencontainers.image.authors"
+
+	// URL is where to find more information on the image.
+	URL = "org.opencontainers.image.url"
+
+	// Documentation is where to find documentation on the image.
+	Documentation = "org.opencontainers.image.documentation"
+
+	// Source is the URL of the source code the image was built from.
+	Source = "org.opencontainers.image.source"
+
+	// Version is the version of the packaged software.
+	Version = "org.opencontainers.image.version"
+
+	// Revision identifies the source within Source, e.g. a git commit SHA.
+	Revision = "org.opencontainers.image.revision"
+
+	// Vendor is the name of the distributing entity.
+	Vendor = "org.opencontainers.image.vendor"
+
+	// Licenses is an SPDX license expression for the packaged software.
+	Licenses = "org.opencontainers.image.licenses"
+
+	// RefName is the name of a reference in an OCI image layout, e.g. a tag.
+	RefName = "org.opencontainers.image.ref.name"
+
+	// Title is the human-readable title of the image.
+	Title = "org.opencontainers.image.title"
+
+	// Description is the human-readable description of the image.
+	Description = "org.opencontainers.image.description"
+
+	// BaseImageDigest is the digest of the image's base image.
+	BaseImageDigest = "org.opencontainers.image.base.digest"
+
+	// BaseImageName is a reference to the image's base image.
+	BaseImageName = "org.opencontainers.image.base.name"
+)
+
+// refName is the grammar of RefName values from the image-spec.
+var refName = regexp.MustCompile(`^[A-Za-z0-9]+(?:(?:[-._:@+]|--)[A-Za-z0-9]+)*(?:/[A-Za-z0-9]+(?:(?:[-._:@+]|--)[A-Za-z0-9]+)*)*$`)
+
+// Annotations provides typed access to an annotations map, such as those of
+// v1.Descriptor, v1.Manifest and v1.IndexManifest.
+type Annotations struct {
+	m *map[string]string
+}
+
+// Of returns typed access to the annotations in m. Setters create the map if
+// it is nil, so pass a pointer to the field, e.g.
+//
+//	annotations.Of(&desc.Annotations).SetRevision(sha)
+func Of(m *map[string]string) Annotations {
+	return Annotations{m: m}
+}
+
+func (a Annotations) get(key string) string {
+	return (*a.m)[key]
+}
+
+// set sets key to value, or deletes key if value is empty.
+func (a Annotations) set(key, value string) {
+	if value == "" {
+		delete(*a.m, key)
+		return
+	}
+	if *a.m == nil {
+		*a.m = map[string]string{}
+	}
+	(*a.m)[key] = value
+}
+
+// Created returns the Created annotation, or the zero time if it's unset.
+func (a Annotations) Created() (time.Time, error) {
+	s := a.get(Created)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing %s: %w", Created, err)
+	}
+	return t, nil
+}
+
+// SetCreated sets the Created annotation, in UTC. The zero time unsets it.
+func (a Annotations) SetCreated(t time.Time) {
+	if t.IsZero() {
+		a.set(Created, "")
+		return
+	}
+	a.set(Created, t.UTC().Format(time.RFC3339))
+}
+
+// Source returns the Source annotation.
+func (a Annotations) Source() string {
+	return a.get(Source)
+}
+
+// SetSource sets the Source annotation. An empty source unsets it.
+func (a Annotations) SetSource(source string) {
+	a.set(Source, source)
+}
+
+// Revision returns the Revision annotation.
+func (a Annotations) Revision() string {
+	return a.get(Revision)
+}
+
+// SetRevision sets the Revision annotation. An empty revision unsets it.
+func (a Annotations) SetRevision(revision string) {
+	a.set(Revision, revision)
+}
+
+// RefName returns the RefName annotation.
+func (a Annotations) RefName() string {
+	return a.get(RefName)
+}
+
+// SetRefName sets the RefName annotation. An empty name unsets it.
+func (a Annotations) SetRefName(ref string) {
+	a.set(RefName, ref)
+}
+
+// BaseImage returns the BaseImageName and BaseImageDigest annotations, or
+// nil and the zero hash where they're unset.
+func (a Annotations) BaseImage() (name.Reference, v1.Hash, error) {
+	var (
+		ref name.Reference
+		h   v1.Hash
+		err error
+	)
+	if s := a.get(BaseImageName); s != "" {
+		if ref, err = name.ParseReference(s); err != nil {
+			return nil, v1.Hash{}, fmt.Errorf("parsing %s: %w", BaseImageName, err)
+		}
+	}
+	if s := a.get(BaseImageDigest); s != "" {
+		if h, err = v1.NewHash(s); err != nil {
+			return nil, v1.Hash{}, fmt.Errorf("parsing %s: %w", BaseImageDigest, err)
+		}
+	}
+	return ref, h, nil
+}
+
+// SetBaseImage sets the BaseImageName and BaseImageDigest annotations. A nil
+// ref or zero hash unsets the respective annotation.
+func (a Annotations) SetBaseImage(ref name.Reference, h v1.Hash) {
+	if ref == nil {
+		a.set(BaseImageName, "")
+	} else {
+		a.set(BaseImageName, ref.Name())
+	}
+	if h == (v1.Hash{}) {
+		a.set(BaseImageDigest, "")
+	} else {
+		a.set(BaseImageDigest, h.String())
+	}
+}
+
+// Validate checks that the annotations with typed accessors, if set, have
+// values of the form that the image-spec requires.
+func (a Annotations) Validate() error {
+	if _, err := a.Created(); err != nil {
+		return err
+	}
+	if s := a.Source(); s != "" {
+		if u, err := url.Parse(s); err != nil || !u.IsAbs() {
+			return fmt.Errorf("%s %q is not an absolute URL", Source, s)
+		}
+	}
+	if s := a.RefName(); s != "" && !refName.MatchString(s) {
+		return fmt.Errorf("%s %q is not a valid reference name", RefName, s)
+	}
+	_, _, err := a.BaseImage()
+	return err
+}
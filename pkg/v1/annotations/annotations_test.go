@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestSetters(t *testing.T) {
+	var desc v1.Descriptor
+	a := Of(&desc.Annotations)
+
+	created := time.Date(2022, 3, 4, 5, 6, 7, 0, time.FixedZone("", 3600))
+	a.SetCreated(created)
+	a.SetSource("https://github.com/google/go-containerregistry")
+	a.SetRevision("abc123")
+	a.SetRefName("v1.0")
+	base, err := name.ParseReference("alpine:3.15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := v1.NewHash("sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetBaseImage(base, h)
+
+	want := map[string]string{
+		Created:         "2022-03-04T04:06:07Z",
+		Source:          "https://github.com/google/go-containerregistry",
+		Revision:        "abc123",
+		RefName:         "v1.0",
+		BaseImageName:   "index.docker.io/library/alpine:3.15",
+		BaseImageDigest: h.String(),
+	}
+	if len(desc.Annotations) != len(want) {
+		t.Errorf("Annotations = %v, want %v", desc.Annotations, want)
+	}
+	for k, v := range want {
+		if got := desc.Annotations[k]; got != v {
+			t.Errorf("Annotations[%q] = %q, want %q", k, got, v)
+		}
+	}
+
+	if err := a.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if got, err := a.Created(); err != nil || !got.Equal(created) {
+		t.Errorf("Created() = %v, %v; want %v", got, err, created)
+	}
+	if got := a.Source(); got != want[Source] {
+		t.Errorf("Source() = %q", got)
+	}
+	if got := a.Revision(); got != want[Revision] {
+		t.Errorf("Revision() = %q", got)
+	}
+	if got := a.RefName(); got != want[RefName] {
+		t.Errorf("RefName() = %q", got)
+	}
+	gotRef, gotHash, err := a.BaseImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotRef.Name() != want[BaseImageName] || gotHash != h {
+		t.Errorf("BaseImage() = %v, %v", gotRef, gotHash)
+	}
+
+	// Zero values unset.
+	a.SetCreated(time.Time{})
+	a.SetSource("")
+	a.SetRevision("")
+	a.SetRefName("")
+	a.SetBaseImage(nil, v1.Hash{})
+	if len(desc.Annotations) != 0 {
+		t.Errorf("Annotations = %v, want empty", desc.Annotations)
+	}
+}
+
+func TestNilMap(t *testing.T) {
+	var m v1.Manifest
+	a := Of(&m.Annotations)
+	if got, err := a.Created(); err != nil || !got.IsZero() {
+		t.Errorf("Created() = %v, %v", got, err)
+	}
+	if ref, h, err := a.BaseImage(); err != nil || ref != nil || h != (v1.Hash{}) {
+		t.Errorf("BaseImage() = %v, %v, %v", ref, h, err)
+	}
+	if err := a.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	a.SetRevision("")
+	if m.Annotations != nil {
+		t.Errorf("unsetting allocated the map")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		key, value string
+		wantErr    bool
+	}{
+		{Created, "2022-03-04T05:06:07Z", false},
+		{Created, "2022-03-04T05:06:07.123+01:00", false},
+		{Created, "2022-03-04", true},
+		{Source, "https://example.com/repo", false},
+		{Source, "git@github.com:foo/bar", true},
+		{Source, "example.com/repo", true},
+		{RefName, "latest", false},
+		{RefName, "v1.0.0-rc.1+build", false},
+		{RefName, "example.com/foo/bar:v1", false},
+		{RefName, "foo--bar", false},
+		{RefName, "foo__bar", true},
+		{RefName, "-foo", true},
+		{RefName, "foo/", true},
+		{BaseImageName, "gcr.io/foo/bar:baz", false},
+		{BaseImageName, "Not A Name", true},
+		{BaseImageDigest, "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", false},
+		{BaseImageDigest, "sha256:abc", true},
+		// Annotations without typed accessors aren't validated.
+		{Title, "anything goes", false},
+	} {
+		t.Run(tc.key+"="+tc.value, func(t *testing.T) {
+			idx := v1.IndexManifest{Annotations: map[string]string{tc.key: tc.value}}
+			err := Of(&idx.Annotations).Validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Validate() = %v, wantErr %t", err, tc.wantErr)
+			}
+		})
+	}
+}
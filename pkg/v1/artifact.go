@@ -0,0 +1,59 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Artifact defines the interface for interacting with an OCI artifact: content
+// other than a container image, e.g. a Helm chart, a policy or an SBOM, that is
+// stored as an image manifest. Instead of a config file and filesystem layers,
+// an artifact has a type and an ordered collection of arbitrary, typed blobs.
+type Artifact interface {
+	// MediaType of this artifact's manifest.
+	MediaType() (types.MediaType, error)
+
+	// Size returns the size of the manifest.
+	Size() (int64, error)
+
+	// Digest returns the sha256 of this artifact's manifest.
+	Digest() (Hash, error)
+
+	// Manifest returns this artifact's Manifest object.
+	Manifest() (*Manifest, error)
+
+	// RawManifest returns the serialized bytes of Manifest()
+	RawManifest() ([]byte, error)
+
+	// ArtifactType returns the type of this artifact, which is the manifest's
+	// artifactType or, if that is unset, the media type of its config.
+	ArtifactType() (string, error)
+
+	// Subject returns the descriptor of the manifest that this artifact
+	// refers to, or nil if it doesn't refer to one.
+	Subject() (*Descriptor, error)
+
+	// Config returns the artifact's config blob, which is often empty.
+	Config() (Layer, error)
+
+	// Blobs returns the ordered collection of blobs that comprise this
+	// artifact, i.e. the manifest's layers.
+	Blobs() ([]Layer, error)
+
+	// Blob returns a Layer for interacting with a particular blob of the
+	// artifact, looking it up by digest.
+	Blob(Hash) (Layer, error)
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// emptyJSON is the content of the empty config that artifacts use when they
+// don't need a config, see:
+// https://github.com/opencontainers/image-spec/blob/main/manifest.md#guidance-for-an-empty-descriptor
+var emptyJSON = []byte("{}")
+
+// Option is a functional option for New.
+type Option func(*options)
+
+type options struct {
+	config      v1.Layer
+	blobs       []v1.Layer
+	subject     *v1.Descriptor
+	annotations map[string]string
+}
+
+// WithBlobs appends blobs to the artifact.
+func WithBlobs(blobs ...v1.Layer) Option {
+	return func(o *options) {
+		o.blobs = append(o.blobs, blobs...)
+	}
+}
+
+// WithConfig sets the artifact's config blob. By default, artifacts have an
+// empty config with media type types.OCIEmptyJSON.
+func WithConfig(config v1.Layer) Option {
+	return func(o *options) {
+		o.config = config
+	}
+}
+
+// WithSubject makes the artifact refer to subject, e.g. the image that an SBOM
+// describes.
+func WithSubject(subject v1.Descriptor) Option {
+	return func(o *options) {
+		o.subject = &subject
+	}
+}
+
+// WithAnnotations adds annotations to the artifact's manifest.
+func WithAnnotations(annotations map[string]string) Option {
+	return func(o *options) {
+		if o.annotations == nil {
+			o.annotations = map[string]string{}
+		}
+		for k, v := range annotations {
+			o.annotations[k] = v
+		}
+	}
+}
+
+// New returns an artifact of the given type, with the blobs, config, subject
+// and annotations set by opts.
+//
+// The type may only be empty if a config is set with WithConfig, in which case
+// the config's media type identifies the artifact, as older conventions
+// (e.g. Helm's) expect.
+func New(artifactType string, opts ...Option) (v1.Artifact, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.config == nil {
+		if artifactType == "" {
+			return nil, errors.New("artifact type must be set for artifacts without a config")
+		}
+		o.config = static.NewLayer(emptyJSON, types.OCIEmptyJSON)
+	}
+
+	m := &v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		ArtifactType:  artifactType,
+		Layers:        []v1.Descriptor{},
+		Subject:       o.subject,
+		Annotations:   o.annotations,
+	}
+	cfg, err := partial.Descriptor(o.config)
+	if err != nil {
+		return nil, fmt.Errorf("describing config: %w", err)
+	}
+	m.Config = *cfg
+
+	a := &artifact{
+		manifest: m,
+		config:   o.config,
+		blobs:    o.blobs,
+		byDigest: map[v1.Hash]v1.Layer{cfg.Digest: o.config},
+	}
+	for i, blob := range o.blobs {
+		desc, err := partial.Descriptor(blob)
+		if err != nil {
+			return nil, fmt.Errorf("describing blob %d: %w", i, err)
+		}
+		m.Layers = append(m.Layers, *desc)
+		a.byDigest[desc.Digest] = blob
+	}
+
+	if a.raw, err = json.Marshal(m); err != nil {
+		return nil, err
+	}
+	if a.digest, a.size, err = v1.SHA256(bytes.NewReader(a.raw)); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+type artifact struct {
+	manifest *v1.Manifest
+	raw      []byte
+	digest   v1.Hash
+	size     int64
+	config   v1.Layer
+	blobs    []v1.Layer
+	byDigest map[v1.Hash]v1.Layer
+}
+
+var _ v1.Artifact = (*artifact)(nil)
+
+func (a *artifact) MediaType() (types.MediaType, error) {
+	return a.manifest.MediaType, nil
+}
+
+func (a *artifact) Size() (int64, error) {
+	return a.size, nil
+}
+
+func (a *artifact) Digest() (v1.Hash, error) {
+	return a.digest, nil
+}
+
+func (a *artifact) Manifest() (*v1.Manifest, error) {
+	return a.manifest.DeepCopy(), nil
+}
+
+func (a *artifact) RawManifest() ([]byte, error) {
+	return a.raw, nil
+}
+
+func (a *artifact) ArtifactType() (string, error) {
+	return artifactType(a.manifest), nil
+}
+
+func (a *artifact) Subject() (*v1.Descriptor, error) {
+	return a.manifest.Subject.DeepCopy(), nil
+}
+
+func (a *artifact) Config() (v1.Layer, error) {
+	return a.config, nil
+}
+
+func (a *artifact) Blobs() ([]v1.Layer, error) {
+	return a.blobs, nil
+}
+
+func (a *artifact) Blob(h v1.Hash) (v1.Layer, error) {
+	if l, ok := a.byDigest[h]; ok {
+		return l, nil
+	}
+	return nil, fmt.Errorf("blob %s not found in artifact", h)
+}
+
+// artifactType implements v1.Artifact.ArtifactType for a manifest.
+func artifactType(m *v1.Manifest) string {
+	if m.ArtifactType != "" {
+		return m.ArtifactType
+	}
+	return string(m.Config.MediaType)
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const policyType = "application/vnd.example.policy.v1"
+
+func newArtifact(t *testing.T) (v1.Artifact, v1.Layer) {
+	t.Helper()
+	img, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := static.NewLayer([]byte("allow = true"), "application/vnd.example.policy.rego")
+	a, err := artifact.New(policyType,
+		artifact.WithBlobs(blob),
+		artifact.WithSubject(v1.Descriptor{MediaType: types.DockerManifestSchema2, Digest: d, Size: 42}),
+		artifact.WithAnnotations(map[string]string{"org.opencontainers.image.title": "policy"}),
+	)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	return a, blob
+}
+
+func TestNew(t *testing.T) {
+	a, blob := newArtifact(t)
+
+	m, err := a.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Config.MediaType, types.OCIEmptyJSON; got != want {
+		t.Errorf("config media type = %s, want %s", got, want)
+	}
+	if got, want := m.Annotations["org.opencontainers.image.title"], "policy"; got != want {
+		t.Errorf("title = %q, want %q", got, want)
+	}
+	if at, err := a.ArtifactType(); err != nil || at != policyType {
+		t.Errorf("ArtifactType() = %q, %v, want %q", at, err, policyType)
+	}
+	if s, err := a.Subject(); err != nil || s == nil || s.Size != 42 {
+		t.Errorf("Subject() = %v, %v", s, err)
+	}
+
+	bd, err := blob.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := a.Blob(bd)
+	if err != nil {
+		t.Fatalf("Blob() = %v", err)
+	}
+	rc, err := got.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if b, err := ioutil.ReadAll(rc); err != nil || string(b) != "allow = true" {
+		t.Errorf("Blob() = %q, %v", b, err)
+	}
+	if _, err := a.Blob(v1.Hash{Algorithm: "sha256", Hex: "00"}); err == nil {
+		t.Error("Blob() of a missing digest should fail")
+	}
+
+	if _, err := artifact.New(""); err == nil {
+		t.Error("New() with no type or config should fail")
+	}
+	typed, err := artifact.New("", artifact.WithConfig(static.NewLayer([]byte("{}"), "application/vnd.example.config.v1+json")))
+	if err != nil {
+		t.Fatalf("New() with only a config = %v", err)
+	}
+	if at, err := typed.ArtifactType(); err != nil || at != "application/vnd.example.config.v1+json" {
+		t.Errorf("ArtifactType() = %q, %v, want the config media type", at, err)
+	}
+}
+
+func TestImageRoundTrip(t *testing.T) {
+	a, _ := newArtifact(t)
+
+	img, err := artifact.Image(a)
+	if err != nil {
+		t.Fatalf("Image() = %v", err)
+	}
+	want, err := a.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := img.Digest(); err != nil || got != want {
+		t.Errorf("Digest() = %s, %v, want %s", got, err, want)
+	}
+	if layers, err := img.Layers(); err != nil || len(layers) != 1 {
+		t.Errorf("Layers() = %d, %v, want 1", len(layers), err)
+	}
+	if _, err := img.LayerByDigest(mustConfigDigest(t, a)); err != nil {
+		t.Errorf("LayerByDigest(config) = %v", err)
+	}
+	if got := artifact.FromImage(img); got != a {
+		t.Error("FromImage(Image(a)) should return a")
+	}
+
+	// Views of images that weren't created from artifacts work too.
+	rnd, err := random.Image(100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ra := artifact.FromImage(rnd)
+	if at, err := ra.ArtifactType(); err != nil || at != string(types.DockerConfigJSON) {
+		t.Errorf("ArtifactType() = %q, %v, want the config media type", at, err)
+	}
+	if blobs, err := ra.Blobs(); err != nil || len(blobs) != 2 {
+		t.Errorf("Blobs() = %d, %v, want 2", len(blobs), err)
+	}
+	if back, err := artifact.Image(ra); err != nil || back != rnd {
+		t.Errorf("Image(FromImage(img)) = %v, %v, want img", back, err)
+	}
+}
+
+func mustConfigDigest(t *testing.T, a v1.Artifact) v1.Hash {
+	t.Helper()
+	cfg, err := a.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := cfg.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
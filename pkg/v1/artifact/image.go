@@ -0,0 +1,143 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// FromImage returns a view of img as an artifact, so that artifacts can be
+// read with anything that reads images, e.g. remote.Image or
+// layout.Path.Image. The config is not parsed, so it can hold anything.
+func FromImage(img v1.Image) v1.Artifact {
+	if ai, ok := img.(*artifactImage); ok {
+		return ai.a
+	}
+	return &imageArtifact{img}
+}
+
+type imageArtifact struct {
+	v1.Image
+}
+
+var _ v1.Artifact = (*imageArtifact)(nil)
+
+func (i *imageArtifact) ArtifactType() (string, error) {
+	m, err := i.Manifest()
+	if err != nil {
+		return "", err
+	}
+	return artifactType(m), nil
+}
+
+func (i *imageArtifact) Subject() (*v1.Descriptor, error) {
+	m, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return m.Subject, nil
+}
+
+func (i *imageArtifact) Config() (v1.Layer, error) {
+	m, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	b, err := i.RawConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return static.NewLayer(b, m.Config.MediaType), nil
+}
+
+func (i *imageArtifact) Blobs() ([]v1.Layer, error) {
+	return i.Layers()
+}
+
+func (i *imageArtifact) Blob(h v1.Hash) (v1.Layer, error) {
+	return i.LayerByDigest(h)
+}
+
+// Image returns a as a v1.Image, so that artifacts can be written with
+// anything that writes images, e.g. remote.Write or layout.Path.WriteImage.
+//
+// The returned image's ConfigFile will usually fail to parse, or be empty,
+// since an artifact's config is not an image config.
+func Image(a v1.Artifact) (v1.Image, error) {
+	if ia, ok := a.(*imageArtifact); ok {
+		return ia.Image, nil
+	}
+	img, err := partial.CompressedToImage(&artifactCore{a})
+	if err != nil {
+		return nil, err
+	}
+	return &artifactImage{Image: img, a: a}, nil
+}
+
+// artifactImage remembers the artifact it was created from, so that
+// FromImage can return it.
+type artifactImage struct {
+	v1.Image
+	a v1.Artifact
+}
+
+// artifactCore implements partial.CompressedImageCore.
+type artifactCore struct {
+	a v1.Artifact
+}
+
+func (i *artifactCore) MediaType() (types.MediaType, error) {
+	return i.a.MediaType()
+}
+
+func (i *artifactCore) RawManifest() ([]byte, error) {
+	return i.a.RawManifest()
+}
+
+func (i *artifactCore) RawConfigFile() ([]byte, error) {
+	cfg, err := i.a.Config()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := cfg.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+func (i *artifactCore) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	cfg, err := i.a.Config()
+	if err != nil {
+		return nil, err
+	}
+	if d, err := cfg.Digest(); err != nil {
+		return nil, err
+	} else if d == h {
+		return cfg, nil
+	}
+	l, err := i.a.Blob(h)
+	if err != nil {
+		return nil, fmt.Errorf("looking up blob %s: %w", h, err)
+	}
+	return l, nil
+}
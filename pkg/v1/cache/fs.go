@@ -18,21 +18,65 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 )
 
 type fscache struct {
-	path string
+	// Accessed atomically, so kept first for alignment on 32-bit platforms.
+	hits, misses, writes, evictions int64
+
+	// swept is when evict last swept an unbounded cache, in UnixNano.
+	swept int64
+
+	path    string
+	maxSize int64
+	ttl     time.Duration
+	tagTTL  time.Duration
+	mu      sync.Mutex
+}
+
+// Option is a functional option for NewFilesystemCache.
+type Option func(*fscache)
+
+// WithMaxSize bounds the total size of the files in the cache to n bytes.
+//
+// Whenever a new entry is written, the least-recently-used entries are evicted
+// until the cache fits within n again. Entries are considered used when they
+// are written or returned by Get. A value of zero (the default) leaves the
+// cache unbounded. Manifests, config files and tags (see ManifestCache) don't
+// count towards n.
+func WithMaxSize(n int64) Option {
+	return func(fs *fscache) {
+		fs.maxSize = n
+	}
+}
+
+// WithTTL expires entries that have not been used for longer than d. Entries
+// can override this with SetTTL, and pinned entries never expire. A value of
+// zero (the default) means entries don't expire. Cached manifests and config
+// files expire the same way.
+func WithTTL(d time.Duration) Option {
+	return func(fs *fscache) {
+		fs.ttl = d
+	}
 }
 
 // NewFilesystemCache returns a Cache implementation backed by files.
-func NewFilesystemCache(path string) Cache {
-	return &fscache{path}
+func NewFilesystemCache(path string, opts ...Option) Cache {
+	fs := &fscache{path: path}
+	for _, opt := range opts {
+		opt(fs)
+	}
+	return fs
 }
 
 func (fs *fscache) Put(l v1.Layer) (v1.Layer, error) {
@@ -46,6 +90,7 @@ func (fs *fscache) Put(l v1.Layer) (v1.Layer, error) {
 	}
 	return &layer{
 		Layer:  l,
+		fs:     fs,
 		path:   fs.path,
 		digest: digest,
 		diffID: diffID,
@@ -54,54 +99,61 @@ func (fs *fscache) Put(l v1.Layer) (v1.Layer, error) {
 
 type layer struct {
 	v1.Layer
+	fs             *fscache
 	path           string
 	digest, diffID v1.Hash
 }
 
-func (l *layer) create(h v1.Hash) (io.WriteCloser, error) {
+// create returns a temporary file that becomes the cache entry for h once
+// the returned io.ReadCloser has been read to completion and closed. Entries
+// therefore only ever appear fully written, even to other processes.
+func (l *layer) create(rc io.ReadCloser, h v1.Hash) (io.ReadCloser, error) {
 	if err := os.MkdirAll(l.path, 0700); err != nil {
+		rc.Close()
 		return nil, err
 	}
-	return os.Create(cachepath(l.path, h))
-}
-
-func (l *layer) Compressed() (io.ReadCloser, error) {
-	f, err := l.create(l.digest)
+	f, err := ioutil.TempFile(l.path, tmpPrefix+"*")
 	if err != nil {
+		rc.Close()
 		return nil, err
 	}
+	out := &readcloser{t: io.TeeReader(rc, f)}
+	out.closes = []func() error{rc.Close, func() error {
+		return l.fs.commit(f, h, out.complete)
+	}, l.fs.evict}
+	return out, nil
+}
+
+func (l *layer) Compressed() (io.ReadCloser, error) {
 	rc, err := l.Layer.Compressed()
 	if err != nil {
 		return nil, err
 	}
-	return &readcloser{
-		t:      io.TeeReader(rc, f),
-		closes: []func() error{rc.Close, f.Close},
-	}, nil
+	return l.create(rc, l.digest)
 }
 
 func (l *layer) Uncompressed() (io.ReadCloser, error) {
-	f, err := l.create(l.diffID)
-	if err != nil {
-		return nil, err
-	}
 	rc, err := l.Layer.Uncompressed()
 	if err != nil {
 		return nil, err
 	}
-	return &readcloser{
-		t:      io.TeeReader(rc, f),
-		closes: []func() error{rc.Close, f.Close},
-	}, nil
+	return l.create(rc, l.diffID)
 }
 
 type readcloser struct {
 	t      io.Reader
 	closes []func() error
+
+	// complete is set once t has been read to EOF.
+	complete bool
 }
 
 func (rc *readcloser) Read(b []byte) (int, error) {
-	return rc.t.Read(b)
+	n, err := rc.t.Read(b)
+	if err == io.EOF {
+		rc.complete = true
+	}
+	return n, err
 }
 
 func (rc *readcloser) Close() error {
@@ -118,7 +170,21 @@ func (rc *readcloser) Close() error {
 }
 
 func (fs *fscache) Get(h v1.Hash) (v1.Layer, error) {
-	l, err := tarball.LayerFromFile(cachepath(fs.path, h))
+	l, err := fs.get(h)
+	fs.count(err)
+	return l, err
+}
+
+func (fs *fscache) get(h v1.Hash) (v1.Layer, error) {
+	p := cachepath(fs.path, h)
+	if fi, err := os.Stat(p); err == nil && fs.expired(fi, time.Now()) {
+		if err := fs.Delete(h); err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		atomic.AddInt64(&fs.evictions, 1)
+		return nil, ErrNotFound
+	}
+	l, err := tarball.LayerFromFile(p)
 	if os.IsNotExist(err) {
 		return nil, ErrNotFound
 	}
@@ -129,6 +195,12 @@ func (fs *fscache) Get(h v1.Hash) (v1.Layer, error) {
 		}
 		return nil, ErrNotFound
 	}
+	if err == nil {
+		// Record the access for eviction and expiry. This is best-effort,
+		// since the cache may be on a read-only filesystem.
+		now := time.Now()
+		_ = os.Chtimes(p, now, now)
+	}
 	return l, err
 }
 
@@ -0,0 +1,127 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// The filesystem cache may be shared by several processes, e.g. CI jobs on
+// the same runner. To keep it consistent:
+//
+//   - entries are written to temporary files and renamed into place only
+//     once they are complete;
+//   - changes to the set of entries (commits and eviction) happen while
+//     holding an advisory lock on the cache directory;
+//   - temporary files abandoned by crashed processes are cleaned up once
+//     they are no longer being written.
+const (
+	tmpPrefix = ".tmp-"
+
+	// staleAge is how long a temporary file must go without writes before
+	// it's considered abandoned.
+	staleAge = time.Hour
+)
+
+// lock acquires the cache lock, both within this process and across
+// processes, and returns a function that releases it.
+func (fs *fscache) lock() (func(), error) {
+	fs.mu.Lock()
+	if err := os.MkdirAll(fs.path, 0700); err != nil {
+		fs.mu.Unlock()
+		return nil, err
+	}
+	d, err := os.Open(fs.path)
+	if err != nil {
+		fs.mu.Unlock()
+		return nil, err
+	}
+	if err := lockFile(d); err != nil {
+		d.Close()
+		fs.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile(d)
+		d.Close()
+		fs.mu.Unlock()
+	}, nil
+}
+
+// commit closes f and, if it was written completely, atomically moves it into
+// place as the entry for h. Incomplete files are discarded.
+func (fs *fscache) commit(f *os.File, h v1.Hash, complete bool) error {
+	if err := f.Close(); err != nil || !complete {
+		os.Remove(f.Name())
+		return err
+	}
+	unlock, err := fs.lock()
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	defer unlock()
+	if err := os.Rename(f.Name(), cachepath(fs.path, h)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	atomic.AddInt64(&fs.writes, 1)
+	return nil
+}
+
+// isTemp returns true if name is a temporary file rather than a cache entry.
+func isTemp(name string) bool {
+	return strings.HasPrefix(name, tmpPrefix)
+}
+
+// cleanup removes the temporary file fi if it has been abandoned.
+func (fs *fscache) cleanup(fi os.FileInfo, now time.Time) error {
+	if now.Sub(fi.ModTime()) <= staleAge {
+		return nil
+	}
+	return fs.remove(fi.Name())
+}
+
+// writeFile atomically replaces the contents of the file at p.
+func writeFile(p string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p), tmpPrefix+"*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
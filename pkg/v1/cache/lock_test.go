@@ -0,0 +1,138 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestPartialWriteDiscarded(t *testing.T) {
+	dir := t.TempDir()
+	layers, hashes, _ := randomLayers(t, 1)
+
+	c := NewFilesystemCache(dir)
+	cl, err := c.Put(layers[0])
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := cl.Compressed()
+	if err != nil {
+		t.Fatalf("Compressed: %v", err)
+	}
+	if _, err := io.CopyN(ioutil.Discard, rc, 10); err != nil {
+		t.Fatalf("CopyN: %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	checkCached(t, c, hashes, false)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, fi := range files {
+		t.Errorf("unexpected file %q", fi.Name())
+	}
+}
+
+func TestStaleTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	layers, _, _ := randomLayers(t, 1)
+
+	stale := filepath.Join(dir, tmpPrefix+"stale")
+	fresh := filepath.Join(dir, tmpPrefix+"fresh")
+	for _, p := range []string{stale, fresh} {
+		if err := ioutil.WriteFile(p, []byte("partial"), 0600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * staleAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	fill(t, NewFilesystemCache(dir), layers[0])
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale temp file was not removed: %v", err)
+	}
+	// The fresh one may still be written by another process.
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("fresh temp file was removed: %v", err)
+	}
+}
+
+func TestConcurrentCaches(t *testing.T) {
+	dir := t.TempDir()
+	layers, hashes, size := randomLayers(t, 5)
+
+	// Each goroutine has its own cache, like separate processes would.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := NewFilesystemCache(dir, WithMaxSize(int64(len(layers))*size))
+			for _, l := range layers {
+				cl, err := c.Put(l)
+				if err != nil {
+					t.Errorf("Put: %v", err)
+					return
+				}
+				rc, err := cl.Compressed()
+				if err != nil {
+					t.Errorf("Compressed: %v", err)
+					return
+				}
+				if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+					t.Errorf("Copy: %v", err)
+				}
+				if err := rc.Close(); err != nil {
+					t.Errorf("Close: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	c := NewFilesystemCache(dir)
+	for _, h := range hashes {
+		l, err := c.Get(h)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", h, err)
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatalf("Compressed: %v", err)
+		}
+		got, _, err := v1.SHA256(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("SHA256: %v", err)
+		}
+		if got != h {
+			t.Errorf("cached digest = %s, want %s", got, h)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// evict removes abandoned temporary files and expired files from the cache,
+// then the least-recently-used files until their total size is within
+// maxSize. Pinned files are never removed, but they do count towards maxSize.
+//
+// Unbounded caches without TTLs have nothing to evict, so they skip this
+// except to sweep for abandoned temporary files every staleAge.
+func (fs *fscache) evict() error {
+	now := time.Now()
+	// Per-entry TTLs can apply even without a default.
+	_, err := os.Stat(filepath.Join(fs.path, ttlDir))
+	expires := fs.ttl > 0 || err == nil
+	if fs.maxSize <= 0 && !expires {
+		last := atomic.LoadInt64(&fs.swept)
+		if last != 0 && now.Sub(time.Unix(0, last)) < staleAge {
+			return nil
+		}
+		if !atomic.CompareAndSwapInt64(&fs.swept, last, now.UnixNano()) {
+			// Another goroutine is sweeping.
+			return nil
+		}
+	}
+
+	unlock, err := fs.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fis, err := ioutil.ReadDir(fs.path)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	files := make([]os.FileInfo, 0, len(fis))
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		if isTemp(fi.Name()) {
+			if err := fs.cleanup(fi, now); err != nil {
+				return err
+			}
+			continue
+		}
+		if expires && fs.expired(fi, now) {
+			if err := fs.remove(fi.Name()); err != nil {
+				return err
+			}
+			atomic.AddInt64(&fs.evictions, 1)
+			continue
+		}
+		total += fi.Size()
+		if fs.maxSize > 0 && !fs.pinned(fi.Name()) {
+			files = append(files, fi)
+		}
+	}
+	if err := fs.expireMetadata(now); err != nil {
+		return err
+	}
+	if fs.maxSize <= 0 || total <= fs.maxSize {
+		return nil
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, fi := range files {
+		if total <= fs.maxSize {
+			break
+		}
+		if err := fs.remove(fi.Name()); err != nil {
+			return err
+		}
+		atomic.AddInt64(&fs.evictions, 1)
+		total -= fi.Size()
+	}
+	return nil
+}
+
+// expireMetadata removes manifests and config files that have gone unused for
+// longer than the TTL, tags that are no longer fresh, and abandoned temporary
+// files next to them. They're small, so they don't count towards maxSize.
+func (fs *fscache) expireMetadata(now time.Time) error {
+	for _, d := range []struct {
+		dir string
+		ttl time.Duration
+	}{{manifestDir, fs.ttl}, {tagDir, fs.tagTTL}} {
+		fis, err := ioutil.ReadDir(filepath.Join(fs.path, d.dir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, fi := range fis {
+			age := now.Sub(fi.ModTime())
+			if isTemp(fi.Name()) {
+				if age > staleAge {
+					if err := fs.remove(filepath.Join(d.dir, fi.Name())); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if d.ttl > 0 && age > d.ttl {
+				if err := fs.remove(filepath.Join(d.dir, fi.Name())); err != nil {
+					return err
+				}
+				atomic.AddInt64(&fs.evictions, 1)
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *fscache) remove(name string) error {
+	if err := os.Remove(filepath.Join(fs.path, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func randomLayers(t *testing.T, n int) ([]v1.Layer, []v1.Hash, int64) {
+	t.Helper()
+
+	var (
+		layers []v1.Layer
+		hashes []v1.Hash
+		size   int64
+	)
+	for i := 0; i < n; i++ {
+		l, err := random.Layer(1024, types.DockerLayer)
+		if err != nil {
+			t.Fatalf("random.Layer: %v", err)
+		}
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatalf("Digest: %v", err)
+		}
+		sz, err := l.Size()
+		if err != nil {
+			t.Fatalf("Size: %v", err)
+		}
+		if sz > size {
+			size = sz
+		}
+		layers = append(layers, l)
+		hashes = append(hashes, h)
+	}
+	return layers, hashes, size
+}
+
+func fill(t *testing.T, c Cache, l v1.Layer) {
+	t.Helper()
+	cl, err := c.Put(l)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := cl.Compressed()
+	if err != nil {
+		t.Fatalf("Compressed: %v", err)
+	}
+	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+// age sets the last use of the cached entry to d ago.
+func age(t *testing.T, dir string, h v1.Hash, d time.Duration) {
+	t.Helper()
+	old := time.Now().Add(-d)
+	if err := os.Chtimes(cachepath(dir, h), old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+}
+
+// checkCached verifies which of hashes are in c.
+func checkCached(t *testing.T, c Cache, hashes []v1.Hash, want ...bool) {
+	t.Helper()
+	for i, h := range hashes {
+		_, err := c.Get(h)
+		if got := err == nil; got != want[i] {
+			t.Errorf("Get(layers[%d]) cached = %t, want %t (err = %v)", i, got, want[i], err)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(layers[%d]): %v", i, err)
+		}
+	}
+}
+
+func TestFilesystemCacheMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	layers, hashes, size := randomLayers(t, 3)
+
+	// Room for two of the three layers.
+	c := NewFilesystemCache(dir, WithMaxSize(2*size))
+	fill(t, c, layers[0])
+	fill(t, c, layers[1])
+
+	// Age both entries, with layers[0] older than layers[1].
+	age(t, dir, hashes[0], 2*time.Hour)
+	age(t, dir, hashes[1], time.Hour)
+
+	// Using layers[0] makes layers[1] the least recently used.
+	if _, err := c.Get(hashes[0]); err != nil {
+		t.Fatalf("Get(%s): %v", hashes[0], err)
+	}
+
+	fill(t, c, layers[2])
+
+	checkCached(t, c, hashes, true, false, true)
+}
+
+func TestFilesystemCacheUnbounded(t *testing.T) {
+	dir := t.TempDir()
+	layers, hashes, _ := randomLayers(t, 2)
+
+	c := NewFilesystemCache(dir)
+	fill(t, c, layers[0])
+
+	// Having just swept, an unbounded cache doesn't look at its entries
+	// again for a while, so this isn't cleaned up yet.
+	stale := filepath.Join(dir, tmpPrefix+"stale")
+	if err := ioutil.WriteFile(stale, []byte("partial"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	old := time.Now().Add(-2 * staleAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	fill(t, c, layers[1])
+
+	if _, err := os.Stat(stale); err != nil {
+		t.Errorf("stale temp file was removed before the next sweep: %v", err)
+	}
+	checkCached(t, c, hashes, true, true)
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ManifestCache is implemented by caches that can also hold manifests and
+// config files, which are small enough to be handled as bytes, as well as
+// the digests that tags point to.
+//
+// For the filesystem cache, manifests and config files expire with WithTTL
+// and tags once they're older than WithTagTTL, but since they're small, they
+// don't count towards WithMaxSize.
+type ManifestCache interface {
+	// PutManifest writes the manifest or config file with the given
+	// digest to the cache.
+	PutManifest(v1.Hash, []byte) error
+
+	// GetManifest returns the manifest or config file with the given
+	// digest, or ErrNotFound.
+	GetManifest(v1.Hash) ([]byte, error)
+
+	// PutTag records that the tag currently points to the given digest.
+	PutTag(name.Tag, v1.Hash) error
+
+	// GetTag returns the digest the tag pointed to when it was last
+	// recorded, or ErrNotFound if it's unknown or no longer fresh.
+	GetTag(name.Tag) (v1.Hash, error)
+}
+
+// WithTagTTL caches the digests that tags point to for d. Since tags are
+// mutable, they aren't cached unless this is set.
+func WithTagTTL(d time.Duration) Option {
+	return func(fs *fscache) {
+		fs.tagTTL = d
+	}
+}
+
+const (
+	manifestDir = ".manifests"
+	tagDir      = ".tags"
+)
+
+func (fs *fscache) PutManifest(h v1.Hash, b []byte) error {
+	return writeFile(cachepath(filepath.Join(fs.path, manifestDir), h), b)
+}
+
+func (fs *fscache) GetManifest(h v1.Hash) ([]byte, error) {
+	b, err := fs.getManifest(h)
+	fs.count(err)
+	return b, err
+}
+
+func (fs *fscache) getManifest(h v1.Hash) ([]byte, error) {
+	p := cachepath(filepath.Join(fs.path, manifestDir), h)
+	if fi, err := os.Stat(p); err == nil && fs.ttl > 0 && time.Since(fi.ModTime()) > fs.ttl {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		atomic.AddInt64(&fs.evictions, 1)
+		return nil, ErrNotFound
+	}
+	b, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if got, _, err := v1.SHA256(bytes.NewReader(b)); err != nil || got != h {
+		// Treat corrupted entries as missing.
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	// Record the access for expiry, as for layers.
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	return b, nil
+}
+
+func (fs *fscache) PutTag(t name.Tag, h v1.Hash) error {
+	if fs.tagTTL <= 0 {
+		return nil
+	}
+	return writeFile(fs.tagpath(t), []byte(h.String()))
+}
+
+func (fs *fscache) GetTag(t name.Tag) (v1.Hash, error) {
+	if fs.tagTTL <= 0 {
+		return v1.Hash{}, ErrNotFound
+	}
+	p := fs.tagpath(t)
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return v1.Hash{}, ErrNotFound
+	}
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	if time.Since(fi.ModTime()) > fs.tagTTL {
+		return v1.Hash{}, ErrNotFound
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	h, err := v1.NewHash(strings.TrimSpace(string(b)))
+	if err != nil {
+		return v1.Hash{}, ErrNotFound
+	}
+	return h, nil
+}
+
+// tagpath returns where the digest for t is stored. Tags contain characters
+// that aren't allowed in file names everywhere, so the file is named after
+// their hash.
+func (fs *fscache) tagpath(t name.Tag) string {
+	sum := sha256.Sum256([]byte(t.String()))
+	return filepath.Join(fs.path, tagDir, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Pinner is implemented by caches whose entries can be pinned.
+//
+// Pinned entries are never evicted or expired, which is useful for keeping
+// base image layers around while transient layers come and go.
+type Pinner interface {
+	// Pin protects the entry with the given Hash. An entry can be pinned
+	// before it has been written to the cache.
+	Pin(v1.Hash) error
+
+	// Unpin allows the entry with the given Hash to be evicted again.
+	Unpin(v1.Hash) error
+}
+
+// Expirer is implemented by caches that support per-entry TTLs.
+type Expirer interface {
+	// SetTTL overrides the cache's default TTL for the entry with the
+	// given Hash. A TTL of zero restores the default.
+	SetTTL(v1.Hash, time.Duration) error
+}
+
+// Metadata is stored in hidden directories next to the cached files, so that
+// it is shared by every process that uses the same cache path.
+const (
+	pinDir = ".pinned"
+	ttlDir = ".ttl"
+)
+
+func (fs *fscache) Pin(h v1.Hash) error {
+	return writeFile(cachepath(filepath.Join(fs.path, pinDir), h), nil)
+}
+
+func (fs *fscache) Unpin(h v1.Hash) error {
+	err := os.Remove(cachepath(filepath.Join(fs.path, pinDir), h))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (fs *fscache) SetTTL(h v1.Hash, ttl time.Duration) error {
+	p := cachepath(filepath.Join(fs.path, ttlDir), h)
+	if ttl == 0 {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return writeFile(p, []byte(ttl.String()))
+}
+
+func (fs *fscache) pinned(name string) bool {
+	_, err := os.Stat(filepath.Join(fs.path, pinDir, name))
+	return err == nil
+}
+
+// ttlFor returns the TTL of the named entry, falling back to the default.
+func (fs *fscache) ttlFor(name string) time.Duration {
+	b, err := ioutil.ReadFile(filepath.Join(fs.path, ttlDir, name))
+	if err != nil {
+		return fs.ttl
+	}
+	ttl, err := time.ParseDuration(strings.TrimSpace(string(b)))
+	if err != nil {
+		return fs.ttl
+	}
+	return ttl
+}
+
+// expired returns true if the cached file fi has gone unused for longer than
+// its TTL.
+func (fs *fscache) expired(fi os.FileInfo, now time.Time) bool {
+	if fs.pinned(fi.Name()) {
+		return false
+	}
+	ttl := fs.ttlFor(fi.Name())
+	return ttl > 0 && now.Sub(fi.ModTime()) > ttl
+}
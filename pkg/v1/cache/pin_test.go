@@ -0,0 +1,88 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+var (
+	_ Pinner  = (*fscache)(nil)
+	_ Expirer = (*fscache)(nil)
+)
+
+func TestFilesystemCachePin(t *testing.T) {
+	dir := t.TempDir()
+	layers, hashes, size := randomLayers(t, 3)
+
+	c := NewFilesystemCache(dir, WithMaxSize(2*size))
+	p := c.(Pinner)
+
+	// Pinning works before the entry exists.
+	if err := p.Pin(hashes[0]); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	fill(t, c, layers[0])
+	fill(t, c, layers[1])
+	age(t, dir, hashes[0], 2*time.Hour)
+	age(t, dir, hashes[1], time.Hour)
+
+	// layers[0] is the least recently used, but it's pinned.
+	fill(t, c, layers[2])
+	checkCached(t, c, hashes, true, false, true)
+
+	if err := p.Unpin(hashes[0]); err != nil {
+		t.Fatalf("Unpin: %v", err)
+	}
+	if err := p.Unpin(hashes[0]); err != nil {
+		t.Errorf("Unpin (again): %v", err)
+	}
+	age(t, dir, hashes[0], 2*time.Hour)
+	fill(t, c, layers[1])
+	checkCached(t, c, hashes, false, true, true)
+}
+
+func TestFilesystemCacheTTL(t *testing.T) {
+	dir := t.TempDir()
+	layers, hashes, _ := randomLayers(t, 3)
+
+	c := NewFilesystemCache(dir, WithTTL(time.Hour))
+	for _, l := range layers {
+		fill(t, c, l)
+	}
+	if err := c.(Expirer).SetTTL(hashes[1], 3*time.Hour); err != nil {
+		t.Fatalf("SetTTL: %v", err)
+	}
+	if err := c.(Pinner).Pin(hashes[2]); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	for _, h := range hashes {
+		age(t, dir, h, 2*time.Hour)
+	}
+	checkCached(t, c, hashes, false, true, true)
+
+	// Restoring the default TTL expires layers[1] on the next write.
+	if err := c.(Expirer).SetTTL(hashes[1], 0); err != nil {
+		t.Fatalf("SetTTL: %v", err)
+	}
+	age(t, dir, hashes[1], 2*time.Hour)
+	fill(t, c, layers[0])
+	if _, err := os.Stat(cachepath(dir, hashes[1])); !os.IsNotExist(err) {
+		t.Errorf("expired entry was not removed: %v", err)
+	}
+	checkCached(t, c, hashes, true, false, true)
+}
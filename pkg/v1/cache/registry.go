@@ -0,0 +1,119 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+type regcache struct {
+	repo    name.Repository
+	options []remote.Option
+
+	// Registries address blobs by digest only, so we remember the DiffIDs
+	// of layers we've Put in order to serve lookups by DiffID.
+	mu      sync.Mutex
+	digests map[v1.Hash]v1.Hash
+}
+
+// NewRegistryCache returns a Cache implementation that stores layers as blobs
+// in the given repository, which is typically in a registry close to the
+// consumers of the cache.
+//
+// Put uploads the layer right away. If the layer was read from a repository
+// in the same registry, remote will mount it rather than uploading it again,
+// and layers that are already in the cache repository are not uploaded at all.
+//
+// Get by DiffID only finds layers that were Put through the same Cache, since
+// registries have no notion of DiffIDs.
+//
+// Delete is a no-op: the registry API has no portable way to delete blobs.
+// Note that registries may garbage collect blobs that aren't referenced by a
+// manifest.
+//
+// To combine this with a local cache, wrap an image twice:
+//
+//	img = cache.Image(cache.Image(img, registryCache), filesystemCache)
+func NewRegistryCache(repo name.Repository, options ...remote.Option) Cache {
+	return &regcache{
+		repo:    repo,
+		options: options,
+		digests: map[v1.Hash]v1.Hash{},
+	}
+}
+
+func (rc *regcache) Put(l v1.Layer) (v1.Layer, error) {
+	digest, err := l.Digest()
+	if err != nil {
+		return nil, err
+	}
+	diffID, err := l.DiffID()
+	if err != nil {
+		return nil, err
+	}
+	if err := remote.WriteLayer(rc.repo, l, rc.options...); err != nil {
+		return nil, fmt.Errorf("writing %s to cache: %w", digest, err)
+	}
+	rc.mu.Lock()
+	rc.digests[diffID] = digest
+	rc.mu.Unlock()
+
+	cached, err := remote.Layer(rc.repo.Digest(digest.String()), rc.options...)
+	if err != nil {
+		return nil, err
+	}
+	return &regLayer{Layer: l, cached: cached}, nil
+}
+
+func (rc *regcache) Get(h v1.Hash) (v1.Layer, error) {
+	rc.mu.Lock()
+	if digest, ok := rc.digests[h]; ok {
+		h = digest
+	}
+	rc.mu.Unlock()
+
+	l, err := remote.Layer(rc.repo.Digest(h.String()), rc.options...)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := partial.Exists(l)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return l, nil
+}
+
+func (rc *regcache) Delete(v1.Hash) error { return nil }
+
+// regLayer reads the contents of a layer from the cache repository, but
+// otherwise describes the original layer, which knows its media type and
+// DiffID without reading anything.
+type regLayer struct {
+	v1.Layer
+	cached v1.Layer
+}
+
+func (l *regLayer) Compressed() (io.ReadCloser, error)   { return l.cached.Compressed() }
+func (l *regLayer) Uncompressed() (io.ReadCloser, error) { return l.cached.Uncompressed() }
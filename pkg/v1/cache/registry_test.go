@@ -0,0 +1,113 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestRegistryCache(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := name.NewTag(fmt.Sprintf("%s/source:latest", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(fmt.Sprintf("%s/cache", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatalf("random.Image: %v", err)
+	}
+	if err := remote.Write(src, img); err != nil {
+		t.Fatalf("remote.Write: %v", err)
+	}
+	rimg, err := remote.Image(src)
+	if err != nil {
+		t.Fatalf("remote.Image: %v", err)
+	}
+
+	c := NewRegistryCache(repo)
+	ls, err := Image(rimg, c).Layers()
+	if err != nil {
+		t.Fatalf("Layers: %v", err)
+	}
+	for _, l := range ls {
+		rc, err := l.Uncompressed()
+		if err != nil {
+			t.Fatalf("Uncompressed: %v", err)
+		}
+		if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+			t.Fatalf("Copy: %v", err)
+		}
+		rc.Close()
+	}
+
+	// Another cache using the same repository finds the layers by digest.
+	other := NewRegistryCache(repo)
+	for _, l := range ls {
+		digest, err := l.Digest()
+		if err != nil {
+			t.Fatalf("Digest: %v", err)
+		}
+		cl, err := other.Get(digest)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", digest, err)
+		}
+		rc, err := cl.Compressed()
+		if err != nil {
+			t.Fatalf("Compressed: %v", err)
+		}
+		got, _, err := v1.SHA256(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("SHA256: %v", err)
+		}
+		if got != digest {
+			t.Errorf("cached digest = %s, want %s", got, digest)
+		}
+
+		// Only the cache that wrote the layer can find it by DiffID.
+		diffID, err := l.DiffID()
+		if err != nil {
+			t.Fatalf("DiffID: %v", err)
+		}
+		if _, err := c.Get(diffID); err != nil {
+			t.Errorf("Get(%s): %v", diffID, err)
+		}
+		if _, err := other.Get(diffID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%s) = %v, want ErrNotFound", diffID, err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// RemoteImage is like remote.Image, but uses c to avoid talking to the
+// registry at all when everything it needs has been cached.
+//
+// Layers are cached as with Image. If c implements ManifestCache, the
+// manifest and config file are cached by digest as well, and references by
+// tag are resolved through the cache while they're fresh. References to an
+// index are resolved by the registry each time, since that depends on the
+// requested platform, but the image they resolve to is cached.
+func RemoteImage(ref name.Reference, c Cache, options ...remote.Option) (v1.Image, error) {
+	mc, _ := c.(ManifestCache)
+	raw, err := cachedManifest(ref, mc)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		if raw, err = fetchManifest(ref, mc, options...); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := v1.ParseManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	blob := func(h v1.Hash) (io.ReadCloser, error) {
+		if h == m.Config.Digest {
+			return fetchConfig(ref.Context().Digest(h.String()), mc, options...)
+		}
+		l, err := remote.Layer(ref.Context().Digest(h.String()), options...)
+		if err != nil {
+			return nil, err
+		}
+		return l.Compressed()
+	}
+	img, err := partial.ImageFromFuncs(func() ([]byte, error) { return raw, nil }, blob)
+	if err != nil {
+		return nil, err
+	}
+	return Image(img, c), nil
+}
+
+// cachedManifest returns the cached manifest for ref, or nil if it's missing.
+func cachedManifest(ref name.Reference, mc ManifestCache) ([]byte, error) {
+	if mc == nil {
+		return nil, nil
+	}
+	var (
+		h   v1.Hash
+		err error
+	)
+	switch r := ref.(type) {
+	case name.Digest:
+		h, err = v1.NewHash(r.DigestStr())
+	case name.Tag:
+		h, err = mc.GetTag(r)
+	default:
+		return nil, nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	raw, err := mc.GetManifest(h)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return raw, err
+}
+
+// fetchManifest returns the image manifest for ref from the registry, and
+// writes it to mc.
+func fetchManifest(ref name.Reference, mc ManifestCache, options ...remote.Option) ([]byte, error) {
+	desc, err := remote.Get(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	raw, h := desc.Manifest, desc.Digest
+	index := desc.MediaType == types.OCIImageIndex || desc.MediaType == types.DockerManifestList
+	if index {
+		img, err := desc.Image()
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = img.RawManifest(); err != nil {
+			return nil, err
+		}
+		if h, err = img.Digest(); err != nil {
+			return nil, err
+		}
+	}
+	if mc == nil {
+		return raw, nil
+	}
+	if err := mc.PutManifest(h, raw); err != nil {
+		return nil, err
+	}
+	if t, ok := ref.(name.Tag); ok && !index {
+		if err := mc.PutTag(t, h); err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+// fetchConfig returns the config blob ref, from mc if it's cached.
+func fetchConfig(ref name.Digest, mc ManifestCache, options ...remote.Option) (io.ReadCloser, error) {
+	h, err := v1.NewHash(ref.DigestStr())
+	if err != nil {
+		return nil, err
+	}
+	if mc != nil {
+		b, err := mc.GetManifest(h)
+		if err == nil {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	l, err := remote.Layer(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	if mc != nil {
+		if err := mc.PutManifest(h, b); err != nil {
+			return nil, err
+		}
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestRemoteImage(t *testing.T) {
+	var requests int64
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(fmt.Sprintf("%s/test:latest", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatalf("random.Image: %v", err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatalf("remote.Write: %v", err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+
+	c := NewFilesystemCache(t.TempDir(), WithTagTTL(time.Hour))
+	read := func(ref name.Reference) {
+		t.Helper()
+		img, err := RemoteImage(ref, c)
+		if err != nil {
+			t.Fatalf("RemoteImage: %v", err)
+		}
+		if got, err := img.Digest(); err != nil {
+			t.Fatalf("Digest: %v", err)
+		} else if got != want {
+			t.Errorf("Digest() = %s, want %s", got, want)
+		}
+		if _, err := img.ConfigFile(); err != nil {
+			t.Fatalf("ConfigFile: %v", err)
+		}
+		ls, err := img.Layers()
+		if err != nil {
+			t.Fatalf("Layers: %v", err)
+		}
+		for _, l := range ls {
+			rc, err := l.Compressed()
+			if err != nil {
+				t.Fatalf("Compressed: %v", err)
+			}
+			if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+				t.Fatalf("Copy: %v", err)
+			}
+			rc.Close()
+		}
+	}
+
+	atomic.StoreInt64(&requests, 0)
+	read(tag)
+	if atomic.LoadInt64(&requests) == 0 {
+		t.Fatal("expected requests to populate the cache")
+	}
+
+	// Everything is cached now, by tag and by digest.
+	atomic.StoreInt64(&requests, 0)
+	read(tag)
+	read(tag.Context().Digest(want.String()))
+	if got := atomic.LoadInt64(&requests); got != 0 {
+		t.Errorf("made %d requests, want 0", got)
+	}
+
+	cached, err := RemoteImage(tag, c)
+	if err != nil {
+		t.Fatalf("RemoteImage: %v", err)
+	}
+	if err := validate.Image(cached); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+}
+
+func TestTagTTL(t *testing.T) {
+	tag, err := name.NewTag("example.com/test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := v1.NewHash("sha256:" + fmt.Sprintf("%064d", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tags aren't cached by default.
+	c := NewFilesystemCache(t.TempDir()).(ManifestCache)
+	if err := c.PutTag(tag, h); err != nil {
+		t.Fatalf("PutTag: %v", err)
+	}
+	if _, err := c.GetTag(tag); err != ErrNotFound {
+		t.Errorf("GetTag() = %v, want ErrNotFound", err)
+	}
+
+	dir := t.TempDir()
+	c = NewFilesystemCache(dir, WithTagTTL(time.Hour)).(ManifestCache)
+	if err := c.PutTag(tag, h); err != nil {
+		t.Fatalf("PutTag: %v", err)
+	}
+	if got, err := c.GetTag(tag); err != nil {
+		t.Errorf("GetTag: %v", err)
+	} else if got != h {
+		t.Errorf("GetTag() = %s, want %s", got, h)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	p := c.(*fscache).tagpath(tag)
+	if err := os.Chtimes(p, old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if _, err := c.GetTag(tag); err != ErrNotFound {
+		t.Errorf("GetTag() = %v, want ErrNotFound", err)
+	}
+}
+
+func TestManifestCacheExpiry(t *testing.T) {
+	tag, err := name.NewTag("example.com/test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := []byte("{}")
+	h, _, err := v1.SHA256(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	c := NewFilesystemCache(dir, WithTTL(time.Hour), WithTagTTL(time.Hour))
+	mc := c.(ManifestCache)
+	if err := mc.PutManifest(h, b); err != nil {
+		t.Fatalf("PutManifest: %v", err)
+	}
+	if err := mc.PutTag(tag, h); err != nil {
+		t.Fatalf("PutTag: %v", err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	manifest := cachepath(filepath.Join(dir, manifestDir), h)
+	tagfile := c.(*fscache).tagpath(tag)
+	for _, p := range []string{manifest, tagfile} {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+	if _, err := mc.GetManifest(h); err != ErrNotFound {
+		t.Errorf("GetManifest() = %v, want ErrNotFound", err)
+	}
+
+	// Writing a layer sweeps stale tags, too.
+	layers, _, _ := randomLayers(t, 1)
+	fill(t, c, layers[0])
+	for _, p := range []string{manifest, tagfile} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", p, err)
+		}
+	}
+}
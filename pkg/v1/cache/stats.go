@@ -0,0 +1,144 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Entry describes a layer in a cache.
+type Entry struct {
+	// Hash is the digest or DiffID the layer is cached by.
+	Hash v1.Hash
+
+	// Size is the number of bytes the entry takes up.
+	Size int64
+
+	// LastUsed is when the entry was last written or returned by Get.
+	LastUsed time.Time
+
+	// Pinned is true if the entry is exempt from eviction and expiry.
+	Pinned bool
+}
+
+// Stats summarizes the contents and usage of a cache.
+//
+// The counters are kept in memory, so they only reflect the use of the cache
+// by this process since it was created.
+type Stats struct {
+	// Entries is the number of layers in the cache.
+	Entries int
+
+	// Size is the total size of the layers in the cache, in bytes.
+	Size int64
+
+	// Hits and Misses count the lookups that did and didn't find an entry.
+	Hits, Misses int64
+
+	// Writes counts the entries written to the cache.
+	Writes int64
+
+	// Evictions counts the entries removed to respect size limits or TTLs.
+	Evictions int64
+}
+
+// HitRate returns the fraction of lookups that found an entry, or zero if
+// there haven't been any.
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Inspector is implemented by caches that can report on their contents.
+type Inspector interface {
+	// List returns the entries in the cache.
+	List() ([]Entry, error)
+
+	// Stats returns the current Stats for the cache.
+	Stats() (Stats, error)
+}
+
+// count records the outcome of a lookup.
+func (fs *fscache) count(err error) {
+	if err == nil {
+		atomic.AddInt64(&fs.hits, 1)
+	} else if errors.Is(err, ErrNotFound) {
+		atomic.AddInt64(&fs.misses, 1)
+	}
+}
+
+func (fs *fscache) List() ([]Entry, error) {
+	fis, err := ioutil.ReadDir(fs.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() || isTemp(fi.Name()) {
+			continue
+		}
+		h, err := parseCachepath(fi.Name())
+		if err != nil {
+			// Not one of ours.
+			continue
+		}
+		entries = append(entries, Entry{
+			Hash:     h,
+			Size:     fi.Size(),
+			LastUsed: fi.ModTime(),
+			Pinned:   fs.pinned(fi.Name()),
+		})
+	}
+	return entries, nil
+}
+
+func (fs *fscache) Stats() (Stats, error) {
+	entries, err := fs.List()
+	if err != nil {
+		return Stats{}, err
+	}
+	s := Stats{
+		Entries:   len(entries),
+		Hits:      atomic.LoadInt64(&fs.hits),
+		Misses:    atomic.LoadInt64(&fs.misses),
+		Writes:    atomic.LoadInt64(&fs.writes),
+		Evictions: atomic.LoadInt64(&fs.evictions),
+	}
+	for _, e := range entries {
+		s.Size += e.Size
+	}
+	return s, nil
+}
+
+// parseCachepath is the inverse of cachepath.
+func parseCachepath(file string) (v1.Hash, error) {
+	if runtime.GOOS == "windows" {
+		file = strings.Replace(file, "-", ":", 1)
+	}
+	return v1.NewHash(file)
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+var _ Inspector = (*fscache)(nil)
+
+func TestStats(t *testing.T) {
+	dir := t.TempDir()
+	layers, hashes, size := randomLayers(t, 3)
+
+	c := NewFilesystemCache(dir, WithMaxSize(2*size))
+	in := c.(Inspector)
+
+	s, err := in.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if s != (Stats{}) {
+		t.Errorf("Stats() = %+v, want zero", s)
+	}
+
+	if _, err := c.Get(hashes[0]); err != ErrNotFound {
+		t.Fatalf("Get() = %v, want ErrNotFound", err)
+	}
+	fill(t, c, layers[0])
+	fill(t, c, layers[1])
+	age(t, dir, hashes[0], time.Hour)
+	if _, err := c.Get(hashes[1]); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := c.(Pinner).Pin(hashes[1]); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	fill(t, c, layers[2])
+
+	s, err = in.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if got, want := s.Entries, 2; got != want {
+		t.Errorf("Entries = %d, want %d", got, want)
+	}
+	if s.Size <= 0 || s.Size > 2*size {
+		t.Errorf("Size = %d, want (0, %d]", s.Size, 2*size)
+	}
+	if got, want := s.Hits, int64(1); got != want {
+		t.Errorf("Hits = %d, want %d", got, want)
+	}
+	if got, want := s.Misses, int64(1); got != want {
+		t.Errorf("Misses = %d, want %d", got, want)
+	}
+	if got, want := s.Writes, int64(3); got != want {
+		t.Errorf("Writes = %d, want %d", got, want)
+	}
+	if got, want := s.Evictions, int64(1); got != want {
+		t.Errorf("Evictions = %d, want %d", got, want)
+	}
+	if got, want := s.HitRate(), 0.5; got != want {
+		t.Errorf("HitRate() = %f, want %f", got, want)
+	}
+
+	entries, err := in.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	got := map[v1.Hash]Entry{}
+	for _, e := range entries {
+		got[e.Hash] = e
+	}
+	if _, ok := got[hashes[0]]; ok {
+		t.Errorf("List() contains evicted entry %s", hashes[0])
+	}
+	if e, ok := got[hashes[1]]; !ok || !e.Pinned {
+		t.Errorf("List()[%s] = %+v, want pinned", hashes[1], e)
+	}
+	if e, ok := got[hashes[2]]; !ok || e.Pinned || e.LastUsed.IsZero() {
+		t.Errorf("List()[%s] = %+v, want unpinned and used", hashes[2], e)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Difference is one way in which two images, indexes or layers differ.
+type Difference struct {
+	// Path locates the difference, e.g. "config.architecture",
+	// "manifest.annotations[\"org.opencontainers.image.title\"]" or
+	// "layers[2].digest".  Manifest and config paths follow their JSON
+	// field names.
+	Path string
+
+	// A and B hold the differing values of the first and second argument,
+	// respectively.  Either is nil if the value is absent from its side.
+	A, B interface{}
+}
+
+// String implements fmt.Stringer.
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %v != %v", d.Path, d.A, d.B)
+}
+
+// Error is returned by Images, Indexes and Layers when their arguments differ.
+type Error struct {
+	// Kind is what was compared, e.g. "Images".
+	Kind string
+	// Differences holds every difference that was found.
+	Differences []Difference
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	lines := make([]string, 0, len(e.Differences))
+	for _, d := range e.Differences {
+		lines = append(lines, d.String())
+	}
+	return e.Kind + " differ:\n" + strings.Join(lines, "\n")
+}
+
+// asError returns an *Error holding diffs, or nil if there are none.
+func asError(kind string, diffs []Difference, err error) error {
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+	return &Error{Kind: kind, Differences: diffs}
+}
+
+// compareValue appends a Difference at path to diffs if a and b differ.
+func compareValue(diffs []Difference, path string, a, b interface{}) []Difference {
+	if !reflect.DeepEqual(a, b) {
+		diffs = append(diffs, Difference{Path: path, A: a, B: b})
+	}
+	return diffs
+}
+
+// compareJSON appends the differences between the JSON documents a and b to
+// diffs, omitting the top-level fields in skip.
+func compareJSON(diffs []Difference, path string, a, b []byte, skip ...string) ([]Difference, error) {
+	var av, bv map[string]interface{}
+	if err := json.Unmarshal(a, &av); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := json.Unmarshal(b, &bv); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, k := range skip {
+		delete(av, k)
+		delete(bv, k)
+	}
+	return compareValues(diffs, path, av, bv), nil
+}
+
+// compareValues appends the differences between a and b, as decoded by
+// encoding/json, to diffs.  Objects and arrays are compared element-wise, so
+// that each difference is reported at the most specific path.  An absent
+// object or array is compared as if it were empty.
+func compareValues(diffs []Difference, path string, a, b interface{}) []Difference {
+	a, b = orEmpty(a, b), orEmpty(b, a)
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diffs = compareValues(diffs, fieldPath(path, k), av[k], bv[k])
+		}
+		return diffs
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			var ae, be interface{}
+			if i < len(av) {
+				ae = av[i]
+			}
+			if i < len(bv) {
+				be = bv[i]
+			}
+			diffs = compareValues(diffs, fmt.Sprintf("%s[%d]", path, i), ae, be)
+		}
+		return diffs
+	}
+	return compareValue(diffs, path, a, b)
+}
+
+// orEmpty returns an empty value of the same kind as other if v is nil and
+// other is an object or array, and v otherwise.
+func orEmpty(v, other interface{}) interface{} {
+	if v != nil {
+		return v
+	}
+	switch other.(type) {
+	case map[string]interface{}:
+		return map[string]interface{}{}
+	case []interface{}:
+		return []interface{}{}
+	}
+	return v
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// fieldPath returns the path of field k of the object at path.  Keys that
+// aren't identifiers, like most annotation keys, are quoted.
+func fieldPath(path, k string) string {
+	if !identifier.MatchString(k) {
+		return fmt.Sprintf("%s[%q]", path, k)
+	}
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
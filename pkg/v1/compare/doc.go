@@ -12,5 +12,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package compare provides methods for comparing images, indexes, and layers.
+// Package compare provides methods for comparing images, indexes, and layers,
+// and for reporting the differences between them.
 package compare
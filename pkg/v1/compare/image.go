@@ -0,0 +1,150 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Images compares the given images to each other and returns an *Error if
+// they differ.
+func Images(a, b v1.Image) error {
+	diffs, err := ImageDifferences(a, b)
+	return asError("Images", diffs, err)
+}
+
+// ImageDifferences returns the differences between the given images: their
+// digests, media types and sizes, the fields of their manifests and config
+// files, and their layers, as compared by LayerDifferences, along with the
+// annotations of the layers' descriptors.
+func ImageDifferences(a, b v1.Image) ([]Difference, error) {
+	return imageDifferences(nil, "", a, b, false)
+}
+
+// imageDifferences appends the differences between a and b to diffs.  If
+// nested, a and b are children of an index whose descriptors have already
+// been compared, so their digests, media types and sizes are not.
+func imageDifferences(diffs []Difference, path string, a, b v1.Image, nested bool) ([]Difference, error) {
+	digests := []v1.Hash{}
+	manifests := []*v1.Manifest{}
+	rawManifests := [][]byte{}
+	rawConfigs := [][]byte{}
+	sizes := []int64{}
+	mts := []types.MediaType{}
+	layerss := [][]v1.Layer{}
+
+	for _, img := range []v1.Image{a, b} {
+		layers, err := img.Layers()
+		if err != nil {
+			return nil, err
+		}
+		layerss = append(layerss, layers)
+
+		digest, err := img.Digest()
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+
+		manifest, err := img.Manifest()
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+
+		rawManifest, err := img.RawManifest()
+		if err != nil {
+			return nil, err
+		}
+		rawManifests = append(rawManifests, rawManifest)
+
+		rawConfig, err := img.RawConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		rawConfigs = append(rawConfigs, rawConfig)
+
+		size, err := img.Size()
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, size)
+
+		mt, err := img.MediaType()
+		if err != nil {
+			return nil, err
+		}
+		mts = append(mts, mt)
+	}
+
+	if !nested {
+		diffs = compareValue(diffs, fieldPath(path, "digest"), digests[0], digests[1])
+		diffs = compareValue(diffs, fieldPath(path, "mediaType"), mts[0], mts[1])
+		diffs = compareValue(diffs, fieldPath(path, "size"), sizes[0], sizes[1])
+	}
+
+	// Layers are compared below, and the config's diffids along with them.
+	diffs, err := compareJSON(diffs, fieldPath(path, "manifest"), rawManifests[0], rawManifests[1], "mediaType", "layers")
+	if err != nil {
+		return nil, err
+	}
+	diffs, err = compareJSON(diffs, fieldPath(path, "config"), rawConfigs[0], rawConfigs[1], "rootfs")
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(layerss[0]) || i < len(layerss[1]); i++ {
+		lp := fmt.Sprintf("%s[%d]", fieldPath(path, "layers"), i)
+		if i >= len(layerss[0]) || i >= len(layerss[1]) {
+			// Only one image has this layer; report its digest.
+			var ds [2]interface{}
+			for j, layers := range layerss {
+				if i < len(layers) {
+					d, err := layers[i].Digest()
+					if err != nil {
+						return nil, err
+					}
+					ds[j] = d
+				}
+			}
+			diffs = compareValue(diffs, lp, ds[0], ds[1])
+			continue
+		}
+
+		diffs, err = layerDifferences(diffs, lp, layerss[0][i], layerss[1][i])
+		if err != nil {
+			return nil, err
+		}
+		if i < len(manifests[0].Layers) && i < len(manifests[1].Layers) {
+			diffs = compareValues(diffs, fieldPath(lp, "annotations"),
+				annotations(manifests[0].Layers[i].Annotations), annotations(manifests[1].Layers[i].Annotations))
+		}
+	}
+
+	return diffs, nil
+}
+
+// annotations returns anns in the form decoded by encoding/json, so that they
+// can be compared by compareValues key by key.
+func annotations(anns map[string]string) map[string]interface{} {
+	m := make(map[string]interface{}, len(anns))
+	for k, v := range anns {
+		m[k] = v
+	}
+	return m
+}
@@ -15,8 +15,10 @@
 package compare
 
 import (
+	"errors"
 	"testing"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -64,3 +66,64 @@ func TestEqualImages(t *testing.T) {
 		t.Errorf("got err: %v", err)
 	}
 }
+
+func TestImageDifferences(t *testing.T) {
+	a, err := random.Image(100, 2, random.WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := a.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.Architecture = "arm64"
+	b, err := mutate.ConfigFile(a, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = mutate.Annotations(b, map[string]string{"org.opencontainers.image.title": "b"}).(v1.Image)
+	l, err := random.Layer(100, types.DockerLayer, random.WithSeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = mutate.AppendLayers(b, l); err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := ImageDifferences(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]Difference{}
+	for _, d := range diffs {
+		got[d.Path] = d
+	}
+	ld, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]Difference{
+		"config.architecture": {A: "", B: "arm64"},
+		`manifest.annotations["org.opencontainers.image.title"]`: {A: nil, B: "b"},
+		"layers[2]": {A: nil, B: ld},
+	} {
+		d, ok := got[path]
+		if !ok {
+			t.Errorf("missing difference at %s; got %v", path, diffs)
+			continue
+		}
+		if d.A != want.A || d.B != want.B {
+			t.Errorf("%s: got %v, want %v != %v", path, d, want.A, want.B)
+		}
+	}
+	for _, path := range []string{"layers[0].digest", "layers[1].digest"} {
+		if d, ok := got[path]; ok {
+			t.Errorf("unexpected difference %v", d)
+		}
+	}
+
+	if err := Images(a, b); !errors.As(err, new(*Error)) {
+		t.Errorf("Images() = %v, want *Error", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Indexes compares the given indexes to each other and returns an *Error if
+// they differ.
+func Indexes(a, b v1.ImageIndex) error {
+	diffs, err := IndexDifferences(a, b)
+	return asError("Indexes", diffs, err)
+}
+
+// IndexDifferences returns the differences between the given indexes: their
+// digests, media types and sizes, the fields of their manifests, and their
+// manifests' descriptors.  Where both indexes hold a different image or index
+// at the same position, those are compared too, by ImageDifferences or
+// IndexDifferences.
+func IndexDifferences(a, b v1.ImageIndex) ([]Difference, error) {
+	return indexDifferences(nil, "", a, b, false)
+}
+
+// indexDifferences appends the differences between a and b to diffs.  If
+// nested, a and b are children of an index whose descriptors have already
+// been compared, so their digests, media types and sizes are not.
+func indexDifferences(diffs []Difference, path string, a, b v1.ImageIndex, nested bool) ([]Difference, error) {
+	digests := []v1.Hash{}
+	manifests := []*v1.IndexManifest{}
+	rawManifests := [][]byte{}
+	sizes := []int64{}
+	mts := []types.MediaType{}
+
+	for _, idx := range []v1.ImageIndex{a, b} {
+		digest, err := idx.Digest()
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+
+		manifest, err := idx.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+
+		rawManifest, err := idx.RawManifest()
+		if err != nil {
+			return nil, err
+		}
+		rawManifests = append(rawManifests, rawManifest)
+
+		size, err := idx.Size()
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, size)
+
+		mt, err := idx.MediaType()
+		if err != nil {
+			return nil, err
+		}
+		mts = append(mts, mt)
+	}
+
+	if !nested {
+		diffs = compareValue(diffs, fieldPath(path, "digest"), digests[0], digests[1])
+		diffs = compareValue(diffs, fieldPath(path, "mediaType"), mts[0], mts[1])
+		diffs = compareValue(diffs, fieldPath(path, "size"), sizes[0], sizes[1])
+	}
+
+	// Descriptors are compared below, one by one.
+	diffs, err := compareJSON(diffs, fieldPath(path, "manifest"), rawManifests[0], rawManifests[1], "mediaType", "manifests")
+	if err != nil {
+		return nil, err
+	}
+
+	am, bm := manifests[0].Manifests, manifests[1].Manifests
+	for i := 0; i < len(am) || i < len(bm); i++ {
+		mp := fmt.Sprintf("%s[%d]", fieldPath(path, "manifests"), i)
+		if i >= len(am) || i >= len(bm) {
+			// Only one index has this child; report its digest.
+			var ds [2]interface{}
+			if i < len(am) {
+				ds[0] = am[i].Digest
+			}
+			if i < len(bm) {
+				ds[1] = bm[i].Digest
+			}
+			diffs = compareValue(diffs, mp, ds[0], ds[1])
+			continue
+		}
+
+		ad, err := json.Marshal(am[i])
+		if err != nil {
+			return nil, err
+		}
+		bd, err := json.Marshal(bm[i])
+		if err != nil {
+			return nil, err
+		}
+		if diffs, err = compareJSON(diffs, mp, ad, bd); err != nil {
+			return nil, err
+		}
+
+		if am[i].Digest == bm[i].Digest || am[i].MediaType != bm[i].MediaType {
+			continue
+		}
+		switch {
+		case am[i].MediaType.IsImage():
+			ai, err := a.Image(am[i].Digest)
+			if err != nil {
+				return nil, err
+			}
+			bi, err := b.Image(bm[i].Digest)
+			if err != nil {
+				return nil, err
+			}
+			if diffs, err = imageDifferences(diffs, mp, ai, bi, true); err != nil {
+				return nil, err
+			}
+		case am[i].MediaType.IsIndex():
+			ai, err := a.ImageIndex(am[i].Digest)
+			if err != nil {
+				return nil, err
+			}
+			bi, err := b.ImageIndex(bm[i].Digest)
+			if err != nil {
+				return nil, err
+			}
+			if diffs, err = indexDifferences(diffs, mp, ai, bi, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return diffs, nil
+}
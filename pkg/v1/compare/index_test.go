@@ -17,6 +17,8 @@ package compare
 import (
 	"testing"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -49,3 +51,39 @@ func TestEqualIndexes(t *testing.T) {
 		t.Errorf("got err: %v", err)
 	}
 }
+
+func TestIndexDifferences(t *testing.T) {
+	a, err := random.Index(100, 1, 2, random.WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := a.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := a.Image(m.Manifests[1].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.Annotations(img, map[string]string{"foo": "bar"}).(v1.Image)
+	b := mutate.AppendManifests(mutate.RemoveManifests(a, match.Digests(m.Manifests[1].Digest)), mutate.IndexAddendum{
+		Add: img,
+	})
+
+	diffs, err := IndexDifferences(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, d := range diffs {
+		if d.Path == "manifests[0].digest" {
+			t.Errorf("unexpected difference %v", d)
+		}
+		if d.Path == "manifests[1].manifest.annotations.foo" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("missing difference at manifests[1].manifest.annotations.foo; got %v", diffs)
+	}
+}
@@ -15,66 +15,60 @@
 package compare
 
 import (
-	"errors"
-	"fmt"
-	"strings"
-
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
-// Layers compares the given layers to each other and returns an error if they
-// differ.  Note that this does not compare the actual contents (by calling
+// Layers compares the given layers to each other and returns an *Error if
+// they differ.  Note that this does not compare the actual contents (by calling
 // Compressed or Uncompressed).
 func Layers(a, b v1.Layer) error {
+	diffs, err := LayerDifferences(a, b)
+	return asError("Layers", diffs, err)
+}
+
+// LayerDifferences returns the differences between the digests, diffids,
+// sizes and media types of the given layers.
+func LayerDifferences(a, b v1.Layer) ([]Difference, error) {
+	return layerDifferences(nil, "", a, b)
+}
+
+func layerDifferences(diffs []Difference, path string, a, b v1.Layer) ([]Difference, error) {
 	digests := []v1.Hash{}
 	diffids := []v1.Hash{}
 	sizes := []int64{}
 	mts := []types.MediaType{}
-	errs := []string{}
 
 	for _, layer := range []v1.Layer{a, b} {
 		digest, err := layer.Digest()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		digests = append(digests, digest)
 
 		diffid, err := layer.DiffID()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		diffids = append(diffids, diffid)
 
 		size, err := layer.Size()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		sizes = append(sizes, size)
 
 		mt, err := layer.MediaType()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		mts = append(mts, mt)
 	}
 
-	if want, got := digests[0], digests[1]; want != got {
-		errs = append(errs, fmt.Sprintf("a.Digest() != b.Digest(); %s != %s", want, got))
-	}
-	if want, got := diffids[0], diffids[1]; want != got {
-		errs = append(errs, fmt.Sprintf("a.DiffID() != b.DiffID(); %s != %s", want, got))
-	}
-	if want, got := sizes[0], sizes[1]; want != got {
-		errs = append(errs, fmt.Sprintf("a.Size() != b.Size(); %d != %d", want, got))
-	}
-	if want, got := mts[0], mts[1]; want != got {
-		errs = append(errs, fmt.Sprintf("a.MediaType() != b.MediaType(); %s != %s", want, got))
-	}
-
-	if len(errs) != 0 {
-		return errors.New("Layers differ:\n" + strings.Join(errs, "\n"))
-	}
+	diffs = compareValue(diffs, fieldPath(path, "digest"), digests[0], digests[1])
+	diffs = compareValue(diffs, fieldPath(path, "diffID"), diffids[0], diffids[1])
+	diffs = compareValue(diffs, fieldPath(path, "size"), sizes[0], sizes[1])
+	diffs = compareValue(diffs, fieldPath(path, "mediaType"), mts[0], mts[1])
 
-	return nil
+	return diffs, nil
 }
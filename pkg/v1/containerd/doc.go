@@ -0,0 +1,25 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package containerd reads and writes v1.Image and v1.ImageIndex through the
+// containerd API, for hosts (e.g. k3s or Kubernetes nodes) that run
+// containerd without a Docker daemon.
+//
+// Images are named the way containerd names them, within the namespace set
+// by WithNamespace; the kubelet uses "k8s.io". Reads resolve names with
+// containerd's image service and read blobs from its content store. Writes
+// store blobs in the content store under a lease, label them so that
+// containerd's garbage collector keeps everything the image refers to, and
+// then create or update the image's name.
+package containerd
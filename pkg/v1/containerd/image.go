@@ -0,0 +1,219 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Image returns the image that ref refers to in containerd. If ref refers to
+// an index, the image for the platform set by WithPlatform is returned.
+//
+// Tags and digests are looked up in containerd's image store, e.g.
+// "docker.io/library/alpine:latest" as listed by `ctr images ls`. A digest
+// that isn't the target of any image is read straight from the content
+// store.
+//
+// The returned image reads from containerd lazily. Unless WithClient is used,
+// the connection is closed when the context set by WithContext is done.
+func Image(ref name.Reference, options ...Option) (v1.Image, error) {
+	o := makeOptions(options...)
+	s, err := o.open()
+	if err != nil {
+		return nil, err
+	}
+	desc, err := s.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	return s.image(desc)
+}
+
+// Index returns the index that ref refers to in containerd. Children that
+// containerd didn't pull, usually those for other platforms, can't be read.
+func Index(ref name.Reference, options ...Option) (v1.ImageIndex, error) {
+	o := makeOptions(options...)
+	s, err := o.open()
+	if err != nil {
+		return nil, err
+	}
+	desc, err := s.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !desc.MediaType.IsIndex() {
+		return nil, fmt.Errorf("%s is a %s, not an index", ref, desc.MediaType)
+	}
+	return partial.IndexFromFuncs(func() ([]byte, error) { return s.read(desc.Digest) }, s.blob)
+}
+
+// store reads from containerd's image and content stores.
+type store struct {
+	o   *options
+	ctx context.Context
+	cs  content.Store
+	is  images.Store
+}
+
+// open connects to containerd. Reads are lazy, so unless the client is the
+// caller's, it's closed when the context is done.
+func (o *options) open() (*store, error) {
+	client, ctx, closer, err := o.connect()
+	if err != nil {
+		return nil, err
+	}
+	if done := ctx.Done(); o.client == nil && done != nil {
+		go func() {
+			<-done
+			closer()
+		}()
+	}
+	return &store{o: o, ctx: ctx, cs: client.ContentStore(), is: client.ImageService()}, nil
+}
+
+// resolve returns the descriptor that ref refers to.
+func (s *store) resolve(ref name.Reference) (v1.Descriptor, error) {
+	img, err := s.is.Get(s.ctx, imageName(ref))
+	if err == nil {
+		return fromSpec(img.Target)
+	}
+	d, ok := ref.(name.Digest)
+	if !ok || !errdefs.IsNotFound(err) {
+		return v1.Descriptor{}, fmt.Errorf("looking up %s: %w", imageName(ref), err)
+	}
+
+	// Digests might not be named, but they're still in the content store.
+	h, err := v1.NewHash(d.DigestStr())
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	raw, err := s.read(h)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	var m struct {
+		MediaType types.MediaType `json:"mediaType"`
+		Manifests json.RawMessage `json:"manifests"`
+	}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return v1.Descriptor{}, fmt.Errorf("%s is not a manifest: %w", h, err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = types.OCIManifestSchema1
+		if m.Manifests != nil {
+			m.MediaType = types.OCIImageIndex
+		}
+	}
+	return v1.Descriptor{MediaType: m.MediaType, Digest: h, Size: int64(len(raw))}, nil
+}
+
+// image returns the image desc refers to, picking a child if it's an index.
+func (s *store) image(desc v1.Descriptor) (v1.Image, error) {
+	if !desc.MediaType.IsIndex() {
+		return partial.ImageFromFuncs(func() ([]byte, error) { return s.read(desc.Digest) }, s.blob)
+	}
+
+	raw, err := s.read(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	im, err := v1.ParseIndexManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range im.Manifests {
+		// If platform is missing from child descriptor, assume it's amd64/linux.
+		p := v1.Platform{OS: "linux", Architecture: "amd64"}
+		if child.Platform != nil {
+			p = *child.Platform
+		}
+		if !p.Satisfies(s.o.platform) {
+			continue
+		}
+		// containerd only pulls the manifests for its own platform, so
+		// skip children it doesn't have.
+		if _, err := s.cs.Info(s.ctx, digest.Digest(child.Digest.String())); err != nil {
+			continue
+		}
+		return s.image(child)
+	}
+	return nil, fmt.Errorf("no child with platform %s of index %s in containerd", s.o.platform.String(), desc.Digest)
+}
+
+// read returns the contents of the blob with digest h.
+func (s *store) read(h v1.Hash) ([]byte, error) {
+	rc, err := s.blob(h)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// blob opens the blob with digest h from the content store.
+func (s *store) blob(h v1.Hash) (io.ReadCloser, error) {
+	ra, err := s.cs.ReaderAt(s.ctx, specs.Descriptor{Digest: digest.Digest(h.String())})
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from containerd: %w", h, err)
+	}
+	return &readCloser{Reader: content.NewReader(ra), Closer: ra}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// imageName returns the name that containerd uses for ref, e.g.
+// "docker.io/library/alpine:latest" for "alpine".
+func imageName(ref name.Reference) string {
+	repo := ref.Context()
+	reg := repo.RegistryStr()
+	if reg == name.DefaultRegistry {
+		reg = "docker.io"
+	}
+	sep := ":"
+	if _, ok := ref.(name.Digest); ok {
+		sep = "@"
+	}
+	return reg + "/" + repo.RepositoryStr() + sep + ref.Identifier()
+}
+
+func fromSpec(d specs.Descriptor) (v1.Descriptor, error) {
+	h, err := v1.NewHash(d.Digest.String())
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	return v1.Descriptor{MediaType: types.MediaType(d.MediaType), Digest: h, Size: d.Size}, nil
+}
+
+func toSpec(d v1.Descriptor) specs.Descriptor {
+	return specs.Descriptor{MediaType: string(d.MediaType), Digest: digest.Digest(d.Digest.String()), Size: d.Size}
+}
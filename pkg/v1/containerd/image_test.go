@@ -0,0 +1,202 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content/local"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/metadata"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+	bolt "go.etcd.io/bbolt"
+)
+
+// newClient returns a containerd client backed by containerd's own content
+// and metadata stores in a temporary directory, rather than a daemon, and
+// a function that runs containerd's garbage collector on them.
+func newClient(t *testing.T) (*containerd.Client, func()) {
+	t.Helper()
+	dir := t.TempDir()
+	cs, err := local.NewStore(filepath.Join(dir, "content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bdb, err := bolt.Open(filepath.Join(dir, "meta.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bdb.Close() })
+	db := metadata.NewDB(bdb, cs, nil)
+	if err := db.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := containerd.New("", containerd.WithServices(
+		containerd.WithContentStore(db.ContentStore()),
+		containerd.WithImageStore(metadata.NewImageStore(db)),
+		containerd.WithLeasesService(metadata.NewLeaseManager(db)),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gc := func() {
+		t.Helper()
+		if _, err := db.GarbageCollect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client, gc
+}
+
+func TestWriteImage(t *testing.T) {
+	client, gc := newClient(t)
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := name.MustParseReference("example.com/test:latest").(name.Tag)
+	if err := Write(tag, img, WithClient(client)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	// Everything the image refers to must survive garbage collection.
+	gc()
+
+	for _, ref := range []name.Reference{
+		tag,
+		// Images aren't named by digest, so this is read from the content
+		// store directly.
+		tag.Context().Digest(want.String()),
+	} {
+		got, err := Image(ref, WithClient(client))
+		if err != nil {
+			t.Fatalf("Image(%s) = %v", ref, err)
+		}
+		if err := validate.Image(got); err != nil {
+			t.Errorf("validate.Image(%s) = %v", ref, err)
+		}
+		if d, err := got.Digest(); err != nil || d != want {
+			t.Errorf("Image(%s).Digest() = %v, %v, want %v", ref, d, err, want)
+		}
+	}
+
+	// Writing again replaces the image that the tag names.
+	other, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(tag, other, WithClient(client)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	got, err := Image(tag, WithClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ = other.Digest()
+	if d, _ := got.Digest(); d != want {
+		t.Errorf("Image() after rewrite = %v, want %v", d, want)
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	client, gc := newClient(t)
+	ii, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := name.MustParseReference("example.com/test:index").(name.Tag)
+	if err := WriteIndex(tag, ii, WithClient(client)); err != nil {
+		t.Fatalf("WriteIndex() = %v", err)
+	}
+	gc()
+
+	got, err := Index(tag, WithClient(client))
+	if err != nil {
+		t.Fatalf("Index() = %v", err)
+	}
+	if err := validate.Index(got); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+
+	// The children of random indexes have no platform, so they're assumed
+	// to be linux/amd64, and the first one is picked.
+	img, err := Image(tag, WithClient(client), WithPlatform(v1.Platform{OS: "linux", Architecture: "amd64"}))
+	if err != nil {
+		t.Fatalf("Image() = %v", err)
+	}
+	im, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := img.Digest(); err != nil || d != im.Manifests[0].Digest {
+		t.Errorf("Image().Digest() = %v, %v, want %v", d, err, im.Manifests[0].Digest)
+	}
+
+	if _, err := Image(tag, WithClient(client), WithPlatform(v1.Platform{OS: "linux", Architecture: "s390x"})); err == nil {
+		t.Error("Image() for a missing platform succeeded")
+	}
+	if _, err := Index(name.MustParseReference("example.com/test:missing"), WithClient(client)); !errdefs.IsNotFound(err) {
+		t.Errorf("Index(missing) = %v, want not found", err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	client, _ := newClient(t)
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := name.MustParseReference("example.com/test:latest").(name.Tag)
+	if err := Write(tag, img, WithClient(client), WithNamespace("k8s.io")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.ImageService().Get(namespaces.WithNamespace(context.Background(), "k8s.io"), "example.com/test:latest"); err != nil {
+		t.Errorf("image not in the k8s.io namespace: %v", err)
+	}
+	if _, err := Image(tag, WithClient(client)); !errdefs.IsNotFound(err) {
+		t.Errorf("Image() in the default namespace = %v, want not found", err)
+	}
+}
+
+func TestImageName(t *testing.T) {
+	for in, want := range map[string]string{
+		"alpine":                           "docker.io/library/alpine:latest",
+		"gcr.io/foo/bar:v1":                "gcr.io/foo/bar:v1",
+		"localhost:5000/foo@sha256:" + hex: "localhost:5000/foo@sha256:" + hex,
+	} {
+		ref, err := name.ParseReference(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := imageName(ref); got != want {
+			t.Errorf("imageName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+const hex = "deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef"
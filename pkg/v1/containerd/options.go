@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/namespaces"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	// DefaultAddress is where containerd listens by default.
+	DefaultAddress = "/run/containerd/containerd.sock"
+
+	// DefaultNamespace is the namespace that ctr uses by default. Kubernetes
+	// keeps its images in the "k8s.io" namespace.
+	DefaultNamespace = namespaces.Default
+)
+
+// Option is a functional option for reading from and writing to containerd.
+type Option func(*options)
+
+type options struct {
+	address   string
+	namespace string
+	client    *containerd.Client
+	ctx       context.Context
+	platform  v1.Platform
+	unpack    string
+}
+
+func makeOptions(opts ...Option) *options {
+	o := &options{
+		address:   DefaultAddress,
+		namespace: DefaultNamespace,
+		ctx:       context.Background(),
+		// containerd hosts are almost always linux, and only pull content
+		// for their own architecture.
+		platform: v1.Platform{OS: "linux", Architecture: runtime.GOARCH},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// connect returns the client to use and a context in the namespace to use,
+// and a function to close the client, if it was opened here.
+func (o *options) connect() (*containerd.Client, context.Context, func() error, error) {
+	ctx := namespaces.WithNamespace(o.ctx, o.namespace)
+	if o.client != nil {
+		return o.client, ctx, func() error { return nil }, nil
+	}
+	client, err := containerd.New(o.address)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return client, ctx, client.Close, nil
+}
+
+// WithAddress sets the address of containerd's socket, e.g.
+// /run/k3s/containerd/containerd.sock on k3s. It defaults to
+// DefaultAddress.
+func WithAddress(address string) Option {
+	return func(o *options) {
+		o.address = address
+	}
+}
+
+// WithNamespace sets the containerd namespace that images are read from and
+// written to, e.g. "k8s.io" for images that the kubelet can use. It
+// defaults to DefaultNamespace.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithClient uses an existing containerd client, rather than connecting to
+// the address set by WithAddress. The client isn't closed.
+func WithClient(client *containerd.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithContext is a functional option to pass through a context.Context.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithPlatform sets the platform that Image picks when the reference refers
+// to an index. It defaults to linux on the host's architecture.
+func WithPlatform(p v1.Platform) Option {
+	return func(o *options) {
+		o.platform = p
+	}
+}
+
+// WithUnpack unpacks images into the named snapshotter (e.g. "overlayfs")
+// after Write stores them, so that containers can be created from them right
+// away. By default, images are only stored.
+func WithUnpack(snapshotter string) Option {
+	return func(o *options) {
+		o.unpack = snapshotter
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+)
+
+// Write stores img in containerd, and names it tag, replacing the image
+// that tag named, if any.
+//
+// Blobs are written to containerd's content store under a lease, and
+// labeled so that containerd's garbage collector keeps them for as long as
+// the image is named. Blobs that containerd already has aren't written.
+func Write(tag name.Tag, img v1.Image, options ...Option) error {
+	return write(tag, func(w *writer) (v1.Descriptor, error) {
+		return w.writeImage(img)
+	}, options...)
+}
+
+// WriteIndex stores ii, and every image it refers to, in containerd, and
+// names it tag, like Write.
+func WriteIndex(tag name.Tag, ii v1.ImageIndex, options ...Option) error {
+	return write(tag, func(w *writer) (v1.Descriptor, error) {
+		return w.writeIndex(ii)
+	}, options...)
+}
+
+func write(tag name.Tag, f func(*writer) (v1.Descriptor, error), options ...Option) error {
+	o := makeOptions(options...)
+	client, ctx, closer, err := o.connect()
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	// Until the image is created, only the lease keeps the garbage collector
+	// from deleting what's been written.
+	ctx, done, err := client.WithLease(ctx)
+	if err != nil {
+		return err
+	}
+	defer done(ctx)
+
+	w := &writer{ctx: ctx, cs: client.ContentStore()}
+	desc, err := f(w)
+	if err != nil {
+		return err
+	}
+
+	img := images.Image{Name: imageName(tag), Target: toSpec(desc)}
+	is := client.ImageService()
+	if _, err := is.Create(ctx, img); errdefs.IsAlreadyExists(err) {
+		if _, err := is.Update(ctx, img, "target"); err != nil {
+			return fmt.Errorf("updating %s: %w", img.Name, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("creating %s: %w", img.Name, err)
+	}
+
+	if o.unpack != "" {
+		if err := containerd.NewImage(client, img).Unpack(ctx, o.unpack); err != nil {
+			return fmt.Errorf("unpacking %s: %w", img.Name, err)
+		}
+	}
+	return nil
+}
+
+// writer writes blobs to containerd's content store.
+type writer struct {
+	ctx context.Context
+	cs  content.Store
+}
+
+// writeIndex writes ii's children, and then ii, returning its descriptor.
+func (w *writer) writeIndex(ii v1.ImageIndex) (v1.Descriptor, error) {
+	im, err := ii.IndexManifest()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	labels := map[string]string{}
+	for i, desc := range im.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := ii.ImageIndex(desc.Digest)
+			if err != nil {
+				return v1.Descriptor{}, err
+			}
+			if _, err := w.writeIndex(child); err != nil {
+				return v1.Descriptor{}, err
+			}
+		case desc.MediaType.IsImage():
+			child, err := ii.Image(desc.Digest)
+			if err != nil {
+				return v1.Descriptor{}, err
+			}
+			if _, err := w.writeImage(child); err != nil {
+				return v1.Descriptor{}, err
+			}
+		default:
+			// Other artifacts aren't written, so the index mustn't keep them.
+			continue
+		}
+		gcLabel(labels, desc, i)
+	}
+	return w.writeManifest(ii, labels)
+}
+
+// writeImage writes img's layers, config and manifest, returning the
+// manifest's descriptor.
+func (w *writer) writeImage(img v1.Image) (v1.Descriptor, error) {
+	labels := map[string]string{}
+	layers, err := img.Layers()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	for i, l := range layers {
+		desc, err := partial.Descriptor(l)
+		if err != nil {
+			return v1.Descriptor{}, err
+		}
+		if err := w.writeBlob(*desc, l.Compressed, nil); err != nil {
+			return v1.Descriptor{}, err
+		}
+		gcLabel(labels, *desc, i)
+	}
+
+	m, err := img.Manifest()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	raw, err := img.RawConfigFile()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	if err := w.writeBlob(m.Config, bytesOpener(raw), nil); err != nil {
+		return v1.Descriptor{}, err
+	}
+	gcLabel(labels, m.Config, 0)
+
+	return w.writeManifest(img, labels)
+}
+
+// manifest is the part of v1.Image and v1.ImageIndex that writeManifest
+// needs.
+type manifest interface {
+	RawManifest() ([]byte, error)
+	partial.Describable
+}
+
+func (w *writer) writeManifest(m manifest, labels map[string]string) (v1.Descriptor, error) {
+	raw, err := m.RawManifest()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	desc, err := partial.Descriptor(m)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	// Images and indexes can carry the platform they were read with, which
+	// containerd's image records don't.
+	desc.Platform = nil
+	return *desc, w.writeBlob(*desc, bytesOpener(raw), labels)
+}
+
+// writeBlob writes the blob desc describes, with the given labels, unless
+// containerd already has it.
+func (w *writer) writeBlob(desc v1.Descriptor, open func() (io.ReadCloser, error), labels map[string]string) error {
+	if _, err := w.cs.Info(w.ctx, toSpec(desc).Digest); err == nil {
+		return nil
+	} else if !errdefs.IsNotFound(err) {
+		return err
+	}
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := content.WriteBlob(w.ctx, w.cs, "ggcr-"+desc.Digest.String(), rc, toSpec(desc), content.WithLabels(labels)); err != nil {
+		return fmt.Errorf("writing %s to containerd: %w", desc.Digest, err)
+	}
+	return nil
+}
+
+// gcLabel adds the label that tells containerd's garbage collector that the
+// blob being labeled refers to desc, the i'th child of its kind.
+func gcLabel(labels map[string]string, desc v1.Descriptor, i int) {
+	for _, key := range images.ChildGCLabels(toSpec(desc)) {
+		if strings.HasSuffix(key, ".") {
+			key = fmt.Sprintf("%s%d", key, i)
+		}
+		labels[key] = desc.Digest.String()
+	}
+}
+
+func bytesOpener(b []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"runtime"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// BuildkitIndex returns the contents of an OCI image layout tarball, as
+// written by `docker buildx build --output type=oci` or
+// `buildctl build --output type=oci`, as an index.
+//
+// Blobs are read from the tarball as they're used, so the opener is called
+// once per blob.
+func BuildkitIndex(opener tarball.Opener) (v1.ImageIndex, error) {
+	rc, err := tarFile(opener, "index.json")
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	raw, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	blob := func(h v1.Hash) (io.ReadCloser, error) {
+		return tarFile(opener, path.Join("blobs", h.Algorithm, h.Hex))
+	}
+	return partial.IndexFromFuncs(func() ([]byte, error) { return raw, nil }, blob)
+}
+
+// WriteBuildkit loads the image in an OCI image layout tarball, as written by
+// buildkit, into the daemon with the given tag, without an intermediate
+// `docker load` of the tarball itself.
+//
+// If the tarball has images for several platforms, the one for WithPlatform,
+// or linux on the current architecture by default, is loaded.
+func WriteBuildkit(tag name.Tag, opener tarball.Opener, options ...Option) (string, error) {
+	idx, err := BuildkitIndex(opener)
+	if err != nil {
+		return "", err
+	}
+	o, err := makeOptions(options...)
+	if err != nil {
+		return "", err
+	}
+	p := v1.Platform{OS: "linux", Architecture: runtime.GOARCH}
+	if o.platform != nil {
+		p = *o.platform
+	}
+	img, err := findImage(idx, p)
+	if err != nil {
+		return "", err
+	}
+	return Write(tag, img, append(options, WithClient(o.client))...)
+}
+
+var errNoImage = errors.New("no image found")
+
+// findImage returns the first image in idx, or its child indexes, that's for
+// the platform. Images without a platform in their descriptor match any
+// platform, as buildkit omits it for single-platform builds.
+func findImage(idx v1.ImageIndex, p v1.Platform) (v1.Image, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range im.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			img, err := findImage(child, p)
+			if errors.Is(err, errNoImage) {
+				continue
+			}
+			return img, err
+		case desc.MediaType.IsImage():
+			if desc.Platform == nil || desc.Platform.Satisfies(p) {
+				return idx.Image(desc.Digest)
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w for platform %s", errNoImage, p.String())
+}
+
+// tarFile opens the named file in the tarball, which is closed when the
+// returned reader is.
+func tarFile(opener tarball.Opener, name string) (io.ReadCloser, error) {
+	rc, err := opener()
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			rc.Close()
+			return nil, fmt.Errorf("file %s not found in tar", name)
+		} else if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		if path.Clean(hdr.Name) == name {
+			return struct {
+				io.Reader
+				io.Closer
+			}{tr, rc}, nil
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// loadClient keeps the last tarball it loaded.
+type loadClient struct {
+	*MockClient
+	loaded []byte
+}
+
+func (c *loadClient) ImageLoad(_ context.Context, r io.Reader, _ bool) (types.ImageLoadResponse, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return types.ImageLoadResponse{}, err
+	}
+	c.loaded = b
+	return types.ImageLoadResponse{Body: ioutil.NopCloser(strings.NewReader("Loaded"))}, nil
+}
+
+// buildkitTarball writes a multi-platform OCI layout tarball like buildkit's,
+// returning the images in it.
+func buildkitTarball(t *testing.T) (tarball.Opener, map[string]v1.Image) {
+	t.Helper()
+	images := map[string]v1.Image{}
+	var adds []mutate.IndexAddendum
+	for _, arch := range []string{"amd64", "arm64"} {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf.OS, cf.Architecture = "linux", arch
+		if img, err = mutate.ConfigFile(img, cf); err != nil {
+			t.Fatal(err)
+		}
+		images[arch] = img
+		adds = append(adds, mutate.IndexAddendum{
+			Add: img,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: arch},
+			},
+		})
+	}
+
+	dir := t.TempDir()
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendIndex(mutate.AppendManifests(empty.Index, adds...)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name: filepath.ToSlash(rel),
+			Mode: 0644,
+			Size: int64(len(b)),
+		}); err != nil {
+			return err
+		}
+		_, err = tw.Write(b)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}, images
+}
+
+func TestBuildkitIndex(t *testing.T) {
+	opener, images := buildkitTarball(t)
+	idx, err := BuildkitIndex(opener)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := findImage(idx, v1.Platform{OS: "linux", Architecture: "arm64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := images["arm64"].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Digest = %s, want %s", got, want)
+	}
+
+	// The layers are read from the tarball, too.
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := layers[0].Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+		t.Errorf("reading layer: %v", err)
+	}
+
+	if _, err := findImage(idx, v1.Platform{OS: "windows", Architecture: "amd64"}); err == nil {
+		t.Error("expected error for missing platform")
+	}
+}
+
+func TestWriteBuildkit(t *testing.T) {
+	opener, images := buildkitTarball(t)
+	client := &loadClient{MockClient: &MockClient{}}
+	tag, err := name.NewTag("test:buildkit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arm64 := v1.Platform{OS: "linux", Architecture: "arm64"}
+
+	if _, err := WriteBuildkit(tag, opener, WithClient(client), WithPlatform(arm64)); err != nil {
+		t.Fatalf("WriteBuildkit: %v", err)
+	}
+	loaded, err := tarball.Image(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(client.loaded)), nil
+	}, &tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := images["arm64"].ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("loaded config %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// WithContainerdRoot reads images from the containerd content store in root,
+// e.g. /var/lib/containerd, when the daemon uses the containerd image store.
+// By default, images are always read with `docker save`.
+//
+// When the daemon uses the containerd image store, image IDs are the digests
+// of their manifests, and Image reads manifests and layers straight from the
+// content store rather than going through `docker save`. This usually
+// requires root, and Image falls back to `docker save` if it can't, or if any
+// of the image's blobs are missing from the content store, e.g. because
+// containerd discards layers once they're unpacked.
+func WithContainerdRoot(root string) Option {
+	return func(o *options) {
+		o.containerdRoot = root
+	}
+}
+
+// containerdContentDir is where containerd's content plugin keeps its blobs,
+// relative to its root directory.
+const containerdContentDir = "io.containerd.content.v1.content"
+
+// containerdImage reads the image with the given ID from containerd's content
+// store. It fails if the ID isn't the digest of a manifest or index there, or
+// if any of the image's blobs are missing.
+func containerdImage(id v1.Hash, o *options) (v1.Image, error) {
+	img, err := contentStoreImage(id, o)
+	if err != nil {
+		return nil, err
+	}
+	// Make sure the config and layers are there, too, since the image is
+	// lazy, so that we can still fall back to `docker save`.
+	if _, err := img.RawConfigFile(); err != nil {
+		return nil, err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range layers {
+		rc, err := l.Compressed()
+		if err != nil {
+			return nil, err
+		}
+		rc.Close()
+	}
+	return img, nil
+}
+
+// contentStoreImage returns the image whose manifest has the given digest,
+// picking the child for the requested platform if it's an index.
+func contentStoreImage(h v1.Hash, o *options) (v1.Image, error) {
+	raw, err := contentStoreManifest(h, o.containerdRoot)
+	if err != nil {
+		return nil, err
+	}
+	var m struct {
+		MediaType types.MediaType `json:"mediaType"`
+		Config    json.RawMessage `json:"config"`
+		Manifests json.RawMessage `json:"manifests"`
+	}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	blob := func(h v1.Hash) (io.ReadCloser, error) {
+		return os.Open(contentStorePath(o.containerdRoot, h))
+	}
+	if !m.MediaType.IsIndex() && m.Manifests == nil {
+		// Other blobs, like config files, are JSON too.
+		if m.Config == nil {
+			return nil, fmt.Errorf("%s is not an image manifest", h)
+		}
+		return partial.ImageFromFuncs(func() ([]byte, error) { return raw, nil }, blob)
+	}
+
+	im, err := v1.ParseIndexManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	// containerd hosts are almost always linux, and only pull content for
+	// their own architecture.
+	want := v1.Platform{OS: "linux", Architecture: runtime.GOARCH}
+	if o.platform != nil {
+		want = *o.platform
+	}
+	for _, desc := range im.Manifests {
+		// If platform is missing from child descriptor, assume it's amd64/linux.
+		p := v1.Platform{OS: "linux", Architecture: "amd64"}
+		if desc.Platform != nil {
+			p = *desc.Platform
+		}
+		if !p.Satisfies(want) {
+			continue
+		}
+		// containerd only pulls the manifests for its own platform, so
+		// skip children it doesn't have.
+		if _, err := os.Stat(contentStorePath(o.containerdRoot, desc.Digest)); err != nil {
+			continue
+		}
+		return contentStoreImage(desc.Digest, o)
+	}
+	return nil, fmt.Errorf("no child with platform %s of index %s in %s", want.String(), h, o.containerdRoot)
+}
+
+func contentStorePath(root string, h v1.Hash) string {
+	return filepath.Join(root, containerdContentDir, "blobs", h.Algorithm, h.Hex)
+}
+
+// contentStoreManifest reads and verifies the manifest with the given digest.
+func contentStoreManifest(h v1.Hash, root string) ([]byte, error) {
+	b, err := ioutil.ReadFile(contentStorePath(root, h))
+	if err != nil {
+		return nil, err
+	}
+	got, _, err := v1.SHA256(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if got != h {
+		return nil, fmt.Errorf("content store blob %s has digest %s", h, got)
+	}
+	return b, nil
+}
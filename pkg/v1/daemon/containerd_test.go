@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+// containerdClient is a daemon using the containerd image store, so image IDs
+// are manifest digests and saving is slow.
+type containerdClient struct {
+	*MockClient
+	id v1.Hash
+}
+
+func (c *containerdClient) ImageInspectWithRaw(context.Context, string) (types.ImageInspect, []byte, error) {
+	return types.ImageInspect{ID: c.id.String()}, nil, nil
+}
+
+func (c *containerdClient) ImageSave(context.Context, []string) (io.ReadCloser, error) {
+	return nil, errors.New("should read from the content store")
+}
+
+func writeBlob(t *testing.T, root string, h v1.Hash, rc io.ReadCloser) {
+	t.Helper()
+	defer rc.Close()
+	p := filepath.Join(root, "io.containerd.content.v1.content", "blobs", h.Algorithm, h.Hex)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImageFromContainerd(t *testing.T) {
+	root := t.TempDir()
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeBlob(t, root, digest, ioutil.NopCloser(bytes.NewReader(raw)))
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgName, err := img.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeBlob(t, root, cfgName, ioutil.NopCloser(bytes.NewReader(cfg)))
+	ls, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range ls {
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeBlob(t, root, h, rc)
+	}
+
+	ref := name.MustParseReference("test:latest")
+	client := &containerdClient{MockClient: &MockClient{}, id: digest}
+	got, err := Image(ref, WithClient(client), WithContainerdRoot(root))
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if d, err := got.Digest(); err != nil || d != digest {
+		t.Errorf("Digest() = %s, %v; want %s", d, err, digest)
+	}
+	gotLayers, err := got.Layers()
+	if err != nil {
+		t.Fatalf("Layers: %v", err)
+	}
+	for _, l := range gotLayers {
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatalf("Compressed: %v", err)
+		}
+		if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+			t.Errorf("reading layer: %v", err)
+		}
+		rc.Close()
+	}
+
+	// Without the content store, we fall back to saving the image.
+	got, err = Image(ref, WithClient(client))
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if _, err := got.RawManifest(); err == nil {
+		t.Error("RawManifest() = nil, want save error")
+	}
+
+	// Likewise if a layer has been discarded.
+	h, err := ls[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "io.containerd.content.v1.content", "blobs", h.Algorithm, h.Hex)); err != nil {
+		t.Fatal(err)
+	}
+	got, err = Image(ref, WithClient(client), WithContainerdRoot(root))
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if _, err := got.RawManifest(); err == nil {
+		t.Error("RawManifest() = nil, want save error")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/docker/client"
+)
+
+// defaultContext is the docker CLI context that uses DOCKER_HOST or the
+// platform's default socket.
+const defaultContext = "default"
+
+// WithDockerContext connects to the daemon of the named docker CLI context,
+// as `docker --context` does. This takes precedence over DOCKER_HOST.
+//
+// Without this option, the context is chosen as the docker CLI does: unless
+// DOCKER_HOST is set, DOCKER_CONTEXT or the current context in the docker
+// config file is used.
+func WithDockerContext(name string) Option {
+	return func(o *options) {
+		o.dockerContext = name
+	}
+}
+
+// endpoint is the docker endpoint of a docker CLI context.
+type endpoint struct {
+	Host          string
+	SkipTLSVerify bool
+
+	// tlsDir holds the context's ca.pem, cert.pem and key.pem, if any.
+	tlsDir string
+}
+
+// contextMeta is the metadata the docker CLI stores for each context.
+type contextMeta struct {
+	Name      string
+	Endpoints map[string]endpoint
+}
+
+// configDir returns the docker CLI's config directory. config.Dir only reads
+// DOCKER_CONFIG once.
+func configDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	return config.Dir()
+}
+
+// currentContext returns the name of the docker CLI context in use.
+func currentContext() (string, error) {
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name, nil
+	}
+	cfg, err := config.Load(configDir())
+	if err != nil {
+		return "", err
+	}
+	if cfg.CurrentContext != "" {
+		return cfg.CurrentContext, nil
+	}
+	return defaultContext, nil
+}
+
+// loadEndpoint reads the docker endpoint of the named context. The docker CLI
+// stores contexts in directories named by the digest of their name.
+func loadEndpoint(name string) (*endpoint, error) {
+	d := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(d[:])
+	dir := filepath.Join(configDir(), "contexts")
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "meta", id, "meta.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("docker context %q not found", name)
+	} else if err != nil {
+		return nil, err
+	}
+	var meta contextMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return nil, fmt.Errorf("parsing docker context %q: %w", name, err)
+	}
+	ep, ok := meta.Endpoints["docker"]
+	if !ok || ep.Host == "" {
+		return nil, fmt.Errorf("docker context %q has no docker endpoint", name)
+	}
+	ep.tlsDir = filepath.Join(dir, "tls", id, "docker")
+	return &ep, nil
+}
+
+// tlsConfig returns the endpoint's TLS configuration, or nil if it has none.
+func (ep *endpoint) tlsConfig() (*tls.Config, error) {
+	read := func(name string) ([]byte, error) {
+		b, err := ioutil.ReadFile(filepath.Join(ep.tlsDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return b, err
+	}
+	ca, err := read("ca.pem")
+	if err != nil {
+		return nil, err
+	}
+	cert, err := read("cert.pem")
+	if err != nil {
+		return nil, err
+	}
+	key, err := read("key.pem")
+	if err != nil {
+		return nil, err
+	}
+	if ca == nil && cert == nil && !ep.SkipTLSVerify {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: ep.SkipTLSVerify, //nolint: gosec
+	}
+	if ca != nil {
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no certificates found in ca.pem")
+		}
+	}
+	if cert != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+// hostOpts returns the client options to connect to a DOCKER_HOST-style
+// address, including ssh:// addresses, which the docker client can't dial.
+func hostOpts(host string) ([]client.Opt, error) {
+	if strings.HasPrefix(host, "ssh://") {
+		return sshOpts(host)
+	}
+	return []client.Opt{client.WithHost(host)}, nil
+}
+
+// contextClient returns a client for the named docker CLI context.
+func contextClient(name string) (Client, error) {
+	if name == defaultContext {
+		return envClient()
+	}
+	ep, err := loadEndpoint(name)
+	if err != nil {
+		return nil, err
+	}
+	var opts []client.Opt
+	cfg, err := ep.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS config for docker context %q: %w", name, err)
+	}
+	if cfg != nil {
+		opts = append(opts,
+			client.WithHTTPClient(&http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}),
+			client.WithScheme("https"))
+	}
+	hopts, err := hostOpts(ep.Host)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewClientWithOpts(append(opts, hopts...)...)
+}
+
+// envClient returns a client configured from DOCKER_HOST and friends, falling
+// back to podman's libpod API if there's no Docker socket.
+func envClient() (Client, error) {
+	opts := []client.Opt{client.FromEnv}
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		hopts, err := hostOpts(host)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, hopts...)
+	} else if host, ok := detectPodman(); ok {
+		return newLibpodClient(strings.TrimPrefix(host, "unix://")), nil
+	}
+	return client.NewClientWithOpts(opts...)
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+// writeContext creates a docker CLI context in the config dir.
+func writeContext(t *testing.T, dir, name, host string) {
+	t.Helper()
+	d := sha256.Sum256([]byte(name))
+	meta := filepath.Join(dir, "contexts", "meta", hex.EncodeToString(d[:]))
+	if err := os.MkdirAll(meta, 0755); err != nil {
+		t.Fatal(err)
+	}
+	b := `{"Name":"` + name + `","Endpoints":{"docker":{"Host":"` + host + `"}}}`
+	if err := ioutil.WriteFile(filepath.Join(meta, "meta.json"), []byte(b), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func daemonHost(t *testing.T, c Client) string {
+	t.Helper()
+	dc, ok := c.(*client.Client)
+	if !ok {
+		t.Fatalf("client is %T, want *client.Client", c)
+	}
+	return dc.DaemonHost()
+}
+
+func TestDockerContext(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+	writeContext(t, dir, "current", "unix:///current.sock")
+	writeContext(t, dir, "other", "unix:///other.sock")
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"currentContext":"current"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		env     map[string]string
+		options []Option
+		want    string
+	}{{
+		name: "current context",
+		want: "unix:///current.sock",
+	}, {
+		name: "DOCKER_CONTEXT",
+		env:  map[string]string{"DOCKER_CONTEXT": "other"},
+		want: "unix:///other.sock",
+	}, {
+		name: "DOCKER_HOST wins over contexts",
+		env:  map[string]string{"DOCKER_HOST": "unix:///env.sock", "DOCKER_CONTEXT": "other"},
+		want: "unix:///env.sock",
+	}, {
+		name:    "WithDockerContext wins over DOCKER_HOST",
+		env:     map[string]string{"DOCKER_HOST": "unix:///env.sock"},
+		options: []Option{WithDockerContext("other")},
+		want:    "unix:///other.sock",
+	}, {
+		name:    "default context",
+		env:     map[string]string{"DOCKER_HOST": "unix:///env.sock"},
+		options: []Option{WithDockerContext("default")},
+		want:    "unix:///env.sock",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			o, err := makeOptions(tc.options...)
+			if err != nil {
+				t.Fatalf("makeOptions: %v", err)
+			}
+			if got := daemonHost(t, o.client); got != tc.want {
+				t.Errorf("host = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := makeOptions(WithDockerContext("missing")); err == nil {
+		t.Error("expected error for missing context")
+	}
+}
+
+func TestSSHArgs(t *testing.T) {
+	for _, tc := range []struct {
+		host    string
+		want    []string
+		wantErr bool
+	}{{
+		host: "ssh://example.com",
+		want: []string{"--", "example.com", "docker", "system", "dial-stdio"},
+	}, {
+		host: "ssh://me@example.com:2222",
+		want: []string{"-l", "me", "-p", "2222", "--", "example.com", "docker", "system", "dial-stdio"},
+	}, {
+		host:    "ssh://example.com/var/run/docker.sock",
+		wantErr: true,
+	}, {
+		host:    "ssh://",
+		wantErr: true,
+	}} {
+		t.Run(tc.host, func(t *testing.T) {
+			got, err := sshArgs(tc.host)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("sshArgs: %v, wantErr %t", err, tc.wantErr)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("sshArgs = %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("sshArgs = %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSSHContext(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	writeContext(t, dir, "remote", "ssh://me@example.com")
+
+	ep, err := loadEndpoint("remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := hostOpts(ep.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.DaemonHost(), "http://docker.example.com"; got != want {
+		t.Errorf("host = %q, want %q", got, want)
+	}
+}
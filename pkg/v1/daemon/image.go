@@ -38,8 +38,9 @@ type image struct {
 }
 
 type imageOpener struct {
-	ref name.Reference
-	ctx context.Context
+	ref      name.Reference
+	ctx      context.Context
+	progress func(Progress)
 
 	buffered bool
 	client   Client
@@ -50,7 +51,11 @@ type imageOpener struct {
 }
 
 func (i *imageOpener) saveImage() (io.ReadCloser, error) {
-	return i.client.ImageSave(i.ctx, []string{i.ref.Name()})
+	rc, err := i.client.ImageSave(i.ctx, []string{i.ref.Name()})
+	if err != nil || i.progress == nil {
+		return rc, err
+	}
+	return &progressReader{ReadCloser: rc, id: i.ref.Name(), fn: i.progress}, nil
 }
 
 func (i *imageOpener) bufferedOpener() (io.ReadCloser, error) {
@@ -88,12 +93,18 @@ func Image(ref name.Reference, options ...Option) (v1.Image, error) {
 	if err != nil {
 		return nil, err
 	}
+	if o.platform != nil {
+		if err := ensurePlatform(ref, o); err != nil {
+			return nil, err
+		}
+	}
 
 	i := &imageOpener{
 		ref:      ref,
 		buffered: o.buffered,
 		client:   o.client,
 		ctx:      o.ctx,
+		progress: o.progress,
 	}
 
 	img := &image{
@@ -109,6 +120,12 @@ func Image(ref name.Reference, options ...Option) (v1.Image, error) {
 	}
 	img.id = &id
 
+	if o.containerdRoot != "" {
+		if cimg, err := containerdImage(id, o); err == nil {
+			return cimg, nil
+		}
+	}
+
 	return img, nil
 }
 
@@ -0,0 +1,246 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// defaultLibpodVersion is the libpod API version used if the socket doesn't
+// report one.
+const defaultLibpodVersion = "4.0.0"
+
+// libpodClient implements Client, and the optional interfaces for listing
+// and removing images, with podman's libpod API rather than its
+// Docker-compatible one.
+type libpodClient struct {
+	client  *http.Client
+	version string
+}
+
+// Assert that libpodClient implements the interfaces the daemon package uses.
+var (
+	_ Client       = (*libpodClient)(nil)
+	_ imageLister  = (*libpodClient)(nil)
+	_ imageRemover = (*libpodClient)(nil)
+)
+
+// newLibpodClient returns a client for the libpod API socket at sock.
+func newLibpodClient(sock string) *libpodClient {
+	var d net.Dialer
+	return &libpodClient{
+		client: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return d.DialContext(ctx, "unix", sock)
+			},
+		}},
+		version: defaultLibpodVersion,
+	}
+}
+
+// libpodError is the body of libpod's error responses.
+type libpodError struct {
+	Cause    string `json:"cause"`
+	Message  string `json:"message"`
+	Response int    `json:"response"`
+}
+
+// do sends a request for path, relative to /libpod, and returns the response
+// if its status is 2xx.
+func (c *libpodClient) do(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     "d",
+		Path:     "/v" + c.version + "/libpod" + path,
+		RawQuery: query.Encode(),
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-tar")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	var le libpodError
+	if err := json.Unmarshal(b, &le); err != nil || le.Message == "" {
+		return nil, fmt.Errorf("podman: %s %s: %s", method, path, resp.Status)
+	}
+	return nil, fmt.Errorf("podman: %s", le.Message)
+}
+
+// NegotiateAPIVersion implements Client, using the version that the socket
+// reports it supports.
+func (c *libpodClient) NegotiateAPIVersion(ctx context.Context) {
+	resp, err := c.do(ctx, http.MethodGet, "/_ping", nil, nil)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	if v := resp.Header.Get("Libpod-API-Version"); v != "" {
+		c.version = v
+	}
+}
+
+// ImageSave implements Client, like `podman save --format docker-archive`.
+func (c *libpodClient) ImageSave(ctx context.Context, refs []string) (io.ReadCloser, error) {
+	q := url.Values{"format": {"docker-archive"}}
+	path := "/images/export"
+	if len(refs) == 1 {
+		path = "/images/" + refs[0] + "/get"
+	} else {
+		q["references"] = refs
+	}
+	resp, err := c.do(ctx, http.MethodGet, path, q, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ImageLoad implements Client, like `podman load`. The response body is
+// libpod's report of the loaded images, which has no progress messages.
+func (c *libpodClient) ImageLoad(ctx context.Context, r io.Reader, _ bool) (types.ImageLoadResponse, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/images/load", nil, r)
+	if err != nil {
+		return types.ImageLoadResponse{}, err
+	}
+	return types.ImageLoadResponse{Body: resp.Body, JSON: true}, nil
+}
+
+// ImageTag implements Client, like `podman tag`.
+func (c *libpodClient) ImageTag(ctx context.Context, src, dst string) error {
+	repo, tag := splitTag(dst)
+	q := url.Values{"repo": {repo}, "tag": {tag}}
+	resp, err := c.do(ctx, http.MethodPost, "/images/"+src+"/tag", q, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// ImageInspectWithRaw implements Client, like `podman image inspect`.
+// libpod's inspect output is a superset of the fields of Docker's that the
+// daemon package reads.
+func (c *libpodClient) ImageInspectWithRaw(ctx context.Context, ref string) (types.ImageInspect, []byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/images/"+ref+"/json", nil, nil)
+	if err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	var res types.ImageInspect
+	if err := json.NewDecoder(bytes.NewReader(raw)).Decode(&res); err != nil {
+		return types.ImageInspect{}, nil, fmt.Errorf("decoding podman image inspect: %w", err)
+	}
+	res.ID = libpodID(res.ID)
+	return res, raw, nil
+}
+
+// ImageList implements imageLister, like `podman images`.
+func (c *libpodClient) ImageList(ctx context.Context, opts types.ImageListOptions) ([]types.ImageSummary, error) {
+	q := url.Values{"all": {strconv.FormatBool(opts.All)}}
+	if opts.Filters.Len() > 0 {
+		f, err := filters.ToJSON(opts.Filters)
+		if err != nil {
+			return nil, err
+		}
+		q.Set("filters", f)
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/images/json", q, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var res []types.ImageSummary
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decoding podman images: %w", err)
+	}
+	for i := range res {
+		res[i].ID = libpodID(res[i].ID)
+	}
+	return res, nil
+}
+
+// libpodRemoveReport is the body of libpod's image removal responses.
+type libpodRemoveReport struct {
+	Deleted  []string `json:"Deleted"`
+	Untagged []string `json:"Untagged"`
+}
+
+// ImageRemove implements imageRemover, like `podman rmi`. libpod always
+// prunes untagged parents, so opts.PruneChildren is ignored.
+func (c *libpodClient) ImageRemove(ctx context.Context, ref string, opts types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error) {
+	q := url.Values{"force": {strconv.FormatBool(opts.Force)}}
+	resp, err := c.do(ctx, http.MethodDelete, "/images/"+ref, q, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var report libpodRemoveReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("decoding podman rmi: %w", err)
+	}
+	var items []types.ImageDeleteResponseItem
+	for _, u := range report.Untagged {
+		items = append(items, types.ImageDeleteResponseItem{Untagged: u})
+	}
+	for _, d := range report.Deleted {
+		items = append(items, types.ImageDeleteResponseItem{Deleted: d})
+	}
+	return items, nil
+}
+
+// libpodID returns the image ID id, which libpod reports as bare hex, in the
+// "sha256:..." form that Docker uses.
+func libpodID(id string) string {
+	if id == "" || strings.Contains(id, ":") {
+		return id
+	}
+	return "sha256:" + id
+}
+
+// splitTag splits a reference like "registry/repo:tag" into its repository
+// and tag, defaulting the tag to "latest".
+func splitTag(ref string) (string, string) {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
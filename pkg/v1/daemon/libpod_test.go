@@ -0,0 +1,206 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/compare"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// testID is the ID of the fake image, which libpod reports without the
+// algorithm.
+const testID = "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+
+// fakeLibpod serves the libpod endpoints the daemon package uses, and records
+// the requests it gets.
+type fakeLibpod struct {
+	mu       sync.Mutex
+	requests []string
+	loaded   int64
+}
+
+func (f *fakeLibpod) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+	f.mu.Unlock()
+
+	if r.URL.Path == "/v4.0.0/libpod/_ping" {
+		w.Header().Set("Libpod-API-Version", "4.2.0")
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v4.2.0/libpod")
+	switch {
+	case r.Method == http.MethodGet && path == "/images/json":
+		w.Write([]byte(`[{"Id":"` + testID + `","RepoTags":["example.com/test:latest"],"Created":1600000000}]`))
+	case r.Method == http.MethodGet && path == "/images/example.com/test:latest/get":
+		http.ServeFile(w, r, imagePath)
+	case r.Method == http.MethodGet && path == "/images/example.com/test:latest/json":
+		w.Write([]byte(`{"Id":"` + testID + `","Os":"linux","Architecture":"amd64","Labels":null}`))
+	case r.Method == http.MethodPost && path == "/images/load":
+		n, _ := io.Copy(ioutil.Discard, r.Body)
+		f.mu.Lock()
+		f.loaded += n
+		f.mu.Unlock()
+		w.Write([]byte(`{"Names":["example.com/test:latest"]}`))
+	case r.Method == http.MethodPost && path == "/images/example.com/test:latest/tag":
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodDelete && path == "/images/sha256:"+testID:
+		w.Write([]byte(`{"Untagged":["example.com/test:latest"],"Deleted":["` + testID + `"]}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"cause":"image not known","message":"` + path + `: image not known","response":404}`))
+	}
+}
+
+// serveLibpod serves fake at the podman socket under a new XDG_RUNTIME_DIR.
+func serveLibpod(t *testing.T, fake *fakeLibpod) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	sock := filepath.Join(dir, "podman", "podman.sock")
+	if err := os.MkdirAll(filepath.Dir(sock), 0700); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &http.Server{Handler: fake}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+}
+
+func TestLibpod(t *testing.T) {
+	fake := &fakeLibpod{}
+	serveLibpod(t, fake)
+
+	tag, err := name.NewTag("example.com/test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Image", func(t *testing.T) {
+		img, err := Image(tag, WithPodman())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := tarball.ImageFromPath(imagePath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := compare.Images(want, img); err != nil {
+			t.Errorf("compare.Images: %v", err)
+		}
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := Write(tag, img, WithPodman())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(resp, "example.com/test:latest") {
+			t.Errorf("Write() = %q, want the loaded image's name", resp)
+		}
+		if fake.loaded == 0 {
+			t.Error("nothing was loaded")
+		}
+	})
+
+	t.Run("Tag", func(t *testing.T) {
+		dst, err := name.NewTag("example.com/other:v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Tag(tag, dst, WithPodman()); err != nil {
+			t.Fatal(err)
+		}
+		want := "POST /v4.2.0/libpod/images/example.com/test:latest/tag?repo=example.com%2Fother&tag=v1"
+		if got := fake.requests[len(fake.requests)-1]; got != want {
+			t.Errorf("request = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		images, err := List(ListFilter{}, WithPodman())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(images) != 1 || len(images[0].Tags) != 1 || images[0].Tags[0].String() != tag.String() {
+			t.Errorf("List() = %+v, want %s", images, tag)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := Delete(tag, WithPodman(), WithForce()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		missing, err := name.NewTag("example.com/missing:latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := Image(missing, WithPodman())
+		if err == nil {
+			_, err = img.Manifest()
+		}
+		if err == nil || !strings.Contains(err.Error(), "image not known") {
+			t.Errorf("Image(missing) = %v, want libpod's error", err)
+		}
+	})
+}
+
+func TestLibpodError(t *testing.T) {
+	var le libpodError
+	if err := json.Unmarshal([]byte(`{"cause":"c","message":"m","response":500}`), &le); err != nil {
+		t.Fatal(err)
+	}
+	if le.Message != "m" || le.Response != 500 {
+		t.Errorf("libpodError = %+v", le)
+	}
+}
+
+func TestSplitTag(t *testing.T) {
+	for _, tc := range []struct {
+		ref, repo, tag string
+	}{
+		{"localhost:5000/foo:v1", "localhost:5000/foo", "v1"},
+		{"localhost:5000/foo", "localhost:5000/foo", "latest"},
+		{"foo", "foo", "latest"},
+	} {
+		repo, tag := splitTag(tc.ref)
+		if repo != tc.repo || tag != tc.tag {
+			t.Errorf("splitTag(%q) = %q, %q, want %q, %q", tc.ref, repo, tag, tc.repo, tc.tag)
+		}
+	}
+}
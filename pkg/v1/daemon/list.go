@@ -0,0 +1,142 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ListFilter selects the images returned by List. The zero value selects
+// all tagged images.
+type ListFilter struct {
+	// Dangling selects only untagged images. They have no References, so
+	// Image can't read them.
+	Dangling bool
+
+	// Labels selects images with all of the given labels, each either
+	// "key" or "key=value".
+	Labels []string
+
+	// Reference selects images with a reference that matches the pattern,
+	// e.g. "busybox" or "gcr.io/*/app:v*".
+	Reference string
+
+	// All includes intermediate images, as `docker images --all` does.
+	All bool
+}
+
+// Summary describes an image in the daemon, as returned by List.
+type Summary struct {
+	// ID is the image ID. With the classic image store, it's the digest of
+	// the config file; with the containerd image store, of the manifest.
+	ID v1.Hash
+
+	// Tags and Digests are the references that point to the image.
+	Tags    []name.Tag
+	Digests []name.Digest
+
+	Created time.Time
+	Size    int64
+	Labels  map[string]string
+
+	options []Option
+}
+
+// Image reads the image from the daemon, via its first tag or digest. The
+// image is not read until it's used, as with Image.
+func (s Summary) Image() (v1.Image, error) {
+	switch {
+	case len(s.Tags) > 0:
+		return Image(s.Tags[0], s.options...)
+	case len(s.Digests) > 0:
+		return Image(s.Digests[0], s.options...)
+	}
+	return nil, fmt.Errorf("image %s has no references", s.ID)
+}
+
+// imageLister is implemented by clients that can list images. It isn't part
+// of Client to avoid breaking existing implementations.
+type imageLister interface {
+	ImageList(context.Context, types.ImageListOptions) ([]types.ImageSummary, error)
+}
+
+// List returns the images in the daemon that match the filter, like
+// `docker images`. This requires a Client that implements ImageList, as the
+// docker client does.
+//
+// References that can't be parsed, such as "<none>:<none>", are skipped.
+func List(filter ListFilter, options ...Option) ([]Summary, error) {
+	o, err := makeOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := o.client.(imageLister)
+	if !ok {
+		return nil, errors.New("daemon client does not support listing images")
+	}
+
+	args := filters.NewArgs()
+	if filter.Dangling {
+		args.Add("dangling", "true")
+	}
+	for _, l := range filter.Labels {
+		args.Add("label", l)
+	}
+	if filter.Reference != "" {
+		args.Add("reference", filter.Reference)
+	}
+	res, err := lister.ImageList(o.ctx, types.ImageListOptions{All: filter.All, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	// Reuse the client for reading images later. Copy options first, so we
+	// don't write to the caller's array.
+	options = append(append([]Option{}, options...), WithClient(o.client))
+	out := make([]Summary, 0, len(res))
+	for _, r := range res {
+		id, err := v1.NewHash(r.ID)
+		if err != nil {
+			return nil, fmt.Errorf("parsing image ID: %w", err)
+		}
+		s := Summary{
+			ID:      id,
+			Created: time.Unix(r.Created, 0).UTC(),
+			Size:    r.Size,
+			Labels:  r.Labels,
+			options: options,
+		}
+		for _, t := range r.RepoTags {
+			if tag, err := name.NewTag(t); err == nil {
+				s.Tags = append(s.Tags, tag)
+			}
+		}
+		for _, d := range r.RepoDigests {
+			if dig, err := name.NewDigest(d); err == nil {
+				s.Digests = append(s.Digests, dig)
+			}
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
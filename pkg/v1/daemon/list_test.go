@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// listClient returns a fixed set of images and records the list options.
+type listClient struct {
+	*MockClient
+	images []types.ImageSummary
+	opts   types.ImageListOptions
+}
+
+func (c *listClient) ImageList(_ context.Context, opts types.ImageListOptions) ([]types.ImageSummary, error) {
+	c.opts = opts
+	return c.images, nil
+}
+
+func TestList(t *testing.T) {
+	client := &listClient{
+		MockClient: &MockClient{path: imagePath},
+		images: []types.ImageSummary{{
+			ID:          "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			Created:     1600000000,
+			Size:        1234,
+			Labels:      map[string]string{"app": "test"},
+			RepoTags:    []string{"test:latest", "<none>:<none>"},
+			RepoDigests: []string{"test@sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+		}, {
+			ID: "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+		}},
+	}
+
+	images, err := List(ListFilter{
+		Dangling:  true,
+		Labels:    []string{"app=test", "env"},
+		Reference: "test:*",
+		All:       true,
+	}, WithClient(client))
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	if !client.opts.All {
+		t.Error("All was not set")
+	}
+	for _, f := range []struct{ key, value string }{
+		{"dangling", "true"},
+		{"label", "app=test"},
+		{"label", "env"},
+		{"reference", "test:*"},
+	} {
+		if !client.opts.Filters.ExactMatch(f.key, f.value) {
+			t.Errorf("filter %s=%s not set: %v", f.key, f.value, client.opts.Filters.Get(f.key))
+		}
+	}
+
+	if len(images) != 2 {
+		t.Fatalf("got %d images, want 2", len(images))
+	}
+	s := images[0]
+	if len(s.Tags) != 1 || s.Tags[0].String() != "test:latest" {
+		t.Errorf("Tags = %v, want [test:latest]", s.Tags)
+	}
+	if len(s.Digests) != 1 {
+		t.Errorf("Digests = %v, want 1 digest", s.Digests)
+	}
+	if s.Size != 1234 || s.Created.Unix() != 1600000000 || s.Labels["app"] != "test" {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+	if _, err := s.Image(); err != nil {
+		t.Errorf("Image: %v", err)
+	}
+	if _, err := images[1].Image(); err == nil {
+		t.Error("Image of dangling image: expected error")
+	}
+}
+
+func TestListUnsupported(t *testing.T) {
+	if _, err := List(ListFilter{}, WithClient(&MockClient{})); err == nil {
+		t.Error("expected error listing with a client that can't list")
+	}
+}
+
+func TestListDoesNotModifyOptions(t *testing.T) {
+	client := &listClient{MockClient: &MockClient{}}
+	// Leave room after the options, where List must not write.
+	opts := make([]Option, 1, 2)
+	opts[0] = WithClient(client)
+	backing := append(opts, WithBufferedOpener())
+
+	if _, err := List(ListFilter{}, opts...); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	o := &options{}
+	backing[1](o)
+	if !o.buffered {
+		t.Error("List overwrote the caller's options")
+	}
+}
@@ -17,9 +17,10 @@ package daemon
 import (
 	"context"
 	"io"
+	"os"
 
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 // ImageOption is an alias for Option.
@@ -30,13 +31,29 @@ type ImageOption Option
 type Option func(*options)
 
 type options struct {
-	ctx      context.Context
-	client   Client
-	buffered bool
+	ctx           context.Context
+	client        Client
+	buffered      bool
+	podman        bool
+	dockerContext string
+	force         bool
+	noPrune       bool
+	tempDir       string
+	progress      func(Progress)
+	platform      *v1.Platform
+
+	containerdRoot string
 }
 
 var defaultClient = func() (Client, error) {
-	return client.NewClientWithOpts(client.FromEnv)
+	if os.Getenv("DOCKER_HOST") != "" {
+		return envClient()
+	}
+	name, err := currentContext()
+	if err != nil {
+		return nil, err
+	}
+	return contextClient(name)
 }
 
 func makeOptions(opts ...Option) (*options, error) {
@@ -49,7 +66,15 @@ func makeOptions(opts ...Option) (*options, error) {
 	}
 
 	if o.client == nil {
-		client, err := defaultClient()
+		newClient := defaultClient
+		if o.podman {
+			newClient = podmanClient
+		} else if o.dockerContext != "" {
+			newClient = func() (Client, error) {
+				return contextClient(o.dockerContext)
+			}
+		}
+		client, err := newClient()
 		if err != nil {
 			return nil, err
 		}
@@ -76,7 +101,8 @@ func WithUnbufferedOpener() Option {
 
 // WithClient is a functional option to allow injecting a docker client.
 //
-// By default, github.com/docker/docker/client.FromEnv is used.
+// By default, the client is configured as the docker CLI would be, from
+// DOCKER_HOST or the current docker context.
 func WithClient(client Client) Option {
 	return func(o *options) {
 		o.client = client
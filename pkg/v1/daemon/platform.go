@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/docker/docker/api/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// WithPlatform requests images for the given platform.
+//
+// Image pulls the reference for that platform, like `docker pull --platform`,
+// unless the daemon already has an image for it under that reference. This
+// requires a Client that implements ImagePull, as the docker client does.
+//
+// Write fails if the image is for a different platform.
+func WithPlatform(p v1.Platform) Option {
+	return func(o *options) {
+		o.platform = &p
+	}
+}
+
+// imagePuller is implemented by clients that can pull images. It isn't part
+// of Client to avoid breaking existing implementations.
+type imagePuller interface {
+	ImagePull(context.Context, string, types.ImagePullOptions) (io.ReadCloser, error)
+}
+
+func inspectPlatform(res types.ImageInspect) v1.Platform {
+	return v1.Platform{
+		OS:           res.Os,
+		Architecture: res.Architecture,
+		Variant:      res.Variant,
+		OSVersion:    res.OsVersion,
+	}
+}
+
+// ensurePlatform makes sure the daemon's image for ref is for o.platform,
+// pulling it if necessary.
+func ensurePlatform(ref name.Reference, o *options) error {
+	res, _, err := o.client.ImageInspectWithRaw(o.ctx, ref.String())
+	if err == nil && inspectPlatform(res).Satisfies(*o.platform) {
+		return nil
+	}
+
+	puller, ok := o.client.(imagePuller)
+	if !ok {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("daemon has %s for %s, not %s, and the client can't pull", ref, inspectPlatform(res).String(), o.platform.String())
+	}
+	rc, err := puller.ImagePull(o.ctx, ref.String(), types.ImagePullOptions{Platform: o.platform.String()})
+	if err != nil {
+		return fmt.Errorf("pulling %s for %s: %w", ref, o.platform.String(), err)
+	}
+	defer rc.Close()
+	if o.progress != nil {
+		decodeProgress(rc, o.progress)
+	}
+	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+		return fmt.Errorf("pulling %s for %s: %w", ref, o.platform.String(), err)
+	}
+
+	res, _, err = o.client.ImageInspectWithRaw(o.ctx, ref.String())
+	if err != nil {
+		return err
+	}
+	if p := inspectPlatform(res); !p.Satisfies(*o.platform) {
+		return fmt.Errorf("pulled %s for %s, but got %s", ref, o.platform.String(), p.String())
+	}
+	return nil
+}
+
+// checkPlatform returns an error if img isn't for o.platform.
+func checkPlatform(img v1.Image, o *options) error {
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return err
+	}
+	p := v1.Platform{
+		OS:           cf.OS,
+		Architecture: cf.Architecture,
+		OSVersion:    cf.OSVersion,
+	}
+	// Config files don't record the variant, so we can't check it.
+	spec := *o.platform
+	spec.Variant = ""
+	if !p.Satisfies(spec) {
+		return fmt.Errorf("image is for %s, not %s", p.String(), o.platform.String())
+	}
+	return nil
+}
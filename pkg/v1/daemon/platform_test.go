@@ -0,0 +1,116 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+// platformClient has an image for a single platform, and pulls switch it.
+type platformClient struct {
+	*MockClient
+	platform v1.Platform
+	pulls    []string
+}
+
+func (c *platformClient) ImageInspectWithRaw(ctx context.Context, ref string) (types.ImageInspect, []byte, error) {
+	res, raw, err := c.MockClient.ImageInspectWithRaw(ctx, ref)
+	res.Os = c.platform.OS
+	res.Architecture = c.platform.Architecture
+	res.Variant = c.platform.Variant
+	return res, raw, err
+}
+
+func (c *platformClient) ImagePull(_ context.Context, _ string, opts types.ImagePullOptions) (io.ReadCloser, error) {
+	c.pulls = append(c.pulls, opts.Platform)
+	p, err := v1.ParsePlatform(opts.Platform)
+	if err != nil {
+		return nil, err
+	}
+	c.platform = *p
+	return ioutil.NopCloser(strings.NewReader(`{"status":"Pulling from library/test","id":"latest"}`)), nil
+}
+
+func TestImagePlatform(t *testing.T) {
+	ref := name.MustParseReference("test:latest")
+	amd64 := v1.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+
+	client := &platformClient{MockClient: &MockClient{path: imagePath}, platform: amd64}
+
+	// Already the right platform, so there's nothing to pull.
+	if _, err := Image(ref, WithClient(client), WithPlatform(amd64)); err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if len(client.pulls) != 0 {
+		t.Errorf("pulled %v, want no pulls", client.pulls)
+	}
+
+	var updates []Progress
+	if _, err := Image(ref, WithClient(client), WithPlatform(arm64), WithProgress(func(p Progress) {
+		updates = append(updates, p)
+	})); err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if want := []string{"linux/arm64/v8"}; len(client.pulls) != 1 || client.pulls[0] != want[0] {
+		t.Errorf("pulled %v, want %v", client.pulls, want)
+	}
+	if len(updates) != 1 || updates[0].Status != "Pulling from library/test" {
+		t.Errorf("progress = %+v, want pull status", updates)
+	}
+
+	// Clients that can't pull fail instead.
+	if _, err := Image(ref, WithClient(&MockClient{path: imagePath}), WithPlatform(arm64)); err == nil {
+		t.Error("Image() = nil, want error")
+	}
+}
+
+func TestWritePlatform(t *testing.T) {
+	img, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf.OS, cf.Architecture = "linux", "arm64"
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag("test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &MockClient{loadBody: ioutil.NopCloser(strings.NewReader("Loaded"))}
+
+	if _, err := Write(tag, img, WithClient(client), WithPlatform(v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"})); err != nil {
+		t.Errorf("Write(arm64): %v", err)
+	}
+	if _, err := Write(tag, img, WithClient(client), WithPlatform(v1.Platform{OS: "linux", Architecture: "amd64"})); err == nil {
+		t.Error("Write(amd64) = nil, want error")
+	}
+}
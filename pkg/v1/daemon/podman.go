@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// WithPodman connects to podman's API socket rather than the Docker daemon,
+// and uses its libpod endpoints rather than the Docker-compatible ones. The
+// socket is looked for in $XDG_RUNTIME_DIR/podman for rootless podman, then
+// in /run/podman. It must be enabled first, e.g. with
+// `systemctl --user enable --now podman.socket`.
+//
+// Without this option, podman is used automatically when DOCKER_HOST is unset
+// and there's no Docker socket, but there is a podman socket.
+func WithPodman() Option {
+	return func(o *options) {
+		o.podman = true
+	}
+}
+
+// podmanSockets returns where podman's API socket usually is, rootless first.
+func podmanSockets() []string {
+	var socks []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		socks = append(socks, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	return append(socks, "/run/podman/podman.sock")
+}
+
+// podmanHost returns the DOCKER_HOST-style address of podman's API socket.
+func podmanHost() (string, error) {
+	socks := podmanSockets()
+	for _, sock := range socks {
+		if _, err := os.Stat(sock); err == nil {
+			return "unix://" + sock, nil
+		}
+	}
+	return "", fmt.Errorf("podman socket not found in %s", strings.Join(socks, ", "))
+}
+
+// podmanClient returns a libpod client for podman's API socket.
+func podmanClient() (Client, error) {
+	host, err := podmanHost()
+	if err != nil {
+		return nil, err
+	}
+	return newLibpodClient(strings.TrimPrefix(host, "unix://")), nil
+}
+
+// detectPodman returns podman's socket if it should be used instead of the
+// default Docker socket, which doesn't exist.
+func detectPodman() (string, bool) {
+	if os.Getenv("DOCKER_HOST") != "" || runtime.GOOS == "windows" {
+		return "", false
+	}
+	sock := strings.TrimPrefix(client.DefaultDockerHost, "unix://")
+	if _, err := os.Stat(sock); !errors.Is(err, os.ErrNotExist) {
+		return "", false
+	}
+	host, err := podmanHost()
+	return host, err == nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestPodmanHost(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+
+	if host, err := podmanHost(); err == nil && host == "unix://"+filepath.Join(dir, "podman", "podman.sock") {
+		t.Errorf("podmanHost() = %s before the socket exists", host)
+	}
+
+	sock := filepath.Join(dir, "podman", "podman.sock")
+	if err := os.MkdirAll(filepath.Dir(sock), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(sock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	host, err := podmanHost()
+	if err != nil {
+		t.Fatalf("podmanHost: %v", err)
+	}
+	if want := "unix://" + sock; host != want {
+		t.Errorf("podmanHost() = %s, want %s", host, want)
+	}
+
+	// An explicit DOCKER_HOST always wins.
+	t.Setenv("DOCKER_HOST", "tcp://example.com:2375")
+	if host, ok := detectPodman(); ok {
+		t.Errorf("detectPodman() = %s, want DOCKER_HOST to be used", host)
+	}
+}
+
+func TestWithPodmanMissingSocket(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if _, err := os.Stat("/run/podman/podman.sock"); err == nil {
+		t.Skip("podman is running on this host")
+	}
+
+	tag, err := name.NewTag("example.com/test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Tag(tag, tag, WithPodman()); err == nil {
+		t.Error("Tag() = nil, want error about the missing podman socket")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// Progress is a status update from the daemon while it loads or saves an
+// image.
+type Progress struct {
+	// ID identifies what the update is about, usually a layer. It may be
+	// empty for updates about the whole image.
+	ID string
+
+	// Status describes what the daemon is doing, e.g. "Loading layer".
+	Status string
+
+	// Current and Total count the bytes processed so far. Total is zero if
+	// it isn't known.
+	Current, Total int64
+}
+
+// WithProgress calls fn with every status update from the daemon while
+// writing an image, and with the number of bytes read every megabyte and at
+// the end while reading an image, for which the daemon gives no updates of
+// its own.
+//
+// Updates are delivered synchronously, so fn should return quickly.
+func WithProgress(fn func(Progress)) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+// jsonMessage is the subset of github.com/docker/docker/pkg/jsonmessage that
+// we care about.
+type jsonMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Stream         string `json:"stream"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+// decodeProgress calls fn with each message in the JSON stream from r. It
+// stops quietly at the first thing that isn't a message, since older daemons
+// respond with plain text.
+func decodeProgress(r io.Reader, fn func(Progress)) {
+	dec := json.NewDecoder(r)
+	for {
+		var m jsonMessage
+		if err := dec.Decode(&m); err != nil {
+			return
+		}
+		status := m.Status
+		if status == "" {
+			status = strings.TrimSpace(m.Stream)
+		}
+		fn(Progress{
+			ID:      m.ID,
+			Status:  status,
+			Current: m.ProgressDetail.Current,
+			Total:   m.ProgressDetail.Total,
+		})
+	}
+}
+
+// progressInterval is how many bytes progressReader reads between updates.
+const progressInterval = 1 << 20
+
+// progressReader reports the bytes read through it, every progressInterval
+// bytes and once it reaches the end.
+type progressReader struct {
+	io.ReadCloser
+	id                string
+	fn                func(Progress)
+	current, reported int64
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.current += int64(n)
+	if r.current > r.reported && (r.current-r.reported >= progressInterval || err == io.EOF) {
+		r.reported = r.current
+		r.fn(Progress{ID: r.id, Status: "Saving", Current: r.current})
+	}
+	return n, err
+}
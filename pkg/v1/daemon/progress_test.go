@@ -0,0 +1,126 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+)
+
+func TestWriteProgress(t *testing.T) {
+	body := `{"status":"Loading layer","progressDetail":{"current":32768,"total":65536},"progress":"[=>  ]","id":"abc123"}
+{"status":"Loading layer","progressDetail":{"current":65536,"total":65536},"id":"abc123"}
+{"stream":"Loaded image: test:latest\n"}
+`
+	client := &MockClient{
+		loadBody: ioutil.NopCloser(strings.NewReader(body)),
+	}
+	tag, err := name.NewTag("test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Progress
+	response, err := Write(tag, empty.Image, WithClient(client), WithProgress(func(p Progress) {
+		got = append(got, p)
+	}))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if response != body {
+		t.Errorf("Write() = %q, want %q", response, body)
+	}
+
+	want := []Progress{
+		{ID: "abc123", Status: "Loading layer", Current: 32768, Total: 65536},
+		{ID: "abc123", Status: "Loading layer", Current: 65536, Total: 65536},
+		{Status: "Loaded image: test:latest"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d updates, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("update %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWriteProgressPlainText(t *testing.T) {
+	client := &MockClient{
+		loadBody: ioutil.NopCloser(strings.NewReader("Loaded")),
+	}
+	tag, err := name.NewTag("test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	response, err := Write(tag, empty.Image, WithClient(client), WithProgress(func(Progress) { calls++ }))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if response != "Loaded" {
+		t.Errorf("Write() = %q, want %q", response, "Loaded")
+	}
+	if calls != 0 {
+		t.Errorf("got %d updates, want 0", calls)
+	}
+}
+
+func TestImageProgress(t *testing.T) {
+	var last Progress
+	img, err := Image(name.MustParseReference("unused"), WithClient(&MockClient{path: imagePath}), WithBufferedOpener(), WithProgress(func(p Progress) {
+		if p.Current < last.Current {
+			t.Errorf("progress went backwards: %d < %d", p.Current, last.Current)
+		}
+		last = p
+	}))
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if _, err := img.RawManifest(); err != nil {
+		t.Fatalf("RawManifest: %v", err)
+	}
+	if last.Current == 0 || last.Status != "Saving" {
+		t.Errorf("last update = %+v, want bytes saved", last)
+	}
+}
+
+func TestProgressReaderInterval(t *testing.T) {
+	var got []int64
+	r := &progressReader{
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(make([]byte, 3*progressInterval+10))),
+		fn:         func(p Progress) { got = append(got, p.Current) },
+	}
+	// Small reads are reported every progressInterval bytes, then at EOF.
+	if _, err := io.CopyBuffer(ioutil.Discard, struct{ io.Reader }{r}, make([]byte, 4096)); err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{progressInterval, 2 * progressInterval, 3 * progressInterval, 3*progressInterval + 10}
+	if len(got) != len(want) {
+		t.Fatalf("got updates %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("update %d = %d, want %d", i, got[i], want[i])
+		}
+	}
+}
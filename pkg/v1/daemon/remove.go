@@ -0,0 +1,92 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// WithForce makes Untag and Delete remove images that are used by stopped
+// containers, like `docker rmi --force`. Delete also needs it to remove an
+// image with several tags.
+func WithForce() Option {
+	return func(o *options) {
+		o.force = true
+	}
+}
+
+// WithNoPrune makes Untag and Delete keep untagged parents of the images they
+// remove, like `docker rmi --no-prune`.
+func WithNoPrune() Option {
+	return func(o *options) {
+		o.noPrune = true
+	}
+}
+
+// imageRemover is implemented by clients that can remove images. It isn't
+// part of Client to avoid breaking existing implementations.
+type imageRemover interface {
+	ImageRemove(context.Context, string, types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error)
+}
+
+func remove(ref string, o *options) error {
+	remover, ok := o.client.(imageRemover)
+	if !ok {
+		return errors.New("daemon client does not support removing images")
+	}
+	_, err := remover.ImageRemove(o.ctx, ref, types.ImageRemoveOptions{
+		Force:         o.force,
+		PruneChildren: !o.noPrune,
+	})
+	return err
+}
+
+// Untag removes a tag from the daemon, like `docker rmi TAG`. If it was the
+// image's last reference, the daemon deletes the image too.
+//
+// This requires a Client that implements ImageRemove, as the docker client
+// does.
+func Untag(tag name.Tag, options ...Option) error {
+	o, err := makeOptions(options...)
+	if err != nil {
+		return err
+	}
+	return remove(tag.String(), o)
+}
+
+// Delete removes the image that ref points to from the daemon, along with all
+// of its tags, like `docker rmi IMAGE_ID`.
+//
+// This requires a Client that implements ImageRemove, as the docker client
+// does.
+func Delete(ref name.Reference, options ...Option) error {
+	o, err := makeOptions(options...)
+	if err != nil {
+		return err
+	}
+	res, _, err := o.client.ImageInspectWithRaw(o.ctx, ref.String())
+	if err != nil {
+		return err
+	}
+	if err := remove(res.ID, o); err != nil {
+		return fmt.Errorf("deleting %s: %w", ref, err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// removeClient records the images it was asked to remove.
+type removeClient struct {
+	*MockClient
+	removed []string
+	opts    []types.ImageRemoveOptions
+}
+
+func (c *removeClient) ImageRemove(_ context.Context, ref string, opts types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error) {
+	c.removed = append(c.removed, ref)
+	c.opts = append(c.opts, opts)
+	return []types.ImageDeleteResponseItem{{Untagged: ref}}, nil
+}
+
+func TestUntag(t *testing.T) {
+	tag, err := name.NewTag("test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &removeClient{MockClient: &MockClient{}}
+	if err := Untag(tag, WithClient(client)); err != nil {
+		t.Fatalf("Untag: %v", err)
+	}
+	if len(client.removed) != 1 || client.removed[0] != "test:latest" {
+		t.Errorf("removed %v, want [test:latest]", client.removed)
+	}
+	if want := (types.ImageRemoveOptions{PruneChildren: true}); client.opts[0] != want {
+		t.Errorf("options = %+v, want %+v", client.opts[0], want)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ref := name.MustParseReference("test:latest")
+	client := &removeClient{MockClient: &MockClient{}}
+	if err := Delete(ref, WithClient(client), WithForce(), WithNoPrune()); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	// Images are deleted by ID, so all of their tags go too.
+	if want := "sha256:6e0b05049ed9c17d02e1a55e80d6599dbfcce7f4f4b022e3c673e685789c470e"; len(client.removed) != 1 || client.removed[0] != want {
+		t.Errorf("removed %v, want [%s]", client.removed, want)
+	}
+	if want := (types.ImageRemoveOptions{Force: true}); client.opts[0] != want {
+		t.Errorf("options = %+v, want %+v", client.opts[0], want)
+	}
+
+	if err := Delete(ref, WithClient(&MockClient{})); err == nil {
+		t.Error("expected error deleting with a client that can't remove images")
+	}
+}
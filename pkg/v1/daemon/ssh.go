@@ -0,0 +1,155 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// sshOpts returns client options that reach the daemon over ssh, like the
+// docker CLI does: by running `docker system dial-stdio` on the remote host,
+// which proxies its stdin and stdout to the daemon's socket.
+func sshOpts(host string) ([]client.Opt, error) {
+	args, err := sshArgs(host)
+	if err != nil {
+		return nil, err
+	}
+	dial := func(context.Context, string, string) (net.Conn, error) {
+		return dialCommand("ssh", args...)
+	}
+	return []client.Opt{
+		// The host is only used for the Host header; the dialer ignores it.
+		client.WithHost("http://docker.example.com"),
+		client.WithDialContext(dial),
+	}, nil
+}
+
+// sshArgs returns the ssh arguments to reach the daemon at an ssh:// address.
+func sshArgs(host string) ([]string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ssh" {
+		return nil, fmt.Errorf("expected ssh:// host, got %q", host)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("ssh host %q: paths are not supported", host)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("ssh host %q: no hostname", host)
+	}
+	var args []string
+	if user := u.User.Username(); user != "" {
+		args = append(args, "-l", user)
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	return append(args, "--", u.Hostname(), "docker", "system", "dial-stdio"), nil
+}
+
+// commandConn is a net.Conn over the stdin and stdout of a command.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr lockedBuffer
+	once   sync.Once
+}
+
+// lockedBuffer is a bytes.Buffer that's safe to write while it's read.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func dialCommand(name string, args ...string) (net.Conn, error) {
+	// Not exec.CommandContext: the connection outlives the dial.
+	cmd := exec.Command(name, args...)
+	c := &commandConn{cmd: cmd}
+	cmd.Stderr = &c.stderr
+	var err error
+	if c.stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if c.stdout, err = cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", name, err)
+	}
+	return c, nil
+}
+
+func (c *commandConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err == io.EOF {
+		if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+			return n, fmt.Errorf("%s exited: %s", c.cmd.Path, msg)
+		}
+	}
+	return n, err
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+func (c *commandConn) Close() error {
+	c.once.Do(func() {
+		c.stdin.Close()
+		c.stdout.Close()
+		if c.cmd.Process != nil {
+			c.cmd.Process.Kill() //nolint: errcheck
+		}
+		c.cmd.Wait() //nolint: errcheck
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr              { return commandAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr             { return commandAddr{} }
+func (c *commandConn) SetDeadline(time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(time.Time) error { return nil }
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string { return "command" }
+func (commandAddr) String() string  { return "command" }
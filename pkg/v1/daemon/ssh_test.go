@@ -0,0 +1,43 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package daemon
+
+import (
+	"io"
+	"testing"
+)
+
+func TestCommandConn(t *testing.T) {
+	conn, err := dialCommand("cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	want := "hello"
+	if _, err := conn.Write([]byte(want)); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("read %q, want %q", got, want)
+	}
+}
@@ -15,15 +15,31 @@
 package daemon
 
 import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 )
 
+// WithTempDir sets where Write spills layers whose size isn't known up front,
+// which is os.TempDir() by default.
+func WithTempDir(dir string) Option {
+	return func(o *options) {
+		o.tempDir = dir
+	}
+}
+
 // Tag adds a tag to an already existent image.
 func Tag(src, dest name.Tag, options ...Option) error {
 	o, err := makeOptions(options...)
@@ -35,15 +51,25 @@ func Tag(src, dest name.Tag, options ...Option) error {
 }
 
 // Write saves the image into the daemon as the given tag.
+//
+// The image is streamed to the daemon in `docker save` format, one layer at a
+// time, so memory use doesn't grow with the size of the image. Layers whose
+// Size can't be determined are spilled to a temporary file first; see
+// WithTempDir.
 func Write(tag name.Tag, img v1.Image, options ...Option) (string, error) {
 	o, err := makeOptions(options...)
 	if err != nil {
 		return "", err
 	}
+	if o.platform != nil {
+		if err := checkPlatform(img, o); err != nil {
+			return "", err
+		}
+	}
 
 	pr, pw := io.Pipe()
 	go func() {
-		pw.CloseWithError(tarball.Write(tag, img, pw))
+		pw.CloseWithError(writeTarball(pw, tag, img, o))
 	}()
 
 	// write the image in docker save format first, then load it
@@ -52,10 +78,174 @@ func Write(tag name.Tag, img v1.Image, options ...Option) (string, error) {
 		return "", fmt.Errorf("error loading image: %w", err)
 	}
 	defer resp.Body.Close()
-	b, err := ioutil.ReadAll(resp.Body)
-	response := string(b)
+
+	var buf bytes.Buffer
+	body := io.TeeReader(resp.Body, &buf)
+	if o.progress != nil {
+		decodeProgress(body, o.progress)
+	}
+	_, err = io.Copy(ioutil.Discard, body)
+	response := buf.String()
 	if err != nil {
 		return response, fmt.Errorf("error reading load response body: %w", err)
 	}
 	return response, nil
 }
+
+// writeTarball streams img to w in the format of `docker save`, as
+// tarball.Write does, but without computing every layer's size up front.
+func writeTarball(w io.Writer, tag name.Tag, img v1.Image, o *options) error {
+	tw := tar.NewWriter(w)
+
+	cfgName, err := img.ConfigName()
+	if err != nil {
+		return err
+	}
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	if err := writeTarEntry(tw, cfgName.String(), bytes.NewReader(cfg), int64(len(cfg))); err != nil {
+		return err
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	desc := tarball.Descriptor{
+		Config:   cfgName.String(),
+		RepoTags: []string{repoTag(tag)},
+		Layers:   make([]string, 0, len(layers)),
+	}
+	seen := map[string]bool{}
+	for _, l := range layers {
+		file, err := writeLayer(tw, l, seen, o.tempDir)
+		if err != nil {
+			return err
+		}
+		desc.Layers = append(desc.Layers, file)
+
+		// Keep track of foreign layers, as tarball.Write does.
+		if err := addLayerSource(&desc, img, l); err != nil {
+			return err
+		}
+	}
+
+	m, err := json.Marshal(tarball.Manifest{desc})
+	if err != nil {
+		return err
+	}
+	if err := writeTarEntry(tw, "manifest.json", bytes.NewReader(m), int64(len(m))); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// repoTag returns how tag is written in manifest.json. Docker can't load
+// tarballs without an explicit tag, so ":latest" is added if it's implied.
+func repoTag(tag name.Tag) string {
+	ts := tag.String()
+	if tag.Identifier() == name.DefaultTag && !strings.HasSuffix(ts, ":"+name.DefaultTag) {
+		ts += ":" + name.DefaultTag
+	}
+	return ts
+}
+
+// writeLayer writes the layer's compressed contents to tw, unless a layer
+// with the same digest was already written, returning the name of its file.
+func writeLayer(tw *tar.Writer, l v1.Layer, seen map[string]bool, tempDir string) (string, error) {
+	size, err := l.Size()
+	if err != nil {
+		return spillLayer(tw, l, seen, tempDir)
+	}
+	d, err := l.Digest()
+	if err != nil {
+		return "", err
+	}
+	// Drop the "sha256:" prefix, since tar treats colons as remote hosts.
+	file := d.Hex + ".tar.gz"
+	if seen[file] {
+		return file, nil
+	}
+	seen[file] = true
+
+	rc, err := l.Compressed()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return file, writeTarEntry(tw, file, rc, size)
+}
+
+// spillLayer writes a layer whose size isn't known to a temporary file first,
+// since tar headers need the size before the contents.
+func spillLayer(tw *tar.Writer, l v1.Layer, seen map[string]bool, tempDir string) (string, error) {
+	rc, err := l.Compressed()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	f, err := ioutil.TempFile(tempDir, "layer-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), rc)
+	if err != nil {
+		return "", fmt.Errorf("spilling layer: %w", err)
+	}
+	file := hex.EncodeToString(h.Sum(nil)) + ".tar.gz"
+	if seen[file] {
+		return file, nil
+	}
+	seen[file] = true
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return file, writeTarEntry(tw, file, f, size)
+}
+
+// addLayerSource records l in desc.LayerSources if it's a foreign layer.
+func addLayerSource(desc *tarball.Descriptor, img v1.Image, l v1.Layer) error {
+	mt, err := l.MediaType()
+	if err != nil || mt.IsDistributable() {
+		// Layers that can't say what they are are treated as distributable.
+		return nil
+	}
+	d, err := l.Digest()
+	if err != nil {
+		return err
+	}
+	bd, err := partial.BlobDescriptor(img, d)
+	if err != nil {
+		return err
+	}
+	diffID, err := l.DiffID()
+	if err != nil {
+		return err
+	}
+	if desc.LayerSources == nil {
+		desc.LayerSources = map[v1.Hash]v1.Descriptor{}
+	}
+	desc.LayerSources[diffID] = *bd
+	return nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, r io.Reader, size int64) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Typeflag: tar.TypeReg,
+		Size:     size,
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
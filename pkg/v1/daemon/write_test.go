@@ -15,6 +15,7 @@
 package daemon
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -26,7 +27,9 @@ import (
 	"github.com/docker/docker/api/types"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 )
 
@@ -158,3 +161,85 @@ func TestWriteDefaultClient(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// unsizedLayer is a layer that doesn't know its size or digest until it's
+// read, like a stream.Layer.
+type unsizedLayer struct {
+	v1.Layer
+}
+
+func (l *unsizedLayer) Size() (int64, error) {
+	return 0, errors.New("size not computed")
+}
+
+func (l *unsizedLayer) Digest() (v1.Hash, error) {
+	return v1.Hash{}, errors.New("digest not computed")
+}
+
+// unsizedImage has some unsizedLayers, so it has no manifest.
+type unsizedImage struct {
+	v1.Image
+	layers []v1.Layer
+}
+
+func (i *unsizedImage) Layers() ([]v1.Layer, error) {
+	return i.layers, nil
+}
+
+func TestWriteSpill(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := layers[1].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = &unsizedImage{Image: img, layers: []v1.Layer{layers[0], &unsizedLayer{layers[1]}}}
+
+	tag, err := name.NewTag("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	client := &loadClient{MockClient: &MockClient{}}
+	if _, err := Write(tag, img, WithClient(client), WithTempDir(dir)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// The implied ":latest" must be explicit.
+	latest, err := name.NewTag("test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := tarball.Image(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(client.loaded)), nil
+	}, &latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ll, err := loaded.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ll) != 2 {
+		t.Fatalf("loaded %d layers, want 2", len(ll))
+	}
+	got, err := ll[1].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("spilled layer digest = %s, want %s", got, want)
+	}
+
+	if files, err := ioutil.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	} else if len(files) != 0 {
+		t.Errorf("temp files left behind: %v", files)
+	}
+}
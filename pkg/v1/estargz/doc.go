@@ -0,0 +1,23 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package estargz converts layers to eStargz and reads files from them
+// without fetching whole layers. See:
+// https://github.com/containerd/stargz-snapshotter/blob/main/docs/estargz.md
+//
+// An eStargz layer is a gzipped tarball, readable by any runtime, whose
+// files are compressed separately and indexed by a table of contents (TOC)
+// at its end. With the TOC, any one file can be read with a ranged read of
+// the layer.
+package estargz
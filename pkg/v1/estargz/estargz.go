@@ -0,0 +1,161 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package estargz
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// TOCEntry is an entry in an eStargz layer's table of contents.
+type TOCEntry = estargz.TOCEntry
+
+type options struct {
+	prioritized []string
+	missing     *[]string
+	chunkSize   int
+}
+
+// Option is a functional option for Layer.
+type Option func(*options)
+
+// WithPrioritizedFiles puts the given files first in the layer, in order,
+// followed by a landmark that runtimes use to prefetch them. Layer fails if
+// any of them isn't in the layer, unless WithMissingPrioritizedFiles is
+// given.
+func WithPrioritizedFiles(files ...string) Option {
+	return func(o *options) {
+		o.prioritized = append(o.prioritized, files...)
+	}
+}
+
+// WithMissingPrioritizedFiles allows prioritized files to be missing from the
+// layer. When the layer is built, *missing is set to those that are.
+func WithMissingPrioritizedFiles(missing *[]string) Option {
+	return func(o *options) {
+		o.missing = missing
+	}
+}
+
+// WithChunkSize sets the size of the chunks that large files are split into,
+// and so the granularity of ranged reads of them.
+func WithChunkSize(size int) Option {
+	return func(o *options) {
+		o.chunkSize = size
+	}
+}
+
+// Layer returns l converted to eStargz. The returned layer's descriptor is
+// annotated with the digest of its TOC.
+func Layer(l v1.Layer, opts ...Option) (v1.Layer, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	eopts := []estargz.Option{}
+	if len(o.prioritized) != 0 {
+		eopts = append(eopts, estargz.WithPrioritizedFiles(o.prioritized))
+	}
+	if o.missing != nil {
+		eopts = append(eopts, estargz.WithAllowPrioritizeNotFound(o.missing))
+	}
+	if o.chunkSize != 0 {
+		eopts = append(eopts, estargz.WithChunkSize(o.chunkSize))
+	}
+	opener := func() (io.ReadCloser, error) {
+		// The layer may be built more than once, so don't accumulate
+		// missing files across builds.
+		if o.missing != nil {
+			*o.missing = (*o.missing)[:0]
+		}
+		return l.Uncompressed()
+	}
+	return tarball.LayerFromOpener(opener, tarball.WithEstargz, tarball.WithEstargzOptions(eopts...))
+}
+
+// Reader reads the TOC and files of an eStargz layer.
+type Reader struct {
+	r *estargz.Reader
+}
+
+// Open returns a Reader for the eStargz layer in sr. Only the TOC is read.
+func Open(sr *io.SectionReader) (*Reader, error) {
+	r, err := estargz.Open(sr)
+	if err != nil {
+		return nil, fmt.Errorf("opening eStargz layer: %w", err)
+	}
+	return &Reader{r: r}, nil
+}
+
+// Remote returns a Reader for the eStargz layer at ref that reads its TOC
+// and files with ranged reads, using remote.BlobReader.
+func Remote(ref name.Digest, options ...remote.Option) (*Reader, error) {
+	sr, err := remote.BlobReader(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	return Open(sr)
+}
+
+// TOCDigest returns the digest of the layer's TOC.
+func (r *Reader) TOCDigest() (v1.Hash, error) {
+	return v1.NewHash(r.r.TOCDigest().String())
+}
+
+// Entries returns the entries of the layer's TOC, sorted by name. Chunks of
+// large files and the landmarks that mark the end of prioritized files are
+// not included.
+func (r *Reader) Entries() []*TOCEntry {
+	entries := []*TOCEntry{}
+	root, ok := r.r.Lookup("")
+	if !ok {
+		return entries
+	}
+	var walk func(e *TOCEntry)
+	walk = func(e *TOCEntry) {
+		e.ForeachChild(func(_ string, child *TOCEntry) bool {
+			if child.Name != estargz.PrefetchLandmark && child.Name != estargz.NoPrefetchLandmark {
+				entries = append(entries, child)
+			}
+			if child.Type == "dir" {
+				walk(child)
+			}
+			return true
+		})
+	}
+	walk(root)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// Lookup returns the TOC entry for the file at path.
+func (r *Reader) Lookup(path string) (*TOCEntry, bool) {
+	return r.r.Lookup(path)
+}
+
+// OpenFile returns a reader for the contents of the regular file at path,
+// which reads only the chunks of the layer that hold it.
+func (r *Reader) OpenFile(path string) (*io.SectionReader, error) {
+	return r.r.OpenFile(path)
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package estargz
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+var files = map[string]string{
+	"etc/":          "",
+	"etc/hostname":  "localhost\n",
+	"usr/":          "",
+	"usr/bin/":      "",
+	"usr/bin/hello": "#!/bin/sh\necho hello\n",
+}
+
+func layer(t *testing.T) v1.Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"etc/", "etc/hostname", "usr/", "usr/bin/", "usr/bin/hello"} {
+		hdr := &tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}
+		if contents := files[name]; contents != "" {
+			hdr.Typeflag, hdr.Size = tar.TypeReg, int64(len(contents))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return static.NewLayer(buf.Bytes(), types.DockerUncompressedLayer)
+}
+
+func compressed(t *testing.T, l v1.Layer) []byte {
+	t.Helper()
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func check(t *testing.T, r *Reader) {
+	t.Helper()
+	got := []string{}
+	for _, e := range r.Entries() {
+		got = append(got, e.Name)
+	}
+	if want := "[etc etc/hostname usr usr/bin usr/bin/hello]"; fmt.Sprint(got) != want {
+		t.Errorf("Entries() = %v, want %s", got, want)
+	}
+	if e, ok := r.Lookup("usr/bin/hello"); !ok || e.Size != int64(len(files["usr/bin/hello"])) {
+		t.Errorf("Lookup() = %+v, %t", e, ok)
+	}
+	sr, err := r.OpenFile("usr/bin/hello")
+	if err != nil {
+		t.Fatalf("OpenFile() = %v", err)
+	}
+	b, err := ioutil.ReadAll(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != files["usr/bin/hello"] {
+		t.Errorf("OpenFile() = %q, want %q", b, files["usr/bin/hello"])
+	}
+}
+
+func TestLayer(t *testing.T) {
+	missing := []string{}
+	l, err := Layer(layer(t),
+		WithPrioritizedFiles("usr/bin/hello", "missing"),
+		WithMissingPrioritizedFiles(&missing),
+		WithChunkSize(4))
+	if err != nil {
+		t.Fatalf("Layer() = %v", err)
+	}
+	b := compressed(t, l)
+	if fmt.Sprint(missing) != "[missing]" {
+		t.Errorf("missing = %v, want [missing]", missing)
+	}
+
+	r, err := Open(io.NewSectionReader(bytes.NewReader(b), 0, int64(len(b))))
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	check(t, r)
+
+	// Prioritized files come first.
+	hello, _ := r.Lookup("usr/bin/hello")
+	hostname, _ := r.Lookup("etc/hostname")
+	if hello.Offset > hostname.Offset {
+		t.Errorf("usr/bin/hello at offset %d should be before etc/hostname at %d", hello.Offset, hostname.Offset)
+	}
+
+	// The TOC digest is in the layer's annotations.
+	h, err := r.TOCDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := partial.Descriptor(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := desc.Annotations["containerd.io/snapshot/stargz/toc.digest"]; got != h.String() {
+		t.Errorf("TOC digest annotation = %s, want %s", got, h)
+	}
+
+	if _, err := Layer(layer(t), WithPrioritizedFiles("missing")); err == nil {
+		t.Error("Layer() with a missing prioritized file should fail")
+	} else if _, err := l.Digest(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemote(t *testing.T) {
+	l, err := Layer(layer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := compressed(t, l)
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ranges := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case fmt.Sprintf("/v2/foo/blobs/%s", h):
+			if r.Method == http.MethodGet && r.Header.Get("Range") == "" {
+				t.Errorf("Unexpected GET of the whole layer")
+			}
+			ranges++
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+		default:
+			t.Fatalf("Unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := name.NewDigest(fmt.Sprintf("%s/foo@%s", u.Host, h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Remote(ref)
+	if err != nil {
+		t.Fatalf("Remote() = %v", err)
+	}
+	check(t, r)
+	if ranges == 0 {
+		t.Error("no ranged reads")
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fs materializes the filesystem of an image in a directory on disk,
+// so that it can be inspected, or used as the root of a chroot, without a
+// container runtime.
+//
+// Layers are applied in order with overlay semantics: later layers replace
+// entries from earlier ones, whiteout files remove them, and opaque whiteouts
+// hide the earlier contents of a directory. Entries are never written outside
+// of the target directory, even through symlinks or ".." components in the
+// layers.
+package fs
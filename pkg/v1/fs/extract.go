@@ -0,0 +1,305 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = whiteoutPrefix + whiteoutPrefix + ".opq"
+	xattrPrefix    = "SCHILY.xattr."
+)
+
+// Extract writes the flattened filesystem of img to dir, creating dir if it
+// doesn't exist.
+//
+// Device nodes and FIFOs are skipped, since creating them requires
+// privileges that extraction otherwise doesn't.
+func Extract(img v1.Image, dir string, opts ...Option) error {
+	o := makeOptions(opts...)
+	layers, err := partial.LayersWithContext(o.ctx, img)
+	if err != nil {
+		return fmt.Errorf("retrieving image layers: %w", err)
+	}
+	e, err := newExtractor(dir, o)
+	if err != nil {
+		return err
+	}
+	for i, layer := range layers {
+		if err := e.applyLayer(layer); err != nil {
+			return fmt.Errorf("extracting layer %d: %w", i, err)
+		}
+	}
+	return e.finish()
+}
+
+// ExtractLayer applies layer to the filesystem in dir, creating dir if it
+// doesn't exist. Applying each layer of an image in order, to the same dir,
+// is equivalent to Extract.
+func ExtractLayer(layer v1.Layer, dir string, opts ...Option) error {
+	e, err := newExtractor(dir, makeOptions(opts...))
+	if err != nil {
+		return err
+	}
+	if err := e.applyLayer(layer); err != nil {
+		return err
+	}
+	return e.finish()
+}
+
+type extractor struct {
+	root string
+	o    *options
+
+	// dirs holds the modes and times of the directories that have been
+	// extracted, to set once their contents have been written.
+	dirs map[string]*tar.Header
+}
+
+func newExtractor(dir string, o *options) (*extractor, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &extractor{
+		root: root,
+		o:    o,
+		dirs: map[string]*tar.Header{},
+	}, nil
+}
+
+func (e *extractor) applyLayer(layer v1.Layer) error {
+	rc, err := partial.UncompressedWithContext(e.o.ctx, layer)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// added holds the entries that this layer has written, which opaque
+	// whiteouts in the same layer must keep.
+	added := map[string]bool{}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading tar: %w", err)
+		}
+		if err := e.o.ctx.Err(); err != nil {
+			return err
+		}
+		if err := e.apply(hdr, tr, added); err != nil {
+			return fmt.Errorf("extracting %q: %w", hdr.Name, err)
+		}
+	}
+}
+
+func (e *extractor) apply(hdr *tar.Header, r io.Reader, added map[string]bool) error {
+	// Interpret names relative to the root, however they're written.
+	name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+	if name == "" {
+		return nil
+	}
+	dir, base := path.Split(name)
+
+	if base == opaqueWhiteout {
+		p, err := resolve(e.root, dir, true)
+		if err != nil {
+			return err
+		}
+		return e.removeOpaque(p, strings.TrimSuffix(dir, "/"), added)
+	}
+	if strings.HasPrefix(base, whiteoutPrefix) {
+		p, err := resolve(e.root, dir+strings.TrimPrefix(base, whiteoutPrefix), false)
+		if err != nil {
+			return err
+		}
+		return e.remove(p)
+	}
+
+	p, err := resolve(e.root, name, false)
+	if err != nil {
+		return err
+	}
+	if p == e.root {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	// Replace whatever is there, unless both are directories, whose
+	// contents merge.
+	if fi, err := os.Lstat(p); err == nil {
+		if !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+			if err := e.remove(p); err != nil {
+				return err
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		// Keep the directory writable until its contents are extracted.
+		if err := os.Mkdir(p, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		e.dirs[p] = hdr
+	case tar.TypeReg, tar.TypeRegA:
+		if err := writeFile(p, r); err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err := os.Symlink(hdr.Linkname, p); err != nil {
+			return err
+		}
+	case tar.TypeLink:
+		target, err := resolve(e.root, strings.TrimPrefix(path.Clean("/"+hdr.Linkname), "/"), false)
+		if err != nil {
+			return err
+		}
+		if err := os.Link(target, p); err != nil {
+			return err
+		}
+	default:
+		// Skip devices and FIFOs, and anything we don't know about.
+		return nil
+	}
+	added[name] = true
+
+	if e.o.ownership {
+		if err := os.Lchown(p, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+	}
+	if e.o.xattrs {
+		for k, v := range hdr.PAXRecords {
+			if attr := strings.TrimPrefix(k, xattrPrefix); attr != k {
+				if err := setxattr(p, attr, []byte(v)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+		if err := os.Chmod(p, mode(hdr)); err != nil {
+			return err
+		}
+		return os.Chtimes(p, accessTime(hdr), hdr.ModTime)
+	}
+	return nil
+}
+
+// finish sets the modes and times of directories, deepest first, so that
+// setting one doesn't disturb another.
+func (e *extractor) finish() error {
+	paths := make([]string, 0, len(e.dirs))
+	for p := range e.dirs {
+		paths = append(paths, p)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	for _, p := range paths {
+		hdr := e.dirs[p]
+		if err := os.Chmod(p, mode(hdr)); err != nil {
+			return err
+		}
+		if err := os.Chtimes(p, accessTime(hdr), hdr.ModTime); err != nil {
+			return err
+		}
+	}
+	e.dirs = map[string]*tar.Header{}
+	return nil
+}
+
+func writeFile(p string, r io.Reader) error {
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// remove removes p and anything under it, and forgets any directories it
+// removed, so that finish doesn't touch whatever replaces them.
+func (e *extractor) remove(p string) error {
+	delete(e.dirs, p)
+	prefix := p + string(filepath.Separator)
+	for d := range e.dirs {
+		if strings.HasPrefix(d, prefix) {
+			delete(e.dirs, d)
+		}
+	}
+	return os.RemoveAll(p)
+}
+
+// removeOpaque removes the contents of the directory p, whose name in the
+// image is dir, except for the entries in added.
+func (e *extractor) removeOpaque(p, dir string, added map[string]bool) error {
+	entries, err := os.ReadDir(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := path.Join(dir, entry.Name())
+		child := filepath.Join(p, entry.Name())
+		if !added[name] {
+			if err := e.remove(child); err != nil {
+				return err
+			}
+		} else if entry.IsDir() {
+			if err := e.removeOpaque(child, name, added); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func mode(hdr *tar.Header) os.FileMode {
+	return hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+func accessTime(hdr *tar.Header) time.Time {
+	if hdr.AccessTime.IsZero() {
+		return hdr.ModTime
+	}
+	return hdr.AccessTime
+}
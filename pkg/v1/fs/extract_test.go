@@ -0,0 +1,285 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+var modTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+type entry struct {
+	name     string
+	typ      byte
+	content  string
+	linkname string
+	mode     int64
+}
+
+func layer(t *testing.T, entries ...entry) v1.Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typ,
+			Linkname: e.linkname,
+			Mode:     e.mode,
+			Size:     int64(len(e.content)),
+			ModTime:  modTime,
+		}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+			if hdr.Typeflag == tar.TypeDir {
+				hdr.Mode = 0755
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func image(t *testing.T, layers ...v1.Layer) v1.Image {
+	t.Helper()
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func mustContent(t *testing.T, p, want string) {
+	t.Helper()
+	got, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s = %q, want %q", p, got, want)
+	}
+}
+
+func mustNotExist(t *testing.T, p string) {
+	t.Helper()
+	if _, err := os.Lstat(p); !os.IsNotExist(err) {
+		t.Errorf("%s exists (%v), want it not to", p, err)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	img := image(t,
+		layer(t,
+			entry{name: "etc/", typ: tar.TypeDir},
+			entry{name: "etc/passwd", content: "root"},
+			entry{name: "etc/shadow", content: "secret", mode: 0600},
+			entry{name: "bin/sh", content: "sh", mode: 0755},
+			entry{name: "opt/old", content: "old"},
+		),
+		layer(t,
+			entry{name: "./etc/passwd", content: "root\nuser"},
+			entry{name: "etc/.wh.shadow"},
+			entry{name: "bin/bash", typ: tar.TypeLink, linkname: "bin/sh"},
+			entry{name: "opt/.wh.old"},
+		),
+	)
+	dir := t.TempDir()
+	if err := Extract(img, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	mustContent(t, filepath.Join(dir, "etc/passwd"), "root\nuser")
+	mustNotExist(t, filepath.Join(dir, "etc/shadow"))
+	mustNotExist(t, filepath.Join(dir, "opt/old"))
+	mustContent(t, filepath.Join(dir, "bin/bash"), "sh")
+
+	fi, err := os.Stat(filepath.Join(dir, "bin/sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Mode().Perm(), os.FileMode(0755); got != want {
+		t.Errorf("bin/sh mode = %v, want %v", got, want)
+	}
+	if !fi.ModTime().Equal(modTime) {
+		t.Errorf("bin/sh mtime = %v, want %v", fi.ModTime(), modTime)
+	}
+	fi, err = os.Stat(filepath.Join(dir, "etc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Mode().Perm(), os.FileMode(0755); got != want {
+		t.Errorf("etc mode = %v, want %v", got, want)
+	}
+	if !fi.ModTime().Equal(modTime) {
+		t.Errorf("etc mtime = %v, want %v", fi.ModTime(), modTime)
+	}
+}
+
+func TestExtractOpaqueWhiteout(t *testing.T) {
+	img := image(t,
+		layer(t,
+			entry{name: "d/a", content: "a"},
+			entry{name: "d/sub/b", content: "b"},
+		),
+		layer(t,
+			entry{name: "d/", typ: tar.TypeDir},
+			entry{name: "d/c", content: "c"},
+			entry{name: "d/.wh..wh..opq"},
+			entry{name: "d/sub/e", content: "e"},
+		),
+	)
+	dir := t.TempDir()
+	if err := Extract(img, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	mustNotExist(t, filepath.Join(dir, "d/a"))
+	mustNotExist(t, filepath.Join(dir, "d/sub/b"))
+	mustContent(t, filepath.Join(dir, "d/c"), "c")
+	mustContent(t, filepath.Join(dir, "d/sub/e"), "e")
+}
+
+func TestExtractReplace(t *testing.T) {
+	img := image(t,
+		layer(t,
+			entry{name: "a/", typ: tar.TypeDir, mode: 0555},
+			entry{name: "a/b", content: "b"},
+			entry{name: "f", content: "f"},
+		),
+		layer(t,
+			// A file replaces a directory, and vice versa.
+			entry{name: "a", content: "a"},
+			entry{name: "f/", typ: tar.TypeDir},
+			entry{name: "f/g", content: "g"},
+		),
+	)
+	dir := t.TempDir()
+	if err := Extract(img, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	mustContent(t, filepath.Join(dir, "a"), "a")
+	mustContent(t, filepath.Join(dir, "f/g"), "g")
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	img := image(t,
+		layer(t,
+			entry{name: "usr/lib/", typ: tar.TypeDir},
+			entry{name: "lib", typ: tar.TypeSymlink, linkname: "/usr/lib"},
+		),
+		layer(t,
+			// Written through the absolute symlink, within the root.
+			entry{name: "lib/libc.so", content: "libc"},
+		),
+	)
+	dir := t.TempDir()
+	if err := Extract(img, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	mustContent(t, filepath.Join(dir, "usr/lib/libc.so"), "libc")
+	if target, err := os.Readlink(filepath.Join(dir, "lib")); err != nil {
+		t.Fatal(err)
+	} else if target != "/usr/lib" {
+		t.Errorf("lib -> %q, want %q", target, "/usr/lib")
+	}
+}
+
+func TestExtractTraversal(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "root")
+
+	img := image(t,
+		layer(t,
+			entry{name: "../escape", content: "dots"},
+			entry{name: "/abs", content: "abs"},
+			entry{name: "up", typ: tar.TypeSymlink, linkname: "../../.."},
+			entry{name: "rel", typ: tar.TypeSymlink, linkname: "sub/../../"},
+			entry{name: "loop", typ: tar.TypeSymlink, linkname: "loop"},
+		),
+		layer(t,
+			entry{name: "up/through-up", content: "up"},
+			entry{name: "rel/through-rel", content: "rel"},
+			entry{name: "hard", typ: tar.TypeLink, linkname: "../../escape"},
+		),
+	)
+	if err := Extract(img, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	mustContent(t, filepath.Join(dir, "escape"), "dots")
+	mustContent(t, filepath.Join(dir, "abs"), "abs")
+	mustContent(t, filepath.Join(dir, "through-up"), "up")
+	mustContent(t, filepath.Join(dir, "through-rel"), "rel")
+	mustContent(t, filepath.Join(dir, "hard"), "dots")
+
+	entries, err := ioutil.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("extraction wrote outside of its root: %v", entries)
+	}
+
+	// Writing through a symlink loop fails rather than spins.
+	if err := ExtractLayer(layer(t, entry{name: "loop/x", content: "x"}), dir); err == nil {
+		t.Error("ExtractLayer() through a symlink loop succeeded, want error")
+	}
+}
+
+func TestExtractLayer(t *testing.T) {
+	l1 := layer(t, entry{name: "a", content: "a"}, entry{name: "b", content: "b"})
+	l2 := layer(t, entry{name: ".wh.a"})
+
+	dir := t.TempDir()
+	for _, l := range []v1.Layer{l1, l2} {
+		if err := ExtractLayer(l, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustNotExist(t, filepath.Join(dir, "a"))
+	mustContent(t, filepath.Join(dir, "b"), "b")
+}
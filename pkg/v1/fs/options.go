@@ -0,0 +1,63 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import "context"
+
+// Option is a functional option for Extract and ExtractLayer.
+type Option func(*options)
+
+type options struct {
+	ctx       context.Context
+	ownership bool
+	xattrs    bool
+}
+
+func makeOptions(opts ...Option) *options {
+	o := &options{
+		ctx: context.Background(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithContext sets the context used to read layers, and to cancel
+// extraction between entries.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithOwnership sets the owner and group of each extracted entry to the
+// uid and gid recorded in the layer. This usually requires privileges.
+//
+// By default, entries are owned by the current user.
+func WithOwnership() Option {
+	return func(o *options) {
+		o.ownership = true
+	}
+}
+
+// WithXattrs sets the extended attributes recorded in the layer (as
+// "SCHILY.xattr." PAX records) on each extracted entry. Extended attributes
+// are only supported on Linux.
+func WithXattrs() Option {
+	return func(o *options) {
+		o.xattrs = true
+	}
+}
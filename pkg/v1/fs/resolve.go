@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxLinks bounds how many symlinks resolve follows, like the kernel's
+// limit, so that symlink loops fail rather than spin.
+const maxLinks = 255
+
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// resolve returns the path on disk of name, interpreted as if root were the
+// root of the filesystem: symlinks in name are followed, but absolute
+// targets and ".." components never lead outside of root. The last
+// component of name is only followed if it's a symlink and followLast is
+// set.
+//
+// Components that don't exist yet are taken as they are.
+func resolve(root, name string, followLast bool) (string, error) {
+	// resolved is slash-separated and relative to root; "" is root itself.
+	resolved := ""
+	rest := name
+	links := 0
+	for rest != "" {
+		var part string
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			part, rest = rest[:i], rest[i+1:]
+		} else {
+			part, rest = rest, ""
+		}
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if resolved = path.Dir(resolved); resolved == "." {
+				resolved = ""
+			}
+			continue
+		}
+
+		next := path.Join(resolved, part)
+		if rest == "" && !followLast {
+			resolved = next
+			break
+		}
+		p := filepath.Join(root, filepath.FromSlash(next))
+		fi, err := os.Lstat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			resolved = next
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if links++; links > maxLinks {
+			return "", &os.PathError{Op: "resolve", Path: name, Err: errTooManyLinks}
+		}
+		target, err := os.Readlink(p)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = ""
+		}
+		// Don't clean the joined path: ".." after a symlink is relative to
+		// where the symlink points, not to where it is.
+		rest = target + "/" + rest
+	}
+	return filepath.Join(root, filepath.FromSlash(resolved)), nil
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// artifactRegistryEndpoint is the Artifact Registry API.
+const artifactRegistryEndpoint = "https://artifactregistry.googleapis.com"
+
+// ArtifactRepositories uses the Artifact Registry API to list the Docker
+// repositories in each of the given projects, across all of their locations,
+// e.g. "us-docker.pkg.dev/my-project/my-repo". These can be passed to List and
+// Walk, which can't discover them on their own since Artifact Registry doesn't
+// support the catalog API across locations.
+//
+// The API requires an OAuth2 access token, e.g. from Keychain or
+// NewEnvAuthenticator, with permission to list repositories.
+func ArtifactRepositories(projects []string, options ...Option) ([]name.Repository, error) {
+	l, err := newAPILister(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []name.Repository
+	for _, project := range projects {
+		locations, err := l.arLocations(project)
+		if err != nil {
+			return nil, fmt.Errorf("listing locations of %s: %w", project, err)
+		}
+		for _, location := range locations {
+			rs, err := l.arRepositories(project, location)
+			if err != nil {
+				return nil, fmt.Errorf("listing repositories of %s in %s: %w", project, location, err)
+			}
+			repos = append(repos, rs...)
+		}
+	}
+	return repos, nil
+}
+
+// newAPILister returns a lister for talking to Google APIs rather than to a
+// registry.
+func newAPILister(options ...Option) (*lister, error) {
+	// Keychains resolve against a registry, so pretend to be one that a Google
+	// keychain will provide credentials for.
+	reg, err := name.NewRegistry("docker.pkg.dev")
+	if err != nil {
+		return nil, err
+	}
+	l := &lister{
+		auth:      authn.Anonymous,
+		transport: http.DefaultTransport,
+		repo:      name.Repository{Registry: reg},
+		ctx:       context.Background(),
+		endpoint:  artifactRegistryEndpoint,
+	}
+	for _, option := range options {
+		if err := option(l); err != nil {
+			return nil, err
+		}
+	}
+	l.wrapTransport()
+	l.client = &http.Client{Transport: l.transport}
+	return l, nil
+}
+
+type arLocations struct {
+	Locations []struct {
+		LocationID string `json:"locationId"`
+	} `json:"locations"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (l *lister) arLocations(project string) ([]string, error) {
+	var locations []string
+	token := ""
+	for {
+		var resp arLocations
+		if err := l.getJSON(fmt.Sprintf("/v1/projects/%s/locations", project), token, &resp); err != nil {
+			return nil, err
+		}
+		for _, loc := range resp.Locations {
+			locations = append(locations, loc.LocationID)
+		}
+		if resp.NextPageToken == "" {
+			return locations, nil
+		}
+		token = resp.NextPageToken
+	}
+}
+
+type arRepositories struct {
+	Repositories []struct {
+		Name   string `json:"name"`
+		Format string `json:"format"`
+	} `json:"repositories"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (l *lister) arRepositories(project, location string) ([]name.Repository, error) {
+	var repos []name.Repository
+	token := ""
+	for {
+		var resp arRepositories
+		if err := l.getJSON(fmt.Sprintf("/v1/projects/%s/locations/%s/repositories", project, location), token, &resp); err != nil {
+			return nil, err
+		}
+		for _, r := range resp.Repositories {
+			if r.Format != "DOCKER" {
+				continue
+			}
+			repo, err := arRepository(r.Name)
+			if err != nil {
+				return nil, err
+			}
+			repos = append(repos, repo)
+		}
+		if resp.NextPageToken == "" {
+			return repos, nil
+		}
+		token = resp.NextPageToken
+	}
+}
+
+// arRepository maps a repository's resource name, e.g.
+// "projects/my-project/locations/us/repositories/my-repo", to its name on the
+// registry, e.g. "us-docker.pkg.dev/my-project/my-repo".
+func arRepository(resource string) (name.Repository, error) {
+	parts := strings.Split(resource, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "repositories" {
+		return name.Repository{}, fmt.Errorf("unexpected repository name %q", resource)
+	}
+	project, location, repo := parts[1], parts[3], parts[5]
+
+	// Domain-scoped projects like "example.com:my-project" are addressed as
+	// "example.com/my-project".
+	project = strings.Replace(project, ":", "/", 1)
+	return name.NewRepository(fmt.Sprintf("%s-docker.pkg.dev/%s/%s", location, project, repo), name.StrictValidation)
+}
+
+// getJSON GETs the page of an API listing with the given page token and
+// decodes it into v.
+func (l *lister) getJSON(path, pageToken string, v interface{}) error {
+	select {
+	case <-l.ctx.Done():
+		return l.ctx.Err()
+	default:
+	}
+
+	u, err := url.Parse(l.endpoint + path)
+	if err != nil {
+		return err
+	}
+	if pageToken != "" {
+		u.RawQuery = url.Values{"pageToken": {pageToken}}.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(l.ctx)
+
+	token, err := l.accessToken()
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// accessToken returns the OAuth2 access token for l.auth, if any.
+func (l *lister) accessToken() (string, error) {
+	cfg, err := l.auth.Authorization()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case cfg.RegistryToken != "":
+		return cfg.RegistryToken, nil
+	case cfg.Username == "_token" || cfg.Username == "oauth2accesstoken":
+		return cfg.Password, nil
+	case *cfg == authn.AuthConfig{}:
+		return "", nil
+	}
+	return "", fmt.Errorf("the Artifact Registry API requires an access token, got credentials for %q", cfg.Username)
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestArtifactRepositories(t *testing.T) {
+	responses := map[string]map[string]interface{}{
+		"/v1/projects/my-project/locations": {
+			"": map[string]interface{}{
+				"locations":     []map[string]string{{"locationId": "us"}},
+				"nextPageToken": "page2",
+			},
+			"page2": map[string]interface{}{
+				"locations": []map[string]string{{"locationId": "europe-west1"}},
+			},
+		},
+		"/v1/projects/my-project/locations/us/repositories": {
+			"": map[string]interface{}{
+				"repositories": []map[string]string{
+					{"name": "projects/my-project/locations/us/repositories/images", "format": "DOCKER"},
+					{"name": "projects/my-project/locations/us/repositories/jars", "format": "MAVEN"},
+				},
+			},
+		},
+		"/v1/projects/my-project/locations/europe-west1/repositories": {
+			"": map[string]interface{}{},
+		},
+		"/v1/projects/example.com:other/locations": {
+			"": map[string]interface{}{
+				"locations": []map[string]string{{"locationId": "asia"}},
+			},
+		},
+		"/v1/projects/example.com:other/locations/asia/repositories": {
+			"": map[string]interface{}{
+				"repositories": []map[string]string{
+					{"name": "projects/example.com:other/locations/asia/repositories/mirror", "format": "DOCKER"},
+				},
+			},
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer hunter2"; got != want {
+			t.Errorf("Authorization = %q, want %q", got, want)
+		}
+		resp, ok := responses[r.URL.Path][r.URL.Query().Get("pageToken")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	endpoint := func(l *lister) error {
+		l.endpoint = server.URL
+		return nil
+	}
+
+	repos, err := ArtifactRepositories([]string{"my-project", "example.com:other"}, WithAuth(&authn.Bearer{Token: "hunter2"}), endpoint)
+	if err != nil {
+		t.Fatalf("ArtifactRepositories() = %v", err)
+	}
+	var got []string
+	for _, r := range repos {
+		got = append(got, r.String())
+	}
+	want := []string{
+		"us-docker.pkg.dev/my-project/images",
+		"asia-docker.pkg.dev/example.com/other/mirror",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ArtifactRepositories() (-want +got) = %s", diff)
+	}
+
+	if _, err := ArtifactRepositories([]string{"missing"}, WithAuth(&authn.Bearer{Token: "hunter2"}), endpoint); err == nil {
+		t.Error("ArtifactRepositories() succeeded for a missing project")
+	}
+
+	// Credentials that aren't access tokens can't be used.
+	if _, err := ArtifactRepositories([]string{"my-project"}, WithAuth(NewJSONKeyAuthenticator("{}")), endpoint); err == nil {
+		t.Error("ArtifactRepositories() succeeded with a JSON key")
+	}
+}
+
+func TestArtifactRepository(t *testing.T) {
+	if _, err := arRepository("projects/p/locations/us"); err == nil {
+		t.Error("arRepository() succeeded for a location")
+	}
+	repo, err := arRepository("projects/p/locations/us-central1/repositories/r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := name.MustParseReference("us-central1-docker.pkg.dev/p/r:latest").Context(); repo != want {
+		t.Errorf("arRepository() = %v, want %v", repo, want)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// ManifestFunc is called by List and Walk for each manifest that passes the
+// filters, when set with WithManifestFunc.
+type ManifestFunc func(repo name.Repository, digest string, info ManifestInfo) error
+
+// filters are applied client-side to listings, since neither GCR nor the
+// registry API understand any filters besides pagination.
+type filters struct {
+	tagPattern     string
+	uploadedBefore time.Time
+	uploadedAfter  time.Time
+	untaggedOnly   bool
+}
+
+func (f *filters) keepTag(tag string) bool {
+	if f.tagPattern == "" {
+		return true
+	}
+	// The pattern was validated by WithTagFilter.
+	ok, _ := path.Match(f.tagPattern, tag)
+	return ok
+}
+
+func (f *filters) keepManifest(info ManifestInfo) bool {
+	if !f.uploadedBefore.IsZero() && !info.Uploaded.Before(f.uploadedBefore) {
+		return false
+	}
+	if !f.uploadedAfter.IsZero() && !info.Uploaded.After(f.uploadedAfter) {
+		return false
+	}
+	if f.untaggedOnly {
+		return len(info.Tags) == 0
+	}
+	if f.tagPattern == "" {
+		return true
+	}
+	for _, tag := range info.Tags {
+		if f.keepTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *filters) filterTags(tags []string) []string {
+	if f.tagPattern == "" {
+		return tags
+	}
+	kept := []string{}
+	for _, tag := range tags {
+		if f.keepTag(tag) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+// decode reads a tags/list response for repo, filtering manifests as they're
+// read rather than after buffering all of them, and passing them to
+// l.manifestFn instead of keeping them if it's set. It also returns whether
+// the response looked like it came from GCR.
+func (l *lister) decode(repo name.Repository, r io.Reader) (*Tags, bool, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, false, err
+	}
+
+	tags := &Tags{}
+	seen := 0
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false, err
+		}
+		switch tok {
+		case "manifest":
+			if seen, err = l.decodeManifests(repo, dec, tags); err != nil {
+				return nil, false, err
+			}
+		case "child":
+			err = dec.Decode(&tags.Children)
+		case "name":
+			err = dec.Decode(&tags.Name)
+		case "tags":
+			err = dec.Decode(&tags.Tags)
+		default:
+			var ignored json.RawMessage
+			err = dec.Decode(&ignored)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, false, err
+	}
+
+	tags.Tags = l.filters.filterTags(tags.Tags)
+	return tags, seen != 0 || len(tags.Children) != 0, nil
+}
+
+// decodeManifests reads the "manifest" object of a GCR response one entry at a
+// time, returning how many entries it saw before filtering.
+func (l *lister) decodeManifests(repo name.Repository, dec *json.Decoder, tags *Tags) (int, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, err
+	}
+	if tok == nil {
+		return 0, nil
+	}
+	if tok != json.Delim('{') {
+		return 0, fmt.Errorf("unexpected token in manifest: %v", tok)
+	}
+	if l.manifestFn == nil {
+		tags.Manifests = map[string]ManifestInfo{}
+	}
+
+	seen := 0
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, err
+		}
+		digest, ok := tok.(string)
+		if !ok {
+			return 0, fmt.Errorf("unexpected token in manifest: %v", tok)
+		}
+		info := ManifestInfo{}
+		if err := dec.Decode(&info); err != nil {
+			return 0, err
+		}
+		seen++
+
+		if !l.filters.keepManifest(info) {
+			continue
+		}
+		if l.manifestFn != nil {
+			if err := l.manifestFn(repo, digest, info); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		tags.Manifests[digest] = info
+	}
+	return seen, expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("unexpected token: got %v, want %v", tok, want)
+	}
+	return nil
+}
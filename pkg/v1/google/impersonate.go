@@ -0,0 +1,161 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"golang.org/x/oauth2"
+)
+
+// impersonateEnv names the environment variable that configures Keychain to
+// impersonate a service account. Like gcloud's --impersonate-service-account,
+// it may be a comma-separated delegation chain, ending with the target.
+const impersonateEnv = "GOOGLE_IMPERSONATE_SERVICE_ACCOUNT"
+
+const iamCredentialsEndpoint = "https://iamcredentials.googleapis.com"
+
+// NewImpersonatingKeychain returns a keychain that behaves like Keychain,
+// except that the credentials it finds are used to generate access tokens
+// for serviceAccount, e.g. "deployer@my-project.iam.gserviceaccount.com".
+// If given, each of delegates must be able to impersonate the next, and the
+// last must be able to impersonate serviceAccount.
+//
+// The found credentials need the roles/iam.serviceAccountTokenCreator role
+// on serviceAccount (or the first delegate), but not its key.
+func NewImpersonatingKeychain(serviceAccount string, delegates ...string) authn.Keychain {
+	return NewKeychain(WithImpersonation(serviceAccount, delegates...))
+}
+
+// NewImpersonatingAuthenticator returns an authn.Authenticator that uses
+// tokens from base to generate access tokens for serviceAccount with the IAM
+// Credentials API, reusing them until they expire. Delegates are as in
+// NewImpersonatingKeychain.
+func NewImpersonatingAuthenticator(base oauth2.TokenSource, serviceAccount string, delegates ...string) authn.Authenticator {
+	return NewTokenSourceAuthenticator(oauth2.ReuseTokenSource(nil, &impersonatedSource{
+		base:      base,
+		target:    serviceAccount,
+		delegates: delegates,
+		endpoint:  iamCredentialsEndpoint,
+		transport: http.DefaultTransport,
+	}))
+}
+
+// impersonationChain parses the value of impersonateEnv.
+func impersonationChain(s string) []string {
+	var chain []string
+	for _, sa := range strings.Split(s, ",") {
+		if sa = strings.TrimSpace(sa); sa != "" {
+			chain = append(chain, sa)
+		}
+	}
+	return chain
+}
+
+// impersonate returns an authenticator for the last service account in
+// chain, using auth's tokens. Only authenticators that wrap token sources,
+// like those from NewEnvAuthenticator and NewGcloudAuthenticator, can be used
+// for impersonation. If t is nil, http.DefaultTransport is used.
+func impersonate(auth authn.Authenticator, chain []string, t http.RoundTripper) (authn.Authenticator, error) {
+	tsa, ok := auth.(*tokenSourceAuth)
+	if !ok {
+		return nil, fmt.Errorf("no Google credentials to impersonate %s with", chain[len(chain)-1])
+	}
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	return NewTokenSourceAuthenticator(oauth2.ReuseTokenSource(nil, &impersonatedSource{
+		base:      tsa.TokenSource,
+		target:    chain[len(chain)-1],
+		delegates: chain[:len(chain)-1],
+		endpoint:  iamCredentialsEndpoint,
+		transport: t,
+	})), nil
+}
+
+// impersonatedSource is an oauth2.TokenSource that generates access tokens
+// for target.
+type impersonatedSource struct {
+	base      oauth2.TokenSource
+	target    string
+	delegates []string
+
+	endpoint  string
+	transport http.RoundTripper
+}
+
+// Token implements oauth2.TokenSource.
+func (is *impersonatedSource) Token() (*oauth2.Token, error) {
+	delegates := make([]string, 0, len(is.delegates))
+	for _, d := range is.delegates {
+		delegates = append(delegates, "projects/-/serviceAccounts/"+d)
+	}
+	body, err := json.Marshal(struct {
+		Delegates []string `json:"delegates,omitempty"`
+		Scope     []string `json:"scope"`
+		Lifetime  string   `json:"lifetime"`
+	}{
+		Delegates: delegates,
+		Scope:     []string{cloudPlatformScope},
+		Lifetime:  "3600s",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%s/v1/projects/-/serviceAccounts/%s:generateAccessToken", is.endpoint, url.PathEscape(is.target))
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := oauth2.NewClient(context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: is.transport}), is.base)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("impersonating %s: %w", is.target, err)
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("impersonating %s: %w", is.target, err)
+	}
+
+	var out struct {
+		AccessToken string `json:"accessToken"`
+		ExpireTime  string `json:"expireTime"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("impersonating %s: %w", is.target, err)
+	}
+	expiry, err := time.Parse(time.RFC3339, out.ExpireTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse impersonated token expiry: %w", err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: out.AccessToken,
+		Expiry:      expiry,
+	}, nil
+}
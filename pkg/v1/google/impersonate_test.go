@@ -0,0 +1,120 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"golang.org/x/oauth2"
+)
+
+func TestImpersonatedSource(t *testing.T) {
+	expiry := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if got, want := r.URL.Path, "/v1/projects/-/serviceAccounts/deployer@p.iam.gserviceaccount.com:generateAccessToken"; got != want {
+			t.Errorf("path = %q, want %q", got, want)
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer base"; got != want {
+			t.Errorf("Authorization = %q, want %q", got, want)
+		}
+		var in struct {
+			Delegates []string `json:"delegates"`
+			Scope     []string `json:"scope"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"projects/-/serviceAccounts/ci@p.iam.gserviceaccount.com"}, in.Delegates); diff != "" {
+			t.Errorf("delegates (-want +got) = %s", diff)
+		}
+		if diff := cmp.Diff([]string{cloudPlatformScope}, in.Scope); diff != "" {
+			t.Errorf("scope (-want +got) = %s", diff)
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"accessToken": "impersonated",
+			"expireTime":  expiry.Format(time.RFC3339),
+		})
+	}))
+	defer s.Close()
+
+	auth := NewTokenSourceAuthenticator(oauth2.ReuseTokenSource(nil, &impersonatedSource{
+		base:      oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "base"}),
+		target:    "deployer@p.iam.gserviceaccount.com",
+		delegates: []string{"ci@p.iam.gserviceaccount.com"},
+		endpoint:  s.URL,
+		transport: s.Client().Transport,
+	}))
+
+	// The token is reused until it expires.
+	for i := 0; i < 2; i++ {
+		cfg, err := auth.Authorization()
+		if err != nil {
+			t.Fatalf("Authorization() = %v", err)
+		}
+		if cfg.Username != "_token" || cfg.Password != "impersonated" {
+			t.Errorf("Authorization() = %+v", cfg)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+}
+
+func TestImpersonatedSourceError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer s.Close()
+
+	is := &impersonatedSource{
+		base:      oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "base"}),
+		target:    "deployer@p.iam.gserviceaccount.com",
+		endpoint:  s.URL,
+		transport: s.Client().Transport,
+	}
+	if _, err := is.Token(); err == nil {
+		t.Error("Token() succeeded, want error")
+	}
+}
+
+func TestImpersonate(t *testing.T) {
+	if diff := cmp.Diff([]string{"ci@p", "deployer@p"}, impersonationChain(" ci@p, deployer@p,")); diff != "" {
+		t.Errorf("impersonationChain() (-want +got) = %s", diff)
+	}
+	if got := impersonationChain(""); got != nil {
+		t.Errorf("impersonationChain(\"\") = %v, want nil", got)
+	}
+
+	if _, err := impersonate(authn.Anonymous, []string{"deployer@p"}, nil); err == nil {
+		t.Error("impersonate(Anonymous) succeeded, want error")
+	}
+	base := NewTokenSourceAuthenticator(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "base"}))
+	if _, err := impersonate(base, []string{"ci@p", "deployer@p"}, nil); err != nil {
+		t.Errorf("impersonate() = %v", err)
+	}
+
+	kc := NewImpersonatingKeychain("deployer@p", "ci@p").(*googleKeychain)
+	if diff := cmp.Diff([]string{"ci@p", "deployer@p"}, kc.impersonate); diff != "" {
+		t.Errorf("NewImpersonatingKeychain() chain (-want +got) = %s", diff)
+	}
+}
@@ -15,19 +15,100 @@
 package google
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
 	"strings"
 	"sync"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/logs"
+	"golang.org/x/oauth2"
+	googauth "golang.org/x/oauth2/google"
 )
 
 // Keychain exports an instance of the google Keychain.
+//
+// It uses Application Default Credentials, which may be a service account
+// key, user credentials, an external account (workload identity federation)
+// configuration or the metadata server, or else gcloud.
+//
+// If GOOGLE_IMPERSONATE_SERVICE_ACCOUNT is set, it impersonates that service
+// account, as with NewImpersonatingKeychain. Like gcloud's
+// --impersonate-service-account flag, it may be a comma-separated delegation
+// chain ending with the service account to impersonate.
 var Keychain authn.Keychain = &googleKeychain{}
 
+// KeychainOption is a functional option for NewKeychain.
+type KeychainOption func(*googleKeychain)
+
+// NewKeychain returns a keychain that behaves like Keychain, except where
+// opts configure it otherwise, e.g. to use a workload identity federation
+// configuration that isn't GOOGLE_APPLICATION_CREDENTIALS.
+func NewKeychain(opts ...KeychainOption) authn.Keychain {
+	gk := &googleKeychain{}
+	for _, opt := range opts {
+		opt(gk)
+	}
+	return gk
+}
+
+// WithCredentialsJSON authenticates with the given credentials instead of
+// Application Default Credentials. They may be anything
+// GOOGLE_APPLICATION_CREDENTIALS can point to: a service account key, user
+// credentials or an external account configuration, as generated by
+// "gcloud iam workload-identity-pools create-cred-config", that exchanges
+// AWS, Azure, OIDC or SAML credentials for Google access tokens.
+func WithCredentialsJSON(b []byte) KeychainOption {
+	return func(gk *googleKeychain) {
+		gk.credentials = func() ([]byte, error) { return b, nil }
+	}
+}
+
+// WithCredentialsFile is like WithCredentialsJSON, but reads the credentials
+// from path when they're first needed.
+func WithCredentialsFile(path string) KeychainOption {
+	return func(gk *googleKeychain) {
+		gk.credentials = func() ([]byte, error) { return ioutil.ReadFile(path) }
+	}
+}
+
+// WithTokenSource authenticates with tokens from ts instead of Application
+// Default Credentials.
+func WithTokenSource(ts oauth2.TokenSource) KeychainOption {
+	return func(gk *googleKeychain) {
+		gk.tokenSource = ts
+	}
+}
+
+// WithImpersonation impersonates serviceAccount, as in
+// NewImpersonatingKeychain, instead of any service account named by
+// GOOGLE_IMPERSONATE_SERVICE_ACCOUNT.
+func WithImpersonation(serviceAccount string, delegates ...string) KeychainOption {
+	return func(gk *googleKeychain) {
+		gk.impersonate = append(append([]string{}, delegates...), serviceAccount)
+	}
+}
+
 type googleKeychain struct {
 	once sync.Once
 	auth authn.Authenticator
+	err  error
+
+	// credentials returns the JSON credentials to use, if set.
+	credentials func() ([]byte, error)
+
+	// tokenSource is used instead of finding credentials, if set.
+	tokenSource oauth2.TokenSource
+
+	// impersonate is a delegation chain, ending with the service account to
+	// impersonate. If nil, it's read from impersonateEnv.
+	impersonate []string
+
+	// transport is used to get and impersonate tokens, for testing.
+	transport http.RoundTripper
 }
 
 // Resolve implements authn.Keychain a la docker-credential-gcr.
@@ -62,10 +143,46 @@ func (gk *googleKeychain) Resolve(target authn.Resource) (authn.Authenticator, e
 	}
 
 	gk.once.Do(func() {
-		gk.auth = resolve()
+		gk.auth, gk.err = gk.resolve()
+		if gk.err != nil {
+			return
+		}
+
+		chain := gk.impersonate
+		if chain == nil {
+			chain = impersonationChain(os.Getenv(impersonateEnv))
+		}
+		if len(chain) != 0 {
+			gk.auth, gk.err = impersonate(gk.auth, chain, gk.transport)
+		}
 	})
 
-	return gk.auth, nil
+	return gk.auth, gk.err
+}
+
+// resolve returns an authenticator for the configured credentials, falling
+// back to those found in the environment.
+func (gk *googleKeychain) resolve() (authn.Authenticator, error) {
+	if gk.tokenSource != nil {
+		return NewTokenSourceAuthenticator(gk.tokenSource), nil
+	}
+	if gk.credentials == nil {
+		return resolve(), nil
+	}
+
+	b, err := gk.credentials()
+	if err != nil {
+		return nil, fmt.Errorf("reading Google credentials: %w", err)
+	}
+	ctx := context.Background()
+	if gk.transport != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: gk.transport})
+	}
+	creds, err := googauth.CredentialsFromJSON(ctx, b, cloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Google credentials: %w", err)
+	}
+	return NewTokenSourceAuthenticator(oauth2.ReuseTokenSource(nil, creds.TokenSource)), nil
 }
 
 func resolve() authn.Authenticator {
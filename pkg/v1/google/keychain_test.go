@@ -0,0 +1,186 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"golang.org/x/oauth2"
+)
+
+// fakeGoogle serves the STS and IAM Credentials APIs.
+type fakeGoogle struct {
+	t     *testing.T
+	calls []string
+}
+
+func (f *fakeGoogle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.calls = append(f.calls, r.Host+r.URL.Path)
+	expiry := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	switch {
+	case r.Host == "sts.googleapis.com" && r.URL.Path == "/v1/token":
+		if got, want := r.FormValue("subject_token"), "oidc"; got != want {
+			f.t.Errorf("subject_token = %q, want %q", got, want)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":      "federated",
+			"issued_token_type": "urn:ietf:params:oauth:token-type:access_token",
+			"token_type":        "Bearer",
+			"expires_in":        3600,
+		})
+	case r.Host == "iamcredentials.googleapis.com" && strings.HasSuffix(r.URL.Path, ":generateAccessToken"):
+		// Each service account's token is the previous token with its name.
+		sa := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/projects/-/serviceAccounts/"), ":generateAccessToken")
+		base := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		json.NewEncoder(w).Encode(map[string]string{
+			"accessToken": base + "/" + sa,
+			"expireTime":  expiry,
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// redirect sends every request to the test server.
+type redirect struct {
+	host string
+	rt   http.RoundTripper
+}
+
+func (r *redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Host = req.URL.Host
+	req.URL.Scheme = "http"
+	req.URL.Host = r.host
+	return r.rt.RoundTrip(req)
+}
+
+func externalAccountJSON(t *testing.T, impersonate string) []byte {
+	t.Helper()
+	token := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(token, []byte("oidc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := map[string]interface{}{
+		"type":               "external_account",
+		"audience":           "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/pool/providers/provider",
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url":          "https://sts.googleapis.com/v1/token",
+		"credential_source":  map[string]string{"file": token},
+	}
+	if impersonate != "" {
+		cfg["service_account_impersonation_url"] = fmt.Sprintf("https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken", impersonate)
+	}
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestKeychainExternalAccount(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		impersonate string
+		opts        []KeychainOption
+		want        string
+	}{{
+		name: "federated",
+		want: "federated",
+	}, {
+		name:        "impersonation url",
+		impersonate: "sa@p.iam.gserviceaccount.com",
+		want:        "federated/sa@p.iam.gserviceaccount.com",
+	}, {
+		name: "explicit impersonation",
+		opts: []KeychainOption{WithImpersonation("deployer@p.iam.gserviceaccount.com", "ci@p.iam.gserviceaccount.com")},
+		want: "federated/deployer@p.iam.gserviceaccount.com",
+	}, {
+		name:        "both",
+		impersonate: "sa@p.iam.gserviceaccount.com",
+		opts:        []KeychainOption{WithImpersonation("deployer@p.iam.gserviceaccount.com")},
+		want:        "federated/sa@p.iam.gserviceaccount.com/deployer@p.iam.gserviceaccount.com",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &fakeGoogle{t: t}
+			s := httptest.NewServer(f)
+			defer s.Close()
+
+			path := filepath.Join(t.TempDir(), "creds.json")
+			if err := os.WriteFile(path, externalAccountJSON(t, tc.impersonate), 0600); err != nil {
+				t.Fatal(err)
+			}
+			kc := NewKeychain(append(tc.opts, WithCredentialsFile(path))...).(*googleKeychain)
+			kc.transport = &redirect{host: s.Listener.Addr().String(), rt: s.Client().Transport}
+
+			for i := 0; i < 2; i++ {
+				auth, err := kc.Resolve(mustRegistry("us-docker.pkg.dev"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				cfg, err := auth.Authorization()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cfg.Username != "_token" || cfg.Password != tc.want {
+					t.Errorf("Authorization() = %+v, want password %q", cfg, tc.want)
+				}
+			}
+			// Tokens are reused until they expire.
+			if got, want := len(f.calls), 1+strings.Count(tc.want, "/"); got != want {
+				t.Errorf("got calls %v, want %d", f.calls, want)
+			}
+		})
+	}
+}
+
+func TestKeychainOptions(t *testing.T) {
+	auth, err := NewKeychain(WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "static"}))).Resolve(mustRegistry("gcr.io"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Password != "static" {
+		t.Errorf("Authorization() = %+v, want password static", cfg)
+	}
+
+	// Other registries don't need credentials.
+	if auth, err := NewKeychain(WithCredentialsJSON([]byte("{"))).Resolve(mustRegistry("docker.io")); err != nil || auth != authn.Anonymous {
+		t.Errorf("Resolve(docker.io) = %v, %v, want Anonymous", auth, err)
+	}
+
+	// Explicit credentials don't fall back to Anonymous.
+	for _, opt := range []KeychainOption{
+		WithCredentialsJSON([]byte("{")),
+		WithCredentialsJSON([]byte(`{"type":"nonsense"}`)),
+		WithCredentialsFile(filepath.Join(t.TempDir(), "missing.json")),
+	} {
+		if _, err := NewKeychain(opt).Resolve(mustRegistry("gcr.io")); err == nil {
+			t.Error("Resolve() with bad credentials succeeded, want error")
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -28,6 +29,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 // Option is a functional option for List and Walk.
@@ -41,6 +43,12 @@ type lister struct {
 	client    *http.Client
 	ctx       context.Context
 	userAgent string
+
+	filters    filters
+	manifestFn ManifestFunc
+
+	// endpoint is the base URL of Google APIs, for newAPILister.
+	endpoint string
 }
 
 func newLister(repo name.Repository, options ...Option) (*lister, error) {
@@ -57,6 +65,21 @@ func newLister(repo name.Repository, options ...Option) (*lister, error) {
 		}
 	}
 
+	l.wrapTransport()
+
+	scopes := []string{repo.Scope(transport.PullScope)}
+	tr, err := transport.NewWithContext(l.ctx, repo.Registry, l.auth, l.transport, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	l.client = &http.Client{Transport: tr}
+
+	return l, nil
+}
+
+// wrapTransport adds logging, retries and the user agent to l.transport.
+func (l *lister) wrapTransport() {
 	// transport.Wrapper is a signal that consumers are opt-ing into providing their own transport without any additional wrapping.
 	// This is to allow consumers full control over the transports logic, such as providing retry logic.
 	if _, ok := l.transport.(*transport.Wrapper); !ok {
@@ -75,16 +98,6 @@ func newLister(repo name.Repository, options ...Option) (*lister, error) {
 			l.transport = transport.NewUserAgent(l.transport, l.userAgent)
 		}
 	}
-
-	scopes := []string{repo.Scope(transport.PullScope)}
-	tr, err := transport.NewWithContext(l.ctx, repo.Registry, l.auth, l.transport, scopes)
-	if err != nil {
-		return nil, err
-	}
-
-	l.client = &http.Client{Transport: tr}
-
-	return l, nil
 }
 
 func (l *lister) list(repo name.Repository) (*Tags, error) {
@@ -122,8 +135,9 @@ func (l *lister) list(repo name.Repository) (*Tags, error) {
 			return nil, err
 		}
 
-		parsed := Tags{}
-		if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		parsed, gcr, err := l.decode(repo, resp.Body)
+		if err != nil {
+			resp.Body.Close()
 			return nil, err
 		}
 
@@ -131,9 +145,9 @@ func (l *lister) list(repo name.Repository) (*Tags, error) {
 			return nil, err
 		}
 
-		if len(parsed.Manifests) != 0 || len(parsed.Children) != 0 {
+		if gcr {
 			// We're dealing with GCR, just return directly.
-			return &parsed, nil
+			return parsed, nil
 		}
 
 		// This isn't GCR, just append the tags and keep paginating.
@@ -185,6 +199,7 @@ func getNextPageURL(resp *http.Response) (*url.URL, error) {
 
 type rawManifestInfo struct {
 	Size      string   `json:"imageSizeBytes"`
+	LayerID   string   `json:"layerId,omitempty"`
 	MediaType string   `json:"mediaType"`
 	Created   string   `json:"timeCreatedMs"`
 	Uploaded  string   `json:"timeUploadedMs"`
@@ -192,12 +207,35 @@ type rawManifestInfo struct {
 }
 
 // ManifestInfo is a Manifests entry is the output of List and Walk.
+//
+// GCR and AR report these as strings; they're parsed so that callers, e.g.
+// retention policies, can use them without fetching each manifest.
 type ManifestInfo struct {
-	Size      uint64    `json:"imageSizeBytes"`
-	MediaType string    `json:"mediaType"`
-	Created   time.Time `json:"timeCreatedMs"`
-	Uploaded  time.Time `json:"timeUploadedMs"`
-	Tags      []string  `json:"tag"`
+	// Size is the total size of the image's config and layers in bytes. It's
+	// zero for indexes.
+	Size uint64 `json:"imageSizeBytes"`
+
+	// LayerID is the legacy v1 ID of the image's top layer, if any.
+	LayerID string `json:"layerId"`
+
+	MediaType types.MediaType `json:"mediaType"`
+
+	// Created is the image's creation time from its config, and Uploaded is
+	// when the manifest was pushed.
+	Created  time.Time `json:"timeCreatedMs"`
+	Uploaded time.Time `json:"timeUploadedMs"`
+
+	Tags []string `json:"tag"`
+}
+
+// Tagged returns true if any tags point to the manifest.
+func (m ManifestInfo) Tagged() bool {
+	return len(m.Tags) != 0
+}
+
+// Age returns how long before now the manifest was uploaded.
+func (m ManifestInfo) Age(now time.Time) time.Duration {
+	return now.Sub(m.Uploaded)
 }
 
 func fromUnixMs(ms int64) time.Time {
@@ -214,7 +252,8 @@ func toUnixMs(t time.Time) string {
 func (m ManifestInfo) MarshalJSON() ([]byte, error) {
 	return json.Marshal(rawManifestInfo{
 		Size:      strconv.FormatUint(m.Size, 10),
-		MediaType: m.MediaType,
+		LayerID:   m.LayerID,
+		MediaType: string(m.MediaType),
 		Created:   toUnixMs(m.Created),
 		Uploaded:  toUnixMs(m.Uploaded),
 		Tags:      m.Tags,
@@ -252,7 +291,8 @@ func (m *ManifestInfo) UnmarshalJSON(data []byte) error {
 		m.Uploaded = fromUnixMs(uploaded)
 	}
 
-	m.MediaType = raw.MediaType
+	m.LayerID = raw.LayerID
+	m.MediaType = types.MediaType(raw.MediaType)
 	m.Tags = raw.Tags
 
 	return nil
@@ -266,7 +306,33 @@ type Tags struct {
 	Tags      []string                `json:"tags"`
 }
 
+// Manifest is an entry of Tags.Manifests, with its digest.
+type Manifest struct {
+	Digest string
+	ManifestInfo
+}
+
+// History returns the manifests in t from the most to the least recently
+// uploaded, breaking ties by digest. Registries don't record which manifests
+// a tag used to point to, so this is the closest there is to a tag history.
+func (t *Tags) History() []Manifest {
+	ms := make([]Manifest, 0, len(t.Manifests))
+	for digest, info := range t.Manifests {
+		ms = append(ms, Manifest{Digest: digest, ManifestInfo: info})
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].Uploaded.Equal(ms[j].Uploaded) {
+			return ms[i].Digest < ms[j].Digest
+		}
+		return ms[i].Uploaded.After(ms[j].Uploaded)
+	})
+	return ms
+}
+
 // List calls /tags/list for the given repository.
+//
+// Neither GCR nor the registry API understand filters, so options like
+// WithTagFilter and WithUntaggedOnly are applied as the response is read.
 func List(repo name.Repository, options ...Option) (*Tags, error) {
 	l, err := newLister(repo, options...)
 	if err != nil {
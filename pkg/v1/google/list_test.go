@@ -43,6 +43,7 @@ func mustParseDuration(t *testing.T, d string) time.Duration {
 func TestRoundtrip(t *testing.T) {
 	raw := rawManifestInfo{
 		Size:      "100",
+		LayerID:   "abc",
 		MediaType: "hi",
 		Created:   "12345678",
 		Uploaded:  "23456789",
@@ -69,6 +70,35 @@ func TestRoundtrip(t *testing.T) {
 	}
 }
 
+func TestHistory(t *testing.T) {
+	now := time.Now()
+	tags := &Tags{
+		Manifests: map[string]ManifestInfo{
+			"sha256:old":    {Uploaded: now.Add(-48 * time.Hour), Tags: []string{"v1"}},
+			"sha256:new":    {Uploaded: now.Add(-time.Hour)},
+			"sha256:tied-b": {Uploaded: now.Add(-24 * time.Hour)},
+			"sha256:tied-a": {Uploaded: now.Add(-24 * time.Hour)},
+		},
+	}
+
+	var got []string
+	for _, m := range tags.History() {
+		got = append(got, m.Digest)
+	}
+	want := []string{"sha256:new", "sha256:tied-a", "sha256:tied-b", "sha256:old"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("History() (-want +got) = %s", diff)
+	}
+
+	old := tags.Manifests["sha256:old"]
+	if !old.Tagged() || tags.Manifests["sha256:new"].Tagged() {
+		t.Error("Tagged() is wrong")
+	}
+	if got, want := old.Age(now), 48*time.Hour; got != want {
+		t.Errorf("Age() = %v, want %v", got, want)
+	}
+}
+
 func TestList(t *testing.T) {
 	cases := []struct {
 		name         string
@@ -337,3 +367,113 @@ func TestGetNextPageURL(t *testing.T) {
 		t.Errorf("expected scheme to match request, got %s", u.Scheme)
 	}
 }
+
+func TestListFilters(t *testing.T) {
+	body := []byte(`{"child":["hello"],"manifest":{` +
+		`"digest1":{"imageSizeBytes":"1","timeUploadedMs":"1000","tag":["v1.0","latest"]},` +
+		`"digest2":{"imageSizeBytes":"2","timeUploadedMs":"2000","tag":["v2.0"]},` +
+		`"digest3":{"imageSizeBytes":"3","timeUploadedMs":"3000"}},` +
+		`"name":"ubuntu","tags":["latest","v1.0","v2.0"]}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/ubuntu/tags/list":
+			w.Write(body)
+		default:
+			t.Fatalf("Unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+	repo, err := name.NewRepository(fmt.Sprintf("%s/ubuntu", u.Host), name.WeakValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := func(m map[string]ManifestInfo) []string {
+		ks := []string{}
+		for _, k := range []string{"digest1", "digest2", "digest3"} {
+			if _, ok := m[k]; ok {
+				ks = append(ks, k)
+			}
+		}
+		return ks
+	}
+
+	for _, tc := range []struct {
+		name     string
+		opts     []Option
+		wantMfs  []string
+		wantTags []string
+	}{{
+		name:     "none",
+		wantMfs:  []string{"digest1", "digest2", "digest3"},
+		wantTags: []string{"latest", "v1.0", "v2.0"},
+	}, {
+		name:     "tag pattern",
+		opts:     []Option{WithTagFilter("v1.*")},
+		wantMfs:  []string{"digest1"},
+		wantTags: []string{"v1.0"},
+	}, {
+		name:     "untagged",
+		opts:     []Option{WithUntaggedOnly()},
+		wantMfs:  []string{"digest3"},
+		wantTags: []string{"latest", "v1.0", "v2.0"},
+	}, {
+		name:     "uploaded",
+		opts:     []Option{WithUploadedAfter(time.Unix(1, 0)), WithUploadedBefore(time.Unix(3, 0))},
+		wantMfs:  []string{"digest2"},
+		wantTags: []string{"latest", "v1.0", "v2.0"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			tags, err := List(repo, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.wantMfs, keys(tags.Manifests)); diff != "" {
+				t.Errorf("List() wrong manifests (-want +got) = %s", diff)
+			}
+			if diff := cmp.Diff(tc.wantTags, tags.Tags); diff != "" {
+				t.Errorf("List() wrong tags (-want +got) = %s", diff)
+			}
+			if diff := cmp.Diff([]string{"hello"}, tags.Children); diff != "" {
+				t.Errorf("List() wrong children (-want +got) = %s", diff)
+			}
+		})
+	}
+
+	t.Run("manifest func", func(t *testing.T) {
+		got := []string{}
+		tags, err := List(repo, WithUploadedBefore(time.Unix(3, 0)), WithManifestFunc(func(r name.Repository, digest string, info ManifestInfo) error {
+			if r != repo {
+				t.Errorf("repo = %v, want %v", r, repo)
+			}
+			got = append(got, digest)
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"digest1", "digest2"}, got); diff != "" {
+			t.Errorf("ManifestFunc wrong digests (-want +got) = %s", diff)
+		}
+		if tags.Manifests != nil {
+			t.Errorf("Manifests = %v, want nil", tags.Manifests)
+		}
+
+		stop := fmt.Errorf("stop")
+		if _, err := List(repo, WithManifestFunc(func(name.Repository, string, ManifestInfo) error {
+			return stop
+		})); err != stop {
+			t.Errorf("List() = %v, want %v", err, stop)
+		}
+	})
+
+	if _, err := List(repo, WithTagFilter("[")); err == nil {
+		t.Error("WithTagFilter accepted an invalid pattern")
+	}
+}
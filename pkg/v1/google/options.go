@@ -16,7 +16,10 @@ package google
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"path"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 )
@@ -71,3 +74,51 @@ func WithUserAgent(ua string) Option {
 		return nil
 	}
 }
+
+// WithTagFilter only lists tags, and manifests with at least one tag, that
+// match the given path.Match pattern, e.g. "v1.*".
+func WithTagFilter(pattern string) Option {
+	return func(l *lister) error {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid tag filter %q: %w", pattern, err)
+		}
+		l.filters.tagPattern = pattern
+		return nil
+	}
+}
+
+// WithUploadedBefore only lists manifests that were uploaded before t.
+func WithUploadedBefore(t time.Time) Option {
+	return func(l *lister) error {
+		l.filters.uploadedBefore = t
+		return nil
+	}
+}
+
+// WithUploadedAfter only lists manifests that were uploaded after t.
+func WithUploadedAfter(t time.Time) Option {
+	return func(l *lister) error {
+		l.filters.uploadedAfter = t
+		return nil
+	}
+}
+
+// WithUntaggedOnly only lists manifests that have no tags, e.g. to find
+// candidates for garbage collection.
+func WithUntaggedOnly() Option {
+	return func(l *lister) error {
+		l.filters.untaggedOnly = true
+		return nil
+	}
+}
+
+// WithManifestFunc calls f for each manifest as it's read from the response,
+// instead of collecting them in Tags.Manifests. This keeps memory bounded when
+// listing huge repositories. If f returns an error, listing stops and returns
+// it.
+func WithManifestFunc(f ManifestFunc) Option {
+	return func(l *lister) error {
+		l.manifestFn = f
+		return nil
+	}
+}
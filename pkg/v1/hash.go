@@ -15,7 +15,6 @@
 package v1
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -23,6 +22,8 @@ import (
 	"io"
 	"strconv"
 	"strings"
+
+	"github.com/google/go-containerregistry/internal/sha256"
 )
 
 // Hash is an unqualified digest of some content, e.g. sha256:deadbeef
@@ -110,6 +111,10 @@ func (h *Hash) parse(unquoted string) error {
 }
 
 // SHA256 computes the Hash of the provided io.Reader's content.
+//
+// This uses crypto/sha256, which uses the CPU's SHA extensions (SHA-NI on
+// amd64, SHA2 on arm64) when they're available, or, when built with the
+// sha256simd tag, github.com/minio/sha256-simd.
 func SHA256(r io.Reader) (Hash, int64, error) {
 	hasher := sha256.New()
 	n, err := io.Copy(hasher, r)
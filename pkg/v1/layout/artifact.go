@@ -0,0 +1,82 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+)
+
+// Artifact reads an OCI artifact with the given manifest digest from the
+// layout.
+func (l Path) Artifact(h v1.Hash) (v1.Artifact, error) {
+	img, err := l.Image(h)
+	if err != nil {
+		return nil, err
+	}
+	return artifact.FromImage(img), nil
+}
+
+// WriteArtifact writes an artifact's manifest and blobs to the layout, without
+// updating the index.json.
+func (l Path) WriteArtifact(a v1.Artifact) error {
+	img, err := artifact.Image(a)
+	if err != nil {
+		return err
+	}
+	return l.WriteImage(img)
+}
+
+// AppendArtifact writes an artifact to the Path and updates the index.json to
+// reference it, with the artifact's type as the descriptor's artifactType.
+func (l Path) AppendArtifact(a v1.Artifact, options ...Option) error {
+	if err := l.WriteArtifact(a); err != nil {
+		return err
+	}
+
+	mt, err := a.MediaType()
+	if err != nil {
+		return err
+	}
+
+	d, err := a.Digest()
+	if err != nil {
+		return err
+	}
+
+	size, err := a.Size()
+	if err != nil {
+		return err
+	}
+
+	at, err := a.ArtifactType()
+	if err != nil {
+		return err
+	}
+
+	desc := v1.Descriptor{
+		MediaType:    mt,
+		Size:         size,
+		Digest:       d,
+		ArtifactType: at,
+	}
+
+	o := makeOptions(options...)
+	for _, opt := range o.descOpts {
+		opt(&desc)
+	}
+
+	return l.AppendDescriptor(desc)
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+)
+
+func TestArtifact(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	a, err := artifact.New("application/vnd.example.policy.v1",
+		artifact.WithBlobs(static.NewLayer([]byte("allow = true"), "application/vnd.example.policy.rego")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendArtifact(a); err != nil {
+		t.Fatalf("AppendArtifact() = %v", err)
+	}
+
+	ii, err := l.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Manifests) != 1 || m.Manifests[0].ArtifactType != "application/vnd.example.policy.v1" {
+		t.Fatalf("index.json manifests = %+v, want the artifact", m.Manifests)
+	}
+
+	got, err := l.Artifact(m.Manifests[0].Digest)
+	if err != nil {
+		t.Fatalf("Artifact() = %v", err)
+	}
+	blobs, err := got.Blobs()
+	if err != nil || len(blobs) != 1 {
+		t.Fatalf("Blobs() = %d, %v, want 1", len(blobs), err)
+	}
+	rc, err := blobs[0].Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if b, err := ioutil.ReadAll(rc); err != nil || string(b) != "allow = true" {
+		t.Errorf("blob = %q, %v", b, err)
+	}
+	if _, err := got.Config(); err != nil {
+		t.Errorf("Config() = %v", err)
+	}
+}
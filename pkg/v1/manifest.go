@@ -16,7 +16,9 @@ package v1
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -28,6 +30,8 @@ type Manifest struct {
 	Config        Descriptor        `json:"config"`
 	Layers        []Descriptor      `json:"layers"`
 	Annotations   map[string]string `json:"annotations,omitempty"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
 }
 
 // IndexManifest represents an OCI image index in a structured way.
@@ -36,17 +40,20 @@ type IndexManifest struct {
 	MediaType     types.MediaType   `json:"mediaType,omitempty"`
 	Manifests     []Descriptor      `json:"manifests"`
 	Annotations   map[string]string `json:"annotations,omitempty"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
 }
 
 // Descriptor holds a reference from the manifest to one of its constituent elements.
 type Descriptor struct {
-	MediaType   types.MediaType   `json:"mediaType"`
-	Size        int64             `json:"size"`
-	Digest      Hash              `json:"digest"`
-	Data        []byte            `json:"data,omitempty"`
-	URLs        []string          `json:"urls,omitempty"`
-	Annotations map[string]string `json:"annotations,omitempty"`
-	Platform    *Platform         `json:"platform,omitempty"`
+	MediaType    types.MediaType   `json:"mediaType"`
+	Size         int64             `json:"size"`
+	Digest       Hash              `json:"digest"`
+	Data         []byte            `json:"data,omitempty"`
+	URLs         []string          `json:"urls,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Platform     *Platform         `json:"platform,omitempty"`
+	ArtifactType string            `json:"artifactType,omitempty"`
 }
 
 // ParseManifest parses the io.Reader's contents into a Manifest.
@@ -66,3 +73,90 @@ func ParseIndexManifest(r io.Reader) (*IndexManifest, error) {
 	}
 	return &im, nil
 }
+
+// WalkIndexManifest decodes an IndexManifest from r without holding all of
+// its children in memory, which matters for indexes with many thousands of
+// children. Each child descriptor is passed to walk as it is decoded, and the
+// returned IndexManifest has every field except Manifests populated.
+//
+// Decoding stops at the first error returned by walk, which is returned.
+func WalkIndexManifest(r io.Reader, walk func(Descriptor) error) (*IndexManifest, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	im := IndexManifest{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %v in index manifest", tok)
+		}
+		// Match field names case-insensitively, as encoding/json does.
+		var v interface{}
+		switch strings.ToLower(key) {
+		case "schemaversion":
+			v = &im.SchemaVersion
+		case "mediatype":
+			v = &im.MediaType
+		case "annotations":
+			v = &im.Annotations
+		case "subject":
+			v = &im.Subject
+		case "artifacttype":
+			v = &im.ArtifactType
+		case "manifests":
+			if err := walkDescriptors(dec, walk); err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			v = &json.RawMessage{}
+		}
+		if err := dec.Decode(v); err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	return &im, nil
+}
+
+func walkDescriptors(dec *json.Decoder, walk func(Descriptor) error) error {
+	// Tolerate "manifests": null, like encoding/json does.
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("unexpected %v in index manifest, expected [", tok)
+	}
+	for dec.More() {
+		desc := Descriptor{}
+		if err := dec.Decode(&desc); err != nil {
+			return err
+		}
+		if err := walk(desc); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("unexpected %v in index manifest, expected %v", tok, want)
+	}
+	return nil
+}
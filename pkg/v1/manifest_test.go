@@ -15,6 +15,7 @@
 package v1
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -74,3 +75,56 @@ func TestParseIndexManifest(t *testing.T) {
 		t.Errorf("expected error, got: %v", got)
 	}
 }
+
+func TestWalkIndexManifest(t *testing.T) {
+	raw := `{
+  "schemaVersion": 2,
+  "MediaType": "application/vnd.oci.image.index.v1+json",
+  "unknown": {"nested": [1, 2, 3]},
+  "manifests": [
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "size": 1, "digest": "sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef"},
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "size": 2, "digest": "sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeee", "platform": {"os": "linux", "architecture": "arm64"}}
+  ],
+  "annotations": {"foo": "bar"},
+  "artifactType": "application/example"
+}`
+	want, err := ParseIndexManifest(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseIndexManifest: %v", err)
+	}
+
+	var children []Descriptor
+	got, err := WalkIndexManifest(strings.NewReader(raw), func(desc Descriptor) error {
+		children = append(children, desc)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkIndexManifest: %v", err)
+	}
+	got.Manifests = children
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WalkIndexManifest(); (-want +got) %s", diff)
+	}
+
+	// Errors from walk stop decoding.
+	stop := errors.New("stop")
+	calls := 0
+	if _, err := WalkIndexManifest(strings.NewReader(raw), func(Descriptor) error {
+		calls++
+		return stop
+	}); err != stop {
+		t.Errorf("WalkIndexManifest() = %v, want %v", err, stop)
+	}
+	if calls != 1 {
+		t.Errorf("walk called %d times, want 1", calls)
+	}
+
+	for _, bad := range []string{"", "[]", `{"manifests": {}}`, `{"manifests": [{]}`, `{"manifests": []`} {
+		if _, err := WalkIndexManifest(strings.NewReader(bad), func(Descriptor) error { return nil }); err == nil {
+			t.Errorf("WalkIndexManifest(%q) = nil, want error", bad)
+		}
+	}
+	if _, err := WalkIndexManifest(strings.NewReader(`{"manifests": null}`), func(Descriptor) error { return nil }); err != nil {
+		t.Errorf("WalkIndexManifest(null manifests): %v", err)
+	}
+}
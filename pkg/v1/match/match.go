@@ -60,6 +60,72 @@ func Platforms(platforms ...v1.Platform) Matcher {
 	}
 }
 
+// PlatformsSatisfied returns a match.Matcher that matches descriptors whose
+// platform satisfies any one of the provided platform specs (see
+// v1.Platform.Satisfies). Unlike Platforms, fields left empty in a spec match
+// anything, e.g. {OS: "linux", Architecture: "arm64"} matches every arm64
+// variant. Ignores any descriptors that do not have a platform.
+func PlatformsSatisfied(specs ...v1.Platform) Matcher {
+	return func(desc v1.Descriptor) bool {
+		if desc.Platform == nil {
+			return false
+		}
+		for _, spec := range specs {
+			if desc.Platform.Satisfies(spec) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// PlatformSelector returns a match.Matcher that matches descriptors whose
+// platform the selector selects, see v1.ParsePlatformSelector. Ignores any
+// descriptors that do not have a platform.
+func PlatformSelector(s *v1.PlatformSelector) Matcher {
+	return func(desc v1.Descriptor) bool {
+		return desc.Platform != nil && s.Matches(*desc.Platform)
+	}
+}
+
+// Attestations returns a match.Matcher that matches the attestation manifests
+// that buildkit attaches to multi-platform indexes.
+func Attestations() Matcher {
+	return Annotation(dockerReferenceType, attestationManifest)
+}
+
+// Artifacts returns a match.Matcher that matches descriptors for OCI
+// artifacts, i.e. those that set an artifactType, such as signatures and SBOMs.
+func Artifacts() Matcher {
+	return func(desc v1.Descriptor) bool {
+		return desc.ArtifactType != ""
+	}
+}
+
+const (
+	dockerReferenceType = "vnd.docker.reference.type"
+	attestationManifest = "attestation-manifest"
+)
+
+// Not returns a match.Matcher that inverts m.
+func Not(m Matcher) Matcher {
+	return func(desc v1.Descriptor) bool {
+		return !m(desc)
+	}
+}
+
+// Any returns a match.Matcher that matches if any of the provided matchers do.
+func Any(ms ...Matcher) Matcher {
+	return func(desc v1.Descriptor) bool {
+		for _, m := range ms {
+			if m(desc) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 // MediaTypes returns a match.Matcher that matches at least one of the provided media types.
 func MediaTypes(mediaTypes ...string) Matcher {
 	mts := map[string]bool{}
@@ -85,6 +85,86 @@ func TestPlatforms(t *testing.T) {
 	}
 }
 
+func TestPlatformsSatisfied(t *testing.T) {
+	tests := []struct {
+		desc      v1.Descriptor
+		platforms []v1.Platform
+		match     bool
+	}{
+		{v1.Descriptor{Platform: &v1.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"}}, []v1.Platform{{Architecture: "arm64", OS: "linux"}}, true},
+		{v1.Descriptor{Platform: &v1.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"}}, []v1.Platform{{OS: "linux"}}, true},
+		{v1.Descriptor{Platform: &v1.Platform{Architecture: "arm64", OS: "linux"}}, []v1.Platform{{Architecture: "arm64", OS: "linux", Variant: "v8"}}, false},
+		{v1.Descriptor{Platform: &v1.Platform{Architecture: "amd64", OS: "linux"}}, []v1.Platform{{Architecture: "arm64"}, {OS: "windows"}}, false},
+		{v1.Descriptor{Platform: nil}, []v1.Platform{{}}, false},
+	}
+	for i, tt := range tests {
+		f := match.PlatformsSatisfied(tt.platforms...)
+		if match := f(tt.desc); match != tt.match {
+			t.Errorf("%d: mismatched, got %v expected %v for desc %#v platform %#v", i, match, tt.match, tt.desc, tt.platforms)
+		}
+	}
+}
+
+func TestPlatformSelector(t *testing.T) {
+	s, err := v1.ParsePlatformSelector("linux/*,!linux/arm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		desc  v1.Descriptor
+		match bool
+	}{
+		{v1.Descriptor{Platform: &v1.Platform{Architecture: "aarch64", OS: "linux"}}, true},
+		{v1.Descriptor{Platform: &v1.Platform{Architecture: "arm", OS: "linux", Variant: "v6"}}, false},
+		{v1.Descriptor{Platform: &v1.Platform{Architecture: "amd64", OS: "windows"}}, false},
+		{v1.Descriptor{Platform: nil}, false},
+	}
+	f := match.PlatformSelector(s)
+	for i, tt := range tests {
+		if match := f(tt.desc); match != tt.match {
+			t.Errorf("%d: mismatched, got %v expected %v for desc %#v", i, match, tt.match, tt.desc)
+		}
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	att := v1.Descriptor{
+		Platform:    &v1.Platform{Architecture: "unknown", OS: "unknown"},
+		Annotations: map[string]string{"vnd.docker.reference.type": "attestation-manifest"},
+	}
+	img := v1.Descriptor{
+		Platform: &v1.Platform{Architecture: "amd64", OS: "linux"},
+	}
+
+	if !match.Attestations()(att) {
+		t.Errorf("Attestations() should match %v", att)
+	}
+	if match.Attestations()(img) {
+		t.Errorf("Attestations() should not match %v", img)
+	}
+	if match.Not(match.Attestations())(att) {
+		t.Errorf("Not(Attestations()) should not match %v", att)
+	}
+
+	either := match.Any(match.Attestations(), match.Platforms(*img.Platform))
+	for _, desc := range []v1.Descriptor{att, img} {
+		if !either(desc) {
+			t.Errorf("Any() should match %v", desc)
+		}
+	}
+	if match.Any()(img) {
+		t.Errorf("Any() with no matchers should not match")
+	}
+
+	sig := v1.Descriptor{ArtifactType: "application/vnd.example.sig"}
+	if !match.Artifacts()(sig) {
+		t.Errorf("Artifacts() should match %v", sig)
+	}
+	if match.Artifacts()(img) {
+		t.Errorf("Artifacts() should not match %v", img)
+	}
+}
+
 func TestMediaTypes(t *testing.T) {
 	tests := []struct {
 		desc       v1.Descriptor
@@ -0,0 +1,181 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// dockerToOCI maps each docker media type to its OCI equivalent.
+var dockerToOCI = map[types.MediaType]types.MediaType{
+	types.DockerManifestSchema2:   types.OCIManifestSchema1,
+	types.DockerManifestList:      types.OCIImageIndex,
+	types.DockerConfigJSON:        types.OCIConfigJSON,
+	types.DockerLayer:             types.OCILayer,
+	types.DockerUncompressedLayer: types.OCIUncompressedLayer,
+	types.DockerForeignLayer:      types.OCIRestrictedLayer,
+}
+
+// ociToDocker is the inverse of dockerToOCI.
+var ociToDocker = map[types.MediaType]types.MediaType{}
+
+// ociOnly are OCI media types that can't be expressed in a docker manifest.
+var ociOnly = map[types.MediaType]bool{
+	types.OCILayerZStd:                   true,
+	types.OCIRestrictedLayerZStd:         true,
+	types.OCIUncompressedRestrictedLayer: true,
+}
+
+func init() {
+	for d, o := range dockerToOCI {
+		ociToDocker[o] = d
+	}
+}
+
+// toOCI returns whether mt, which must be an image or index media type, is
+// of the OCI flavor.
+func toOCI(mt types.MediaType) (bool, error) {
+	switch mt {
+	case types.OCIManifestSchema1, types.OCIImageIndex:
+		return true, nil
+	case types.DockerManifestSchema2, types.DockerManifestList:
+		return false, nil
+	}
+	return false, fmt.Errorf("cannot convert to media type %q", mt)
+}
+
+// convertMediaType translates mt into the OCI or docker flavor, leaving media
+// types it doesn't know about (e.g. for artifacts) alone.
+func convertMediaType(mt types.MediaType, oci bool) (types.MediaType, error) {
+	table := ociToDocker
+	if oci {
+		table = dockerToOCI
+	}
+	if to, ok := table[mt]; ok {
+		return to, nil
+	}
+	if !oci && ociOnly[mt] {
+		return "", fmt.Errorf("media type %q has no docker equivalent", mt)
+	}
+	return mt, nil
+}
+
+// ConvertImage returns img with its manifest, config, and layer media types
+// translated to match mt, which must be types.OCIManifestSchema1 or
+// types.DockerManifestSchema2. This is useful for registries that only accept
+// one flavor of manifest.
+//
+// Only media types are changed; layer contents and digests stay the same.
+// Media types without an equivalent, such as a custom config media type, are
+// left alone, unless they can't be represented at all in the target flavor
+// (e.g. zstd layers in a docker manifest), which is an error.
+func ConvertImage(img v1.Image, mt types.MediaType) (v1.Image, error) {
+	oci, err := toOCI(mt)
+	if err != nil {
+		return nil, err
+	}
+	if !mt.IsImage() {
+		return nil, fmt.Errorf("cannot convert image to %q", mt)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	configMediaType, err := convertMediaType(m.Config.MediaType, oci)
+	if err != nil {
+		return nil, err
+	}
+	layerMediaTypes := map[types.MediaType]types.MediaType{}
+	for _, desc := range m.Layers {
+		to, err := convertMediaType(desc.MediaType, oci)
+		if err != nil {
+			return nil, fmt.Errorf("converting layer %s: %w", desc.Digest, err)
+		}
+		if to != desc.MediaType {
+			layerMediaTypes[desc.MediaType] = to
+		}
+	}
+
+	return &image{
+		base:            img,
+		mediaType:       &mt,
+		configMediaType: &configMediaType,
+		layerMediaTypes: layerMediaTypes,
+	}, nil
+}
+
+// ConvertIndex returns idx with its media type translated to match mt, which
+// must be types.OCIImageIndex or types.DockerManifestList. Every child image
+// and index is converted recursively (see ConvertImage), so the result is
+// consistently one flavor. Children of unknown media types are left alone.
+func ConvertIndex(idx v1.ImageIndex, mt types.MediaType) (v1.ImageIndex, error) {
+	oci, err := toOCI(mt)
+	if err != nil {
+		return nil, err
+	}
+	if !mt.IsIndex() {
+		return nil, fmt.Errorf("cannot convert index to %q", mt)
+	}
+	imageMediaType := types.DockerManifestSchema2
+	if oci {
+		imageMediaType = types.OCIManifestSchema1
+	}
+
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	replace := []replacement{}
+	for _, desc := range im.Manifests {
+		var add Appendable
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if add, err = ConvertIndex(child, mt); err != nil {
+				return nil, fmt.Errorf("converting %s: %w", desc.Digest, err)
+			}
+		case desc.MediaType.IsImage():
+			child, err := idx.Image(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if add, err = ConvertImage(child, imageMediaType); err != nil {
+				return nil, fmt.Errorf("converting %s: %w", desc.Digest, err)
+			}
+		default:
+			continue
+		}
+
+		replace = append(replace, replacement{
+			matcher: match.Digests(desc.Digest),
+			add:     IndexAddendum{Add: add},
+		})
+	}
+
+	return &index{
+		base:      idx,
+		mediaType: &mt,
+		replace:   replace,
+	}, nil
+}
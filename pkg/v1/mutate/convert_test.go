@@ -0,0 +1,162 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// checkFlavor asserts that every image in idx, recursively, uses the media
+// types in want.
+func checkFlavor(t *testing.T, idx v1.ImageIndex, want map[string]types.MediaType) {
+	t.Helper()
+
+	if got, err := idx.MediaType(); err != nil {
+		t.Fatal(err)
+	} else if got != want["index"] {
+		t.Errorf("index MediaType() = %s, want %s", got, want["index"])
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, desc := range im.Manifests {
+		if desc.MediaType.IsIndex() {
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFlavor(t, child, want)
+			continue
+		}
+		if desc.MediaType != want["image"] {
+			t.Errorf("descriptor MediaType = %s, want %s", desc.MediaType, want["image"])
+		}
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.MediaType != want["image"] {
+			t.Errorf("manifest MediaType = %s, want %s", m.MediaType, want["image"])
+		}
+		if m.Config.MediaType != want["config"] {
+			t.Errorf("config MediaType = %s, want %s", m.Config.MediaType, want["config"])
+		}
+		for _, l := range m.Layers {
+			if l.MediaType != want["layer"] {
+				t.Errorf("layer MediaType = %s, want %s", l.MediaType, want["layer"])
+			}
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range layers {
+			if got, err := l.MediaType(); err != nil {
+				t.Fatal(err)
+			} else if got != want["layer"] {
+				t.Errorf("layer.MediaType() = %s, want %s", got, want["layer"])
+			}
+		}
+	}
+}
+
+func TestConvertIndex(t *testing.T) {
+	child, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.DockerManifestList),
+		mutate.IndexAddendum{Add: child},
+		mutate.IndexAddendum{Add: img},
+	)
+
+	oci, err := mutate.ConvertIndex(idx, types.OCIImageIndex)
+	if err != nil {
+		t.Fatalf("ConvertIndex(OCI) = %v", err)
+	}
+	if err := validate.Index(oci); err != nil {
+		t.Errorf("validate.Index(OCI) = %v", err)
+	}
+	checkFlavor(t, oci, map[string]types.MediaType{
+		"index":  types.OCIImageIndex,
+		"image":  types.OCIManifestSchema1,
+		"config": types.OCIConfigJSON,
+		"layer":  types.OCILayer,
+	})
+
+	docker, err := mutate.ConvertIndex(oci, types.DockerManifestList)
+	if err != nil {
+		t.Fatalf("ConvertIndex(docker) = %v", err)
+	}
+	if err := validate.Index(docker); err != nil {
+		t.Errorf("validate.Index(docker) = %v", err)
+	}
+	checkFlavor(t, docker, map[string]types.MediaType{
+		"index":  types.DockerManifestList,
+		"image":  types.DockerManifestSchema2,
+		"config": types.DockerConfigJSON,
+		"layer":  types.DockerLayer,
+	})
+
+	// Converting to the same flavor is a no-op.
+	again, err := mutate.ConvertIndex(docker, types.DockerManifestList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, err := docker.Digest(); err != nil {
+		t.Fatal(err)
+	} else if got, err := again.Digest(); err != nil {
+		t.Fatal(err)
+	} else if got != want {
+		t.Errorf("Digest() = %s, want %s", got, want)
+	}
+}
+
+func TestConvertImageErrors(t *testing.T) {
+	if _, err := mutate.ConvertImage(empty.Image, types.OCIImageIndex); err == nil {
+		t.Error("ConvertImage(index media type) should fail")
+	}
+	if _, err := mutate.ConvertIndex(empty.Index, types.OCIManifestSchema1); err == nil {
+		t.Error("ConvertIndex(image media type) should fail")
+	}
+
+	layer, err := random.Layer(1024, types.OCILayerZStd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.Append(empty.Image, mutate.Addendum{Layer: layer, MediaType: types.OCILayerZStd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mutate.ConvertImage(img, types.DockerManifestSchema2); err == nil {
+		t.Error("ConvertImage(zstd, docker) should fail")
+	}
+}
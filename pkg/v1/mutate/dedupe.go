@@ -0,0 +1,57 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Dedupe returns a copy of img with repeated layers (by DiffID) collapsed into
+// one, fixing up rootfs.diff_ids, history, and the manifest to match. Some
+// build pipelines accidentally produce the same layer more than once.
+//
+// Only the last occurrence of each layer is kept. Applying a layer replaces
+// everything it touches, so dropping earlier copies of it doesn't change the
+// resulting filesystem.
+//
+// If img has no repeated layers, it is returned unchanged.
+func Dedupe(img v1.Image) (v1.Image, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	last := map[v1.Hash]int{}
+	for i, layer := range layers {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return nil, err
+		}
+		last[diffID] = i
+	}
+	if len(last) == len(layers) {
+		return img, nil
+	}
+
+	return rewriteLayers(img, func(i int, layer v1.Layer) (v1.Layer, error) {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return nil, err
+		}
+		if last[diffID] != i {
+			return nil, nil
+		}
+		return layer, nil
+	})
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestDedupe(t *testing.T) {
+	a := layerFromFiles(t, testFile{name: "a", contents: "a"})
+	b := layerFromFiles(t, testFile{name: "b", contents: "b"})
+
+	img, err := mutate.Append(empty.Image,
+		mutate.Addendum{Layer: a, History: v1.History{CreatedBy: "a"}},
+		mutate.Addendum{History: v1.History{CreatedBy: "ENV", EmptyLayer: true}},
+		mutate.Addendum{Layer: b, History: v1.History{CreatedBy: "b"}},
+		mutate.Addendum{Layer: a, History: v1.History{CreatedBy: "a again"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deduped, err := mutate.Dedupe(img)
+	if err != nil {
+		t.Fatalf("Dedupe() = %v", err)
+	}
+	if err := validate.Image(deduped); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	cf, err := deduped.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDiffIDs := []v1.Hash{}
+	for _, l := range []v1.Layer{b, a} {
+		h, err := l.DiffID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantDiffIDs = append(wantDiffIDs, h)
+	}
+	if d := cmp.Diff(wantDiffIDs, cf.RootFS.DiffIDs); d != "" {
+		t.Errorf("DiffIDs Diff(-want,+got): %s", d)
+	}
+
+	gotHistory := []string{}
+	for _, h := range cf.History {
+		gotHistory = append(gotHistory, h.CreatedBy)
+	}
+	if d := cmp.Diff([]string{"ENV", "b", "a again"}, gotHistory); d != "" {
+		t.Errorf("history Diff(-want,+got): %s", d)
+	}
+
+	m, err := deduped.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(m.Layers), 2; got != want {
+		t.Errorf("len(Layers) = %d, want %d", got, want)
+	}
+
+	// Nothing to do.
+	if got, err := mutate.Dedupe(deduped); err != nil {
+		t.Fatalf("Dedupe() = %v", err)
+	} else if got != deduped {
+		t.Errorf("Dedupe() without duplicates should return the input image")
+	}
+}
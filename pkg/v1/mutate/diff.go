@@ -0,0 +1,195 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// FileInfo describes a single entry in an image's flattened filesystem.
+type FileInfo struct {
+	Path     string
+	Typeflag byte
+	Size     int64
+	Mode     os.FileMode
+	UID      int
+	GID      int
+	Linkname string
+
+	// Digest is the sha256 of the entry's contents. It is only set for
+	// regular files.
+	Digest v1.Hash
+
+	// Layer is the DiffID of the layer that provides this entry.
+	Layer v1.Hash
+}
+
+// FileChange describes an entry that exists in both images but differs.
+type FileChange struct {
+	Path string
+	Old  FileInfo
+	New  FileInfo
+}
+
+// FileDiff is the result of comparing the flattened filesystems of two images.
+// Each list is sorted by path.
+type FileDiff struct {
+	Added    []FileInfo
+	Removed  []FileInfo
+	Modified []FileChange
+}
+
+// Empty returns true if the two images had identical filesystems.
+func (d *FileDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Diff compares the flattened filesystems of a and b, returning the files
+// that were added, removed, or modified going from a to b.
+//
+// Entries are compared by type, mode, ownership, link target, size, and
+// content. Modification times are ignored, since they rarely matter and
+// would otherwise dominate the result for rebuilt images.
+//
+// If both images have the same layers (by DiffID), no layer contents are read.
+func Diff(a, b v1.Image) (*FileDiff, error) {
+	ad, err := diffIDs(a)
+	if err != nil {
+		return nil, err
+	}
+	bd, err := diffIDs(b)
+	if err != nil {
+		return nil, err
+	}
+	if equalHashes(ad, bd) {
+		return &FileDiff{}, nil
+	}
+
+	af, err := flattenedFiles(a)
+	if err != nil {
+		return nil, fmt.Errorf("reading filesystem: %w", err)
+	}
+	bf, err := flattenedFiles(b)
+	if err != nil {
+		return nil, fmt.Errorf("reading filesystem: %w", err)
+	}
+
+	diff := &FileDiff{}
+	for p, before := range af {
+		after, ok := bf[p]
+		if !ok {
+			diff.Removed = append(diff.Removed, before)
+			continue
+		}
+		// An entry that comes from the same layer in both images can't differ.
+		if before.Layer == after.Layer {
+			continue
+		}
+		if !sameFile(before, after) {
+			diff.Modified = append(diff.Modified, FileChange{Path: p, Old: before, New: after})
+		}
+	}
+	for p, after := range bf {
+		if _, ok := af[p]; !ok {
+			diff.Added = append(diff.Added, after)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Path < diff.Added[j].Path })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Path < diff.Removed[j].Path })
+	sort.Slice(diff.Modified, func(i, j int) bool { return diff.Modified[i].Path < diff.Modified[j].Path })
+
+	return diff, nil
+}
+
+func diffIDs(img v1.Image) ([]v1.Hash, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	hs := make([]v1.Hash, 0, len(layers))
+	for _, l := range layers {
+		h, err := l.DiffID()
+		if err != nil {
+			return nil, err
+		}
+		hs = append(hs, h)
+	}
+	return hs, nil
+}
+
+func equalHashes(a, b []v1.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func flattenedFiles(img v1.Image) (map[string]FileInfo, error) {
+	files := map[string]FileInfo{}
+	err := walkFlattened(img, func(layer v1.Layer, header *tar.Header, r io.Reader) error {
+		// Layers are walked from the top, so the first entry we see for a path
+		// is the one in the flattened filesystem; never let a lower layer's
+		// entry (e.g. for a directory) replace it.
+		if _, ok := files[header.Name]; ok {
+			return nil
+		}
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return err
+		}
+		fi := FileInfo{
+			Path:     header.Name,
+			Typeflag: header.Typeflag,
+			Size:     header.Size,
+			Mode:     header.FileInfo().Mode(),
+			UID:      header.Uid,
+			GID:      header.Gid,
+			Linkname: header.Linkname,
+			Layer:    diffID,
+		}
+		if header.Typeflag == tar.TypeReg {
+			h, _, err := v1.SHA256(io.LimitReader(r, header.Size))
+			if err != nil {
+				return fmt.Errorf("hashing %s: %w", header.Name, err)
+			}
+			fi.Digest = h
+		}
+		files[header.Name] = fi
+		return nil
+	})
+	return files, err
+}
+
+func sameFile(a, b FileInfo) bool {
+	return a.Typeflag == b.Typeflag &&
+		a.Mode == b.Mode &&
+		a.UID == b.UID &&
+		a.GID == b.GID &&
+		a.Linkname == b.Linkname &&
+		a.Size == b.Size &&
+		a.Digest == b.Digest
+}
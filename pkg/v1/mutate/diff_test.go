@@ -0,0 +1,184 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+type testFile struct {
+	name     string
+	contents string
+	mode     int64
+	typeflag byte
+	linkname string
+}
+
+func layerFromFiles(t *testing.T, files ...testFile) v1.Layer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		typeflag := f.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		mode := f.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     f.name,
+			Typeflag: typeflag,
+			Mode:     mode,
+			Size:     int64(len(f.contents)),
+			Linkname: f.linkname,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := buf.Bytes()
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layer
+}
+
+func imageFromLayers(t *testing.T, layers ...v1.Layer) v1.Image {
+	t.Helper()
+
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestDiff(t *testing.T) {
+	base := layerFromFiles(t,
+		testFile{name: "etc", typeflag: tar.TypeDir, mode: 0755},
+		testFile{name: "etc/hosts", contents: "localhost"},
+		testFile{name: "etc/passwd", contents: "root"},
+		testFile{name: "bin/sh", contents: "#!", mode: 0755},
+	)
+
+	a := imageFromLayers(t, base)
+	b := imageFromLayers(t, base, layerFromFiles(t,
+		testFile{name: "etc/.wh.passwd"},
+		testFile{name: "etc/hosts", contents: "127.0.0.1 localhost"},
+		testFile{name: "bin/sh", contents: "#!", mode: 0700},
+		testFile{name: "app/main", contents: "main"},
+	))
+
+	diff, err := mutate.Diff(a, b)
+	if err != nil {
+		t.Fatalf("Diff() = %v", err)
+	}
+
+	if got, want := len(diff.Added), 1; got != want {
+		t.Fatalf("len(Added) = %d, want %d", got, want)
+	}
+	if got, want := diff.Added[0].Path, "app/main"; got != want {
+		t.Errorf("Added[0].Path = %q, want %q", got, want)
+	}
+	if got, want := diff.Added[0].Size, int64(4); got != want {
+		t.Errorf("Added[0].Size = %d, want %d", got, want)
+	}
+
+	if got, want := len(diff.Removed), 1; got != want {
+		t.Fatalf("len(Removed) = %d, want %d", got, want)
+	}
+	if got, want := diff.Removed[0].Path, "etc/passwd"; got != want {
+		t.Errorf("Removed[0].Path = %q, want %q", got, want)
+	}
+
+	if got, want := len(diff.Modified), 2; got != want {
+		t.Fatalf("len(Modified) = %d, want %d", got, want)
+	}
+	if got, want := diff.Modified[0].Path, "bin/sh"; got != want {
+		t.Errorf("Modified[0].Path = %q, want %q", got, want)
+	}
+	if got, want := diff.Modified[0].New.Mode.Perm(), 0700; int(got) != want {
+		t.Errorf("Modified[0].New.Mode = %o, want %o", got, want)
+	}
+	if got, want := diff.Modified[1].Path, "etc/hosts"; got != want {
+		t.Errorf("Modified[1].Path = %q, want %q", got, want)
+	}
+	if diff.Modified[1].Old.Digest == diff.Modified[1].New.Digest {
+		t.Errorf("Modified[1] digests should differ")
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	layer := layerFromFiles(t, testFile{name: "foo", contents: "bar"})
+	a := imageFromLayers(t, layer)
+	b := imageFromLayers(t, layerFromFiles(t, testFile{name: "foo", contents: "bar"}))
+
+	for _, img := range []v1.Image{a, b} {
+		diff, err := mutate.Diff(a, img)
+		if err != nil {
+			t.Fatalf("Diff() = %v", err)
+		}
+		if !diff.Empty() {
+			t.Errorf("Diff() = %+v, want empty", diff)
+		}
+	}
+}
+
+func TestDiffDirectoryMode(t *testing.T) {
+	base := layerFromFiles(t, testFile{name: "data", typeflag: tar.TypeDir, mode: 0755})
+	a := imageFromLayers(t, base)
+	b := imageFromLayers(t, base, layerFromFiles(t, testFile{name: "data", typeflag: tar.TypeDir, mode: 0700}))
+
+	// The topmost layer's directory metadata wins.
+	diff, err := mutate.Diff(a, b)
+	if err != nil {
+		t.Fatalf("Diff() = %v", err)
+	}
+	if got, want := len(diff.Modified), 1; got != want {
+		t.Fatalf("len(Modified) = %d, want %d", got, want)
+	}
+	if got, want := diff.Modified[0].New.Mode.Perm(), 0700; int(got) != want {
+		t.Errorf("Modified[0].New.Mode = %o, want %o", got, want)
+	}
+
+	diff, err = mutate.Diff(b, b)
+	if err != nil {
+		t.Fatalf("Diff() = %v", err)
+	}
+	if !diff.Empty() {
+		t.Errorf("Diff(b, b) = %+v, want empty", diff)
+	}
+}
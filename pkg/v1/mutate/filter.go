@@ -0,0 +1,207 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// FilterLayer returns a copy of layer with only the entries for which keep
+// returns true, e.g. to strip secrets, docs, or test fixtures after a build.
+// keep is called for every entry in the layer, including whiteouts.
+//
+// The result has the same media type and compression as layer. FilterLayer
+// reads and filters the whole layer up front to compute its digest and diff
+// ID, so keep is called then, and again each time the contents are read.
+func FilterLayer(layer v1.Layer, keep func(*tar.Header) bool) (v1.Layer, error) {
+	return rewriteLayer(layer, func(tr *tar.Reader, tw *tar.Writer) error {
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !keep(header) {
+				continue
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// Filter returns a copy of img with only the files for which keep returns
+// true, by rewriting each of its layers with FilterLayer. The config,
+// including history, and annotations are carried over.
+func Filter(img v1.Image, keep func(*tar.Header) bool) (v1.Image, error) {
+	return rewriteLayers(img, func(_ int, layer v1.Layer) (v1.Layer, error) {
+		return FilterLayer(layer, keep)
+	})
+}
+
+// rewriteLayer returns a layer whose (uncompressed) contents are produced by
+// rewriting the contents of layer with fn, keeping its media type and
+// compression.
+func rewriteLayer(layer v1.Layer, fn func(*tar.Reader, *tar.Writer) error) (v1.Layer, error) {
+	mt, err := layer.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	comp, err := layerCompression(layer)
+	if err != nil {
+		return nil, err
+	}
+
+	opener := func() (io.ReadCloser, error) {
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return nil, err
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			defer rc.Close()
+
+			tw := tar.NewWriter(pw)
+			if err := fn(tar.NewReader(rc), tw); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			pw.CloseWithError(tw.Close())
+		}()
+		return pr, nil
+	}
+
+	return tarball.LayerFromOpener(opener, tarball.WithMediaType(mt), tarball.WithCompression(comp))
+}
+
+// rewriteLayers returns a copy of img with the i'th layer replaced by fn(i, layer),
+// or dropped along with its history if fn returns nil. Everything else about
+// img (config, history, media types, annotations) is carried over.
+func rewriteLayers(img v1.Image, fn func(int, v1.Layer) (v1.Layer, error)) (v1.Image, error) {
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	ocf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) != len(m.Layers) {
+		return nil, fmt.Errorf("image has %d layers but %d manifest layers", len(layers), len(m.Layers))
+	}
+
+	adds := make([]Addendum, 0, len(layers))
+	dropped := map[int]bool{}
+	for i, layer := range layers {
+		newLayer, err := fn(i, layer)
+		if err != nil {
+			return nil, fmt.Errorf("rewriting layer %d: %w", i, err)
+		}
+		if newLayer == nil {
+			dropped[i] = true
+			continue
+		}
+		adds = append(adds, Addendum{
+			Layer:       newLayer,
+			Annotations: m.Layers[i].Annotations,
+			URLs:        m.Layers[i].URLs,
+			MediaType:   m.Layers[i].MediaType,
+		})
+	}
+
+	base := empty.Image
+	if m.MediaType != "" {
+		base = MediaType(base, m.MediaType)
+	}
+	if m.Config.MediaType != "" {
+		base = ConfigMediaType(base, m.Config.MediaType)
+	}
+	newImage, err := Append(base, adds...)
+	if err != nil {
+		return nil, err
+	}
+
+	cf, err := newImage.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cfg := ocf.DeepCopy()
+	cfg.RootFS.DiffIDs = cf.RootFS.DiffIDs
+	if len(dropped) != 0 {
+		cfg.History = dropHistory(cfg.History, len(layers), dropped)
+	}
+	newImage, err = ConfigFile(newImage, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Annotations != nil {
+		newImage = Annotations(newImage, m.Annotations).(v1.Image)
+	}
+	if m.Subject != nil {
+		newImage = Subject(newImage, *m.Subject).(v1.Image)
+	}
+	if m.ArtifactType != "" {
+		newImage = ArtifactType(newImage, m.ArtifactType).(v1.Image)
+	}
+	return newImage, nil
+}
+
+// dropHistory removes the history entries for the dropped layers, where
+// history is expected to have an entry that isn't an EmptyLayer for each of
+// the image's n layers. If it doesn't, we can't tell which entries belong to
+// which layers, so history is returned unchanged.
+func dropHistory(history []v1.History, n int, dropped map[int]bool) []v1.History {
+	nonEmpty := 0
+	for _, h := range history {
+		if !h.EmptyLayer {
+			nonEmpty++
+		}
+	}
+	if nonEmpty != n {
+		return history
+	}
+
+	kept := make([]v1.History, 0, len(history))
+	layer := 0
+	for _, h := range history {
+		if h.EmptyLayer {
+			kept = append(kept, h)
+			continue
+		}
+		if !dropped[layer] {
+			kept = append(kept, h)
+		}
+		layer++
+	}
+	return kept
+}
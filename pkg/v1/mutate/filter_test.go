@@ -0,0 +1,145 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func layerNames(t *testing.T, layer v1.Layer) []string {
+	t.Helper()
+
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	var names []string
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+}
+
+func noDocs(header *tar.Header) bool {
+	return !strings.HasPrefix(header.Name, "usr/share/doc/")
+}
+
+func TestFilterLayer(t *testing.T) {
+	layer := layerFromFiles(t,
+		testFile{name: "bin/sh", contents: "#!"},
+		testFile{name: "usr/share/doc/README", contents: "read me"},
+		testFile{name: "etc/hosts", contents: "localhost"},
+	)
+
+	filtered, err := mutate.FilterLayer(layer, noDocs)
+	if err != nil {
+		t.Fatalf("FilterLayer() = %v", err)
+	}
+	if err := validate.Layer(filtered); err != nil {
+		t.Errorf("validate.Layer() = %v", err)
+	}
+	if d := cmp.Diff([]string{"bin/sh", "etc/hosts"}, layerNames(t, filtered)); d != "" {
+		t.Errorf("names Diff(-want,+got): %s", d)
+	}
+
+	want, err := layer.MediaType()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := filtered.MediaType(); err != nil {
+		t.Fatal(err)
+	} else if got != want {
+		t.Errorf("MediaType() = %s, want %s", got, want)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	img := imageFromLayers(t,
+		layerFromFiles(t,
+			testFile{name: "bin/sh", contents: "#!"},
+			testFile{name: "usr/share/doc/README", contents: "read me"},
+		),
+		layerFromFiles(t,
+			testFile{name: "usr/share/doc/LICENSE", contents: "apache"},
+			testFile{name: "app/main", contents: "main"},
+		),
+	)
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	img = mutate.Annotations(img, map[string]string{"foo": "bar"}).(v1.Image)
+
+	filtered, err := mutate.Filter(img, noDocs)
+	if err != nil {
+		t.Fatalf("Filter() = %v", err)
+	}
+	if err := validate.Image(filtered); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	layers, err := filtered.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(layers), 2; got != want {
+		t.Fatalf("len(Layers()) = %d, want %d", got, want)
+	}
+	if d := cmp.Diff([]string{"bin/sh"}, layerNames(t, layers[0])); d != "" {
+		t.Errorf("layer 0 names Diff(-want,+got): %s", d)
+	}
+	if d := cmp.Diff([]string{"app/main"}, layerNames(t, layers[1])); d != "" {
+		t.Errorf("layer 1 names Diff(-want,+got): %s", d)
+	}
+
+	m, err := filtered.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.MediaType, types.OCIManifestSchema1; got != want {
+		t.Errorf("MediaType = %s, want %s", got, want)
+	}
+	if d := cmp.Diff(map[string]string{"foo": "bar"}, m.Annotations); d != "" {
+		t.Errorf("annotations Diff(-want,+got): %s", d)
+	}
+
+	ocf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := filtered.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(ocf.History, cf.History); d != "" {
+		t.Errorf("history Diff(-want,+got): %s", d)
+	}
+}
@@ -35,6 +35,11 @@ type image struct {
 	annotations     map[string]string
 	mediaType       *types.MediaType
 	configMediaType *types.MediaType
+	subject         *v1.Descriptor
+	artifactType    *string
+	dropAnnotations []string
+	// layerMediaTypes relabels layers of one media type as another
+	layerMediaTypes map[types.MediaType]types.MediaType
 	diffIDMap       map[v1.Hash]v1.Layer
 	digestMap       map[v1.Hash]v1.Layer
 }
@@ -154,6 +159,28 @@ func (i *image) compute() error {
 		}
 	}
 
+	if i.dropAnnotations != nil {
+		manifest.Annotations = withoutKeys(manifest.Annotations, i.dropAnnotations)
+		manifest.Config.Annotations = withoutKeys(manifest.Config.Annotations, i.dropAnnotations)
+		for j := range manifest.Layers {
+			manifest.Layers[j].Annotations = withoutKeys(manifest.Layers[j].Annotations, i.dropAnnotations)
+		}
+	}
+
+	for j, desc := range manifest.Layers {
+		if mt, ok := i.layerMediaTypes[desc.MediaType]; ok {
+			manifest.Layers[j].MediaType = mt
+		}
+	}
+
+	if i.subject != nil {
+		manifest.Subject = i.subject
+	}
+
+	if i.artifactType != nil {
+		manifest.ArtifactType = *i.artifactType
+	}
+
 	i.configFile = configFile
 	i.manifest = manifest
 	i.diffIDMap = diffIDMap
@@ -261,18 +288,42 @@ func (i *image) LayerByDigest(h v1.Hash) (v1.Layer, error) {
 		return partial.ConfigLayer(i)
 	}
 	if layer, ok := i.digestMap[h]; ok {
-		return layer, nil
+		return i.relabel(layer)
+	}
+	layer, err := i.base.LayerByDigest(h)
+	if err != nil {
+		return nil, err
 	}
-	return i.base.LayerByDigest(h)
+	return i.relabel(layer)
 }
 
 // LayerByDiffID is an analog to LayerByDigest, looking up by "diff id"
 // (the uncompressed hash).
 func (i *image) LayerByDiffID(h v1.Hash) (v1.Layer, error) {
 	if layer, ok := i.diffIDMap[h]; ok {
+		return i.relabel(layer)
+	}
+	layer, err := i.base.LayerByDiffID(h)
+	if err != nil {
+		return nil, err
+	}
+	return i.relabel(layer)
+}
+
+// relabel applies layerMediaTypes to layer, so that its MediaType agrees
+// with our manifest.
+func (i *image) relabel(layer v1.Layer) (v1.Layer, error) {
+	if len(i.layerMediaTypes) == 0 {
 		return layer, nil
 	}
-	return i.base.LayerByDiffID(h)
+	mt, err := layer.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	if to, ok := i.layerMediaTypes[mt]; ok {
+		return &relabeledLayer{Layer: layer, mediaType: to}, nil
+	}
+	return layer, nil
 }
 
 func validate(adds []Addendum) error {
@@ -53,23 +53,62 @@ func computeDescriptor(ia IndexAddendum) (*v1.Descriptor, error) {
 	if ia.Descriptor.Data != nil {
 		desc.Data = ia.Descriptor.Data
 	}
+	if ia.Descriptor.ArtifactType != "" {
+		desc.ArtifactType = ia.Descriptor.ArtifactType
+	} else if at, err := artifactType(ia.Add); err != nil {
+		return nil, err
+	} else {
+		desc.ArtifactType = at
+	}
 
 	return desc, nil
 }
 
+// artifactType returns the artifactType of an image or index manifest, so that
+// descriptors pointing at artifacts carry it as OCI image-spec v1.1 expects.
+func artifactType(add Appendable) (string, error) {
+	switch a := add.(type) {
+	case v1.ImageIndex:
+		m, err := a.IndexManifest()
+		if err != nil {
+			return "", err
+		}
+		return m.ArtifactType, nil
+	case v1.Image:
+		m, err := a.Manifest()
+		if err != nil {
+			return "", err
+		}
+		return m.ArtifactType, nil
+	}
+	return "", nil
+}
+
+// replacement swaps out any descriptors that match matcher with add.
+type replacement struct {
+	matcher match.Matcher
+	add     IndexAddendum
+}
+
 type index struct {
 	base v1.ImageIndex
 	adds []IndexAddendum
 	// remove is removed before adds
 	remove match.Matcher
+	// replace is applied after remove, before adds
+	replace []replacement
+	// dropAnnotations are removed from the manifest and its descriptors
+	dropAnnotations []string
 
-	computed    bool
-	manifest    *v1.IndexManifest
-	annotations map[string]string
-	mediaType   *types.MediaType
-	imageMap    map[v1.Hash]v1.Image
-	indexMap    map[v1.Hash]v1.ImageIndex
-	layerMap    map[v1.Hash]v1.Layer
+	computed     bool
+	manifest     *v1.IndexManifest
+	annotations  map[string]string
+	mediaType    *types.MediaType
+	subject      *v1.Descriptor
+	artifactType *string
+	imageMap     map[v1.Hash]v1.Image
+	indexMap     map[v1.Hash]v1.ImageIndex
+	layerMap     map[v1.Hash]v1.Layer
 }
 
 var _ v1.ImageIndex = (*index)(nil)
@@ -110,6 +149,34 @@ func (i *index) compute() error {
 		manifests = cleanedManifests
 	}
 
+	for _, r := range i.replace {
+		var desc *v1.Descriptor
+		for j, m := range manifests {
+			if !r.matcher(m) {
+				continue
+			}
+			if desc == nil {
+				var err error
+				desc, err = computeDescriptor(r.add)
+				if err != nil {
+					return err
+				}
+				i.register(desc.Digest, r.add.Add)
+			}
+			replaced := *desc
+			if replaced.Platform == nil {
+				replaced.Platform = m.Platform
+			}
+			if len(replaced.Annotations) == 0 {
+				replaced.Annotations = m.Annotations
+			}
+			if len(replaced.URLs) == 0 {
+				replaced.URLs = m.URLs
+			}
+			manifests[j] = replaced
+		}
+	}
+
 	for _, add := range i.adds {
 		desc, err := computeDescriptor(add)
 		if err != nil {
@@ -117,15 +184,7 @@ func (i *index) compute() error {
 		}
 
 		manifests = append(manifests, *desc)
-		if idx, ok := add.Add.(v1.ImageIndex); ok {
-			i.indexMap[desc.Digest] = idx
-		} else if img, ok := add.Add.(v1.Image); ok {
-			i.imageMap[desc.Digest] = img
-		} else if l, ok := add.Add.(v1.Layer); ok {
-			i.layerMap[desc.Digest] = l
-		} else {
-			logs.Warn.Printf("Unexpected index addendum: %T", add.Add)
-		}
+		i.register(desc.Digest, add.Add)
 	}
 
 	manifest.Manifests = manifests
@@ -143,11 +202,40 @@ func (i *index) compute() error {
 		}
 	}
 
+	if i.dropAnnotations != nil {
+		manifest.Annotations = withoutKeys(manifest.Annotations, i.dropAnnotations)
+		for j := range manifest.Manifests {
+			manifest.Manifests[j].Annotations = withoutKeys(manifest.Manifests[j].Annotations, i.dropAnnotations)
+		}
+	}
+
+	if i.subject != nil {
+		manifest.Subject = i.subject
+	}
+
+	if i.artifactType != nil {
+		manifest.ArtifactType = *i.artifactType
+	}
+
 	i.manifest = manifest
 	i.computed = true
 	return nil
 }
 
+// register remembers the Appendable for h so we can return it from Image,
+// ImageIndex, or Layer.
+func (i *index) register(h v1.Hash, add Appendable) {
+	if idx, ok := add.(v1.ImageIndex); ok {
+		i.indexMap[h] = idx
+	} else if img, ok := add.(v1.Image); ok {
+		i.imageMap[h] = img
+	} else if l, ok := add.(v1.Layer); ok {
+		i.layerMap[h] = l
+	} else {
+		logs.Warn.Printf("Unexpected index addendum: %T", add)
+	}
+}
+
 func (i *index) Image(h v1.Hash) (v1.Image, error) {
 	if img, ok := i.imageMap[h]; ok {
 		return img, nil
@@ -22,6 +22,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -139,6 +140,83 @@ func TestAppendIndex(t *testing.T) {
 	}
 }
 
+func TestIndexReplace(t *testing.T) {
+	amd64 := v1.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+
+	var base v1.ImageIndex = empty.Index
+	for _, p := range []v1.Platform{amd64, arm64} {
+		p := p
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		base = mutate.AppendManifests(base, mutate.IndexAddendum{
+			Add: img,
+			Descriptor: v1.Descriptor{
+				Platform:    &p,
+				Annotations: map[string]string{"arch": p.Architecture},
+			},
+		})
+	}
+
+	img, err := random.Image(2048, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.IndexReplace(base, match.PlatformsSatisfied(v1.Platform{OS: "linux", Architecture: "arm64"}), mutate.IndexAddendum{
+		Add: img,
+	})
+
+	if err := validate.Index(idx); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(m.Manifests), 2; got != want {
+		t.Fatalf("len(Manifests) = %d, want %d", got, want)
+	}
+
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaced := m.Manifests[1]
+	if replaced.Digest != want {
+		t.Errorf("Manifests[1].Digest = %s, want %s", replaced.Digest, want)
+	}
+	if replaced.Platform == nil || !replaced.Platform.Equals(arm64) {
+		t.Errorf("Manifests[1].Platform = %v, want %v", replaced.Platform, arm64)
+	}
+	if got, want := replaced.Annotations["arch"], "arm64"; got != want {
+		t.Errorf("Manifests[1].Annotations[arch] = %q, want %q", got, want)
+	}
+	if m.Manifests[0].Digest == want {
+		t.Errorf("Manifests[0] should not have been replaced")
+	}
+
+	got, err := idx.Image(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != img {
+		t.Errorf("Image() returned a different image")
+	}
+
+	// Drop the arm64 image and make sure it's gone.
+	idx = mutate.RemoveManifests(idx, match.Not(match.Platforms(amd64)))
+	m, err = idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(m.Manifests), 1; got != want {
+		t.Fatalf("len(Manifests) = %d, want %d", got, want)
+	}
+}
+
 func TestIndexImmutability(t *testing.T) {
 	base, err := random.Index(1024, 3, 3)
 	if err != nil {
@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"fmt"
+
+	icompression "github.com/google/go-containerregistry/internal/compression"
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// convertLayer returns a layer with the given media type, recompressing l if
+// mt implies a different compression than l currently has. If estgz is true,
+// l is always rebuilt as an eStargz layer.
+func convertLayer(l v1.Layer, mt types.MediaType, estgz bool) (v1.Layer, error) {
+	want, known := icompression.ForMediaType(mt)
+
+	if estgz {
+		if known && want != compression.GZip {
+			return nil, fmt.Errorf("estargz requires a gzip media type, got %q", mt)
+		}
+		opts := []tarball.LayerOption{tarball.WithEstargz}
+		if mt != "" {
+			opts = append(opts, tarball.WithMediaType(mt))
+		}
+		return tarball.LayerFromOpener(l.Uncompressed, opts...)
+	}
+
+	if !known {
+		// We don't know how this media type is supposed to be compressed, so
+		// just relabel the layer (via the Addendum) and leave it alone.
+		return l, nil
+	}
+
+	have, err := layerCompression(l)
+	if err != nil {
+		return nil, err
+	}
+	if have == want {
+		return l, nil
+	}
+
+	return tarball.LayerFromOpener(l.Uncompressed, tarball.WithMediaType(mt), tarball.WithCompression(want))
+}
+
+// layerCompression determines how l is compressed, preferring its media type
+// and falling back to sniffing its contents.
+func layerCompression(l v1.Layer) (compression.Compression, error) {
+	mt, err := l.MediaType()
+	if err != nil {
+		return compression.None, err
+	}
+	if comp, ok := icompression.ForMediaType(mt); ok {
+		return comp, nil
+	}
+	return icompression.Detect(l.Compressed)
+}
+
+// relabeledLayer overrides the MediaType of a layer without touching its
+// contents, e.g. to convert between docker and OCI media types.
+type relabeledLayer struct {
+	v1.Layer
+	mediaType types.MediaType
+}
+
+// MediaType implements v1.Layer
+func (l *relabeledLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
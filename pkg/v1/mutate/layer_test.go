@@ -0,0 +1,90 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestAppendConvertsCompression(t *testing.T) {
+	layer := layerFromFiles(t, testFile{name: "foo", contents: "bar"})
+	wantDiffID, err := layer.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDigest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mt := range []types.MediaType{
+		types.OCILayerZStd,
+		types.OCIUncompressedLayer,
+		types.OCILayer,
+	} {
+		t.Run(string(mt), func(t *testing.T) {
+			img, err := mutate.Append(empty.Image, mutate.Addendum{
+				Layer:     layer,
+				MediaType: mt,
+			})
+			if err != nil {
+				t.Fatalf("Append() = %v", err)
+			}
+			m, err := img.Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Layers[0].MediaType; got != mt {
+				t.Errorf("MediaType; got %v, want %v", got, mt)
+			}
+
+			layers, err := img.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validate.Layer(layers[0]); err != nil {
+				t.Errorf("validate.Layer() = %v", err)
+			}
+			diffID, err := layers[0].DiffID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diffID != wantDiffID {
+				t.Errorf("DiffID; got %v, want %v", diffID, wantDiffID)
+			}
+
+			// Only the gzip target should reuse the original blob.
+			if (m.Layers[0].Digest == wantDigest) != (mt == types.OCILayer) {
+				t.Errorf("Digest %v unexpectedly (not) reused for %v", m.Layers[0].Digest, mt)
+			}
+		})
+	}
+}
+
+func TestAppendEstargzRequiresGzip(t *testing.T) {
+	layer := layerFromFiles(t, testFile{name: "foo", contents: "bar"})
+	if _, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:     layer,
+		MediaType: types.OCILayerZStd,
+		Estargz:   true,
+	}); err == nil {
+		t.Error("Append() = nil, wanted error")
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// MergePolicy controls how Merge resolves fields that are set differently in
+// both images' configs.
+type MergePolicy int
+
+const (
+	// MergePreferBase keeps the base image's value. This is the default, and
+	// is what you want when adding e.g. a tool layer onto an arbitrary image.
+	MergePreferBase MergePolicy = iota
+
+	// MergePreferOverlay takes the overlay image's value.
+	MergePreferOverlay
+
+	// MergeStrict fails the merge if there are any conflicts.
+	MergeStrict
+)
+
+// MergeConflict describes a config field that is set differently in the base
+// and overlay images.
+type MergeConflict struct {
+	// Field is the name of the conflicting field, e.g. "Entrypoint", or
+	// "Env[PATH]" and "Labels[maintainer]" for keyed fields.
+	Field   string
+	Base    string
+	Overlay string
+}
+
+func (c MergeConflict) String() string {
+	return fmt.Sprintf("%s: %q (base) vs %q (overlay)", c.Field, c.Base, c.Overlay)
+}
+
+// Merge stacks the layers of overlay on top of base, and merges their configs:
+//
+//   - Env and Labels are merged by key.
+//   - ExposedPorts and Volumes are unioned.
+//   - Entrypoint, Cmd, User, WorkingDir, and StopSignal are taken from
+//     whichever image sets them.
+//
+// Any field that is set differently by both images is reported as a
+// MergeConflict and resolved according to policy. With MergeStrict, conflicts
+// are returned along with an error.
+//
+// The images must be for the same platform. Everything else about base,
+// such as its media types and annotations, is carried over.
+func Merge(base, overlay v1.Image, policy MergePolicy) (v1.Image, []MergeConflict, error) {
+	bcf, err := base.ConfigFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	ocf, err := overlay.ConfigFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ocf.OS != "" && ocf.OS != bcf.OS || ocf.Architecture != "" && ocf.Architecture != bcf.Architecture {
+		return nil, nil, fmt.Errorf("cannot merge %s/%s image onto %s/%s image", ocf.OS, ocf.Architecture, bcf.OS, bcf.Architecture)
+	}
+
+	cfg, conflicts := mergeConfig(bcf.Config, ocf.Config, policy)
+	if policy == MergeStrict && len(conflicts) != 0 {
+		msgs := make([]string, 0, len(conflicts))
+		for _, c := range conflicts {
+			msgs = append(msgs, c.String())
+		}
+		return nil, conflicts, fmt.Errorf("merge conflicts: %s", strings.Join(msgs, "; "))
+	}
+
+	adds, err := overlayAddenda(overlay, ocf)
+	if err != nil {
+		return nil, nil, err
+	}
+	img, err := Append(base, adds...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	cf.Config = cfg
+	img, err = ConfigFile(img, cf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, conflicts, nil
+}
+
+// overlayAddenda returns the layers of overlay, along with their history and
+// descriptor fields, as Addenda for Append.
+func overlayAddenda(overlay v1.Image, ocf *v1.ConfigFile) ([]Addendum, error) {
+	m, err := overlay.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := overlay.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	adds := []Addendum{}
+	next := 0
+	addLayer := func(h v1.History) {
+		desc := m.Layers[next]
+		adds = append(adds, Addendum{
+			Layer:       layers[next],
+			History:     h,
+			URLs:        desc.URLs,
+			Annotations: desc.Annotations,
+		})
+		next++
+	}
+	for _, h := range ocf.History {
+		if h.EmptyLayer {
+			adds = append(adds, Addendum{History: h})
+			continue
+		}
+		if next < len(layers) {
+			addLayer(h)
+		}
+	}
+	// Tolerate missing history.
+	for next < len(layers) {
+		addLayer(v1.History{})
+	}
+	return adds, nil
+}
+
+func mergeConfig(base, overlay v1.Config, policy MergePolicy) (v1.Config, []MergeConflict) {
+	var conflicts []MergeConflict
+	resolve := func(field, b, o string) string {
+		switch {
+		case o == "" || o == b:
+			return b
+		case b == "":
+			return o
+		}
+		conflicts = append(conflicts, MergeConflict{Field: field, Base: b, Overlay: o})
+		if policy == MergePreferOverlay {
+			return o
+		}
+		return b
+	}
+
+	resolveArgs := func(field string, b, o []string) []string {
+		if resolve(field, joinArgs(b), joinArgs(o)) == joinArgs(b) {
+			return b
+		}
+		return o
+	}
+
+	cfg := *base.DeepCopy()
+	cfg.Entrypoint = resolveArgs("Entrypoint", base.Entrypoint, overlay.Entrypoint)
+	cfg.Cmd = resolveArgs("Cmd", base.Cmd, overlay.Cmd)
+	cfg.User = resolve("User", base.User, overlay.User)
+	cfg.WorkingDir = resolve("WorkingDir", base.WorkingDir, overlay.WorkingDir)
+	cfg.StopSignal = resolve("StopSignal", base.StopSignal, overlay.StopSignal)
+
+	// Env is ordered, so keep base's order and append new keys from overlay.
+	env := map[string]int{}
+	for i, kv := range cfg.Env {
+		k, _ := splitEnv(kv)
+		env[k] = i
+	}
+	for _, kv := range overlay.Env {
+		k, v := splitEnv(kv)
+		i, ok := env[k]
+		if !ok {
+			env[k] = len(cfg.Env)
+			cfg.Env = append(cfg.Env, kv)
+			continue
+		}
+		_, bv := splitEnv(cfg.Env[i])
+		cfg.Env[i] = k + "=" + resolve("Env["+k+"]", bv, v)
+	}
+
+	// Visit labels in order so that conflicts are reported deterministically.
+	labels := make([]string, 0, len(overlay.Labels))
+	for k := range overlay.Labels {
+		labels = append(labels, k)
+	}
+	sort.Strings(labels)
+	for _, k := range labels {
+		if cfg.Labels == nil {
+			cfg.Labels = map[string]string{}
+		}
+		cfg.Labels[k] = resolve("Labels["+k+"]", cfg.Labels[k], overlay.Labels[k])
+	}
+	for k := range overlay.ExposedPorts {
+		if cfg.ExposedPorts == nil {
+			cfg.ExposedPorts = map[string]struct{}{}
+		}
+		cfg.ExposedPorts[k] = struct{}{}
+	}
+	for k := range overlay.Volumes {
+		if cfg.Volumes == nil {
+			cfg.Volumes = map[string]struct{}{}
+		}
+		cfg.Volumes[k] = struct{}{}
+	}
+
+	return cfg, conflicts
+}
+
+func splitEnv(kv string) (string, string) {
+	if i := strings.Index(kv, "="); i >= 0 {
+		return kv[:i], kv[i+1:]
+	}
+	return kv, ""
+}
+
+// joinArgs renders args for comparison and conflict messages.
+func joinArgs(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%q", args)
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func withConfig(t *testing.T, img v1.Image, cfg v1.Config) v1.Image {
+	t.Helper()
+
+	img, err := mutate.Config(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestMerge(t *testing.T) {
+	base := withConfig(t, imageFromLayers(t, layerFromFiles(t, testFile{name: "bin/sh", contents: "#!"})), v1.Config{
+		Entrypoint: []string{"/bin/sh"},
+		Env:        []string{"PATH=/bin", "HOME=/root"},
+		Labels:     map[string]string{"maintainer": "base", "base": "true"},
+	})
+	overlay := withConfig(t, imageFromLayers(t,
+		layerFromFiles(t, testFile{name: "usr/bin/tool", contents: "tool"}),
+		layerFromFiles(t, testFile{name: "etc/tool.conf", contents: "conf"}),
+	), v1.Config{
+		Entrypoint:   []string{"/usr/bin/tool"},
+		Env:          []string{"PATH=/usr/bin:/bin", "TOOL=1"},
+		Labels:       map[string]string{"maintainer": "overlay"},
+		ExposedPorts: map[string]struct{}{"8080/tcp": {}},
+	})
+
+	wantConflicts := []mutate.MergeConflict{{
+		Field:   "Entrypoint",
+		Base:    `["/bin/sh"]`,
+		Overlay: `["/usr/bin/tool"]`,
+	}, {
+		Field:   "Env[PATH]",
+		Base:    "/bin",
+		Overlay: "/usr/bin:/bin",
+	}, {
+		Field:   "Labels[maintainer]",
+		Base:    "base",
+		Overlay: "overlay",
+	}}
+
+	for _, c := range []struct {
+		policy mutate.MergePolicy
+		want   v1.Config
+	}{{
+		policy: mutate.MergePreferBase,
+		want: v1.Config{
+			Entrypoint:   []string{"/bin/sh"},
+			Env:          []string{"PATH=/bin", "HOME=/root", "TOOL=1"},
+			Labels:       map[string]string{"maintainer": "base", "base": "true"},
+			ExposedPorts: map[string]struct{}{"8080/tcp": {}},
+		},
+	}, {
+		policy: mutate.MergePreferOverlay,
+		want: v1.Config{
+			Entrypoint:   []string{"/usr/bin/tool"},
+			Env:          []string{"PATH=/usr/bin:/bin", "HOME=/root", "TOOL=1"},
+			Labels:       map[string]string{"maintainer": "overlay", "base": "true"},
+			ExposedPorts: map[string]struct{}{"8080/tcp": {}},
+		},
+	}} {
+		img, conflicts, err := mutate.Merge(base, overlay, c.policy)
+		if err != nil {
+			t.Fatalf("Merge(%d) = %v", c.policy, err)
+		}
+		if err := validate.Image(img); err != nil {
+			t.Errorf("validate.Image(%d) = %v", c.policy, err)
+		}
+
+		if d := cmp.Diff(wantConflicts, conflicts); d != "" {
+			t.Errorf("conflicts Diff(-want,+got): %s", d)
+		}
+
+		cf, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := cmp.Diff(c.want, cf.Config); d != "" {
+			t.Errorf("config Diff(-want,+got): %s", d)
+		}
+		if got, want := len(cf.RootFS.DiffIDs), 3; got != want {
+			t.Errorf("len(DiffIDs) = %d, want %d", got, want)
+		}
+		if got, want := len(cf.History), 3; got != want {
+			t.Errorf("len(History) = %d, want %d", got, want)
+		}
+	}
+
+	if _, conflicts, err := mutate.Merge(base, overlay, mutate.MergeStrict); err == nil {
+		t.Error("Merge(MergeStrict) should fail")
+	} else if got, want := len(conflicts), len(wantConflicts); got != want {
+		t.Errorf("len(conflicts) = %d, want %d", got, want)
+	}
+}
+
+func TestMergePlatformMismatch(t *testing.T) {
+	base := imageFromLayers(t)
+	overlay, err := mutate.ConfigFile(base, &v1.ConfigFile{OS: "windows", Architecture: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := mutate.Merge(base, overlay, mutate.MergePreferBase); err == nil {
+		t.Error("Merge() of different platforms should fail")
+	}
+}
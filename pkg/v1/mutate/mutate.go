@@ -16,22 +16,18 @@ package mutate
 
 import (
 	"archive/tar"
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/google/go-containerregistry/internal/gzip"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
@@ -44,7 +40,14 @@ type Addendum struct {
 	History     v1.History
 	URLs        []string
 	Annotations map[string]string
-	MediaType   types.MediaType
+
+	// MediaType overrides the media type of Layer. If the new media type
+	// implies a different compression than Layer currently has (e.g. gzip
+	// vs. zstd vs. uncompressed), Layer is recompressed to match.
+	MediaType types.MediaType
+
+	// Estargz converts Layer to eStargz before it is appended.
+	Estargz bool
 }
 
 // AppendLayers applies layers to a base image.
@@ -66,9 +69,21 @@ func Append(base v1.Image, adds ...Addendum) (v1.Image, error) {
 		return nil, err
 	}
 
+	converted := make([]Addendum, 0, len(adds))
+	for _, add := range adds {
+		if add.Layer != nil && (add.MediaType != "" || add.Estargz) {
+			l, err := convertLayer(add.Layer, add.MediaType, add.Estargz)
+			if err != nil {
+				return nil, fmt.Errorf("converting layer: %w", err)
+			}
+			add.Layer = l
+		}
+		converted = append(converted, add)
+	}
+
 	return &image{
 		base: base,
-		adds: adds,
+		adds: converted,
 	}, nil
 }
 
@@ -104,6 +119,25 @@ func RemoveManifests(base v1.ImageIndex, matcher match.Matcher) v1.ImageIndex {
 	}
 }
 
+// IndexReplace replaces every descriptor in base that matches the
+// match.Matcher with add, keeping its position in the index. This is useful
+// for e.g. swapping out the image for a single platform:
+//
+//	idx = IndexReplace(idx, match.PlatformsSatisfied(arm64), IndexAddendum{Add: img})
+//
+// Unless they are set in add.Descriptor, the Platform, Annotations, and URLs
+// of the replaced descriptor are carried over. To remove descriptors from
+// an index, see RemoveManifests.
+func IndexReplace(base v1.ImageIndex, matcher match.Matcher, add IndexAddendum) v1.ImageIndex {
+	return &index{
+		base: base,
+		replace: []replacement{{
+			matcher: matcher,
+			add:     add,
+		}},
+	}
+}
+
 // Config mutates the provided v1.Image to have the provided v1.Config
 func Config(base v1.Image, cfg v1.Config) (v1.Image, error) {
 	cf, err := base.ConfigFile()
@@ -151,12 +185,65 @@ func Annotations(f Annotatable, anns map[string]string) Annotatable {
 			annotations: anns,
 		}
 	}
-	return arbitraryRawManifest{f, anns}
+	return arbitraryRawManifest{a: f, anns: anns}
+}
+
+// Subject mutates the subject on an image or index manifest, making it a
+// referrer of the given descriptor (as in OCI image-spec v1.1).
+//
+// Like Annotations, the input is expected to be a v1.Image or v1.ImageIndex,
+// and returns the same type:
+//
+//     sbom := Subject(img, *desc).(v1.Image)
+//
+// If the input Annotatable is not an Image or ImageIndex, the result will
+// attempt to lazily set the subject on the raw manifest.
+func Subject(f Annotatable, subject v1.Descriptor) Annotatable {
+	if img, ok := f.(v1.Image); ok {
+		return &image{
+			base:    img,
+			subject: &subject,
+		}
+	}
+	if idx, ok := f.(v1.ImageIndex); ok {
+		return &index{
+			base:    idx,
+			subject: &subject,
+		}
+	}
+	return arbitraryRawManifest{a: f, subject: &subject}
+}
+
+// ArtifactType mutates the artifactType on an image or index manifest.
+//
+// Like Annotations, the input is expected to be a v1.Image or v1.ImageIndex,
+// and returns the same type:
+//
+//     sig := ArtifactType(img, "application/vnd.dev.cosign.artifact.sig.v1+json").(v1.Image)
+//
+// If the input Annotatable is not an Image or ImageIndex, the result will
+// attempt to lazily set the artifactType on the raw manifest.
+func ArtifactType(f Annotatable, artifactType string) Annotatable {
+	if img, ok := f.(v1.Image); ok {
+		return &image{
+			base:         img,
+			artifactType: &artifactType,
+		}
+	}
+	if idx, ok := f.(v1.ImageIndex); ok {
+		return &index{
+			base:         idx,
+			artifactType: &artifactType,
+		}
+	}
+	return arbitraryRawManifest{a: f, artifactType: &artifactType}
 }
 
 type arbitraryRawManifest struct {
-	a    Annotatable
-	anns map[string]string
+	a            Annotatable
+	anns         map[string]string
+	subject      *v1.Descriptor
+	artifactType *string
 }
 
 func (a arbitraryRawManifest) RawManifest() ([]byte, error) {
@@ -176,9 +263,15 @@ func (a arbitraryRawManifest) RawManifest() ([]byte, error) {
 		} else {
 			return nil, fmt.Errorf(".annotations is not a map: %T", ann)
 		}
-	} else {
+	} else if a.anns != nil {
 		m["annotations"] = a.anns
 	}
+	if a.subject != nil {
+		m["subject"] = a.subject
+	}
+	if a.artifactType != nil {
+		m["artifactType"] = *a.artifactType
+	}
 	return json.Marshal(m)
 }
 
@@ -238,6 +331,27 @@ func extract(img v1.Image, w io.Writer) error {
 	tarWriter := tar.NewWriter(w)
 	defer tarWriter.Close()
 
+	return walkFlattened(img, func(_ v1.Layer, header *tar.Header, r io.Reader) error {
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if header.Size > 0 {
+			if _, err := io.CopyN(tarWriter, r, header.Size); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// walkFunc is called for every entry that survives in an image's flattened
+// filesystem, along with the layer that entry came from. The reader is only
+// valid until walkFunc returns.
+type walkFunc func(layer v1.Layer, header *tar.Header, r io.Reader) error
+
+// walkFlattened visits the entries of the flattened filesystem of img,
+// honoring whiteouts and overwritten files.
+func walkFlattened(img v1.Image, fn walkFunc) error {
 	fileMap := map[string]bool{}
 
 	layers, err := img.Layers()
@@ -248,65 +362,72 @@ func extract(img v1.Image, w io.Writer) error {
 	// whiteout layers more efficient, since we can just keep track of the removed
 	// files as we see .wh. layers and ignore those in previous layers.
 	for i := len(layers) - 1; i >= 0; i-- {
-		layer := layers[i]
-		layerReader, err := layer.Uncompressed()
+		if err := walkLayer(layers[i], fileMap, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkLayer visits the entries of layer that aren't hidden by the entries in
+// fileMap, which records those of the layers above it, and adds its own.
+//
+// Each layer is closed before the next is opened, since reading a remote
+// layer holds a slot of the concurrency budget until it's closed.
+func walkLayer(layer v1.Layer, fileMap map[string]bool, fn walkFunc) error {
+	layerReader, err := layer.Uncompressed()
+	if err != nil {
+		return fmt.Errorf("reading layer contents: %w", err)
+	}
+	defer layerReader.Close()
+	tarReader := tar.NewReader(layerReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
 		if err != nil {
-			return fmt.Errorf("reading layer contents: %w", err)
+			return fmt.Errorf("reading tar: %w", err)
 		}
-		defer layerReader.Close()
-		tarReader := tar.NewReader(layerReader)
-		for {
-			header, err := tarReader.Next()
-			if errors.Is(err, io.EOF) {
-				break
-			}
-			if err != nil {
-				return fmt.Errorf("reading tar: %w", err)
-			}
 
-			// Some tools prepend everything with "./", so if we don't Clean the
-			// name, we may have duplicate entries, which angers tar-split.
-			header.Name = filepath.Clean(header.Name)
+		// Some tools prepend everything with "./", so if we don't Clean the
+		// name, we may have duplicate entries, which angers tar-split.
+		header.Name = filepath.Clean(header.Name)
 
-			basename := filepath.Base(header.Name)
-			dirname := filepath.Dir(header.Name)
-			tombstone := strings.HasPrefix(basename, whiteoutPrefix)
-			if tombstone {
-				basename = basename[len(whiteoutPrefix):]
-			}
+		basename := filepath.Base(header.Name)
+		dirname := filepath.Dir(header.Name)
+		tombstone := strings.HasPrefix(basename, whiteoutPrefix)
+		if tombstone {
+			basename = basename[len(whiteoutPrefix):]
+		}
 
-			// check if we have seen value before
-			// if we're checking a directory, don't filepath.Join names
-			var name string
-			if header.Typeflag == tar.TypeDir {
-				name = header.Name
-			} else {
-				name = filepath.Join(dirname, basename)
-			}
+		// check if we have seen value before
+		// if we're checking a directory, don't filepath.Join names
+		var name string
+		if header.Typeflag == tar.TypeDir {
+			name = header.Name
+		} else {
+			name = filepath.Join(dirname, basename)
+		}
 
-			if _, ok := fileMap[name]; ok {
-				continue
-			}
+		if _, ok := fileMap[name]; ok {
+			continue
+		}
 
-			// check for a whited out parent directory
-			if inWhiteoutDir(fileMap, name) {
-				continue
-			}
+		// check for a whited out parent directory
+		if inWhiteoutDir(fileMap, name) {
+			continue
+		}
 
-			// mark file as handled. non-directory implicitly tombstones
-			// any entries with a matching (or child) name
-			fileMap[name] = tombstone || !(header.Typeflag == tar.TypeDir)
-			if !tombstone {
-				tarWriter.WriteHeader(header)
-				if header.Size > 0 {
-					if _, err := io.CopyN(tarWriter, tarReader, header.Size); err != nil {
-						return err
-					}
-				}
+		// mark file as handled. non-directory implicitly tombstones
+		// any entries with a matching (or child) name
+		fileMap[name] = tombstone || !(header.Typeflag == tar.TypeDir)
+		if !tombstone {
+			if err := fn(layer, header, tarReader); err != nil {
+				return err
 			}
 		}
 	}
-	return nil
 }
 
 func inWhiteoutDir(fileMap map[string]bool, file string) bool {
@@ -326,8 +447,11 @@ func inWhiteoutDir(fileMap map[string]bool, file string) bool {
 	return false
 }
 
-// Time sets all timestamps in an image to the given timestamp.
+// Time sets all timestamps in an image to the given timestamp, including the
+// modification times of files in its layers. The timestamp is converted to
+// UTC, so the result doesn't depend on the local time zone.
 func Time(img v1.Image, t time.Time) (v1.Image, error) {
+	t = t.UTC()
 	newImage := empty.Image
 
 	layers, err := img.Layers()
@@ -384,53 +508,84 @@ func Time(img v1.Image, t time.Time) (v1.Image, error) {
 }
 
 func layerTime(layer v1.Layer, t time.Time) (v1.Layer, error) {
-	layerReader, err := layer.Uncompressed()
-	if err != nil {
-		return nil, fmt.Errorf("getting layer: %w", err)
-	}
-	defer layerReader.Close()
-	w := new(bytes.Buffer)
-	tarWriter := tar.NewWriter(w)
-	defer tarWriter.Close()
-
-	tarReader := tar.NewReader(layerReader)
-	for {
-		header, err := tarReader.Next()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("reading layer: %w", err)
-		}
+	layer, err := rewriteLayer(layer, func(tr *tar.Reader, tw *tar.Writer) error {
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("reading layer: %w", err)
+			}
 
-		header.ModTime = t
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return nil, fmt.Errorf("writing tar header: %w", err)
-		}
+			header.ModTime = t
+			if !header.AccessTime.IsZero() {
+				header.AccessTime = t
+			}
+			if !header.ChangeTime.IsZero() {
+				header.ChangeTime = t
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return fmt.Errorf("writing tar header: %w", err)
+			}
 
-		if header.Typeflag == tar.TypeReg {
-			// TODO(#1168): This should be lazy, and not buffer the entire layer contents.
-			if _, err = io.CopyN(tarWriter, tarReader, header.Size); err != nil {
-				return nil, fmt.Errorf("writing layer file: %w", err)
+			if header.Typeflag == tar.TypeReg {
+				if _, err = io.CopyN(tw, tr, header.Size); err != nil {
+					return fmt.Errorf("writing layer file: %w", err)
+				}
 			}
 		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating layer: %w", err)
 	}
 
-	if err := tarWriter.Close(); err != nil {
+	return layer, nil
+}
+
+// IndexTime sets all timestamps in every image of an index, recursively, to
+// the given timestamp (see Time). This produces fully timestamp-normalized
+// multi-platform images in one call.
+func IndexTime(idx v1.ImageIndex, t time.Time) (v1.ImageIndex, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
 		return nil, err
 	}
 
-	b := w.Bytes()
-	// gzip the contents, then create the layer
-	opener := func() (io.ReadCloser, error) {
-		return gzip.ReadCloser(ioutil.NopCloser(bytes.NewReader(b))), nil
-	}
-	layer, err = tarball.LayerFromOpener(opener)
-	if err != nil {
-		return nil, fmt.Errorf("creating layer: %w", err)
+	replace := []replacement{}
+	for _, desc := range im.Manifests {
+		var add Appendable
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if add, err = IndexTime(child, t); err != nil {
+				return nil, fmt.Errorf("setting time for %s: %w", desc.Digest, err)
+			}
+		case desc.MediaType.IsImage():
+			child, err := idx.Image(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if add, err = Time(child, t); err != nil {
+				return nil, fmt.Errorf("setting time for %s: %w", desc.Digest, err)
+			}
+		default:
+			continue
+		}
+
+		replace = append(replace, replacement{
+			matcher: match.Digests(desc.Digest),
+			add:     IndexAddendum{Add: add},
+		})
 	}
 
-	return layer, nil
+	return &index{
+		base:    idx,
+		replace: replace,
+	}, nil
 }
 
 // Canonical is a helper function to combine Time and configFile
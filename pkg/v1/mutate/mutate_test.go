@@ -17,6 +17,7 @@ package mutate_test
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"io"
 	"io/ioutil"
@@ -32,6 +33,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/stream"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
@@ -306,6 +308,60 @@ func TestAnnotations(t *testing.T) {
 	}
 }
 
+func TestSubjectAndArtifactType(t *testing.T) {
+	subject, err := partial.Descriptor(empty.Image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := "application/vnd.example.sbom"
+
+	for _, c := range []struct {
+		desc string
+		in   mutate.Annotatable
+	}{{
+		desc: "image",
+		in:   empty.Image,
+	}, {
+		desc: "index",
+		in:   empty.Index,
+	}, {
+		desc: "arbitrary",
+		in:   arbitrary{},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			a := mutate.ArtifactType(mutate.Subject(c.in, *subject), at)
+			b, err := a.RawManifest()
+			if err != nil {
+				t.Fatalf("RawManifest: %v", err)
+			}
+			var got struct {
+				Subject      *v1.Descriptor `json:"subject"`
+				ArtifactType string         `json:"artifactType"`
+			}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if d := cmp.Diff(subject, got.Subject); d != "" {
+				t.Errorf("subject Diff(-want,+got): %s", d)
+			}
+			if got.ArtifactType != at {
+				t.Errorf("artifactType = %q, want %q", got.ArtifactType, at)
+			}
+		})
+	}
+
+	// Appending an artifact to an index propagates its artifactType.
+	img := mutate.ArtifactType(empty.Image, at).(v1.Image)
+	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := im.Manifests[0].ArtifactType; got != at {
+		t.Errorf("descriptor artifactType = %q, want %q", got, at)
+	}
+}
+
 func TestMutateCreatedAt(t *testing.T) {
 	source := sourceImage(t)
 	want := time.Now().Add(-2 * time.Minute)
@@ -342,6 +398,68 @@ func TestMutateTime(t *testing.T) {
 	}
 }
 
+func TestIndexTime(t *testing.T) {
+	child, err := random.Index(1024, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: child},
+		mutate.IndexAddendum{Add: sourceImage(t)},
+	)
+
+	want := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+	result, err := mutate.IndexTime(idx, want)
+	if err != nil {
+		t.Fatalf("IndexTime() = %v", err)
+	}
+	if err := validate.Index(result); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+
+	var check func(idx v1.ImageIndex)
+	check = func(idx v1.ImageIndex) {
+		im, err := idx.IndexManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, desc := range im.Manifests {
+			if desc.MediaType.IsIndex() {
+				child, err := idx.ImageIndex(desc.Digest)
+				if err != nil {
+					t.Fatal(err)
+				}
+				check(child)
+				continue
+			}
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := getConfigFile(t, img).Created.Time; !got.Equal(want) {
+				t.Errorf("Created = %v, want %v", got, want)
+			}
+			for _, layer := range getLayers(t, img) {
+				assertMTime(t, layer, want)
+			}
+		}
+	}
+	check(result)
+
+	// The result doesn't depend on the time zone.
+	local, err := mutate.IndexTime(idx, want.In(time.FixedZone("UTC+8", 8*60*60)))
+	if err != nil {
+		t.Fatalf("IndexTime() = %v", err)
+	}
+	if got, err := local.Digest(); err != nil {
+		t.Fatal(err)
+	} else if d, err := result.Digest(); err != nil {
+		t.Fatal(err)
+	} else if got != d {
+		t.Errorf("Digest() = %s, want %s", got, d)
+	}
+}
+
 func TestMutateMediaType(t *testing.T) {
 	want := types.OCIManifestSchema1
 	wantCfg := types.OCIConfigJSON
@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// RelocateLayer returns a copy of layer with every path at or under from moved
+// to the same place under to, e.g. from "/app" to "/srv/app". Whiteouts,
+// hardlinks, and absolute symlinks that refer to from are rewritten to match.
+// Relative symlinks are left alone.
+//
+// Parent directories of to are not created; container runtimes create any
+// that are missing when the layer is extracted.
+func RelocateLayer(layer v1.Layer, from, to string) (v1.Layer, error) {
+	from, to = cleanPath(from), cleanPath(to)
+	if from == "" || to == "" {
+		return nil, errors.New("cannot relocate the root directory")
+	}
+	if from == to {
+		return layer, nil
+	}
+
+	return rewriteLayer(layer, func(tr *tar.Reader, tw *tar.Writer) error {
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			header.Name = relocateName(header.Name, from, to)
+			switch header.Typeflag {
+			case tar.TypeLink:
+				header.Linkname = relocate(header.Linkname, from, to)
+			case tar.TypeSymlink:
+				if path.IsAbs(header.Linkname) {
+					header.Linkname = relocate(header.Linkname, from, to)
+				}
+			}
+
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// Relocate returns a copy of img with every path at or under from moved to
+// the same place under to, by rewriting each of its layers with
+// RelocateLayer. This is useful to repackage images to a different directory
+// layout without rebuilding them.
+//
+// The config is carried over unchanged, so e.g. an Entrypoint or WorkingDir
+// that refers to from must be updated separately.
+func Relocate(img v1.Image, from, to string) (v1.Image, error) {
+	if cleanPath(from) == "" || cleanPath(to) == "" {
+		return nil, errors.New("cannot relocate the root directory")
+	}
+	return rewriteLayers(img, func(i int, layer v1.Layer) (v1.Layer, error) {
+		l, err := RelocateLayer(layer, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("relocating %s to %s: %w", from, to, err)
+		}
+		return l, nil
+	})
+}
+
+// cleanPath normalizes p to the form used for names in a tar, e.g. "app/bin".
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// relocate moves p to to if it's at or under from, preserving its style
+// (e.g. a leading "./" or "/" and a trailing "/").
+func relocate(p, from, to string) string {
+	rest := p
+	for {
+		if strings.HasPrefix(rest, "./") {
+			rest = rest[2:]
+		} else if strings.HasPrefix(rest, "/") {
+			rest = rest[1:]
+		} else {
+			break
+		}
+	}
+	if rest == from || strings.HasPrefix(rest, from+"/") {
+		return p[:len(p)-len(rest)] + to + rest[len(from):]
+	}
+	return p
+}
+
+// relocateName is like relocate, but also moves whiteouts for from itself.
+func relocateName(name, from, to string) string {
+	dir, base := path.Split(name)
+	if strings.HasPrefix(base, whiteoutPrefix) && base != whiteoutPrefix+whiteoutPrefix+".opq" {
+		hidden := dir + strings.TrimPrefix(base, whiteoutPrefix)
+		if target := relocate(hidden, from, to); target != hidden {
+			dir, base = path.Split(target)
+			return dir + whiteoutPrefix + base
+		}
+	}
+	return relocate(name, from, to)
+}
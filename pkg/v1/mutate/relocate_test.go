@@ -0,0 +1,97 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestRelocate(t *testing.T) {
+	img := imageFromLayers(t, layerFromFiles(t,
+		testFile{name: "app/", typeflag: tar.TypeDir, mode: 0755},
+		testFile{name: "app/main", contents: "main"},
+		testFile{name: "./app/lib/config", contents: "config"},
+		testFile{name: "app/link", typeflag: tar.TypeLink, linkname: "app/main"},
+		testFile{name: "usr/bin/main", typeflag: tar.TypeSymlink, linkname: "/app/main"},
+		testFile{name: "usr/bin/rel", typeflag: tar.TypeSymlink, linkname: "../../app/main"},
+		testFile{name: "apple", contents: "not app"},
+	), layerFromFiles(t,
+		testFile{name: ".wh.app"},
+		testFile{name: "app/.wh..wh..opq"},
+		testFile{name: "app/.wh.main"},
+	))
+
+	relocated, err := mutate.Relocate(img, "/app", "/srv/app")
+	if err != nil {
+		t.Fatalf("Relocate() = %v", err)
+	}
+	if err := validate.Image(relocated); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	layers := getLayers(t, relocated)
+	type entry struct{ Name, Linkname string }
+	want := [][]entry{{
+		{"srv/app/", ""},
+		{"srv/app/main", ""},
+		{"./srv/app/lib/config", ""},
+		{"srv/app/link", "srv/app/main"},
+		{"usr/bin/main", "/srv/app/main"},
+		{"usr/bin/rel", "../../app/main"},
+		{"apple", ""},
+	}, {
+		{"srv/.wh.app", ""},
+		{"srv/app/.wh..wh..opq", ""},
+		{"srv/app/.wh.main", ""},
+	}}
+	for i, layer := range layers {
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []entry
+		tr := tar.NewReader(rc)
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, entry{header.Name, header.Linkname})
+		}
+		rc.Close()
+		if d := cmp.Diff(want[i], got); d != "" {
+			t.Errorf("layer %d Diff(-want,+got): %s", i, d)
+		}
+	}
+}
+
+func TestRelocateRoot(t *testing.T) {
+	img := imageFromLayers(t)
+	for _, c := range [][2]string{{"/", "/srv"}, {"/app", "."}} {
+		if _, err := mutate.Relocate(img, c[0], c[1]); err == nil {
+			t.Errorf("Relocate(%q, %q) should fail", c[0], c[1])
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/annotations"
+)
+
+// The pre-defined OCI annotation keys that Stamp sets.
+const (
+	AnnotationCreated  = annotations.Created
+	AnnotationSource   = annotations.Source
+	AnnotationRevision = annotations.Revision
+)
+
+// VCSInfo describes the version-controlled source an image was built from.
+// Zero fields are ignored.
+type VCSInfo struct {
+	// Source is the URL of the repository, e.g. https://github.com/google/go-containerregistry
+	Source string
+
+	// Revision identifies the source within the repository, e.g. a git commit SHA.
+	Revision string
+
+	// Created is when the image was built. To get reproducible images, use the
+	// commit time of Revision.
+	Created time.Time
+}
+
+// Stamp records info in img, setting the OCI source, revision, and created
+// annotations on both the manifest and the config labels, and the config's
+// created time.
+func Stamp(img v1.Image, info VCSInfo) (v1.Image, error) {
+	// Record the same instant in the same zone everywhere.
+	created := info.Created.UTC()
+
+	anns := map[string]string{}
+	if info.Source != "" {
+		anns[AnnotationSource] = info.Source
+	}
+	if info.Revision != "" {
+		anns[AnnotationRevision] = info.Revision
+	}
+	if !info.Created.IsZero() {
+		anns[AnnotationCreated] = created.Format(time.RFC3339)
+	}
+	if len(anns) == 0 {
+		return img, nil
+	}
+
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cfg := cf.DeepCopy()
+	if cfg.Config.Labels == nil {
+		cfg.Config.Labels = map[string]string{}
+	}
+	for k, v := range anns {
+		cfg.Config.Labels[k] = v
+	}
+	if !info.Created.IsZero() {
+		cfg.Created = v1.Time{Time: created}
+	}
+
+	img, err = ConfigFile(img, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return Annotations(img, anns).(v1.Image), nil
+}
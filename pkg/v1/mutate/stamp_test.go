@@ -0,0 +1,106 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestStamp(t *testing.T) {
+	created := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+	img, err := mutate.Stamp(empty.Image, mutate.VCSInfo{
+		Source:   "https://github.com/google/go-containerregistry",
+		Revision: "deadbeef",
+		Created:  created,
+	})
+	if err != nil {
+		t.Fatalf("Stamp() = %v", err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	want := map[string]string{
+		"org.opencontainers.image.source":   "https://github.com/google/go-containerregistry",
+		"org.opencontainers.image.revision": "deadbeef",
+		"org.opencontainers.image.created":  "2022-03-01T12:00:00Z",
+	}
+
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(want, m.Annotations); d != "" {
+		t.Errorf("annotations Diff(-want,+got): %s", d)
+	}
+
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(want, cf.Config.Labels); d != "" {
+		t.Errorf("labels Diff(-want,+got): %s", d)
+	}
+	if !cf.Created.Time.Equal(created) {
+		t.Errorf("Created = %v, want %v", cf.Created.Time, created)
+	}
+}
+
+func TestStampUTC(t *testing.T) {
+	created := time.Date(2022, time.March, 1, 13, 0, 0, 0, time.FixedZone("CET", 60*60))
+	img, err := mutate.Stamp(empty.Image, mutate.VCSInfo{Created: created})
+	if err != nil {
+		t.Fatalf("Stamp() = %v", err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cf.Config.Labels["org.opencontainers.image.created"], "2022-03-01T12:00:00Z"; got != want {
+		t.Errorf("created label = %q, want %q", got, want)
+	}
+	if got, want := cf.Created.Time.Format(time.RFC3339), "2022-03-01T12:00:00Z"; got != want {
+		t.Errorf("Created = %q, want %q", got, want)
+	}
+}
+
+func TestStampPartial(t *testing.T) {
+	img, err := mutate.Stamp(empty.Image, mutate.VCSInfo{Revision: "deadbeef"})
+	if err != nil {
+		t.Fatalf("Stamp() = %v", err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(map[string]string{"org.opencontainers.image.revision": "deadbeef"}, cf.Config.Labels); d != "" {
+		t.Errorf("labels Diff(-want,+got): %s", d)
+	}
+	if !cf.Created.IsZero() {
+		t.Errorf("Created = %v, want zero", cf.Created)
+	}
+
+	if img, err := mutate.Stamp(empty.Image, mutate.VCSInfo{}); err != nil {
+		t.Fatalf("Stamp() = %v", err)
+	} else if img != empty.Image {
+		t.Errorf("Stamp() with no info should return the input image")
+	}
+}
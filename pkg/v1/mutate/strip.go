@@ -0,0 +1,137 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+)
+
+// StripImage returns img without nonessential metadata, for environments
+// that only want what's needed to run it:
+//
+//   - the config's history, which records how each layer was built
+//   - the given annotation keys, from the manifest and its descriptors
+//
+// The filesystem and runtime config are left untouched.
+func StripImage(img v1.Image, annotations ...string) (v1.Image, error) {
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cf = cf.DeepCopy()
+	cf.History = nil
+
+	return &image{
+		base:            img,
+		configFile:      cf,
+		dropAnnotations: annotations,
+	}, nil
+}
+
+// StripIndex returns idx without anything attached to its images, for
+// environments that forbid extra artifacts:
+//
+//   - attestation manifests (see match.Attestations)
+//   - artifacts such as signatures and SBOMs, i.e. children that set an
+//     artifactType or a subject
+//   - the given annotation keys, from every manifest and descriptor
+//
+// Every remaining child is stripped recursively with StripImage or StripIndex.
+func StripIndex(idx v1.ImageIndex, annotations ...string) (v1.ImageIndex, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := []v1.Hash{}
+	replace := []replacement{}
+	for _, desc := range im.Manifests {
+		if match.Attestations()(desc) || match.Artifacts()(desc) {
+			continue
+		}
+
+		var add Appendable
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			m, err := child.IndexManifest()
+			if err != nil {
+				return nil, err
+			}
+			if m.Subject != nil || m.ArtifactType != "" {
+				artifacts = append(artifacts, desc.Digest)
+				continue
+			}
+			if add, err = StripIndex(child, annotations...); err != nil {
+				return nil, fmt.Errorf("stripping %s: %w", desc.Digest, err)
+			}
+		case desc.MediaType.IsImage():
+			child, err := idx.Image(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			m, err := child.Manifest()
+			if err != nil {
+				return nil, err
+			}
+			if m.Subject != nil || m.ArtifactType != "" {
+				artifacts = append(artifacts, desc.Digest)
+				continue
+			}
+			if add, err = StripImage(child, annotations...); err != nil {
+				return nil, fmt.Errorf("stripping %s: %w", desc.Digest, err)
+			}
+		default:
+			// We don't know how to strip anything else, so leave it alone.
+			continue
+		}
+
+		replace = append(replace, replacement{
+			matcher: match.Digests(desc.Digest),
+			add:     IndexAddendum{Add: add},
+		})
+	}
+
+	return &index{
+		base:            idx,
+		remove:          match.Any(match.Attestations(), match.Artifacts(), match.Digests(artifacts...)),
+		replace:         replace,
+		dropAnnotations: annotations,
+	}, nil
+}
+
+// withoutKeys returns a copy of m without keys, or nil if nothing is left.
+func withoutKeys(m map[string]string, keys []string) map[string]string {
+	if len(m) == 0 {
+		return m
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	for _, k := range keys {
+		delete(out, k)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestStripImage(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = mutate.Append(img, mutate.Addendum{
+		Layer:       layerFromFiles(t, testFile{name: "foo", contents: "bar"}),
+		History:     v1.History{CreatedBy: "RUN echo bar > foo"},
+		Annotations: map[string]string{"foo": "bar"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.Annotations(img, map[string]string{"foo": "bar", "keep": "me"}).(v1.Image)
+
+	stripped, err := mutate.StripImage(img, "foo")
+	if err != nil {
+		t.Fatalf("StripImage() = %v", err)
+	}
+	if err := validate.Image(stripped); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	cf, err := stripped.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cf.History) != 0 {
+		t.Errorf("History = %v, want none", cf.History)
+	}
+
+	m, err := stripped.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(map[string]string{"keep": "me"}, m.Annotations); d != "" {
+		t.Errorf("annotations Diff(-want,+got): %s", d)
+	}
+	for _, l := range m.Layers {
+		if l.Annotations != nil {
+			t.Errorf("layer %s annotations = %v, want none", l.Digest, l.Annotations)
+		}
+	}
+}
+
+func TestStripIndex(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	att, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgDesc, err := partial.Descriptor(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbom := mutate.ArtifactType(empty.Image, "application/vnd.example.sbom").(v1.Image)
+	sig := mutate.Subject(empty.Image, *imgDesc).(v1.Image)
+
+	platform := &v1.Platform{OS: "linux", Architecture: "amd64"}
+	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add: img,
+		Descriptor: v1.Descriptor{
+			Platform:    platform,
+			Annotations: map[string]string{"foo": "bar", "keep": "me"},
+		},
+	}, mutate.IndexAddendum{
+		Add: att,
+		Descriptor: v1.Descriptor{
+			Platform:    &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{"vnd.docker.reference.type": "attestation-manifest"},
+		},
+	}, mutate.IndexAddendum{
+		Add: sbom,
+	}, mutate.IndexAddendum{
+		Add: sig,
+	})
+	idx = mutate.Annotations(idx, map[string]string{"foo": "bar"}).(v1.ImageIndex)
+
+	stripped, err := mutate.StripIndex(idx, "foo")
+	if err != nil {
+		t.Fatalf("StripIndex() = %v", err)
+	}
+	if err := validate.Index(stripped); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+
+	im, err := stripped.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if im.Annotations != nil {
+		t.Errorf("index annotations = %v, want none", im.Annotations)
+	}
+	if got, want := len(im.Manifests), 1; got != want {
+		t.Fatalf("len(Manifests) = %d, want %d", got, want)
+	}
+	desc := im.Manifests[0]
+	if d := cmp.Diff(platform, desc.Platform); d != "" {
+		t.Errorf("platform Diff(-want,+got): %s", d)
+	}
+	if d := cmp.Diff(map[string]string{"keep": "me"}, desc.Annotations); d != "" {
+		t.Errorf("annotations Diff(-want,+got): %s", d)
+	}
+
+	child, err := stripped.Image(desc.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := child.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cf.History) != 0 {
+		t.Errorf("History = %v, want none", cf.History)
+	}
+}
@@ -15,10 +15,11 @@
 package partial
 
 import (
+	"context"
 	"io"
 
 	"github.com/google/go-containerregistry/internal/and"
-	"github.com/google/go-containerregistry/internal/gzip"
+	"github.com/google/go-containerregistry/internal/compression"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -50,11 +51,25 @@ func (cle *compressedLayerExtender) Uncompressed() (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
+	return decompress(rc)
+}
+
+// UncompressedWithContext decompresses the result of CompressedWithContext.
+// See partial.UncompressedWithContext.
+func (cle *compressedLayerExtender) UncompressedWithContext(ctx context.Context) (io.ReadCloser, error) {
+	rc, err := CompressedWithContext(ctx, cle)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(rc)
+}
 
-	// Often, the "compressed" bytes are not actually gzip-compressed.
-	// Peek at the first two bytes to determine whether or not it's correct to
-	// wrap this with gzip.UnzipReadCloser.
-	gzipped, pr, err := gzip.Peek(rc)
+// decompress returns the decompressed contents of rc.
+func decompress(rc io.ReadCloser) (io.ReadCloser, error) {
+	// Often, the "compressed" bytes are not actually compressed.
+	// Peek at the first few bytes to determine whether or not it's correct to
+	// wrap this with a decompressor (and which one).
+	comp, pr, err := compression.Peek(rc)
 	if err != nil {
 		return nil, err
 	}
@@ -63,11 +78,7 @@ func (cle *compressedLayerExtender) Uncompressed() (io.ReadCloser, error) {
 		CloseFunc: rc.Close,
 	}
 
-	if !gzipped {
-		return prc, nil
-	}
-
-	return gzip.UnzipReadCloser(prc)
+	return compression.Decompress(prc, comp)
 }
 
 // DiffID implements v1.Layer
@@ -120,9 +131,18 @@ func (i *compressedImageExtender) Digest() (v1.Hash, error) {
 
 // ConfigName implements v1.Image
 func (i *compressedImageExtender) ConfigName() (v1.Hash, error) {
+	// Cores that know the config's digest, e.g. from their manifest, can
+	// answer without reading and hashing the config.
+	if wcn, ok := i.CompressedImageCore.(withConfigName); ok {
+		return wcn.ConfigName()
+	}
 	return ConfigName(i)
 }
 
+type withConfigName interface {
+	ConfigName() (v1.Hash, error)
+}
+
 // Layers implements v1.Image
 func (i *compressedImageExtender) Layers() ([]v1.Layer, error) {
 	hs, err := FSLayers(i)
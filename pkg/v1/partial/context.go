@@ -0,0 +1,134 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	"context"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+type withRawManifestWithContext interface {
+	RawManifestWithContext(context.Context) ([]byte, error)
+}
+
+type withRawConfigFileWithContext interface {
+	RawConfigFileWithContext(context.Context) ([]byte, error)
+}
+
+type withCompressedWithContext interface {
+	CompressedWithContext(context.Context) (io.ReadCloser, error)
+}
+
+type withUncompressedWithContext interface {
+	UncompressedWithContext(context.Context) (io.ReadCloser, error)
+}
+
+// RawManifestWithContext returns the serialized manifest of i. If i
+// implements RawManifestWithContext, e.g. because it fetches the manifest
+// from a registry, fetching it is cancelled when ctx is done.
+func RawManifestWithContext(ctx context.Context, i WithRawManifest) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if wc, ok := unwrap(i).(withRawManifestWithContext); ok {
+		return wc.RawManifestWithContext(ctx)
+	}
+	return i.RawManifest()
+}
+
+// RawConfigFileWithContext returns the serialized config file of i, like
+// RawManifestWithContext.
+func RawConfigFileWithContext(ctx context.Context, i WithRawConfigFile) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if wc, ok := unwrap(i).(withRawConfigFileWithContext); ok {
+		return wc.RawConfigFileWithContext(ctx)
+	}
+	return i.RawConfigFile()
+}
+
+// LayersWithContext returns the layers of img, using ctx to fetch its
+// manifest if needed. The layers' contents aren't fetched; use
+// CompressedWithContext or UncompressedWithContext for that.
+func LayersWithContext(ctx context.Context, img v1.Image) ([]v1.Layer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Images that fetch their manifest memoize it, so fetching it first with
+	// ctx means that Layers won't fetch it again without. Don't compute the
+	// manifest otherwise: that may need the digests of streaming layers.
+	if wc, ok := unwrap(img).(withRawManifestWithContext); ok {
+		if _, err := wc.RawManifestWithContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return img.Layers()
+}
+
+// CompressedWithContext returns the compressed contents of l. If l
+// implements CompressedWithContext, e.g. because it fetches its contents
+// from a registry, the fetch is cancelled when ctx is done. Otherwise, reads
+// from the result fail once ctx is done.
+func CompressedWithContext(ctx context.Context, l v1.Layer) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if wc, ok := unwrap(l).(withCompressedWithContext); ok {
+		return wc.CompressedWithContext(ctx)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	return &contextReader{ctx: ctx, ReadCloser: rc}, nil
+}
+
+// UncompressedWithContext returns the uncompressed contents of l, like
+// CompressedWithContext.
+func UncompressedWithContext(ctx context.Context, l v1.Layer) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Check l itself first: the layers of compressed images decompress their
+	// CompressedWithContext.
+	if wc, ok := l.(withUncompressedWithContext); ok {
+		return wc.UncompressedWithContext(ctx)
+	}
+	if wc, ok := unwrap(l).(withUncompressedWithContext); ok {
+		return wc.UncompressedWithContext(ctx)
+	}
+	rc, err := l.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	return &contextReader{ctx: ctx, ReadCloser: rc}, nil
+}
+
+// contextReader fails reads once ctx is done, for layers that can't cancel
+// reads themselves.
+type contextReader struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.ReadCloser.Read(p)
+}
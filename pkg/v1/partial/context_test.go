@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ctxLayer is a compressed layer that records the context it's read with.
+type ctxLayer struct {
+	contents []byte
+	ctx      context.Context
+}
+
+func (l *ctxLayer) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(l.contents))
+	return h, err
+}
+
+func (l *ctxLayer) Compressed() (io.ReadCloser, error) {
+	return l.CompressedWithContext(context.Background())
+}
+
+func (l *ctxLayer) CompressedWithContext(ctx context.Context) (io.ReadCloser, error) {
+	l.ctx = ctx
+	return ioutil.NopCloser(bytes.NewReader(l.contents)), nil
+}
+
+func (l *ctxLayer) Size() (int64, error) {
+	return int64(len(l.contents)), nil
+}
+
+func (l *ctxLayer) MediaType() (types.MediaType, error) {
+	return types.DockerUncompressedLayer, nil
+}
+
+type key struct{}
+
+func TestCompressedWithContext(t *testing.T) {
+	cl := &ctxLayer{contents: []byte("hello")}
+	l, err := partial.CompressedToLayer(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	for _, read := range []func(context.Context, v1.Layer) (io.ReadCloser, error){
+		partial.CompressedWithContext,
+		partial.UncompressedWithContext,
+	} {
+		cl.ctx = nil
+		rc, err := read(ctx, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "hello" {
+			t.Errorf("read %q, want hello", b)
+		}
+		if cl.ctx == nil || cl.ctx.Value(key{}) != "value" {
+			t.Errorf("layer was read with %v, want the given context", cl.ctx)
+		}
+	}
+}
+
+func TestWithContextFallback(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if _, err := partial.RawManifestWithContext(ctx, img); err != nil {
+		t.Errorf("RawManifestWithContext() = %v", err)
+	}
+	if _, err := partial.RawConfigFileWithContext(ctx, img); err != nil {
+		t.Errorf("RawConfigFileWithContext() = %v", err)
+	}
+	ls, err := partial.LayersWithContext(ctx, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := partial.UncompressedWithContext(ctx, ls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := rc.Read(make([]byte, 1)); err != nil {
+		t.Errorf("Read() = %v", err)
+	}
+
+	// Layers that can't cancel their reads fail them once ctx is done.
+	cancel()
+	if _, err := ioutil.ReadAll(rc); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadAll() after cancel = %v, want %v", err, context.Canceled)
+	}
+	if _, err := partial.CompressedWithContext(ctx, ls[0]); !errors.Is(err, context.Canceled) {
+		t.Errorf("CompressedWithContext() = %v, want %v", err, context.Canceled)
+	}
+	if _, err := partial.LayersWithContext(ctx, img); !errors.Is(err, context.Canceled) {
+		t.Errorf("LayersWithContext() = %v, want %v", err, context.Canceled)
+	}
+}
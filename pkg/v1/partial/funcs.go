@@ -0,0 +1,258 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/google/go-containerregistry/internal/verify"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ManifestFunc returns the serialized bytes of a manifest.
+type ManifestFunc func() ([]byte, error)
+
+// BlobFunc returns the contents of the blob with the given digest.
+type BlobFunc func(v1.Hash) (io.ReadCloser, error)
+
+// ImageFromFuncs returns a v1.Image whose manifest is returned by manifest and
+// whose config and layers are returned by blob. This lets custom storage
+// backends expose images without implementing all of v1.Image.
+//
+// The manifest is only fetched once. Blobs are verified against the digest
+// and size in the manifest as they are read.
+func ImageFromFuncs(manifest ManifestFunc, blob BlobFunc) (v1.Image, error) {
+	return CompressedToImage(&funcsImage{
+		manifest: &memoManifest{fetch: manifest},
+		blob:     blob,
+	})
+}
+
+// IndexFromFuncs returns a v1.ImageIndex whose manifest is returned by
+// manifest. Child manifests, and their configs and layers, are returned by
+// blob, which is how most storage backends address them.
+//
+// The manifest is only fetched once. Child manifests and blobs are verified
+// against the digests and sizes in their parents as they are read.
+func IndexFromFuncs(manifest ManifestFunc, blob BlobFunc) (v1.ImageIndex, error) {
+	return &funcsIndex{
+		manifest: &memoManifest{fetch: manifest},
+		blob:     blob,
+	}, nil
+}
+
+// memoManifest fetches a manifest once.
+type memoManifest struct {
+	fetch ManifestFunc
+
+	once sync.Once
+	raw  []byte
+	err  error
+}
+
+func (m *memoManifest) get() ([]byte, error) {
+	m.once.Do(func() {
+		m.raw, m.err = m.fetch()
+	})
+	return m.raw, m.err
+}
+
+// mediaType returns the mediaType field of the manifest, or def if it's unset.
+func (m *memoManifest) mediaType(def types.MediaType) (types.MediaType, error) {
+	raw, err := m.get()
+	if err != nil {
+		return "", err
+	}
+	var mt struct {
+		MediaType types.MediaType `json:"mediaType"`
+	}
+	if err := json.Unmarshal(raw, &mt); err != nil {
+		return "", err
+	}
+	if mt.MediaType == "" {
+		return def, nil
+	}
+	return mt.MediaType, nil
+}
+
+// readBlob reads and verifies the blob described by desc.
+func readBlob(blob BlobFunc, desc v1.Descriptor) ([]byte, error) {
+	rc, err := blob(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	vrc, err := verify.ReadCloser(rc, desc.Size, desc.Digest)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	defer vrc.Close()
+	return ioutil.ReadAll(vrc)
+}
+
+type funcsImage struct {
+	manifest *memoManifest
+	blob     BlobFunc
+}
+
+var _ CompressedImageCore = (*funcsImage)(nil)
+
+// RawManifest implements CompressedImageCore.
+func (i *funcsImage) RawManifest() ([]byte, error) {
+	return i.manifest.get()
+}
+
+// MediaType implements CompressedImageCore.
+func (i *funcsImage) MediaType() (types.MediaType, error) {
+	return i.manifest.mediaType(types.OCIManifestSchema1)
+}
+
+// RawConfigFile implements CompressedImageCore.
+func (i *funcsImage) RawConfigFile() ([]byte, error) {
+	m, err := Manifest(i)
+	if err != nil {
+		return nil, err
+	}
+	return readBlob(i.blob, m.Config)
+}
+
+// LayerByDigest implements CompressedImageCore.
+func (i *funcsImage) LayerByDigest(h v1.Hash) (CompressedLayer, error) {
+	m, err := Manifest(i)
+	if err != nil {
+		return nil, err
+	}
+	if m.Config.Digest == h {
+		return &funcsLayer{desc: m.Config, blob: i.blob}, nil
+	}
+	for _, desc := range m.Layers {
+		if desc.Digest == h {
+			return &funcsLayer{desc: desc, blob: i.blob}, nil
+		}
+	}
+	return nil, fmt.Errorf("blob %v not found", h)
+}
+
+type funcsLayer struct {
+	desc v1.Descriptor
+	blob BlobFunc
+}
+
+var _ CompressedLayer = (*funcsLayer)(nil)
+
+// Digest implements CompressedLayer.
+func (l *funcsLayer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+// Size implements CompressedLayer.
+func (l *funcsLayer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+// MediaType implements CompressedLayer.
+func (l *funcsLayer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
+
+// Descriptor implements withDescriptor.
+func (l *funcsLayer) Descriptor() (*v1.Descriptor, error) {
+	return &l.desc, nil
+}
+
+// Compressed implements CompressedLayer.
+func (l *funcsLayer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.blob(l.desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return verify.ReadCloser(rc, l.desc.Size, l.desc.Digest)
+}
+
+type funcsIndex struct {
+	manifest *memoManifest
+	blob     BlobFunc
+}
+
+var _ v1.ImageIndex = (*funcsIndex)(nil)
+
+// MediaType implements v1.ImageIndex.
+func (i *funcsIndex) MediaType() (types.MediaType, error) {
+	return i.manifest.mediaType(types.OCIImageIndex)
+}
+
+// Digest implements v1.ImageIndex.
+func (i *funcsIndex) Digest() (v1.Hash, error) {
+	return Digest(i)
+}
+
+// Size implements v1.ImageIndex.
+func (i *funcsIndex) Size() (int64, error) {
+	return Size(i)
+}
+
+// RawManifest implements v1.ImageIndex.
+func (i *funcsIndex) RawManifest() ([]byte, error) {
+	return i.manifest.get()
+}
+
+// IndexManifest implements v1.ImageIndex.
+func (i *funcsIndex) IndexManifest() (*v1.IndexManifest, error) {
+	raw, err := i.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	return v1.ParseIndexManifest(bytes.NewReader(raw))
+}
+
+// child returns a ManifestFunc for the child manifest with digest h.
+func (i *funcsIndex) child(h v1.Hash) (ManifestFunc, error) {
+	im, err := i.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range im.Manifests {
+		if desc.Digest == h {
+			return func() ([]byte, error) {
+				return readBlob(i.blob, desc)
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("manifest %v not found", h)
+}
+
+// Image implements v1.ImageIndex.
+func (i *funcsIndex) Image(h v1.Hash) (v1.Image, error) {
+	manifest, err := i.child(h)
+	if err != nil {
+		return nil, err
+	}
+	return ImageFromFuncs(manifest, i.blob)
+}
+
+// ImageIndex implements v1.ImageIndex.
+func (i *funcsIndex) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
+	manifest, err := i.child(h)
+	if err != nil {
+		return nil, err
+	}
+	return IndexFromFuncs(manifest, i.blob)
+}
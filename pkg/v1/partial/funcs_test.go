@@ -0,0 +1,158 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/compare"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// blobStore is a minimal content-addressed storage backend.
+type blobStore map[v1.Hash][]byte
+
+func (s blobStore) blob(h v1.Hash) (io.ReadCloser, error) {
+	b, ok := s[h]
+	if !ok {
+		return nil, fmt.Errorf("blob %s not found", h)
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (s blobStore) putImage(t *testing.T, img v1.Image) {
+	t.Helper()
+
+	raw, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s[h] = raw
+
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, err = img.ConfigName(); err != nil {
+		t.Fatal(err)
+	}
+	s[h] = cfg
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range layers {
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+		s[h] = b
+	}
+}
+
+func TestImageFromFuncs(t *testing.T) {
+	rnd, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := blobStore{}
+	s.putImage(t, rnd)
+
+	img, err := partial.ImageFromFuncs(rnd.RawManifest, s.blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+	if err := compare.Images(rnd, img); err != nil {
+		t.Errorf("compare.Images() = %v", err)
+	}
+
+	// Corrupt a layer and make sure we notice.
+	layers, err := rnd.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s[h] = append([]byte{}, s[h]...)
+	s[h][0] ^= 0xff
+	l, err := img.LayerByDigest(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := ioutil.ReadAll(rc); err == nil {
+		t.Error("reading a corrupted layer should fail")
+	}
+}
+
+func TestIndexFromFuncs(t *testing.T) {
+	rnd, err := random.Index(1024, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := blobStore{}
+	im, err := rnd.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, desc := range im.Manifests {
+		img, err := rnd.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.putImage(t, img)
+	}
+
+	idx, err := partial.IndexFromFuncs(rnd.RawManifest, s.blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(idx); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+	if err := compare.Indexes(rnd, idx); err != nil {
+		t.Errorf("compare.Indexes() = %v", err)
+	}
+}
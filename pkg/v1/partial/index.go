@@ -15,6 +15,7 @@
 package partial
 
 import (
+	"bytes"
 	"fmt"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -83,3 +84,17 @@ func FindIndexes(index v1.ImageIndex, matcher match.Matcher) ([]v1.ImageIndex, e
 	}
 	return matches, nil
 }
+
+// WalkManifests calls walk with each child descriptor of index, in order. The
+// descriptors are decoded from the raw manifest one at a time, so this uses
+// much less memory than IndexManifest for indexes with many children.
+//
+// It stops at the first error returned by walk, and returns it.
+func WalkManifests(index v1.ImageIndex, walk func(v1.Descriptor) error) error {
+	b, err := index.RawManifest()
+	if err != nil {
+		return err
+	}
+	_, err = v1.WalkIndexManifest(bytes.NewReader(b), walk)
+	return err
+}
@@ -119,3 +119,30 @@ func TestFindIndexes(t *testing.T) {
 		t.Errorf("failed on index, actual %d, expected %d", len(idxes), indexCount)
 	}
 }
+
+func TestWalkManifests(t *testing.T) {
+	ii, err := random.Index(100, 1, 5)
+	if err != nil {
+		t.Fatal("could not create random index:", err)
+	}
+	m, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []v1.Hash
+	if err := partial.WalkManifests(ii, func(desc v1.Descriptor) error {
+		got = append(got, desc.Digest)
+		return nil
+	}); err != nil {
+		t.Fatalf("WalkManifests: %v", err)
+	}
+	if len(got) != len(m.Manifests) {
+		t.Fatalf("walked %d manifests, want %d", len(got), len(m.Manifests))
+	}
+	for i, desc := range m.Manifests {
+		if got[i] != desc.Digest {
+			t.Errorf("manifest %d = %s, want %s", i, got[i], desc.Digest)
+		}
+	}
+}
@@ -48,8 +48,17 @@ type uncompressedLayerExtender struct {
 	once          sync.Once
 }
 
+// withCompressed allows an UncompressedLayer to control how it is compressed,
+// e.g. to cache the result.
+type withCompressed interface {
+	Compressed() (io.ReadCloser, error)
+}
+
 // Compressed implements v1.Layer
 func (ule *uncompressedLayerExtender) Compressed() (io.ReadCloser, error) {
+	if wc, ok := ule.UncompressedLayer.(withCompressed); ok {
+		return wc.Compressed()
+	}
 	u, err := ule.Uncompressed()
 	if err != nil {
 		return nil, err
@@ -138,11 +147,22 @@ func (i *uncompressedImageExtender) Manifest() (*v1.Manifest, error) {
 		return nil, err
 	}
 
+	mt, err := i.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	cfgMediaType := types.DockerConfigJSON
+	if mt == types.OCIManifestSchema1 {
+		cfgMediaType = types.OCIConfigJSON
+	} else {
+		mt = types.DockerManifestSchema2
+	}
+
 	m := &v1.Manifest{
 		SchemaVersion: 2,
-		MediaType:     types.DockerManifestSchema2,
+		MediaType:     mt,
 		Config: v1.Descriptor{
-			MediaType: types.DockerConfigJSON,
+			MediaType: cfgMediaType,
 			Size:      cfgSize,
 			Digest:    cfgHash,
 		},
@@ -0,0 +1,206 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	icompression "github.com/google/go-containerregistry/internal/compression"
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// UncompressedOpener opens the uncompressed contents (i.e. the tarball) of
+// the layer with the given DiffID.
+type UncompressedOpener func(diffID v1.Hash) (io.ReadCloser, error)
+
+// UncompressedOption configures UncompressedImage.
+type UncompressedOption func(*uncompressedOptions)
+
+type uncompressedOptions struct {
+	compression compression.Compression
+	cacheDir    string
+}
+
+// WithLayerCompression sets how layers are compressed on the fly, which
+// defaults to gzip. With zstd, the image uses OCI media types.
+func WithLayerCompression(comp compression.Compression) UncompressedOption {
+	return func(o *uncompressedOptions) {
+		o.compression = comp
+	}
+}
+
+// WithCompressedCache stores the compressed contents of each layer in dir
+// the first time they are read, and reads them from there afterwards.
+//
+// Without this, every layer is compressed at least twice: once to compute its
+// digest for the manifest, and again when it is e.g. uploaded.
+func WithCompressedCache(dir string) UncompressedOption {
+	return func(o *uncompressedOptions) {
+		o.cacheDir = dir
+	}
+}
+
+// UncompressedImage returns a v1.Image for sources that only have a config
+// file and uncompressed layers, such as a container runtime's image store or
+// snapshotter. Layers are looked up by the DiffIDs in the config, opened with
+// open, and compressed on the fly.
+func UncompressedImage(rawConfig []byte, open UncompressedOpener, opts ...UncompressedOption) (v1.Image, error) {
+	o := &uncompressedOptions{
+		compression: compression.GZip,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	switch o.compression {
+	case compression.GZip, compression.ZStd:
+	default:
+		return nil, fmt.Errorf("unsupported layer compression: %s", o.compression)
+	}
+
+	if o.cacheDir != "" {
+		if err := os.MkdirAll(o.cacheDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	return UncompressedToImage(&uncompressedSource{
+		rawConfig: rawConfig,
+		open:      open,
+		opts:      o,
+	})
+}
+
+type uncompressedSource struct {
+	rawConfig []byte
+	open      UncompressedOpener
+	opts      *uncompressedOptions
+}
+
+var _ UncompressedImageCore = (*uncompressedSource)(nil)
+
+// RawConfigFile implements UncompressedImageCore.
+func (s *uncompressedSource) RawConfigFile() ([]byte, error) {
+	return s.rawConfig, nil
+}
+
+// MediaType implements UncompressedImageCore.
+func (s *uncompressedSource) MediaType() (types.MediaType, error) {
+	if s.opts.compression == compression.ZStd {
+		return types.OCIManifestSchema1, nil
+	}
+	return types.DockerManifestSchema2, nil
+}
+
+// LayerByDiffID implements UncompressedImageCore.
+func (s *uncompressedSource) LayerByDiffID(h v1.Hash) (UncompressedLayer, error) {
+	return &uncompressedSourceLayer{diffID: h, source: s}, nil
+}
+
+type uncompressedSourceLayer struct {
+	diffID v1.Hash
+	source *uncompressedSource
+}
+
+var _ withCompressed = (*uncompressedSourceLayer)(nil)
+
+// DiffID implements UncompressedLayer.
+func (l *uncompressedSourceLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+// Uncompressed implements UncompressedLayer.
+func (l *uncompressedSourceLayer) Uncompressed() (io.ReadCloser, error) {
+	return l.source.open(l.diffID)
+}
+
+// MediaType implements UncompressedLayer.
+func (l *uncompressedSourceLayer) MediaType() (types.MediaType, error) {
+	if l.source.opts.compression == compression.ZStd {
+		return types.OCILayerZStd, nil
+	}
+	return types.DockerLayer, nil
+}
+
+// Compressed implements withCompressed.
+func (l *uncompressedSourceLayer) Compressed() (io.ReadCloser, error) {
+	var path string
+	if dir := l.source.opts.cacheDir; dir != "" {
+		path = filepath.Join(dir, fmt.Sprintf("%s-%s.%s", l.diffID.Algorithm, l.diffID.Hex, l.source.opts.compression))
+		if f, err := os.Open(path); err == nil {
+			return f, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	u, err := l.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	rc := icompression.Compress(u, l.source.opts.compression, gzip.BestSpeed)
+	if path == "" {
+		return rc, nil
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return &cachingReader{rc: rc, tmp: tmp, path: path}, nil
+}
+
+// cachingReader copies everything read from rc into tmp, and moves tmp to
+// path if rc was read in full.
+type cachingReader struct {
+	rc   io.ReadCloser
+	tmp  *os.File
+	path string
+	err  error
+	done bool
+}
+
+func (c *cachingReader) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	if n > 0 && c.err == nil {
+		_, c.err = c.tmp.Write(p[:n])
+	}
+	if errors.Is(err, io.EOF) {
+		c.done = true
+	}
+	return n, err
+}
+
+func (c *cachingReader) Close() error {
+	err := c.rc.Close()
+	if cerr := c.tmp.Close(); c.err == nil {
+		c.err = cerr
+	}
+	if c.done && c.err == nil && os.Rename(c.tmp.Name(), c.path) == nil {
+		return err
+	}
+	// Don't leave partial or broken files behind.
+	os.Remove(c.tmp.Name())
+	return err
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// uncompressedStore is what e.g. a container runtime has: a config file and
+// uncompressed layers keyed by DiffID.
+func uncompressedStore(t *testing.T) ([]byte, map[v1.Hash]int, partial.UncompressedOpener) {
+	t.Helper()
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byDiffID := map[v1.Hash]v1.Layer{}
+	for _, l := range layers {
+		h, err := l.DiffID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		byDiffID[h] = l
+	}
+	opens := map[v1.Hash]int{}
+	return cfg, opens, func(h v1.Hash) (io.ReadCloser, error) {
+		l, ok := byDiffID[h]
+		if !ok {
+			return nil, fmt.Errorf("layer %s not found", h)
+		}
+		opens[h]++
+		return l.Uncompressed()
+	}
+}
+
+func TestUncompressedImage(t *testing.T) {
+	for _, c := range []struct {
+		comp      compression.Compression
+		mediaType types.MediaType
+		layerType types.MediaType
+	}{
+		{compression.GZip, types.DockerManifestSchema2, types.DockerLayer},
+		{compression.ZStd, types.OCIManifestSchema1, types.OCILayerZStd},
+	} {
+		t.Run(string(c.comp), func(t *testing.T) {
+			cfg, _, open := uncompressedStore(t)
+			img, err := partial.UncompressedImage(cfg, open, partial.WithLayerCompression(c.comp))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validate.Image(img); err != nil {
+				t.Errorf("validate.Image() = %v", err)
+			}
+
+			m, err := img.Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.MediaType != c.mediaType {
+				t.Errorf("MediaType = %s, want %s", m.MediaType, c.mediaType)
+			}
+			for _, l := range m.Layers {
+				if l.MediaType != c.layerType {
+					t.Errorf("layer MediaType = %s, want %s", l.MediaType, c.layerType)
+				}
+			}
+		})
+	}
+}
+
+func TestUncompressedImageCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg, opens, open := uncompressedStore(t)
+	img, err := partial.UncompressedImage(cfg, open, partial.WithCompressedCache(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Computing digests, then reading every layer, only compresses once.
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range layers {
+		if _, err := l.Digest(); err != nil {
+			t.Fatal(err)
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+	}
+	for h, n := range opens {
+		if n != 1 {
+			t.Errorf("layer %s opened %d times, want 1", h, n)
+		}
+	}
+
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(files), len(layers); got != want {
+		t.Errorf("cached %d files, want %d", got, want)
+	}
+}
@@ -370,6 +370,20 @@ func Exists(l v1.Layer) (bool, error) {
 	return true, nil
 }
 
+type withStat interface {
+	Stat() (*v1.Descriptor, error)
+}
+
+// Stat returns the digest and size of a layer as reported by wherever it's
+// stored, e.g. in a registry's response to a HEAD request, without reading its
+// contents. It returns nil if the layer doesn't support that.
+func Stat(l v1.Layer) (*v1.Descriptor, error) {
+	if ws, ok := unwrap(l).(withStat); ok {
+		return ws.Stat()
+	}
+	return nil, nil
+}
+
 // Recursively unwrap our wrappers so that we can check for the original implementation.
 // We might want to expose this?
 func unwrap(i interface{}) interface{} {
@@ -0,0 +1,197 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Normalize returns p with its OS, architecture and variant in canonical
+// form, following containerd's conventions: "aarch64" is "arm64", "x86_64"
+// is "amd64", "armhf" is "arm" with variant "v7", and so on. Default
+// variants are made implicit for amd64 ("v1") and arm64 ("v8"), and explicit
+// for arm ("v7"), so that equivalent platforms compare equal. As elsewhere,
+// case is significant.
+func (p Platform) Normalize() Platform {
+	if p.OS == "macos" {
+		p.OS = "darwin"
+	}
+	p.Architecture, p.Variant = normalizeArch(p.Architecture, p.Variant)
+	return p
+}
+
+func normalizeArch(arch, variant string) (string, string) {
+	switch arch {
+	case "i386":
+		return "386", ""
+	case "x86_64", "x86-64", "amd64":
+		if variant == "v1" {
+			variant = ""
+		}
+		return "amd64", variant
+	case "aarch64", "arm64":
+		switch variant {
+		case "8", "v8", "v8.0":
+			variant = ""
+		}
+		return "arm64", variant
+	case "armhf":
+		return "arm", "v7"
+	case "armel":
+		return "arm", "v6"
+	case "arm":
+		switch variant {
+		case "", "7":
+			variant = "v7"
+		case "5", "6", "8":
+			variant = "v" + variant
+		}
+		return "arm", variant
+	}
+	return arch, variant
+}
+
+// Matches returns true if p matches spec. Like Satisfies, parts of spec
+// that are empty match anything, but platforms are compared after Normalize,
+// so e.g. "linux/aarch64" matches "linux/arm64/v8", and an OS version
+// matches more specific ones, as Windows builds do: "10.0.17763" matches
+// "10.0.17763.5458".
+func (p Platform) Matches(spec Platform) bool {
+	t := platformTerm{os: spec.OS, arch: spec.Architecture, variant: spec.Variant, osVersion: spec.OSVersion}
+	return t.normalize().matches(p.Normalize()) &&
+		satisfiesList(spec.OSFeatures, p.OSFeatures) &&
+		satisfiesList(spec.Features, p.Features)
+}
+
+// PlatformSelector selects platforms with an expression like
+// "linux/amd64,linux/arm64/v8,!windows/*". See ParsePlatformSelector.
+type PlatformSelector struct {
+	include []platformTerm
+	exclude []platformTerm
+}
+
+// platformTerm is one platform in a selector. Empty fields match anything.
+type platformTerm struct {
+	os, arch, variant, osVersion string
+}
+
+// ParsePlatformSelector parses a comma-separated list of platforms in the
+// form os[/arch[/variant]][:osversion], each optionally prefixed by "!".
+//
+// A platform is selected if it matches none of the platforms prefixed by
+// "!", and any one of the others, if there are others. Any part of a
+// platform may be "*", which matches anything, as do omitted parts: e.g.
+// "linux/arm" matches every arm variant. Platforms are compared after
+// Normalize, and an OS version matches that version and any more specific
+// one, so "windows/amd64:10.0.17763" matches Windows build 10.0.17763.5458.
+func ParsePlatformSelector(s string) (*PlatformSelector, error) {
+	sel := &PlatformSelector{}
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		exclude := strings.HasPrefix(t, "!")
+		t = strings.TrimPrefix(t, "!")
+		if t == "" {
+			return nil, fmt.Errorf("empty platform in selector %q", s)
+		}
+		p, err := ParsePlatform(t)
+		if err != nil {
+			return nil, err
+		}
+		if p.OS == "" {
+			return nil, fmt.Errorf("missing OS in platform %q", t)
+		}
+		term := platformTerm{os: p.OS, arch: p.Architecture, variant: p.Variant, osVersion: p.OSVersion}
+		term = term.normalize()
+		if exclude {
+			sel.exclude = append(sel.exclude, term)
+		} else {
+			sel.include = append(sel.include, term)
+		}
+	}
+	return sel, nil
+}
+
+// normalize normalizes the term's parts, replacing wildcards with "".
+func (t platformTerm) normalize() platformTerm {
+	for _, part := range []*string{&t.os, &t.arch, &t.variant, &t.osVersion} {
+		if *part == "*" {
+			*part = ""
+		}
+	}
+	n := Platform{OS: t.os, Architecture: t.arch, Variant: t.variant}.Normalize()
+	// Normalize fills in arm's default variant, but an omitted variant
+	// matches any, unless the architecture implies one, as "armhf" does.
+	if t.variant != "" || t.arch != "arm" {
+		t.variant = n.Variant
+	}
+	t.os, t.arch = n.OS, n.Architecture
+	return t
+}
+
+func (t platformTerm) matches(p Platform) bool {
+	return (t.os == "" || t.os == p.OS) &&
+		(t.arch == "" || t.arch == p.Architecture) &&
+		(t.variant == "" || t.variant == p.Variant) &&
+		(t.osVersion == "" || p.OSVersion == t.osVersion || strings.HasPrefix(p.OSVersion, t.osVersion+"."))
+}
+
+func (t platformTerm) String() string {
+	parts := []string{t.os, t.arch, t.variant}
+	for i := range parts {
+		if parts[i] == "" {
+			parts[i] = "*"
+		}
+	}
+	// Drop trailing wildcards.
+	for len(parts) > 1 && parts[len(parts)-1] == "*" {
+		parts = parts[:len(parts)-1]
+	}
+	s := strings.Join(parts, "/")
+	if t.osVersion != "" {
+		s += ":" + t.osVersion
+	}
+	return s
+}
+
+// Matches returns true if the selector selects p.
+func (s *PlatformSelector) Matches(p Platform) bool {
+	p = p.Normalize()
+	for _, t := range s.exclude {
+		if t.matches(p) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, t := range s.include {
+		if t.matches(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *PlatformSelector) String() string {
+	terms := make([]string, 0, len(s.include)+len(s.exclude))
+	for _, t := range s.include {
+		terms = append(terms, t.String())
+	}
+	for _, t := range s.exclude {
+		terms = append(terms, "!"+t.String())
+	}
+	return strings.Join(terms, ",")
+}
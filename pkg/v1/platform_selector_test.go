@@ -0,0 +1,135 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1_test
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestPlatformNormalize(t *testing.T) {
+	for _, c := range []struct {
+		in, want v1.Platform
+	}{
+		{v1.Platform{OS: "linux", Architecture: "x86_64"}, v1.Platform{OS: "linux", Architecture: "amd64"}},
+		{v1.Platform{OS: "linux", Architecture: "amd64", Variant: "v1"}, v1.Platform{OS: "linux", Architecture: "amd64"}},
+		{v1.Platform{OS: "linux", Architecture: "amd64", Variant: "v3"}, v1.Platform{OS: "linux", Architecture: "amd64", Variant: "v3"}},
+		{v1.Platform{OS: "linux", Architecture: "aarch64"}, v1.Platform{OS: "linux", Architecture: "arm64"}},
+		{v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, v1.Platform{OS: "linux", Architecture: "arm64"}},
+		{v1.Platform{OS: "linux", Architecture: "arm"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{v1.Platform{OS: "linux", Architecture: "arm", Variant: "6"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}},
+		{v1.Platform{OS: "linux", Architecture: "armhf"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{v1.Platform{OS: "linux", Architecture: "armel"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}},
+		{v1.Platform{OS: "linux", Architecture: "i386"}, v1.Platform{OS: "linux", Architecture: "386"}},
+		{v1.Platform{OS: "macos", Architecture: "arm64"}, v1.Platform{OS: "darwin", Architecture: "arm64"}},
+	} {
+		if got := c.in.Normalize(); !got.Equals(c.want) {
+			t.Errorf("Normalize(%s) = %s, want %s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	for _, c := range []struct {
+		p, spec v1.Platform
+		want    bool
+	}{
+		{v1.Platform{OS: "linux", Architecture: "arm64"}, v1.Platform{OS: "linux", Architecture: "aarch64"}, true},
+		{v1.Platform{OS: "linux", Architecture: "arm64"}, v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, true},
+		{v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, v1.Platform{OS: "linux", Architecture: "arm64"}, true},
+		{v1.Platform{OS: "linux", Architecture: "arm"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, true},
+		{v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, v1.Platform{OS: "linux", Architecture: "arm"}, true},
+		{v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, v1.Platform{OS: "linux", Architecture: "armhf"}, false},
+		{v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, false},
+		{v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.5458"}, v1.Platform{OS: "windows", OSVersion: "10.0.17763"}, true},
+		{v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.177630"}, v1.Platform{OS: "windows", OSVersion: "10.0.17763"}, false},
+		{v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348.2227"}, v1.Platform{OS: "windows", OSVersion: "10.0.17763"}, false},
+		{v1.Platform{OS: "linux", Architecture: "amd64", Features: []string{"sse4"}}, v1.Platform{OS: "linux", Features: []string{"sse4"}}, true},
+		{v1.Platform{OS: "linux", Architecture: "amd64"}, v1.Platform{OS: "linux", Features: []string{"sse4"}}, false},
+	} {
+		if got := c.p.Matches(c.spec); got != c.want {
+			t.Errorf("%s.Matches(%s) = %t, want %t", c.p, c.spec, got, c.want)
+		}
+	}
+}
+
+func TestPlatformSelector(t *testing.T) {
+	for _, c := range []struct {
+		selector string
+		str      string
+		match    []string
+		noMatch  []string
+	}{{
+		selector: "linux/amd64,linux/arm64/v8,!windows/*",
+		str:      "linux/amd64,linux/arm64,!windows",
+		match:    []string{"linux/amd64", "linux/arm64", "linux/arm64/v8"},
+		noMatch:  []string{"linux/arm/v7", "windows/amd64", "linux/s390x"},
+	}, {
+		selector: "!windows/*",
+		str:      "!windows",
+		match:    []string{"linux/amd64", "linux/s390x", "darwin/arm64"},
+		noMatch:  []string{"windows/amd64", "windows/arm64:10.0.17763.1"},
+	}, {
+		selector: "linux/*, !linux/arm",
+		str:      "linux,!linux/arm",
+		match:    []string{"linux/amd64", "linux/arm64"},
+		noMatch:  []string{"linux/arm/v6", "linux/arm/v7", "linux/arm", "windows/amd64"},
+	}, {
+		selector: "*/arm64",
+		str:      "*/arm64",
+		match:    []string{"linux/arm64", "darwin/arm64", "linux/aarch64"},
+		noMatch:  []string{"linux/amd64"},
+	}, {
+		selector: "linux/x86_64,linux/armhf",
+		str:      "linux/amd64,linux/arm/v7",
+		match:    []string{"linux/amd64", "linux/arm", "linux/arm/v7"},
+		noMatch:  []string{"linux/arm/v6", "linux/386"},
+	}, {
+		selector: "windows/amd64:10.0.17763",
+		str:      "windows/amd64:10.0.17763",
+		match:    []string{"windows/amd64:10.0.17763.5458", "windows/amd64:10.0.17763"},
+		noMatch:  []string{"windows/amd64:10.0.20348.2227", "windows/amd64"},
+	}} {
+		s, err := v1.ParsePlatformSelector(c.selector)
+		if err != nil {
+			t.Fatalf("ParsePlatformSelector(%q) = %v", c.selector, err)
+		}
+		if got := s.String(); got != c.str {
+			t.Errorf("ParsePlatformSelector(%q).String() = %q, want %q", c.selector, got, c.str)
+		}
+		for _, want := range []bool{true, false} {
+			platforms := c.match
+			if !want {
+				platforms = c.noMatch
+			}
+			for _, ps := range platforms {
+				p, err := v1.ParsePlatform(ps)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := s.Matches(*p); got != want {
+					t.Errorf("%q.Matches(%s) = %t, want %t", c.selector, ps, got, want)
+				}
+			}
+		}
+	}
+
+	for _, bad := range []string{"", "linux/amd64,", "!", "/amd64", "linux/arm/v7/extra"} {
+		if _, err := v1.ParsePlatformSelector(bad); err == nil {
+			t.Errorf("ParsePlatformSelector(%q) should fail", bad)
+		}
+	}
+}
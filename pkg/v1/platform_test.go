@@ -149,3 +149,39 @@ func TestPlatformEquals(t *testing.T) {
 		}
 	}
 }
+
+func TestPlatformSatisfies(t *testing.T) {
+	for _, c := range []struct {
+		p    v1.Platform
+		spec v1.Platform
+		want bool
+	}{{
+		p:    v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		spec: v1.Platform{OS: "linux", Architecture: "arm64"},
+		want: true,
+	}, {
+		p:    v1.Platform{OS: "linux", Architecture: "arm64"},
+		spec: v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		want: false,
+	}, {
+		p:    v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1234"},
+		spec: v1.Platform{OS: "windows"},
+		want: true,
+	}, {
+		p:    v1.Platform{OS: "linux", Architecture: "amd64", Features: []string{"a", "b"}},
+		spec: v1.Platform{Features: []string{"b"}},
+		want: true,
+	}, {
+		p:    v1.Platform{OS: "linux", Architecture: "amd64", OSFeatures: []string{"a"}},
+		spec: v1.Platform{OSFeatures: []string{"a", "b"}},
+		want: false,
+	}, {
+		p:    v1.Platform{OS: "linux", Architecture: "amd64"},
+		spec: v1.Platform{},
+		want: true,
+	}} {
+		if got := c.p.Satisfies(c.spec); got != c.want {
+			t.Errorf("%v.Satisfies(%v) = %t, want %t", c.p, c.spec, got, c.want)
+		}
+	}
+}
@@ -17,13 +17,12 @@ package random
 import (
 	"archive/tar"
 	"bytes"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
-	mrand "math/rand"
+	"math/rand"
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -58,10 +57,25 @@ func (ul *uncompressedLayer) MediaType() (types.MediaType, error) {
 var _ partial.UncompressedLayer = (*uncompressedLayer)(nil)
 
 // Image returns a pseudo-randomly generated Image.
-func Image(byteSize, layers int64) (v1.Image, error) {
+func Image(byteSize, layers int64, opts ...Option) (v1.Image, error) {
+	o := makeOptions(opts...)
+	var platform *v1.Platform
+	if len(o.platforms) > 0 {
+		platform = &o.platforms[0]
+	}
+	return image(byteSize, layers, o, rand.New(o.source), platform)
+}
+
+func image(byteSize, layers int64, o *options, rnd *rand.Rand, platform *v1.Platform) (v1.Image, error) {
+	// Seeded images must not depend on when they were generated.
+	created := v1.Time{Time: time.Now()}
+	if o.seeded {
+		created = v1.Time{}
+	}
+
 	adds := make([]mutate.Addendum, 0, 5)
 	for i := int64(0); i < layers; i++ {
-		layer, err := Layer(byteSize, types.DockerLayer)
+		layer, err := layer(byteSize, types.DockerLayer, o, rnd)
 		if err != nil {
 			return nil, err
 		}
@@ -71,34 +85,60 @@ func Image(byteSize, layers int64) (v1.Image, error) {
 				Author:    "random.Image",
 				Comment:   fmt.Sprintf("this is a random history %d of %d", i, layers),
 				CreatedBy: "random",
-				Created:   v1.Time{Time: time.Now()},
+				Created:   created,
 			},
 		})
 	}
 
-	return mutate.Append(empty.Image, adds...)
+	img, err := mutate.Append(empty.Image, adds...)
+	if err != nil {
+		return nil, err
+	}
+	if platform != nil {
+		cf, err := img.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		cf = cf.DeepCopy()
+		cf.OS = platform.OS
+		cf.Architecture = platform.Architecture
+		cf.OSVersion = platform.OSVersion
+		if img, err = mutate.ConfigFile(img, cf); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.annotations) > 0 {
+		img = mutate.Annotations(img, o.annotations).(v1.Image)
+	}
+	return img, nil
 }
 
 // Layer returns a layer with pseudo-randomly generated content.
-func Layer(byteSize int64, mt types.MediaType) (v1.Layer, error) {
-	fileName := fmt.Sprintf("random_file_%d.txt", mrand.Int()) //nolint: gosec
+func Layer(byteSize int64, mt types.MediaType, opts ...Option) (v1.Layer, error) {
+	o := makeOptions(opts...)
+	return layer(byteSize, mt, o, rand.New(o.source))
+}
 
+func layer(byteSize int64, mt types.MediaType, o *options, rnd *rand.Rand) (v1.Layer, error) {
 	// Hash the contents as we write it out to the buffer.
 	var b bytes.Buffer
 	hasher := sha256.New()
 	mw := io.MultiWriter(&b, hasher)
 
-	// Write a single file with a random name and random contents.
+	// Write files with random names and random contents.
 	tw := tar.NewWriter(mw)
-	if err := tw.WriteHeader(&tar.Header{
-		Name:     fileName,
-		Size:     byteSize,
-		Typeflag: tar.TypeRegA,
-	}); err != nil {
-		return nil, err
-	}
-	if _, err := io.CopyN(tw, rand.Reader, byteSize); err != nil {
-		return nil, err
+	for i := 0; i < o.files; i++ {
+		fileName := fmt.Sprintf("random_file_%d.txt", rnd.Int())
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     fileName,
+			Size:     byteSize,
+			Typeflag: tar.TypeRegA,
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(tw, rnd, byteSize); err != nil {
+			return nil, err
+		}
 	}
 	if err := tw.Close(); err != nil {
 		return nil, err
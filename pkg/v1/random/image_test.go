@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"testing"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
@@ -128,3 +129,82 @@ func TestRandomLayer(t *testing.T) {
 		t.Errorf("Layer contained more files; got %v, want EOF", err)
 	}
 }
+
+func TestSeededImage(t *testing.T) {
+	digest := func(opts ...Option) string {
+		img, err := Image(1024, 3, opts...)
+		if err != nil {
+			t.Fatalf("Image: %v", err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatalf("Digest: %v", err)
+		}
+		return d.String()
+	}
+
+	if a, b := digest(WithSeed(42)), digest(WithSeed(42)); a != b {
+		t.Errorf("same seed, different digests: %s != %s", a, b)
+	}
+	if a, b := digest(WithSeed(42)), digest(WithSeed(43)); a == b {
+		t.Errorf("different seeds, same digest: %s", a)
+	}
+	if a, b := digest(), digest(); a == b {
+		t.Errorf("unseeded images have the same digest: %s", a)
+	}
+}
+
+func TestImageOptions(t *testing.T) {
+	img, err := Image(16, 2,
+		WithSeed(1),
+		WithFileCount(3),
+		WithPlatforms(v1.Platform{OS: "linux", Architecture: "arm64"}),
+		WithAnnotations(map[string]string{"foo": "bar"}),
+	)
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatalf("ConfigFile: %v", err)
+	}
+	if cf.OS != "linux" || cf.Architecture != "arm64" {
+		t.Errorf("platform = %s/%s, want linux/arm64", cf.OS, cf.Architecture)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatalf("Manifest: %v", err)
+	}
+	if got := m.Annotations["foo"]; got != "bar" {
+		t.Errorf("Annotations[foo] = %q, want bar", got)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatalf("Layers: %v", err)
+	}
+	for _, l := range layers {
+		rc, err := l.Uncompressed()
+		if err != nil {
+			t.Fatalf("Uncompressed: %v", err)
+		}
+		defer rc.Close()
+		files := 0
+		tr := tar.NewReader(rc)
+		for {
+			if _, err := tr.Next(); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				t.Fatalf("tar.Next: %v", err)
+			}
+			files++
+		}
+		if files != 3 {
+			t.Errorf("Layer contained %d files, want 3", files)
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
@@ -31,15 +32,24 @@ type randomIndex struct {
 
 // Index returns a pseudo-randomly generated ImageIndex with count images, each
 // having the given number of layers of size byteSize.
-func Index(byteSize, layers, count int64) (v1.ImageIndex, error) {
+func Index(byteSize, layers, count int64, opts ...Option) (v1.ImageIndex, error) {
+	o := makeOptions(opts...)
+	rnd := rand.New(o.source)
+
 	manifest := v1.IndexManifest{
 		SchemaVersion: 2,
 		Manifests:     []v1.Descriptor{},
+		Annotations:   o.annotations,
 	}
 
 	images := make(map[v1.Hash]v1.Image)
 	for i := int64(0); i < count; i++ {
-		img, err := Image(byteSize, layers)
+		var platform *v1.Platform
+		if len(o.platforms) > 0 {
+			p := o.platforms[i%int64(len(o.platforms))]
+			platform = &p
+		}
+		img, err := image(byteSize, layers, o, rnd, platform)
 		if err != nil {
 			return nil, err
 		}
@@ -61,6 +71,7 @@ func Index(byteSize, layers, count int64) (v1.ImageIndex, error) {
 			Digest:    digest,
 			Size:      size,
 			MediaType: mediaType,
+			Platform:  platform,
 		})
 
 		images[digest] = img
@@ -17,6 +17,7 @@ package random
 import (
 	"testing"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
@@ -53,3 +54,64 @@ func TestRandomIndex(t *testing.T) {
 		t.Errorf("MediaType(): got: %v, want: %v", got, want)
 	}
 }
+
+func TestSeededIndex(t *testing.T) {
+	platforms := []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+	opts := []Option{
+		WithSeed(7),
+		WithPlatforms(platforms...),
+		WithAnnotations(map[string]string{"foo": "bar"}),
+	}
+
+	ii, err := Index(64, 2, 2, opts...)
+	if err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if err := validate.Index(ii); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+
+	again, err := Index(64, 2, 2, opts...)
+	if err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	d1, err := ii.Digest()
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+	d2, err := again.Digest()
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+	if d1 != d2 {
+		t.Errorf("same seed, different digests: %s != %s", d1, d2)
+	}
+
+	m, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatalf("IndexManifest: %v", err)
+	}
+	if got := m.Annotations["foo"]; got != "bar" {
+		t.Errorf("Annotations[foo] = %q, want bar", got)
+	}
+	for i, desc := range m.Manifests {
+		want := platforms[i%len(platforms)]
+		if desc.Platform == nil || !desc.Platform.Equals(want) {
+			t.Errorf("Manifests[%d].Platform = %v, want %v", i, desc.Platform, want)
+		}
+		img, err := ii.Image(desc.Digest)
+		if err != nil {
+			t.Fatalf("Image: %v", err)
+		}
+		cf, err := img.ConfigFile()
+		if err != nil {
+			t.Fatalf("ConfigFile: %v", err)
+		}
+		if cf.Architecture != want.Architecture {
+			t.Errorf("Manifests[%d] architecture = %s, want %s", i, cf.Architecture, want.Architecture)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package random
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Option is a functional option for Image, Index and Layer.
+type Option func(*options)
+
+type options struct {
+	source      rand.Source
+	seeded      bool
+	files       int
+	platforms   []v1.Platform
+	annotations map[string]string
+}
+
+func makeOptions(opts ...Option) *options {
+	o := &options{
+		files: 1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.source == nil {
+		var seed [8]byte
+		if _, err := crand.Read(seed[:]); err != nil {
+			panic(err)
+		}
+		o.source = rand.NewSource(int64(binary.LittleEndian.Uint64(seed[:])))
+	}
+	return o
+}
+
+// WithSource sets the source of randomness.  Everything generated from a
+// source is a function of its state, including names and timestamps, so
+// a source seeded with the same value generates byte-identical results across
+// runs.
+//
+// The source is consumed as things are generated, so share it between calls
+// only in a fixed order.
+func WithSource(source rand.Source) Option {
+	return func(o *options) {
+		o.source = source
+		o.seeded = true
+	}
+}
+
+// WithSeed sets the source of randomness to a new source seeded with seed,
+// each time the option is used, as described by WithSource.
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		WithSource(rand.NewSource(seed))(o)
+	}
+}
+
+// WithFileCount sets the number of files in each layer, each of which is
+// byteSize bytes.  The default is 1.
+func WithFileCount(n int) Option {
+	return func(o *options) {
+		o.files = n
+	}
+}
+
+// WithPlatforms sets the platforms of the generated images.  Index assigns
+// them round-robin, and records each in the image's descriptor; Image uses
+// the first.
+func WithPlatforms(platforms ...v1.Platform) Option {
+	return func(o *options) {
+		o.platforms = platforms
+	}
+}
+
+// WithAnnotations sets the annotations on the manifest of each generated
+// image, and of the generated index.
+func WithAnnotations(annotations map[string]string) Option {
+	return func(o *options) {
+		o.annotations = annotations
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+)
+
+// Artifact provides access to a remote OCI artifact reference.
+func Artifact(ref name.Reference, options ...Option) (v1.Artifact, error) {
+	desc, err := Get(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return desc.Artifact()
+}
+
+// Artifact converts the Descriptor into a v1.Artifact.
+//
+// Unlike Image, it does not resolve indexes to a child manifest, since
+// artifacts are always stored as image manifests.
+func (d *Descriptor) Artifact() (v1.Artifact, error) {
+	if !d.MediaType.IsImage() {
+		return nil, fmt.Errorf("unexpected media type for Artifact(): %s", d.MediaType)
+	}
+	img, err := d.Image()
+	if err != nil {
+		return nil, err
+	}
+	return artifact.FromImage(img), nil
+}
+
+// WriteArtifact pushes the provided artifact to the specified reference.
+func WriteArtifact(ref name.Reference, a v1.Artifact, options ...Option) error {
+	img, err := artifact.Image(a)
+	if err != nil {
+		return err
+	}
+	return Write(ref, img, options...)
+}
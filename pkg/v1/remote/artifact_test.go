@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+)
+
+func TestArtifact(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := artifact.New("application/vnd.example.policy.v1",
+		artifact.WithBlobs(static.NewLayer([]byte("allow = true"), "application/vnd.example.policy.rego")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/repo:policy", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteArtifact(ref, a); err != nil {
+		t.Fatalf("WriteArtifact() = %v", err)
+	}
+
+	got, err := Artifact(ref)
+	if err != nil {
+		t.Fatalf("Artifact() = %v", err)
+	}
+	if at, err := got.ArtifactType(); err != nil || at != "application/vnd.example.policy.v1" {
+		t.Errorf("ArtifactType() = %q, %v", at, err)
+	}
+	blobs, err := got.Blobs()
+	if err != nil || len(blobs) != 1 {
+		t.Fatalf("Blobs() = %d, %v, want 1", len(blobs), err)
+	}
+	rc, err := blobs[0].Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if b, err := ioutil.ReadAll(rc); err != nil || string(b) != "allow = true" {
+		t.Errorf("blob = %q, %v", b, err)
+	}
+
+	// Indexes aren't artifacts.
+	idx, err := random.Index(100, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Artifact(ref); err == nil {
+		t.Error("Artifact() of an index should fail")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/go-containerregistry/internal/redact"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// BlobReader returns a reader for random access to the blob at ref, which
+// reads ranges of it with HTTP range requests rather than fetching it whole.
+//
+// Each call to ReadAt, or Read, makes a request, so callers should read in
+// large chunks. Since only parts of the blob are read, its digest is not
+// verified.
+func BlobReader(ref name.Digest, options ...Option) (*io.SectionReader, error) {
+	o, err := makeOptions(ref.Context(), options...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := makeFetcher(ref, o)
+	if err != nil {
+		return nil, err
+	}
+	h, err := v1.NewHash(ref.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	d, err := f.statBlob(h)
+	if err != nil {
+		return nil, err
+	}
+	if d.Size < 0 {
+		return nil, fmt.Errorf("HEAD %s: missing Content-Length", ref)
+	}
+	return io.NewSectionReader(&blobRange{fetcher: f, digest: h}, 0, d.Size), nil
+}
+
+type blobRange struct {
+	*fetcher
+	digest v1.Hash
+}
+
+// ReadAt implements io.ReaderAt.
+func (b *blobRange) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	u := b.url("blobs", b.digest.String())
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+
+	// We don't want to log binary layers -- this can break terminals.
+	ctx := redact.NewContext(b.context, "omitting binary blobs from logs")
+	resp, err := b.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return 0, io.EOF
+	}
+	if resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("GET %s: registry does not support range requests", u.String())
+	}
+	if err := transport.CheckError(resp, http.StatusPartialContent); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		// The range ran past the end of the blob.
+		err = io.EOF
+	}
+	return n, err
+}
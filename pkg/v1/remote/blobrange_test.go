@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestBlobReader(t *testing.T) {
+	blob := []byte(strings.Repeat("0123456789", 10))
+	h, _, err := v1.SHA256(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobPath := fmt.Sprintf("/v2/foo/blobs/%s", h)
+
+	for _, tc := range []struct {
+		name   string
+		ranges bool
+	}{
+		{name: "ranges", ranges: true},
+		{name: "no ranges"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v2/":
+					w.WriteHeader(http.StatusOK)
+				case blobPath:
+					if !tc.ranges {
+						r.Header.Del("Range")
+					}
+					http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob))
+				default:
+					t.Fatalf("Unexpected path: %v", r.URL.Path)
+				}
+			}))
+			defer server.Close()
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ref, err := name.NewDigest(fmt.Sprintf("%s/foo@%s", u.Host, h))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sr, err := BlobReader(ref)
+			if err != nil {
+				t.Fatalf("BlobReader() = %v", err)
+			}
+			if sr.Size() != int64(len(blob)) {
+				t.Errorf("Size() = %d, want %d", sr.Size(), len(blob))
+			}
+
+			p := make([]byte, 15)
+			n, err := sr.ReadAt(p, 20)
+			if !tc.ranges {
+				if err == nil {
+					t.Error("ReadAt() should fail when the registry ignores ranges")
+				}
+				return
+			}
+			if err != nil || n != len(p) {
+				t.Fatalf("ReadAt() = %d, %v", n, err)
+			}
+			if got, want := string(p), string(blob[20:35]); got != want {
+				t.Errorf("ReadAt() = %q, want %q", got, want)
+			}
+
+			// Reads past the end are cut short.
+			n, err = sr.ReadAt(p, 90)
+			if err != io.EOF || n != 10 {
+				t.Errorf("ReadAt(90) = %d, %v, want 10, EOF", n, err)
+			}
+			if got, want := string(p[:n]), string(blob[90:]); got != want {
+				t.Errorf("ReadAt(90) = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -15,8 +15,9 @@
 package remote
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -25,6 +26,7 @@ import (
 	"strings"
 
 	"github.com/google/go-containerregistry/internal/verify"
+	"github.com/google/go-containerregistry/pkg/concurrency"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -122,7 +124,8 @@ func get(ref name.Reference, acceptable []types.MediaType, options ...Option) (*
 	if err != nil {
 		return nil, err
 	}
-	b, desc, err := f.fetchManifest(ref, acceptable)
+	logs.FromContext(o.context).Log(logs.LevelInfo, "pull started", "ref", ref)
+	b, desc, err := f.fetchManifest(o.context, ref, acceptable)
 	if err != nil {
 		return nil, err
 	}
@@ -156,7 +159,7 @@ func (d *Descriptor) Image() (v1.Image, error) {
 	default:
 		// We could just return an error here, but some registries (e.g. static
 		// registries) don't set the Content-Type headers correctly, so instead...
-		logs.Warn.Printf("Unexpected media type for Image(): %s", d.MediaType)
+		logs.FromContext(d.context).Log(logs.LevelWarn, "Unexpected media type for Image()", "mediaType", d.MediaType)
 	}
 
 	// Wrap the v1.Layers returned by this v1.Image in a hint for downstream
@@ -186,7 +189,7 @@ func (d *Descriptor) ImageIndex() (v1.ImageIndex, error) {
 	default:
 		// We could just return an error here, but some registries (e.g. static
 		// registries) don't set the Content-Type headers correctly, so instead...
-		logs.Warn.Printf("Unexpected media type for ImageIndex(): %s", d.MediaType)
+		logs.FromContext(d.context).Log(logs.LevelWarn, "Unexpected media type for ImageIndex()", "mediaType", d.MediaType)
 	}
 	return d.remoteIndex(), nil
 }
@@ -211,9 +214,11 @@ func (d *Descriptor) remoteIndex() *remoteIndex {
 
 // fetcher implements methods for reading from a registry.
 type fetcher struct {
-	Ref     name.Reference
-	Client  *http.Client
-	context context.Context
+	Ref       name.Reference
+	Client    *http.Client
+	context   context.Context
+	lowMemory bool
+	budget    *concurrency.Budget
 }
 
 func makeFetcher(ref name.Reference, o *options) (*fetcher, error) {
@@ -222,9 +227,11 @@ func makeFetcher(ref name.Reference, o *options) (*fetcher, error) {
 		return nil, err
 	}
 	return &fetcher{
-		Ref:     ref,
-		Client:  &http.Client{Transport: tr},
-		context: o.context,
+		Ref:       ref,
+		Client:    &http.Client{Transport: tr},
+		context:   o.context,
+		lowMemory: o.lowMemory,
+		budget:    o.budget,
 	}, nil
 }
 
@@ -237,7 +244,7 @@ func (f *fetcher) url(resource, identifier string) url.URL {
 	}
 }
 
-func (f *fetcher) fetchManifest(ref name.Reference, acceptable []types.MediaType) ([]byte, *v1.Descriptor, error) {
+func (f *fetcher) fetchManifest(ctx context.Context, ref name.Reference, acceptable []types.MediaType) ([]byte, *v1.Descriptor, error) {
 	u := f.url("manifests", ref.Identifier())
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
 	if err != nil {
@@ -249,25 +256,33 @@ func (f *fetcher) fetchManifest(ref name.Reference, acceptable []types.MediaType
 	}
 	req.Header.Set("Accept", strings.Join(accept, ","))
 
-	resp, err := f.Client.Do(req.WithContext(f.context))
+	ctx, release, err := f.budget.Hold(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer resp.Body.Close()
+	defer release()
 
-	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+	resp, err := f.Client.Do(req.WithContext(ctx))
+	if err != nil {
 		return nil, nil, err
 	}
+	defer resp.Body.Close()
 
-	manifest, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
 		return nil, nil, err
 	}
 
-	digest, size, err := v1.SHA256(bytes.NewReader(manifest))
+	// Hash the manifest as it's read, rather than reading it again.
+	hasher := sha256.New()
+	manifest, err := readAll(io.TeeReader(resp.Body, hasher), resp.ContentLength)
 	if err != nil {
 		return nil, nil, err
 	}
+	digest := v1.Hash{
+		Algorithm: "sha256",
+		Hex:       hex.EncodeToString(hasher.Sum(nil)),
+	}
+	size := int64(len(manifest))
 
 	mediaType := types.MediaType(resp.Header.Get("Content-Type"))
 	contentDigest, err := v1.NewHash(resp.Header.Get("Docker-Content-Digest"))
@@ -358,13 +373,24 @@ func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType)
 	}, nil
 }
 
-func (f *fetcher) fetchBlob(ctx context.Context, size int64, h v1.Hash) (io.ReadCloser, error) {
+func (f *fetcher) fetchBlob(ctx context.Context, size int64, h v1.Hash) (_ io.ReadCloser, rerr error) {
 	u := f.url("blobs", h.String())
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 
+	// The blob is read as a unit of work of f.budget, until it's closed.
+	ctx, release, err := f.budget.Hold(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if rerr != nil {
+			release()
+		}
+	}()
+
 	resp, err := f.Client.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
@@ -386,7 +412,83 @@ func (f *fetcher) fetchBlob(ctx context.Context, size int64, h v1.Hash) (io.Read
 		}
 	}
 
-	return verify.ReadCloser(resp.Body, size, h)
+	rc, err := verify.ReadCloser(resp.Body, size, h)
+	if err != nil {
+		return nil, err
+	}
+	return &heldBlob{ReadCloser: logPulled(ctx, rc, h), release: release}, nil
+}
+
+// heldBlob releases the budget slot it was read with once it's closed.
+type heldBlob struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *heldBlob) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// maxPrealloc bounds how much readAll allocates up front based on a size it
+// was told, which may come from an untrusted header.
+const maxPrealloc = 4 << 20
+
+// readAll is like ioutil.ReadAll, but when the size of r is known to be n,
+// it reads into a buffer of exactly that size, so the result doesn't retain
+// the slack that growing a buffer leaves behind.
+func readAll(r io.Reader, n int64) ([]byte, error) {
+	if n < 0 || n > maxPrealloc {
+		return ioutil.ReadAll(r)
+	}
+	b := make([]byte, 0, n)
+	for {
+		if len(b) == cap(b) {
+			// Check for EOF without growing the buffer; if there's more than
+			// we were told, fall back to append.
+			var probe [512]byte
+			m, err := r.Read(probe[:])
+			b = append(b, probe[:m]...)
+			if err == io.EOF {
+				return b, nil
+			} else if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		m, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+m]
+		if err == io.EOF {
+			return b, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// logPulled wraps a verified blob to log that it was pulled once it has been
+// read in full.
+func logPulled(ctx context.Context, rc io.ReadCloser, h v1.Hash) io.ReadCloser {
+	return &pulledBlob{ReadCloser: rc, logger: logs.FromContext(ctx), digest: h}
+}
+
+type pulledBlob struct {
+	io.ReadCloser
+	logger logs.Logger
+	digest v1.Hash
+	size   int64
+	logged bool
+}
+
+func (b *pulledBlob) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	// verify.ReadCloser only returns io.EOF if the digest matched.
+	if err == io.EOF && !b.logged {
+		b.logged = true
+		b.logger.Log(logs.LevelInfo, "pulled blob", "digest", b.digest, "size", b.size)
+	}
+	return n, err
 }
 
 func (f *fetcher) headBlob(h v1.Hash) (*http.Response, error) {
@@ -409,6 +511,24 @@ func (f *fetcher) headBlob(h v1.Hash) (*http.Response, error) {
 	return resp, nil
 }
 
+// statBlob returns the digest and size of a blob as reported by a HEAD request.
+func (f *fetcher) statBlob(h v1.Hash) (*v1.Descriptor, error) {
+	resp, err := f.headBlob(h)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	d := &v1.Descriptor{
+		Digest: h,
+		Size:   resp.ContentLength,
+	}
+	if dh, err := v1.NewHash(resp.Header.Get("Docker-Content-Digest")); err == nil {
+		d.Digest = dh
+	}
+	return d, nil
+}
+
 func (f *fetcher) blobExists(h v1.Hash) (bool, error) {
 	u := f.url("blobs", h.String())
 	req, err := http.NewRequest(http.MethodHead, u.String(), nil)
@@ -16,6 +16,8 @@ package remote
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -67,6 +69,13 @@ func (r *remoteImage) MediaType() (types.MediaType, error) {
 }
 
 func (r *remoteImage) RawManifest() ([]byte, error) {
+	return r.RawManifestWithContext(r.context)
+}
+
+// RawManifestWithContext is like RawManifest, but fetches the manifest with
+// ctx rather than the context it was created with.
+// See partial.RawManifestWithContext.
+func (r *remoteImage) RawManifestWithContext(ctx context.Context) ([]byte, error) {
 	r.manifestLock.Lock()
 	defer r.manifestLock.Unlock()
 	if r.manifest != nil {
@@ -76,7 +85,7 @@ func (r *remoteImage) RawManifest() ([]byte, error) {
 	// NOTE(jonjohnsonjr): We should never get here because the public entrypoints
 	// do type-checking via remote.Descriptor. I've left this here for tests that
 	// directly instantiate a remoteImage.
-	manifest, desc, err := r.fetchManifest(r.Ref, acceptableImageMediaTypes)
+	manifest, desc, err := r.fetchManifest(ctx, r.Ref, acceptableImageMediaTypes)
 	if err != nil {
 		return nil, err
 	}
@@ -90,36 +99,72 @@ func (r *remoteImage) RawManifest() ([]byte, error) {
 }
 
 func (r *remoteImage) RawConfigFile() ([]byte, error) {
+	return r.RawConfigFileWithContext(r.context)
+}
+
+// RawConfigFileWithContext is like RawConfigFile, but fetches the config
+// with ctx rather than the context it was created with.
+// See partial.RawConfigFileWithContext.
+func (r *remoteImage) RawConfigFileWithContext(ctx context.Context) ([]byte, error) {
 	r.configLock.Lock()
 	defer r.configLock.Unlock()
 	if r.config != nil {
 		return r.config, nil
 	}
 
-	m, err := partial.Manifest(r)
+	cfg, err := r.configDescriptor(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if m.Config.Data != nil {
-		if err := verify.Descriptor(m.Config); err != nil {
+	var config []byte
+	if cfg.Data != nil {
+		if err := verify.Descriptor(*cfg); err != nil {
 			return nil, err
 		}
-		r.config = m.Config.Data
-		return r.config, nil
+		config = cfg.Data
+	} else {
+		body, err := r.fetchBlob(ctx, cfg.Size, cfg.Digest)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+
+		if config, err = readAll(body, cfg.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	if !r.lowMemory {
+		r.config = config
 	}
+	return config, nil
+}
 
-	body, err := r.fetchBlob(r.context, m.Config.Size, m.Config.Digest)
+// ConfigName returns the digest of the config from the manifest, so that it
+// doesn't have to be fetched (again, with WithLowMemory) to be hashed. The
+// config is verified against this digest when it's fetched.
+func (r *remoteImage) ConfigName() (v1.Hash, error) {
+	cfg, err := r.configDescriptor(r.context)
 	if err != nil {
-		return nil, err
+		return v1.Hash{}, err
 	}
-	defer body.Close()
+	return cfg.Digest, nil
+}
 
-	r.config, err = ioutil.ReadAll(body)
+// configDescriptor decodes just the config descriptor from the manifest.
+func (r *remoteImage) configDescriptor(ctx context.Context) (*v1.Descriptor, error) {
+	b, err := r.RawManifestWithContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.config, nil
+	var m struct {
+		Config v1.Descriptor `json:"config"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(b)).Decode(&m); err != nil {
+		return nil, err
+	}
+	return &m.Config, nil
 }
 
 // Descriptor retains the original descriptor from an index manifest.
@@ -144,6 +189,13 @@ func (rl *remoteImageLayer) Digest() (v1.Hash, error) {
 
 // Compressed implements partial.CompressedLayer
 func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
+	return rl.CompressedWithContext(rl.ri.context)
+}
+
+// CompressedWithContext is like Compressed, but fetches the layer with ctx
+// rather than the context its image was created with.
+// See partial.CompressedWithContext.
+func (rl *remoteImageLayer) CompressedWithContext(ctx context.Context) (_ io.ReadCloser, rerr error) {
 	urls := []url.URL{rl.ri.url("blobs", rl.digest.String())}
 
 	// Add alternative layer sources from URLs (usually none).
@@ -157,7 +209,18 @@ func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
 	}
 
 	// We don't want to log binary layers -- this can break terminals.
-	ctx := redact.NewContext(rl.ri.context, "omitting binary blobs from logs")
+	ctx = redact.NewContext(ctx, "omitting binary blobs from logs")
+
+	// The layer is read as a unit of work of the budget, until it's closed.
+	ctx, release, err := rl.ri.budget.Hold(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if rerr != nil {
+			release()
+		}
+	}()
 
 	for _, s := range d.URLs {
 		u, err := url.Parse(s)
@@ -190,7 +253,11 @@ func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
 			continue
 		}
 
-		return verify.ReadCloser(resp.Body, d.Size, rl.digest)
+		rc, err := verify.ReadCloser(resp.Body, d.Size, rl.digest)
+		if err != nil {
+			return nil, err
+		}
+		return &heldBlob{ReadCloser: logPulled(ctx, rc, rl.digest), release: release}, nil
 	}
 
 	return nil, lastErr
@@ -239,6 +306,11 @@ func (rl *remoteImageLayer) Exists() (bool, error) {
 	return rl.ri.blobExists(rl.digest)
 }
 
+// See partial.Stat.
+func (rl *remoteImageLayer) Stat() (*v1.Descriptor, error) {
+	return rl.ri.statBlob(rl.digest)
+}
+
 // LayerByDigest implements partial.CompressedLayer
 func (r *remoteImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
 	return &remoteImageLayer{
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -27,6 +28,7 @@ import (
 	"path"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -739,3 +741,156 @@ func TestData(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestImageWithContext(t *testing.T) {
+	// Blob fetches hang until they're cancelled.
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			<-r.Context().Done()
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// The image is created without a deadline...
+	rmt, err := Image(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ...but its manifest and blobs can be fetched with one.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := partial.RawManifestWithContext(ctx, rmt); err != nil {
+		t.Fatal(err)
+	}
+	ls, err := partial.LayersWithContext(ctx, rmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partial.CompressedWithContext(ctx, ls[0]); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CompressedWithContext() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if _, err := partial.UncompressedWithContext(ctx, ls[0]); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("UncompressedWithContext() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if _, err := partial.RawConfigFileWithContext(ctx, rmt); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RawConfigFileWithContext() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The same goes for layers fetched directly.
+	l, err := Layer(ref.Context().Digest(mustDigest(t, ls[0]).String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partial.CompressedWithContext(ctx, l); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CompressedWithContext() of remote.Layer = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestImageWithLowMemory(t *testing.T) {
+	// Count how many times the config is fetched.
+	var cfgDigest string
+	var fetches int
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && cfgDigest != "" && strings.HasSuffix(r.URL.Path, "/blobs/"+cfgDigest) {
+			fetches++
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	wantName, err := img.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgDigest = wantName.String()
+
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want int
+	}{{
+		name: "default",
+		want: 1,
+	}, {
+		name: "low memory",
+		opts: []Option{WithLowMemory()},
+		want: 2,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			fetches = 0
+			rmt, err := Image(ref, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The config's digest comes from the manifest.
+			if got, err := rmt.ConfigName(); err != nil {
+				t.Fatal(err)
+			} else if got != wantName {
+				t.Errorf("ConfigName() = %v, want %v", got, wantName)
+			}
+			if fetches != 0 {
+				t.Errorf("ConfigName() fetched the config %d times", fetches)
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := rmt.RawConfigFile(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if fetches != tc.want {
+				t.Errorf("fetched the config %d times, want %d", fetches, tc.want)
+			}
+			if err := validate.Image(rmt); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	want := []byte(strings.Repeat("abcdefgh", 128))
+	for _, n := range []int64{-1, 0, 10, int64(len(want)), int64(len(want)) * 2, maxPrealloc + 1} {
+		got, err := readAll(bytes.NewReader(want), n)
+		if err != nil {
+			t.Fatalf("readAll(%d): %v", n, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("readAll(%d) = %d bytes, want %d", n, len(got), len(want))
+		}
+		if n == int64(len(want)) && cap(got) != len(want) {
+			t.Errorf("readAll(%d) has capacity %d, want %d", n, cap(got), len(want))
+		}
+	}
+}
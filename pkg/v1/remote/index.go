@@ -16,6 +16,7 @@ package remote
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"sync"
 
@@ -38,6 +39,11 @@ type remoteIndex struct {
 	manifest     []byte
 	mediaType    types.MediaType
 	descriptor   *v1.Descriptor
+
+	// Indexes can be huge, so we only parse them once. Protected by indexLock.
+	indexLock sync.Mutex
+	index     *v1.IndexManifest
+	children  map[v1.Hash]int // Position of each digest in index.Manifests.
 }
 
 // Index provides access to a remote index reference.
@@ -66,6 +72,13 @@ func (r *remoteIndex) Size() (int64, error) {
 }
 
 func (r *remoteIndex) RawManifest() ([]byte, error) {
+	return r.RawManifestWithContext(r.context)
+}
+
+// RawManifestWithContext is like RawManifest, but fetches the manifest with
+// ctx rather than the context it was created with.
+// See partial.RawManifestWithContext.
+func (r *remoteIndex) RawManifestWithContext(ctx context.Context) ([]byte, error) {
 	r.manifestLock.Lock()
 	defer r.manifestLock.Unlock()
 	if r.manifest != nil {
@@ -75,7 +88,7 @@ func (r *remoteIndex) RawManifest() ([]byte, error) {
 	// NOTE(jonjohnsonjr): We should never get here because the public entrypoints
 	// do type-checking via remote.Descriptor. I've left this here for tests that
 	// directly instantiate a remoteIndex.
-	manifest, desc, err := r.fetchManifest(r.Ref, acceptableIndexMediaTypes)
+	manifest, desc, err := r.fetchManifest(ctx, r.Ref, acceptableIndexMediaTypes)
 	if err != nil {
 		return nil, err
 	}
@@ -89,11 +102,36 @@ func (r *remoteIndex) RawManifest() ([]byte, error) {
 }
 
 func (r *remoteIndex) IndexManifest() (*v1.IndexManifest, error) {
+	index, err := r.indexManifest()
+	if err != nil {
+		return nil, err
+	}
+	// Callers are free to modify what we return, so don't share it.
+	return index.DeepCopy(), nil
+}
+
+// indexManifest returns the parsed manifest, which must not be modified.
+func (r *remoteIndex) indexManifest() (*v1.IndexManifest, error) {
+	r.indexLock.Lock()
+	defer r.indexLock.Unlock()
+	if r.index != nil {
+		return r.index, nil
+	}
 	b, err := r.RawManifest()
 	if err != nil {
 		return nil, err
 	}
-	return v1.ParseIndexManifest(bytes.NewReader(b))
+	index, err := v1.ParseIndexManifest(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	r.children = make(map[v1.Hash]int, len(index.Manifests))
+	for i := len(index.Manifests) - 1; i >= 0; i-- {
+		// Keep the first occurrence of each digest.
+		r.children[index.Manifests[i].Digest] = i
+	}
+	r.index = index
+	return r.index, nil
 }
 
 func (r *remoteIndex) Image(h v1.Hash) (v1.Image, error) {
@@ -125,7 +163,7 @@ func (r *remoteIndex) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
 
 // Workaround for #819.
 func (r *remoteIndex) Layer(h v1.Hash) (v1.Layer, error) {
-	index, err := r.IndexManifest()
+	index, err := r.indexManifest()
 	if err != nil {
 		return nil, err
 	}
@@ -150,7 +188,7 @@ func (r *remoteIndex) Layer(h v1.Hash) (v1.Layer, error) {
 // Experiment with a better API for v1.ImageIndex. We might want to move this
 // to partial?
 func (r *remoteIndex) Manifests() ([]partial.Describable, error) {
-	m, err := r.IndexManifest()
+	m, err := r.indexManifest()
 	if err != nil {
 		return nil, err
 	}
@@ -199,7 +237,7 @@ func (r *remoteIndex) imageByPlatform(platform v1.Platform) (v1.Image, error) {
 // But first we'd need to migrate to:
 //   github.com/opencontainers/image-spec/specs-go/v1
 func (r *remoteIndex) childByPlatform(platform v1.Platform) (*Descriptor, error) {
-	index, err := r.IndexManifest()
+	index, err := r.indexManifest()
 	if err != nil {
 		return nil, err
 	}
@@ -218,14 +256,12 @@ func (r *remoteIndex) childByPlatform(platform v1.Platform) (*Descriptor, error)
 }
 
 func (r *remoteIndex) childByHash(h v1.Hash) (*Descriptor, error) {
-	index, err := r.IndexManifest()
+	index, err := r.indexManifest()
 	if err != nil {
 		return nil, err
 	}
-	for _, childDesc := range index.Manifests {
-		if h == childDesc.Digest {
-			return r.childDescriptor(childDesc, defaultPlatform)
-		}
+	if i, ok := r.children[h]; ok {
+		return r.childDescriptor(index.Manifests[i], defaultPlatform)
 	}
 	return nil, fmt.Errorf("no child with digest %s in index %s", h, r.Ref)
 }
@@ -243,19 +279,22 @@ func (r *remoteIndex) childDescriptor(child v1.Descriptor, platform v1.Platform)
 		}
 		manifest = child.Data
 	} else {
-		manifest, _, err = r.fetchManifest(ref, []types.MediaType{child.MediaType})
+		manifest, _, err = r.fetchManifest(r.context, ref, []types.MediaType{child.MediaType})
 		if err != nil {
 			return nil, err
 		}
 	}
 	return &Descriptor{
 		fetcher: fetcher{
-			Ref:     ref,
-			Client:  r.Client,
-			context: r.context,
+			Ref:       ref,
+			Client:    r.Client,
+			context:   r.context,
+			lowMemory: r.lowMemory,
+			budget:    r.budget,
 		},
-		Manifest:   manifest,
-		Descriptor: child,
+		Manifest: manifest,
+		// Don't share maps with the memoized index manifest.
+		Descriptor: *child.DeepCopy(),
 		platform:   platform,
 	}, nil
 }
@@ -265,43 +304,17 @@ func (r *remoteIndex) childDescriptor(child v1.Descriptor, platform v1.Platform)
 // - architecture and OS are identical.
 // - OS version and variant are identical if provided.
 // - features and OS features of the required platform are subsets of those of the given platform.
+//
+// Platforms are compared after normalization, see v1.Platform.Matches.
 func matchesPlatform(given, required v1.Platform) bool {
 	// Required fields that must be identical.
-	if given.Architecture != required.Architecture || given.OS != required.OS {
+	g, r := given.Normalize(), required.Normalize()
+	if g.Architecture != r.Architecture || g.OS != r.OS {
 		return false
 	}
 
 	// Optional fields that may be empty, but must be identical if provided.
-	if required.OSVersion != "" && given.OSVersion != required.OSVersion {
-		return false
-	}
-	if required.Variant != "" && given.Variant != required.Variant {
-		return false
-	}
-
-	// Verify required platform's features are a subset of given platform's features.
-	if !isSubset(given.OSFeatures, required.OSFeatures) {
-		return false
-	}
-	if !isSubset(given.Features, required.Features) {
-		return false
-	}
-
-	return true
-}
-
-// isSubset checks if the required array of strings is a subset of the given lst.
-func isSubset(lst, required []string) bool {
-	set := make(map[string]bool)
-	for _, value := range lst {
-		set[value] = true
-	}
-
-	for _, value := range required {
-		if _, ok := set[value]; !ok {
-			return false
-		}
-	}
-
-	return true
+	// Features and OS features of the required platform must be subsets of
+	// those of the given platform.
+	return given.Matches(required)
 }
@@ -493,6 +493,32 @@ func TestMatchesPlatform(t *testing.T) {
 			},
 			want: true,
 		},
+		{ // Architecture aliases and default variants are normalized.
+			// matchesPlatform expected to return true.
+			given: v1.Platform{
+				Architecture: "arm64",
+				OS:           "linux",
+			},
+			required: v1.Platform{
+				Architecture: "aarch64",
+				OS:           "linux",
+				Variant:      "v8",
+			},
+			want: true,
+		},
+		{ // A required variant must still match after normalization.
+			// matchesPlatform expected to return false.
+			given: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				Variant:      "v6",
+			},
+			required: v1.Platform{
+				Architecture: "armhf",
+				OS:           "linux",
+			},
+			want: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -15,6 +15,7 @@
 package remote
 
 import (
+	"context"
 	"io"
 
 	"github.com/google/go-containerregistry/internal/redact"
@@ -33,8 +34,15 @@ type remoteLayer struct {
 
 // Compressed implements partial.CompressedLayer
 func (rl *remoteLayer) Compressed() (io.ReadCloser, error) {
+	return rl.CompressedWithContext(rl.context)
+}
+
+// CompressedWithContext is like Compressed, but fetches the layer with ctx
+// rather than the context it was created with.
+// See partial.CompressedWithContext.
+func (rl *remoteLayer) CompressedWithContext(ctx context.Context) (io.ReadCloser, error) {
 	// We don't want to log binary layers -- this can break terminals.
-	ctx := redact.NewContext(rl.context, "omitting binary blobs from logs")
+	ctx = redact.NewContext(ctx, "omitting binary blobs from logs")
 	return rl.fetchBlob(ctx, verify.SizeUnknown, rl.digest)
 }
 
@@ -63,6 +71,11 @@ func (rl *remoteLayer) Exists() (bool, error) {
 	return rl.blobExists(rl.digest)
 }
 
+// See partial.Stat.
+func (rl *remoteLayer) Stat() (*v1.Descriptor, error) {
+	return rl.statBlob(rl.digest)
+}
+
 // Layer reads the given blob reference from a registry as a Layer. A blob
 // reference here is just a punned name.Digest where the digest portion is the
 // digest of the blob to be read and the repository portion is the repo where
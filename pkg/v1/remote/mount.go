@@ -15,6 +15,9 @@
 package remote
 
 import (
+	"context"
+	"io"
+
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
@@ -39,6 +42,21 @@ func (ml *MountableLayer) Exists() (bool, error) {
 	return partial.Exists(ml.Layer)
 }
 
+// Stat is a hack. See partial.Stat.
+func (ml *MountableLayer) Stat() (*v1.Descriptor, error) {
+	return partial.Stat(ml.Layer)
+}
+
+// CompressedWithContext implements partial.CompressedWithContext.
+func (ml *MountableLayer) CompressedWithContext(ctx context.Context) (io.ReadCloser, error) {
+	return partial.CompressedWithContext(ctx, ml.Layer)
+}
+
+// UncompressedWithContext implements partial.UncompressedWithContext.
+func (ml *MountableLayer) UncompressedWithContext(ctx context.Context) (io.ReadCloser, error) {
+	return partial.UncompressedWithContext(ctx, ml.Layer)
+}
+
 // mountableImage wraps the v1.Layer references returned by the embedded v1.Image
 // in MountableLayer's so that remote.Write might attempt to mount them from their
 // source repository.
@@ -93,3 +111,13 @@ func (mi *mountableImage) LayerByDiffID(d v1.Hash) (v1.Layer, error) {
 func (mi *mountableImage) Descriptor() (*v1.Descriptor, error) {
 	return partial.Descriptor(mi.Image)
 }
+
+// RawManifestWithContext implements partial.RawManifestWithContext.
+func (mi *mountableImage) RawManifestWithContext(ctx context.Context) ([]byte, error) {
+	return partial.RawManifestWithContext(ctx, mi.Image)
+}
+
+// RawConfigFileWithContext implements partial.RawConfigFileWithContext.
+func (mi *mountableImage) RawConfigFileWithContext(ctx context.Context) ([]byte, error) {
+	return partial.RawConfigFileWithContext(ctx, mi.Image)
+}
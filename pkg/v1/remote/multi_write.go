@@ -94,6 +94,7 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) (rerr error) {
 		lastUpdate: &v1.Update{},
 		backoff:    o.retryBackoff,
 		predicate:  o.retryPredicate,
+		budget:     o.budget,
 	}
 
 	// Collect the total size of blobs and manifests we're about to write.
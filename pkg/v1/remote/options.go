@@ -25,6 +25,7 @@ import (
 
 	"github.com/google/go-containerregistry/internal/retry"
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/concurrency"
 	"github.com/google/go-containerregistry/pkg/logs"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
@@ -40,12 +41,15 @@ type options struct {
 	platform                       v1.Platform
 	context                        context.Context
 	jobs                           int
+	budget                         *concurrency.Budget
 	userAgent                      string
 	allowNondistributableArtifacts bool
 	updates                        chan<- v1.Update
 	pageSize                       int
 	retryBackoff                   Backoff
 	retryPredicate                 retry.Predicate
+	logging                        []transport.LoggerOption
+	lowMemory                      bool
 }
 
 var defaultPlatform = v1.Platform{
@@ -56,14 +60,20 @@ var defaultPlatform = v1.Platform{
 // Backoff is an alias of retry.Backoff to expose this configuration option to consumers of this lib
 type Backoff = retry.Backoff
 
-var defaultRetryPredicate retry.Predicate = func(err error) bool {
-	// Various failure modes here, as we're often reading from and writing to
-	// the network.
-	if retry.IsTemporary(err) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.Is(err, syscall.EPIPE) {
-		logs.Warn.Printf("retrying %v", err)
-		return true
+var defaultRetryPredicate = retryPredicateFor(context.Background())
+
+// retryPredicateFor returns the default retry.Predicate, which logs retries to
+// the logger carried by ctx.
+func retryPredicateFor(ctx context.Context) retry.Predicate {
+	return func(err error) bool {
+		// Various failure modes here, as we're often reading from and writing to
+		// the network.
+		if retry.IsTemporary(err) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.Is(err, syscall.EPIPE) {
+			logs.FromContext(ctx).Log(logs.LevelWarn, "retrying", "error", err)
+			return true
+		}
+		return false
 	}
-	return false
 }
 
 // Try this three times, waiting 1s after first failure, 3s after second.
@@ -102,14 +112,14 @@ var DefaultTransport = &http.Transport{
 
 func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 	o := &options{
-		auth:           authn.Anonymous,
-		transport:      DefaultTransport,
-		platform:       defaultPlatform,
-		context:        context.Background(),
-		jobs:           defaultJobs,
-		pageSize:       defaultPageSize,
-		retryPredicate: defaultRetryPredicate,
-		retryBackoff:   defaultRetryBackoff,
+		auth:         authn.Anonymous,
+		transport:    DefaultTransport,
+		platform:     defaultPlatform,
+		context:      context.Background(),
+		jobs:         defaultJobs,
+		budget:       concurrency.Default(),
+		pageSize:     defaultPageSize,
+		retryBackoff: defaultRetryBackoff,
 	}
 
 	for _, option := range opts {
@@ -118,6 +128,10 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 		}
 	}
 
+	if o.retryPredicate == nil {
+		o.retryPredicate = retryPredicateFor(o.context)
+	}
+
 	if o.keychain != nil {
 		auth, err := o.keychain.Resolve(target)
 		if err != nil {
@@ -130,11 +144,9 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 	// This is to allow consumers full control over the transports logic, such as providing retry logic.
 	if _, ok := o.transport.(*transport.Wrapper); !ok {
 		// Wrap the transport in something that logs requests and responses.
-		// It's expensive to generate the dumps, so skip it if we're writing
-		// to nothing.
-		if logs.Enabled(logs.Debug) {
-			o.transport = transport.NewLogger(o.transport)
-		}
+		// It checks per request whether debug logging is enabled, since the
+		// request's context may carry its own logger.
+		o.transport = transport.NewLogger(o.transport, o.logging...)
 
 		// Wrap the transport in something that can retry network flakes.
 		o.transport = transport.NewRetry(o.transport)
@@ -199,7 +211,12 @@ func WithPlatform(p v1.Platform) Option {
 // performed by a given function. Note that this context is used for _all_
 // http requests, not just the initial volley. E.g., for remote.Image, the
 // context will be set on http requests generated by subsequent calls to
-// RawConfigFile() and even methods on layers returned by Layers().
+// RawConfigFile() and even methods on layers returned by Layers(). To fetch
+// those with a different context, use partial.RawConfigFileWithContext,
+// partial.CompressedWithContext, etc.
+//
+// Messages are logged to the logger carried by the context, see
+// logs.NewContext and logs.WithRequestID.
 //
 // The default context is context.Background().
 func WithContext(ctx context.Context) Option {
@@ -224,6 +241,32 @@ func WithJobs(jobs int) Option {
 	}
 }
 
+// WithBudget sets the concurrency budget that bounds how many blobs and
+// manifests are uploaded or downloaded at once, across every operation sharing
+// it. It applies on top of WithJobs. A blob being downloaded counts against
+// the budget until it's closed, unless it's read to upload it elsewhere, in
+// which case the upload and download share a slot.
+//
+// The default is concurrency.Default().
+func WithBudget(b *concurrency.Budget) Option {
+	return func(o *options) error {
+		o.budget = b
+		return nil
+	}
+}
+
+// WithLowMemory keeps images from retaining the config files they fetch, so
+// that jobs holding many images at once don't hold all of their configs too.
+// Each call to RawConfigFile or ConfigFile fetches the config again, but
+// ConfigName is answered from the manifest. Manifests are still retained,
+// since nearly every method needs them.
+func WithLowMemory() Option {
+	return func(o *options) error {
+		o.lowMemory = true
+		return nil
+	}
+}
+
 // WithUserAgent adds the given string to the User-Agent header for any HTTP
 // requests. This header will also include "go-containerregistry/${version}".
 //
@@ -282,3 +325,13 @@ func WithRetryPredicate(predicate retry.Predicate) Option {
 		return nil
 	}
 }
+
+// WithWireLogging configures how requests and responses are logged at
+// logs.LevelDebug, e.g. to truncate bodies with transport.WithMaxBodySize.
+// It has no effect if the transport is a *transport.Wrapper.
+func WithWireLogging(opts ...transport.LoggerOption) Option {
+	return func(o *options) error {
+		o.logging = append(o.logging, opts...)
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Referrers returns descriptors of the manifests whose subject is d, using the
+// OCI referrers API.
+//
+// If the registry doesn't support that API, Referrers falls back to the
+// referrers tag schema, reading the index tagged "<algorithm>-<hex>" in d's
+// repository. If neither finds anything, the returned index is empty.
+func Referrers(d name.Digest, options ...Option) (*v1.IndexManifest, error) {
+	o, err := makeOptions(d.Context(), options...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := makeFetcher(d, o)
+	if err != nil {
+		return nil, err
+	}
+	return f.fetchReferrers(d)
+}
+
+func (f *fetcher) fetchReferrers(d name.Digest) (*v1.IndexManifest, error) {
+	u := f.url("referrers", d.DigestStr())
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(types.OCIImageIndex))
+
+	resp, err := f.Client.Do(req.WithContext(f.context))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return f.fetchReferrersTag(d)
+	}
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return v1.ParseIndexManifest(resp.Body)
+}
+
+// fetchReferrersTag reads the referrers of d from the referrers tag schema.
+func (f *fetcher) fetchReferrersTag(d name.Digest) (*v1.IndexManifest, error) {
+	h, err := v1.NewHash(d.DigestStr())
+	if err != nil {
+		return nil, err
+	}
+	tag := d.Context().Tag(fmt.Sprintf("%s-%s", h.Algorithm, h.Hex))
+	b, _, err := f.fetchManifest(f.context, tag, []types.MediaType{types.OCIImageIndex})
+	if err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			return &v1.IndexManifest{
+				SchemaVersion: 2,
+				MediaType:     types.OCIImageIndex,
+			}, nil
+		}
+		return nil, err
+	}
+	return v1.ParseIndexManifest(bytes.NewReader(b))
+}
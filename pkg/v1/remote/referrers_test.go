@@ -0,0 +1,100 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestReferrers(t *testing.T) {
+	subject := v1.Hash{Algorithm: "sha256", Hex: "1111111111111111111111111111111111111111111111111111111111111111"}
+	sig := v1.Descriptor{
+		MediaType:    types.OCIManifestSchema1,
+		Digest:       v1.Hash{Algorithm: "sha256", Hex: "2222222222222222222222222222222222222222222222222222222222222222"},
+		Size:         123,
+		ArtifactType: "application/vnd.dev.cosign.artifact.sig.v1+json",
+	}
+	index := v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests:     []v1.Descriptor{sig},
+	}
+	b, err := json.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := v1.IndexManifest{SchemaVersion: 2, MediaType: types.OCIImageIndex}
+
+	referrersPath := fmt.Sprintf("/v2/foo/referrers/%s", subject)
+	tagPath := fmt.Sprintf("/v2/foo/manifests/sha256-%s", subject.Hex)
+	for _, tc := range []struct {
+		name  string
+		paths map[string]bool
+		want  v1.IndexManifest
+	}{{
+		name:  "api",
+		paths: map[string]bool{referrersPath: true},
+		want:  index,
+	}, {
+		name:  "tag schema",
+		paths: map[string]bool{tagPath: true},
+		want:  index,
+	}, {
+		name: "none",
+		want: empty,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v2/" {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				if !tc.paths[r.URL.Path] {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Type", string(types.OCIImageIndex))
+				w.Write(b)
+			}))
+			defer server.Close()
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := name.NewDigest(fmt.Sprintf("%s/foo@%s", u.Host, subject))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Referrers(d)
+			if err != nil {
+				t.Fatalf("Referrers() = %v", err)
+			}
+			if diff := cmp.Diff(&tc.want, got); diff != "" {
+				t.Errorf("Referrers() (-want +got) = %s", diff)
+			}
+		})
+	}
+}
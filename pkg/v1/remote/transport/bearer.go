@@ -24,6 +24,8 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	authchallenge "github.com/docker/distribution/registry/client/auth/challenge"
 	"github.com/google/go-containerregistry/internal/redact"
@@ -35,10 +37,18 @@ import (
 type bearerTransport struct {
 	// Wrapped by bearerTransport.
 	inner http.RoundTripper
+
+	// Protects the fields below that change when we refresh.
+	mu sync.Mutex
 	// Basic credentials that we exchange for bearer tokens.
 	basic authn.Authenticator
 	// Holds the bearer response from the token service.
 	bearer authn.AuthConfig
+	// When to get a new bearer token, before it expires, if we know when it
+	// expires; otherwise zero.
+	refreshAt time.Time
+	// Returns the current time; replaced in tests.
+	now func() time.Time
 	// Registry to which we send bearer tokens.
 	registry name.Registry
 	// See https://tools.ietf.org/html/rfc6750#section-3
@@ -65,6 +75,10 @@ func stringSet(ss []string) map[string]struct{} {
 	return set
 }
 
+// tokenRefreshSkew is how long before a bearer token expires that we get a
+// new one, so that it doesn't expire while a request is in flight.
+const tokenRefreshSkew = 30 * time.Second
+
 // RoundTrip implements http.RoundTripper
 func (bt *bearerTransport) RoundTrip(in *http.Request) (*http.Response, error) {
 	sendRequest := func() (*http.Response, error) {
@@ -74,12 +88,26 @@ func (bt *bearerTransport) RoundTrip(in *http.Request) (*http.Response, error) {
 		// the registry with which we are interacting.
 		// In case of redirect http.Client can use an empty Host, check URL too.
 		if matchesHost(bt.registry, in, bt.scheme) {
+			bt.mu.Lock()
 			hdr := fmt.Sprintf("Bearer %s", bt.bearer.RegistryToken)
+			bt.mu.Unlock()
 			in.Header.Set("Authorization", hdr)
 		}
 		return bt.inner.RoundTrip(in)
 	}
 
+	// Get a new token before the current one expires, rather than waiting
+	// for the registry to reject it: by then, the request body may have been
+	// consumed, and we couldn't retry.
+	bt.mu.Lock()
+	if !bt.refreshAt.IsZero() && !bt.clock().Before(bt.refreshAt) {
+		if err := bt.refresh(in.Context()); err != nil {
+			bt.mu.Unlock()
+			return nil, err
+		}
+	}
+	bt.mu.Unlock()
+
 	res, err := sendRequest()
 	if err != nil {
 		return nil, err
@@ -87,6 +115,7 @@ func (bt *bearerTransport) RoundTrip(in *http.Request) (*http.Response, error) {
 
 	// If we hit a WWW-Authenticate challenge, it might be due to expired tokens or insufficient scope.
 	if challenges := authchallenge.ResponseChallenges(res); len(challenges) != 0 {
+		bt.mu.Lock()
 		newScopes := []string{}
 		for _, wac := range challenges {
 			// TODO(jonjohnsonjr): Should we also update "realm" or "service"?
@@ -108,7 +137,9 @@ func (bt *bearerTransport) RoundTrip(in *http.Request) (*http.Response, error) {
 		// TODO(jonjohnsonjr): Teach transport.Error about "error" and "error_description" from challenge.
 
 		// Retry the request to attempt to get a valid token.
-		if err = bt.refresh(in.Context()); err != nil {
+		err = bt.refresh(in.Context())
+		bt.mu.Unlock()
+		if err != nil {
 			return nil, err
 		}
 		return sendRequest()
@@ -117,10 +148,34 @@ func (bt *bearerTransport) RoundTrip(in *http.Request) (*http.Response, error) {
 	return res, err
 }
 
+// expireAt sets when to refresh a token that expires after lifetime: within
+// tokenRefreshSkew of expiring, or halfway for tokens that don't last much
+// longer than that. A lifetime that isn't positive means we don't know.
+func (bt *bearerTransport) expireAt(lifetime time.Duration) {
+	if lifetime <= 0 {
+		bt.refreshAt = time.Time{}
+		return
+	}
+	skew := tokenRefreshSkew
+	if lifetime < 2*skew {
+		skew = lifetime / 2
+	}
+	bt.refreshAt = bt.clock().Add(lifetime - skew)
+}
+
+func (bt *bearerTransport) clock() time.Time {
+	if bt.now != nil {
+		return bt.now()
+	}
+	return time.Now()
+}
+
 // It's unclear which authentication flow to use based purely on the protocol,
 // so we rely on heuristics and fallbacks to support as many registries as possible.
 // The basic token exchange is attempted first, falling back to the oauth flow.
 // If the IdentityToken is set, this indicates that we should start with the oauth flow.
+//
+// bt.mu must be held, unless bt isn't shared yet.
 func (bt *bearerTransport) refresh(ctx context.Context) error {
 	auth, err := bt.basic.Authorization()
 	if err != nil {
@@ -129,6 +184,15 @@ func (bt *bearerTransport) refresh(ctx context.Context) error {
 
 	if auth.RegistryToken != "" {
 		bt.bearer.RegistryToken = auth.RegistryToken
+		// Authenticators that know when the token expires, e.g. those from
+		// authn.NewRefreshingAuthenticator, will give us a new one.
+		var lifetime time.Duration
+		if e, ok := bt.basic.(authn.Expirer); ok {
+			if expiry := e.Expiry(); !expiry.IsZero() {
+				lifetime = expiry.Sub(bt.clock())
+			}
+		}
+		bt.expireAt(lifetime)
 		return nil
 	}
 
@@ -157,7 +221,7 @@ func (bt *bearerTransport) refresh(ctx context.Context) error {
 		Token        string `json:"token"`
 		AccessToken  string `json:"access_token"`
 		RefreshToken string `json:"refresh_token"`
-		// TODO: handle expiry?
+		ExpiresIn    int64  `json:"expires_in"`
 	}
 
 	var response tokenResponse
@@ -177,12 +241,18 @@ func (bt *bearerTransport) refresh(ctx context.Context) error {
 		return fmt.Errorf("no token in bearer response:\n%s", content)
 	}
 
+	// Remember when the token expires, measured from when we got it rather
+	// than from "issued_at", so that clock skew doesn't matter. Tokens
+	// without "expires_in" are refreshed when the registry rejects them.
+	bt.expireAt(time.Duration(response.ExpiresIn) * time.Second)
+
 	// If we obtained a refresh token from the oauth flow, use that for refresh() now.
 	if response.RefreshToken != "" {
 		bt.basic = authn.FromConfig(authn.AuthConfig{
 			IdentityToken: response.RefreshToken,
 		})
 	}
+	logs.FromContext(ctx).Log(logs.LevelDebug, "refreshed token", "registry", bt.registry, "scopes", bt.scopes)
 
 	return nil
 }
@@ -263,7 +333,7 @@ func (bt *bearerTransport) refreshOauth(ctx context.Context) ([]byte, error) {
 	defer resp.Body.Close()
 
 	if err := CheckError(resp, http.StatusOK); err != nil {
-		logs.Warn.Printf("No matching credentials were found for %q", bt.registry)
+		logs.FromContext(ctx).Log(logs.LevelWarn, "No matching credentials were found", "registry", bt.registry)
 		return nil, err
 	}
 
@@ -303,7 +373,7 @@ func (bt *bearerTransport) refreshBasic(ctx context.Context) ([]byte, error) {
 	defer resp.Body.Close()
 
 	if err := CheckError(resp, http.StatusOK); err != nil {
-		logs.Warn.Printf("No matching credentials were found for %q", bt.registry)
+		logs.FromContext(ctx).Log(logs.LevelWarn, "No matching credentials were found", "registry", bt.registry)
 		return nil, err
 	}
 
@@ -22,6 +22,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -559,3 +560,149 @@ func TestInsufficientScope(t *testing.T) {
 		t.Error("didn't refresh insufficient scope")
 	}
 }
+
+func TestBearerTransportExpiry(t *testing.T) {
+	for _, tc := range []struct {
+		expiresIn int
+		// How long after getting a token we should get a new one.
+		refreshAfter time.Duration
+	}{{
+		expiresIn:    300,
+		refreshAfter: 270 * time.Second,
+	}, {
+		// Short-lived tokens are refreshed halfway.
+		expiresIn:    20,
+		refreshAfter: 10 * time.Second,
+	}} {
+		t.Run(fmt.Sprint(tc.expiresIn), func(t *testing.T) {
+			var tokens int
+			var got string
+			server := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					hdr := r.Header.Get("Authorization")
+					if strings.HasPrefix(hdr, "Basic ") {
+						tokens++
+						w.Write([]byte(fmt.Sprintf(`{"token": "token-%d", "expires_in": %d}`, tokens, tc.expiresIn)))
+						return
+					}
+					got = strings.TrimPrefix(hdr, "Bearer ")
+				}))
+			defer server.Close()
+
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			registry, err := name.NewRegistry(u.Host, name.WeakValidation)
+			if err != nil {
+				t.Fatalf("Unexpected error during NewRegistry: %v", err)
+			}
+			now := time.Now()
+			transport := &bearerTransport{
+				inner:    http.DefaultTransport,
+				basic:    &authn.Basic{Username: "foo", Password: "bar"},
+				registry: registry,
+				realm:    server.URL,
+				scheme:   "http",
+				now:      func() time.Time { return now },
+			}
+			if err := transport.refresh(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			client := http.Client{Transport: transport}
+
+			for _, step := range []struct {
+				after time.Duration
+				want  string
+			}{{
+				after: tc.refreshAfter - time.Second,
+				want:  "token-1",
+			}, {
+				after: time.Second,
+				want:  "token-2",
+			}} {
+				now = now.Add(step.after)
+				res, err := client.Get(fmt.Sprintf("http://%s/v2/foo/bar/blobs/blah", u.Host))
+				if err != nil {
+					t.Fatalf("Unexpected error during client.Get: %v", err)
+				}
+				res.Body.Close()
+				if got != step.want {
+					t.Errorf("after %v: sent token %q, want %q", step.after, got, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBearerTransportExpirer(t *testing.T) {
+	var got string
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := name.NewRegistry(u.Host, name.WeakValidation)
+	if err != nil {
+		t.Fatalf("Unexpected error during NewRegistry: %v", err)
+	}
+
+	// The authenticator hands out registry tokens that last an hour. It uses
+	// the real clock, so start the transport's clock an hour ago, less a few
+	// seconds, for the authenticator to agree that the first token is about
+	// to expire when the transport does.
+	now := time.Now().Add(-time.Hour + 10*time.Second)
+	var calls int
+	auth := authn.NewRefreshingAuthenticator(authenticatorFunc(func() (*authn.AuthConfig, error) {
+		calls++
+		return &authn.AuthConfig{RegistryToken: fmt.Sprintf("token-%d", calls)}, nil
+	}), func(*authn.AuthConfig) time.Time {
+		return now.Add(time.Hour)
+	})
+	transport := &bearerTransport{
+		inner:    http.DefaultTransport,
+		basic:    auth,
+		registry: registry,
+		scheme:   "http",
+		now:      func() time.Time { return now },
+	}
+	if err := transport.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	client := http.Client{Transport: transport}
+
+	start := now
+	for _, step := range []struct {
+		at   time.Duration
+		want string
+	}{{
+		at:   30 * time.Minute,
+		want: "token-1",
+	}, {
+		// The transport asks for a new token within 30s of expiry, and the
+		// authenticator gives it one.
+		at:   time.Hour - 10*time.Second,
+		want: "token-2",
+	}} {
+		now = start.Add(step.at)
+		res, err := client.Get(fmt.Sprintf("http://%s/v2/foo/bar/blobs/blah", u.Host))
+		if err != nil {
+			t.Fatalf("Unexpected error during client.Get: %v", err)
+		}
+		res.Body.Close()
+		if got != step.want {
+			t.Errorf("at %v: sent token %q, want %q", step.at, got, step.want)
+		}
+	}
+}
+
+type authenticatorFunc func() (*authn.AuthConfig, error)
+
+func (f authenticatorFunc) Authorization() (*authn.AuthConfig, error) {
+	return f()
+}
@@ -15,77 +15,225 @@
 package transport
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httputil"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/go-containerregistry/internal/redact"
 	"github.com/google/go-containerregistry/pkg/logs"
 )
 
+// redactedHeaders are replaced with "<redacted>" in dumps.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// lastID is used to give each logged round trip a unique ID, so that the
+// messages for concurrent requests can be told apart.
+var lastID int64
+
 type logTransport struct {
 	inner http.RoundTripper
+
+	// maxBody is the number of bytes of each body to log, or -1 to log bodies
+	// in full.
+	maxBody int64
+}
+
+// LoggerOption is a functional option for NewLogger.
+type LoggerOption func(*logTransport)
+
+// WithMaxBodySize truncates the request and response bodies that are logged to
+// n bytes, noting how many bytes were left out. Pass 0 to only log headers.
+//
+// By default, bodies are logged in full.
+func WithMaxBodySize(n int64) LoggerOption {
+	return func(t *logTransport) {
+		if n < 0 {
+			n = 0
+		}
+		t.maxBody = n
+	}
 }
 
-// NewLogger returns a transport that logs requests and responses to
-// github.com/google/go-containerregistry/pkg/logs.Debug.
-func NewLogger(inner http.RoundTripper) http.RoundTripper {
-	return &logTransport{inner}
+// NewLogger returns a transport that logs requests and responses at
+// logs.LevelDebug to the logger carried by each request's context, see
+// github.com/google/go-containerregistry/pkg/logs.
+//
+// Every message for a round trip carries the same "http_id". Credential
+// headers are always redacted, as are the bodies of form posts and of requests
+// that this library knows to contain tokens or binary data.
+//
+// At logs.LevelTrace, it also logs how long each phase of a round trip took
+// (DNS, connect, TLS and time to first byte) and the throughput of bodies.
+func NewLogger(inner http.RoundTripper, opts ...LoggerOption) http.RoundTripper {
+	t := &logTransport{inner: inner, maxBody: -1}
+	for _, o := range opts {
+		o(t)
+	}
+	return t
 }
 
 func (t *logTransport) RoundTrip(in *http.Request) (out *http.Response, err error) {
 	// Inspired by: github.com/motemen/go-loghttp
+	// It's expensive to generate the dumps, so skip it if we're writing to
+	// nothing.
+	logger := logs.FromContext(in.Context())
+	debug, trace := logger.Enabled(logs.LevelDebug), logger.Enabled(logs.LevelTrace)
+	if !debug && !trace {
+		return t.inner.RoundTrip(in)
+	}
+	id := atomic.AddInt64(&lastID, 1)
+
+	var tt *timings
+	if trace {
+		in, tt = withTimings(in, logger, id)
+	}
 
 	// We redact token responses and binary blobs in response/request.
 	omitBody, reason := redact.FromContext(in.Context())
-	if omitBody {
-		logs.Debug.Printf("--> %s %s [body redacted: %s]", in.Method, in.URL, reason)
-	} else {
-		logs.Debug.Printf("--> %s %s", in.Method, in.URL)
+	if debug {
+		t.logRequest(logger, id, in, omitBody, reason)
 	}
 
-	// Save these headers so we can redact Authorization.
-	savedHeaders := in.Header.Clone()
-	if in.Header != nil && in.Header.Get("authorization") != "" {
-		in.Header.Set("authorization", "<redacted>")
+	start := time.Now()
+	out, err = t.inner.RoundTrip(in)
+	duration := time.Since(start)
+	if trace {
+		tt.logResponse(out, err)
 	}
+	if !debug {
+		return
+	}
+	if err != nil {
+		logger.Log(logs.LevelDebug, "<--", "http_id", id, "method", in.Method, "url", in.URL, "error", err, "duration", duration)
+	}
+	if out != nil {
+		t.logResponse(logger, id, in, out, duration, omitBody, reason)
+	}
+	return
+}
 
-	b, err := httputil.DumpRequestOut(in, !omitBody)
-	if err == nil {
-		logs.Debug.Println(string(b))
+func (t *logTransport) logRequest(logger logs.Logger, id int64, in *http.Request, omitBody bool, reason string) {
+	if omitBody {
+		logger.Log(logs.LevelDebug, "-->", "http_id", id, "method", in.Method, "url", in.URL, "redacted", reason)
 	} else {
-		logs.Debug.Printf("Failed to dump request %s %s: %v", in.Method, in.URL, err)
+		logger.Log(logs.LevelDebug, "-->", "http_id", id, "method", in.Method, "url", in.URL)
 	}
 
+	// Form posts are used to exchange credentials for tokens, so never log
+	// their contents.
+	omitBody = omitBody || in.Header.Get("Content-Type") == "application/x-www-form-urlencoded"
+
+	// Save these headers so we can redact them.
+	savedHeaders := in.Header
+	in.Header = redactHeaders(in.Header)
+	var (
+		b   []byte
+		err error
+	)
+	if omitBody || t.maxBody < 0 {
+		b, err = httputil.DumpRequestOut(in, !omitBody)
+	} else if b, err = httputil.DumpRequestOut(in, false); err == nil {
+		var (
+			body      []byte
+			truncated bool
+		)
+		body, truncated, in.Body, err = peek(in.Body, t.maxBody)
+		b = appendBody(b, body, truncated, in.ContentLength)
+	}
 	// Restore the non-redacted headers.
 	in.Header = savedHeaders
+	if err == nil {
+		logger.Log(logs.LevelDebug, string(b), "http_id", id)
+	} else {
+		logger.Log(logs.LevelDebug, "Failed to dump request", "http_id", id, "method", in.Method, "url", in.URL, "error", err)
+	}
+}
 
-	start := time.Now()
-	out, err = t.inner.RoundTrip(in)
-	duration := time.Since(start)
-	if err != nil {
-		logs.Debug.Printf("<-- %v %s %s (%s)", err, in.Method, in.URL, duration)
+func (t *logTransport) logResponse(logger logs.Logger, id int64, in *http.Request, out *http.Response, duration time.Duration, omitBody bool, reason string) {
+	kv := []interface{}{"http_id", id, "status", out.StatusCode}
+	if out.Request != nil {
+		kv = append(kv, "url", out.Request.URL)
 	}
-	if out != nil {
-		msg := fmt.Sprintf("<-- %d", out.StatusCode)
-		if out.Request != nil {
-			msg = fmt.Sprintf("%s %s", msg, out.Request.URL)
-		}
-		msg = fmt.Sprintf("%s (%s)", msg, duration)
+	kv = append(kv, "duration", duration)
+	if omitBody {
+		kv = append(kv, "redacted", reason)
+	}
+	logger.Log(logs.LevelDebug, "<--", kv...)
+
+	savedHeaders := out.Header
+	out.Header = redactHeaders(out.Header)
+	var (
+		b   []byte
+		err error
+	)
+	if omitBody || t.maxBody < 0 {
+		b, err = httputil.DumpResponse(out, !omitBody)
+	} else if b, err = httputil.DumpResponse(out, false); err == nil {
+		var (
+			body      []byte
+			truncated bool
+		)
+		body, truncated, out.Body, err = peek(out.Body, t.maxBody)
+		b = appendBody(b, body, truncated, out.ContentLength)
+	}
+	out.Header = savedHeaders
+	if err == nil {
+		logger.Log(logs.LevelDebug, string(b), "http_id", id)
+	} else {
+		logger.Log(logs.LevelDebug, "Failed to dump response", "http_id", id, "method", in.Method, "url", in.URL, "error", err)
+	}
+}
 
-		if omitBody {
-			msg = fmt.Sprintf("%s [body redacted: %s]", msg, reason)
+// redactHeaders returns a copy of h with credentials replaced.
+func redactHeaders(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	h = h.Clone()
+	for _, k := range redactedHeaders {
+		if _, ok := h[k]; ok {
+			h.Set(k, "<redacted>")
 		}
+	}
+	return h
+}
 
-		logs.Debug.Print(msg)
+// peek reads up to n bytes from rc, returning them, whether rc had more, and
+// a ReadCloser that still yields all of rc.
+func peek(rc io.ReadCloser, n int64) ([]byte, bool, io.ReadCloser, error) {
+	if rc == nil || rc == http.NoBody {
+		return nil, false, rc, nil
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, rc, n+1); err != nil && err != io.EOF {
+		return nil, false, rc, err
+	}
+	b := buf.Bytes()
+	body := &readCloser{io.MultiReader(bytes.NewReader(b), rc), rc}
+	if int64(len(b)) > n {
+		return b[:n], true, body, nil
+	}
+	return b, false, body, nil
+}
 
-		b, err := httputil.DumpResponse(out, !omitBody)
-		if err == nil {
-			logs.Debug.Println(string(b))
-		} else {
-			logs.Debug.Printf("Failed to dump response %s %s: %v", in.Method, in.URL, err)
-		}
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// appendBody appends a (possibly truncated) body of size bytes to a dump of
+// headers, noting how much of it was left out.
+func appendBody(dump, body []byte, truncated bool, size int64) []byte {
+	dump = append(dump, body...)
+	if !truncated {
+		return dump
 	}
-	return
+	if size > 0 {
+		return append(dump, fmt.Sprintf("\n[truncated %d of %d bytes]", size-int64(len(body)), size)...)
+	}
+	return append(dump, "\n[truncated]"...)
 }
@@ -91,3 +91,81 @@ func TestLoggerError(t *testing.T) {
 		t.Errorf("Expected logs to contain %s, got %s", canary, logged)
 	}
 }
+
+type echoTransport struct{}
+
+func (echoTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	b, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Set-Cookie": []string{"session=secret"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       in,
+	}, nil
+}
+
+func TestLoggerMaxBodySize(t *testing.T) {
+	var b bytes.Buffer
+	logs.Debug.SetOutput(&b)
+	defer logs.Debug.SetOutput(ioutil.Discard)
+
+	body := strings.Repeat("a", 10) + strings.Repeat("b", 90)
+	req, err := http.NewRequest(http.MethodPut, "http://example.com", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Proxy-Authorization", "Basic secret")
+
+	tr := NewLogger(echoTransport{}, WithMaxBodySize(10))
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() = %v", err)
+	}
+	got, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != body {
+		t.Errorf("body = %q, want %q", got, body)
+	}
+	if resp.Header.Get("Set-Cookie") != "session=secret" {
+		t.Errorf("Set-Cookie = %q, redaction should not modify the response", resp.Header.Get("Set-Cookie"))
+	}
+
+	logged := b.String()
+	for _, want := range []string{"aaaaaaaaaa\n[truncated 90 of 100 bytes]", "http_id=", "<redacted>"} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("Expected logs to contain %q, got %s", want, logged)
+		}
+	}
+	for _, unwanted := range []string{"ab", "Basic secret", "session=secret"} {
+		if strings.Contains(logged, unwanted) {
+			t.Errorf("Expected logs NOT to contain %q, got %s", unwanted, logged)
+		}
+	}
+}
+
+func TestLoggerFormBody(t *testing.T) {
+	var b bytes.Buffer
+	logs.Debug.SetOutput(&b)
+	defer logs.Debug.SetOutput(ioutil.Discard)
+
+	secret := "client_secret=hunter2"
+	req, err := http.NewRequest(http.MethodPost, "http://example.com/token", strings.NewReader(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if _, err := NewLogger(echoTransport{}).RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() = %v", err)
+	}
+	if logged := b.String(); strings.Count(logged, secret) != 1 {
+		// The echoed response is logged, but the request body shouldn't be.
+		t.Errorf("Expected the request body to be omitted, got %s", logged)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+)
+
+// timings records when the phases of a round trip happened, so that slow
+// networks can be told apart from slow registries.
+type timings struct {
+	logger logs.Logger
+	id     int64
+	in     *http.Request
+
+	// sent counts the bytes read from the request body.
+	sent int64
+
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+// withTimings returns a copy of in that records its timings.
+func withTimings(in *http.Request, logger logs.Logger, id int64) (*http.Request, *timings) {
+	t := &timings{logger: logger, id: id, start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart:      func(string, string) { t.set(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { t.set(&t.connectDone) },
+		TLSHandshakeStart: func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.set(&t.gotConn)
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	}
+	in = in.WithContext(httptrace.WithClientTrace(in.Context(), trace))
+	if in.Body != nil && in.Body != http.NoBody {
+		in.Body = &countingReader{ReadCloser: in.Body, n: &t.sent}
+	}
+	t.in = in
+	return in, t
+}
+
+// set records the first time an event happened, since some (e.g. connecting)
+// can happen more than once.
+func (t *timings) set(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// logResponse logs the timings of the round trip, and arranges for the
+// throughput of the response body to be logged once it has been read.
+func (t *timings) logResponse(out *http.Response, err error) {
+	t.mu.Lock()
+	kv := []interface{}{"http_id", t.id, "method", t.in.Method, "url", t.in.URL, "reused", t.reused}
+	kv = appendPhase(kv, "dns", t.dnsStart, t.dnsDone)
+	kv = appendPhase(kv, "connect", t.connectStart, t.connectDone)
+	kv = appendPhase(kv, "tls", t.tlsStart, t.tlsDone)
+	if sent := atomic.LoadInt64(&t.sent); sent > 0 {
+		kv = append(kv, "sent", sent)
+		kv = appendRate(kv, "upload", sent, t.gotConn, t.wroteRequest)
+	}
+	// Time from having sent the request to the response starting to arrive is
+	// spent by the registry.
+	kv = appendPhase(kv, "wait", t.wroteRequest, t.firstByte)
+	kv = appendPhase(kv, "ttfb", t.start, t.firstByte)
+	firstByte := t.firstByte
+	t.mu.Unlock()
+
+	if err != nil {
+		kv = append(kv, "error", err)
+	}
+	t.logger.Log(logs.LevelTrace, "timings", kv...)
+
+	if out != nil && out.Body != nil && out.Body != http.NoBody {
+		out.Body = &countingReader{
+			ReadCloser: out.Body,
+			n:          new(int64),
+			done: func(n int64) {
+				kv := []interface{}{"http_id", t.id, "method", t.in.Method, "url", t.in.URL, "received", n}
+				kv = appendRate(kv, "download", n, firstByte, time.Now())
+				t.logger.Log(logs.LevelTrace, "received body", kv...)
+			},
+		}
+	}
+}
+
+func appendPhase(kv []interface{}, phase string, start, end time.Time) []interface{} {
+	if start.IsZero() || end.IsZero() {
+		return kv
+	}
+	return append(kv, phase, end.Sub(start))
+}
+
+func appendRate(kv []interface{}, key string, n int64, start, end time.Time) []interface{} {
+	if start.IsZero() || !end.After(start) {
+		return kv
+	}
+	return append(kv, key, throughput(n, end.Sub(start)))
+}
+
+// throughput formats n bytes transferred over d as a human-readable rate.
+func throughput(n int64, d time.Duration) string {
+	rate := float64(n) / d.Seconds()
+	switch {
+	case rate >= 1e6:
+		return fmt.Sprintf("%.1f MB/s", rate/1e6)
+	case rate >= 1e3:
+		return fmt.Sprintf("%.1f kB/s", rate/1e3)
+	default:
+		return fmt.Sprintf("%.0f B/s", rate)
+	}
+}
+
+// countingReader counts the bytes read through it, calling done once with
+// the total when it reaches EOF or is closed.
+type countingReader struct {
+	io.ReadCloser
+	n    *int64
+	done func(int64)
+	once sync.Once
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+	if err == io.EOF {
+		r.finish()
+	}
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	r.finish()
+	return r.ReadCloser.Close()
+}
+
+func (r *countingReader) finish() {
+	if r.done == nil {
+		return
+	}
+	r.once.Do(func() { r.done(atomic.LoadInt64(r.n)) })
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+)
+
+func TestLoggerTrace(t *testing.T) {
+	var trace, debug bytes.Buffer
+	logs.Trace.SetOutput(&trace)
+	defer logs.Trace.SetOutput(ioutil.Discard)
+	logs.Debug.SetOutput(&debug)
+	defer logs.Debug.SetOutput(ioutil.Discard)
+
+	body := strings.Repeat("x", 4096)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("upload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := NewLogger(http.DefaultTransport).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() = %v", err)
+	}
+	got, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if string(got) != body {
+		t.Errorf("body = %q, want %q", got, body)
+	}
+
+	logged := trace.String()
+	for _, want := range []string{"timings http_id=", "connect=", "sent=6", "wait=", "ttfb=", "received body", "received=4096", "download="} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("Expected trace logs to contain %q, got %s", want, logged)
+		}
+	}
+	if strings.Contains(debug.String(), "timings") {
+		t.Errorf("Expected timings only at trace level, got %s", debug.String())
+	}
+}
+
+func TestThroughput(t *testing.T) {
+	for _, tc := range []struct {
+		n    int64
+		want string
+	}{
+		{500, "500 B/s"},
+		{2500, "2.5 kB/s"},
+		{12345678, "12.3 MB/s"},
+	} {
+		if got := throughput(tc.n, 1e9); got != tc.want {
+			t.Errorf("throughput(%d, 1s) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
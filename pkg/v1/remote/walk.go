@@ -0,0 +1,93 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// WalkIndex fetches the index manifest for ref and passes each of its child
+// descriptors to walk as they are decoded from the response, so that indexes
+// with thousands of children never need to be held in memory at once. The
+// returned IndexManifest has every field except Manifests populated.
+//
+// If ref is a digest, the manifest can only be verified once it has been read
+// completely, so walk may see descriptors from a manifest that then fails
+// verification, in which case WalkIndex returns an error.
+func WalkIndex(ref name.Reference, walk func(v1.Descriptor) error, options ...Option) (*v1.IndexManifest, error) {
+	o, err := makeOptions(ref.Context(), options...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := makeFetcher(ref, o)
+	if err != nil {
+		return nil, err
+	}
+	return f.walkIndex(ref, walk)
+}
+
+func (f *fetcher) walkIndex(ref name.Reference, walk func(v1.Descriptor) error) (*v1.IndexManifest, error) {
+	u := f.url("manifests", ref.Identifier())
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	accept := []string{}
+	for _, mt := range acceptableIndexMediaTypes {
+		accept = append(accept, string(mt))
+	}
+	req.Header.Set("Accept", strings.Join(accept, ","))
+
+	resp, err := f.Client.Do(req.WithContext(f.context))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	if mt := types.MediaType(resp.Header.Get("Content-Type")); !mt.IsIndex() {
+		return nil, fmt.Errorf("%s is not an index: %s", ref, mt)
+	}
+
+	h := sha256.New()
+	index, err := v1.WalkIndexManifest(io.TeeReader(resp.Body, h), walk)
+	if err != nil {
+		return nil, err
+	}
+	// Hash any trailing whitespace, too.
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return nil, err
+	}
+
+	if dgst, ok := ref.(name.Digest); ok {
+		digest := v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(h.Sum(nil))}
+		if digest.String() != dgst.DigestStr() {
+			return nil, fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst.DigestStr(), f.Ref)
+		}
+	}
+	return index, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestWalkIndex(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(fmt.Sprintf("%s/test:index", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(100, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(tag, idx); err != nil {
+		t.Fatalf("WriteIndex: %v", err)
+	}
+	want, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []name.Reference{tag, tag.Context().Digest(digest.String())} {
+		var children []v1.Descriptor
+		got, err := WalkIndex(ref, func(desc v1.Descriptor) error {
+			children = append(children, desc)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkIndex(%s): %v", ref, err)
+		}
+		got.Manifests = children
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("WalkIndex(%s); (-want +got) %s", ref, diff)
+		}
+	}
+
+	// Missing manifests are an error.
+	other := tag.Context().Digest("sha256:" + fmt.Sprintf("%064d", 0))
+	if _, err := WalkIndex(other, func(v1.Descriptor) error { return nil }); err == nil {
+		t.Error("WalkIndex(missing digest) = nil, want error")
+	}
+
+	stop := errors.New("stop")
+	if _, err := WalkIndex(tag, func(v1.Descriptor) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("WalkIndex() = %v, want %v", err, stop)
+	}
+
+	// Images aren't indexes.
+	img, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgTag := tag.Context().Tag("image")
+	if err := Write(imgTag, img); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := WalkIndex(imgTag, func(v1.Descriptor) error { return nil }); err == nil {
+		t.Error("WalkIndex(image) = nil, want error")
+	}
+}
+
+func TestIndexManifestCopies(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(fmt.Sprintf("%s/test:index", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(100, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(tag, idx); err != nil {
+		t.Fatalf("WriteIndex: %v", err)
+	}
+
+	ridx, err := Index(tag)
+	if err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	m, err := ridx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := m.Manifests[0].Digest
+	m.Manifests[0].Digest = v1.Hash{}
+
+	// Modifying a returned manifest doesn't affect the index.
+	m, err = ridx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Manifests[0].Digest; got != want {
+		t.Errorf("Manifests[0].Digest = %s, want %s", got, want)
+	}
+	if _, err := ridx.Image(want); err != nil {
+		t.Errorf("Image(%s): %v", want, err)
+	}
+}
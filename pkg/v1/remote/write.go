@@ -27,6 +27,7 @@ import (
 
 	"github.com/google/go-containerregistry/internal/redact"
 	"github.com/google/go-containerregistry/internal/retry"
+	"github.com/google/go-containerregistry/pkg/concurrency"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -63,7 +64,7 @@ func Write(ref name.Reference, img v1.Image, options ...Option) (rerr error) {
 }
 
 func writeImage(ctx context.Context, ref name.Reference, img v1.Image, o *options, lastUpdate *v1.Update) error {
-	ls, err := img.Layers()
+	ls, err := partial.LayersWithContext(ctx, img)
 	if err != nil {
 		return err
 	}
@@ -80,6 +81,7 @@ func writeImage(ctx context.Context, ref name.Reference, img v1.Image, o *option
 		lastUpdate: lastUpdate,
 		backoff:    o.retryBackoff,
 		predicate:  o.retryPredicate,
+		budget:     o.budget,
 	}
 
 	// Upload individual blobs and collect any errors.
@@ -178,6 +180,7 @@ type writer struct {
 	lastUpdate *v1.Update
 	backoff    Backoff
 	predicate  retry.Predicate
+	budget     *concurrency.Budget
 }
 
 func sendError(ch chan<- v1.Update, err error) error {
@@ -408,8 +411,16 @@ func (w *writer) incrProgress(written int64) {
 	}
 }
 
-// uploadOne performs a complete upload of a single layer.
+// uploadOne performs a complete upload of a single layer, as a unit of work of
+// w.budget. The layer is read with the context that carries the slot, so that
+// reading a remote layer doesn't take another one.
 func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
+	ctx, release, err := w.budget.Hold(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	tryUpload := func() error {
 		var from, mount string
 		if h, err := l.Digest(); err == nil {
@@ -425,7 +436,7 @@ func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
 					return err
 				}
 				w.incrProgress(size)
-				logs.Progress.Printf("existing blob: %v", h)
+				logs.FromContext(ctx).Log(logs.LevelInfo, "existing blob", "digest", h)
 				return nil
 			}
 
@@ -450,7 +461,7 @@ func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
 			if err != nil {
 				return err
 			}
-			logs.Progress.Printf("mounted blob: %s", h.String())
+			logs.FromContext(ctx).Log(logs.LevelInfo, "mounted blob", "digest", h)
 			return nil
 		}
 
@@ -465,7 +476,7 @@ func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
 			ctx = redact.NewContext(ctx, "omitting binary blobs from logs")
 		}
 
-		blob, err := l.Compressed()
+		blob, err := partial.CompressedWithContext(ctx, l)
 		if err != nil {
 			return err
 		}
@@ -483,7 +494,7 @@ func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
 		if err := w.commitBlob(location, digest); err != nil {
 			return err
 		}
-		logs.Progress.Printf("pushed blob: %s", digest)
+		logs.FromContext(ctx).Log(logs.LevelInfo, "pushed blob", "digest", digest)
 		return nil
 	}
 
@@ -513,7 +524,7 @@ func (w *writer) writeIndex(ctx context.Context, ref name.Reference, ii v1.Image
 			return err
 		}
 		if exists {
-			logs.Progress.Print("existing manifest: ", desc.Digest)
+			logs.FromContext(ctx).Log(logs.LevelInfo, "existing manifest", "digest", desc.Digest)
 			continue
 		}
 
@@ -605,25 +616,29 @@ func (w *writer) commitManifest(ctx context.Context, t Taggable, ref name.Refere
 
 		u := w.url(fmt.Sprintf("/v2/%s/manifests/%s", w.repo.RepositoryStr(), ref.Identifier()))
 
-		// Make the request to PUT the serialized manifest
-		req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewBuffer(raw))
-		if err != nil {
-			return err
-		}
-		req.Header.Set("Content-Type", string(desc.MediaType))
+		// Make the request to PUT the serialized manifest, as a unit of work
+		// of w.budget. unpackTaggable may fetch the manifest, so it must not
+		// run while the slot is held.
+		if err := w.budget.Do(ctx, func(ctx context.Context) error {
+			req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewBuffer(raw))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", string(desc.MediaType))
 
-		resp, err := w.client.Do(req.WithContext(ctx))
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
+			resp, err := w.client.Do(req.WithContext(ctx))
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
 
-		if err := transport.CheckError(resp, http.StatusOK, http.StatusCreated, http.StatusAccepted); err != nil {
+			return transport.CheckError(resp, http.StatusOK, http.StatusCreated, http.StatusAccepted)
+		}); err != nil {
 			return err
 		}
 
 		// The image was successfully pushed!
-		logs.Progress.Printf("%v: digest: %v size: %d", ref, desc.Digest, desc.Size)
+		logs.FromContext(ctx).Log(logs.LevelInfo, "pushed manifest", "ref", ref, "digest", desc.Digest, "size", desc.Size)
 		w.incrProgress(int64(len(raw)))
 		return nil
 	}
@@ -677,6 +692,7 @@ func WriteIndex(ref name.Reference, ii v1.ImageIndex, options ...Option) (rerr e
 		updates:   o.updates,
 		backoff:   o.retryBackoff,
 		predicate: o.retryPredicate,
+		budget:    o.budget,
 	}
 
 	if o.updates != nil {
@@ -818,6 +834,7 @@ func WriteLayer(repo name.Repository, layer v1.Layer, options ...Option) (rerr e
 		updates:   o.updates,
 		backoff:   o.retryBackoff,
 		predicate: o.retryPredicate,
+		budget:    o.budget,
 	}
 
 	if o.updates != nil {
@@ -886,6 +903,7 @@ func Put(ref name.Reference, t Taggable, options ...Option) error {
 		context:   o.context,
 		backoff:   o.retryBackoff,
 		predicate: o.retryPredicate,
+		budget:    o.budget,
 	}
 
 	return w.commitManifest(o.context, t, ref)
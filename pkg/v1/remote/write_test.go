@@ -28,11 +28,15 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-containerregistry/pkg/concurrency"
+	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -45,6 +49,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"golang.org/x/sync/errgroup"
 )
 
 func mustNewTag(t *testing.T, s string) name.Tag {
@@ -1563,3 +1568,199 @@ func BenchmarkWrite(b *testing.B) {
 		}
 	}
 }
+
+func TestWriteContextLogger(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu   sync.Mutex
+		msgs = map[string]bool{}
+	)
+	logger := logs.LoggerFunc(func(level logs.Level, msg string, kv ...interface{}) {
+		if len(kv) < 2 || kv[0] != "request_id" || kv[1] != "copy-1" {
+			t.Errorf("%s %q logged with %v, want request_id first", level, msg, kv)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		msgs[msg] = true
+	})
+	ctx := logs.WithRequestID(logs.NewContext(context.Background(), logger), "copy-1")
+
+	ref := mustNewTag(t, fmt.Sprintf("%s/repo:latest", u.Host))
+	if err := Write(ref, img, WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"-->", "<--", "pushed blob", "pushed manifest"} {
+		if !msgs[msg] {
+			t.Errorf("%q was not logged to the context's logger", msg)
+		}
+	}
+}
+
+func TestWriteBudget(t *testing.T) {
+	var inFlight, most int32
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/blobs/uploads/") {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				m := atomic.LoadInt32(&most)
+				if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two concurrent writes share a budget of one upload at a time.
+	budget := concurrency.New(1)
+	var g errgroup.Group
+	for i := 0; i < 2; i++ {
+		ref := mustNewTag(t, fmt.Sprintf("%s/repo:%d", u.Host, i))
+		g.Go(func() error {
+			img, err := random.Image(1024, 4)
+			if err != nil {
+				return err
+			}
+			return Write(ref, img, WithJobs(4), WithBudget(budget))
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if most != 1 {
+		t.Errorf("at most %d blob upload requests were in flight, want 1", most)
+	}
+}
+
+func TestWriteBudgetNested(t *testing.T) {
+	// Use separate registries, so that no blob exists at the destination.
+	var hosts []string
+	for i := 0; i < 2; i++ {
+		s := httptest.NewServer(registry.New())
+		defer s.Close()
+		u, err := url.Parse(s.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, u.Host)
+	}
+
+	budget := concurrency.New(1)
+	src := mustNewTag(t, fmt.Sprintf("%s/src:latest", hosts[0]))
+	dst := mustNewTag(t, fmt.Sprintf("%s/dst:latest", hosts[1]))
+	idx, err := random.Index(1024, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(src, idx, WithBudget(budget)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Copying from the registry reads each blob while it's being uploaded,
+	// which must reuse the upload's slot rather than wait for another.
+	done := make(chan error)
+	go func() {
+		done <- func() error {
+			ridx, err := Index(src, WithBudget(budget))
+			if err != nil {
+				return err
+			}
+			if err := WriteIndex(dst, ridx, WithBudget(budget), WithJobs(4)); err != nil {
+				return err
+			}
+			im, err := ridx.IndexManifest()
+			if err != nil {
+				return err
+			}
+			img, err := ridx.Image(im.Manifests[0].Digest)
+			if err != nil {
+				return err
+			}
+			return Write(dst.Context().Tag("image"), img, WithBudget(budget), WithJobs(4))
+		}()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("copying with a budget of 1 deadlocked")
+	}
+
+	got, err := Index(dst, WithBudget(budget))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(got); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+}
+
+func TestJSONEvents(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	ctx := logs.NewContext(context.Background(), logs.NewJSONLogger(&b, logs.LevelInfo))
+	ref := mustNewTag(t, fmt.Sprintf("%s/repo:latest", u.Host))
+	if err := Write(ref, img, WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	pulled, err := Image(ref, WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(pulled); err != nil {
+		t.Fatal(err)
+	}
+
+	events := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var event struct {
+			Msg string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Unmarshal(%q) = %v", line, err)
+		}
+		events[event.Msg]++
+	}
+	for msg, want := range map[string]int{
+		"pushed blob":     3,
+		"pushed manifest": 1,
+		"pull started":    1,
+	} {
+		if got := events[msg]; got != want {
+			t.Errorf("%q was logged %d times, want %d: %v", msg, got, want, events)
+		}
+	}
+	if events["pulled blob"] < 3 {
+		t.Errorf("\"pulled blob\" was logged %d times, want at least 3: %v", events["pulled blob"], events)
+	}
+}
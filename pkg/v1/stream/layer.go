@@ -17,16 +17,19 @@ package stream
 import (
 	"bufio"
 	"compress/gzip"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"hash"
 	"io"
 	"os"
 	"sync"
 
+	"github.com/google/go-containerregistry/internal/sha256"
+	"github.com/google/go-containerregistry/pkg/compression"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/klauspost/compress/zstd"
 )
 
 var (
@@ -41,9 +44,12 @@ var (
 
 // Layer is a streaming implementation of v1.Layer.
 type Layer struct {
-	blob        io.ReadCloser
-	consumed    bool
-	compression int
+	blob             io.ReadCloser
+	opener           func() (io.ReadCloser, error)
+	consumed         bool
+	compression      compression.Compression
+	compressionLevel int
+	windowSize       int
 
 	mu             sync.Mutex
 	digest, diffID *v1.Hash
@@ -55,18 +61,62 @@ var _ v1.Layer = (*Layer)(nil)
 // LayerOption applies options to layer
 type LayerOption func(*Layer)
 
-// WithCompressionLevel sets the gzip compression. See `gzip.NewWriterLevel` for possible values.
+// WithCompressionLevel sets the compression level. For gzip, see
+// `gzip.NewWriterLevel` for possible values. For zstd, the level is
+// interpreted like the zstd command line levels (1-22) and mapped onto the
+// closest level that is supported.
 func WithCompressionLevel(level int) LayerOption {
 	return func(l *Layer) {
-		l.compression = level
+		l.compressionLevel = level
+	}
+}
+
+// WithCompression sets the compression algorithm used for the layer, which
+// defaults to gzip. The layer's media type is adjusted to match.
+func WithCompression(comp compression.Compression) LayerOption {
+	return func(l *Layer) {
+		l.compression = comp
+	}
+}
+
+// WithWindowSize sets the maximum size of the window, in bytes, used to find
+// repeated data. Larger windows compress better, but use more memory to
+// compress and decompress. This must be a power of 2, and is only supported
+// for zstd, since gzip's window is fixed at 32KB.
+func WithWindowSize(size int) LayerOption {
+	return func(l *Layer) {
+		l.windowSize = size
 	}
 }
 
 // NewLayer creates a Layer from an io.ReadCloser.
 func NewLayer(rc io.ReadCloser, opts ...LayerOption) *Layer {
 	layer := &Layer{
-		blob:        rc,
-		compression: gzip.BestSpeed,
+		blob:             rc,
+		compression:      compression.GZip,
+		compressionLevel: gzip.BestSpeed,
+	}
+
+	for _, opt := range opts {
+		opt(layer)
+	}
+
+	return layer
+}
+
+// NewLayerFromOpener creates a Layer that reads its contents from the
+// io.ReadCloser returned by opener.
+//
+// Unlike a Layer created by NewLayer, it can be read more than once, by
+// calling opener again, e.g. when an upload has to be retried. As with
+// NewLayer, Digest, DiffID, and Size are not available until the contents
+// have been read in full once; opener must return the same contents each
+// time it is called.
+func NewLayerFromOpener(opener func() (io.ReadCloser, error), opts ...LayerOption) *Layer {
+	layer := &Layer{
+		opener:           opener,
+		compression:      compression.GZip,
+		compressionLevel: gzip.BestSpeed,
 	}
 
 	for _, opt := range opts {
@@ -108,22 +158,38 @@ func (l *Layer) Size() (int64, error) {
 
 // MediaType implements v1.Layer
 func (l *Layer) MediaType() (types.MediaType, error) {
-	// We return DockerLayer for now as uncompressed layers
-	// are unimplemented
+	switch l.compression {
+	case compression.ZStd:
+		return types.OCILayerZStd, nil
+	case compression.None:
+		return types.DockerUncompressedLayer, nil
+	}
 	return types.DockerLayer, nil
 }
 
 // Uncompressed implements v1.Layer.
+//
+// This is only implemented for layers created by NewLayerFromOpener.
 func (l *Layer) Uncompressed() (io.ReadCloser, error) {
+	if l.opener != nil {
+		return l.opener()
+	}
 	return nil, errors.New("NYI: stream.Layer.Uncompressed is not implemented")
 }
 
 // Compressed implements v1.Layer.
 func (l *Layer) Compressed() (io.ReadCloser, error) {
+	if l.opener != nil {
+		rc, err := l.opener()
+		if err != nil {
+			return nil, err
+		}
+		return newCompressedReader(l, rc)
+	}
 	if l.consumed {
 		return nil, ErrConsumed
 	}
-	return newCompressedReader(l)
+	return newCompressedReader(l, l.blob)
 }
 
 // finalize sets the layer to consumed and computes all hash and size values.
@@ -153,14 +219,14 @@ type compressedReader struct {
 	closer func() error
 }
 
-func newCompressedReader(l *Layer) (*compressedReader, error) {
+func newCompressedReader(l *Layer, blob io.ReadCloser) (*compressedReader, error) {
 	// Collect digests of compressed and uncompressed stream and size of
 	// compressed stream.
 	h := sha256.New()
 	zh := sha256.New()
 	count := &countWriter{}
 
-	// gzip.Writer writes to the output stream via pipe, a hasher to
+	// The compressor writes to the output stream via pipe, a hasher to
 	// capture compressed digest, and a countWriter to capture compressed
 	// size.
 	pr, pw := io.Pipe()
@@ -168,15 +234,17 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 	// Write compressed bytes to be read by the pipe.Reader, hashed by zh, and counted by count.
 	mw := io.MultiWriter(pw, zh, count)
 
-	// Buffer the output of the gzip writer so we don't have to wait on pr to keep writing.
+	// Buffer the output of the compressor so we don't have to wait on pr to keep writing.
 	// 64K ought to be small enough for anybody.
 	bw := bufio.NewWriterSize(mw, 2<<16)
-	zw, err := gzip.NewWriterLevel(bw, l.compression)
+	zw, err := l.newWriter(bw)
 	if err != nil {
 		return nil, err
 	}
 
 	doneDigesting := make(chan struct{})
+	// complete is set before doneDigesting is closed if blob was read in full.
+	complete := false
 
 	cr := &compressedReader{
 		pr: pr,
@@ -199,21 +267,26 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 			//
 			// NOTE: net/http will call close on success, so if we've already
 			// closed the inner rc, it's not an error.
-			if err := l.blob.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			if err := blob.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
 				return err
 			}
 
 			// Finalize layer with its digest and size values.
 			<-doneDigesting
+			if !complete && l.opener != nil {
+				// We can read this layer again, so don't record the digest
+				// and size of a partial read.
+				return nil
+			}
 			return l.finalize(h, zh, count.n)
 		},
 	}
 	go func() {
-		// Copy blob into the gzip writer, which also hashes and counts the
+		// Copy blob into the compressor, which also hashes and counts the
 		// size of the compressed output, and hasher of the raw contents.
-		_, copyErr := io.Copy(io.MultiWriter(h, zw), l.blob)
+		_, copyErr := io.Copy(io.MultiWriter(h, zw), blob)
 
-		// Close the gzip writer once copying is done. If this is done in the
+		// Close the compressor once copying is done. If this is done in the
 		// Close method of compressedReader instead, then it can cause a panic
 		// when the compressedReader is closed before the blob is fully
 		// consumed and io.Copy in this goroutine is still blocking.
@@ -231,7 +304,7 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 			return
 		}
 
-		// Flush the buffer once all writes are complete to the gzip writer.
+		// Flush the buffer once all writes are complete to the compressor.
 		if err := bw.Flush(); err != nil {
 			close(doneDigesting)
 			pw.CloseWithError(err)
@@ -239,6 +312,7 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 		}
 
 		// Notify closer that digests are done being written.
+		complete = true
 		close(doneDigesting)
 
 		// Close the compressed reader to calculate digest/diffID/size. This
@@ -250,6 +324,30 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 	return cr, nil
 }
 
+// newWriter returns a writer that compresses to w according to l's options.
+func (l *Layer) newWriter(w io.Writer) (io.WriteCloser, error) {
+	if l.windowSize != 0 && l.compression != compression.ZStd {
+		return nil, fmt.Errorf("window size is not supported for %s", l.compression)
+	}
+	switch l.compression {
+	case compression.GZip:
+		return gzip.NewWriterLevel(w, l.compressionLevel)
+	case compression.ZStd:
+		opts := []zstd.EOption{zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(l.compressionLevel))}
+		if l.windowSize != 0 {
+			opts = append(opts, zstd.WithWindowSize(l.windowSize))
+		}
+		return zstd.NewWriter(w, opts...)
+	case compression.None:
+		return nopWriteCloser{w}, nil
+	}
+	return nil, fmt.Errorf("unsupported compression: %s", l.compression)
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
 func (cr *compressedReader) Read(b []byte) (int, error) { return cr.pr.Read(b) }
 
 func (cr *compressedReader) Close() error { return cr.closer() }
@@ -17,6 +17,7 @@ package stream
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"crypto/rand"
 	"errors"
 	"fmt"
@@ -25,6 +26,8 @@ import (
 	"strings"
 	"testing"
 
+	icompression "github.com/google/go-containerregistry/internal/compression"
+	"github.com/google/go-containerregistry/pkg/compression"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -223,6 +226,71 @@ func TestConsumed(t *testing.T) {
 	}
 }
 
+// TestOpenerRereadable tests that a layer created from an opener can be read
+// again after it has been consumed, or after a partial read.
+func TestOpenerRereadable(t *testing.T) {
+	opens := 0
+	l := NewLayerFromOpener(func() (io.ReadCloser, error) {
+		opens++
+		return ioutil.NopCloser(strings.NewReader(strings.Repeat("hello", 1000))), nil
+	})
+
+	// Abandon the first read partway through, like a failed upload.
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatalf("Compressed: %v", err)
+	}
+	if _, err := io.CopyN(ioutil.Discard, rc, 1); err != nil {
+		t.Fatalf("Error reading contents: %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := l.Digest(); !errors.Is(err, ErrNotComputed) {
+		t.Errorf("Digest after partial read: got %v, want %v", err, ErrNotComputed)
+	}
+
+	var digests []v1.Hash
+	for i := 0; i < 2; i++ {
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatalf("Compressed: %v", err)
+		}
+		if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+			t.Fatalf("Error reading contents: %v", err)
+		}
+		if err := rc.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatalf("Digest: %v", err)
+		}
+		digests = append(digests, h)
+	}
+	if digests[0] != digests[1] {
+		t.Errorf("Digest changed between reads: %s != %s", digests[0], digests[1])
+	}
+	if got, want := opens, 3; got != want {
+		t.Errorf("opener called %d times, want %d", got, want)
+	}
+
+	rc, err = l.Uncompressed()
+	if err != nil {
+		t.Fatalf("Uncompressed: %v", err)
+	}
+	defer rc.Close()
+	h, _, err := v1.SHA256(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffID, err := l.DiffID(); err != nil {
+		t.Fatalf("DiffID: %v", err)
+	} else if diffID != h {
+		t.Errorf("DiffID: got %s, want %s", diffID, h)
+	}
+}
+
 func TestCloseTextStreamBeforeConsume(t *testing.T) {
 	// Create stream layer from tar pipe
 	l := NewLayer(ioutil.NopCloser(strings.NewReader("hello")))
@@ -284,3 +352,86 @@ func TestMediaType(t *testing.T) {
 		t.Errorf("MediaType(): want %q, got %q", want, got)
 	}
 }
+
+func TestCompression(t *testing.T) {
+	contents := strings.Repeat("hello world ", 10000)
+
+	for _, c := range []struct {
+		comp compression.Compression
+		opts []LayerOption
+		want types.MediaType
+	}{{
+		comp: compression.GZip,
+		opts: []LayerOption{WithCompressionLevel(gzip.BestCompression)},
+		want: types.DockerLayer,
+	}, {
+		comp: compression.ZStd,
+		want: types.OCILayerZStd,
+	}, {
+		comp: compression.ZStd,
+		opts: []LayerOption{WithCompressionLevel(19), WithWindowSize(1 << 20)},
+		want: types.OCILayerZStd,
+	}, {
+		comp: compression.None,
+		want: types.DockerUncompressedLayer,
+	}} {
+		t.Run(string(c.comp), func(t *testing.T) {
+			opts := append([]LayerOption{WithCompression(c.comp)}, c.opts...)
+			l := NewLayer(ioutil.NopCloser(strings.NewReader(contents)), opts...)
+
+			if got, err := l.MediaType(); err != nil {
+				t.Fatalf("MediaType: %v", err)
+			} else if got != c.want {
+				t.Errorf("MediaType: got %q, want %q", got, c.want)
+			}
+
+			rc, err := l.Compressed()
+			if err != nil {
+				t.Fatalf("Compressed: %v", err)
+			}
+			b, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("Error reading contents: %v", err)
+			}
+			if err := rc.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			comp, pr, err := icompression.Peek(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if comp != c.comp {
+				t.Errorf("compressed with %q, want %q", comp, c.comp)
+			}
+			urc, err := icompression.Decompress(ioutil.NopCloser(pr), comp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadAll(urc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != contents {
+				t.Errorf("decompressed contents don't match")
+			}
+
+			diffID, err := l.DiffID()
+			if err != nil {
+				t.Fatalf("DiffID: %v", err)
+			}
+			if want, _, err := v1.SHA256(strings.NewReader(contents)); err != nil {
+				t.Fatal(err)
+			} else if diffID != want {
+				t.Errorf("DiffID: got %s, want %s", diffID, want)
+			}
+		})
+	}
+}
+
+func TestWindowSizeRequiresZstd(t *testing.T) {
+	l := NewLayer(ioutil.NopCloser(strings.NewReader("hello")), WithWindowSize(1<<20))
+	if _, err := l.Compressed(); err == nil {
+		t.Error("Compressed() with gzip and a window size should fail")
+	}
+}
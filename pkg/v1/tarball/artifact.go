@@ -0,0 +1,249 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tarball
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Artifacts don't fit the "docker save" format used for images, so they are
+// written as an OCI image layout in a tarball, as the image-spec allows.
+const (
+	ociLayoutFile = "oci-layout"
+	ociIndexFile  = "index.json"
+)
+
+var ociLayout = []byte(`{"imageLayoutVersion":"1.0.0"}`)
+
+func blobPath(h v1.Hash) string {
+	return path.Join("blobs", h.Algorithm, h.Hex)
+}
+
+// WriteArtifactToFile writes an artifact to a tarball, on disk.
+// This is just syntactic sugar wrapping tarball.WriteArtifact with a new file.
+func WriteArtifactToFile(p string, a v1.Artifact) error {
+	w, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	return WriteArtifact(w, a)
+}
+
+// WriteArtifact writes an artifact to w as a tarball of an OCI image layout,
+// whose index.json refers only to the artifact.
+func WriteArtifact(w io.Writer, a v1.Artifact) error {
+	raw, err := a.RawManifest()
+	if err != nil {
+		return err
+	}
+	mt, err := a.MediaType()
+	if err != nil {
+		return err
+	}
+	d, err := a.Digest()
+	if err != nil {
+		return err
+	}
+	at, err := a.ArtifactType()
+	if err != nil {
+		return err
+	}
+	index, err := json.Marshal(v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests: []v1.Descriptor{{
+			MediaType:    mt,
+			Size:         int64(len(raw)),
+			Digest:       d,
+			ArtifactType: at,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	config, err := a.Config()
+	if err != nil {
+		return err
+	}
+	blobs, err := a.Blobs()
+	if err != nil {
+		return err
+	}
+
+	tf := tar.NewWriter(w)
+	defer tf.Close()
+
+	if err := writeTarEntry(tf, ociLayoutFile, bytes.NewReader(ociLayout), int64(len(ociLayout))); err != nil {
+		return err
+	}
+	if err := writeTarEntry(tf, ociIndexFile, bytes.NewReader(index), int64(len(index))); err != nil {
+		return err
+	}
+	if err := writeTarEntry(tf, blobPath(d), bytes.NewReader(raw), int64(len(raw))); err != nil {
+		return err
+	}
+
+	seen := map[v1.Hash]bool{}
+	for _, blob := range append([]v1.Layer{config}, blobs...) {
+		h, err := blob.Digest()
+		if err != nil {
+			return err
+		}
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+
+		size, err := blob.Size()
+		if err != nil {
+			return err
+		}
+		rc, err := blob.Compressed()
+		if err != nil {
+			return err
+		}
+		if err := writeTarEntry(tf, blobPath(h), rc, size); err != nil {
+			rc.Close()
+			return err
+		}
+		if err := rc.Close(); err != nil {
+			return err
+		}
+	}
+
+	return tf.Close()
+}
+
+// ArtifactFromPath returns a v1.Artifact from a tarball written by
+// WriteArtifact, located on path.
+func ArtifactFromPath(path string) (v1.Artifact, error) {
+	return Artifact(pathOpener(path))
+}
+
+// Artifact exposes an artifact from a tarball written by WriteArtifact, or
+// any tarball of an OCI image layout whose index.json refers to exactly one
+// image manifest.
+func Artifact(opener Opener) (v1.Artifact, error) {
+	b, err := readFileFromTar(opener, ociIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	index, err := v1.ParseIndexManifest(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ociIndexFile, err)
+	}
+	if len(index.Manifests) != 1 {
+		return nil, fmt.Errorf("%s refers to %d manifests, expected 1", ociIndexFile, len(index.Manifests))
+	}
+	desc := index.Manifests[0]
+	if !desc.MediaType.IsImage() {
+		return nil, fmt.Errorf("unexpected media type for Artifact(): %s", desc.MediaType)
+	}
+
+	t := &ociTarball{opener: opener, mediaType: desc.MediaType}
+	if t.rawManifest, err = readFileFromTar(opener, blobPath(desc.Digest)); err != nil {
+		return nil, err
+	}
+	if t.manifest, err = v1.ParseManifest(bytes.NewReader(t.rawManifest)); err != nil {
+		return nil, err
+	}
+	img, err := partial.CompressedToImage(t)
+	if err != nil {
+		return nil, err
+	}
+	return artifact.FromImage(img), nil
+}
+
+func readFileFromTar(opener Opener, filePath string) ([]byte, error) {
+	rc, err := extractFileFromTar(opener, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// ociTarball implements partial.CompressedImageCore for a manifest in a
+// tarball of an OCI image layout.
+type ociTarball struct {
+	opener      Opener
+	mediaType   types.MediaType
+	rawManifest []byte
+	manifest    *v1.Manifest
+}
+
+func (t *ociTarball) MediaType() (types.MediaType, error) {
+	return t.mediaType, nil
+}
+
+func (t *ociTarball) RawManifest() ([]byte, error) {
+	return t.rawManifest, nil
+}
+
+func (t *ociTarball) RawConfigFile() ([]byte, error) {
+	return readFileFromTar(t.opener, blobPath(t.manifest.Config.Digest))
+}
+
+func (t *ociTarball) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	if h == t.manifest.Config.Digest {
+		return &ociTarballBlob{opener: t.opener, desc: t.manifest.Config}, nil
+	}
+	for _, desc := range t.manifest.Layers {
+		if desc.Digest == h {
+			return &ociTarballBlob{opener: t.opener, desc: desc}, nil
+		}
+	}
+	return nil, fmt.Errorf("blob %s not found in tarball", h)
+}
+
+type ociTarballBlob struct {
+	opener Opener
+	desc   v1.Descriptor
+}
+
+func (b *ociTarballBlob) Digest() (v1.Hash, error) {
+	return b.desc.Digest, nil
+}
+
+func (b *ociTarballBlob) Compressed() (io.ReadCloser, error) {
+	return extractFileFromTar(b.opener, blobPath(b.desc.Digest))
+}
+
+func (b *ociTarballBlob) Size() (int64, error) {
+	return b.desc.Size, nil
+}
+
+func (b *ociTarballBlob) MediaType() (types.MediaType, error) {
+	return b.desc.MediaType, nil
+}
+
+func (b *ociTarballBlob) Descriptor() (*v1.Descriptor, error) {
+	return &b.desc, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tarball
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/artifact"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+)
+
+func TestArtifact(t *testing.T) {
+	blob := static.NewLayer([]byte("allow = true"), "application/vnd.example.policy.rego")
+	a, err := artifact.New("application/vnd.example.policy.v1", artifact.WithBlobs(blob, blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteArtifact(&buf, a); err != nil {
+		t.Fatalf("WriteArtifact() = %v", err)
+	}
+	got, err := Artifact(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	if err != nil {
+		t.Fatalf("Artifact() = %v", err)
+	}
+
+	for _, f := range []func(v1.Artifact) (interface{}, error){
+		func(a v1.Artifact) (interface{}, error) { return a.Digest() },
+		func(a v1.Artifact) (interface{}, error) { return a.ArtifactType() },
+	} {
+		want, err := f(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := f(got); err != nil || got != want {
+			t.Errorf("got %v, %v, want %v", got, err, want)
+		}
+	}
+
+	h, err := blob.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := got.Blob(h)
+	if err != nil {
+		t.Fatalf("Blob() = %v", err)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if b, err := ioutil.ReadAll(rc); err != nil || string(b) != "allow = true" {
+		t.Errorf("blob = %q, %v", b, err)
+	}
+
+	cfg, err := got.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt, err := cfg.MediaType(); err != nil || mt != "application/vnd.oci.empty.v1+json" {
+		t.Errorf("config media type = %s, %v", mt, err)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tarball
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// LayerFromFS returns a v1.Layer holding the files and directories in fsys,
+// e.g. an embed.FS or the result of os.DirFS.  Entries are named by their
+// path in fsys, relative to its root; use fs.Sub to select a subtree.
+//
+// The layer is reproducible: entries are written in lexical order, and all
+// timestamps and ownership are zeroed, so only paths, permissions and file
+// contents contribute to its digest.  Symbolic links are followed, and other
+// irregular files are an error.
+//
+// The tarball is generated each time the layer is read, so fsys must not
+// change for the lifetime of the layer.
+func LayerFromFS(fsys fs.FS, opts ...LayerOption) (v1.Layer, error) {
+	opener := func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			tw := tar.NewWriter(pw)
+			if err := writeFS(tw, fsys); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			pw.CloseWithError(tw.Close())
+		}()
+		return pr, nil
+	}
+	return LayerFromOpener(opener, opts...)
+}
+
+// writeFS writes the contents of fsys to tw.  fs.WalkDir visits entries in
+// lexical order, which makes the output deterministic.
+func writeFS(tw *tar.Writer, fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == "." {
+			return nil
+		}
+
+		if d.IsDir() {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     path + "/",
+				Mode:     int64(fi.Mode().Perm()),
+			})
+		}
+
+		f, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		// Stat the opened file rather than d, to follow symbolic links.
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return fmt.Errorf("%s: unsupported file mode %s", path, fi.Mode())
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path,
+			Mode:     int64(fi.Mode().Perm()),
+			Size:     fi.Size(),
+		}); err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tarball
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLayerFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"usr/bin/app":    {Data: []byte("binary"), Mode: 0755},
+		"etc/app.conf":   {Data: []byte("key=value"), Mode: 0644},
+		"etc/empty.conf": {Mode: 0600},
+	}
+
+	layer, err := LayerFromFS(fsys)
+	if err != nil {
+		t.Fatalf("LayerFromFS() = %v", err)
+	}
+
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	type entry struct {
+		Name    string
+		Mode    int64
+		Content string
+	}
+	var got []entry
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if !hdr.ModTime.IsZero() && hdr.ModTime.Unix() != 0 {
+			t.Errorf("%s: ModTime = %v, want zero", hdr.Name, hdr.ModTime)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, entry{hdr.Name, hdr.Mode, string(b)})
+	}
+	want := []entry{
+		{"etc/", 0555, ""},
+		{"etc/app.conf", 0644, "key=value"},
+		{"etc/empty.conf", 0600, ""},
+		{"usr/", 0555, ""},
+		{"usr/bin/", 0555, ""},
+		{"usr/bin/app", 0755, "binary"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("entries (-want +got) = %s", diff)
+	}
+
+	// The same contents always produce the same layer.
+	again, err := LayerFromFS(fsys)
+	if err != nil {
+		t.Fatalf("LayerFromFS() = %v", err)
+	}
+	d1, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := again.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d1 != d2 {
+		t.Errorf("Digest() = %v, then %v", d1, d2)
+	}
+}
+
+func TestLayerFromFSDirFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	layer, err := LayerFromFS(os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("LayerFromFS() = %v", err)
+	}
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for _, name := range []string{"file", "link"} {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name != name || hdr.Typeflag != tar.TypeReg || hdr.Size != int64(len("hello")) {
+			t.Errorf("entry = %s (type %c, size %d), want regular file %s", hdr.Name, hdr.Typeflag, hdr.Size, name)
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Errorf("Next() = %v, want EOF", err)
+	}
+}
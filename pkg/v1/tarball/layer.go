@@ -17,6 +17,7 @@ package tarball
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -25,8 +26,11 @@ import (
 
 	"github.com/containerd/stargz-snapshotter/estargz"
 	"github.com/google/go-containerregistry/internal/and"
+	icompression "github.com/google/go-containerregistry/internal/compression"
 	gestargz "github.com/google/go-containerregistry/internal/estargz"
 	ggzip "github.com/google/go-containerregistry/internal/gzip"
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/concurrency"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -37,10 +41,12 @@ type layer struct {
 	size               int64
 	compressedopener   Opener
 	uncompressedopener Opener
-	compression        int
+	compression        compression.Compression
+	compressionLevel   int
 	annotations        map[string]string
 	estgzopts          []estargz.Option
 	mediaType          types.MediaType
+	budget             *concurrency.Budget
 }
 
 // Descriptor implements partial.withDescriptor.
@@ -49,12 +55,17 @@ func (l *layer) Descriptor() (*v1.Descriptor, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &v1.Descriptor{
-		Size:        l.size,
-		Digest:      digest,
-		Annotations: l.annotations,
-		MediaType:   l.mediaType,
-	}, nil
+	desc := &v1.Descriptor{
+		Size:      l.size,
+		Digest:    digest,
+		MediaType: l.mediaType,
+	}
+	// Leave Annotations nil when there are none, so that the descriptor
+	// survives a round-trip through JSON unchanged.
+	if len(l.annotations) != 0 {
+		desc.Annotations = l.annotations
+	}
+	return desc, nil
 }
 
 // Digest implements v1.Layer
@@ -94,7 +105,19 @@ type LayerOption func(*layer)
 // compression level used for compressing uncompressed tarballs.
 func WithCompressionLevel(level int) LayerOption {
 	return func(l *layer) {
-		l.compression = level
+		l.compressionLevel = level
+	}
+}
+
+// WithCompression is a functional option for overriding the algorithm used
+// to compress the layer. If the input is already compressed with a different
+// algorithm, it will be recompressed.
+//
+// Unless overridden by WithMediaType, the layer's media type is adjusted to
+// match the compression.
+func WithCompression(comp compression.Compression) LayerOption {
+	return func(l *layer) {
+		l.compression = comp
 	}
 }
 
@@ -105,6 +128,17 @@ func WithMediaType(mt types.MediaType) LayerOption {
 	}
 }
 
+// WithBudget is a functional option for setting the concurrency budget that
+// bounds how many layers compute their digests at once, across every
+// operation sharing it. The opener is called while the slot is held, so it
+// must not draw on b itself, e.g. by reading a remote layer that shares it.
+// By default, computing digests isn't limited.
+func WithBudget(b *concurrency.Budget) LayerOption {
+	return func(l *layer) {
+		l.budget = b
+	}
+}
+
 // WithCompressedCaching is a functional option that overrides the
 // logic for accessing the compressed bytes to memoize the result
 // and avoid expensive repeated gzips.
@@ -149,7 +183,7 @@ func WithEstargz(l *layer) {
 		if err != nil {
 			return nil, err
 		}
-		eopts := append(l.estgzopts, estargz.WithCompressionLevel(l.compression))
+		eopts := append(l.estgzopts, estargz.WithCompressionLevel(l.compressionLevel))
 		rc, h, err := gestargz.ReadCloser(crc, eopts...)
 		if err != nil {
 			return nil, err
@@ -205,54 +239,71 @@ func LayerFromOpener(opener Opener, opts ...LayerOption) (v1.Layer, error) {
 	}
 	defer rc.Close()
 
-	compressed, err := ggzip.Is(rc)
+	comp, _, err := icompression.Peek(rc)
 	if err != nil {
 		return nil, err
 	}
 
 	layer := &layer{
-		compression: gzip.BestSpeed,
-		annotations: make(map[string]string, 1),
-		mediaType:   types.DockerLayer,
+		compression:      compression.GZip,
+		compressionLevel: gzip.BestSpeed,
+		annotations:      make(map[string]string, 1),
 	}
 
 	if estgz := os.Getenv("GGCR_EXPERIMENT_ESTARGZ"); estgz == "1" {
 		opts = append([]LayerOption{WithEstargz}, opts...)
 	}
 
-	if compressed {
-		layer.compressedopener = opener
+	if comp == compression.None {
+		layer.uncompressedopener = opener
+	} else {
+		// Default to the compression we were given.
+		layer.compression = comp
 		layer.uncompressedopener = func() (io.ReadCloser, error) {
 			urc, err := opener()
 			if err != nil {
 				return nil, err
 			}
-			return ggzip.UnzipReadCloser(urc)
+			return icompression.Decompress(urc, comp)
 		}
-	} else {
-		layer.uncompressedopener = opener
-		layer.compressedopener = func() (io.ReadCloser, error) {
-			crc, err := opener()
-			if err != nil {
-				return nil, err
-			}
-			return ggzip.ReadCloserLevel(crc, layer.compression), nil
+	}
+	uncompressed := layer.uncompressedopener
+	layer.compressedopener = func() (io.ReadCloser, error) {
+		if layer.compression == comp {
+			return opener()
 		}
+		urc, err := uncompressed()
+		if err != nil {
+			return nil, err
+		}
+		return icompression.Compress(urc, layer.compression, layer.compressionLevel), nil
 	}
 
 	for _, opt := range opts {
 		opt(layer)
 	}
 
-	if layer.digest, layer.size, err = computeDigest(layer.compressedopener); err != nil {
-		return nil, err
+	if layer.mediaType == "" {
+		switch layer.compression {
+		case compression.ZStd:
+			layer.mediaType = types.OCILayerZStd
+		case compression.None:
+			layer.mediaType = types.DockerUncompressedLayer
+		default:
+			layer.mediaType = types.DockerLayer
+		}
 	}
 
-	empty := v1.Hash{}
-	if layer.diffID == empty {
-		if layer.diffID, err = computeDiffID(layer.uncompressedopener); err != nil {
-			return nil, err
+	if err := layer.budget.Do(context.Background(), func(context.Context) (err error) {
+		if layer.digest, layer.size, err = computeDigest(layer.compressedopener); err != nil {
+			return err
+		}
+		if layer.diffID == (v1.Hash{}) {
+			layer.diffID, err = computeDiffID(layer.uncompressedopener)
 		}
+		return err
+	}); err != nil {
+		return nil, err
 	}
 
 	return layer, nil
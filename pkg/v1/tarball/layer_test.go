@@ -23,7 +23,9 @@ import (
 	"testing"
 
 	"github.com/containerd/stargz-snapshotter/estargz"
-	"github.com/google/go-containerregistry/internal/compare"
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/compare"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
@@ -255,6 +257,68 @@ func TestWithMediaType(t *testing.T) {
 	}
 }
 
+func TestWithCompression(t *testing.T) {
+	setupFixtures(t)
+	defer teardownFixtures(t)
+
+	tarLayer, err := LayerFromFile("testdata/content.tar")
+	if err != nil {
+		t.Fatalf("Unable to create layer from tar file: %v", err)
+	}
+
+	for _, tc := range []struct {
+		comp compression.Compression
+		mt   types.MediaType
+	}{{
+		comp: compression.ZStd,
+		mt:   types.OCILayerZStd,
+	}, {
+		comp: compression.None,
+		mt:   types.DockerUncompressedLayer,
+	}, {
+		comp: compression.GZip,
+		mt:   types.DockerLayer,
+	}} {
+		t.Run(string(tc.comp), func(t *testing.T) {
+			// Start from a gzipped tarball to exercise recompression.
+			l, err := LayerFromFile("gzip_content.tgz", WithCompression(tc.comp))
+			if err != nil {
+				t.Fatalf("Unable to create layer: %v", err)
+			}
+			if got, err := l.MediaType(); err != nil {
+				t.Fatal(err)
+			} else if got != tc.mt {
+				t.Errorf("MediaType(); got %v, want %v", got, tc.mt)
+			}
+			if err := validate.Layer(l); err != nil {
+				t.Errorf("validate.Layer: %v", err)
+			}
+			if err := compare.Layers(tarLayer, l); err == nil && tc.comp != compression.GZip {
+				t.Errorf("compare.Layers: expected digests to differ")
+			}
+
+			// The recompressed layer should round-trip through its compressed form.
+			rt, err := LayerFromOpener(l.Compressed, WithCompression(tc.comp))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, pair := range [][2]func() (v1.Hash, error){{l.Digest, rt.Digest}, {l.DiffID, rt.DiffID}} {
+				want, err := pair[0]()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := pair[1]()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("round trip; got %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
 func TestLayerFromReader(t *testing.T) {
 	setupFixtures(t)
 	defer teardownFixtures(t)
@@ -22,12 +22,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"runtime"
 	"sort"
 	"strings"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"golang.org/x/sync/errgroup"
 )
 
 // WriteToFile writes in the compressed format to a tarball, on disk.
@@ -240,12 +242,12 @@ func calculateManifest(refToImage map[name.Reference]v1.Image) (m Manifest, err
 		if err != nil {
 			return nil, err
 		}
+		digests, err := layerDigests(layers)
+		if err != nil {
+			return nil, err
+		}
 		layerFiles := make([]string, len(layers))
-		for i, l := range layers {
-			d, err := l.Digest()
-			if err != nil {
-				return nil, err
-			}
+		for i, d := range digests {
 			// Munge the file name to appease ancient technology.
 			//
 			// tar assumes anything with a colon is a remote tape drive:
@@ -288,6 +290,31 @@ func calculateManifest(refToImage map[name.Reference]v1.Image) (m Manifest, err
 	return m, nil
 }
 
+// layerDigests computes the digests of layers concurrently, at most
+// GOMAXPROCS at a time. Layers that haven't been digested yet, like those that
+// are compressed on the fly, are hashed in parallel rather than one at a time.
+//
+// This doesn't hold a slot of the default concurrency budget, since the
+// layers may need one themselves, e.g. to read a remote layer.
+func layerDigests(layers []v1.Layer) ([]v1.Hash, error) {
+	digests := make([]v1.Hash, len(layers))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var g errgroup.Group
+	for i, l := range layers {
+		i, l := i, l
+		g.Go(func() (err error) {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			digests[i], err = l.Digest()
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
 // CalculateSize calculates the expected complete size of the output tar file
 func CalculateSize(refToImage map[name.Reference]v1.Image) (size int64, err error) {
 	size, _, err = getSizeAndManifest(refToImage)
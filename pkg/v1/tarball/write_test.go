@@ -26,9 +26,9 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/google/go-containerregistry/internal/compare"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/compare"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
@@ -478,6 +478,33 @@ func TestComputeManifest_FailsOnNoRefs(t *testing.T) {
 	}
 }
 
+func TestComputeManifest_ManyLayers(t *testing.T) {
+	// Layers are digested concurrently, but must be listed in order.
+	img, err := random.Image(256, 32)
+	if err != nil {
+		t.Fatalf("Error creating random image: %v", err)
+	}
+	tag, err := name.NewTag("gcr.io/foo/bar:latest", name.StrictValidation)
+	if err != nil {
+		t.Fatalf("Error creating test tag: %v", err)
+	}
+	hashes, err := getLayersHashes(img)
+	if err != nil {
+		t.Fatalf("error getting image hashes: %v", err)
+	}
+
+	m, err := tarball.ComputeManifest(map[name.Reference]v1.Image{tag: img})
+	if err != nil {
+		t.Fatalf("Unexpected error calculating manifest: %v", err)
+	}
+	if len(m) != 1 {
+		t.Fatalf("mismatched manifest lengths: actual %d, expected 1", len(m))
+	}
+	if got, want := strings.Join(m[0].Layers, ","), strings.Join(getLayersFilenames(hashes), ","); got != want {
+		t.Errorf("mismatched layers.\nActual: %s\nExpected: %s", got, want)
+	}
+}
+
 func getLayersHashes(img v1.Image) ([]string, error) {
 	hashes := []string{}
 	layers, err := img.Layers()
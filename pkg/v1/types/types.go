@@ -24,9 +24,12 @@ const (
 	OCIManifestSchema1             MediaType = "application/vnd.oci.image.manifest.v1+json"
 	OCIConfigJSON                  MediaType = "application/vnd.oci.image.config.v1+json"
 	OCILayer                       MediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+	OCILayerZStd                   MediaType = "application/vnd.oci.image.layer.v1.tar+zstd"
 	OCIRestrictedLayer             MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip"
+	OCIRestrictedLayerZStd         MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+zstd"
 	OCIUncompressedLayer           MediaType = "application/vnd.oci.image.layer.v1.tar"
 	OCIUncompressedRestrictedLayer MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar"
+	OCIEmptyJSON                   MediaType = "application/vnd.oci.empty.v1+json"
 
 	DockerManifestSchema1       MediaType = "application/vnd.docker.distribution.manifest.v1+json"
 	DockerManifestSchema1Signed MediaType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
@@ -46,7 +49,7 @@ const (
 // https://github.com/opencontainers/image-spec/blob/master/layer.md#non-distributable-layers
 func (m MediaType) IsDistributable() bool {
 	switch m {
-	case DockerForeignLayer, OCIRestrictedLayer, OCIUncompressedRestrictedLayer:
+	case DockerForeignLayer, OCIRestrictedLayer, OCIRestrictedLayerZStd, OCIUncompressedRestrictedLayer:
 		return false
 	}
 	return true
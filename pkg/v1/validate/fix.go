@@ -0,0 +1,210 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Fix returns a copy of img with the inconsistencies that can be recovered
+// from corrected:
+//
+//   - layer and config descriptors whose digest or size don't match the
+//     contents they describe;
+//   - missing media types on the manifest and its descriptors;
+//   - config diff_ids that don't match the layers, e.g. after the layers
+//     were edited without updating the config.
+//
+// Fix reads and decompresses every layer. If there was nothing to fix, img is
+// returned as is. Problems that can't be fixed, like corrupt layers, are
+// returned as errors.
+func Fix(img v1.Image) (v1.Image, error) {
+	orig, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	m := orig.DeepCopy()
+	rcf, err := img.RawConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := fixLayers(img, m)
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) != len(m.Layers) {
+		return nil, fmt.Errorf("image has %d layers, but its manifest has %d", len(layers), len(m.Layers))
+	}
+
+	if m.MediaType == "" {
+		if mt, err := img.MediaType(); err == nil && mt != "" {
+			m.MediaType = mt
+		} else if m.Config.MediaType == types.DockerConfigJSON {
+			m.MediaType = types.DockerManifestSchema2
+		} else {
+			m.MediaType = types.OCIManifestSchema1
+		}
+	}
+	docker := m.MediaType == types.DockerManifestSchema2
+
+	fixed := make([]*fixedLayer, 0, len(layers))
+	diffids := make([]v1.Hash, 0, len(layers))
+	for i, layer := range layers {
+		cl, err := computeLayer(context.Background(), layer)
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %d: %w", i, err)
+		}
+		desc := m.Layers[i]
+		desc.Digest = cl.digest
+		desc.Size = cl.size
+		if desc.MediaType == "" {
+			if mt, err := layer.MediaType(); err == nil && mt != "" {
+				desc.MediaType = mt
+			} else if docker {
+				desc.MediaType = types.DockerLayer
+			} else {
+				desc.MediaType = types.OCILayer
+			}
+		}
+		m.Layers[i] = desc
+		fixed = append(fixed, &fixedLayer{Layer: layer, desc: desc, diffID: cl.diffid})
+		diffids = append(diffids, cl.diffid)
+	}
+
+	if cf.RootFS.Type != "layers" || !cmp.Equal(cf.RootFS.DiffIDs, diffids) {
+		// Only rewrite the config if we have to, since that can drop fields
+		// that ConfigFile doesn't know about.
+		cf = cf.DeepCopy()
+		cf.RootFS.Type = "layers"
+		cf.RootFS.DiffIDs = diffids
+		if rcf, err = json.Marshal(cf); err != nil {
+			return nil, err
+		}
+	}
+	h, size, err := v1.SHA256(bytes.NewReader(rcf))
+	if err != nil {
+		return nil, err
+	}
+	m.Config.Digest = h
+	m.Config.Size = size
+	if m.Config.MediaType == "" {
+		if docker {
+			m.Config.MediaType = types.DockerConfigJSON
+		} else {
+			m.Config.MediaType = types.OCIConfigJSON
+		}
+	}
+
+	if cmp.Equal(orig, m) {
+		return img, nil
+	}
+	rm, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return partial.CompressedToImage(&fixedImage{
+		manifest:    m,
+		rawManifest: rm,
+		rawConfig:   rcf,
+		layers:      fixed,
+	})
+}
+
+// fixLayers returns img's layers, even if its config's diff_ids are wrong.
+func fixLayers(img v1.Image, m *v1.Manifest) ([]v1.Layer, error) {
+	layers, err := img.Layers()
+	if err == nil {
+		return layers, nil
+	}
+	// Some images look their layers up by diffid, so try by digest instead.
+	layers = make([]v1.Layer, 0, len(m.Layers))
+	for _, desc := range m.Layers {
+		layer, lerr := img.LayerByDigest(desc.Digest)
+		if lerr != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// fixedImage is an image with a corrected manifest and config.
+type fixedImage struct {
+	manifest    *v1.Manifest
+	rawManifest []byte
+	rawConfig   []byte
+	layers      []*fixedLayer
+}
+
+var _ partial.CompressedImageCore = (*fixedImage)(nil)
+
+func (i *fixedImage) RawConfigFile() ([]byte, error) {
+	return i.rawConfig, nil
+}
+
+func (i *fixedImage) MediaType() (types.MediaType, error) {
+	return i.manifest.MediaType, nil
+}
+
+func (i *fixedImage) RawManifest() ([]byte, error) {
+	return i.rawManifest, nil
+}
+
+func (i *fixedImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	if h == i.manifest.Config.Digest {
+		return partial.ConfigLayer(i)
+	}
+	for _, l := range i.layers {
+		if l.desc.Digest == h {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("layer %s not found", h)
+}
+
+// fixedLayer is a layer with a corrected descriptor and diffid.
+type fixedLayer struct {
+	v1.Layer
+	desc   v1.Descriptor
+	diffID v1.Hash
+}
+
+func (l *fixedLayer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+func (l *fixedLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *fixedLayer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+func (l *fixedLayer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
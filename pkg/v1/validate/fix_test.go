@@ -0,0 +1,110 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// noMediaType is a layer without a media type.
+type noMediaType struct {
+	v1.Layer
+}
+
+func (noMediaType) MediaType() (types.MediaType, error) {
+	return "", nil
+}
+
+func TestFix(t *testing.T) {
+	l, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staleDiffIDs := func() v1.Image {
+		cf, err := good.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf = cf.DeepCopy()
+		cf.RootFS.DiffIDs = []v1.Hash{bogus}
+		img, err := mutate.ConfigFile(good, cf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	for _, tc := range []struct {
+		desc string
+		img  v1.Image
+	}{{
+		desc: "wrong size",
+		img:  appendLayers(t, badSize{l}),
+	}, {
+		desc: "missing media type",
+		img:  appendLayers(t, noMediaType{l}),
+	}, {
+		desc: "stale diff_ids",
+		img:  staleDiffIDs(),
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			fixed, err := validate.Fix(tc.img)
+			if err != nil {
+				t.Fatalf("Fix() = %v", err)
+			}
+			if fixed == tc.img {
+				t.Error("Fix() returned the image unchanged")
+			}
+			if err := validate.Image(fixed); err != nil {
+				t.Errorf("validate.Image(Fix()) = %v", err)
+			}
+			m, err := fixed.Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, desc := range m.Layers {
+				if desc.MediaType == "" {
+					t.Errorf("layer %s has no media type", desc.Digest)
+				}
+			}
+		})
+	}
+
+	// Images with nothing to fix come back as they were.
+	if fixed, err := validate.Fix(good); err != nil || fixed != good {
+		t.Errorf("Fix(good) = %v, %v; want it unchanged", fixed, err)
+	}
+}
+
+func appendLayers(t *testing.T, layers ...v1.Layer) v1.Image {
+	t.Helper()
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
@@ -19,7 +19,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -27,91 +26,96 @@ import (
 )
 
 // Image validates that img does not violate any invariants of the image format.
+// If it does, the error is a *Report of everything that's wrong with it.
 func Image(img v1.Image, opt ...Option) error {
-	errs := []string{}
-	if err := validateLayers(img, opt...); err != nil {
-		errs = append(errs, fmt.Sprintf("validating layers: %v", err))
-	}
-
-	if err := validateConfig(img); err != nil {
-		errs = append(errs, fmt.Sprintf("validating config: %v", err))
-	}
+	return ImageReport(img, opt...).Err()
+}
 
-	if err := validateManifest(img); err != nil {
-		errs = append(errs, fmt.Sprintf("validating manifest: %v", err))
-	}
+// ImageReport validates img like Image, and returns everything it found,
+// including warnings.
+func ImageReport(img v1.Image, opt ...Option) *Report {
+	o := makeOptions(opt...)
+	r := &Report{ignore: o.ignore}
+	validateImage(r, "", img, o)
+	return r
+}
 
-	if len(errs) != 0 {
-		return errors.New(strings.Join(errs, "\n\n"))
+func validateImage(r *Report, loc string, img v1.Image, o options) {
+	if o.light {
+		validateBlobs(r, loc, img, o)
+		validateManifest(r, loc, img)
+	} else {
+		validateLayers(r, loc, img, o)
+		validateConfig(r, at(loc, "config"), img)
+		validateManifest(r, loc, img)
 	}
-	return nil
+	validateRules(r, loc, img, o)
 }
 
-func validateConfig(img v1.Image) error {
+func validateConfig(r *Report, loc string, img v1.Image) {
 	cn, err := img.ConfigName()
 	if err != nil {
-		return err
+		r.unreadable(loc, v1.Hash{}, err)
+		return
 	}
 
 	rc, err := img.RawConfigFile()
 	if err != nil {
-		return err
+		r.unreadable(loc, cn, err)
+		return
 	}
 
 	hash, size, err := v1.SHA256(bytes.NewReader(rc))
 	if err != nil {
-		return err
+		r.unreadable(loc, cn, err)
+		return
 	}
 
 	m, err := img.Manifest()
 	if err != nil {
-		return err
+		r.unreadable(loc, cn, err)
+		return
 	}
 
 	cf, err := img.ConfigFile()
 	if err != nil {
-		return err
+		r.unreadable(loc, cn, err)
+		return
 	}
 
 	pcf, err := v1.ParseConfigFile(bytes.NewReader(rc))
 	if err != nil {
-		return err
+		r.unreadable(loc, cn, err)
+		return
 	}
 
-	errs := []string{}
 	if cn != hash {
-		errs = append(errs, fmt.Sprintf("mismatched config digest: ConfigName()=%s, SHA256(RawConfigFile())=%s", cn, hash))
+		r.errorf(KindDigestMismatch, loc, cn, "mismatched config digest: ConfigName()=%s, SHA256(RawConfigFile())=%s", cn, hash)
 	}
 
 	if want, got := m.Config.Size, size; want != got {
-		errs = append(errs, fmt.Sprintf("mismatched config size: Manifest.Config.Size()=%d, len(RawConfigFile())=%d", want, got))
+		r.errorf(KindSizeMismatch, loc, cn, "mismatched config size: Manifest.Config.Size()=%d, len(RawConfigFile())=%d", want, got)
 	}
 
 	if diff := cmp.Diff(pcf, cf); diff != "" {
-		errs = append(errs, fmt.Sprintf("mismatched config content: (-ParseConfigFile(RawConfigFile()) +ConfigFile()) %s", diff))
+		r.errorf(KindContentMismatch, loc, cn, "mismatched config content: (-ParseConfigFile(RawConfigFile()) +ConfigFile()) %s", diff)
 	}
 
 	if cf.RootFS.Type != "layers" {
-		errs = append(errs, fmt.Sprintf("invalid ConfigFile.RootFS.Type: %q != %q", cf.RootFS.Type, "layers"))
+		r.errorf(KindInvalidConfig, loc, cn, "invalid ConfigFile.RootFS.Type: %q != %q", cf.RootFS.Type, "layers")
 	}
-
-	if len(errs) != 0 {
-		return errors.New(strings.Join(errs, "\n"))
-	}
-
-	return nil
 }
 
-func validateLayers(img v1.Image, opt ...Option) error {
-	o := makeOptions(opt...)
-
+func validateLayers(r *Report, loc string, img v1.Image, o options) {
 	layers, err := img.Layers()
 	if err != nil {
-		return err
+		r.unreadable(at(loc, "layers"), v1.Hash{}, err)
+		return
 	}
 
 	if o.fast {
-		return layersExist(layers)
+		layersExist(r, loc, layers, o)
+		return
 	}
 
 	digests := []v1.Hash{}
@@ -119,19 +123,27 @@ func validateLayers(img v1.Image, opt ...Option) error {
 	udiffids := []v1.Hash{}
 	sizes := []int64{}
 	for i, layer := range layers {
-		cl, err := computeLayer(layer)
+		lloc := at(loc, fmt.Sprintf("layers[%d]", i))
+		digest, _ := layer.Digest()
+		if !o.checkpoint(r, Progress{Location: lloc, Digest: digest, Layer: i, Layers: len(layers)}) {
+			return
+		}
+		cl, err := compute(layer, o)
 		if errors.Is(err, io.ErrUnexpectedEOF) {
 			// Errored while reading tar content of layer because a header or
 			// content section was not the correct length. This is most likely
 			// due to an incomplete download or otherwise interrupted process.
 			m, err := img.Manifest()
 			if err != nil {
-				return fmt.Errorf("undersized layer[%d] content", i)
+				r.errorf(KindTruncated, lloc, v1.Hash{}, "undersized layer[%d] content", i)
+				return
 			}
-			return fmt.Errorf("undersized layer[%d] content: Manifest.Layers[%d].Size=%d", i, i, m.Layers[i].Size)
+			r.errorf(KindTruncated, lloc, m.Layers[i].Digest, "undersized layer[%d] content: Manifest.Layers[%d].Size=%d", i, i, m.Layers[i].Size)
+			return
 		}
 		if err != nil {
-			return err
+			layerError(r, lloc, digest, err)
+			return
 		}
 		// Compute all of these first before we call Config() and Manifest() to allow
 		// for lazy access e.g. for stream.Layer.
@@ -143,146 +155,155 @@ func validateLayers(img v1.Image, opt ...Option) error {
 
 	cf, err := img.ConfigFile()
 	if err != nil {
-		return err
+		r.unreadable(at(loc, "layers"), v1.Hash{}, err)
+		return
 	}
 
 	m, err := img.Manifest()
 	if err != nil {
-		return err
+		r.unreadable(at(loc, "layers"), v1.Hash{}, err)
+		return
 	}
 
-	errs := []string{}
 	for i, layer := range layers {
+		lloc := at(loc, fmt.Sprintf("layers[%d]", i))
 		digest, err := layer.Digest()
 		if err != nil {
-			return err
+			r.unreadable(lloc, digests[i], err)
+			return
 		}
 		diffid, err := layer.DiffID()
 		if err != nil {
-			return err
+			r.unreadable(lloc, digest, err)
+			return
 		}
 		size, err := layer.Size()
 		if err != nil {
-			return err
+			r.unreadable(lloc, digest, err)
+			return
 		}
 		mediaType, err := layer.MediaType()
 		if err != nil {
-			return err
+			r.unreadable(lloc, digest, err)
+			return
 		}
 
 		if _, err := img.LayerByDigest(digest); err != nil {
-			return err
+			r.unreadable(lloc, digest, err)
+			return
 		}
 
 		if _, err := img.LayerByDiffID(diffid); err != nil {
-			return err
+			r.unreadable(lloc, digest, err)
+			return
 		}
 
 		if digest != digests[i] {
-			errs = append(errs, fmt.Sprintf("mismatched layer[%d] digest: Digest()=%s, SHA256(Compressed())=%s", i, digest, digests[i]))
+			r.errorf(KindDigestMismatch, lloc, digest, "mismatched layer[%d] digest: Digest()=%s, SHA256(Compressed())=%s", i, digest, digests[i])
 		}
 
 		if m.Layers[i].Digest != digests[i] {
-			errs = append(errs, fmt.Sprintf("mismatched layer[%d] digest: Manifest.Layers[%d].Digest=%s, SHA256(Compressed())=%s", i, i, m.Layers[i].Digest, digests[i]))
+			r.errorf(KindDigestMismatch, lloc, digest, "mismatched layer[%d] digest: Manifest.Layers[%d].Digest=%s, SHA256(Compressed())=%s", i, i, m.Layers[i].Digest, digests[i])
 		}
 
-		if diffid != diffids[i] {
-			errs = append(errs, fmt.Sprintf("mismatched layer[%d] diffid: DiffID()=%s, SHA256(Gunzip(Compressed()))=%s", i, diffid, diffids[i]))
-		}
+		if !o.compressedOnly {
+			if diffid != diffids[i] {
+				r.errorf(KindDiffIDMismatch, lloc, digest, "mismatched layer[%d] diffid: DiffID()=%s, SHA256(Gunzip(Compressed()))=%s", i, diffid, diffids[i])
+			}
 
-		if diffid != udiffids[i] {
-			errs = append(errs, fmt.Sprintf("mismatched layer[%d] diffid: DiffID()=%s, SHA256(Uncompressed())=%s", i, diffid, udiffids[i]))
-		}
+			if diffid != udiffids[i] {
+				r.errorf(KindDiffIDMismatch, lloc, digest, "mismatched layer[%d] diffid: DiffID()=%s, SHA256(Uncompressed())=%s", i, diffid, udiffids[i])
+			}
 
-		if cf.RootFS.DiffIDs[i] != diffids[i] {
-			errs = append(errs, fmt.Sprintf("mismatched layer[%d] diffid: ConfigFile.RootFS.DiffIDs[%d]=%s, SHA256(Gunzip(Compressed()))=%s", i, i, cf.RootFS.DiffIDs[i], diffids[i]))
+			if cf.RootFS.DiffIDs[i] != diffids[i] {
+				r.errorf(KindDiffIDMismatch, lloc, digest, "mismatched layer[%d] diffid: ConfigFile.RootFS.DiffIDs[%d]=%s, SHA256(Gunzip(Compressed()))=%s", i, i, cf.RootFS.DiffIDs[i], diffids[i])
+			}
+		} else if cf.RootFS.DiffIDs[i] != diffid {
+			r.errorf(KindDiffIDMismatch, lloc, digest, "mismatched layer[%d] diffid: ConfigFile.RootFS.DiffIDs[%d]=%s, DiffID()=%s", i, i, cf.RootFS.DiffIDs[i], diffid)
 		}
 
 		if size != sizes[i] {
-			errs = append(errs, fmt.Sprintf("mismatched layer[%d] size: Size()=%d, len(Compressed())=%d", i, size, sizes[i]))
+			r.errorf(KindSizeMismatch, lloc, digest, "mismatched layer[%d] size: Size()=%d, len(Compressed())=%d", i, size, sizes[i])
 		}
 
 		if m.Layers[i].Size != sizes[i] {
-			errs = append(errs, fmt.Sprintf("mismatched layer[%d] size: Manifest.Layers[%d].Size=%d, len(Compressed())=%d", i, i, m.Layers[i].Size, sizes[i]))
+			r.errorf(KindSizeMismatch, lloc, digest, "mismatched layer[%d] size: Manifest.Layers[%d].Size=%d, len(Compressed())=%d", i, i, m.Layers[i].Size, sizes[i])
 		}
 
 		if m.Layers[i].MediaType != mediaType {
-			errs = append(errs, fmt.Sprintf("mismatched layer[%d] mediaType: Manifest.Layers[%d].MediaType=%s, layer.MediaType()=%s", i, i, m.Layers[i].MediaType, mediaType))
+			r.errorf(KindMediaTypeMismatch, lloc, digest, "mismatched layer[%d] mediaType: Manifest.Layers[%d].MediaType=%s, layer.MediaType()=%s", i, i, m.Layers[i].MediaType, mediaType)
 		}
 	}
-	if len(errs) != 0 {
-		return errors.New(strings.Join(errs, "\n"))
-	}
-
-	return nil
 }
 
-func validateManifest(img v1.Image) error {
+func validateManifest(r *Report, loc string, img v1.Image) {
+	mloc := at(loc, "manifest")
 	digest, err := img.Digest()
 	if err != nil {
-		return err
+		r.unreadable(mloc, v1.Hash{}, err)
+		return
 	}
 
 	size, err := img.Size()
 	if err != nil {
-		return err
+		r.unreadable(mloc, digest, err)
+		return
 	}
 
 	rm, err := img.RawManifest()
 	if err != nil {
-		return err
+		r.unreadable(mloc, digest, err)
+		return
 	}
 
 	hash, _, err := v1.SHA256(bytes.NewReader(rm))
 	if err != nil {
-		return err
+		r.unreadable(mloc, digest, err)
+		return
 	}
 
 	m, err := img.Manifest()
 	if err != nil {
-		return err
+		r.unreadable(mloc, digest, err)
+		return
 	}
 
 	pm, err := v1.ParseManifest(bytes.NewReader(rm))
 	if err != nil {
-		return err
+		r.unreadable(mloc, digest, err)
+		return
 	}
 
-	errs := []string{}
 	if digest != hash {
-		errs = append(errs, fmt.Sprintf("mismatched manifest digest: Digest()=%s, SHA256(RawManifest())=%s", digest, hash))
+		r.errorf(KindDigestMismatch, mloc, digest, "mismatched manifest digest: Digest()=%s, SHA256(RawManifest())=%s", digest, hash)
 	}
 
 	if diff := cmp.Diff(pm, m); diff != "" {
-		errs = append(errs, fmt.Sprintf("mismatched manifest content: (-ParseManifest(RawManifest()) +Manifest()) %s", diff))
+		r.errorf(KindContentMismatch, mloc, digest, "mismatched manifest content: (-ParseManifest(RawManifest()) +Manifest()) %s", diff)
 	}
 
 	if size != int64(len(rm)) {
-		errs = append(errs, fmt.Sprintf("mismatched manifest size: Size()=%d, len(RawManifest())=%d", size, len(rm)))
-	}
-
-	if len(errs) != 0 {
-		return errors.New(strings.Join(errs, "\n"))
+		r.errorf(KindSizeMismatch, mloc, digest, "mismatched manifest size: Size()=%d, len(RawManifest())=%d", size, len(rm))
 	}
-
-	return nil
 }
 
-func layersExist(layers []v1.Layer) error {
-	errs := []string{}
-	for _, layer := range layers {
-		ok, err := partial.Exists(layer)
-		if err != nil {
-			errs = append(errs, err.Error())
-		}
-		if !ok {
-			errs = append(errs, "layer does not exist")
+func layersExist(r *Report, loc string, layers []v1.Layer, o options) {
+	for i, layer := range layers {
+		lloc := at(loc, fmt.Sprintf("layers[%d]", i))
+		digest, _ := layer.Digest()
+		if !o.checkpoint(r, Progress{Location: lloc, Digest: digest, Layer: i, Layers: len(layers)}) {
+			return
 		}
+		layerExists(r, lloc, digest, layer)
 	}
+}
 
-	if len(errs) != 0 {
-		return errors.New(strings.Join(errs, "\n"))
+func layerExists(r *Report, loc string, digest v1.Hash, layer v1.Layer) {
+	ok, err := partial.Exists(layer)
+	if err != nil {
+		r.unreadable(loc, digest, err)
+	}
+	if !ok {
+		r.errorf(KindMissing, loc, digest, "layer does not exist")
 	}
-
-	return nil
 }
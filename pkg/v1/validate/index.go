@@ -16,9 +16,7 @@ package validate
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/logs"
@@ -27,149 +25,169 @@ import (
 )
 
 // Index validates that idx does not violate any invariants of the index format.
+// If it does, the error is a *Report of everything that's wrong with it,
+// including with its children.
 func Index(idx v1.ImageIndex, opt ...Option) error {
-	errs := []string{}
-
-	if err := validateChildren(idx, opt...); err != nil {
-		errs = append(errs, fmt.Sprintf("validating children: %v", err))
+	r := IndexReport(idx, opt...)
+	for _, f := range r.Warnings() {
+		logs.Warn.Print(f)
 	}
+	return r.Err()
+}
 
-	if err := validateIndexManifest(idx); err != nil {
-		errs = append(errs, fmt.Sprintf("validating index manifest: %v", err))
-	}
+// IndexReport validates idx like Index, and returns everything it found,
+// including warnings.
+func IndexReport(idx v1.ImageIndex, opt ...Option) *Report {
+	o := makeOptions(opt...)
+	r := &Report{ignore: o.ignore}
+	validateIndex(r, "", idx, o)
+	return r
+}
 
-	if len(errs) != 0 {
-		return errors.New(strings.Join(errs, "\n\n"))
-	}
-	return nil
+func validateIndex(r *Report, loc string, idx v1.ImageIndex, o options) {
+	validateChildren(r, loc, idx, o)
+	validateIndexManifest(r, at(loc, "manifest"), idx)
 }
 
 type withLayer interface {
 	Layer(v1.Hash) (v1.Layer, error)
 }
 
-func validateChildren(idx v1.ImageIndex, opt ...Option) error {
+func validateChildren(r *Report, loc string, idx v1.ImageIndex, o options) {
 	manifest, err := idx.IndexManifest()
 	if err != nil {
-		return err
+		r.unreadable(at(loc, "manifests"), v1.Hash{}, err)
+		return
 	}
 
-	errs := []string{}
 	for i, desc := range manifest.Manifests {
+		cloc := at(loc, fmt.Sprintf("manifests[%d]", i))
+		if !o.proceed(r, cloc) {
+			return
+		}
 		switch desc.MediaType {
 		case types.OCIImageIndex, types.DockerManifestList:
 			idx, err := idx.ImageIndex(desc.Digest)
 			if err != nil {
-				return err
-			}
-			if err := Index(idx, opt...); err != nil {
-				errs = append(errs, fmt.Sprintf("failed to validate index Manifests[%d](%s): %v", i, desc.Digest, err))
-			}
-			if err := validateMediaType(idx, desc.MediaType); err != nil {
-				errs = append(errs, fmt.Sprintf("failed to validate index MediaType[%d](%s): %v", i, desc.Digest, err))
+				r.unreadable(cloc, desc.Digest, err)
+				return
 			}
+			validateIndex(r, cloc, idx, o)
+			validateMediaType(r, cloc, desc.Digest, idx, desc.MediaType)
 		case types.OCIManifestSchema1, types.DockerManifestSchema2:
 			img, err := idx.Image(desc.Digest)
 			if err != nil {
-				return err
+				r.unreadable(cloc, desc.Digest, err)
+				return
 			}
-			if err := Image(img, opt...); err != nil {
-				errs = append(errs, fmt.Sprintf("failed to validate image Manifests[%d](%s): %v", i, desc.Digest, err))
-			}
-			if err := validateMediaType(img, desc.MediaType); err != nil {
-				errs = append(errs, fmt.Sprintf("failed to validate image MediaType[%d](%s): %v", i, desc.Digest, err))
+			validateImage(r, cloc, img, o)
+			validateMediaType(r, cloc, desc.Digest, img, desc.MediaType)
+			if !o.light {
+				// Comparing platforms means fetching the config.
+				validateChildPlatform(r, cloc, desc, img)
 			}
 		default:
 			// Workaround for #819.
 			if wl, ok := idx.(withLayer); ok {
 				layer, err := wl.Layer(desc.Digest)
 				if err != nil {
-					return fmt.Errorf("failed to get layer Manifests[%d]: %w", i, err)
+					r.unreadable(cloc, desc.Digest, fmt.Errorf("failed to get layer: %w", err))
+					return
 				}
-				if err := Layer(layer, opt...); err != nil {
-					lerr := fmt.Sprintf("failed to validate layer Manifests[%d](%s): %v", i, desc.Digest, err)
-					if desc.MediaType.IsDistributable() {
-						errs = append(errs, lerr)
+				check := func(r *Report) {
+					if o.light {
+						validateStat(r, cloc, desc, layer)
 					} else {
-						logs.Warn.Printf("nondistributable layer failure: %v", lerr)
+						validateLayer(r, cloc, layer, o)
+					}
+				}
+				if !o.checkpoint(r, Progress{Location: cloc, Digest: desc.Digest, Layers: 1}) {
+					return
+				}
+				if desc.MediaType.IsDistributable() {
+					check(r)
+				} else {
+					// Failures of non-distributable layers are only warnings.
+					lr := &Report{ignore: r.ignore}
+					check(lr)
+					for _, f := range lr.Findings {
+						if f.Kind == KindCanceled {
+							r.cancel(f.Location, f.Digest, lr.canceled)
+							continue
+						}
+						f.Severity = SeverityWarning
+						r.add(f)
 					}
 				}
 			} else {
-				logs.Warn.Printf("Unexpected manifest: %s", desc.MediaType)
+				r.warnf(KindUnexpectedManifest, cloc, desc.Digest, "unexpected manifest: %s", desc.MediaType)
 			}
 		}
 	}
-
-	if len(errs) != 0 {
-		return errors.New(strings.Join(errs, "\n"))
-	}
-
-	return nil
+	validatePlatforms(r, loc, manifest)
 }
 
 type withMediaType interface {
 	MediaType() (types.MediaType, error)
 }
 
-func validateMediaType(i withMediaType, want types.MediaType) error {
+func validateMediaType(r *Report, loc string, digest v1.Hash, i withMediaType, want types.MediaType) {
 	got, err := i.MediaType()
 	if err != nil {
-		return err
+		r.unreadable(loc, digest, err)
+		return
 	}
 	if want != got {
-		return fmt.Errorf("mismatched mediaType: MediaType() = %v != %v", got, want)
+		r.errorf(KindMediaTypeMismatch, loc, digest, "mismatched mediaType: MediaType() = %v != %v", got, want)
 	}
-
-	return nil
 }
 
-func validateIndexManifest(idx v1.ImageIndex) error {
+func validateIndexManifest(r *Report, loc string, idx v1.ImageIndex) {
 	digest, err := idx.Digest()
 	if err != nil {
-		return err
+		r.unreadable(loc, v1.Hash{}, err)
+		return
 	}
 
 	size, err := idx.Size()
 	if err != nil {
-		return err
+		r.unreadable(loc, digest, err)
+		return
 	}
 
 	rm, err := idx.RawManifest()
 	if err != nil {
-		return err
+		r.unreadable(loc, digest, err)
+		return
 	}
 
 	hash, _, err := v1.SHA256(bytes.NewReader(rm))
 	if err != nil {
-		return err
+		r.unreadable(loc, digest, err)
+		return
 	}
 
 	m, err := idx.IndexManifest()
 	if err != nil {
-		return err
+		r.unreadable(loc, digest, err)
+		return
 	}
 
 	pm, err := v1.ParseIndexManifest(bytes.NewReader(rm))
 	if err != nil {
-		return err
+		r.unreadable(loc, digest, err)
+		return
 	}
 
-	errs := []string{}
 	if digest != hash {
-		errs = append(errs, fmt.Sprintf("mismatched manifest digest: Digest()=%s, SHA256(RawManifest())=%s", digest, hash))
+		r.errorf(KindDigestMismatch, loc, digest, "mismatched manifest digest: Digest()=%s, SHA256(RawManifest())=%s", digest, hash)
 	}
 
 	if diff := cmp.Diff(pm, m); diff != "" {
-		errs = append(errs, fmt.Sprintf("mismatched manifest content: (-ParseIndexManifest(RawManifest()) +Manifest()) %s", diff))
+		r.errorf(KindContentMismatch, loc, digest, "mismatched manifest content: (-ParseIndexManifest(RawManifest()) +Manifest()) %s", diff)
 	}
 
 	if size != int64(len(rm)) {
-		errs = append(errs, fmt.Sprintf("mismatched manifest size: Size()=%d, len(RawManifest())=%d", size, len(rm)))
-	}
-
-	if len(errs) != 0 {
-		return errors.New(strings.Join(errs, "\n"))
+		r.errorf(KindSizeMismatch, loc, digest, "mismatched manifest size: Size()=%d, len(RawManifest())=%d", size, len(rm))
 	}
-
-	return nil
 }
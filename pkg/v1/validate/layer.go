@@ -16,75 +16,111 @@ package validate
 
 import (
 	"archive/tar"
-	"compress/gzip"
-	"crypto/sha256"
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"strings"
 
+	"github.com/google/go-containerregistry/internal/compression"
+	"github.com/google/go-containerregistry/internal/sha256"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 )
 
 // Layer validates that the values return by its methods are consistent with the
-// contents returned by Compressed and Uncompressed.
+// contents returned by Compressed and Uncompressed. If they aren't, the error
+// is a *Report of everything that's wrong with it.
 func Layer(layer v1.Layer, opt ...Option) error {
+	return LayerReport(layer, opt...).Err()
+}
+
+// LayerReport validates layer like Layer, and returns everything it found.
+func LayerReport(layer v1.Layer, opt ...Option) *Report {
 	o := makeOptions(opt...)
-	if o.fast {
-		ok, err := partial.Exists(layer)
+	r := &Report{ignore: o.ignore}
+	digest, _ := layer.Digest()
+	if o.checkpoint(r, Progress{Digest: digest, Layers: 1}) {
+		validateLayer(r, "", layer, o)
+	}
+	return r
+}
+
+func validateLayer(r *Report, loc string, layer v1.Layer, o options) {
+	if o.light {
+		desc, err := partial.Descriptor(layer)
 		if err != nil {
-			return err
-		}
-		if !ok {
-			return fmt.Errorf("layer does not exist")
+			r.unreadable(loc, v1.Hash{}, err)
+			return
 		}
-		return nil
+		validateStat(r, loc, *desc, layer)
+		return
 	}
 
-	cl, err := computeLayer(layer)
-	if err != nil {
-		return err
+	if o.fast {
+		digest, _ := layer.Digest()
+		layerExists(r, loc, digest, layer)
+		return
 	}
 
-	errs := []string{}
+	// Compute these first to allow for lazy access, e.g. for stream.Layer.
+	cl, err := compute(layer, o)
+	if err != nil {
+		digest, _ := layer.Digest()
+		layerError(r, loc, digest, err)
+		return
+	}
 
 	digest, err := layer.Digest()
 	if err != nil {
-		return err
+		r.unreadable(loc, v1.Hash{}, err)
+		return
 	}
 	diffid, err := layer.DiffID()
 	if err != nil {
-		return err
+		r.unreadable(loc, digest, err)
+		return
 	}
 	size, err := layer.Size()
 	if err != nil {
-		return err
+		r.unreadable(loc, digest, err)
+		return
 	}
 
 	if digest != cl.digest {
-		errs = append(errs, fmt.Sprintf("mismatched digest: Digest()=%s, SHA256(Compressed())=%s", digest, cl.digest))
+		r.errorf(KindDigestMismatch, loc, digest, "mismatched digest: Digest()=%s, SHA256(Compressed())=%s", digest, cl.digest)
 	}
 
-	if diffid != cl.diffid {
-		errs = append(errs, fmt.Sprintf("mismatched diffid: DiffID()=%s, SHA256(Gunzip(Compressed()))=%s", diffid, cl.diffid))
-	}
+	if !o.compressedOnly {
+		if diffid != cl.diffid {
+			r.errorf(KindDiffIDMismatch, loc, digest, "mismatched diffid: DiffID()=%s, SHA256(Gunzip(Compressed()))=%s", diffid, cl.diffid)
+		}
 
-	if diffid != cl.uncompressedDiffid {
-		errs = append(errs, fmt.Sprintf("mismatched diffid: DiffID()=%s, SHA256(Uncompressed())=%s", diffid, cl.uncompressedDiffid))
+		if diffid != cl.uncompressedDiffid {
+			r.errorf(KindDiffIDMismatch, loc, digest, "mismatched diffid: DiffID()=%s, SHA256(Uncompressed())=%s", diffid, cl.uncompressedDiffid)
+		}
 	}
 
 	if size != cl.size {
-		errs = append(errs, fmt.Sprintf("mismatched size: Size()=%d, len(Compressed())=%d", size, cl.size))
+		r.errorf(KindSizeMismatch, loc, digest, "mismatched size: Size()=%d, len(Compressed())=%d", size, cl.size)
 	}
+}
 
-	if len(errs) != 0 {
-		return errors.New(strings.Join(errs, "\n"))
-	}
+// errDuplicatePath is returned by computeLayer for layers that contain the
+// same path more than once.
+var errDuplicatePath = errors.New("duplicate file path")
 
-	return nil
+// layerError records an error from computeLayer.
+func layerError(r *Report, loc string, digest v1.Hash, err error) {
+	switch {
+	case errors.Is(err, errDuplicatePath):
+		r.errorf(KindDuplicateFile, loc, digest, "%v", err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		r.errorf(KindTruncated, loc, digest, "%v", err)
+	default:
+		r.unreadable(loc, digest, err)
+	}
 }
 
 type computedLayer struct {
@@ -98,11 +134,37 @@ type computedLayer struct {
 	uncompressedSize   int64
 }
 
-func computeLayer(layer v1.Layer) (*computedLayer, error) {
-	compressed, err := layer.Compressed()
+// compute computes what the options ask to validate about layer.
+func compute(layer v1.Layer, o options) (*computedLayer, error) {
+	if o.compressedOnly {
+		return computeCompressed(o.ctx, layer)
+	}
+	return computeLayer(o.ctx, layer)
+}
+
+// computeCompressed only computes the digest and size of layer.
+func computeCompressed(ctx context.Context, layer v1.Layer) (*computedLayer, error) {
+	rc, err := layer.Compressed()
 	if err != nil {
 		return nil, err
 	}
+	digest, size, err := v1.SHA256(&contextReader{ctx, rc})
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	if err := rc.Close(); err != nil {
+		return nil, err
+	}
+	return &computedLayer{digest: digest, size: size}, nil
+}
+
+func computeLayer(ctx context.Context, layer v1.Layer) (*computedLayer, error) {
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	compressed := &contextReader{ctx, rc}
 
 	// Keep track of compressed digest.
 	digester := sha256.New()
@@ -128,8 +190,12 @@ func computeLayer(layer v1.Layer) (*computedLayer, error) {
 		pw.CloseWithError(compressed.Close())
 	}()
 
-	// Read the bytes through gzip.Reader to compute the DiffID.
-	uncompressed, err := gzip.NewReader(pr)
+	// Read the bytes through the decompressor to compute the DiffID.
+	comp, peeked, err := compression.Peek(pr)
+	if err != nil {
+		return nil, err
+	}
+	uncompressed, err := compression.Decompress(ioutil.NopCloser(peeked), comp)
 	if err != nil {
 		return nil, err
 	}
@@ -148,7 +214,7 @@ func computeLayer(layer v1.Layer) (*computedLayer, error) {
 			return nil, err
 		}
 		if _, ok := files[hdr.Name]; ok {
-			return nil, fmt.Errorf("duplicate file path: %s", hdr.Name)
+			return nil, fmt.Errorf("%w: %s", errDuplicatePath, hdr.Name)
 		}
 		files[hdr.Name] = struct{}{}
 	}
@@ -177,7 +243,7 @@ func computeLayer(layer v1.Layer) (*computedLayer, error) {
 		return nil, err
 	}
 	defer ur.Close()
-	udiffid, usize, err := v1.SHA256(ur)
+	udiffid, usize, err := v1.SHA256(&contextReader{ctx, ur})
 	if err != nil {
 		return nil, err
 	}
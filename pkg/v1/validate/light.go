@@ -0,0 +1,93 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// validateBlobs checks that the config and layers referenced by img's manifest
+// exist with the digests and sizes it claims, without reading them.
+func validateBlobs(r *Report, loc string, img v1.Image, o options) {
+	m, err := img.Manifest()
+	if err != nil {
+		r.unreadable(at(loc, "manifest"), v1.Hash{}, err)
+		return
+	}
+
+	validateBlob(r, at(loc, "config"), img, m.Config)
+	for i, desc := range m.Layers {
+		lloc := at(loc, fmt.Sprintf("layers[%d]", i))
+		if !o.checkpoint(r, Progress{Location: lloc, Digest: desc.Digest, Layer: i, Layers: len(m.Layers)}) {
+			return
+		}
+		// Foreign layers aren't expected to be stored alongside the image.
+		if !desc.MediaType.IsDistributable() {
+			continue
+		}
+		validateBlob(r, lloc, img, desc)
+	}
+}
+
+func validateBlob(r *Report, loc string, img v1.Image, desc v1.Descriptor) {
+	layer, err := img.LayerByDigest(desc.Digest)
+	if err != nil {
+		r.unreadable(loc, desc.Digest, err)
+		return
+	}
+	validateStat(r, loc, desc, layer)
+}
+
+// validateStat checks that layer exists and that its storage agrees with desc.
+// This uses partial.Stat where possible, so that a remote blob costs a single
+// HEAD request.
+func validateStat(r *Report, loc string, desc v1.Descriptor, layer v1.Layer) {
+	st, err := partial.Stat(layer)
+	if err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			r.errorf(KindMissing, loc, desc.Digest, "blob does not exist")
+		} else {
+			r.unreadable(loc, desc.Digest, err)
+		}
+		return
+	}
+
+	if st == nil {
+		// We can't learn anything more than whether it exists.
+		ok, err := partial.Exists(layer)
+		if err != nil {
+			r.unreadable(loc, desc.Digest, err)
+		} else if !ok {
+			r.errorf(KindMissing, loc, desc.Digest, "blob does not exist")
+		}
+		return
+	}
+
+	if st.Digest != desc.Digest {
+		r.errorf(KindDigestMismatch, loc, desc.Digest, "mismatched digest: Descriptor.Digest=%s, Stat().Digest=%s", desc.Digest, st.Digest)
+	}
+
+	// Registries may omit Content-Length, in which case the size is unknown.
+	if st.Size >= 0 && st.Size != desc.Size {
+		r.errorf(KindSizeMismatch, loc, desc.Digest, "mismatched size: Descriptor.Size=%d, Stat().Size=%d", desc.Size, st.Size)
+	}
+}
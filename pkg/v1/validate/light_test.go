@@ -0,0 +1,125 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestWithLight(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+		override = map[string]func(http.ResponseWriter){}
+	)
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			requests = append(requests, req.Method+" "+req.URL.Path)
+			f := override[req.Method+" "+req.URL.Path]
+			mu.Unlock()
+			if f != nil {
+				f(w)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)), registry.WithMiddleware(mw)))
+	defer s.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/test/light")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	requests = nil
+	mu.Unlock()
+
+	rmt, err := remote.Image(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(rmt, validate.WithLight()); err != nil {
+		t.Errorf("Image(WithLight) = %v", err)
+	}
+	heads := 0
+	for _, r := range requests {
+		if strings.Contains(r, "/blobs/") {
+			if !strings.HasPrefix(r, http.MethodHead+" ") {
+				t.Errorf("unexpected blob request: %s", r)
+			}
+			heads++
+		}
+	}
+	if got, want := heads, 1+len(m.Layers); got != want {
+		t.Errorf("HEAD requests = %d, want %d", got, want)
+	}
+
+	blob := func(i int) string {
+		return http.MethodHead + " /v2/test/light/blobs/" + m.Layers[i].Digest.String()
+	}
+	mu.Lock()
+	override[blob(0)] = func(w http.ResponseWriter) {
+		w.Header().Set("Content-Length", "1")
+		w.WriteHeader(http.StatusOK)
+	}
+	override[blob(1)] = func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	mu.Unlock()
+
+	r := validate.ImageReport(rmt, validate.WithLight())
+	if len(r.Findings) != 2 {
+		t.Fatalf("ImageReport(WithLight) = %+v", r.Findings)
+	}
+	for i, want := range []validate.Kind{validate.KindSizeMismatch, validate.KindMissing} {
+		if got := r.Findings[i].Kind; got != want {
+			t.Errorf("Findings[%d].Kind = %s, want %s", i, got, want)
+		}
+		if got, want := r.Findings[i].Digest, m.Layers[i].Digest; got != want {
+			t.Errorf("Findings[%d].Digest = %s, want %s", i, got, want)
+		}
+	}
+
+	// Images that don't support partial.Stat still get existence checks.
+	if err := validate.Image(img, validate.WithLight()); err != nil {
+		t.Errorf("Image(WithLight) = %v", err)
+	}
+}
@@ -14,16 +14,31 @@
 
 package validate
 
+import "context"
+
 // Option is a functional option for validate.
 type Option func(*options)
 
 type options struct {
-	fast bool
+	fast   bool
+	ignore []Kind
+
+	// compressedOnly skips decompressing layers.
+	compressedOnly bool
+
+	// light only checks that blobs exist with the right digest and size.
+	light bool
+
+	ctx      context.Context
+	progress func(Progress)
+
+	rules []Rule
 }
 
 func makeOptions(opts ...Option) options {
 	opt := options{
 		fast: false,
+		ctx:  context.Background(),
 	}
 	for _, o := range opts {
 		o(&opt)
@@ -35,3 +50,63 @@ func makeOptions(opts ...Option) options {
 func Fast(o *options) {
 	o.fast = true
 }
+
+// WithFast reads and digests every layer's compressed contents, and checks
+// them against the manifest along with everything else, but skips
+// decompressing layers, so their diffids aren't verified. That's cheaper than
+// a full validation for large images, but unlike Fast and WithLight, it still
+// reads every layer.
+func WithFast() Option {
+	return func(o *options) {
+		o.compressedOnly = true
+	}
+}
+
+// WithCompressedOnly is an alias for WithFast, named for what it does: layers
+// are only checked in their compressed form.
+func WithCompressedOnly() Option {
+	return WithFast()
+}
+
+// WithLight only checks manifests and the existence, digests, and sizes of the
+// blobs they refer to, without reading any blob contents. For remote images,
+// that costs a manifest fetch per image and a HEAD request per blob, which makes
+// it suitable for periodic integrity audits of large registries.
+func WithLight() Option {
+	return func(o *options) {
+		o.light = true
+	}
+}
+
+// WithContext stops validation once ctx is done. The returned error then
+// satisfies errors.Is with ctx.Err(). To also interrupt requests for remote
+// images, pass the same context to remote.WithContext.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithProgress calls f before checking each layer, so that callers can show
+// which layer is being validated. Calls are made sequentially.
+func WithProgress(f func(Progress)) Option {
+	return func(o *options) {
+		o.progress = f
+	}
+}
+
+// WithRules checks that images follow the given rules, in addition to the
+// integrity checks. For indexes, the rules apply to each image.
+func WithRules(rules ...Rule) Option {
+	return func(o *options) {
+		o.rules = append(o.rules, rules...)
+	}
+}
+
+// Ignore drops findings of the given kinds, so that they don't make
+// validation fail.
+func Ignore(kinds ...Kind) Option {
+	return func(o *options) {
+		o.ignore = append(o.ignore, kinds...)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+)
+
+// dockerReferenceDigest is the annotation buildkit sets on attestation
+// manifests to the digest of the image they describe.
+const dockerReferenceDigest = "vnd.docker.reference.digest"
+
+// validatePlatforms checks that the images in an index have sensible, distinct
+// platforms, and that attestations refer to images in the index.
+func validatePlatforms(r *Report, loc string, manifest *v1.IndexManifest) {
+	images := map[v1.Hash]bool{}
+	for _, desc := range manifest.Manifests {
+		if desc.MediaType.IsImage() {
+			images[desc.Digest] = true
+		}
+	}
+
+	attestation := match.Attestations()
+	seen := []v1.Platform{}
+	for i, desc := range manifest.Manifests {
+		cloc := at(loc, fmt.Sprintf("manifests[%d]", i))
+		if attestation(desc) {
+			ref := desc.Annotations[dockerReferenceDigest]
+			if h, err := v1.NewHash(ref); err != nil {
+				r.errorf(KindInvalidAttestation, cloc, desc.Digest, "attestation has invalid %s annotation %q: %v", dockerReferenceDigest, ref, err)
+			} else if !images[h] {
+				r.errorf(KindInvalidAttestation, cloc, desc.Digest, "attestation refers to %s, which isn't an image in the index", h)
+			}
+			continue
+		}
+		if !desc.MediaType.IsImage() {
+			continue
+		}
+		if desc.Platform == nil {
+			r.warnf(KindPlatform, cloc, desc.Digest, "image has no platform")
+			continue
+		}
+		if desc.Platform.OS == "" || desc.Platform.Architecture == "" {
+			r.errorf(KindPlatform, cloc, desc.Digest, "platform %+v is missing os or architecture", *desc.Platform)
+		}
+		for _, p := range seen {
+			if p.Equals(*desc.Platform) {
+				r.errorf(KindDuplicatePlatform, cloc, desc.Digest, "duplicate platform %s", desc.Platform)
+				break
+			}
+		}
+		seen = append(seen, *desc.Platform)
+	}
+}
+
+// validateChildPlatform checks that an index's descriptor for img has the same
+// platform as img's config.
+func validateChildPlatform(r *Report, loc string, desc v1.Descriptor, img v1.Image) {
+	if desc.Platform == nil || match.Attestations()(desc) {
+		return
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		r.unreadable(at(loc, "config"), desc.Digest, err)
+		return
+	}
+	for _, f := range []struct {
+		name       string
+		desc, conf string
+	}{
+		{"os", desc.Platform.OS, cf.OS},
+		{"architecture", desc.Platform.Architecture, cf.Architecture},
+		{"os.version", desc.Platform.OSVersion, cf.OSVersion},
+	} {
+		if f.desc != "" && f.conf != "" && f.desc != f.conf {
+			r.errorf(KindPlatform, loc, desc.Digest, "mismatched %s: Platform=%q, ConfigFile=%q", f.name, f.desc, f.conf)
+		}
+	}
+}
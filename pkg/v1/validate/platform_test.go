@@ -0,0 +1,120 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func platformImage(t *testing.T, os, arch string) v1.Image {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.OS, cf.Architecture = os, arch
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func attestation(t *testing.T, ref string) mutate.IndexAddendum {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mutate.IndexAddendum{
+		Add: img,
+		Descriptor: v1.Descriptor{
+			Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{
+				"vnd.docker.reference.type":   "attestation-manifest",
+				"vnd.docker.reference.digest": ref,
+			},
+		},
+	}
+}
+
+func TestPlatforms(t *testing.T) {
+	amd64 := platformImage(t, "linux", "amd64")
+	arm64 := platformImage(t, "linux", "arm64")
+	d, err := amd64.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := func(img v1.Image, os, arch string) mutate.IndexAddendum {
+		return mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: os, Architecture: arch}},
+		}
+	}
+
+	for _, tc := range []struct {
+		desc string
+		adds []mutate.IndexAddendum
+		want validate.Kind
+	}{{
+		desc: "valid",
+		adds: []mutate.IndexAddendum{on(amd64, "linux", "amd64"), on(arm64, "linux", "arm64"), attestation(t, d.String())},
+	}, {
+		desc: "duplicate platform",
+		adds: []mutate.IndexAddendum{on(amd64, "linux", "amd64"), on(platformImage(t, "linux", "amd64"), "linux", "amd64")},
+		want: validate.KindDuplicatePlatform,
+	}, {
+		desc: "missing architecture",
+		adds: []mutate.IndexAddendum{on(platformImage(t, "linux", ""), "linux", "")},
+		want: validate.KindPlatform,
+	}, {
+		desc: "mismatched config",
+		adds: []mutate.IndexAddendum{on(arm64, "linux", "amd64")},
+		want: validate.KindPlatform,
+	}, {
+		desc: "attestation without reference",
+		adds: []mutate.IndexAddendum{on(amd64, "linux", "amd64"), attestation(t, "")},
+		want: validate.KindInvalidAttestation,
+	}, {
+		desc: "attestation for missing image",
+		adds: []mutate.IndexAddendum{on(amd64, "linux", "amd64"), attestation(t, bogus.String())},
+		want: validate.KindInvalidAttestation,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			idx := mutate.AppendManifests(empty.Index, tc.adds...)
+			errs := validate.IndexReport(idx).Errors()
+			if tc.want == "" {
+				if len(errs) != 0 {
+					t.Errorf("IndexReport() = %v, want no errors", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || errs[0].Kind != tc.want {
+				t.Errorf("IndexReport() = %v, want one %s error", errs, tc.want)
+			}
+		})
+	}
+}
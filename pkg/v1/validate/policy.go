@@ -0,0 +1,159 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Rule is a policy that images must follow, checked alongside the integrity
+// checks with WithRules. Violations are reported as KindPolicy findings.
+type Rule interface {
+	// Check returns a message for each way that img violates the rule. An
+	// error means that img couldn't be checked.
+	Check(img v1.Image) ([]string, error)
+}
+
+// RuleFunc adapts a function to a Rule.
+type RuleFunc func(img v1.Image) ([]string, error)
+
+// Check implements Rule.
+func (f RuleFunc) Check(img v1.Image) ([]string, error) {
+	return f(img)
+}
+
+func validateRules(r *Report, loc string, img v1.Image, o options) {
+	for _, rule := range o.rules {
+		if !o.proceed(r, loc) {
+			return
+		}
+		violations, err := rule.Check(img)
+		if err != nil {
+			r.unreadable(loc, v1.Hash{}, err)
+			continue
+		}
+		if len(violations) == 0 {
+			continue
+		}
+		digest, _ := img.Digest()
+		for _, v := range violations {
+			r.errorf(KindPolicy, loc, digest, "%s", v)
+		}
+	}
+}
+
+// MaxLayers requires images to have at most n layers.
+func MaxLayers(n int) Rule {
+	return RuleFunc(func(img v1.Image) ([]string, error) {
+		m, err := img.Manifest()
+		if err != nil {
+			return nil, err
+		}
+		if len(m.Layers) > n {
+			return []string{fmt.Sprintf("image has %d layers, more than the maximum of %d", len(m.Layers), n)}, nil
+		}
+		return nil, nil
+	})
+}
+
+// MaxSize requires the config and layers of images to add up to at most n
+// bytes, as described by their manifest.
+func MaxSize(n int64) Rule {
+	return RuleFunc(func(img v1.Image) ([]string, error) {
+		m, err := img.Manifest()
+		if err != nil {
+			return nil, err
+		}
+		size := m.Config.Size
+		for _, desc := range m.Layers {
+			size += desc.Size
+		}
+		if size > n {
+			return []string{fmt.Sprintf("image is %d bytes, more than the maximum of %d", size, n)}, nil
+		}
+		return nil, nil
+	})
+}
+
+// ForbidMediaTypes forbids images whose manifest, config or layers have any of
+// the given media types.
+func ForbidMediaTypes(mts ...types.MediaType) Rule {
+	return RuleFunc(func(img v1.Image) ([]string, error) {
+		m, err := img.Manifest()
+		if err != nil {
+			return nil, err
+		}
+		forbidden := func(mt types.MediaType) bool {
+			for _, f := range mts {
+				if mt == f {
+					return true
+				}
+			}
+			return false
+		}
+
+		var violations []string
+		if forbidden(m.MediaType) {
+			violations = append(violations, fmt.Sprintf("manifest has forbidden media type %s", m.MediaType))
+		}
+		if forbidden(m.Config.MediaType) {
+			violations = append(violations, fmt.Sprintf("config has forbidden media type %s", m.Config.MediaType))
+		}
+		for i, desc := range m.Layers {
+			if forbidden(desc.MediaType) {
+				violations = append(violations, fmt.Sprintf("layer[%d] has forbidden media type %s", i, desc.MediaType))
+			}
+		}
+		return violations, nil
+	})
+}
+
+// RequireAnnotations requires images' manifests to have the given annotations.
+func RequireAnnotations(keys ...string) Rule {
+	return RuleFunc(func(img v1.Image) ([]string, error) {
+		m, err := img.Manifest()
+		if err != nil {
+			return nil, err
+		}
+		var violations []string
+		for _, k := range keys {
+			if _, ok := m.Annotations[k]; !ok {
+				violations = append(violations, fmt.Sprintf("manifest is missing required annotation %q", k))
+			}
+		}
+		return violations, nil
+	})
+}
+
+// RequireLabels requires images' configs to have the given labels. This reads
+// the config, even with WithLight.
+func RequireLabels(keys ...string) Rule {
+	return RuleFunc(func(img v1.Image) ([]string, error) {
+		cf, err := img.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		var violations []string
+		for _, k := range keys {
+			if _, ok := cf.Config.Labels[k]; !ok {
+				violations = append(violations, fmt.Sprintf("config is missing required label %q", k))
+			}
+		}
+		return violations, nil
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestWithRules(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.Annotations(img, map[string]string{"org.opencontainers.image.source": "example"}).(v1.Image)
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.Config.Labels = map[string]string{"maintainer": "someone"}
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		rule validate.Rule
+		want int
+	}{
+		{validate.MaxLayers(3), 0},
+		{validate.MaxLayers(2), 1},
+		{validate.MaxSize(1 << 20), 0},
+		{validate.MaxSize(1024), 1},
+		{validate.ForbidMediaTypes(types.DockerForeignLayer), 0},
+		{validate.ForbidMediaTypes(types.DockerLayer, types.DockerManifestSchema2), 4},
+		{validate.RequireAnnotations("org.opencontainers.image.source"), 0},
+		{validate.RequireAnnotations("org.opencontainers.image.source", "a", "b"), 2},
+		{validate.RequireLabels("maintainer"), 0},
+		{validate.RequireLabels("maintainer", "version"), 1},
+	} {
+		r := validate.ImageReport(img, validate.WithRules(tc.rule))
+		if got := len(r.Findings); got != tc.want {
+			t.Errorf("got %d findings, want %d: %v", got, tc.want, r.Findings)
+		}
+		for _, f := range r.Findings {
+			if f.Kind != validate.KindPolicy {
+				t.Errorf("Kind = %s, want %s", f.Kind, validate.KindPolicy)
+			}
+		}
+	}
+
+	// Rules apply to each image of an index, alongside the integrity checks.
+	idx, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := validate.RuleFunc(func(img v1.Image) ([]string, error) {
+		return []string{"nope"}, nil
+	})
+	r := validate.IndexReport(idx, validate.WithRules(validate.MaxLayers(1), custom), validate.WithLight())
+	if got, want := len(r.Errors()), 4; got != want {
+		t.Errorf("got %d errors, want %d: %v", got, want, r.Findings)
+	}
+	if !strings.HasPrefix(r.Findings[0].Location, "manifests[0]") {
+		t.Errorf("Location = %q", r.Findings[0].Location)
+	}
+	if err := validate.Index(idx, validate.WithRules(custom), validate.Ignore(validate.KindPolicy)); err != nil {
+		t.Errorf("Index(Ignore(KindPolicy)) = %v", err)
+	}
+
+	// Errors from rules are reported as unreadable.
+	broken := validate.RuleFunc(func(img v1.Image) ([]string, error) {
+		return nil, errors.New("oops")
+	})
+	r = validate.ImageReport(img, validate.WithRules(broken))
+	if len(r.Findings) != 1 || r.Findings[0].Kind != validate.KindUnreadable {
+		t.Errorf("ImageReport() = %v", r.Findings)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Progress describes the layer that validation is about to check.
+type Progress struct {
+	// Location and Digest identify the layer, as in Finding. Digest may be
+	// unset for layers that haven't been read yet, e.g. stream.Layer.
+	Location string
+	Digest   v1.Hash
+
+	// Layer is the index of the layer in its image, out of Layers.
+	Layer, Layers int
+}
+
+// checkpoint reports p to the progress callback, if any. It returns false if
+// validation has been canceled, after recording that in r.
+func (o options) checkpoint(r *Report, p Progress) bool {
+	if !o.proceed(r, p.Location) {
+		return false
+	}
+	if o.progress != nil {
+		o.progress(p)
+	}
+	return true
+}
+
+// proceed returns false if validation has been canceled, after recording that
+// in r.
+func (o options) proceed(r *Report, loc string) bool {
+	if err := o.ctx.Err(); err != nil {
+		r.cancel(loc, v1.Hash{}, err)
+		return false
+	}
+	return true
+}
+
+// contextReader stops reading once its context is done, so that validating a
+// huge layer can be interrupted.
+type contextReader struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.ReadCloser.Read(p)
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestWithProgress(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range [][]validate.Option{{}, {validate.WithFast()}, {validate.WithCompressedOnly()}, {validate.Fast}, {validate.WithLight()}} {
+		var got []validate.Progress
+		opts = append(opts, validate.WithProgress(func(p validate.Progress) {
+			got = append(got, p)
+		}))
+		if err := validate.Image(img, opts...); err != nil {
+			t.Fatalf("Image() = %v", err)
+		}
+		if len(got) != len(m.Layers) {
+			t.Fatalf("got %d progress updates, want %d", len(got), len(m.Layers))
+		}
+		for i, p := range got {
+			want := validate.Progress{
+				Location: fmt.Sprintf("layers[%d]", i),
+				Digest:   m.Layers[i].Digest,
+				Layer:    i,
+				Layers:   len(m.Layers),
+			}
+			if p != want {
+				t.Errorf("progress[%d] = %+v, want %+v", i, p, want)
+			}
+		}
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cancel while checking the second layer.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	err = validate.Image(img, validate.WithContext(ctx), validate.WithProgress(func(p validate.Progress) {
+		calls++
+		if p.Layer == 1 {
+			cancel()
+		}
+	}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Image() = %v, want %v", err, context.Canceled)
+	}
+	if calls != 2 {
+		t.Errorf("got %d progress updates, want 2", calls)
+	}
+
+	var r *validate.Report
+	if !errors.As(err, &r) {
+		t.Fatalf("Image() = %T, want *Report", err)
+	}
+	errs := r.Errors()
+	if len(errs) != 1 || errs[0].Kind != validate.KindCanceled || errs[0].Location != "layers[1]" {
+		t.Errorf("Errors() = %+v", errs)
+	}
+
+	// Cancellation can't be ignored.
+	idx, err := random.Index(1024, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(idx, validate.WithContext(ctx), validate.Ignore(validate.KindCanceled)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Index() = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Severity is how serious a Finding is.
+type Severity int
+
+const (
+	// SeverityError findings make an image invalid.
+	SeverityError Severity = iota
+
+	// SeverityWarning findings are suspicious, but don't make an image
+	// invalid.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Kind classifies findings, so that classes of them can be ignored.
+type Kind string
+
+const (
+	// KindUnreadable means that something couldn't be read at all.
+	KindUnreadable Kind = "unreadable"
+
+	// KindMissing means that a layer doesn't exist.
+	KindMissing Kind = "missing"
+
+	// KindTruncated means that a layer's contents ended early.
+	KindTruncated Kind = "truncated"
+
+	// KindDuplicateFile means that a layer contains the same path twice.
+	KindDuplicateFile Kind = "duplicate-file"
+
+	// KindDigestMismatch means that a digest doesn't match the contents.
+	KindDigestMismatch Kind = "digest-mismatch"
+
+	// KindDiffIDMismatch means that a diffid doesn't match the uncompressed
+	// contents of a layer.
+	KindDiffIDMismatch Kind = "diffid-mismatch"
+
+	// KindSizeMismatch means that a size doesn't match the contents.
+	KindSizeMismatch Kind = "size-mismatch"
+
+	// KindMediaTypeMismatch means that a descriptor's media type doesn't
+	// match what it describes.
+	KindMediaTypeMismatch Kind = "mediatype-mismatch"
+
+	// KindContentMismatch means that the parsed form of a manifest or config
+	// doesn't match its raw form.
+	KindContentMismatch Kind = "content-mismatch"
+
+	// KindInvalidConfig means that a config file has invalid values.
+	KindInvalidConfig Kind = "invalid-config"
+
+	// KindPlatform means that an index's image has a missing or incomplete
+	// platform, or one that doesn't match its config.
+	KindPlatform Kind = "platform"
+
+	// KindDuplicatePlatform means that an index has more than one image for
+	// the same platform.
+	KindDuplicatePlatform Kind = "duplicate-platform"
+
+	// KindInvalidAttestation means that an attestation manifest doesn't
+	// refer to an image in its index.
+	KindInvalidAttestation Kind = "invalid-attestation"
+
+	// KindPolicy means that an image violates a Rule.
+	KindPolicy Kind = "policy"
+
+	// KindCanceled means that validation stopped early because its context
+	// was done. It can't be ignored.
+	KindCanceled Kind = "canceled"
+
+	// KindUnexpectedManifest means that an index refers to something that
+	// isn't an image or index and can't be validated.
+	KindUnexpectedManifest Kind = "unexpected-manifest"
+)
+
+// Finding is a single problem found during validation.
+type Finding struct {
+	Severity Severity
+	Kind     Kind
+
+	// Digest is the digest of the manifest, config or layer the finding is
+	// about, if known.
+	Digest v1.Hash
+
+	// Location is where in the image or index the finding is, e.g.
+	// "manifests[1].layers[0]". It's empty for the top-level object.
+	Location string
+
+	Message string
+}
+
+func (f Finding) String() string {
+	s := f.Message
+	if f.Location != "" {
+		s = f.Location + ": " + s
+	}
+	if f.Severity != SeverityError {
+		s = f.Severity.String() + ": " + s
+	}
+	return s
+}
+
+// Report lists everything found while validating an image, index or layer.
+// A Report with errors is returned as the error of Image, Index and Layer,
+// and can be retrieved with errors.As.
+type Report struct {
+	Findings []Finding
+
+	ignore []Kind
+
+	// canceled is why validation stopped early, if it did.
+	canceled error
+}
+
+// Errors returns the findings with SeverityError.
+func (r *Report) Errors() []Finding {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns the findings with SeverityWarning.
+func (r *Report) Warnings() []Finding {
+	return r.filter(SeverityWarning)
+}
+
+func (r *Report) filter(s Severity) []Finding {
+	var fs []Finding
+	for _, f := range r.Findings {
+		if f.Severity == s {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// Err returns r if it has any errors, and nil otherwise.
+func (r *Report) Err() error {
+	if len(r.Errors()) == 0 {
+		return nil
+	}
+	return r
+}
+
+// Error lists every finding, one per line.
+func (r *Report) Error() string {
+	lines := make([]string, 0, len(r.Findings))
+	for _, f := range r.Findings {
+		lines = append(lines, f.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the context error that canceled validation, if any.
+func (r *Report) Unwrap() error {
+	return r.canceled
+}
+
+func (r *Report) add(f Finding) {
+	for _, k := range r.ignore {
+		if f.Kind == k {
+			return
+		}
+	}
+	r.Findings = append(r.Findings, f)
+}
+
+func (r *Report) errorf(kind Kind, loc string, digest v1.Hash, format string, args ...interface{}) {
+	r.add(Finding{Severity: SeverityError, Kind: kind, Digest: digest, Location: loc, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) warnf(kind Kind, loc string, digest v1.Hash, format string, args ...interface{}) {
+	r.add(Finding{Severity: SeverityWarning, Kind: kind, Digest: digest, Location: loc, Message: fmt.Sprintf(format, args...)})
+}
+
+// unreadable records that something at loc couldn't be read.
+func (r *Report) unreadable(loc string, digest v1.Hash, err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		r.cancel(loc, digest, err)
+		return
+	}
+	r.errorf(KindUnreadable, loc, digest, "%v", err)
+}
+
+// cancel records that validation stopped at loc because of err. Only the
+// first cancellation is recorded.
+func (r *Report) cancel(loc string, digest v1.Hash, err error) {
+	if r.canceled != nil {
+		return
+	}
+	r.canceled = err
+	r.Findings = append(r.Findings, Finding{Severity: SeverityError, Kind: KindCanceled, Digest: digest, Location: loc, Message: fmt.Sprintf("validation canceled: %v", err)})
+}
+
+// at returns the location of elem within loc.
+func at(loc, elem string) string {
+	if loc == "" {
+		return elem
+	}
+	return loc + "." + elem
+}
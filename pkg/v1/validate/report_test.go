@@ -0,0 +1,164 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// badConfigName is an image that lies about its config digest.
+type badConfigName struct {
+	v1.Image
+}
+
+var bogus = v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("0", 64)}
+
+func (badConfigName) ConfigName() (v1.Hash, error) {
+	return bogus, nil
+}
+
+// badSize is a layer that lies about its size.
+type badSize struct {
+	v1.Layer
+}
+
+func (l badSize) Size() (int64, error) {
+	size, err := l.Layer.Size()
+	return size + 1, err
+}
+
+// badChild is an index whose images lie about their config digest.
+type badChild struct {
+	idx v1.ImageIndex
+}
+
+func (idx badChild) MediaType() (types.MediaType, error)       { return idx.idx.MediaType() }
+func (idx badChild) Digest() (v1.Hash, error)                  { return idx.idx.Digest() }
+func (idx badChild) Size() (int64, error)                      { return idx.idx.Size() }
+func (idx badChild) IndexManifest() (*v1.IndexManifest, error) { return idx.idx.IndexManifest() }
+func (idx badChild) RawManifest() ([]byte, error)              { return idx.idx.RawManifest() }
+func (idx badChild) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
+	return idx.idx.ImageIndex(h)
+}
+
+func (idx badChild) Image(h v1.Hash) (v1.Image, error) {
+	img, err := idx.idx.Image(h)
+	return badConfigName{img}, err
+}
+
+func TestImageReport(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := validate.ImageReport(img); len(r.Findings) != 0 {
+		t.Errorf("ImageReport() = %v, want no findings", r)
+	}
+
+	err = validate.Image(badConfigName{img})
+	var r *validate.Report
+	if !errors.As(err, &r) {
+		t.Fatalf("Image() = %v, want a *Report", err)
+	}
+	if len(r.Errors()) != 1 {
+		t.Fatalf("Errors() = %v, want 1 error", r.Errors())
+	}
+	f := r.Errors()[0]
+	if f.Kind != validate.KindDigestMismatch || f.Location != "config" || f.Digest != bogus {
+		t.Errorf("finding = %+v", f)
+	}
+	if !strings.HasPrefix(err.Error(), "config: mismatched config digest") {
+		t.Errorf("Error() = %q", err)
+	}
+
+	if err := validate.Image(badConfigName{img}, validate.Ignore(validate.KindDigestMismatch)); err != nil {
+		t.Errorf("Image(Ignore) = %v", err)
+	}
+}
+
+func TestLayerReport(t *testing.T) {
+	l, err := random.Layer(1024, "application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := validate.LayerReport(badSize{l})
+	if len(r.Findings) != 1 || r.Findings[0].Kind != validate.KindSizeMismatch || r.Findings[0].Location != "" {
+		t.Errorf("LayerReport() = %+v", r.Findings)
+	}
+}
+
+func TestIndexReport(t *testing.T) {
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(idx); err != nil {
+		t.Errorf("Index() = %v", err)
+	}
+
+	r := validate.IndexReport(badChild{idx})
+	var locs []string
+	for _, f := range r.Errors() {
+		locs = append(locs, f.Location)
+	}
+	if got, want := strings.Join(locs, ","), "manifests[0].config,manifests[1].config"; got != want {
+		t.Errorf("locations = %s, want %s", got, want)
+	}
+}
+
+// badDiffID is a layer that lies about its diffid.
+type badDiffID struct {
+	v1.Layer
+}
+
+func (badDiffID) DiffID() (v1.Hash, error) {
+	return bogus, nil
+}
+
+func TestWithFast(t *testing.T) {
+	l, err := random.Layer(1024, "application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Diffids are only checked when layers are decompressed.
+	if err := validate.Layer(badDiffID{l}); err == nil {
+		t.Error("Layer() succeeded with a bad diffid")
+	}
+	if err := validate.Layer(badDiffID{l}, validate.WithFast()); err != nil {
+		t.Errorf("Layer(WithFast) = %v", err)
+	}
+
+	// But compressed contents still are.
+	r := validate.LayerReport(badSize{l}, validate.WithFast())
+	if len(r.Findings) != 1 || r.Findings[0].Kind != validate.KindSizeMismatch {
+		t.Errorf("LayerReport(WithFast) = %+v", r.Findings)
+	}
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(img, validate.WithFast()); err != nil {
+		t.Errorf("Image(WithFast) = %v", err)
+	}
+}
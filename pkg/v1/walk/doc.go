@@ -0,0 +1,22 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package walk traverses the graph of descriptors reachable from a root
+// descriptor: the children of indexes, the configs and layers of manifests,
+// and, optionally, the referrers of each manifest.
+//
+// Copying, garbage collection, validation and signing all need this
+// traversal; Descriptors implements it once, with protection against cycles
+// and oversized or deeply nested graphs, over any Fetcher.
+package walk
@@ -0,0 +1,67 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package walk
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Remote returns a ReferrersFetcher for the manifests in repo.
+func Remote(repo name.Repository, options ...remote.Option) ReferrersFetcher {
+	return &remoteFetcher{repo: repo, options: options}
+}
+
+type remoteFetcher struct {
+	repo    name.Repository
+	options []remote.Option
+}
+
+func (f *remoteFetcher) opts(ctx context.Context) []remote.Option {
+	return append(append([]remote.Option{}, f.options...), remote.WithContext(ctx))
+}
+
+func (f *remoteFetcher) Manifest(ctx context.Context, desc v1.Descriptor) ([]byte, error) {
+	d, err := remote.Get(f.repo.Digest(desc.Digest.String()), f.opts(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+	return d.Manifest, nil
+}
+
+func (f *remoteFetcher) Referrers(ctx context.Context, desc v1.Descriptor) ([]v1.Descriptor, error) {
+	idx, err := remote.Referrers(f.repo.Digest(desc.Digest.String()), f.opts(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Manifests, nil
+}
+
+// Layout returns a Fetcher for the manifests in the OCI image layout at path.
+func Layout(path layout.Path) Fetcher {
+	return layoutFetcher{path: path}
+}
+
+type layoutFetcher struct {
+	path layout.Path
+}
+
+func (f layoutFetcher) Manifest(_ context.Context, desc v1.Descriptor) ([]byte, error) {
+	return f.path.Bytes(desc.Digest)
+}
@@ -0,0 +1,274 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package walk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Relation is how a node relates to its parent.
+type Relation string
+
+// The relations between nodes.
+const (
+	// Root is the relation of the root node, which has no parent.
+	Root Relation = "root"
+
+	// Child is a manifest in an index.
+	Child Relation = "child"
+
+	// Config is the config of a manifest.
+	Config Relation = "config"
+
+	// Layer is a layer of a manifest.
+	Layer Relation = "layer"
+
+	// Referrer is a manifest whose subject is its parent.
+	Referrer Relation = "referrer"
+)
+
+// Node is a descriptor in the graph.
+type Node struct {
+	Descriptor v1.Descriptor
+	Relation   Relation
+
+	// Parent is the node that the descriptor was found in, or nil for the
+	// root.
+	Parent *Node
+
+	// Depth is the number of ancestors of the node.
+	Depth int
+}
+
+// Visitor is called with each node, before its children are visited.
+//
+// If it returns SkipChildren, the node's children and referrers aren't
+// visited, and their manifests aren't fetched. Any other error stops the walk
+// and is returned by Descriptors.
+type Visitor func(ctx context.Context, n *Node) error
+
+// SkipChildren is returned by a Visitor to skip the children of a node.
+var SkipChildren = errors.New("skip children")
+
+// Fetcher fetches the contents of the manifests in the graph.
+type Fetcher interface {
+	// Manifest returns the raw manifest that desc describes.
+	Manifest(ctx context.Context, desc v1.Descriptor) ([]byte, error)
+}
+
+// ReferrersFetcher is a Fetcher that can also list referrers, as required by
+// WithReferrers.
+type ReferrersFetcher interface {
+	Fetcher
+
+	// Referrers returns the descriptors of the manifests whose subject is
+	// desc.
+	Referrers(ctx context.Context, desc v1.Descriptor) ([]v1.Descriptor, error)
+}
+
+const (
+	defaultMaxDepth        = 16
+	defaultMaxManifestSize = 4 * 1024 * 1024
+)
+
+type options struct {
+	referrers       bool
+	maxDepth        int
+	maxManifestSize int64
+	maxNodes        int
+}
+
+// Option is a functional option for Descriptors.
+type Option func(*options)
+
+// WithReferrers visits the referrers of each manifest and index, after its
+// children. The Fetcher must implement ReferrersFetcher.
+func WithReferrers() Option {
+	return func(o *options) {
+		o.referrers = true
+	}
+}
+
+// WithMaxDepth sets how many ancestors a node may have. The default is 16.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+// WithMaxManifestSize sets the largest manifest, in bytes, that will be
+// fetched. The default is 4 MiB.
+func WithMaxManifestSize(n int64) Option {
+	return func(o *options) {
+		o.maxManifestSize = n
+	}
+}
+
+// WithMaxNodes sets how many nodes may be visited. By default there's no
+// limit.
+func WithMaxNodes(n int) Option {
+	return func(o *options) {
+		o.maxNodes = n
+	}
+}
+
+// Descriptors walks the graph of descriptors reachable from root, depth
+// first, calling visit with each. Each digest is visited once, even if it's
+// reachable by several paths, which also breaks cycles.
+//
+// Manifests are verified against their descriptors' size and sha256 digest
+// before they're parsed. A descriptor's embedded data is used instead of
+// fetching it, if present.
+func Descriptors(ctx context.Context, root v1.Descriptor, fetcher Fetcher, visit Visitor, opts ...Option) error {
+	o := options{
+		maxDepth:        defaultMaxDepth,
+		maxManifestSize: defaultMaxManifestSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	w := &walker{
+		options: o,
+		fetcher: fetcher,
+		visit:   visit,
+		seen:    map[v1.Hash]bool{},
+	}
+	if o.referrers {
+		rf, ok := fetcher.(ReferrersFetcher)
+		if !ok {
+			return errors.New("walk: WithReferrers requires a ReferrersFetcher")
+		}
+		w.referrers = rf
+	}
+	return w.walk(ctx, &Node{Descriptor: root, Relation: Root})
+}
+
+type walker struct {
+	options
+	fetcher   Fetcher
+	referrers ReferrersFetcher
+	visit     Visitor
+	seen      map[v1.Hash]bool
+}
+
+func (w *walker) walk(ctx context.Context, n *Node) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	desc := n.Descriptor
+	if w.seen[desc.Digest] {
+		return nil
+	}
+	w.seen[desc.Digest] = true
+	if n.Depth > w.maxDepth {
+		return fmt.Errorf("walk: %s is nested more than %d deep", desc.Digest, w.maxDepth)
+	}
+	if w.maxNodes > 0 && len(w.seen) > w.maxNodes {
+		return fmt.Errorf("walk: more than %d descriptors", w.maxNodes)
+	}
+
+	if err := w.visit(ctx, n); err != nil {
+		if errors.Is(err, SkipChildren) {
+			return nil
+		}
+		return err
+	}
+
+	mt := desc.MediaType
+	if !mt.IsIndex() && !mt.IsImage() {
+		return nil
+	}
+	b, err := w.manifest(ctx, desc)
+	if err != nil {
+		return err
+	}
+	if mt.IsIndex() {
+		idx, err := v1.ParseIndexManifest(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("walk: parsing index %s: %w", desc.Digest, err)
+		}
+		for _, child := range idx.Manifests {
+			if err := w.walk(ctx, n.child(child, Child)); err != nil {
+				return err
+			}
+		}
+	} else {
+		m, err := v1.ParseManifest(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("walk: parsing manifest %s: %w", desc.Digest, err)
+		}
+		if err := w.walk(ctx, n.child(m.Config, Config)); err != nil {
+			return err
+		}
+		for _, l := range m.Layers {
+			if err := w.walk(ctx, n.child(l, Layer)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if w.referrers == nil {
+		return nil
+	}
+	refs, err := w.referrers.Referrers(ctx, desc)
+	if err != nil {
+		return fmt.Errorf("walk: listing referrers of %s: %w", desc.Digest, err)
+	}
+	for _, r := range refs {
+		if err := w.walk(ctx, n.child(r, Referrer)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Node) child(desc v1.Descriptor, rel Relation) *Node {
+	return &Node{
+		Descriptor: desc,
+		Relation:   rel,
+		Parent:     n,
+		Depth:      n.Depth + 1,
+	}
+}
+
+// manifest returns the verified contents of desc.
+func (w *walker) manifest(ctx context.Context, desc v1.Descriptor) ([]byte, error) {
+	if desc.Size > w.maxManifestSize {
+		return nil, fmt.Errorf("walk: manifest %s is %d bytes, more than the limit of %d", desc.Digest, desc.Size, w.maxManifestSize)
+	}
+	b := desc.Data
+	if b == nil {
+		var err error
+		if b, err = w.fetcher.Manifest(ctx, desc); err != nil {
+			return nil, fmt.Errorf("walk: fetching %s: %w", desc.Digest, err)
+		}
+	}
+	if int64(len(b)) != desc.Size {
+		return nil, fmt.Errorf("walk: manifest %s is %d bytes, descriptor says %d", desc.Digest, len(b), desc.Size)
+	}
+	h, _, err := v1.SHA256(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if desc.Digest.Algorithm == h.Algorithm && h != desc.Digest {
+		return nil, fmt.Errorf("walk: manifest %s has digest %s", desc.Digest, h)
+	}
+	return b, nil
+}
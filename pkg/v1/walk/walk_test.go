@@ -0,0 +1,324 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package walk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// mapFetcher serves manifests from memory.
+type mapFetcher map[v1.Hash][]byte
+
+func (f mapFetcher) Manifest(_ context.Context, desc v1.Descriptor) ([]byte, error) {
+	b, ok := f[desc.Digest]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", desc.Digest)
+	}
+	return b, nil
+}
+
+// add adds the manifests of idx and its children to f, and returns idx's
+// descriptor.
+func (f mapFetcher) add(t *testing.T, idx v1.ImageIndex) v1.Descriptor {
+	t.Helper()
+	desc, err := partial.Descriptor(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f[desc.Digest], err = idx.RawManifest(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, child := range m.Manifests {
+		img, err := idx.Image(child.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f[child.Digest], err = img.RawManifest(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return *desc
+}
+
+// record returns a visitor that records the relation and digest of each node.
+func record(got *[]string) Visitor {
+	return func(_ context.Context, n *Node) error {
+		*got = append(*got, fmt.Sprintf("%s@%d %s", n.Relation, n.Depth, n.Descriptor.Digest))
+		return nil
+	}
+}
+
+// expected returns what record records for idx, each of whose images has one
+// layer.
+func expected(t *testing.T, idx v1.ImageIndex) []string {
+	t.Helper()
+	h, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{fmt.Sprintf("root@0 %s", h)}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, child := range m.Manifests {
+		img, err := idx.Image(child.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		im, err := img.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want,
+			fmt.Sprintf("child@1 %s", child.Digest),
+			fmt.Sprintf("config@2 %s", im.Config.Digest),
+			fmt.Sprintf("layer@2 %s", im.Layers[0].Digest),
+		)
+	}
+	return want
+}
+
+func equal(t *testing.T, got, want []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("visited:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDescriptors(t *testing.T) {
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := mapFetcher{}
+	root := f.add(t, idx)
+
+	var got []string
+	if err := Descriptors(context.Background(), root, f, record(&got)); err != nil {
+		t.Fatal(err)
+	}
+	equal(t, got, expected(t, idx))
+
+	t.Run("embedded data", func(t *testing.T) {
+		root := root
+		root.Data = f[root.Digest]
+		var got []string
+		// Only the children need fetching.
+		children := mapFetcher{}
+		for h, b := range f {
+			if h != root.Digest {
+				children[h] = b
+			}
+		}
+		if err := Descriptors(context.Background(), root, children, record(&got)); err != nil {
+			t.Fatal(err)
+		}
+		equal(t, got, expected(t, idx))
+	})
+
+	t.Run("skip children", func(t *testing.T) {
+		var got []string
+		visit := func(ctx context.Context, n *Node) error {
+			record(&got)(ctx, n)
+			if n.Relation == Child {
+				return SkipChildren
+			}
+			return nil
+		}
+		if err := Descriptors(context.Background(), root, f, visit); err != nil {
+			t.Fatal(err)
+		}
+		want := expected(t, idx)
+		equal(t, got, []string{want[0], want[1], want[4]})
+	})
+
+	t.Run("visitor error", func(t *testing.T) {
+		want := errors.New("stop")
+		visit := func(context.Context, *Node) error { return want }
+		if err := Descriptors(context.Background(), root, f, visit); !errors.Is(err, want) {
+			t.Errorf("Descriptors() = %v, want %v", err, want)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := Descriptors(ctx, root, f, record(new([]string))); !errors.Is(err, context.Canceled) {
+			t.Errorf("Descriptors() = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"max depth", []Option{WithMaxDepth(1)}},
+		{"max nodes", []Option{WithMaxNodes(3)}},
+		{"max manifest size", []Option{WithMaxManifestSize(root.Size - 1)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Descriptors(context.Background(), root, f, record(new([]string)), tc.opts...); err == nil {
+				t.Error("Descriptors() = nil error")
+			}
+		})
+	}
+
+	t.Run("referrers", func(t *testing.T) {
+		if err := Descriptors(context.Background(), root, f, record(new([]string)), WithReferrers()); err == nil {
+			t.Error("Descriptors() = nil error for a Fetcher without Referrers")
+		}
+	})
+}
+
+func TestVerify(t *testing.T) {
+	idx, err := random.Index(1024, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := mapFetcher{}
+	root := f.add(t, idx)
+
+	b := f[root.Digest]
+	f[root.Digest] = []byte(strings.Replace(string(b), "sha256", "sha257", 1))
+	if err := Descriptors(context.Background(), root, f, record(new([]string))); err == nil || !strings.Contains(err.Error(), "has digest") {
+		t.Errorf("Descriptors() = %v, want digest mismatch", err)
+	}
+
+	f[root.Digest] = append(b, ' ')
+	if err := Descriptors(context.Background(), root, f, record(new([]string))); err == nil || !strings.Contains(err.Error(), "bytes") {
+		t.Errorf("Descriptors() = %v, want size mismatch", err)
+	}
+}
+
+func TestSharedBlobs(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same image under two platforms is visited once.
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+	)
+	f := mapFetcher{}
+	root := f.add(t, idx)
+	var got []string
+	if err := Descriptors(context.Background(), root, f, record(&got)); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Errorf("visited %d nodes, want 4:\n%s", len(got), strings.Join(got, "\n"))
+	}
+}
+
+func TestRemote(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	repo, err := name.NewRepository(strings.TrimPrefix(s.URL, "http://") + "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := random.Index(1024, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(repo.Tag("latest"), idx); err != nil {
+		t.Fatal(err)
+	}
+	root, err := partial.Descriptor(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Attach an image to the index.
+	sig, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig = mutate.MediaType(sig, types.OCIManifestSchema1)
+	sig = mutate.Subject(sig, *root).(v1.Image)
+	if err := remote.Write(repo.Tag("sig"), sig); err != nil {
+		t.Fatal(err)
+	}
+	// The registry lacks the referrers API, so it's listed by the fallback tag.
+	fallback := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex), mutate.IndexAddendum{Add: sig})
+	if err := remote.WriteIndex(repo.Tag(strings.Replace(root.Digest.String(), ":", "-", 1)), fallback); err != nil {
+		t.Fatal(err)
+	}
+	sigDigest, err := sig.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigm, err := sig.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	if err := Descriptors(context.Background(), *root, Remote(repo), record(&got), WithReferrers()); err != nil {
+		t.Fatal(err)
+	}
+	want := append(expected(t, idx),
+		fmt.Sprintf("referrer@1 %s", sigDigest),
+		fmt.Sprintf("config@2 %s", sigm.Config.Digest),
+		fmt.Sprintf("layer@2 %s", sigm.Layers[0].Digest),
+	)
+	equal(t, got, want)
+}
+
+func TestLayout(t *testing.T) {
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := layout.Write(t.TempDir(), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	root, err := partial.Descriptor(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	if err := Descriptors(context.Background(), *root, Layout(p), record(&got)); err != nil {
+		t.Fatal(err)
+	}
+	equal(t, got, expected(t, idx))
+}
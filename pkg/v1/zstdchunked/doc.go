@@ -0,0 +1,25 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package zstdchunked produces and reads zstd:chunked layers, which podman
+// and CRI-O can pull partially, fetching only the files they don't already
+// have. See:
+// https://github.com/containers/storage/blob/main/docs/containers-storage-zstd-chunked.md
+//
+// A zstd:chunked layer is a zstd-compressed tarball, readable by any
+// runtime, in which every file's contents are compressed as a separate
+// frame. Skippable frames at its end, which zstd decoders ignore, hold a
+// table of contents (TOC) listing each file's frame, the tar-split data
+// needed to rebuild the tarball exactly, and a footer that locates both.
+package zstdchunked
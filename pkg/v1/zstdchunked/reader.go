@@ -0,0 +1,230 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zstdchunked
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/google/go-containerregistry/internal/and"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	kzstd "github.com/klauspost/compress/zstd"
+)
+
+// Reader reads the TOC and files of a zstd:chunked layer.
+type Reader struct {
+	sr        *io.SectionReader
+	toc       *TOC
+	tocDigest v1.Hash
+
+	// files maps cleaned names to the indexes of their entries in toc.
+	files map[string]int
+}
+
+// Open returns a Reader for the zstd:chunked layer in sr. Only its footer and
+// TOC are read.
+func Open(sr *io.SectionReader) (*Reader, error) {
+	off, size, err := readFooter(sr)
+	if err != nil {
+		return nil, err
+	}
+	manifest := make([]byte, size)
+	if _, err := sr.ReadAt(manifest, off); err != nil {
+		return nil, fmt.Errorf("reading TOC: %w", err)
+	}
+	h := sha256.Sum256(manifest)
+
+	zr, err := kzstd.NewReader(bytes.NewReader(manifest))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	toc := &TOC{}
+	if err := json.NewDecoder(zr).Decode(toc); err != nil {
+		return nil, fmt.Errorf("parsing TOC: %w", err)
+	}
+
+	r := &Reader{
+		sr:        sr,
+		toc:       toc,
+		tocDigest: v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(h[:])},
+		files:     make(map[string]int, len(toc.Entries)),
+	}
+	for i, e := range toc.Entries {
+		if e.Type != "chunk" {
+			r.files[clean(e.Name)] = i
+		}
+	}
+	return r, nil
+}
+
+// Remote returns a Reader for the zstd:chunked layer at ref that reads its
+// TOC and files with ranged reads, using remote.BlobReader.
+func Remote(ref name.Digest, options ...remote.Option) (*Reader, error) {
+	sr, err := remote.BlobReader(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	return Open(sr)
+}
+
+// readFooter returns the offset and size of the compressed TOC.
+func readFooter(sr *io.SectionReader) (int64, int64, error) {
+	for _, size := range []int64{footerSize, legacyFooterSize} {
+		start := sr.Size() - skippableHeaderSize - size
+		if start < 0 {
+			continue
+		}
+		b := make([]byte, skippableHeaderSize+size)
+		if _, err := sr.ReadAt(b, start); err != nil {
+			return 0, 0, fmt.Errorf("reading footer: %w", err)
+		}
+		if !bytes.Equal(b[:4], skippableFrameMagic) || binary.LittleEndian.Uint32(b[4:8]) != uint32(size) {
+			continue
+		}
+		footer := b[skippableHeaderSize:]
+		if !bytes.Equal(footer[size-8:], footerMagic) {
+			continue
+		}
+		if typ := binary.LittleEndian.Uint64(footer[24:]); typ != manifestTypeCRFS {
+			return 0, 0, fmt.Errorf("unsupported TOC type %d", typ)
+		}
+		off, n := int64(binary.LittleEndian.Uint64(footer[0:])), int64(binary.LittleEndian.Uint64(footer[8:]))
+		if off < 0 || n < 0 || off+n > sr.Size() {
+			return 0, 0, fmt.Errorf("TOC at %d:%d is out of bounds", off, n)
+		}
+		return off, n, nil
+	}
+	return 0, 0, errors.New("not a zstd:chunked layer: no footer")
+}
+
+// TOC returns the layer's table of contents.
+func (r *Reader) TOC() *TOC {
+	return r.toc
+}
+
+// TOCDigest returns the digest of the layer's compressed TOC, which should
+// match its ManifestChecksumAnnotation.
+func (r *Reader) TOCDigest() v1.Hash {
+	return r.tocDigest
+}
+
+// Lookup returns the TOC entry for the file at path.
+func (r *Reader) Lookup(path string) (*FileMetadata, bool) {
+	i, ok := r.files[clean(path)]
+	if !ok {
+		return nil, false
+	}
+	return r.toc.Entries[i], true
+}
+
+// OpenFile returns a reader for the contents of the regular file at path,
+// which reads only the frames of the layer that hold it.
+func (r *Reader) OpenFile(path string) (io.ReadCloser, error) {
+	i, ok := r.files[clean(path)]
+	if !ok {
+		return nil, fmt.Errorf("%s: file not found", path)
+	}
+	e := r.toc.Entries[i]
+	if e.Type != "reg" {
+		return nil, fmt.Errorf("%s: not a regular file", path)
+	}
+
+	// The file's first chunk is in its own entry, followed by the rest.
+	chunks := []*FileMetadata{e}
+	for _, c := range r.toc.Entries[i+1:] {
+		if c.Type != "chunk" {
+			break
+		}
+		chunks = append(chunks, c)
+	}
+	readers := []io.Reader{}
+	for _, c := range chunks {
+		if c.ChunkType == "zeros" {
+			readers = append(readers, io.LimitReader(zeros{}, c.ChunkSize))
+			continue
+		}
+		if c.EndOffset <= c.Offset {
+			continue
+		}
+		readers = append(readers, io.NewSectionReader(r.sr, c.Offset, c.EndOffset-c.Offset))
+	}
+
+	zr, err := kzstd.NewReader(io.MultiReader(readers...))
+	if err != nil {
+		return nil, err
+	}
+	return &and.ReadCloser{
+		Reader: io.LimitReader(zr, e.Size),
+		CloseFunc: func() error {
+			zr.Close()
+			return nil
+		},
+	}, nil
+}
+
+// Verify checks the contents of every regular file in the layer against the
+// digest in its TOC entry.
+func (r *Reader) Verify() error {
+	for _, e := range r.toc.Entries {
+		if e.Type != "reg" || e.Digest == "" {
+			continue
+		}
+		want, err := v1.NewHash(e.Digest)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Name, err)
+		}
+		rc, err := r.OpenFile(e.Name)
+		if err != nil {
+			return err
+		}
+		got, n, err := v1.SHA256(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Name, err)
+		}
+		if n != e.Size {
+			return fmt.Errorf("%s: read %d bytes, want %d", e.Name, n, e.Size)
+		}
+		if got != want {
+			return fmt.Errorf("%s: digest is %s, want %s", e.Name, got, want)
+		}
+	}
+	return nil
+}
+
+// zeros reads as an endless stream of zeros, to fill holes in sparse files.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func clean(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
@@ -0,0 +1,464 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zstdchunked
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/go-containerregistry/internal/zstd"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	kzstd "github.com/klauspost/compress/zstd"
+)
+
+// The annotations that locate a zstd:chunked layer's TOC and tar-split data,
+// so that clients needn't read its footer first.
+const (
+	ManifestChecksumAnnotation = "io.github.containers.zstd-chunked.manifest-checksum"
+	ManifestPositionAnnotation = "io.github.containers.zstd-chunked.manifest-position"
+	TarSplitChecksumAnnotation = "io.github.containers.zstd-chunked.tarsplit-checksum"
+	TarSplitPositionAnnotation = "io.github.containers.zstd-chunked.tarsplit-position"
+)
+
+const (
+	// manifestTypeCRFS is the only type of TOC.
+	manifestTypeCRFS = 1
+
+	// The footer's data is seven little-endian uint64s followed by
+	// footerMagic. Older layers have only the first four.
+	footerSize       = 8*7 + 8
+	legacyFooterSize = 8*4 + 8
+
+	skippableHeaderSize = 8
+)
+
+var (
+	// skippableFrameMagic starts a zstd skippable frame, in little-endian.
+	skippableFrameMagic = []byte{0x50, 0x2a, 0x4d, 0x18}
+
+	footerMagic = []byte("GNUlInUx")
+
+	crc64Table = crc64.MakeTable(crc64.ISO)
+)
+
+// TOC is the table of contents of a zstd:chunked layer.
+type TOC struct {
+	Version        int             `json:"version"`
+	Entries        []*FileMetadata `json:"entries"`
+	TarSplitDigest string          `json:"tarSplitDigest,omitempty"`
+}
+
+// FileMetadata is an entry in a TOC. Regular files whose contents are split
+// into chunks are followed by an entry of Type "chunk" for each chunk after
+// the first.
+type FileMetadata struct {
+	Type       string            `json:"type"`
+	Name       string            `json:"name"`
+	Linkname   string            `json:"linkName,omitempty"`
+	Mode       int64             `json:"mode,omitempty"`
+	Size       int64             `json:"size,omitempty"`
+	UID        int               `json:"uid,omitempty"`
+	GID        int               `json:"gid,omitempty"`
+	ModTime    *time.Time        `json:"modtime,omitempty"`
+	AccessTime *time.Time        `json:"accesstime,omitempty"`
+	ChangeTime *time.Time        `json:"changetime,omitempty"`
+	Devmajor   int64             `json:"devMajor,omitempty"`
+	Devminor   int64             `json:"devMinor,omitempty"`
+	Xattrs     map[string]string `json:"xattrs,omitempty"`
+	Digest     string            `json:"digest,omitempty"`
+
+	// Offset and EndOffset bound the frames that hold the file's contents,
+	// or this chunk of them, in the compressed layer.
+	Offset    int64 `json:"offset,omitempty"`
+	EndOffset int64 `json:"endOffset,omitempty"`
+
+	ChunkSize   int64  `json:"chunkSize,omitempty"`
+	ChunkOffset int64  `json:"chunkOffset,omitempty"`
+	ChunkDigest string `json:"chunkDigest,omitempty"`
+
+	// ChunkType is "zeros" for chunks that are holes in sparse files, which
+	// have no frames.
+	ChunkType string `json:"chunkType,omitempty"`
+}
+
+var typeNames = map[byte]string{
+	tar.TypeReg:     "reg",
+	tar.TypeRegA:    "reg",
+	tar.TypeDir:     "dir",
+	tar.TypeSymlink: "symlink",
+	tar.TypeLink:    "hardlink",
+	tar.TypeChar:    "char",
+	tar.TypeBlock:   "block",
+	tar.TypeFifo:    "fifo",
+}
+
+type options struct {
+	level int
+}
+
+// Option is a functional option for Layer.
+type Option func(*options)
+
+// WithCompressionLevel sets the zstd compression level, interpreted like the
+// zstd command line levels (1-22). The default is 3.
+func WithCompressionLevel(level int) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// Layer returns l recompressed as a zstd:chunked layer. Its descriptor is
+// annotated with the positions of its TOC and tar-split data.
+//
+// The layer is built in memory.
+func Layer(l v1.Layer, opts ...Option) (v1.Layer, error) {
+	o := &options{level: 3}
+	for _, opt := range opts {
+		opt(o)
+	}
+	rc, err := l.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var buf bytes.Buffer
+	w, err := newWriter(&buf, o.level)
+	if err != nil {
+		return nil, err
+	}
+	diffID := sha256.New()
+	if err := w.writeTar(io.TeeReader(rc, diffID)); err != nil {
+		return nil, err
+	}
+	annotations, err := w.close()
+	if err != nil {
+		return nil, err
+	}
+
+	b := buf.Bytes()
+	digest := sha256.Sum256(b)
+	return &layer{
+		b:           b,
+		digest:      v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(digest[:])},
+		diffID:      v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(diffID.Sum(nil))},
+		annotations: annotations,
+	}, nil
+}
+
+type layer struct {
+	b           []byte
+	digest      v1.Hash
+	diffID      v1.Hash
+	annotations map[string]string
+}
+
+var _ v1.Layer = (*layer)(nil)
+
+func (l *layer) Digest() (v1.Hash, error) { return l.digest, nil }
+func (l *layer) DiffID() (v1.Hash, error) { return l.diffID, nil }
+func (l *layer) Size() (int64, error)     { return int64(len(l.b)), nil }
+
+func (l *layer) MediaType() (types.MediaType, error) {
+	return types.OCILayerZStd, nil
+}
+
+func (l *layer) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l.b)), nil
+}
+
+func (l *layer) Uncompressed() (io.ReadCloser, error) {
+	rc, _ := l.Compressed()
+	return zstd.UnzipReadCloser(rc)
+}
+
+// Descriptor implements partial.withDescriptor.
+func (l *layer) Descriptor() (*v1.Descriptor, error) {
+	return &v1.Descriptor{
+		MediaType:   types.OCILayerZStd,
+		Size:        int64(len(l.b)),
+		Digest:      l.digest,
+		Annotations: l.annotations,
+	}, nil
+}
+
+// countingWriter counts the bytes written to the layer, to record offsets.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// recorder records what a tar.Reader reads from the tarball, so that the
+// raw headers and padding between files' contents can be kept for tar-split.
+type recorder struct {
+	r   io.Reader
+	buf bytes.Buffer
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf.Write(p[:n])
+	return n, err
+}
+
+// tarSplitEntry is an entry in tar-split data: either a segment of raw
+// tarball bytes, or a file whose contents are elided.
+type tarSplitEntry struct {
+	Type     int    `json:"type"`
+	Name     string `json:"name,omitempty"`
+	NameRaw  []byte `json:"name_raw,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	Payload  []byte `json:"payload"`
+	Position int    `json:"position"`
+}
+
+const (
+	tarSplitFile    = 1
+	tarSplitSegment = 2
+)
+
+type writer struct {
+	out      *countingWriter
+	zw       *kzstd.Encoder
+	toc      TOC
+	tarSplit *json.Encoder
+	tsBuf    bytes.Buffer
+	position int
+}
+
+func newWriter(w io.Writer, level int) (*writer, error) {
+	out := &countingWriter{w: w}
+	zw, err := kzstd.NewWriter(out, kzstd.WithEncoderLevel(kzstd.EncoderLevelFromZstd(level)))
+	if err != nil {
+		return nil, err
+	}
+	cw := &writer{out: out, zw: zw, toc: TOC{Version: 1, Entries: []*FileMetadata{}}}
+	cw.tarSplit = json.NewEncoder(&cw.tsBuf)
+	return cw, nil
+}
+
+// frame writes b as a separate frame.
+func (w *writer) frame(r io.Reader) error {
+	w.zw.Reset(w.out)
+	if _, err := io.Copy(w.zw, r); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+func (w *writer) segment(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	if err := w.frame(bytes.NewReader(b)); err != nil {
+		return err
+	}
+	return w.entry(tarSplitEntry{Type: tarSplitSegment, Payload: b})
+}
+
+func (w *writer) entry(e tarSplitEntry) error {
+	e.Position = w.position
+	w.position++
+	return w.tarSplit.Encode(e)
+}
+
+func (w *writer) writeTar(r io.Reader) error {
+	rec := &recorder{r: r}
+	tr := tar.NewReader(rec)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// Everything read so far is padding and headers.
+		if err := w.segment(rec.buf.Bytes()); err != nil {
+			return err
+		}
+		rec.buf.Reset()
+
+		fm, err := fileMetadata(hdr)
+		if err != nil {
+			return err
+		}
+		digest, crc := sha256.New(), crc64.New(crc64Table)
+		if hdr.Size > 0 {
+			fm.Offset = w.out.n
+			if err := w.frame(io.TeeReader(tr, io.MultiWriter(digest, crc))); err != nil {
+				return err
+			}
+			fm.EndOffset = w.out.n
+		}
+		// The contents are in the frame, not tar-split.
+		rec.buf.Reset()
+		if fm.Type == "reg" {
+			fm.Digest = "sha256:" + hex.EncodeToString(digest.Sum(nil))
+		}
+		w.toc.Entries = append(w.toc.Entries, fm)
+
+		e := tarSplitEntry{Type: tarSplitFile, Size: hdr.Size, Payload: crc.Sum(nil)}
+		if utf8.ValidString(hdr.Name) {
+			e.Name = hdr.Name
+		} else {
+			e.NameRaw = []byte(hdr.Name)
+		}
+		if err := w.entry(e); err != nil {
+			return err
+		}
+	}
+
+	// Keep the end-of-archive blocks and anything after them.
+	if _, err := io.Copy(ioutil.Discard, rec); err != nil {
+		return err
+	}
+	return w.segment(rec.buf.Bytes())
+}
+
+// close writes the TOC, tar-split data and footer, returning the layer's
+// annotations.
+func (w *writer) close() (map[string]string, error) {
+	tarSplit, err := w.compress(w.tsBuf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	w.toc.TarSplitDigest = digestOf(tarSplit)
+	toc, err := json.Marshal(w.toc)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := w.compress(toc)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestOffset := w.out.n + skippableHeaderSize
+	if err := w.skippable(manifest); err != nil {
+		return nil, err
+	}
+	tarSplitOffset := w.out.n + skippableHeaderSize
+	if err := w.skippable(tarSplit); err != nil {
+		return nil, err
+	}
+
+	footer := make([]byte, footerSize)
+	for i, v := range []int64{
+		manifestOffset, int64(len(manifest)), int64(len(toc)), manifestTypeCRFS,
+		tarSplitOffset, int64(len(tarSplit)), int64(w.tsBuf.Len()),
+	} {
+		binary.LittleEndian.PutUint64(footer[8*i:], uint64(v))
+	}
+	copy(footer[8*7:], footerMagic)
+	if err := w.skippable(footer); err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		ManifestChecksumAnnotation: digestOf(manifest),
+		ManifestPositionAnnotation: fmt.Sprintf("%d:%d:%d:%d", manifestOffset, len(manifest), len(toc), manifestTypeCRFS),
+		TarSplitChecksumAnnotation: digestOf(tarSplit),
+		TarSplitPositionAnnotation: fmt.Sprintf("%d:%d:%d", tarSplitOffset, len(tarSplit), w.tsBuf.Len()),
+	}, nil
+}
+
+func (w *writer) compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w.zw.Reset(&buf)
+	if _, err := w.zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// skippable writes b in a skippable frame, which zstd decoders ignore.
+func (w *writer) skippable(b []byte) error {
+	hdr := make([]byte, skippableHeaderSize)
+	copy(hdr, skippableFrameMagic)
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(len(b)))
+	if _, err := w.out.Write(hdr); err != nil {
+		return err
+	}
+	_, err := w.out.Write(b)
+	return err
+}
+
+func fileMetadata(hdr *tar.Header) (*FileMetadata, error) {
+	typ, ok := typeNames[hdr.Typeflag]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tar entry type %q for %s", hdr.Typeflag, hdr.Name)
+	}
+	fm := &FileMetadata{
+		Type:     typ,
+		Name:     hdr.Name,
+		Linkname: hdr.Linkname,
+		Mode:     hdr.Mode,
+		UID:      hdr.Uid,
+		GID:      hdr.Gid,
+		Devmajor: hdr.Devmajor,
+		Devminor: hdr.Devminor,
+	}
+	if typ == "reg" {
+		fm.Size = hdr.Size
+	}
+	if !hdr.ModTime.IsZero() {
+		t := hdr.ModTime.UTC()
+		fm.ModTime = &t
+	}
+	if !hdr.AccessTime.IsZero() {
+		t := hdr.AccessTime.UTC()
+		fm.AccessTime = &t
+	}
+	if !hdr.ChangeTime.IsZero() {
+		t := hdr.ChangeTime.UTC()
+		fm.ChangeTime = &t
+	}
+	for k, v := range hdr.PAXRecords {
+		if name := strings.TrimPrefix(k, "SCHILY.xattr."); name != k {
+			if fm.Xattrs == nil {
+				fm.Xattrs = map[string]string{}
+			}
+			fm.Xattrs[name] = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+	}
+	return fm, nil
+}
+
+func digestOf(b []byte) string {
+	h := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(h[:])
+}
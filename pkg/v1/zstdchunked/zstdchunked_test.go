@@ -0,0 +1,279 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zstdchunked
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	kzstd "github.com/klauspost/compress/zstd"
+)
+
+var (
+	hello = "#!/bin/sh\necho hello\n"
+	big   = strings.Repeat("0123456789abcdef", 1000)
+)
+
+func tarball(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range []struct {
+		hdr      tar.Header
+		contents string
+	}{
+		{hdr: tar.Header{Name: "usr/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{hdr: tar.Header{Name: "usr/bin/hello", Typeflag: tar.TypeReg, Mode: 0755, ModTime: time.Unix(1600000000, 0)}, contents: hello},
+		{hdr: tar.Header{Name: "usr/bin/hi", Typeflag: tar.TypeSymlink, Linkname: "hello"}},
+		{hdr: tar.Header{Name: "usr/share/empty", Typeflag: tar.TypeReg, Mode: 0644}},
+		{hdr: tar.Header{Name: "usr/share/big", Typeflag: tar.TypeReg, Mode: 0644, PAXRecords: map[string]string{"SCHILY.xattr.user.k": "v"}}, contents: big},
+	} {
+		f.hdr.Size = int64(len(f.contents))
+		if err := tw.WriteHeader(&f.hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func chunked(t *testing.T) (v1.Layer, []byte, []byte) {
+	t.Helper()
+	tb := tarball(t)
+	l, err := Layer(static.NewLayer(tb, types.OCIUncompressedLayer))
+	if err != nil {
+		t.Fatalf("Layer() = %v", err)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, tb, b
+}
+
+func readAll(t *testing.T, rc io.ReadCloser) string {
+	t.Helper()
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func check(t *testing.T, r *Reader) {
+	t.Helper()
+	names := []string{}
+	for _, e := range r.TOC().Entries {
+		names = append(names, e.Type+":"+e.Name)
+	}
+	if got, want := fmt.Sprint(names), "[dir:usr/ reg:usr/bin/hello symlink:usr/bin/hi reg:usr/share/empty reg:usr/share/big]"; got != want {
+		t.Errorf("TOC entries = %s, want %s", got, want)
+	}
+	if e, ok := r.Lookup("/usr/bin/hi"); !ok || e.Linkname != "hello" {
+		t.Errorf("Lookup(/usr/bin/hi) = %+v, %t", e, ok)
+	}
+	if e, ok := r.Lookup("usr/share/big"); !ok || e.Xattrs["user.k"] != "dg==" {
+		t.Errorf("Lookup(usr/share/big) = %+v, %t", e, ok)
+	}
+	for path, want := range map[string]string{"usr/bin/hello": hello, "usr/share/big": big, "usr/share/empty": ""} {
+		rc, err := r.OpenFile(path)
+		if err != nil {
+			t.Fatalf("OpenFile(%s) = %v", path, err)
+		}
+		if got := readAll(t, rc); got != want {
+			t.Errorf("OpenFile(%s) = %d bytes, want %d", path, len(got), len(want))
+		}
+	}
+	if _, err := r.OpenFile("usr/bin/hi"); err == nil {
+		t.Error("OpenFile() of a symlink should fail")
+	}
+	if err := r.Verify(); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+}
+
+func TestLayer(t *testing.T) {
+	l, tb, b := chunked(t)
+
+	// Any zstd decoder gets the original tarball back.
+	if got := readAll(t, mustUncompressed(t, l)); got != string(tb) {
+		t.Error("Uncompressed() doesn't match the original tarball")
+	}
+	want, _, err := v1.SHA256(bytes.NewReader(tb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := l.DiffID(); err != nil || got != want {
+		t.Errorf("DiffID() = %s, %v, want %s", got, err, want)
+	}
+	if got, want := mustDigest(t, l), digest(b); got != want {
+		t.Errorf("Digest() = %s, want %s", got, want)
+	}
+
+	r, err := Open(io.NewSectionReader(bytes.NewReader(b), 0, int64(len(b))))
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	check(t, r)
+
+	desc, err := partial.Descriptor(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.MediaType != types.OCILayerZStd {
+		t.Errorf("MediaType = %s, want %s", desc.MediaType, types.OCILayerZStd)
+	}
+	if got := desc.Annotations[ManifestChecksumAnnotation]; got != r.TOCDigest().String() {
+		t.Errorf("manifest checksum = %s, want %s", got, r.TOCDigest())
+	}
+	if got := desc.Annotations[TarSplitChecksumAnnotation]; got != r.TOC().TarSplitDigest {
+		t.Errorf("tar-split checksum = %s, want %s", got, r.TOC().TarSplitDigest)
+	}
+
+	// The tar-split data and the files' contents rebuild the tarball.
+	pos := strings.Split(desc.Annotations[TarSplitPositionAnnotation], ":")
+	off, _ := strconv.Atoi(pos[0])
+	n, _ := strconv.Atoi(pos[1])
+	zr, err := kzstd.NewReader(bytes.NewReader(b[off : off+n]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var rebuilt bytes.Buffer
+	s := bufio.NewScanner(zr)
+	for i := 0; s.Scan(); i++ {
+		var e tarSplitEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Position != i {
+			t.Errorf("position = %d, want %d", e.Position, i)
+		}
+		switch e.Type {
+		case tarSplitSegment:
+			rebuilt.Write(e.Payload)
+		case tarSplitFile:
+			if e.Size == 0 {
+				continue
+			}
+			rc, err := r.OpenFile(e.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rebuilt.WriteString(readAll(t, rc))
+		}
+	}
+	if rebuilt.String() != string(tb) {
+		t.Errorf("rebuilt tarball is %d bytes, want %d", rebuilt.Len(), len(tb))
+	}
+}
+
+func TestOpenNotChunked(t *testing.T) {
+	var buf bytes.Buffer
+	zw, err := kzstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(tarball(t))
+	zw.Close()
+	if _, err := Open(io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len()))); err == nil {
+		t.Error("Open() of a plain zstd layer should fail")
+	}
+}
+
+func TestRemote(t *testing.T) {
+	l, _, b := chunked(t)
+	h := mustDigest(t, l)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case fmt.Sprintf("/v2/foo/blobs/%s", h):
+			if r.Method == http.MethodGet && r.Header.Get("Range") == "" {
+				t.Errorf("Unexpected GET of the whole layer")
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+		default:
+			t.Fatalf("Unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := name.NewDigest(fmt.Sprintf("%s/foo@%s", u.Host, h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Remote(ref)
+	if err != nil {
+		t.Fatalf("Remote() = %v", err)
+	}
+	check(t, r)
+}
+
+func mustUncompressed(t *testing.T, l v1.Layer) io.ReadCloser {
+	t.Helper()
+	rc, err := l.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rc
+}
+
+func mustDigest(t *testing.T, l v1.Layer) v1.Hash {
+	t.Helper()
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func digest(b []byte) v1.Hash {
+	h, _, _ := v1.SHA256(bytes.NewReader(b))
+	return h
+}
@@ -222,6 +222,11 @@ func (in *IndexManifest) DeepCopyInto(out *IndexManifest) {
 			(*out)[key] = val
 		}
 	}
+	if in.Subject != nil {
+		in, out := &in.Subject, &out.Subject
+		*out = new(Descriptor)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -253,6 +258,11 @@ func (in *Manifest) DeepCopyInto(out *Manifest) {
 			(*out)[key] = val
 		}
 	}
+	if in.Subject != nil {
+		in, out := &in.Subject, &out.Subject
+		*out = new(Descriptor)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 